@@ -0,0 +1,219 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// defaultAskMaxCount is the number of chunks given to the chat model when the question does not set it
+const defaultAskMaxCount = 5
+
+// AskHandler handles the questions answered from the stored documents (POST /ask): the chunks most similar to the
+// question are retrieved, and the chat model (see store.SetChatModel) answers from them, citing the chunks it used.
+// With an "Accept: text/event-stream" header, the answer is streamed as Server-Sent Events (see streamAnswer).
+// The search and the answer are canceled when the client disconnects.
+func AskHandler(w http.ResponseWriter, r *http.Request, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.AskResponse{
+			Success: false,
+			Error:   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	// Parse request body
+	var req models.AskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.AskResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+
+	// Validate required fields
+	if req.Question == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.AskResponse{
+			Success: false,
+			Error:   "Question is required",
+		})
+		return
+	}
+
+	if req.MaxCount <= 0 {
+		req.MaxCount = defaultAskMaxCount
+	}
+
+	if err := store.ValidateExpandContext(req.ExpandContext); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.AskResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateResponseLanguage(req.ResponseLanguage); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.AskResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	filters, err := store.ParseMetadataFilters(req.Filters)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.AskResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid filters: %v", err),
+		})
+		return
+	}
+
+	// The answer is made from the content of the documents
+	if !RequestRole(r).CanReadContent() {
+		w.WriteHeader(http.StatusForbidden)
+		json.NewEncoder(w).Encode(models.AskResponse{
+			Success: false,
+			Error:   "The role of the API key does not allow reading document content",
+		})
+		return
+	}
+
+	// Avoid embedding the question when it cannot be answered
+	if store.ChatModelID() == "" {
+		w.WriteHeader(errorStatus(store.ErrChatModelMissing))
+		json.NewEncoder(w).Encode(models.AskResponse{
+			Success: false,
+			Error:   "No chat model configured (set CHAT_MODEL)",
+		})
+		return
+	}
+
+	// The answer ends when the client disconnects
+	ctx := UsageContext(r.Context(), r)
+
+	// Resolve the collection of the documents
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, req.Collection)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.AskResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	docs, _, err := store.SearchByText(ctx, *openaiClient, redisClient, collection.ModelID(embeddingModelId), collection.IndexName, req.Question, req.MaxCount, store.SearchOptions{
+		Label:       req.Label,
+		MinQuality:  req.MinQuality,
+		MaxDistance: req.DistanceThreshold,
+		Filters:     filters,
+	}, store.TextSearchBudget{})
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.AskResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to search the documents: %v", err),
+		})
+		return
+	}
+	sources := store.TextSearchResults(docs, "", req.DistanceThreshold)
+	if req.ExpandContext > 0 {
+		expandSearchResults(ctx, redisClient, collection.IndexName, sources, req.ExpandContext)
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		streamAnswer(w, ctx, req.Question, sources, req.ResponseLanguage)
+		return
+	}
+
+	answer, citations, err := store.Ask(ctx, req.Question, sources, req.ResponseLanguage)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.AskResponse{
+			Sources: sources,
+			Success: false,
+			Error:   fmt.Sprintf("Failed to answer the question: %v", err),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.AskResponse{
+		Answer:    answer,
+		Citations: citations,
+		Sources:   sources,
+		Model:     store.ChatModelID(),
+		Success:   true,
+	})
+}
+
+// streamAnswer streams the answer of a question as Server-Sent Events: a "token" event by piece of the answer as the
+// chat model generates it, then a "done" event with the whole response (answer, citations and sources). The stream
+// starts with the first piece, so that a chat model failing at once still gets a JSON error response; a failure
+// after the first piece ends the stream with an "error" event.
+func streamAnswer(w http.ResponseWriter, ctx context.Context, question string, sources []models.SimilaritySearchResult, language string) {
+	controller := http.NewResponseController(w)
+	started := false
+	start := func() {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+		started = true
+	}
+
+	answer, citations, err := store.AskStream(ctx, question, sources, language, func(token string) error {
+		if !started {
+			start()
+		}
+		data, _ := json.Marshal(map[string]string{"content": token})
+		fmt.Fprintf(w, "event: token\ndata: %s\n\n", data)
+		return controller.Flush()
+	})
+	if err != nil {
+		if !started {
+			w.WriteHeader(errorStatus(err))
+			json.NewEncoder(w).Encode(models.AskResponse{
+				Sources: sources,
+				Success: false,
+				Error:   fmt.Sprintf("Failed to answer the question: %v", err),
+			})
+			return
+		}
+		if ctx.Err() == nil {
+			fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+			controller.Flush()
+		}
+		return
+	}
+
+	if !started {
+		start()
+	}
+	data, _ := json.Marshal(models.AskResponse{
+		Answer:    answer,
+		Citations: citations,
+		Sources:   sources,
+		Model:     store.ChatModelID(),
+		Success:   true,
+	})
+	fmt.Fprintf(w, "event: done\ndata: %s\n\n", data)
+	controller.Flush()
+}
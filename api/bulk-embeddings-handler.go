@@ -0,0 +1,245 @@
+package api
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxBulkLineSize is the maximum size of a line (a document) of a bulk ingestion request
+const maxBulkLineSize = 16 * 1024 * 1024
+
+// ndjsonContentTypes are the content types accepted by the bulk ingestion endpoint
+var ndjsonContentTypes = map[string]bool{
+	"application/x-ndjson": true,
+	"application/jsonl":    true,
+}
+
+var bulkProgressInterval = 5 * time.Second
+
+// SetBulkProgressInterval sets the interval of the progress lines of the bulk ingestion responses
+func SetBulkProgressInterval(interval time.Duration) {
+	if interval > 0 {
+		bulkProgressInterval = interval
+	}
+}
+
+// BulkCreateEmbeddingsHandler handles bulk ingestion requests: an NDJSON stream of {content,label,labels,metadata,collection,ttl_seconds,dedup}
+// lines, each line stored as a document as soon as it is read (in the collection of the line, or of the collection query
+// parameter). The response is an NDJSON stream reporting the outcome of each line, a progress line at regular intervals
+// (which also keeps the connection alive) and a summary.
+func BulkCreateEmbeddingsHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !ndjsonContentTypes[mediaType] {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Unsupported content type. Use application/x-ndjson",
+		})
+		return
+	}
+
+	// The collection of the lines without collection field
+	defaultCollection, err := store.ResolveCollection(ctx, redisClient, indexName, r.URL.Query().Get("collection"))
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	collections := map[string]store.Collection{defaultCollection.Name: defaultCollection}
+	resolveCollection := func(name string) (store.Collection, error) {
+		if name == "" {
+			return defaultCollection, nil
+		}
+		if collection, ok := collections[name]; ok {
+			return collection, nil
+		}
+		collection, err := store.ResolveCollection(ctx, redisClient, indexName, name)
+		if err == nil {
+			collections[name] = collection
+		}
+		return collection, err
+	}
+
+	// The status is sent before the lines are processed, the outcome of each line is in the stream
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	var mutex sync.Mutex
+	encoder := json.NewEncoder(w)
+	controller := http.NewResponseController(w)
+	writeEvent := func(event any) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		encoder.Encode(event)
+		controller.Flush()
+	}
+
+	progress := models.BulkProgressEvent{Type: models.BulkEventProgress}
+	var progressMutex sync.Mutex
+	currentProgress := func() models.BulkProgressEvent {
+		progressMutex.Lock()
+		defer progressMutex.Unlock()
+		return progress
+	}
+
+	// Report the progress periodically, even while a slow document is embedded
+	done := make(chan struct{})
+	var ticker sync.WaitGroup
+	ticker.Add(1)
+	go func() {
+		defer ticker.Done()
+		t := time.NewTicker(bulkProgressInterval)
+		defer t.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-t.C:
+				writeEvent(currentProgress())
+			}
+		}
+	}()
+
+	scanner := bufio.NewScanner(r.Body)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxBulkLineSize)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		docID, duplicate, err := storeBulkLine(ctx, openaiClient, redisClient, embeddingModelId, line, resolveCollection)
+		event := models.BulkDocumentEvent{Type: models.BulkEventDocument, Line: lineNumber, ID: docID, Status: models.ChunkStatusStored}
+		progressMutex.Lock()
+		progress.Lines++
+		switch {
+		case err != nil:
+			event.Status = models.ChunkStatusFailed
+			event.Error = err.Error()
+			progress.Failed++
+		case duplicate:
+			event.Status = models.ChunkStatusDuplicate
+			progress.Duplicates++
+		default:
+			progress.Stored++
+		}
+		progressMutex.Unlock()
+		writeEvent(event)
+	}
+
+	close(done)
+	ticker.Wait()
+
+	summary := currentProgress()
+	summary.Type = models.BulkEventSummary
+	summary.Success = true
+	if err := scanner.Err(); err != nil {
+		summary.Success = false
+		summary.Error = fmt.Sprintf("Failed to read line %d: %v", lineNumber+1, err)
+	}
+	writeEvent(summary)
+}
+
+// storeBulkLine embeds and stores the document of a line of a bulk ingestion request.
+// It returns the ID of the document, and whether the document is a skipped duplicate.
+func storeBulkLine(ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId string, line []byte, resolveCollection func(name string) (store.Collection, error)) (string, bool, error) {
+	var req models.CreateEmbeddingRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return "", false, fmt.Errorf("Invalid line: %v", err)
+	}
+
+	// The labels are stored together in the label field
+	label, err := store.JoinLabels(req.Label, req.Labels)
+	if err != nil {
+		return "", false, err
+	}
+
+	if req.Content == "" {
+		return "", false, fmt.Errorf("Content is required")
+	}
+
+	ttl, err := store.DocumentTTL(req.TTLSeconds)
+	if err != nil {
+		return "", false, err
+	}
+
+	if err := store.ValidateDedupMode(req.Dedup); err != nil {
+		return "", false, err
+	}
+	if err := store.ValidateIDConflict(req.OnConflict); err != nil {
+		return "", false, err
+	}
+	if req.ID != "" && req.Dedup != "" && req.Dedup != store.DedupOff {
+		return "", false, fmt.Errorf("id and dedup cannot be combined")
+	}
+
+	collection, err := resolveCollection(req.Collection)
+	if err != nil {
+		return "", false, err
+	}
+
+	var docID string
+	overwrite := store.OverwriteOnIDConflict(req.OnConflict)
+	if req.ID != "" {
+		if docID, err = store.CallerDocumentID(collection.KeyPrefix, req.ID); err != nil {
+			return "", false, err
+		}
+		if exists, err := store.DocumentExists(ctx, redisClient, docID); err == nil && exists && !overwrite {
+			return docID, false, store.ErrDocumentExists
+		}
+	} else {
+		var duplicate bool
+		docID, duplicate, err = store.DedupDocumentID(ctx, redisClient, collection, req.Content, req.Dedup)
+		if err != nil || duplicate {
+			return docID, duplicate, err
+		}
+	}
+
+	embedding, err := store.CreateEmbeddingFromText(store.WithUsageLabel(ctx, label), *openaiClient, req.Content, collection.ModelID(embeddingModelId))
+	if err != nil {
+		return "", false, fmt.Errorf("Failed to create embedding: %v", err)
+	}
+
+	if req.ID != "" {
+		err = store.InsertDocument(ctx, redisClient, store.NewDocument(docID, req.Content, embedding, label, req.Metadata, ttl), overwrite)
+	} else {
+		err = store.StoreEmbeddingWithTTL(ctx, redisClient, docID, req.Content, embedding, label, req.Metadata, ttl)
+	}
+	if errors.Is(err, store.ErrDocumentExists) {
+		return docID, false, err
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("Failed to store embedding: %v", err)
+	}
+	return docID, false, nil
+}
@@ -0,0 +1,28 @@
+package api
+
+import (
+	"net/http"
+	"time"
+	"vectormind/store"
+)
+
+// ChaosHeader is the response header of the requests delayed by the chaos mode, set to the injected delay
+const ChaosHeader = "X-Chaos-Delay"
+
+// WithChaos delays the requests drawn as slow responses by the chaos mode (see store.SetChaos) before handling them,
+// or until the client gives up
+func WithChaos(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if delay := store.ChaosResponseDelay(); delay > 0 {
+			w.Header().Set(ChaosHeader, delay.String())
+			timer := time.NewTimer(delay)
+			select {
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			case <-timer.C:
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -10,7 +10,6 @@ import (
 	"vectormind/splitter"
 	"vectormind/store"
 
-	"github.com/google/uuid"
 	"github.com/openai/openai-go"
 	"github.com/redis/go-redis/v9"
 )
@@ -29,9 +28,9 @@ func ChunkAndStoreHandler(w http.ResponseWriter, r *http.Request, ctx context.Co
 		return
 	}
 
-	// Parse request body
+	// Parse request body (JSON, or the document as a text/plain or text/markdown body)
 	var req models.ChunkAndStoreRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequestBody(r, "document", &req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
 			Success: false,
@@ -40,6 +39,19 @@ func ChunkAndStoreHandler(w http.ResponseWriter, r *http.Request, ctx context.Co
 		return
 	}
 
+	// The labels are stored together in the label field
+	label, err := store.JoinLabels(req.Label, req.Labels)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.Label = label
+	ctx = store.WithUsageLabel(ctx, label)
+
 	// Validate required fields
 	if req.Document == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -50,40 +62,38 @@ func ChunkAndStoreHandler(w http.ResponseWriter, r *http.Request, ctx context.Co
 		return
 	}
 
-	if req.ChunkSize <= 0 {
+	if err := store.ValidateIDStrategy(req.IDStrategy); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
 			Success: false,
-			Error:   "ChunkSize must be greater than 0",
+			Error:   err.Error(),
 		})
 		return
 	}
 
-	if req.Overlap < 0 {
+	if req.ChunkSize <= 0 {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
 			Success: false,
-			Error:   "Overlap cannot be negative",
+			Error:   "ChunkSize must be greater than 0",
 		})
 		return
 	}
 
-	if req.Overlap >= req.ChunkSize {
+	if req.Overlap < 0 {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
 			Success: false,
-			Error:   "Overlap must be less than ChunkSize",
+			Error:   "Overlap cannot be negative",
 		})
 		return
 	}
 
-	// Validate chunk_size <= embeddingDimension
-	embeddingDim := GetEmbeddingDimension()
-	if req.ChunkSize > embeddingDim {
+	if req.Overlap >= req.ChunkSize {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
 			Success: false,
-			Error:   fmt.Sprintf("ChunkSize (%d) must be less than or equal to embedding dimension (%d)", req.ChunkSize, embeddingDim),
+			Error:   "Overlap must be less than ChunkSize",
 		})
 		return
 	}
@@ -100,45 +110,112 @@ func ChunkAndStoreHandler(w http.ResponseWriter, r *http.Request, ctx context.Co
 		return
 	}
 
-	// Store all chunks
-	chunkIDs := make([]string, 0, len(chunks))
-	createdAt := time.Now()
-
+	// Validate that every chunk fits the context window of the embedding model
+	maxTokens := GetEmbeddingMaxTokens()
 	for _, chunk := range chunks {
-		// Create embedding from chunk text
-		embedding, err := store.CreateEmbeddingFromText(ctx, *openaiClient, chunk, embeddingModelId)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
+		if tokens := splitter.CountTokens(chunk); tokens > maxTokens {
+			w.WriteHeader(http.StatusBadRequest)
 			json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
 				Success: false,
-				Error:   fmt.Sprintf("Failed to create embedding for chunk: %v", err),
+				Error:   fmt.Sprintf("ChunkSize (%d characters) produces chunks of about %d tokens, above the embedding model limit (%d tokens)", req.ChunkSize, tokens, maxTokens),
 			})
 			return
 		}
+	}
 
-		// Generate unique document ID for this chunk
-		chunkID := fmt.Sprintf("doc:%s", uuid.New().String())
+	// Expiration of the chunks
+	ttl, err := store.DocumentTTL(req.TTLSeconds)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
 
-		// Store embedding in Redis with the same label and metadata for all chunks
-		err = store.StoreEmbedding(ctx, redisClient, chunkID, chunk, embedding, req.Label, req.Metadata)
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
-				Success: false,
-				Error:   fmt.Sprintf("Failed to store chunk embedding: %v", err),
-			})
-			return
-		}
+	// Handling of the chunks already stored
+	if err := store.ValidateDedupMode(req.Dedup); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
 
-		chunkIDs = append(chunkIDs, chunkID)
+	// An atomic ingestion stores all the chunks or none
+	if err := store.ValidateAtomic(req.Atomic, req.ContinueOnError); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
 	}
 
-	// Success response
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+	// Resolve the collection of the chunks
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, req.Collection)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	embeddingModelId = collection.ModelID(embeddingModelId)
+
+	chunkOptions := store.ChunkOptions{
+		Label:           req.Label,
+		Metadata:        req.Metadata,
+		IDStrategy:      req.IDStrategy,
+		SourceID:        req.SourceID,
+		ContinueOnError: req.ContinueOnError,
+		Rollback:        req.Rollback,
+		Atomic:          req.Atomic,
+		Original:        req.Document,
+		KeyPrefix:       collection.KeyPrefix,
+		TTL:             ttl,
+		Dedup:           req.Dedup,
+		IndexName:       collection.IndexName,
+	}
+
+	// Store the chunks in the background: the job reports the progress
+	if req.Async {
+		respondIngestionJob(w, store.StartIngestionJob(ctx, *openaiClient, redisClient, embeddingModelId, chunks, chunkOptions))
+		return
+	}
+
+	// Store all chunks
+	createdAt := time.Now()
+	statuses, err := store.StoreChunks(ctx, *openaiClient, redisClient, embeddingModelId, chunks, chunkOptions)
+	if err != nil && len(statuses) == 0 {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to store chunks: %v", err),
+		})
+		return
+	}
+
+	chunkIDs, chunksFailed := store.StoredChunkIDs(statuses)
+	response := models.ChunkAndStoreResponse{
+		SourceID:     store.OriginalSourceID(req.SourceID, req.Document),
 		ChunkIDs:     chunkIDs,
+		Chunks:       store.ChunkPreviews(chunks, statuses, req.IncludeContent),
 		ChunksStored: len(chunkIDs),
+		ChunksFailed: chunksFailed,
 		CreatedAt:    createdAt,
-		Success:      true,
-	})
+		Success:      chunksFailed == 0 && err == nil,
+	}
+	if req.ContinueOnError || err != nil {
+		response.ChunkStatuses = statuses
+	}
+
+	// Success response (or partial success when some chunks failed in continue_on_error mode,
+	// or the chunks stored before the failure that aborted the ingestion)
+	httpStatus, errorMessage := chunkStoreOutcome(len(chunkIDs), chunksFailed, err)
+	response.Error = errorMessage
+	writeChunkStoreResponse(w, httpStatus, response.Chunks, response)
 }
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"vectormind/models"
+	"vectormind/splitter"
+	"vectormind/store"
+)
+
+// ChunkPreviewHandler handles requests to split a document with a registered splitting strategy and return its chunks
+// without creating embeddings or storing them, to tune the strategy and its options before an ingestion.
+// The strategy is taken from the "strategy" query parameter, or from the request body, as for /split-and-store.
+func ChunkPreviewHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.ChunkPreviewResponse{
+			Success: false,
+			Error:   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	// Parse request body (JSON, or the document as a text/plain or text/markdown body)
+	var req models.ChunkPreviewRequest
+	if err := decodeRequestBody(r, "document", &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkPreviewResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+
+	if strategy := r.URL.Query().Get("strategy"); strategy != "" {
+		req.Strategy = strategy
+	}
+	if filename := r.URL.Query().Get("filename"); filename != "" {
+		req.Filename = filename
+	}
+
+	// Without strategy, the strategy is chosen from the extension of the file name of the document, with the default
+	// options of the file type (the options of the request take precedence)
+	if config, ok := splitter.ConfigForFile(req.Filename); ok && (req.Strategy == "" || req.Strategy == config.Strategy) {
+		req.Strategy = config.Strategy
+		req.Options = splitter.Options(req.Options).Merge(config.Options)
+	}
+
+	// Validate required fields
+	if req.Document == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkPreviewResponse{
+			Success: false,
+			Error:   "Document is required",
+		})
+		return
+	}
+
+	if req.Strategy == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkPreviewResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Strategy is required, or a filename with a known extension (available strategies: %v)", splitter.Strategies()),
+		})
+		return
+	}
+
+	// Split the document as an ingestion would (chunks fit the embedding model context window)
+	chunks, err := splitter.Split(req.Strategy, req.Document, req.Options, GetEmbeddingMaxTokens())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkPreviewResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	json.NewEncoder(w).Encode(store.ChunkPreviewResponse(req.Strategy, req.Options, chunks, GetEmbeddingMaxTokens()))
+}
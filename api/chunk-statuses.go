@@ -0,0 +1,51 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"vectormind/models"
+)
+
+// chunkStoreOutcome returns the HTTP status code and the error message (if any) describing the ingestion of a document.
+// Some chunks can only fail without aborting the ingestion in continue_on_error mode, err is the error that aborted it.
+func chunkStoreOutcome(chunksStored, chunksFailed int, err error) (int, string) {
+	switch {
+	case err != nil:
+		return errorStatus(err), fmt.Sprintf("Failed to store chunks: %v", err)
+	case chunksFailed == 0:
+		return http.StatusCreated, ""
+	case chunksStored == 0:
+		return http.StatusInternalServerError, fmt.Sprintf("All %d chunks failed to be stored", chunksFailed)
+	default:
+		return http.StatusMultiStatus, fmt.Sprintf("%d of %d chunks failed to be stored", chunksFailed, chunksStored+chunksFailed)
+	}
+}
+
+// documentURL returns the URL of a stored document (GET /documents/{id})
+func documentURL(id string) string {
+	return "/documents/" + url.PathEscape(id)
+}
+
+// writeChunkStoreResponse writes the response of an ingestion after setting the URL of each chunk stored by the
+// request (chunks are the chunks of the response): the duplicates of already stored chunks have no URL. A 201
+// response has a Location header designating the first stored chunk (none when every chunk was a duplicate).
+func writeChunkStoreResponse(w http.ResponseWriter, httpStatus int, chunks []models.ChunkPreview, response any) {
+	location := ""
+	for i := range chunks {
+		if chunks[i].ID == "" || chunks[i].Duplicate {
+			continue
+		}
+		chunks[i].URL = documentURL(chunks[i].ID)
+		if location == "" {
+			location = chunks[i].URL
+		}
+	}
+	if httpStatus == http.StatusCreated && location != "" {
+		w.Header().Set("Location", location)
+	}
+	w.WriteHeader(httpStatus)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// CollectionsHandler handles requests to list the collections (GET /collections) and to create a collection (POST /collections).
+// A collection has its own index, created with the settings of the main index.
+func CollectionsHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string, indexOptions store.IndexOptions) {
+	switch r.Method {
+	case http.MethodGet:
+		ListCollectionsHandler(w, r, ctx, redisClient, indexName)
+	case http.MethodPost:
+		CreateCollectionHandler(w, r, ctx, redisClient, indexName, indexOptions)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed. Use GET or POST",
+		})
+	}
+}
+
+// ListCollectionsHandler handles requests to list the collections (GET /collections, conditional with If-None-Match)
+func ListCollectionsHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.CollectionsResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET",
+		})
+		return
+	}
+
+	names, err := store.ListCollections(ctx, redisClient, indexName)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.CollectionsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	writeConditionalJSON(w, r, models.CollectionsResponse{
+		Collections: names,
+		Success:     true,
+	})
+}
+
+// CreateCollectionHandler handles requests to create a collection (POST /collections)
+func CreateCollectionHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string, indexOptions store.IndexOptions) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.CollectionResponse{
+			Success: false,
+			Error:   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	// Parse request body
+	var req models.CreateCollectionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.CollectionResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+
+	if err := store.ValidateCollectionName(req.Name); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.CollectionResponse{
+			Name:    req.Name,
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	collection, err := store.CreateCollection(ctx, redisClient, indexName, req.Name, req.EmbeddingModel, GetEmbeddingDimension(), indexOptions)
+	if err != nil {
+		status := errorStatus(err)
+		if errors.Is(err, store.ErrUnknownEmbeddingModel) {
+			status = http.StatusBadRequest
+		}
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.CollectionResponse{
+			Name:    req.Name,
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(models.CollectionResponse{
+		Name:           collection.Name,
+		IndexName:      collection.IndexName,
+		KeyPrefix:      collection.KeyPrefix,
+		EmbeddingModel: collection.EmbeddingModel,
+		Success:        true,
+	})
+}
+
+// DeleteCollectionHandler handles requests to delete a collection and all its documents (DELETE /collections/{name})
+func DeleteCollectionHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept DELETE requests
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.CollectionResponse{
+			Success: false,
+			Error:   "Method not allowed. Use DELETE",
+		})
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := store.ValidateCollectionName(name); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.CollectionResponse{
+			Name:    name,
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.DeleteCollection(ctx, redisClient, indexName, name); err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.CollectionResponse{
+			Name:    name,
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.CollectionResponse{
+		Name:    name,
+		Success: true,
+	})
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"vectormind/helpers"
+)
+
+// WithConcurrencyLimit limits the number of requests processed at the same time by the handler.
+// Requests that do not get a slot in time are refused with 503 Service Unavailable.
+// Near the limit, the responses carry a load hint (X-Load-Pressure and X-Retry-After-Ms headers), so that the callers
+// slow down before their requests are refused.
+func WithConcurrencyLimit(limiter *helpers.ConcurrencyLimiter, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		release, err := limiter.Acquire(r.Context())
+		if err != nil {
+			response := map[string]interface{}{
+				"success": false,
+				"error":   "Server busy: " + err.Error(),
+			}
+			retryAfter := "1"
+			if hint, ok := limiter.Hint(); ok {
+				setLoadHintHeaders(w, hint)
+				response["pressure"] = hint.Pressure
+				response["retry_after_ms"] = hint.RetryAfter.Milliseconds()
+				retryAfter = strconv.FormatInt(max(1, (hint.RetryAfter.Milliseconds()+999)/1000), 10)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", retryAfter)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			json.NewEncoder(w).Encode(response)
+			return
+		}
+		defer release()
+		if hint, ok := limiter.Hint(); ok {
+			setLoadHintHeaders(w, hint)
+		}
+		handler(w, r)
+	}
+}
+
+// setLoadHintHeaders sets the headers of a load hint: the pressure (2 decimals) and the retry delay in milliseconds
+func setLoadHintHeaders(w http.ResponseWriter, hint helpers.LoadHint) {
+	w.Header().Set("X-Load-Pressure", strconv.FormatFloat(hint.Pressure, 'f', 2, 64))
+	w.Header().Set("X-Retry-After-Ms", strconv.FormatInt(hint.RetryAfter.Milliseconds(), 10))
+}
@@ -0,0 +1,202 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// maxDeleteDocumentsIDs is the maximum number of documents deleted by a single bulk request
+const maxDeleteDocumentsIDs = 1000
+
+// DeleteDocumentHandler handles requests to delete a stored document (DELETE /documents/{id})
+func DeleteDocumentHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept DELETE requests
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.DeleteDocumentResponse{
+			Success: false,
+			Error:   "Method not allowed. Use DELETE",
+		})
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := store.ValidateDocumentID(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.DeleteDocumentResponse{
+			ID:      id,
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	deleted, err := store.DeleteDocument(ctx, redisClient, id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.DeleteDocumentResponse{
+			ID:      id,
+			Success: false,
+			Error:   fmt.Sprintf("Failed to delete document: %v", err),
+		})
+		return
+	}
+
+	if !deleted {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.DeleteDocumentResponse{
+			ID:      id,
+			Success: false,
+			Error:   "Document not found",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.DeleteDocumentResponse{
+		ID:      id,
+		Deleted: true,
+		Success: true,
+	})
+}
+
+// DeleteDocumentsHandler handles requests to delete several stored documents (DELETE /documents with a list of IDs).
+// Unknown IDs are reported in the response and do not fail the request.
+// With a "label" or "parent_id" query parameter, it deletes all the documents of a collection having the label or
+// split from the parent document instead (see deleteMatchingDocuments).
+func DeleteDocumentsHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept DELETE requests
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.DeleteDocumentsResponse{
+			Success: false,
+			Error:   "Method not allowed. Use DELETE",
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Has("label") || query.Has("parent_id") {
+		deleteMatchingDocuments(w, ctx, redisClient, indexName, query.Get("collection"), query.Get("label"), query.Get("parent_id"))
+		return
+	}
+
+	// Parse request body
+	var req models.DeleteDocumentsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.DeleteDocumentsResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+
+	// Validate required fields
+	if len(req.IDs) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.DeleteDocumentsResponse{
+			Success: false,
+			Error:   "IDs are required",
+		})
+		return
+	}
+
+	if len(req.IDs) > maxDeleteDocumentsIDs {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.DeleteDocumentsResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Too many IDs (%d), the maximum is %d", len(req.IDs), maxDeleteDocumentsIDs),
+		})
+		return
+	}
+
+	for _, id := range req.IDs {
+		if err := store.ValidateTenantDocumentID(indexName, id); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.DeleteDocumentsResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+
+	deleted, notFound, err := store.DeleteDocuments(ctx, redisClient, req.IDs)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.DeleteDocumentsResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to delete documents: %v", err),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.DeleteDocumentsResponse{
+		Deleted:      deleted,
+		NotFound:     notFound,
+		DeletedCount: len(deleted),
+		Success:      true,
+	})
+}
+
+// deleteMatchingDocuments deletes all the documents of a collection having a label and/or split from a parent
+// document (DELETE /documents?label=... or ?parent_id=...), e.g. to ingest a document again or purge a dataset
+func deleteMatchingDocuments(w http.ResponseWriter, ctx context.Context, redisClient *redis.Client, indexName, collectionName, label, parentID string) {
+	if label == "" && parentID == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.DeleteMatchingDocumentsResponse{
+			Success: false,
+			Error:   "Label or parent_id is required",
+		})
+		return
+	}
+
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, collectionName)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.DeleteMatchingDocumentsResponse{
+			Label:    label,
+			ParentID: parentID,
+			Success:  false,
+			Error:    err.Error(),
+		})
+		return
+	}
+
+	filter := store.SearchOptions{ParentID: parentID}
+	if label != "" {
+		filter.Labels = []string{label}
+	}
+	deleted, err := store.DeleteMatchingDocuments(ctx, redisClient, collection.IndexName, filter)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.DeleteMatchingDocumentsResponse{
+			Label:        label,
+			ParentID:     parentID,
+			DeletedCount: deleted,
+			Success:      false,
+			Error:        fmt.Sprintf("Failed to delete documents: %v", err),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.DeleteMatchingDocumentsResponse{
+		Label:        label,
+		ParentID:     parentID,
+		DeletedCount: deleted,
+		Success:      true,
+	})
+}
@@ -0,0 +1,141 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DocumentVersionsHandler handles requests to list the versions of a document (GET /documents/{id}/versions), with
+// the document versioning (see store.SetVersioning). The embedding vectors are returned with the
+// include_embedding=true query parameter.
+func DocumentVersionsHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.DocumentVersionsResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET",
+		})
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := store.ValidateDocumentID(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.DocumentVersionsResponse{
+			ID:      id,
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	includeEmbedding := false
+	if value := r.URL.Query().Get("include_embedding"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.DocumentVersionsResponse{
+				ID:      id,
+				Success: false,
+				Error:   "include_embedding must be true or false",
+			})
+			return
+		}
+		includeEmbedding = parsed
+	}
+
+	versions, err := store.DocumentVersions(ctx, redisClient, id, includeEmbedding)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.DocumentVersionsResponse{
+			ID:      id,
+			Success: false,
+			Error:   fmt.Sprintf("Failed to get the versions of the document: %v", err),
+		})
+		return
+	}
+
+	// Withhold the content from the callers that only decide relevance
+	if !RequestRole(r).CanReadContent() {
+		for i := range versions {
+			versions[i].Content = ""
+		}
+	}
+
+	current := versions[len(versions)-1]
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.DocumentVersionsResponse{
+		ID:       id,
+		Version:  current.Version,
+		Deleted:  current.Deleted,
+		Versions: versions,
+		Success:  true,
+	})
+}
+
+// RestoreVersionHandler handles requests to restore a version of a document
+// (POST /documents/{id}/versions/{version}/restore): the version becomes the current version of the document, under
+// a new version number, and a deleted document is undeleted (see store.RestoreDocumentVersion)
+func RestoreVersionHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.RestoreVersionResponse{
+			Success: false,
+			Error:   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := store.ValidateDocumentID(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.RestoreVersionResponse{
+			ID:      id,
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	version, err := strconv.Atoi(r.PathValue("version"))
+	if err != nil || version < 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.RestoreVersionResponse{
+			ID:      id,
+			Success: false,
+			Error:   "version must be an integer >= 1",
+		})
+		return
+	}
+
+	current, err := store.RestoreDocumentVersion(ctx, redisClient, id, version)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.RestoreVersionResponse{
+			ID:      id,
+			Success: false,
+			Error:   fmt.Sprintf("Failed to restore the version: %v", err),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.RestoreVersionResponse{
+		ID:       id,
+		Restored: version,
+		Version:  current,
+		Success:  true,
+	})
+}
@@ -0,0 +1,33 @@
+package api
+
+import (
+	"net/http"
+	"vectormind/store"
+)
+
+// errorStatuses are the HTTP status codes of the error codes of the store package
+var errorStatuses = map[string]int{
+	store.ErrorCodeInvalidRequest:      http.StatusBadRequest,
+	store.ErrorCodeNotFound:            http.StatusNotFound,
+	store.ErrorCodeIndexMissing:        http.StatusNotFound,
+	store.ErrorCodeConflict:            http.StatusConflict,
+	store.ErrorCodeDimensionMismatch:   http.StatusConflict,
+	store.ErrorCodeIndexCorrupted:      http.StatusServiceUnavailable,
+	store.ErrorCodeBackendUnavailable:  http.StatusServiceUnavailable,
+	store.ErrorCodeInsufficientStorage: http.StatusInsufficientStorage,
+	store.ErrorCodeEmbeddingFailed:     http.StatusBadGateway,
+	store.ErrorCodeFetchFailed:         http.StatusBadGateway,
+	store.ErrorCodeChatFailed:          http.StatusBadGateway,
+	store.ErrorCodeRerankFailed:        http.StatusBadGateway,
+	store.ErrorCodeNotConfigured:       http.StatusNotImplemented,
+	store.ErrorCodeTimeout:             http.StatusGatewayTimeout,
+}
+
+// errorStatus returns the HTTP status code of an error of the store package (see store.ErrorCode):
+// 500 Internal Server Error for an unknown error
+func errorStatus(err error) int {
+	if status, ok := errorStatuses[store.ErrorCode(err)]; ok {
+		return status
+	}
+	return http.StatusInternalServerError
+}
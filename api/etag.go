@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"vectormind/store"
+)
+
+const (
+	// modelInfoMaxAge is the time during which the caches reuse an embedding model info response without revalidation
+	modelInfoMaxAge = time.Minute
+	// statsMaxAge is the time during which the caches reuse a stats response without revalidation
+	statsMaxAge = 10 * time.Second
+)
+
+// contentETag returns the strong entity tag of a response body (the first 32 hex characters of its SHA-256)
+func contentETag(body []byte) string {
+	return `"` + store.HashContent(string(body))[:32] + `"`
+}
+
+// etagMatches reports whether an If-None-Match header designates the entity tag: "*", or one of a comma separated
+// list of tags, compared with the weak comparison of RFC 9110 (a W/ prefix is ignored)
+func etagMatches(ifNoneMatch, etag string) bool {
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeConditional writes a 200 response with the entity tag of its body (ETag header). When the If-None-Match header
+// of the request matches the tag, the client already has the content: the response is 304 Not Modified, without body.
+func writeConditional(w http.ResponseWriter, r *http.Request, body []byte) {
+	etag := contentETag(body)
+	w.Header().Set("ETag", etag)
+	// Unless a freshness is set (see writeCacheableJSON), the client can cache the content, but has to revalidate it
+	// on each read
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "no-cache")
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, etag) {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// writeConditionalJSON encodes a 200 JSON response and writes it with its entity tag (see writeConditional)
+func writeConditionalJSON(w http.ResponseWriter, r *http.Request, response any) {
+	var body bytes.Buffer
+	json.NewEncoder(&body).Encode(response)
+	writeConditional(w, r, body.Bytes())
+}
+
+// writeCacheableJSON writes a 200 JSON response with its entity tag (see writeConditional), that the clients and the
+// reverse proxies can reuse during maxAge without asking for it again. The response depends on the tenant and the API
+// key of the request, so the shared caches keep a response per tenant and key (Vary header).
+func writeCacheableJSON(w http.ResponseWriter, r *http.Request, response any, maxAge time.Duration) {
+	w.Header().Set("Cache-Control", "max-age="+strconv.Itoa(int(maxAge.Seconds())))
+	w.Header().Set("Vary", strings.Join([]string{TenantHeader, APIKeyHeader, "Authorization"}, ", "))
+	writeConditionalJSON(w, r, response)
+}
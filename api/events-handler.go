@@ -0,0 +1,62 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"vectormind/events"
+	"vectormind/models"
+)
+
+// EventsHandler handles requests for the recent events of the server (GET /events).
+// The since query parameter is the ID of the last event already received, or an RFC 3339 time.
+func EventsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.EventsResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET",
+		})
+		return
+	}
+
+	// The events recorded while the request is handled are returned by the next request
+	lastID := events.LastID()
+	since := r.URL.Query().Get("since")
+	var serverEvents []models.ServerEvent
+	if sinceID, err := strconv.ParseUint(since, 10, 64); err == nil || since == "" {
+		serverEvents = events.Since(sinceID)
+	} else {
+		sinceTime, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.EventsResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid since %q (use an event ID or an RFC 3339 time)", since),
+			})
+			return
+		}
+		serverEvents = []models.ServerEvent{}
+		for _, event := range events.Since(0) {
+			if eventTime, err := time.Parse(time.RFC3339Nano, event.Time); err == nil && eventTime.After(sinceTime) {
+				serverEvents = append(serverEvents, event)
+			}
+		}
+	}
+
+	if len(serverEvents) > 0 {
+		lastID = max(lastID, serverEvents[len(serverEvents)-1].ID)
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.EventsResponse{
+		Events:  serverEvents,
+		LastID:  lastID,
+		Success: true,
+	})
+}
@@ -0,0 +1,46 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"vectormind/features"
+	"vectormind/models"
+)
+
+// FeatureFlagsHandler handles requests for the feature flags of the deployment (GET /admin/flags)
+func FeatureFlagsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.FeatureFlagsResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.FeatureFlagsResponse{
+		Flags:   features.List(),
+		Success: true,
+	})
+}
+
+// WithFeature refuses the requests with 501 Not Implemented when a feature is disabled (see features.Enabled)
+func WithFeature(name string, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := features.Check(name); err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(errorStatus(err))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"error":   fmt.Sprintf("Feature not available: %v", err),
+			})
+			return
+		}
+		handler(w, r)
+	}
+}
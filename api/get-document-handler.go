@@ -0,0 +1,117 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// GetDocumentHandler handles requests to retrieve a stored document by ID (GET /documents/{id}).
+// The embedding vector is returned with the include_embedding=true query parameter, and a part of the content with
+// the content_offset and max_content_chars query parameters (in characters).
+// The response has an ETag, and a request with a matching If-None-Match header gets 304 Not Modified.
+func GetDocumentHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.GetDocumentResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET",
+		})
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := store.ValidateDocumentID(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.GetDocumentResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	includeEmbedding := false
+	if value := r.URL.Query().Get("include_embedding"); value != "" {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.GetDocumentResponse{
+				Success: false,
+				Error:   "include_embedding must be true or false",
+			})
+			return
+		}
+		includeEmbedding = parsed
+	}
+
+	// Part of the content, e.g. the rest of a search result cut to max_content_chars
+	contentOffset := 0
+	if value := r.URL.Query().Get("content_offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || store.ValidateContentOffset(parsed) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.GetDocumentResponse{
+				Success: false,
+				Error:   "content_offset must be an integer >= 0",
+			})
+			return
+		}
+		contentOffset = parsed
+	}
+	maxContentChars := 0
+	if value := r.URL.Query().Get("max_content_chars"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || store.ValidateMaxContentChars(parsed) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.GetDocumentResponse{
+				Success: false,
+				Error:   "max_content_chars must be an integer >= 0",
+			})
+			return
+		}
+		maxContentChars = parsed
+	}
+
+	document, err := store.GetDocument(ctx, redisClient, id, includeEmbedding)
+	if errors.Is(err, store.ErrDocumentNotFound) {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.GetDocumentResponse{
+			Success: false,
+			Error:   "Document not found",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.GetDocumentResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to get document: %v", err),
+		})
+		return
+	}
+
+	// Withhold the content from the callers that only decide relevance
+	redacted := !RequestRole(r).CanReadContent()
+	if redacted {
+		document.Content = ""
+	} else {
+		store.DocumentContentWindow(&document, contentOffset, maxContentChars)
+	}
+
+	// Conditional GET: a client polling an unchanged document gets 304 Not Modified
+	writeConditionalJSON(w, r, models.GetDocumentResponse{
+		Document: &document,
+		Redacted: redacted,
+		Success:  true,
+	})
+}
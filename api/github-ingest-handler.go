@@ -0,0 +1,247 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// IngestGitHubHandler handles requests to download the markdown, documentation and code files of a GitHub repository
+// and store their chunks, with the repo, path, branch and commit of their file in their metadata
+// (see store.FetchGitHubRepository and store.GitHubChunks)
+func IngestGitHubHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Error:   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	// Parse request body
+	var req models.IngestGitHubRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+
+	// The labels are stored together in the label field
+	label, err := store.JoinLabels(req.Label, req.Labels)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.Label = label
+	ctx = store.WithUsageLabel(ctx, label)
+
+	// Validate required fields
+	if req.RepoURL == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Error:   "Repo URL is required",
+		})
+		return
+	}
+
+	repo, err := store.ParseGitHubRepository(req.RepoURL)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateGitHubBranch(req.Branch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateGlobs(slices.Concat(req.Include, req.Exclude)); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateIDStrategy(req.IDStrategy); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Expiration of the chunks
+	ttl, err := store.DocumentTTL(req.TTLSeconds)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Handling of the chunks already stored
+	if err := store.ValidateDedupMode(req.Dedup); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// An atomic ingestion stores all the chunks or none
+	if err := store.ValidateAtomic(req.Atomic, req.ContinueOnError); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Download the files of the repository
+	repository, err := store.FetchGitHubRepository(ctx, repo, req.Branch, req.Include, req.Exclude)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Repo:    repo,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// The chunks keep the repo, path, branch and commit of their file in their metadata
+	chunks, chunkMetadata, err := store.GitHubChunks(repository, req.Metadata, GetEmbeddingMaxTokens())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Repo:    repo,
+			Commit:  repository.Commit,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if len(chunks) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Repo:    repo,
+			Commit:  repository.Commit,
+			Error:   "No chunks generated from the repository (no file matching the filters)",
+		})
+		return
+	}
+
+	// Resolve the collection of the chunks
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, req.Collection)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	embeddingModelId = collection.ModelID(embeddingModelId)
+
+	// The repository is the source of its chunks, unless another one is given
+	sourceID := req.SourceID
+	if sourceID == "" {
+		sourceID = "github.com/" + repo
+	}
+
+	chunkOptions := store.ChunkOptions{
+		Label:           req.Label,
+		ChunkMetadata:   chunkMetadata,
+		IDStrategy:      req.IDStrategy,
+		SourceID:        sourceID,
+		ContinueOnError: req.ContinueOnError,
+		Rollback:        req.Rollback,
+		Atomic:          req.Atomic,
+		KeyPrefix:       collection.KeyPrefix,
+		TTL:             ttl,
+		Dedup:           req.Dedup,
+		IndexName:       collection.IndexName,
+	}
+
+	// Store the chunks in the background: the job reports the progress
+	if req.Async {
+		respondIngestionJob(w, store.StartIngestionJob(ctx, *openaiClient, redisClient, embeddingModelId, chunks, chunkOptions))
+		return
+	}
+
+	// Store all chunks
+	createdAt := time.Now()
+	statuses, err := store.StoreChunks(ctx, *openaiClient, redisClient, embeddingModelId, chunks, chunkOptions)
+	if err != nil && len(statuses) == 0 {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.IngestGitHubResponse{
+			Success: false,
+			Repo:    repo,
+			Commit:  repository.Commit,
+			Error:   fmt.Sprintf("Failed to store chunks: %v", err),
+		})
+		return
+	}
+
+	chunkIDs, chunksFailed := store.StoredChunkIDs(statuses)
+	response := models.IngestGitHubResponse{
+		SourceID:      sourceID,
+		Repo:          repo,
+		Branch:        repository.Branch,
+		Commit:        repository.Commit,
+		FilesIngested: len(repository.Files),
+		FilesSkipped:  repository.Skipped,
+		ChunkIDs:      chunkIDs,
+		Chunks:        store.ChunkPreviews(chunks, statuses, req.IncludeContent),
+		ChunksStored:  len(chunkIDs),
+		ChunksFailed:  chunksFailed,
+		CreatedAt:     createdAt,
+		Success:       chunksFailed == 0 && err == nil,
+	}
+	if req.ContinueOnError || err != nil {
+		response.ChunkStatuses = statuses
+	}
+
+	// Success response (or partial success when some chunks failed in continue_on_error mode,
+	// or the chunks stored before the failure that aborted the ingestion)
+	httpStatus, errorMessage := chunkStoreOutcome(len(chunkIDs), chunksFailed, err)
+	response.Error = errorMessage
+	writeChunkStoreResponse(w, httpStatus, response.Chunks, response)
+}
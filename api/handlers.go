@@ -3,21 +3,21 @@ package api
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
-	"sort"
-	"strconv"
 	"time"
+	"vectormind/helpers"
 	"vectormind/models"
 	"vectormind/store"
 
-	"github.com/google/uuid"
 	"github.com/openai/openai-go"
 	"github.com/redis/go-redis/v9"
 )
 
 var embeddingDimension int
 var embeddingModelId string
+var embeddingMaxTokens int
 
 func SetEmbeddingDimension(dim int) {
 	embeddingDimension = dim
@@ -27,6 +27,14 @@ func GetEmbeddingDimension() int {
 	return embeddingDimension
 }
 
+func SetEmbeddingMaxTokens(maxTokens int) {
+	embeddingMaxTokens = maxTokens
+}
+
+func GetEmbeddingMaxTokens() int {
+	return embeddingMaxTokens
+}
+
 func SetEmbeddingModelId(modelId string) {
 	embeddingModelId = modelId
 }
@@ -35,8 +43,9 @@ func GetEmbeddingModelId() string {
 	return embeddingModelId
 }
 
-// GetEmbeddingModelInfoHandler handles requests for embedding model information
-func GetEmbeddingModelInfoHandler(w http.ResponseWriter, r *http.Request) {
+// GetEmbeddingModelInfoHandler handles requests for embedding model information: the default model, or the model
+// of the collection query parameter, and the registered models. The response can be cached (see writeCacheableJSON).
+func GetEmbeddingModelInfoHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string) {
 	w.Header().Set("Content-Type", "application/json")
 
 	// Only accept GET requests
@@ -49,14 +58,40 @@ func GetEmbeddingModelInfoHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	model := store.EmbeddingModel{
+		Name:      store.DefaultEmbeddingModelName,
+		ModelID:   embeddingModelId,
+		Dimension: embeddingDimension,
+		MaxTokens: embeddingMaxTokens,
+	}
+	name := r.URL.Query().Get("collection")
+	if name != "" {
+		collection, err := store.ResolveCollection(ctx, redisClient, indexName, name)
+		if err != nil {
+			w.WriteHeader(errorStatus(err))
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		model = collection.Model(model)
+	}
+
 	response := map[string]interface{}{
-		"success":   true,
-		"model_id":  embeddingModelId,
-		"dimension": embeddingDimension,
+		"success":         true,
+		"embedding_model": model.Name,
+		"model_id":        model.ModelID,
+		"dimension":       model.Dimension,
+		"max_tokens":      model.MaxTokens,
+		"models":          store.ListEmbeddingModels(),
+	}
+	if name != "" {
+		response["collection"] = name
 	}
 
-	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(response)
+	// The models only change with the configuration of the server or a new collection
+	writeCacheableJSON(w, r, response, modelInfoMaxAge)
 }
 
 // HealthCheckHandler handles health check requests
@@ -85,9 +120,9 @@ func CreateEmbeddingHandler(w http.ResponseWriter, r *http.Request, ctx context.
 		return
 	}
 
-	// Parse request body
+	// Parse request body (JSON, or the content as a text/plain or text/markdown body)
 	var req models.CreateEmbeddingRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequestBody(r, "content", &req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
 			Success: false,
@@ -96,6 +131,19 @@ func CreateEmbeddingHandler(w http.ResponseWriter, r *http.Request, ctx context.
 		return
 	}
 
+	// The labels are stored together in the label field
+	label, err := store.JoinLabels(req.Label, req.Labels)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.Label = label
+	ctx = store.WithUsageLabel(ctx, label)
+
 	// Validate required fields
 	if req.Content == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -106,10 +154,111 @@ func CreateEmbeddingHandler(w http.ResponseWriter, r *http.Request, ctx context.
 		return
 	}
 
+	// Expiration of the document
+	ttl, err := store.DocumentTTL(req.TTLSeconds)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Handling of a content already stored
+	if err := store.ValidateDedupMode(req.Dedup); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Handling of an ID already used (with an ID chosen by the caller)
+	if err := store.ValidateIDConflict(req.OnConflict); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if req.ID != "" && req.Dedup != "" && req.Dedup != store.DedupOff {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
+			Success: false,
+			Error:   "id and dedup cannot be combined",
+		})
+		return
+	}
+
+	// Resolve the collection of the document
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, req.Collection)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	embeddingModelId = collection.ModelID(embeddingModelId)
+
+	// The ID of the document: the ID chosen by the caller, a new ID, or the ID of the document with the same content
+	var docID string
+	var duplicate bool
+	overwrite := store.OverwriteOnIDConflict(req.OnConflict)
+	if req.ID != "" {
+		docID, err = store.CallerDocumentID(collection.KeyPrefix, req.ID)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+		// Checked before the embedding is created (the creation checks it again)
+		if exists, err := store.DocumentExists(ctx, redisClient, docID); err == nil && exists && !overwrite {
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
+				ID:      docID,
+				Success: false,
+				Error:   store.ErrDocumentExists.Error(),
+			})
+			return
+		}
+	} else {
+		docID, duplicate, err = store.DedupDocumentID(ctx, redisClient, collection, req.Content, req.Dedup)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
+				Success: false,
+				Error:   err.Error(),
+			})
+			return
+		}
+	}
+	if duplicate {
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
+			ID:        docID,
+			Content:   req.Content,
+			Label:     req.Label,
+			Labels:    store.SplitLabels(req.Label),
+			Metadata:  req.Metadata,
+			CreatedAt: time.Now(),
+			Duplicate: true,
+			Success:   true,
+		})
+		return
+	}
+
 	// Create embedding from text
 	embedding, err := store.CreateEmbeddingFromText(ctx, *openaiClient, req.Content, embeddingModelId)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to create embedding: %v", err),
@@ -117,13 +266,23 @@ func CreateEmbeddingHandler(w http.ResponseWriter, r *http.Request, ctx context.
 		return
 	}
 
-	// Generate unique document ID
-	docID := fmt.Sprintf("doc:%s", uuid.New().String())
-
 	// Store embedding in Redis
-	err = store.StoreEmbedding(ctx, redisClient, docID, req.Content, embedding, req.Label, req.Metadata)
+	if req.ID != "" {
+		err = store.InsertDocument(ctx, redisClient, store.NewDocument(docID, req.Content, embedding, req.Label, req.Metadata, ttl), overwrite)
+	} else {
+		err = store.StoreEmbeddingWithTTL(ctx, redisClient, docID, req.Content, embedding, req.Label, req.Metadata, ttl)
+	}
+	if errors.Is(err, store.ErrDocumentExists) {
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
+			ID:      docID,
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
 			Success: false,
 			Error:   fmt.Sprintf("Failed to store embedding: %v", err),
@@ -132,11 +291,14 @@ func CreateEmbeddingHandler(w http.ResponseWriter, r *http.Request, ctx context.
 	}
 
 	// Success response
+	w.Header().Set("Location", documentURL(docID))
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(models.CreateEmbeddingResponse{
 		ID:        docID,
+		URL:       documentURL(docID),
 		Content:   req.Content,
 		Label:     req.Label,
+		Labels:    store.SplitLabels(req.Label),
 		Metadata:  req.Metadata,
 		CreatedAt: time.Now(),
 		Success:   true,
@@ -145,6 +307,7 @@ func CreateEmbeddingHandler(w http.ResponseWriter, r *http.Request, ctx context.
 
 // SimilaritySearchHandler handles similarity search requests
 func SimilaritySearchHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId, indexName string) {
+	start := time.Now()
 	w.Header().Set("Content-Type", "application/json")
 
 	// Only accept POST requests
@@ -182,72 +345,170 @@ func SimilaritySearchHandler(w http.ResponseWriter, r *http.Request, ctx context
 		req.MaxCount = 5 // Default value
 	}
 
-	// Create embedding from query text
-	queryEmbedding, err := store.CreateEmbeddingFromText(ctx, *openaiClient, req.Text, embeddingModelId)
+	if req.TimeoutMs < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   "timeout_ms cannot be negative",
+		})
+		return
+	}
+
+	if err := store.ValidateSnippetSize(req.SnippetSize); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateExpandContext(req.ExpandContext); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateMaxContentChars(req.MaxContentChars); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateDiversity(req.Diversity); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateRecency(req.RecencyHalfLifeHours, req.RecencyWeight); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	filters, err := store.ParseMetadataFilters(req.Filters)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
 			Success: false,
-			Error:   fmt.Sprintf("Failed to create embedding: %v", err),
+			Error:   fmt.Sprintf("Invalid filters: %v", err),
+		})
+		return
+	}
+
+	if req.Rerank && !store.RerankEnabled() {
+		w.WriteHeader(errorStatus(store.ErrRerankerMissing))
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   "Reranking is not available (RERANK_PROVIDER is not set, or the reranking feature is disabled)",
+		})
+		return
+	}
+
+	if req.ExpandQuery && !store.ExpansionEnabled() {
+		w.WriteHeader(errorStatus(store.ErrChatModelMissing))
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   "Query expansion is not available (CHAT_MODEL is not set, or the query expansion feature is disabled)",
 		})
 		return
 	}
 
-	// Perform similarity search
-	docs, err := store.SimilaritySearch(ctx, redisClient, indexName, queryEmbedding, req.MaxCount)
+	// Resolve the collection of the documents
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, req.Collection)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
 			Success: false,
-			Error:   fmt.Sprintf("Failed to perform similarity search: %v", err),
+			Error:   err.Error(),
 		})
 		return
 	}
+	embeddingModelId = collection.ModelID(embeddingModelId)
 
-	// Convert results to response format
-	results := make([]models.SimilaritySearchResult, 0, len(docs))
-	for _, doc := range docs {
-		str := doc.Fields["vector_distance"]
-		distance, err := strconv.ParseFloat(str, 32)
-		if err != nil {
-			distance = 9.9
-		}
+	// Paraphrases of the query searched with it (the search goes on without them when the chat model fails)
+	var variants []string
+	if req.ExpandQuery {
+		variants = expandQuery(ctx, req.Text)
+	}
 
-		// Filter by distance threshold if specified
-		if req.DistanceThreshold != nil && distance > *req.DistanceThreshold {
-			continue
-		}
+	// Perform similarity search (query embedding and vector search within the time budget)
+	docs, fallback, timings, err := store.SearchByTextWithTimings(ctx, *openaiClient, redisClient, embeddingModelId, collection.IndexName, req.Text, searchCount(req.MaxCount, req.Rerank, req.RecencyHalfLifeHours > 0), store.SearchOptions{
+		MinQuality:    req.MinQuality,
+		MaxDistance:   req.DistanceThreshold,
+		Filters:       filters,
+		Diversity:     req.Diversity,
+		QueryVariants: variants,
+	}, store.TextSearchBudget{
+		Timeout:         time.Duration(req.TimeoutMs) * time.Millisecond,
+		KeywordFallback: req.KeywordFallback,
+	})
+	if err != nil {
+		writeSearchError(w, err)
+		return
+	}
+	postStart := time.Now()
 
-		createdAtUnix, _ := strconv.ParseInt(doc.Fields["created_at"], 10, 64)
-		createdAt := time.Unix(createdAtUnix, 0).Format(time.RFC3339)
+	// Convert results to response format
+	results := store.TextSearchResults(docs, fallback, req.DistanceThreshold)
+	var reranked bool
+	if req.Rerank {
+		results, reranked = rerankSearchResults(ctx, req.Text, results, req.MaxCount)
+	}
+	if req.RecencyHalfLifeHours > 0 {
+		results = rankByRecency(results, fallback, req.RecencyHalfLifeHours, req.RecencyWeight, req.MaxCount)
+	}
 
-		result := models.SimilaritySearchResult{
-			ID:        doc.ID,
-			Content:   doc.Fields["content"],
-			Label:     doc.Fields["label"],
-			Metadata:  doc.Fields["metadata"],
-			Distance:  distance,
-			CreatedAt: createdAt,
-		}
+	// Withhold the content from the callers that only decide relevance
+	redacted := !RequestRole(r).CanReadContent()
+	if redacted {
+		redactSearchResults(results)
+	}
 
-		results = append(results, result)
+	// The snippets are skipped when the query embedding already timed out
+	if req.SnippetSize > 0 && !redacted && fallback == "" {
+		addSnippets(ctx, *openaiClient, req.Text, results, embeddingModelId, req.SnippetSize)
 	}
 
-	// Sort results by distance in ascending order (closest first)
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Distance < results[j].Distance
-	})
+	if req.ExpandContext > 0 && !redacted {
+		expandSearchResults(ctx, redisClient, collection.IndexName, results, req.ExpandContext)
+	}
+
+	// The snippets are made from the whole content before it is cut
+	store.TruncateSearchResults(results, req.MaxContentChars)
 
 	// Success response
+	response := models.SimilaritySearchResponse{
+		Results:         results,
+		Redacted:        redacted,
+		Fallback:        fallback,
+		Reranked:        reranked,
+		ExpandedQueries: variants,
+		Success:         true,
+	}
+	if req.Debug {
+		response.Timings = newSearchTimings(timings, time.Since(postStart), time.Since(start))
+	}
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
-		Results: results,
-		Success: true,
-	})
+	json.NewEncoder(w).Encode(response)
 }
 
 // SimilaritySearchWithLabelHandler handles similarity search with label filter requests
 func SimilaritySearchWithLabelHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId, indexName string) {
+	start := time.Now()
 	w.Header().Set("Content-Type", "application/json")
 
 	// Only accept POST requests
@@ -289,71 +550,254 @@ func SimilaritySearchWithLabelHandler(w http.ResponseWriter, r *http.Request, ct
 		})
 		return
 	}
+	ctx = store.WithUsageLabel(ctx, req.Label)
 
 	if req.MaxCount <= 0 {
 		req.MaxCount = 5 // Default value
 	}
 
-	// Create embedding from query text
-	queryEmbedding, err := store.CreateEmbeddingFromText(ctx, *openaiClient, req.Text, embeddingModelId)
+	if req.TimeoutMs < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   "timeout_ms cannot be negative",
+		})
+		return
+	}
+
+	if err := store.ValidateSnippetSize(req.SnippetSize); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateExpandContext(req.ExpandContext); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateMaxContentChars(req.MaxContentChars); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateDiversity(req.Diversity); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateRecency(req.RecencyHalfLifeHours, req.RecencyWeight); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	filters, err := store.ParseMetadataFilters(req.Filters)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
 			Success: false,
-			Error:   fmt.Sprintf("Failed to create embedding: %v", err),
+			Error:   fmt.Sprintf("Invalid filters: %v", err),
+		})
+		return
+	}
+
+	if req.Rerank && !store.RerankEnabled() {
+		w.WriteHeader(errorStatus(store.ErrRerankerMissing))
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   "Reranking is not available (RERANK_PROVIDER is not set, or the reranking feature is disabled)",
+		})
+		return
+	}
+
+	if req.ExpandQuery && !store.ExpansionEnabled() {
+		w.WriteHeader(errorStatus(store.ErrChatModelMissing))
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   "Query expansion is not available (CHAT_MODEL is not set, or the query expansion feature is disabled)",
 		})
 		return
 	}
 
-	// Perform similarity search with label filter
-	docs, err := store.SimilaritySearchWithLabel(ctx, redisClient, indexName, queryEmbedding, req.MaxCount, req.Label)
+	// Resolve the collection of the documents
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, req.Collection)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
+		w.WriteHeader(errorStatus(err))
 		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
 			Success: false,
-			Error:   fmt.Sprintf("Failed to perform similarity search: %v", err),
+			Error:   err.Error(),
 		})
 		return
 	}
+	embeddingModelId = collection.ModelID(embeddingModelId)
 
-	// Convert results to response format
-	results := make([]models.SimilaritySearchResult, 0, len(docs))
-	for _, doc := range docs {
-		str := doc.Fields["vector_distance"]
-		distance, err := strconv.ParseFloat(str, 32)
-		if err != nil {
-			distance = 9.9
-		}
+	// Paraphrases of the query searched with it (the search goes on without them when the chat model fails)
+	var variants []string
+	if req.ExpandQuery {
+		variants = expandQuery(ctx, req.Text)
+	}
 
-		// Filter by distance threshold if specified
-		if req.DistanceThreshold != nil && distance > *req.DistanceThreshold {
-			continue
-		}
+	// Perform similarity search with label filter (query embedding and vector search within the time budget)
+	docs, fallback, timings, err := store.SearchByTextWithTimings(ctx, *openaiClient, redisClient, embeddingModelId, collection.IndexName, req.Text, searchCount(req.MaxCount, req.Rerank, req.RecencyHalfLifeHours > 0), store.SearchOptions{
+		Label:         req.Label,
+		MinQuality:    req.MinQuality,
+		MaxDistance:   req.DistanceThreshold,
+		Filters:       filters,
+		Diversity:     req.Diversity,
+		QueryVariants: variants,
+	}, store.TextSearchBudget{
+		Timeout:         time.Duration(req.TimeoutMs) * time.Millisecond,
+		KeywordFallback: req.KeywordFallback,
+	})
+	if err != nil {
+		writeSearchError(w, err)
+		return
+	}
+	postStart := time.Now()
 
-		createdAtUnix, _ := strconv.ParseInt(doc.Fields["created_at"], 10, 64)
-		createdAt := time.Unix(createdAtUnix, 0).Format(time.RFC3339)
+	// Convert results to response format
+	results := store.TextSearchResults(docs, fallback, req.DistanceThreshold)
+	var reranked bool
+	if req.Rerank {
+		results, reranked = rerankSearchResults(ctx, req.Text, results, req.MaxCount)
+	}
+	if req.RecencyHalfLifeHours > 0 {
+		results = rankByRecency(results, fallback, req.RecencyHalfLifeHours, req.RecencyWeight, req.MaxCount)
+	}
 
-		result := models.SimilaritySearchResult{
-			ID:        doc.ID,
-			Content:   doc.Fields["content"],
-			Label:     doc.Fields["label"],
-			Metadata:  doc.Fields["metadata"],
-			Distance:  distance,
-			CreatedAt: createdAt,
-		}
+	// Withhold the content from the callers that only decide relevance
+	redacted := !RequestRole(r).CanReadContent()
+	if redacted {
+		redactSearchResults(results)
+	}
 
-		results = append(results, result)
+	// The snippets are skipped when the query embedding already timed out
+	if req.SnippetSize > 0 && !redacted && fallback == "" {
+		addSnippets(ctx, *openaiClient, req.Text, results, embeddingModelId, req.SnippetSize)
 	}
 
-	// Sort results by distance in ascending order (closest first)
-	sort.Slice(results, func(i, j int) bool {
-		return results[i].Distance < results[j].Distance
-	})
+	if req.ExpandContext > 0 && !redacted {
+		expandSearchResults(ctx, redisClient, collection.IndexName, results, req.ExpandContext)
+	}
+
+	// The snippets are made from the whole content before it is cut
+	store.TruncateSearchResults(results, req.MaxContentChars)
 
 	// Success response
+	response := models.SimilaritySearchResponse{
+		Results:         results,
+		Redacted:        redacted,
+		Fallback:        fallback,
+		Reranked:        reranked,
+		ExpandedQueries: variants,
+		Success:         true,
+	}
+	if req.Debug {
+		response.Timings = newSearchTimings(timings, time.Since(postStart), time.Since(start))
+	}
 	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
+
+// writeSearchError writes the error of a failed search (504 Gateway Timeout when the time budget is exceeded,
+// 502 Bad Gateway when the query embedding failed, see errorStatus)
+func writeSearchError(w http.ResponseWriter, err error) {
+	w.WriteHeader(errorStatus(err))
 	json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
-		Results: results,
-		Success: true,
+		Success: false,
+		Error:   fmt.Sprintf("Search failed: %v", err),
 	})
 }
+
+// addSnippets sets the snippets of the search results (see store.AddSnippets). The results are returned without
+// snippets when the sentences cannot be embedded.
+func addSnippets(ctx context.Context, openaiClient openai.Client, query string, results []models.SimilaritySearchResult, embeddingModelId string, size int) {
+	if err := store.AddSnippets(ctx, openaiClient, query, results, embeddingModelId, size); err != nil {
+		helpers.Logf(ctx, "🟠 Failed to create the snippets of the search results: %v", err)
+	}
+}
+
+// expandSearchResults sets the expanded content of the search results (see store.ExpandSearchResults). The results
+// are returned without expanded content when their neighbouring chunks cannot be read.
+func expandSearchResults(ctx context.Context, redisClient *redis.Client, indexName string, results []models.SimilaritySearchResult, n int) {
+	if err := store.ExpandSearchResults(ctx, redisClient, indexName, results, n); err != nil {
+		helpers.Logf(ctx, "🟠 Failed to expand the context of the search results: %v", err)
+	}
+}
+
+// searchCount returns the number of results retrieved by a search returning n results: more candidates are
+// retrieved for the reranker and for the recency ranking (with both, the recency orders the reranked results)
+func searchCount(n int, rerank, recency bool) int {
+	if rerank {
+		return store.RerankCandidates(n)
+	}
+	if recency {
+		return store.RecencyCandidates(n)
+	}
+	return n
+}
+
+// rerankSearchResults returns the n most relevant search results according to the reranker (see
+// store.RerankResults). The first n results are returned in their search order when the reranker fails.
+func rerankSearchResults(ctx context.Context, query string, results []models.SimilaritySearchResult, n int) ([]models.SimilaritySearchResult, bool) {
+	reranked, err := store.RerankResults(ctx, query, results, n)
+	if err != nil {
+		helpers.Logf(ctx, "🟠 Failed to rerank the search results: %v", err)
+		return results[:min(n, len(results))], false
+	}
+	return reranked, true
+}
+
+// rankByRecency returns the n best search results by similarity and freshness (see store.RankByRecency). The
+// keyword fallback results have no distance: the first n are returned in their order.
+func rankByRecency(results []models.SimilaritySearchResult, fallback string, halfLifeHours, weight float64, n int) []models.SimilaritySearchResult {
+	if fallback != "" {
+		return results[:min(n, len(results))]
+	}
+	return store.RankByRecency(results, store.RecencyHalfLife(halfLifeHours), weight, time.Now(), n)
+}
+
+// expandQuery returns the paraphrases of a query generated by the chat model (see store.ExpandQuery). The query is
+// searched alone when the chat model fails.
+func expandQuery(ctx context.Context, query string) []string {
+	variants, err := store.ExpandQuery(ctx, query)
+	if err != nil {
+		helpers.Logf(ctx, "🟠 Failed to expand the search query: %v", err)
+		return nil
+	}
+	return variants
+}
+
+// newSearchTimings converts the timings of a search to milliseconds
+func newSearchTimings(timings store.SearchTimings, post, total time.Duration) *models.SearchTimings {
+	return &models.SearchTimings{
+		EmbedMs:  durationMs(timings.Embed),
+		SearchMs: durationMs(timings.Search),
+		PostMs:   durationMs(post),
+		TotalMs:  durationMs(total),
+	}
+}
+
+// durationMs converts a duration to milliseconds, with a microsecond precision
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
@@ -0,0 +1,265 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"vectormind/helpers"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// HybridSearchHandler handles hybrid search requests: a BM25 full-text search on the content (or the search fields) and a vector search,
+// fused by reciprocal rank fusion or weighted sum
+func HybridSearchHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId, indexName string) {
+	start := time.Now()
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	// Parse request body
+	var req models.HybridSearchRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+
+	// Validate required fields
+	if req.Text == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   "Text is required",
+		})
+		return
+	}
+
+	if req.MaxCount <= 0 {
+		req.MaxCount = 5 // Default value
+	}
+
+	hybridOptions := store.HybridOptions{Fusion: req.Fusion, VectorWeight: 0.5}
+	if hybridOptions.Fusion == "" {
+		hybridOptions.Fusion = store.FusionRRF
+	}
+	if req.VectorWeight != nil {
+		hybridOptions.VectorWeight = *req.VectorWeight
+	}
+	if err := store.ValidateHybridOptions(hybridOptions); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	filters, err := store.ParseMetadataFilters(req.Filters)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid filters: %v", err),
+		})
+		return
+	}
+
+	if err := store.ValidateSearchFields(req.SearchFields); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateSnippetSize(req.SnippetSize); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateExpandContext(req.ExpandContext); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateMaxContentChars(req.MaxContentChars); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	var fieldWeights *store.FieldWeights
+	if req.FieldWeights != nil {
+		weights, err := store.ApplyFieldWeights(store.GetFieldWeights(), req.FieldWeights)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.HybridSearchResponse{
+				Success: false,
+				Error:   fmt.Sprintf("Invalid field weights: %v", err),
+			})
+			return
+		}
+		fieldWeights = &weights
+	}
+
+	if req.Rerank && !store.RerankEnabled() {
+		w.WriteHeader(errorStatus(store.ErrRerankerMissing))
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   "Reranking is not available (RERANK_PROVIDER is not set, or the reranking feature is disabled)",
+		})
+		return
+	}
+
+	// Resolve the collection of the documents
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, req.Collection)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	embeddingModelId = collection.ModelID(embeddingModelId)
+
+	// Create embedding from query text (charged to the label of the search)
+	ctx = store.WithUsageLabel(ctx, req.Label)
+	var timings store.SearchTimings
+	embedStart := time.Now()
+	queryEmbedding, err := store.CreateQueryEmbeddingFromText(ctx, *openaiClient, req.Text, embeddingModelId)
+	timings.Embed = time.Since(embedStart)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to create embedding: %v", err),
+		})
+		return
+	}
+
+	// Perform hybrid search
+	searchStart := time.Now()
+	results, err := store.HybridSearch(ctx, redisClient, collection.IndexName, req.Text, queryEmbedding, searchCount(req.MaxCount, req.Rerank, false), store.SearchOptions{
+		Label:        req.Label,
+		MinQuality:   req.MinQuality,
+		Filters:      filters,
+		SearchFields: req.SearchFields,
+		FieldWeights: fieldWeights,
+	}, hybridOptions)
+	if errors.Is(err, store.ErrKeywordSearchDisabled) {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Hybrid search is not available: %v", err),
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.HybridSearchResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to perform hybrid search: %v", err),
+		})
+		return
+	}
+
+	timings.Search = time.Since(searchStart)
+	postStart := time.Now()
+
+	// The reranker orders the candidates of the fusion (the first max_count are kept when it fails)
+	var reranked bool
+	if req.Rerank {
+		if rerankedResults, err := store.RerankHybridResults(ctx, req.Text, results, req.MaxCount); err != nil {
+			helpers.Logf(ctx, "🟠 Failed to rerank the search results: %v", err)
+			results = results[:min(req.MaxCount, len(results))]
+		} else {
+			results, reranked = rerankedResults, true
+		}
+	}
+
+	// Withhold the content from the callers that only decide relevance
+	redacted := !RequestRole(r).CanReadContent()
+	if redacted {
+		for i := range results {
+			results[i].Content = ""
+			results[i].Title = ""
+			results[i].Hierarchy = ""
+		}
+	}
+
+	if req.SnippetSize > 0 && !redacted {
+		contents := make([]string, len(results))
+		for i, result := range results {
+			contents[i] = result.Content
+		}
+		snippets, err := store.SearchSnippets(ctx, *openaiClient, req.Text, contents, embeddingModelId, req.SnippetSize)
+		if err != nil {
+			helpers.Logf(ctx, "🟠 Failed to create the snippets of the search results: %v", err)
+		}
+		for i := range snippets {
+			results[i].Snippet = snippets[i]
+		}
+	}
+
+	if req.ExpandContext > 0 && !redacted {
+		chunks := make([]models.ChunkProvenance, len(results))
+		for i, result := range results {
+			chunks[i] = result.ChunkProvenance
+		}
+		contexts, err := store.ExpandContexts(ctx, redisClient, collection.IndexName, chunks, req.ExpandContext)
+		if err != nil {
+			helpers.Logf(ctx, "🟠 Failed to expand the context of the search results: %v", err)
+		}
+		for i := range contexts {
+			results[i].ExpandedContent = contexts[i]
+		}
+	}
+
+	// The snippets are made from the whole content before it is cut
+	store.TruncateHybridResults(results, req.MaxContentChars)
+
+	// Success response
+	response := models.HybridSearchResponse{
+		Results:  results,
+		Fusion:   hybridOptions.Fusion,
+		Redacted: redacted,
+		Reranked: reranked,
+		Success:  true,
+	}
+	if req.Debug {
+		response.Timings = newSearchTimings(timings, time.Since(postStart), time.Since(start))
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
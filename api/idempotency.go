@@ -0,0 +1,159 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+	"vectormind/helpers"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IdempotencyKeyHeader is the request header identifying a request, so that a retried request is not applied twice
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentReplayedHeader is set on the responses replayed from the result of a previous request with the same key
+const IdempotentReplayedHeader = "Idempotent-Replayed"
+
+// maxIdempotencyKeyLength is the maximum length of an idempotency key
+const maxIdempotencyKeyLength = 255
+
+var idempotencyTTL = store.DefaultIdempotencyTTL
+
+// SetIdempotencyTTL sets the time the results of the requests with an idempotency key are kept
+func SetIdempotencyTTL(ttl time.Duration) {
+	if ttl > 0 {
+		idempotencyTTL = ttl
+	}
+}
+
+// idempotencyRecorder forwards the response to the client and keeps a copy of it
+type idempotencyRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (recorder *idempotencyRecorder) WriteHeader(status int) {
+	recorder.status = status
+	recorder.ResponseWriter.WriteHeader(status)
+}
+
+func (recorder *idempotencyRecorder) Write(data []byte) (int, error) {
+	recorder.body.Write(data)
+	return recorder.ResponseWriter.Write(data)
+}
+
+// idempotencyKey returns the idempotency key of a request: the Idempotency-Key header,
+// or the doc_id field of the JSON body (the doc_id query parameter with a text body)
+func idempotencyKey(r *http.Request, body []byte) string {
+	if key := r.Header.Get(IdempotencyKeyHeader); key != "" {
+		return key
+	}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if textContentTypes[mediaType] {
+		return r.URL.Query().Get("doc_id")
+	}
+	var fields struct {
+		DocID string `json:"doc_id"`
+	}
+	json.Unmarshal(body, &fields)
+	return fields.DocID
+}
+
+// WithIdempotency applies the POST requests with an idempotency key once: the successful response is stored in Redis
+// (for the idempotency TTL) and replayed to the requests with the same key, a request with the key of a request
+// in progress is refused with 409 Conflict, and a key reused for another request with 422 Unprocessable Entity.
+// Failed requests are not stored, they can be retried with the same key.
+func WithIdempotency(handler TenantHandler) TenantHandler {
+	return func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, indexName string) {
+		if r.Method != http.MethodPost {
+			handler(w, r, redisClient, indexName)
+			return
+		}
+
+		writeError := func(status int, message string) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"error":   message,
+			})
+		}
+
+		// The body is read first to find the key, and restored for the handler
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(http.StatusBadRequest, "Failed to read the request body: "+err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		key := idempotencyKey(r, body)
+		if key == "" {
+			handler(w, r, redisClient, indexName)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(http.StatusBadRequest, "The idempotency key is too long (255 characters max)")
+			return
+		}
+		// The keys are scoped to the endpoint and to the tenant
+		endpoint := r.URL.Path
+		if tenant := r.Header.Get(TenantHeader); tenant != "" {
+			endpoint = tenant + ":" + endpoint
+		}
+		requestHash := store.HashContent(r.URL.RequestURI() + "\n" + r.Header.Get("Content-Type") + "\n" + string(body))
+
+		// The result is stored even when the client has gone (a retry replays it)
+		ctx := context.WithoutCancel(r.Context())
+		result, err := store.ReserveIdempotencyKey(ctx, redisClient, endpoint, key)
+		switch {
+		case errors.Is(err, store.ErrIdempotencyKeyInProgress):
+			writeError(http.StatusConflict, err.Error())
+			return
+		case err != nil:
+			writeError(http.StatusInternalServerError, err.Error())
+			return
+		case result != nil:
+			if result.RequestHash != requestHash {
+				writeError(http.StatusUnprocessableEntity, "The idempotency key was already used for another request")
+				return
+			}
+			w.Header().Set("Content-Type", result.ContentType)
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			if result.Location != "" {
+				w.Header().Set("Location", result.Location)
+			}
+			w.WriteHeader(result.Status)
+			io.WriteString(w, result.Body)
+			return
+		}
+
+		recorder := &idempotencyRecorder{ResponseWriter: w, status: http.StatusOK}
+		handler(recorder, r, redisClient, indexName)
+
+		if recorder.status < 200 || recorder.status >= 300 {
+			if err := store.ReleaseIdempotencyKey(ctx, redisClient, endpoint, key); err != nil {
+				helpers.Logf(ctx, "🟠 Failed to release the idempotency key: %v", err)
+			}
+			return
+		}
+		err = store.SaveIdempotentResult(ctx, redisClient, endpoint, key, store.IdempotentResult{
+			RequestHash: requestHash,
+			Status:      recorder.status,
+			ContentType: recorder.Header().Get("Content-Type"),
+			Location:    recorder.Header().Get("Location"),
+			Body:        recorder.body.String(),
+		}, idempotencyTTL)
+		if err != nil {
+			helpers.Logf(ctx, "🟠 Failed to store the result of the idempotency key: %v", err)
+		}
+	}
+}
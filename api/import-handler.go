@@ -0,0 +1,149 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// ImportHandler handles the imports of the exports of other vector stores: the body is the export, read by the
+// importer of the format query parameter (see store.ParseExport), e.g. a LangChain JSON export or a LlamaIndex
+// docstore.json. The documents are stored in the collection query parameter with the label query parameter, and
+// embedded with the embedding model of the collection unless keep_embeddings keeps the exported embeddings; keep_ids
+// keeps the IDs of the export.
+func ImportHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.ImportResponse{
+			Success: false,
+			Error:   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	query := r.URL.Query()
+	format := query.Get("format")
+	if format == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ImportResponse{
+			Success: false,
+			Error:   "format is required (e.g. langchain or llamaindex)",
+		})
+		return
+	}
+
+	options := store.ImportOptions{}
+	for name, option := range map[string]*bool{"keep_ids": &options.KeepIDs, "keep_embeddings": &options.KeepEmbeddings} {
+		if value := query.Get(name); value != "" {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(models.ImportResponse{
+					Success: false,
+					Error:   name + " must be true or false",
+				})
+				return
+			}
+			*option = parsed
+		}
+	}
+
+	label, err := store.JoinLabels(query.Get("label"), nil)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ImportResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	options.Label = label
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ImportResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to read the body: %v", err),
+		})
+		return
+	}
+
+	docs, err := store.ParseExport(format, body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ImportResponse{
+			Format:  format,
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Resolve the collection of the documents
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, query.Get("collection"))
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.ImportResponse{
+			Format:  format,
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	statuses, kept, err := store.ImportDocuments(ctx, *openaiClient, redisClient, embeddingModelId, collection, docs, options)
+	if err != nil && statuses == nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.ImportResponse{
+			Format:  format,
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	response := models.ImportResponse{
+		Format:         format,
+		EmbeddingsKept: kept,
+		IDs:            []string{},
+		Success:        err == nil,
+	}
+	for _, status := range statuses {
+		switch status.Status {
+		case models.ChunkStatusStored:
+			response.Imported++
+			response.IDs = append(response.IDs, status.ID)
+		case models.ChunkStatusFailed:
+			response.Failed++
+		}
+	}
+	if response.Failed > 0 {
+		response.Statuses = statuses
+	}
+	if err != nil {
+		// The import was interrupted: the documents without status were not imported
+		response.Error = fmt.Sprintf("The import was interrupted after %d documents: %v", response.Imported+response.Failed, err)
+	} else if response.Imported == 0 && response.Failed > 0 {
+		response.Success = false
+		response.Error = "No document could be imported"
+	}
+
+	status := http.StatusOK
+	if !response.Success {
+		status = http.StatusInternalServerError
+	}
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// IndexInfoHandler handles requests for the information of the index (GET /index/info):
+// document count, indexing state and memory usage. The collection query parameter selects the index of a collection.
+func IndexInfoHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string, memoryGuard *store.MemoryGuard) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.IndexInfoResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET",
+		})
+		return
+	}
+
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, r.URL.Query().Get("collection"))
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.IndexInfoResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	info, err := store.GetIndexInfo(ctx, redisClient, collection.IndexName)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.IndexInfoResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	memoryInfo, err := store.GetMemoryInfo(ctx, redisClient)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.IndexInfoResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.IndexInfoResponse{
+		Index: &models.IndexInfo{
+			Name:               info.Name,
+			Collection:         collection.Name,
+			KeyPrefixes:        info.KeyPrefixes,
+			NumDocs:            info.NumDocs,
+			NumRecords:         info.NumRecords,
+			Indexing:           info.Indexing,
+			PercentIndexed:     info.PercentIndexed,
+			IndexingFailures:   info.IndexingFailures,
+			VectorIndexSizeMB:  info.VectorIndexSizeMB,
+			InvertedSizeMB:     info.InvertedSizeMB,
+			DocTableSizeMB:     info.DocTableSizeMB,
+			KeyTableSizeMB:     info.KeyTableSizeMB,
+			TotalIndexMemoryMB: info.TotalIndexMemoryMB,
+			EmbeddingDimension: collection.Dimension(GetEmbeddingDimension()),
+		},
+		Memory:  newMemoryStats(memoryInfo, memoryGuard),
+		Success: true,
+	})
+}
+
+// RebuildIndexHandler handles requests to rebuild the index (POST /index/rebuild): the index is dropped and created
+// again with the current settings, and Redis indexes the stored documents again in the background.
+// The collection query parameter selects the index of a collection.
+func RebuildIndexHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string, indexOptions store.IndexOptions) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.IndexResponse{
+			Success: false,
+			Error:   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, r.URL.Query().Get("collection"))
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.IndexResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	indexOptions.KeyPrefix = collection.KeyPrefix
+	if err := store.RebuildIndex(ctx, redisClient, collection.IndexName, collection.Dimension(GetEmbeddingDimension()), indexOptions); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.IndexResponse{
+			IndexName:  collection.IndexName,
+			Collection: collection.Name,
+			Success:    false,
+			Error:      err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.IndexResponse{
+		IndexName:  collection.IndexName,
+		Collection: collection.Name,
+		Success:    true,
+	})
+}
+
+// DeleteIndexHandler handles requests to reset the index (DELETE /index): the index and all its documents are deleted,
+// and an empty index is created again with the current settings. The collection query parameter selects the index of
+// a collection (the collection itself is kept, see DeleteCollectionHandler).
+func DeleteIndexHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string, indexOptions store.IndexOptions) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept DELETE requests
+	if r.Method != http.MethodDelete {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.IndexResponse{
+			Success: false,
+			Error:   "Method not allowed. Use DELETE",
+		})
+		return
+	}
+
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, r.URL.Query().Get("collection"))
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.IndexResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	indexOptions.KeyPrefix = collection.KeyPrefix
+	if err := store.ResetIndex(ctx, redisClient, collection.IndexName, collection.Dimension(GetEmbeddingDimension()), indexOptions); err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.IndexResponse{
+			IndexName:  collection.IndexName,
+			Collection: collection.Name,
+			Success:    false,
+			Error:      err.Error(),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.IndexResponse{
+		IndexName:  collection.IndexName,
+		Collection: collection.Name,
+		Success:    true,
+	})
+}
+
+// defaultDimensionCheckLimit is the default number of mismatched documents listed by the dimension check
+const defaultDimensionCheckLimit = 100
+
+// IndexDimensionsHandler handles requests to check the dimension of the stored vectors (GET /index/dimensions):
+// the documents embedded with a model of another dimension than the index are not indexed, and are listed.
+// The collection query parameter selects the index of a collection, limit the number of listed documents.
+func IndexDimensionsHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.IndexDimensionsResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET",
+		})
+		return
+	}
+
+	limit := defaultDimensionCheckLimit
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.IndexDimensionsResponse{
+				Success: false,
+				Error:   "limit must be a positive integer",
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, r.URL.Query().Get("collection"))
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.IndexDimensionsResponse{Success: false, Error: err.Error()})
+		return
+	}
+
+	check, err := store.CheckDocumentDimensions(ctx, redisClient, collection, limit)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.IndexDimensionsResponse{
+			IndexName:  collection.IndexName,
+			Collection: collection.Name,
+			Success:    false,
+			Error:      err.Error(),
+		})
+		return
+	}
+
+	documents := make([]models.DocumentDimension, len(check.Documents))
+	for i, document := range check.Documents {
+		documents[i] = models.DocumentDimension{ID: document.ID, Dimension: document.Dimension}
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.IndexDimensionsResponse{
+		IndexName:  collection.IndexName,
+		Collection: collection.Name,
+		Dimension:  check.Dimension,
+		Checked:    check.Checked,
+		Mismatched: check.Mismatched,
+		Documents:  documents,
+		Success:    true,
+	})
+}
@@ -0,0 +1,138 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"vectormind/events"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// repairJobs holds the last repair job of each index (by database and index name, see reembedJobKey)
+var (
+	repairJobs      = map[string]*models.IndexRepairJob{}
+	repairJobsMutex sync.Mutex
+)
+
+// IndexRepairHandler handles the repair jobs of the index: POST /admin/index/repair starts a job rebuilding the index
+// definition from the current settings and indexing the stored documents again (without re-embedding them),
+// GET /admin/index/repair returns the state of the last job and the tombstone of the index when its searches failed
+// because of a corruption. The collection query parameter selects the index of a collection.
+func IndexRepairHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string, indexOptions store.IndexOptions) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.IndexRepairResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET or POST",
+		})
+		return
+	}
+
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, r.URL.Query().Get("collection"))
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.IndexRepairResponse{Success: false, Error: err.Error()})
+		return
+	}
+	tombstone, err := store.GetIndexTombstone(ctx, redisClient, collection.IndexName)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.IndexRepairResponse{Success: false, Error: err.Error()})
+		return
+	}
+	key := reembedJobKey(redisClient, collection.IndexName)
+
+	repairJobsMutex.Lock()
+	defer repairJobsMutex.Unlock()
+	job := repairJobs[key]
+
+	if r.Method == http.MethodGet {
+		if job == nil && tombstone == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(models.IndexRepairResponse{
+				Success: false,
+				Error:   "No repair job for this index, and the index is not marked as corrupted",
+			})
+			return
+		}
+		response := models.IndexRepairResponse{Tombstone: tombstone, Success: true}
+		if job != nil {
+			snapshot := *job
+			response.Job = &snapshot
+		}
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(response)
+		return
+	}
+
+	if job != nil && job.Status == models.ReembedStatusRunning {
+		snapshot := *job
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(models.IndexRepairResponse{
+			Job:       &snapshot,
+			Tombstone: tombstone,
+			Success:   false,
+			Error:     "A repair job is already running for this index",
+		})
+		return
+	}
+
+	job = &models.IndexRepairJob{
+		IndexName:  collection.IndexName,
+		Collection: collection.Name,
+		Status:     models.ReembedStatusRunning,
+		StartedAt:  time.Now().Format(time.RFC3339),
+	}
+	repairJobs[key] = job
+	go runRepairJob(ctx, redisClient, collection, indexOptions, job)
+
+	snapshot := *job
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(models.IndexRepairResponse{Job: &snapshot, Tombstone: tombstone, Success: true})
+}
+
+// runRepairJob repairs the index of a collection and records the progress in the job
+func runRepairJob(ctx context.Context, redisClient *redis.Client, collection store.Collection, indexOptions store.IndexOptions, job *models.IndexRepairJob) {
+	updateJob := func(progress store.RepairProgress) {
+		repairJobsMutex.Lock()
+		defer repairJobsMutex.Unlock()
+		job.Total = progress.Total
+		job.Indexed = progress.Indexed
+		job.PercentIndexed = progress.PercentIndexed
+		job.IndexingFailures = progress.IndexingFailures
+	}
+
+	progress, err := store.RepairIndex(ctx, redisClient, collection, collection.Dimension(GetEmbeddingDimension()), indexOptions, updateJob)
+	updateJob(progress)
+
+	repairJobsMutex.Lock()
+	defer repairJobsMutex.Unlock()
+	job.Status = models.ReembedStatusCompleted
+	if err != nil {
+		job.Status = models.ReembedStatusFailed
+		job.Error = err.Error()
+	}
+	job.CompletedAt = time.Now().Format(time.RFC3339)
+
+	eventType, message := events.TypeJobCompleted, fmt.Sprintf("Repair of index %s completed", job.IndexName)
+	if job.Status == models.ReembedStatusFailed {
+		eventType, message = events.TypeJobFailed, fmt.Sprintf("Repair of index %s failed", job.IndexName)
+	}
+	events.Record(eventType, message, map[string]any{
+		"job":               "index_repair",
+		"index":             job.IndexName,
+		"db":                redisClient.Options().DB,
+		"total":             job.Total,
+		"indexed":           job.Indexed,
+		"indexing_failures": job.IndexingFailures,
+		"error":             job.Error,
+	})
+}
@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"vectormind/helpers"
+)
+
+// ParseCIDRList parses a comma separated list of CIDR ranges like "10.0.0.0/8,192.168.1.10".
+// A single IP address is a range of one address.
+func ParseCIDRList(spec string) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			addr, err := netip.ParseAddr(entry)
+			if err != nil {
+				return nil, fmt.Errorf("invalid IP address %q", entry)
+			}
+			addr = addr.Unmap()
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid CIDR range %q", entry)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// IPFilter allows or denies requests by client IP address.
+// The client IP is read from X-Forwarded-For only when the request comes from a trusted proxy.
+type IPFilter struct {
+	allow          []netip.Prefix // empty allows every address that is not denied
+	deny           []netip.Prefix
+	trustedProxies []netip.Prefix
+}
+
+// NewIPFilter creates an IP filter (deny ranges take precedence over allow ranges)
+func NewIPFilter(allow, deny, trustedProxies []netip.Prefix) *IPFilter {
+	return &IPFilter{allow: allow, deny: deny, trustedProxies: trustedProxies}
+}
+
+// ClientIP returns the IP address of the client of a request.
+// Behind trusted proxies, it is the rightmost X-Forwarded-For address that is not a trusted proxy.
+func (filter *IPFilter) ClientIP(r *http.Request) netip.Addr {
+	remoteAddr := remoteIP(r)
+	if filter == nil || !containsAddr(filter.trustedProxies, remoteAddr) {
+		return remoteAddr
+	}
+
+	forwarded := []string{}
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		forwarded = append(forwarded, strings.Split(header, ",")...)
+	}
+
+	clientAddr := remoteAddr
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		addr, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			// A malformed entry cannot be trusted, the last valid hop is the client
+			break
+		}
+		clientAddr = addr.Unmap()
+		if !containsAddr(filter.trustedProxies, clientAddr) {
+			break
+		}
+	}
+	return clientAddr
+}
+
+// Allowed reports whether a client IP address is allowed
+func (filter *IPFilter) Allowed(addr netip.Addr) bool {
+	if filter == nil {
+		return true
+	}
+	if containsAddr(filter.deny, addr) {
+		return false
+	}
+	return len(filter.allow) == 0 || containsAddr(filter.allow, addr)
+}
+
+type clientIPContextKey struct{}
+
+// ClientIPFromContext returns the client IP address resolved by WithIPFilter
+func ClientIPFromContext(ctx context.Context) (netip.Addr, bool) {
+	addr, ok := ctx.Value(clientIPContextKey{}).(netip.Addr)
+	return addr, ok
+}
+
+// WithIPFilter refuses the requests of the clients that are not allowed with 403 Forbidden,
+// and makes the client IP of the allowed requests available with ClientIPFromContext
+func WithIPFilter(filter *IPFilter, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		clientIP := filter.ClientIP(r)
+		if !filter.Allowed(clientIP) {
+			helpers.Logf(r.Context(), "🟠 Request from %s refused by the IP filter", clientIP)
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"error":   "Forbidden",
+			})
+			return
+		}
+		handler.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), clientIPContextKey{}, clientIP)))
+	})
+}
+
+// remoteIP returns the IP address of the peer of a request
+func remoteIP(r *http.Request) netip.Addr {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}
+	}
+	return addr.Unmap()
+}
+
+func containsAddr(prefixes []netip.Prefix, addr netip.Addr) bool {
+	if !addr.IsValid() {
+		return false
+	}
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"vectormind/models"
+	"vectormind/store"
+)
+
+// respondIngestionJob responds to an asynchronous chunk and store request with its job (202 Accepted)
+func respondIngestionJob(w http.ResponseWriter, job models.IngestionJob) {
+	w.Header().Set("Location", "/jobs/"+job.ID)
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(models.IngestionJobResponse{Job: &job, Success: true})
+}
+
+// IngestionJobHandler handles requests for the progress of an asynchronous chunk and store request (GET /jobs/{id})
+func IngestionJobHandler(w http.ResponseWriter, r *http.Request, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.IngestionJobResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET",
+		})
+		return
+	}
+
+	job, ok := store.GetIngestionJob(indexName, r.PathValue("id"))
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.IngestionJobResponse{
+			Success: false,
+			Error:   "Ingestion job not found (completed jobs are kept for one hour)",
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.IngestionJobResponse{Job: &job, Success: true})
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"vectormind/store"
+)
+
+// WithMemoryGuard refuses the write requests (POST and PUT) with 507 Insufficient Storage
+// when Redis uses more memory than the configured watermark
+func WithMemoryGuard(memoryGuard *store.MemoryGuard, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost || r.Method == http.MethodPut {
+			if err := memoryGuard.CheckWrite(r.Context()); err != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusInsufficientStorage)
+				json.NewEncoder(w).Encode(map[string]interface{}{
+					"success": false,
+					"error":   "Write refused: " + err.Error(),
+				})
+				return
+			}
+		}
+		handler(w, r)
+	}
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"vectormind/archive"
+	"vectormind/store"
+)
+
+// OriginalDocumentHandler handles requests for the archived original (pre-chunk) document of a source ID
+// (GET /documents/{source_id}/original) of the tenant of an index. The document is returned as is with an ETag
+// (conditional with If-None-Match), errors are returned as JSON.
+func OriginalDocumentHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, indexName string) {
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		writeOriginalError(w, http.StatusMethodNotAllowed, "Method not allowed. Use GET")
+		return
+	}
+
+	// The original document is only content, it is refused to the callers that only decide relevance
+	if !RequestRole(r).CanReadContent() {
+		writeOriginalError(w, http.StatusForbidden, "The role of the API key does not allow reading document content")
+		return
+	}
+
+	sourceID := r.PathValue("source_id")
+	original, err := store.GetOriginal(ctx, indexName, sourceID)
+	switch {
+	case errors.Is(err, store.ErrArchiveDisabled):
+		writeOriginalError(w, http.StatusNotFound, "Original documents are not archived (set ARCHIVE_BACKEND to enable the archive)")
+		return
+	case errors.Is(err, archive.ErrNotFound):
+		writeOriginalError(w, http.StatusNotFound, "Original document not found")
+		return
+	case err != nil:
+		writeOriginalError(w, http.StatusInternalServerError, fmt.Sprintf("Failed to get original document: %v", err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	writeConditional(w, r, original)
+}
+
+func writeOriginalError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": false,
+		"error":   message,
+	})
+}
@@ -0,0 +1,93 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// QualityReportHandler handles requests listing the stored chunks with the lowest quality scores
+func QualityReportHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.QualityReportResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET",
+		})
+		return
+	}
+
+	limit := 20 // Default value
+	if value := r.URL.Query().Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.QualityReportResponse{
+				Success: false,
+				Error:   "limit must be a positive integer",
+			})
+			return
+		}
+		limit = parsed
+	}
+
+	var maxQuality *float64
+	if value := r.URL.Query().Get("max_quality"); value != "" {
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.QualityReportResponse{
+				Success: false,
+				Error:   "max_quality must be a number",
+			})
+			return
+		}
+		maxQuality = &parsed
+	}
+
+	docs, err := store.LowestQualityDocuments(ctx, redisClient, indexName, limit, maxQuality)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.QualityReportResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to build quality report: %v", err),
+		})
+		return
+	}
+
+	// Withhold the content from the callers that only decide relevance
+	redacted := !RequestRole(r).CanReadContent()
+
+	chunks := make([]models.QualityReportEntry, 0, len(docs))
+	for _, doc := range docs {
+		result := store.DocumentToSearchResult(doc)
+		if redacted {
+			result.Content = ""
+		}
+		chunks = append(chunks, models.QualityReportEntry{
+			ID:        result.ID,
+			Content:   result.Content,
+			Label:     result.Label,
+			Metadata:  result.Metadata,
+			Quality:   result.Quality,
+			CreatedAt: result.CreatedAt,
+		})
+	}
+
+	// Success response
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.QualityReportResponse{
+		Chunks:   chunks,
+		Redacted: redacted,
+		Success:  true,
+	})
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"net/http"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// WithQuotaWarnings adds to the successful JSON responses of the writes the warnings of the quotas approached by the
+// documents they wrote, as "warnings" (see store.QuotaWarnings). The responses without warning are unchanged.
+func WithQuotaWarnings(memoryGuard *store.MemoryGuard, handler TenantHandler) TenantHandler {
+	return func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, indexName string) {
+		ctx := store.TrackWrites(r.Context())
+		editor := &jsonResponseEditor{ResponseWriter: w, edited: func(status int) bool { return status < http.StatusMultipleChoices }}
+		handler(editor, r.WithContext(ctx), redisClient, indexName)
+		editor.flush(func(object map[string]any) bool {
+			warnings := store.QuotaWarnings(ctx, redisClient, indexName, memoryGuard)
+			if len(warnings) == 0 {
+				return false
+			}
+			object["warnings"] = warnings
+			return true
+		})
+	}
+}
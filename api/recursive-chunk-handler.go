@@ -0,0 +1,222 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"vectormind/models"
+	"vectormind/splitter"
+	"vectormind/store"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// RecursiveChunkAndStoreHandler handles requests to chunk a document at paragraph, then sentence, then word boundaries
+// (see splitter.RecursiveSplit) and store all chunks
+func RecursiveChunkAndStoreHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	// Parse request body (JSON, or the document as a text/plain or text/markdown body)
+	var req models.RecursiveChunkAndStoreRequest
+	if err := decodeRequestBody(r, "document", &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+
+	// The labels are stored together in the label field
+	label, err := store.JoinLabels(req.Label, req.Labels)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.Label = label
+	ctx = store.WithUsageLabel(ctx, label)
+
+	// Validate required fields
+	if req.Document == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   "Document is required",
+		})
+		return
+	}
+
+	if err := store.ValidateIDStrategy(req.IDStrategy); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if req.ChunkSize <= 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   "ChunkSize must be greater than 0",
+		})
+		return
+	}
+
+	if req.Overlap < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   "Overlap cannot be negative",
+		})
+		return
+	}
+
+	if req.Overlap >= req.ChunkSize {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   "Overlap must be less than ChunkSize",
+		})
+		return
+	}
+
+	// Chunk the document
+	chunks := splitter.RecursiveSplit(req.Document, req.ChunkSize, req.Overlap, req.Separators)
+
+	if len(chunks) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   "No chunks generated from the document",
+		})
+		return
+	}
+
+	// Validate that every chunk fits the context window of the embedding model
+	maxTokens := GetEmbeddingMaxTokens()
+	for _, chunk := range chunks {
+		if tokens := splitter.CountTokens(chunk); tokens > maxTokens {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+				Success: false,
+				Error:   fmt.Sprintf("ChunkSize (%d characters) produces chunks of about %d tokens, above the embedding model limit (%d tokens)", req.ChunkSize, tokens, maxTokens),
+			})
+			return
+		}
+	}
+
+	// Expiration of the chunks
+	ttl, err := store.DocumentTTL(req.TTLSeconds)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Handling of the chunks already stored
+	if err := store.ValidateDedupMode(req.Dedup); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// An atomic ingestion stores all the chunks or none
+	if err := store.ValidateAtomic(req.Atomic, req.ContinueOnError); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Resolve the collection of the chunks
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, req.Collection)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	embeddingModelId = collection.ModelID(embeddingModelId)
+
+	chunkOptions := store.ChunkOptions{
+		Label:           req.Label,
+		Metadata:        req.Metadata,
+		IDStrategy:      req.IDStrategy,
+		SourceID:        req.SourceID,
+		ContinueOnError: req.ContinueOnError,
+		Rollback:        req.Rollback,
+		Atomic:          req.Atomic,
+		Original:        req.Document,
+		KeyPrefix:       collection.KeyPrefix,
+		TTL:             ttl,
+		Dedup:           req.Dedup,
+		IndexName:       collection.IndexName,
+	}
+
+	// Store the chunks in the background: the job reports the progress
+	if req.Async {
+		respondIngestionJob(w, store.StartIngestionJob(ctx, *openaiClient, redisClient, embeddingModelId, chunks, chunkOptions))
+		return
+	}
+
+	// Store all chunks
+	createdAt := time.Now()
+	statuses, err := store.StoreChunks(ctx, *openaiClient, redisClient, embeddingModelId, chunks, chunkOptions)
+	if err != nil && len(statuses) == 0 {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.ChunkAndStoreResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to store chunks: %v", err),
+		})
+		return
+	}
+
+	chunkIDs, chunksFailed := store.StoredChunkIDs(statuses)
+	response := models.ChunkAndStoreResponse{
+		SourceID:     store.OriginalSourceID(req.SourceID, req.Document),
+		ChunkIDs:     chunkIDs,
+		Chunks:       store.ChunkPreviews(chunks, statuses, req.IncludeContent),
+		ChunksStored: len(chunkIDs),
+		ChunksFailed: chunksFailed,
+		CreatedAt:    createdAt,
+		Success:      chunksFailed == 0 && err == nil,
+	}
+	if req.ContinueOnError || err != nil {
+		response.ChunkStatuses = statuses
+	}
+
+	// Success response (or partial success when some chunks failed in continue_on_error mode,
+	// or the chunks stored before the failure that aborted the ingestion)
+	httpStatus, errorMessage := chunkStoreOutcome(len(chunkIDs), chunksFailed, err)
+	response.Error = errorMessage
+	writeChunkStoreResponse(w, httpStatus, response.Chunks, response)
+}
@@ -0,0 +1,135 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+	"vectormind/events"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// reembedJobs holds the last re-embedding job of each index (by database and index name)
+var (
+	reembedJobs      = map[string]*models.ReembedJob{}
+	reembedJobsMutex sync.Mutex
+)
+
+// reembedJobKey identifies the index of a re-embedding job (the indexes of the tenants have their own names)
+func reembedJobKey(redisClient *redis.Client, indexName string) string {
+	return fmt.Sprintf("%d/%s", redisClient.Options().DB, indexName)
+}
+
+// ReembedHandler handles the re-embedding jobs of the index: POST /index/reembed starts a job re-encoding all the
+// stored documents with the current embedding model (rebuilding the index when the dimension has changed),
+// GET /index/reembed returns the state of the last job. The collection query parameter selects the index of a collection.
+func ReembedHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId, indexName string, indexOptions store.IndexOptions) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.ReembedJobResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET or POST",
+		})
+		return
+	}
+
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, r.URL.Query().Get("collection"))
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.ReembedJobResponse{Success: false, Error: err.Error()})
+		return
+	}
+	embeddingModelId = collection.ModelID(embeddingModelId)
+	key := reembedJobKey(redisClient, collection.IndexName)
+
+	reembedJobsMutex.Lock()
+	defer reembedJobsMutex.Unlock()
+	job := reembedJobs[key]
+
+	if r.Method == http.MethodGet {
+		if job == nil {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(models.ReembedJobResponse{
+				Success: false,
+				Error:   "No re-embedding job for this index",
+			})
+			return
+		}
+		snapshot := *job
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(models.ReembedJobResponse{Job: &snapshot, Success: true})
+		return
+	}
+
+	if job != nil && job.Status == models.ReembedStatusRunning {
+		snapshot := *job
+		w.WriteHeader(http.StatusConflict)
+		json.NewEncoder(w).Encode(models.ReembedJobResponse{
+			Job:     &snapshot,
+			Success: false,
+			Error:   "A re-embedding job is already running for this index",
+		})
+		return
+	}
+
+	job = &models.ReembedJob{
+		IndexName:      collection.IndexName,
+		Collection:     collection.Name,
+		EmbeddingModel: embeddingModelId,
+		Status:         models.ReembedStatusRunning,
+		StartedAt:      time.Now().Format(time.RFC3339),
+	}
+	reembedJobs[key] = job
+	go runReembedJob(ctx, openaiClient, redisClient, embeddingModelId, collection, indexOptions, job)
+
+	snapshot := *job
+	w.WriteHeader(http.StatusAccepted)
+	json.NewEncoder(w).Encode(models.ReembedJobResponse{Job: &snapshot, Success: true})
+}
+
+// runReembedJob re-encodes the documents of a collection and records the progress in the job
+func runReembedJob(ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId string, collection store.Collection, indexOptions store.IndexOptions, job *models.ReembedJob) {
+	updateJob := func(progress store.ReembedProgress) {
+		reembedJobsMutex.Lock()
+		defer reembedJobsMutex.Unlock()
+		job.Total = progress.Total
+		job.Reembedded = progress.Reembedded
+		job.Failed = progress.Failed
+		job.Error = progress.LastError
+	}
+
+	progress, err := store.MigrateIndex(ctx, *openaiClient, redisClient, embeddingModelId, collection, collection.Dimension(GetEmbeddingDimension()), indexOptions, updateJob)
+	updateJob(progress)
+
+	reembedJobsMutex.Lock()
+	defer reembedJobsMutex.Unlock()
+	job.Status = models.ReembedStatusCompleted
+	if err != nil {
+		job.Status = models.ReembedStatusFailed
+		job.Error = err.Error()
+	}
+	job.CompletedAt = time.Now().Format(time.RFC3339)
+
+	eventType, message := events.TypeJobCompleted, fmt.Sprintf("Re-embedding of index %s completed", job.IndexName)
+	if job.Status == models.ReembedStatusFailed {
+		eventType, message = events.TypeJobFailed, fmt.Sprintf("Re-embedding of index %s failed", job.IndexName)
+	}
+	events.Record(eventType, message, map[string]any{
+		"job":        "reembed",
+		"index":      job.IndexName,
+		"db":         redisClient.Options().DB,
+		"model":      job.EmbeddingModel,
+		"total":      job.Total,
+		"reembedded": job.Reembedded,
+		"failed":     job.Failed,
+		"error":      job.Error,
+	})
+}
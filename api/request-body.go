@@ -0,0 +1,156 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// textContentTypes are the content types of the request bodies holding the document itself instead of JSON
+var textContentTypes = map[string]bool{
+	"text/plain":           true,
+	"text/markdown":        true,
+	"text/html":            true, // HTML pages (UTF-8)
+	"message/rfc822":       true, // email messages (.eml)
+	"application/mbox":     true, // email archives
+	"text/vtt":             true, // WebVTT subtitles
+	"application/x-subrip": true, // SRT subtitles
+}
+
+// binaryContentTypes are the content types of the request bodies holding a binary document (e.g. an Office file),
+// decoded into the []byte content field of the request
+var binaryContentTypes = map[string]bool{
+	"application/octet-stream": true,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   true, // .docx
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": true, // .pptx
+}
+
+// decodeRequestBody decodes the body of an ingestion request.
+// JSON bodies are decoded as is. A text/plain or text/markdown body is the content of the document (the field
+// named contentField in JSON), and the other fields are read from the query parameters, or from X- headers
+// (e.g. "?label=docs&chunk_size=512" or "X-Label: docs" and "X-Chunk-Size: 512").
+// A binary body (see binaryContentTypes) is decoded the same way into a []byte content field.
+func decodeRequestBody(r *http.Request, contentField string, req any) error {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if !textContentTypes[mediaType] && !binaryContentTypes[mediaType] {
+		return json.NewDecoder(r.Body).Decode(req)
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read the body: %w", err)
+	}
+	if textContentTypes[mediaType] && !utf8.Valid(body) {
+		return fmt.Errorf("the %s body is not valid UTF-8", mediaType)
+	}
+	return decodeTextRequest(r, contentField, string(body), reflect.ValueOf(req).Elem())
+}
+
+// decodeTextRequest sets the content field to the body, and the other fields from the query parameters or headers
+func decodeTextRequest(r *http.Request, contentField, body string, value reflect.Value) error {
+	query := r.URL.Query()
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			// Embedded options (e.g. ChunkStoreOptions)
+			if err := decodeTextRequest(r, contentField, body, value.Field(i)); err != nil {
+				return err
+			}
+			continue
+		}
+
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == contentField {
+			if value.Field(i).Kind() == reflect.String {
+				if !utf8.ValidString(body) {
+					return fmt.Errorf("the body is not valid UTF-8 (%s expects a text document)", contentField)
+				}
+				value.Field(i).SetString(body)
+			} else {
+				value.Field(i).SetBytes([]byte(body))
+			}
+			continue
+		}
+
+		param, ok := query[name]
+		raw := ""
+		if ok {
+			raw = param[0]
+		} else if header := r.Header.Get(paramHeader(name)); header != "" {
+			raw, ok = header, true
+		}
+		if !ok {
+			continue
+		}
+		if err := setParam(value.Field(i), raw); err != nil {
+			return fmt.Errorf("invalid %s parameter %q: %w", name, raw, err)
+		}
+	}
+	return nil
+}
+
+// paramHeader returns the header carrying a request field (e.g. "X-Chunk-Size" for "chunk_size")
+func paramHeader(name string) string {
+	return "X-" + strings.ReplaceAll(name, "_", "-")
+}
+
+// setParam sets a request field from the text of a query parameter or a header
+func setParam(field reflect.Value, raw string) error {
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(raw)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("expected a boolean")
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int64:
+		n, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return fmt.Errorf("expected an integer")
+		}
+		field.SetInt(n)
+	case reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("expected a number")
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("unsupported list parameter")
+		}
+		// Lists of strings are comma separated (e.g. "labels=docs,wiki")
+		items := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	case reflect.Pointer:
+		pointed := reflect.New(field.Type().Elem())
+		if err := setParam(pointed.Elem(), raw); err != nil {
+			return err
+		}
+		field.Set(pointed)
+	default:
+		// Structured fields (e.g. the options of a strategy) are passed as JSON
+		pointed := reflect.New(field.Type())
+		if err := json.Unmarshal([]byte(raw), pointed.Interface()); err != nil {
+			return fmt.Errorf("expected JSON")
+		}
+		field.Set(pointed.Elem())
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"time"
+	"vectormind/helpers"
+)
+
+// RequestIDHeader is the header of the request ID, sent by the callers (optional) and in all the responses
+const RequestIDHeader = "X-Request-ID"
+
+// RequestIDContext returns a context carrying the ID of a request: its X-Request-ID header when it is valid,
+// or a new ID
+func RequestIDContext(ctx context.Context, r *http.Request) context.Context {
+	id := r.Header.Get(RequestIDHeader)
+	if !helpers.ValidRequestID(id) {
+		id = helpers.NewRequestID()
+	}
+	return helpers.WithRequestID(ctx, id)
+}
+
+// WithRequestID identifies each request (see RequestIDContext): the ID is sent back in the X-Request-ID header,
+// added as request_id to the JSON error responses, and logged with the failed requests and the warnings of the
+// handlers (see helpers.Logf)
+func WithRequestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ctx := RequestIDContext(r.Context(), r)
+		id := helpers.RequestID(ctx)
+		w.Header().Set(RequestIDHeader, id)
+
+		editor := &jsonResponseEditor{ResponseWriter: w, edited: func(status int) bool { return status >= http.StatusBadRequest }}
+		handler.ServeHTTP(editor, r.WithContext(ctx))
+		editor.flush(func(object map[string]any) bool {
+			if _, ok := object["request_id"]; ok {
+				return false
+			}
+			object["request_id"] = id
+			return true
+		})
+
+		if editor.status >= http.StatusBadRequest {
+			helpers.Logf(ctx, "🟠 %s %s: %d %s in %s", r.Method, r.URL.Path, editor.status, http.StatusText(editor.status), time.Since(start).Round(time.Millisecond))
+		}
+	})
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+)
+
+// jsonResponseEditor records the status of a response, and holds the body of the JSON responses whose status is
+// edited, to add fields to it (the other responses, and the streams, are written through)
+type jsonResponseEditor struct {
+	http.ResponseWriter
+	edited func(status int) bool
+	status int
+	body   *bytes.Buffer // nil when the body is written through
+}
+
+func (editor *jsonResponseEditor) WriteHeader(status int) {
+	if editor.status == 0 {
+		editor.status = status
+		if editor.edited(status) && strings.HasPrefix(editor.Header().Get("Content-Type"), "application/json") {
+			editor.body = &bytes.Buffer{}
+		}
+	}
+	editor.ResponseWriter.WriteHeader(status)
+}
+
+func (editor *jsonResponseEditor) Write(data []byte) (int, error) {
+	if editor.status == 0 {
+		editor.WriteHeader(http.StatusOK)
+	}
+	if editor.body != nil {
+		return editor.body.Write(data)
+	}
+	return editor.ResponseWriter.Write(data)
+}
+
+// Unwrap returns the response writer, so that the streaming handlers flush it (see http.ResponseController)
+func (editor *jsonResponseEditor) Unwrap() http.ResponseWriter {
+	return editor.ResponseWriter
+}
+
+// flush writes the held body, re-encoded when it is a JSON object changed by edit (edit returns whether it changed
+// the object, the body is written as it is otherwise)
+func (editor *jsonResponseEditor) flush(edit func(object map[string]any) bool) {
+	if editor.body == nil {
+		return
+	}
+	body := editor.body.Bytes()
+	var object map[string]any
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber() // the numbers are written back as they are
+	if err := decoder.Decode(&object); err == nil && object != nil && edit(object) {
+		if encoded, err := json.Marshal(object); err == nil {
+			body = append(encoded, '\n')
+		}
+	}
+	editor.ResponseWriter.Write(body)
+}
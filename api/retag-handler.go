@@ -0,0 +1,190 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RenameLabelHandler handles requests to rename a label in all the documents having it (POST /labels/rename).
+// The other labels of the documents are kept.
+func RenameLabelHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Success: false,
+			Error:   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	// Parse request body
+	var req models.RenameLabelRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+
+	// Validate required fields
+	if req.From == "" || req.To == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Success: false,
+			Error:   "From and to are required",
+		})
+		return
+	}
+
+	if err := store.ValidateLabelChange(store.LabelChange{Add: []string{req.To}, Remove: []string{req.From}}); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Resolve the collection of the documents
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, req.Collection)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	result, err := store.RenameLabel(ctx, redisClient, collection.IndexName, req.From, req.To)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Matched: result.Matched,
+			Updated: result.Updated,
+			Success: false,
+			Error:   fmt.Sprintf("Failed to rename the label: %v", err),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+		Matched: result.Matched,
+		Updated: result.Updated,
+		Success: true,
+	})
+}
+
+// RetagDocumentsHandler handles requests to change the labels of the documents matching a filter
+// (POST /documents/retag): the labels are replaced (set_labels), or added and removed (add_labels, remove_labels).
+func RetagDocumentsHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Success: false,
+			Error:   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	// Parse request body
+	var req models.RetagDocumentsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+
+	// A filter is required, so that a request cannot relabel the whole index by mistake
+	if req.Label == "" && len(req.Labels) == 0 && req.MinQuality == nil && len(req.Filters) == 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Success: false,
+			Error:   "A filter is required: label, labels, min_quality or filters",
+		})
+		return
+	}
+
+	if err := store.ValidateLabelMatch(req.Match); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	filters, err := store.ParseMetadataFilters(req.Filters)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid filters: %v", err),
+		})
+		return
+	}
+
+	change := store.LabelChange{Set: req.SetLabels, Add: req.AddLabels, Remove: req.RemoveLabels}
+	if err := store.ValidateLabelChange(change); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Resolve the collection of the documents
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, req.Collection)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	result, err := store.RetagDocuments(ctx, redisClient, collection.IndexName, store.SearchOptions{
+		Label:          req.Label,
+		Labels:         req.Labels,
+		MatchAllLabels: req.Match == store.LabelMatchAll,
+		MinQuality:     req.MinQuality,
+		Filters:        filters,
+	}, change)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+			Matched: result.Matched,
+			Updated: result.Updated,
+			Success: false,
+			Error:   fmt.Sprintf("Failed to change the labels: %v", err),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.RetagDocumentsResponse{
+		Matched: result.Matched,
+		Updated: result.Updated,
+		Success: true,
+	})
+}
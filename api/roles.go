@@ -0,0 +1,100 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"vectormind/models"
+)
+
+// APIKeyHeader is the request header carrying the API key of the caller (an "Authorization: Bearer" header is also accepted)
+const APIKeyHeader = "X-API-Key"
+
+// Role defines which fields of the stored documents a caller receives
+type Role string
+
+const (
+	// RoleFull receives the documents with their content
+	RoleFull Role = "full"
+	// RoleMetadataOnly receives the IDs, distances, labels and metadata of the documents, but not their content
+	RoleMetadataOnly Role = "metadata_only"
+)
+
+var apiKeyRoles = map[string]Role{}
+var defaultRole = RoleFull
+
+// ParseRole checks that a role name is known
+func ParseRole(name string) (Role, error) {
+	switch role := Role(strings.TrimSpace(name)); role {
+	case RoleFull, RoleMetadataOnly:
+		return role, nil
+	default:
+		return "", fmt.Errorf("unknown role %q (use %s or %s)", name, RoleFull, RoleMetadataOnly)
+	}
+}
+
+// ParseAPIKeyRoles parses a list of API key roles like "orchestrator-key=metadata_only,llm-key=full"
+func ParseAPIKeyRoles(spec string) (map[string]Role, error) {
+	roles := map[string]Role{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, name, found := strings.Cut(entry, "=")
+		key = strings.TrimSpace(key)
+		if !found || key == "" {
+			return nil, fmt.Errorf("invalid API key role %q (expected key=role)", entry)
+		}
+		role, err := ParseRole(name)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := roles[key]; exists {
+			return nil, fmt.Errorf("duplicate API key in roles")
+		}
+		roles[key] = role
+	}
+	return roles, nil
+}
+
+// SetAPIKeyRoles sets the roles of the API keys, and the role of the requests without a known API key
+func SetAPIKeyRoles(roles map[string]Role, role Role) {
+	if roles == nil {
+		roles = map[string]Role{}
+	}
+	apiKeyRoles = roles
+	defaultRole = role
+}
+
+// requestAPIKey returns the API key of a request ("" when there is none)
+func requestAPIKey(r *http.Request) string {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		key, _ = strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	}
+	return key
+}
+
+// RequestRole returns the role of the API key of a request (the default role when the key is missing or unknown)
+func RequestRole(r *http.Request) Role {
+	key := requestAPIKey(r)
+	if role, ok := apiKeyRoles[key]; ok && key != "" {
+		return role
+	}
+	return defaultRole
+}
+
+// CanReadContent reports whether the role receives the content of the documents
+func (role Role) CanReadContent() bool {
+	return role != RoleMetadataOnly
+}
+
+// redactSearchResults removes the content of the search results (and the titles taken from the content)
+func redactSearchResults(results []models.SimilaritySearchResult) {
+	for i := range results {
+		results[i].Content = ""
+		results[i].Title = ""
+		results[i].Hierarchy = ""
+	}
+}
@@ -0,0 +1,246 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// SimilaritySearchWithLabelsHandler handles similarity search requests filtered by several labels
+// (documents having any of the labels, or all of them)
+func SimilaritySearchWithLabelsHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId, indexName string) {
+	start := time.Now()
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept POST requests
+	if r.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   "Method not allowed. Use POST",
+		})
+		return
+	}
+
+	// Parse request body
+	var req models.SimilaritySearchWithLabelsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+
+	// Validate required fields
+	if req.Text == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   "Text is required",
+		})
+		return
+	}
+
+	labels, err := store.JoinLabels("", req.Labels)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	if labels == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   "Labels are required",
+		})
+		return
+	}
+	ctx = store.WithUsageLabel(ctx, labels)
+
+	if err := store.ValidateLabelMatch(req.Match); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if req.MaxCount <= 0 {
+		req.MaxCount = 5 // Default value
+	}
+
+	if req.TimeoutMs < 0 {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   "timeout_ms cannot be negative",
+		})
+		return
+	}
+
+	if err := store.ValidateSnippetSize(req.SnippetSize); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateExpandContext(req.ExpandContext); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateMaxContentChars(req.MaxContentChars); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateDiversity(req.Diversity); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	if err := store.ValidateRecency(req.RecencyHalfLifeHours, req.RecencyWeight); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	filters, err := store.ParseMetadataFilters(req.Filters)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Invalid filters: %v", err),
+		})
+		return
+	}
+
+	if req.Rerank && !store.RerankEnabled() {
+		w.WriteHeader(errorStatus(store.ErrRerankerMissing))
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   "Reranking is not available (RERANK_PROVIDER is not set, or the reranking feature is disabled)",
+		})
+		return
+	}
+
+	if req.ExpandQuery && !store.ExpansionEnabled() {
+		w.WriteHeader(errorStatus(store.ErrChatModelMissing))
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   "Query expansion is not available (CHAT_MODEL is not set, or the query expansion feature is disabled)",
+		})
+		return
+	}
+
+	// Resolve the collection of the documents
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, req.Collection)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.SimilaritySearchResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	embeddingModelId = collection.ModelID(embeddingModelId)
+
+	// Paraphrases of the query searched with it (the search goes on without them when the chat model fails)
+	var variants []string
+	if req.ExpandQuery {
+		variants = expandQuery(ctx, req.Text)
+	}
+
+	// Perform similarity search with labels filter (query embedding and vector search within the time budget)
+	docs, fallback, timings, err := store.SearchByTextWithTimings(ctx, *openaiClient, redisClient, embeddingModelId, collection.IndexName, req.Text, searchCount(req.MaxCount, req.Rerank, req.RecencyHalfLifeHours > 0), store.SearchOptions{
+		Labels:         store.SplitLabels(labels),
+		MatchAllLabels: req.Match == store.LabelMatchAll,
+		MinQuality:     req.MinQuality,
+		MaxDistance:    req.DistanceThreshold,
+		Filters:        filters,
+		Diversity:      req.Diversity,
+		QueryVariants:  variants,
+	}, store.TextSearchBudget{
+		Timeout:         time.Duration(req.TimeoutMs) * time.Millisecond,
+		KeywordFallback: req.KeywordFallback,
+	})
+	if err != nil {
+		writeSearchError(w, err)
+		return
+	}
+	postStart := time.Now()
+
+	// Convert results to response format
+	results := store.TextSearchResults(docs, fallback, req.DistanceThreshold)
+	var reranked bool
+	if req.Rerank {
+		results, reranked = rerankSearchResults(ctx, req.Text, results, req.MaxCount)
+	}
+	if req.RecencyHalfLifeHours > 0 {
+		results = rankByRecency(results, fallback, req.RecencyHalfLifeHours, req.RecencyWeight, req.MaxCount)
+	}
+
+	// Withhold the content from the callers that only decide relevance
+	redacted := !RequestRole(r).CanReadContent()
+	if redacted {
+		redactSearchResults(results)
+	}
+
+	// The snippets are skipped when the query embedding already timed out
+	if req.SnippetSize > 0 && !redacted && fallback == "" {
+		addSnippets(ctx, *openaiClient, req.Text, results, embeddingModelId, req.SnippetSize)
+	}
+
+	if req.ExpandContext > 0 && !redacted {
+		expandSearchResults(ctx, redisClient, collection.IndexName, results, req.ExpandContext)
+	}
+
+	// The snippets are made from the whole content before it is cut
+	store.TruncateSearchResults(results, req.MaxContentChars)
+
+	// Success response
+	response := models.SimilaritySearchResponse{
+		Results:         results,
+		Redacted:        redacted,
+		Fallback:        fallback,
+		Reranked:        reranked,
+		ExpandedQueries: variants,
+		Success:         true,
+	}
+	if req.Debug {
+		response.Timings = newSearchTimings(timings, time.Since(postStart), time.Since(start))
+	}
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(response)
+}
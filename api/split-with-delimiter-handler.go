@@ -4,14 +4,13 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"time"
+	"vectormind/helpers"
 	"vectormind/models"
 	"vectormind/splitter"
 	"vectormind/store"
 
-	"github.com/google/uuid"
 	"github.com/openai/openai-go"
 	"github.com/redis/go-redis/v9"
 )
@@ -30,9 +29,9 @@ func SplitAndStoreWithDelimiterHandler(w http.ResponseWriter, r *http.Request, c
 		return
 	}
 
-	// Parse request body
+	// Parse request body (JSON, or the document as a text/plain or text/markdown body)
 	var req models.SplitAndStoreWithDelimiterRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+	if err := decodeRequestBody(r, "document", &req); err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.SplitAndStoreWithDelimiterResponse{
 			Success: false,
@@ -41,6 +40,19 @@ func SplitAndStoreWithDelimiterHandler(w http.ResponseWriter, r *http.Request, c
 		return
 	}
 
+	// The labels are stored together in the label field
+	label, err := store.JoinLabels(req.Label, req.Labels)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SplitAndStoreWithDelimiterResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	req.Label = label
+	ctx = store.WithUsageLabel(ctx, label)
+
 	// Validate required fields
 	if req.Document == "" {
 		w.WriteHeader(http.StatusBadRequest)
@@ -51,6 +63,15 @@ func SplitAndStoreWithDelimiterHandler(w http.ResponseWriter, r *http.Request, c
 		return
 	}
 
+	if err := store.ValidateIDStrategy(req.IDStrategy); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SplitAndStoreWithDelimiterResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
 	if req.Delimiter == "" {
 		w.WriteHeader(http.StatusBadRequest)
 		json.NewEncoder(w).Encode(models.SplitAndStoreWithDelimiterResponse{
@@ -72,74 +93,116 @@ func SplitAndStoreWithDelimiterHandler(w http.ResponseWriter, r *http.Request, c
 		return
 	}
 
-	// Get embedding dimension for validation
-	embeddingDim := GetEmbeddingDimension()
+	// Get the embedding model context window for validation
+	maxTokens := GetEmbeddingMaxTokens()
 
-	// Store all chunks (subdividing if necessary)
-	chunkIDs := make([]string, 0)
-	createdAt := time.Now()
+	// Collect all chunks to store (subdividing if necessary)
+	allChunks := make([]string, 0, len(chunks))
 
 	for _, chunk := range chunks {
-		// Extract first 2 non-empty lines from the chunk
-		chunkHeader := splitter.ExtractFirstNonEmptyLines(chunk, 2)
-
-		// If chunk is larger than embedding dimension, subdivide it
-		var chunksToStore []string
-		if len(chunk) > embeddingDim {
-			// Subdivide the chunk into smaller pieces without overlap
-			chunksToStore = splitter.ChunkText(chunk, embeddingDim, 0)
-			log.Println("🟠 Chunk exceeded embedding dimension, subdivided into", len(chunksToStore), "chunks")
-
-			// If we have a header and subdivided chunks, prepend the header to each sub-chunk
-			// (except the first one which already contains it)
-			if chunkHeader != "" && len(chunksToStore) > 1 {
-				for i := 1; i < len(chunksToStore); i++ {
-					chunksToStore[i] = chunkHeader + "\n\n" + chunksToStore[i]
-					log.Println("🟡", i+1, "Prepended header to sub-chunk")
-				}
-			}
-
-		} else {
-			chunksToStore = []string{chunk}
+		// If chunk is larger than the embedding model context window, subdivide it
+		// and prepend its first 2 non-empty lines to each sub-chunk (except the first one which already contains them)
+		chunksToStore := splitter.SubdivideWithHeader(chunk, splitter.ExtractFirstNonEmptyLines(chunk, 2), maxTokens)
+		if len(chunksToStore) > 1 {
+			helpers.Logf(ctx, "🟠 Chunk exceeded embedding model max tokens, subdivided into %d chunks", len(chunksToStore))
 		}
 
-		// Store each chunk
-		for _, chunkToStore := range chunksToStore {
-			// Create embedding from chunk text
-			embedding, err := store.CreateEmbeddingFromText(ctx, *openaiClient, chunkToStore, embeddingModelId)
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(models.SplitAndStoreWithDelimiterResponse{
-					Success: false,
-					Error:   fmt.Sprintf("Failed to create embedding for chunk: %v", err),
-				})
-				return
-			}
-
-			// Generate unique document ID for this chunk
-			chunkID := fmt.Sprintf("doc:%s", uuid.New().String())
-
-			// Store embedding in Redis with the same label and metadata for all chunks
-			err = store.StoreEmbedding(ctx, redisClient, chunkID, chunkToStore, embedding, req.Label, req.Metadata)
-			if err != nil {
-				w.WriteHeader(http.StatusInternalServerError)
-				json.NewEncoder(w).Encode(models.SplitAndStoreWithDelimiterResponse{
-					Success: false,
-					Error:   fmt.Sprintf("Failed to store chunk embedding: %v", err),
-				})
-				return
-			}
-
-			chunkIDs = append(chunkIDs, chunkID)
-		}
+		allChunks = append(allChunks, chunksToStore...)
+	}
+
+	// Expiration of the chunks
+	ttl, err := store.DocumentTTL(req.TTLSeconds)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SplitAndStoreWithDelimiterResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
 	}
 
-	// Success response
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(models.SplitAndStoreWithDelimiterResponse{
+	// Handling of the chunks already stored
+	if err := store.ValidateDedupMode(req.Dedup); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SplitAndStoreWithDelimiterResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// An atomic ingestion stores all the chunks or none
+	if err := store.ValidateAtomic(req.Atomic, req.ContinueOnError); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.SplitAndStoreWithDelimiterResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Resolve the collection of the chunks
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, req.Collection)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.SplitAndStoreWithDelimiterResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+	embeddingModelId = collection.ModelID(embeddingModelId)
+
+	chunkOptions := store.ChunkOptions{
+		Label:           req.Label,
+		Metadata:        req.Metadata,
+		IDStrategy:      req.IDStrategy,
+		SourceID:        req.SourceID,
+		ContinueOnError: req.ContinueOnError,
+		Rollback:        req.Rollback,
+		Atomic:          req.Atomic,
+		Original:        req.Document,
+		KeyPrefix:       collection.KeyPrefix,
+		TTL:             ttl,
+		Dedup:           req.Dedup,
+		IndexName:       collection.IndexName,
+	}
+
+	// Store the chunks in the background: the job reports the progress
+	if req.Async {
+		respondIngestionJob(w, store.StartIngestionJob(ctx, *openaiClient, redisClient, embeddingModelId, allChunks, chunkOptions))
+		return
+	}
+
+	// Store all chunks
+	createdAt := time.Now()
+	statuses, err := store.StoreChunks(ctx, *openaiClient, redisClient, embeddingModelId, allChunks, chunkOptions)
+	if err != nil && len(statuses) == 0 {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.SplitAndStoreWithDelimiterResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to store chunks: %v", err),
+		})
+		return
+	}
+
+	chunkIDs, chunksFailed := store.StoredChunkIDs(statuses)
+	response := models.SplitAndStoreWithDelimiterResponse{
+		SourceID:     store.OriginalSourceID(req.SourceID, req.Document),
 		ChunkIDs:     chunkIDs,
+		Chunks:       store.ChunkPreviews(allChunks, statuses, req.IncludeContent),
 		ChunksStored: len(chunkIDs),
+		ChunksFailed: chunksFailed,
 		CreatedAt:    createdAt,
-		Success:      true,
-	})
+		Success:      chunksFailed == 0 && err == nil,
+	}
+	if req.ContinueOnError || err != nil {
+		response.ChunkStatuses = statuses
+	}
+
+	// Success response (or partial success when some chunks failed in continue_on_error mode,
+	// or the chunks stored before the failure that aborted the ingestion)
+	httpStatus, errorMessage := chunkStoreOutcome(len(chunkIDs), chunksFailed, err)
+	response.Error = errorMessage
+	writeChunkStoreResponse(w, httpStatus, response.Chunks, response)
 }
@@ -0,0 +1,97 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// StatsHandler handles requests for the statistics of the store (documents of the index or of the collection query
+// parameter, Redis memory usage, embedding providers usage).
+// The response can be cached for a few seconds (see writeCacheableJSON).
+func StatsHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, redisClient *redis.Client, indexName string, memoryGuard *store.MemoryGuard) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.StatsResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET",
+		})
+		return
+	}
+
+	name := r.URL.Query().Get("collection")
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, name)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.StatsResponse{
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	indexStats, err := store.GetIndexStats(ctx, redisClient, collection.IndexName)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.StatsResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to get stats: %v", err),
+		})
+		return
+	}
+	indexStats.Collection = name
+
+	memoryInfo, err := store.GetMemoryInfo(ctx, redisClient)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.StatsResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to get stats: %v", err),
+		})
+		return
+	}
+
+	usage, err := store.GetUsageTotals(ctx)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.StatsResponse{
+			Success: false,
+			Error:   fmt.Sprintf("Failed to get stats: %v", err),
+		})
+		return
+	}
+
+	writeCacheableJSON(w, r, models.StatsResponse{
+		Index:      &indexStats,
+		Memory:     newMemoryStats(memoryInfo, memoryGuard),
+		Embeddings: store.GetEmbeddingStats(),
+		Usage:      usage,
+		Success:    true,
+	}, statsMaxAge)
+}
+
+// newMemoryStats returns the memory usage of Redis and the write watermark of the memory guard
+func newMemoryStats(memoryInfo store.MemoryInfo, memoryGuard *store.MemoryGuard) *models.MemoryStats {
+	memoryStats := &models.MemoryStats{
+		UsedMemory:      memoryInfo.UsedMemory,
+		UsedMemoryHuman: memoryInfo.UsedMemoryHuman,
+		MaxMemory:       memoryInfo.MaxMemory,
+		MaxMemoryPolicy: memoryInfo.MaxMemoryPolicy,
+		WriteWatermark:  memoryGuard.Watermark(memoryInfo.MaxMemory),
+		EvictionWarning: store.EvictionWarning(memoryInfo),
+	}
+	if memoryInfo.MaxMemory > 0 {
+		ratio := float64(memoryInfo.UsedMemory) / float64(memoryInfo.MaxMemory)
+		memoryStats.UsageRatio = &ratio
+	}
+	memoryStats.WritesRefused = memoryStats.WriteWatermark > 0 && memoryInfo.UsedMemory >= memoryStats.WriteWatermark
+	return memoryStats
+}
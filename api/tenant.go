@@ -0,0 +1,58 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TenantHeader is the request header selecting the tenant (and thus the index and the keys) of a request
+const TenantHeader = "X-Tenant"
+
+// TenantHandler is a handler served with the Redis client and the main index of the tenant of the request
+type TenantHandler func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, indexName string)
+
+// TenantContext returns a context carrying the tenant of a request (its X-Tenant header), e.g. for the MCP requests
+// (see store.ContextTenant)
+func TenantContext(ctx context.Context, r *http.Request) context.Context {
+	return store.WithTenant(ctx, r.Header.Get(TenantHeader))
+}
+
+// WithTenantRedisClient resolves the main index of the tenant of the request before calling the handler.
+// Requests for an unknown tenant, and requests for a document of another tenant (the {id} of a /documents/ path), are
+// rejected with 400 Bad Request.
+func WithTenantRedisClient(router *store.RedisRouter, handler TenantHandler) http.HandlerFunc {
+	return withTenant(router, router.DefaultClient, handler)
+}
+
+// WithTenantSearchClient resolves the main index of the tenant of the request and the Redis client of the searches
+// before calling the handler: a read replica when replicas are configured, else the primary (see
+// store.RedisRouter.SearchClient)
+func WithTenantSearchClient(router *store.RedisRouter, handler TenantHandler) http.HandlerFunc {
+	return withTenant(router, router.SearchClient, handler)
+}
+
+func withTenant(router *store.RedisRouter, client func() *redis.Client, handler TenantHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		indexName, err := router.IndexName(r.Header.Get(TenantHeader))
+		if err == nil {
+			if id := r.PathValue("id"); id != "" && strings.HasPrefix(r.URL.Path, "/documents/") {
+				err = store.ValidateTenantDocumentID(indexName, id)
+			}
+		}
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"success": false,
+				"error":   err.Error(),
+			})
+			return
+		}
+		handler(w, r, client(), indexName)
+	}
+}
@@ -0,0 +1,162 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// DocumentHandler dispatches the requests on a stored document (/documents/{id}) according to their method
+func DocumentHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId string) {
+	switch r.Method {
+	case http.MethodGet:
+		GetDocumentHandler(w, r, ctx, redisClient)
+	case http.MethodPut:
+		UpdateDocumentHandler(w, r, ctx, openaiClient, redisClient, embeddingModelId)
+	case http.MethodDelete:
+		DeleteDocumentHandler(w, r, ctx, redisClient)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed. Use GET, PUT or DELETE",
+		})
+	}
+}
+
+// UpdateDocumentHandler handles requests to replace the content of a stored document and regenerate its embedding (PUT /documents/{id})
+func UpdateDocumentHandler(w http.ResponseWriter, r *http.Request, ctx context.Context, openaiClient *openai.Client, redisClient *redis.Client, embeddingModelId string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept PUT requests
+	if r.Method != http.MethodPut {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.UpdateDocumentResponse{
+			Success: false,
+			Error:   "Method not allowed. Use PUT",
+		})
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := store.ValidateDocumentID(id); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.UpdateDocumentResponse{
+			ID:      id,
+			Success: false,
+			Error:   err.Error(),
+		})
+		return
+	}
+
+	// Parse request body (JSON, or the content as a text/plain or text/markdown body)
+	var req models.UpdateDocumentRequest
+	if err := decodeRequestBody(r, "content", &req); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.UpdateDocumentResponse{
+			ID:      id,
+			Success: false,
+			Error:   fmt.Sprintf("Invalid request body: %v", err),
+		})
+		return
+	}
+
+	// Validate required fields
+	if req.Content == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(models.UpdateDocumentResponse{
+			ID:      id,
+			Success: false,
+			Error:   "Content is required",
+		})
+		return
+	}
+
+	// Avoid creating an embedding for a document that does not exist
+	exists, err := store.DocumentExists(ctx, redisClient, id)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.UpdateDocumentResponse{
+			ID:      id,
+			Success: false,
+			Error:   fmt.Sprintf("Failed to update document: %v", err),
+		})
+		return
+	}
+	if !exists {
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.UpdateDocumentResponse{
+			ID:      id,
+			Success: false,
+			Error:   "Document not found",
+		})
+		return
+	}
+
+	// Create embedding from the new content, with the embedding model of the collection of the document
+	embeddingModelId, err = store.DocumentModelID(ctx, redisClient, id, embeddingModelId)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.UpdateDocumentResponse{
+			ID:      id,
+			Success: false,
+			Error:   fmt.Sprintf("Failed to update document: %v", err),
+		})
+		return
+	}
+	embedding, err := store.CreateEmbeddingFromText(ctx, *openaiClient, req.Content, embeddingModelId)
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.UpdateDocumentResponse{
+			ID:      id,
+			Success: false,
+			Error:   fmt.Sprintf("Failed to create embedding: %v", err),
+		})
+		return
+	}
+
+	doc, err := store.UpdateDocument(ctx, redisClient, id, store.DocumentUpdate{
+		Content:   req.Content,
+		Embedding: embedding,
+		Label:     req.Label,
+		Metadata:  req.Metadata,
+	})
+	if errors.Is(err, store.ErrDocumentNotFound) {
+		// The document was deleted while the embedding was created
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(models.UpdateDocumentResponse{
+			ID:      id,
+			Success: false,
+			Error:   "Document not found",
+		})
+		return
+	}
+	if err != nil {
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(models.UpdateDocumentResponse{
+			ID:      id,
+			Success: false,
+			Error:   fmt.Sprintf("Failed to update document: %v", err),
+		})
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.UpdateDocumentResponse{
+		ID:        doc.ID,
+		Content:   doc.Content,
+		Label:     doc.Label,
+		Metadata:  doc.Metadata,
+		UpdatedAt: time.Now(),
+		Success:   true,
+	})
+}
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"vectormind/helpers"
+	"vectormind/store"
+)
+
+// UsageContext returns a context charging the embedding requests of a request to its tenant and API key
+// (the handlers add the label of the documents or of the search), carrying its request ID (see WithRequestID) and
+// recording its writes for the quotas (see WithQuotaWarnings)
+func UsageContext(ctx context.Context, r *http.Request) context.Context {
+	if id := helpers.RequestID(r.Context()); id != "" {
+		ctx = helpers.WithRequestID(ctx, id)
+	}
+	ctx = store.InheritWriteTracking(ctx, r.Context())
+	return store.WithUsageAttribution(ctx, store.UsageAttribution{
+		Tenant: r.Header.Get(TenantHeader),
+		APIKey: store.APIKeyFingerprint(requestAPIKey(r)),
+	})
+}
+
+// UsageCSVHandler handles requests for the export of the usage of the embedding providers as CSV (GET /stats/usage.csv),
+// one line by tenant, API key, label and model
+func UsageCSVHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   "Method not allowed. Use GET",
+		})
+		return
+	}
+
+	totals, err := store.GetUsageTotals(r.Context())
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(errorStatus(err))
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="usage.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"tenant", "api_key", "label", "model", "requests", "inputs", "embedding_tokens"})
+	for _, total := range totals {
+		writer.Write([]string{
+			total.Tenant,
+			total.APIKey,
+			total.Label,
+			total.Model,
+			strconv.FormatInt(total.Requests, 10),
+			strconv.FormatInt(total.Inputs, 10),
+			strconv.FormatInt(total.EmbeddingTokens, 10),
+		})
+	}
+	writer.Flush()
+}
@@ -0,0 +1,35 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"runtime"
+	"vectormind/helpers"
+	"vectormind/models"
+)
+
+// VersionHandler handles requests for the build information of the server (GET /version): version, commit hash and
+// build date, to know which version a client talks to when several versions run side by side
+func VersionHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		json.NewEncoder(w).Encode(models.VersionResponse{
+			Success: false,
+			Error:   "Method not allowed. Use GET",
+		})
+		return
+	}
+
+	version, commit, buildDate := helpers.BuildInfo()
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.VersionResponse{
+		Version:   version,
+		Commit:    commit,
+		BuildDate: buildDate,
+		GoVersion: runtime.Version(),
+		Success:   true,
+	})
+}
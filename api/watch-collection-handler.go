@@ -0,0 +1,170 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// defaultWatchTimeout is the default time a watch request waits for a change
+	defaultWatchTimeout = 25 * time.Second
+	// maxWatchTimeout is the longest time a watch request can wait for a change
+	maxWatchTimeout = 60 * time.Second
+	// defaultWatchLimit is the default number of changes returned by a watch request
+	defaultWatchLimit = 100
+	// maxWatchLimit is the highest number of changes returned by a watch request
+	maxWatchLimit = 1000
+	// sseKeepAliveInterval is the interval of the comments sent on an idle event stream, so that proxies keep it open
+	sseKeepAliveInterval = 15 * time.Second
+)
+
+// WatchCollectionHandler handles requests for the changes of the documents of a collection (GET /collections/{name}/watch).
+// The request waits up to timeout seconds for a change following the cursor (long-poll), and returns the changes
+// with the cursor of the next request. With an "Accept: text/event-stream" header, the changes are streamed as
+// Server-Sent Events until the client disconnects.
+func WatchCollectionHandler(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, indexName string) {
+	w.Header().Set("Content-Type", "application/json")
+
+	writeError := func(status int, message string) {
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(models.WatchCollectionResponse{
+			Collection: r.PathValue("name"),
+			Success:    false,
+			Error:      message,
+		})
+	}
+
+	// Only accept GET requests
+	if r.Method != http.MethodGet {
+		writeError(http.StatusMethodNotAllowed, "Method not allowed. Use GET")
+		return
+	}
+
+	name := r.PathValue("name")
+	if err := store.ValidateCollectionName(name); err != nil {
+		writeError(http.StatusBadRequest, err.Error())
+		return
+	}
+
+	query := r.URL.Query()
+	timeout := defaultWatchTimeout
+	if value := query.Get("timeout"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 || time.Duration(seconds)*time.Second > maxWatchTimeout {
+			writeError(http.StatusBadRequest, fmt.Sprintf("timeout must be a number of seconds between 0 and %d", int(maxWatchTimeout.Seconds())))
+			return
+		}
+		timeout = time.Duration(seconds) * time.Second
+	}
+	limit := defaultWatchLimit
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 || parsed > maxWatchLimit {
+			writeError(http.StatusBadRequest, fmt.Sprintf("limit must be between 1 and %d", maxWatchLimit))
+			return
+		}
+		limit = parsed
+	}
+	// A reconnected event stream resumes from the last received change
+	cursor := query.Get("cursor")
+	if lastEventID := r.Header.Get("Last-Event-ID"); cursor == "" && lastEventID != "" {
+		cursor = lastEventID
+	}
+	if cursor != "" {
+		if err := store.ValidateChangeCursor(cursor); err != nil {
+			writeError(http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
+	if !store.ChangeFeedEnabled() {
+		writeError(http.StatusNotFound, "The change feed is disabled (CHANGE_FEED_LENGTH is 0)")
+		return
+	}
+
+	// The watch ends when the client disconnects
+	ctx := r.Context()
+	collection, err := store.ResolveCollection(ctx, redisClient, indexName, name)
+	if err != nil {
+		writeError(errorStatus(err), err.Error())
+		return
+	}
+
+	if strings.Contains(r.Header.Get("Accept"), "text/event-stream") {
+		streamCollectionChanges(w, r, redisClient, collection, cursor, limit)
+		return
+	}
+
+	batch, err := store.WaitForChanges(ctx, redisClient, collection, cursor, limit, timeout)
+	if err != nil {
+		if ctx.Err() != nil {
+			return // client gone
+		}
+		writeError(http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(models.WatchCollectionResponse{
+		Collection: name,
+		Changes:    batch.Changes,
+		Cursor:     batch.Cursor,
+		Truncated:  batch.Truncated,
+		Success:    true,
+	})
+}
+
+// streamCollectionChanges streams the changes of a collection as Server-Sent Events: one event by change (the event ID
+// is the cursor of the change), a "truncated" event when changes may have been dropped, and keep-alive comments
+func streamCollectionChanges(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, collection store.Collection, cursor string, limit int) {
+	ctx := r.Context()
+	controller := http.NewResponseController(w)
+
+	// The changes already recorded are read before the stream starts, so that a failure is still a JSON response
+	batch, err := store.WaitForChanges(ctx, redisClient, collection, cursor, limit, 0)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(models.WatchCollectionResponse{
+			Collection: collection.Name,
+			Success:    false,
+			Error:      err.Error(),
+		})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	for {
+		if batch.Truncated {
+			fmt.Fprintf(w, "event: truncated\ndata: {\"cursor\":%q}\n\n", batch.Cursor)
+		}
+		for _, change := range batch.Changes {
+			data, _ := json.Marshal(change)
+			fmt.Fprintf(w, "id: %s\ndata: %s\n\n", change.Cursor, data)
+		}
+		if len(batch.Changes) == 0 && !batch.Truncated {
+			fmt.Fprint(w, ": keep-alive\n\n")
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+
+		batch, err = store.WaitForChanges(ctx, redisClient, collection, batch.Cursor, limit, sseKeepAliveInterval)
+		if err != nil {
+			if ctx.Err() == nil {
+				fmt.Fprintf(w, "event: error\ndata: %q\n\n", err.Error())
+				controller.Flush()
+			}
+			return
+		}
+	}
+}
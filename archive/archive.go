@@ -0,0 +1,99 @@
+// Package archive stores the original (pre-chunk) documents outside of Redis,
+// on local disk or in an S3 compatible object storage (AWS S3, MinIO), or in a backend added with Register.
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// ErrNotFound is returned when an original document is not archived
+var ErrNotFound = errors.New("original document not found")
+
+// Store archives the original documents by source ID
+type Store interface {
+	// Put archives an original document and returns its reference (e.g. s3://bucket/originals/id)
+	Put(ctx context.Context, sourceID string, content []byte) (string, error)
+	// Get returns an archived original document, or ErrNotFound
+	Get(ctx context.Context, sourceID string) ([]byte, error)
+}
+
+// Config holds the archive settings
+type Config struct {
+	Backend     string // "local", "s3" or a registered backend ("" disables the archive)
+	Dir         string // local backend directory
+	S3Endpoint  string
+	S3Bucket    string
+	S3AccessKey string
+	S3SecretKey string
+	S3UseSSL    bool
+	S3Prefix    string // key prefix of the archived objects
+}
+
+// Factory creates the archive store of a backend from the archive settings
+type Factory func(ctx context.Context, config Config) (Store, error)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]Factory)
+)
+
+// Register makes an archive backend available by name to New (ARCHIVE_BACKEND), e.g. from the init function of a
+// package imported by a fork. It panics if the name is empty, the factory is nil or the name is already registered.
+func Register(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if name == "" {
+		panic("archive: Register with an empty name")
+	}
+	if factory == nil {
+		panic("archive: Register with a nil factory for " + name)
+	}
+	if _, exists := registry[name]; exists {
+		panic("archive: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// Backends returns the sorted names of the registered archive backends
+func Backends() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// New creates the archive store of the configured backend (nil when the archive is disabled)
+func New(ctx context.Context, config Config) (Store, error) {
+	if config.Backend == "" {
+		return nil, nil
+	}
+	registryMutex.RLock()
+	factory, ok := registry[config.Backend]
+	registryMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown archive backend %q (use %s)", config.Backend, strings.Join(Backends(), ", "))
+	}
+	return factory(ctx, config)
+}
+
+func init() {
+	Register("local", func(ctx context.Context, config Config) (Store, error) { return NewLocalStore(config.Dir) })
+	Register("s3", func(ctx context.Context, config Config) (Store, error) { return NewS3Store(ctx, config) })
+}
+
+// objectKey returns the archive key of a source ID, escaped so that it never designates a path outside the archive
+func objectKey(sourceID string) (string, error) {
+	key := url.PathEscape(sourceID)
+	if key == "" || key == "." || key == ".." {
+		return "", fmt.Errorf("invalid source id %q", sourceID)
+	}
+	return key, nil
+}
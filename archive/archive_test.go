@@ -0,0 +1,123 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	store, err := NewLocalStore(dir)
+	if err != nil {
+		t.Fatalf("Failed to create local store: %v", err)
+	}
+
+	ref, err := store.Put(ctx, "readme", []byte("# Original document"))
+	if err != nil {
+		t.Fatalf("Failed to archive document: %v", err)
+	}
+	if ref != "file://"+filepath.Join(dir, "readme") {
+		t.Errorf("Unexpected reference: %s", ref)
+	}
+
+	content, err := store.Get(ctx, "readme")
+	if err != nil || string(content) != "# Original document" {
+		t.Errorf("Expected the archived document, got %q (err=%v)", content, err)
+	}
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestLocalStore_SourceIDEscaping(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, _ := NewLocalStore(dir)
+
+	// Source IDs with path separators stay inside the archive directory
+	ref, err := store.Put(ctx, "../docs/readme.md", []byte("content"))
+	if err != nil {
+		t.Fatalf("Failed to archive document: %v", err)
+	}
+	if !strings.HasPrefix(ref, "file://"+dir+string(os.PathSeparator)) {
+		t.Errorf("Expected the document to be archived in %s, got %s", dir, ref)
+	}
+	if content, err := store.Get(ctx, "../docs/readme.md"); err != nil || string(content) != "content" {
+		t.Errorf("Expected the archived document, got %q (err=%v)", content, err)
+	}
+
+	for _, sourceID := range []string{"", ".", ".."} {
+		if _, err := store.Put(ctx, sourceID, []byte("content")); err == nil {
+			t.Errorf("Expected an error for source id %q", sourceID)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	ctx := context.Background()
+
+	store, err := New(ctx, Config{})
+	if err != nil || store != nil {
+		t.Errorf("Expected no archive without backend, got %v (err=%v)", store, err)
+	}
+
+	if _, err := New(ctx, Config{Backend: "ftp"}); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+
+	if _, err := New(ctx, Config{Backend: "s3"}); err == nil {
+		t.Error("Expected an error for an S3 backend without endpoint")
+	}
+}
+
+// memoryStore archives the original documents in memory
+type memoryStore map[string][]byte
+
+func (s memoryStore) Put(ctx context.Context, sourceID string, content []byte) (string, error) {
+	s[sourceID] = content
+	return "memory://" + sourceID, nil
+}
+
+func (s memoryStore) Get(ctx context.Context, sourceID string) ([]byte, error) {
+	content, ok := s[sourceID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return content, nil
+}
+
+func TestRegister(t *testing.T) {
+	ctx := context.Background()
+	Register("test_memory", func(ctx context.Context, config Config) (Store, error) { return memoryStore{}, nil })
+	defer func() {
+		registryMutex.Lock()
+		delete(registry, "test_memory")
+		registryMutex.Unlock()
+	}()
+
+	store, err := New(ctx, Config{Backend: "test_memory"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if ref, err := store.Put(ctx, "notes.md", []byte("# Notes")); err != nil || ref != "memory://notes.md" {
+		t.Errorf("Expected the reference of the registered backend, got %q (%v)", ref, err)
+	}
+	if backends := Backends(); !slices.Equal(backends, []string{"local", "s3", "test_memory"}) {
+		t.Errorf("Expected the built-in and registered backends, got %v", backends)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for a backend registered twice")
+		}
+	}()
+	Register("local", func(ctx context.Context, config Config) (Store, error) { return nil, nil })
+}
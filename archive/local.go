@@ -0,0 +1,62 @@
+package archive
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// LocalStore archives the original documents as files of a local directory
+type LocalStore struct {
+	dir string
+}
+
+// NewLocalStore creates a local archive store, creating its directory if needed
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("archive directory is required")
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	return &LocalStore{dir: dir}, nil
+}
+
+// Put writes an original document to the archive directory
+func (s *LocalStore) Put(ctx context.Context, sourceID string, content []byte) (string, error) {
+	key, err := objectKey(sourceID)
+	if err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(s.dir, key)
+	// Write to a temporary file first so that readers never see a partial document
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, content, 0o644); err != nil {
+		return "", fmt.Errorf("failed to archive original document: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", fmt.Errorf("failed to archive original document: %w", err)
+	}
+	return "file://" + path, nil
+}
+
+// Get reads an original document from the archive directory
+func (s *LocalStore) Get(ctx context.Context, sourceID string) ([]byte, error) {
+	key, err := objectKey(sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := os.ReadFile(filepath.Join(s.dir, key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original document: %w", err)
+	}
+	return content, nil
+}
@@ -0,0 +1,85 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3Store archives the original documents in an S3 compatible bucket (AWS S3, MinIO)
+type S3Store struct {
+	client *minio.Client
+	bucket string
+	prefix string
+}
+
+// NewS3Store creates an S3 archive store, creating the bucket if needed
+func NewS3Store(ctx context.Context, config Config) (*S3Store, error) {
+	if config.S3Endpoint == "" || config.S3Bucket == "" {
+		return nil, fmt.Errorf("archive S3 endpoint and bucket are required")
+	}
+
+	client, err := minio.New(config.S3Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(config.S3AccessKey, config.S3SecretKey, ""),
+		Secure: config.S3UseSSL,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create S3 client: %w", err)
+	}
+
+	exists, err := client.BucketExists(ctx, config.S3Bucket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check archive bucket: %w", err)
+	}
+	if !exists {
+		if err := client.MakeBucket(ctx, config.S3Bucket, minio.MakeBucketOptions{}); err != nil {
+			return nil, fmt.Errorf("failed to create archive bucket: %w", err)
+		}
+	}
+
+	return &S3Store{client: client, bucket: config.S3Bucket, prefix: config.S3Prefix}, nil
+}
+
+// Put uploads an original document to the bucket
+func (s *S3Store) Put(ctx context.Context, sourceID string, content []byte) (string, error) {
+	key, err := objectKey(sourceID)
+	if err != nil {
+		return "", err
+	}
+
+	key = s.prefix + key
+	_, err = s.client.PutObject(ctx, s.bucket, key, bytes.NewReader(content), int64(len(content)), minio.PutObjectOptions{
+		ContentType: "text/plain; charset=utf-8",
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to archive original document: %w", err)
+	}
+	return fmt.Sprintf("s3://%s/%s", s.bucket, key), nil
+}
+
+// Get downloads an original document from the bucket
+func (s *S3Store) Get(ctx context.Context, sourceID string) ([]byte, error) {
+	key, err := objectKey(sourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := s.client.GetObject(ctx, s.bucket, s.prefix+key, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original document: %w", err)
+	}
+	defer object.Close()
+
+	content, err := io.ReadAll(object)
+	if err != nil {
+		if minio.ToErrorResponse(err).Code == minio.NoSuchKey {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("failed to read original document: %w", err)
+	}
+	return content, nil
+}
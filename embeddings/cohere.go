@@ -0,0 +1,88 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+)
+
+// DefaultCohereBaseURL is the address of the Cohere API
+const DefaultCohereBaseURL = "https://api.cohere.com"
+
+// cohereMaxTexts is the maximum number of texts of a Cohere embed request
+const cohereMaxTexts = 96
+
+// Cohere input types: the documents and the search queries are embedded differently
+const (
+	cohereInputDocument = "search_document"
+	cohereInputQuery    = "search_query"
+)
+
+// CohereEmbedder creates the embeddings with the Cohere embed API (POST /v1/embed)
+type CohereEmbedder struct {
+	config Config
+}
+
+// NewCohereEmbedder creates an embedder for the Cohere API (the API key is required)
+func NewCohereEmbedder(config Config) *CohereEmbedder {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultCohereBaseURL
+	}
+	return &CohereEmbedder{config: config}
+}
+
+// cohereRequest is the body of a Cohere embed request
+type cohereRequest struct {
+	Model     string   `json:"model"`
+	Texts     []string `json:"texts"`
+	InputType string   `json:"input_type"`
+}
+
+// cohereResponse is the body of a Cohere embed response
+type cohereResponse struct {
+	Embeddings [][]float64 `json:"embeddings"`
+	Meta       struct {
+		BilledUnits struct {
+			InputTokens int64 `json:"input_tokens"`
+		} `json:"billed_units"`
+	} `json:"meta"`
+}
+
+// Embed creates the embedding vectors of several texts
+func (embedder *CohereEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, _, err := embedder.EmbedWithTokens(ctx, texts)
+	return vectors, err
+}
+
+// EmbedWithTokens creates the embedding vectors of several texts (with a request per 96 texts),
+// and returns the number of input tokens billed by Cohere
+func (embedder *CohereEmbedder) EmbedWithTokens(ctx context.Context, texts []string) ([][]float32, int64, error) {
+	inputType := cohereInputDocument
+	if IsQuery(ctx) {
+		inputType = cohereInputQuery
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	var tokens int64
+	for start := 0; start < len(texts); start += cohereMaxTexts {
+		batch := texts[start:min(start+cohereMaxTexts, len(texts))]
+		var response cohereResponse
+		if err := postJSON(ctx, embedder.config, "/v1/embed", cohereRequest{Model: embedder.config.Model, Texts: batch, InputType: inputType}, &response); err != nil {
+			return nil, 0, err
+		}
+		if len(response.Embeddings) != len(batch) {
+			return nil, 0, fmt.Errorf("%w: expected %d embeddings, got %d", ErrInvalidResponse, len(batch), len(response.Embeddings))
+		}
+		for _, embedding := range response.Embeddings {
+			vector := make([]float32, len(embedding))
+			for i, f := range embedding {
+				vector[i] = float32(f)
+			}
+			vectors = append(vectors, vector)
+		}
+		tokens += response.Meta.BilledUnits.InputTokens
+	}
+	if err := ValidateVectors(vectors, texts); err != nil {
+		return nil, 0, err
+	}
+	return vectors, tokens, nil
+}
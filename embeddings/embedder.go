@@ -0,0 +1,145 @@
+// Package embeddings provides the embedding providers: the OpenAI compatible runners, Ollama's native API, Cohere
+// and local ONNX models. Other providers are added with Register.
+package embeddings
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Embedding providers
+const (
+	// ProviderOpenAI is an OpenAI compatible API (Docker Model Runner, llama.cpp, vLLM, OpenAI, Azure OpenAI...)
+	ProviderOpenAI = "openai"
+	// ProviderOllama is the native API of Ollama (/api/embeddings)
+	ProviderOllama = "ollama"
+	// ProviderCohere is the Cohere embed API (/v1/embed)
+	ProviderCohere = "cohere"
+	// ProviderLocal is an ONNX model run in-process (requires the onnx build tag)
+	ProviderLocal = "local"
+)
+
+// ErrRequestFailed is returned when the embedding provider cannot be reached or answers with an error
+var ErrRequestFailed = errors.New("embedding request failed")
+
+// ErrInvalidResponse is returned when the embedding provider answers without the expected vectors
+var ErrInvalidResponse = errors.New("invalid embedding response")
+
+// Embedder creates the embedding vectors of texts, returned in the same order as the texts
+type Embedder interface {
+	Embed(ctx context.Context, texts []string) ([][]float32, error)
+}
+
+// TokenCounter is implemented by the embedders whose provider reports the number of input tokens of a request
+type TokenCounter interface {
+	EmbedWithTokens(ctx context.Context, texts []string) ([][]float32, int64, error)
+}
+
+// Config holds the settings of an embedding provider
+type Config struct {
+	BaseURL    string
+	APIKey     string
+	Model      string
+	Headers    map[string]string // extra HTTP headers (e.g. the organization of a hosted provider)
+	HTTPClient *http.Client      // default: http.DefaultClient
+
+	// Local models
+	ModelPath   string // ONNX model
+	VocabPath   string // vocab.txt of the model (default: next to the model)
+	LibraryPath string // onnxruntime shared library (default: the one of the system)
+	Threads     int    // threads of an inference (default: chosen by onnxruntime)
+}
+
+// Factory creates the embedder of a provider from its settings
+type Factory func(config Config) (Embedder, error)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]Factory)
+)
+
+// Register makes an embedding provider available by name to New (EMBEDDING_PROVIDER), e.g. from the init function
+// of a package imported by a fork. It panics if the name is empty, the factory is nil or the name is already registered.
+func Register(name string, factory Factory) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	name = strings.ToLower(name)
+	if name == "" {
+		panic("embeddings: Register with an empty name")
+	}
+	if factory == nil {
+		panic("embeddings: Register with a nil factory for " + name)
+	}
+	if _, exists := registry[name]; exists {
+		panic("embeddings: Register called twice for " + name)
+	}
+	registry[name] = factory
+}
+
+// Providers returns the sorted names of the registered embedding providers
+func Providers() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	return slices.Sorted(maps.Keys(registry))
+}
+
+// New creates the embedder of a registered provider ("openai", "ollama", "cohere", "local" or a provider added
+// with Register)
+func New(provider string, config Config) (Embedder, error) {
+	registryMutex.RLock()
+	factory, ok := registry[strings.ToLower(provider)]
+	registryMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown embedding provider %q (use %s)", provider, strings.Join(Providers(), ", "))
+	}
+	return factory(config)
+}
+
+func init() {
+	Register(ProviderOpenAI, func(config Config) (Embedder, error) { return NewOpenAIEmbedderFromConfig(config), nil })
+	Register(ProviderOllama, func(config Config) (Embedder, error) { return NewOllamaEmbedder(config), nil })
+	Register(ProviderCohere, func(config Config) (Embedder, error) { return NewCohereEmbedder(config), nil })
+	Register(ProviderLocal, func(config Config) (Embedder, error) {
+		embedder, err := NewLocalEmbedder(config)
+		if err != nil {
+			return nil, err
+		}
+		return embedder, nil
+	})
+}
+
+// ValidateVectors checks that a provider returned one non-empty vector per text
+func ValidateVectors(vectors [][]float32, texts []string) error {
+	if len(vectors) != len(texts) {
+		return fmt.Errorf("%w: expected %d embeddings, got %d", ErrInvalidResponse, len(texts), len(vectors))
+	}
+	for i, vector := range vectors {
+		if len(vector) == 0 {
+			return fmt.Errorf("%w: empty embedding vector at index %d", ErrInvalidResponse, i)
+		}
+	}
+	return nil
+}
+
+// queryKey is the context key marking the embeddings of search queries
+type queryKey struct{}
+
+// WithQuery marks the embeddings created with the context as search queries: the providers distinguishing
+// queries from documents (Cohere) embed them as queries
+func WithQuery(ctx context.Context) context.Context {
+	return context.WithValue(ctx, queryKey{}, true)
+}
+
+// IsQuery reports whether the embeddings created with the context are search queries (see WithQuery)
+func IsQuery(ctx context.Context) bool {
+	query, _ := ctx.Value(queryKey{}).(bool)
+	return query
+}
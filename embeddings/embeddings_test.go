@@ -0,0 +1,262 @@
+package embeddings
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+// newProviderServer starts a fake embedding provider answering each request with the response of handle
+func newProviderServer(t *testing.T, path string, handle func(body map[string]any) (int, any)) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != path {
+			http.Error(w, "not found", http.StatusNotFound)
+			return
+		}
+		var body map[string]any
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body["authorization"] = r.Header.Get("Authorization")
+		status, response := handle(body)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(response)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestNew(t *testing.T) {
+	for _, provider := range []string{"openai", "Ollama", "cohere"} {
+		if _, err := New(provider, Config{BaseURL: "http://localhost", Model: "model"}); err != nil {
+			t.Errorf("New(%q): unexpected error: %v", provider, err)
+		}
+	}
+	if _, err := New("bedrock", Config{}); err == nil {
+		t.Error("Expected an error for an unknown provider")
+	}
+}
+
+// fixedEmbedder returns the same vector for each text
+type fixedEmbedder struct{ vector []float32 }
+
+func (embedder fixedEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, len(texts))
+	for i := range texts {
+		vectors[i] = embedder.vector
+	}
+	return vectors, nil
+}
+
+func TestRegister(t *testing.T) {
+	Register("test_fixed", func(config Config) (Embedder, error) { return fixedEmbedder{vector: []float32{1, 2}}, nil })
+	defer func() {
+		registryMutex.Lock()
+		delete(registry, "test_fixed")
+		registryMutex.Unlock()
+	}()
+
+	embedder, err := New("Test_Fixed", Config{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if vectors, err := embedder.Embed(context.Background(), []string{"a", "b"}); err != nil || len(vectors) != 2 || vectors[1][1] != 2 {
+		t.Errorf("Expected the vectors of the registered provider, got %v (%v)", vectors, err)
+	}
+	if providers := Providers(); !slices.Contains(providers, "test_fixed") || !slices.Contains(providers, ProviderOllama) {
+		t.Errorf("Expected the built-in and registered providers, got %v", providers)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected a panic for a provider registered twice")
+		}
+	}()
+	Register(ProviderCohere, func(config Config) (Embedder, error) { return nil, nil })
+}
+
+func TestOpenAIEmbedder(t *testing.T) {
+	server := newProviderServer(t, "/embeddings", func(body map[string]any) (int, any) {
+		// The vectors are returned out of order, they are sorted by index
+		return http.StatusOK, map[string]any{
+			"object": "list",
+			"model":  body["model"],
+			"data": []map[string]any{
+				{"object": "embedding", "index": 1, "embedding": []float64{0.3, 0.4}},
+				{"object": "embedding", "index": 0, "embedding": []float64{0.1, 0.2}},
+			},
+			"usage": map[string]any{"prompt_tokens": 7, "total_tokens": 7},
+		}
+	})
+
+	embedder := NewOpenAIEmbedderFromConfig(Config{BaseURL: server.URL, APIKey: "secret", Model: "model"})
+	vectors, tokens, err := embedder.EmbedWithTokens(context.Background(), []string{"first", "second"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(vectors, [][]float32{{0.1, 0.2}, {0.3, 0.4}}) || tokens != 7 {
+		t.Errorf("Unexpected vectors %v and tokens %d", vectors, tokens)
+	}
+
+	// A response without one vector per text is invalid
+	if _, err := embedder.Embed(context.Background(), []string{"only one"}); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("Expected ErrInvalidResponse, got %v", err)
+	}
+}
+
+func TestOllamaEmbedder(t *testing.T) {
+	prompts := []string{}
+	server := newProviderServer(t, "/api/embeddings", func(body map[string]any) (int, any) {
+		prompt, _ := body["prompt"].(string)
+		if body["model"] != "nomic-embed-text" || prompt == "" {
+			return http.StatusBadRequest, map[string]any{"error": "invalid request"}
+		}
+		if prompt == "fail" {
+			return http.StatusInternalServerError, map[string]any{"error": "model not loaded"}
+		}
+		prompts = append(prompts, prompt)
+		return http.StatusOK, map[string]any{"embedding": []float64{float64(len(prompt)), 1}}
+	})
+
+	embedder := NewOllamaEmbedder(Config{BaseURL: server.URL + "/", Model: "nomic-embed-text"})
+	vectors, err := embedder.Embed(context.Background(), []string{"a", "abc"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(vectors, [][]float32{{1, 1}, {3, 1}}) || !reflect.DeepEqual(prompts, []string{"a", "abc"}) {
+		t.Errorf("Unexpected vectors %v (prompts %v)", vectors, prompts)
+	}
+
+	if _, err := embedder.Embed(context.Background(), []string{"fail"}); !errors.Is(err, ErrRequestFailed) {
+		t.Errorf("Expected ErrRequestFailed, got %v", err)
+	}
+}
+
+func TestCohereEmbedder(t *testing.T) {
+	requests := []map[string]any{}
+	server := newProviderServer(t, "/v1/embed", func(body map[string]any) (int, any) {
+		if body["authorization"] != "Bearer secret" {
+			return http.StatusUnauthorized, map[string]any{"message": "invalid api token"}
+		}
+		requests = append(requests, body)
+		texts, _ := body["texts"].([]any)
+		embeddings := [][]float64{}
+		for i := range texts {
+			embeddings = append(embeddings, []float64{float64(i), 0.5})
+		}
+		return http.StatusOK, map[string]any{
+			"embeddings": embeddings,
+			"meta":       map[string]any{"billed_units": map[string]any{"input_tokens": len(texts)}},
+		}
+	})
+
+	embedder := NewCohereEmbedder(Config{BaseURL: server.URL, APIKey: "secret", Model: "embed-english-v3.0"})
+
+	// More texts than the limit of a request: the texts are sent in batches
+	texts := make([]string, cohereMaxTexts+4)
+	for i := range texts {
+		texts[i] = "chunk"
+	}
+	vectors, tokens, err := embedder.EmbedWithTokens(context.Background(), texts)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(vectors) != len(texts) || tokens != int64(len(texts)) || len(requests) != 2 {
+		t.Fatalf("Expected %d vectors in 2 requests, got %d vectors, %d tokens, %d requests", len(texts), len(vectors), tokens, len(requests))
+	}
+	if requests[0]["input_type"] != "search_document" || requests[0]["model"] != "embed-english-v3.0" {
+		t.Errorf("Unexpected document request: %v", requests[0])
+	}
+
+	// The search queries are embedded as queries
+	if _, err := embedder.Embed(WithQuery(context.Background()), []string{"query"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if requests[2]["input_type"] != "search_query" {
+		t.Errorf("Expected a search_query input type, got %v", requests[2]["input_type"])
+	}
+
+	unauthorized := NewCohereEmbedder(Config{BaseURL: server.URL, Model: "embed-english-v3.0"})
+	if _, err := unauthorized.Embed(context.Background(), []string{"chunk"}); !errors.Is(err, ErrRequestFailed) {
+		t.Errorf("Expected ErrRequestFailed, got %v", err)
+	}
+}
+
+func TestValidateVectors(t *testing.T) {
+	if err := ValidateVectors([][]float32{{1}}, []string{"a", "b"}); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("Expected ErrInvalidResponse for a missing vector, got %v", err)
+	}
+	if err := ValidateVectors([][]float32{{1}, {}}, []string{"a", "b"}); !errors.Is(err, ErrInvalidResponse) {
+		t.Errorf("Expected ErrInvalidResponse for an empty vector, got %v", err)
+	}
+	if err := ValidateVectors([][]float32{{1}, {2}}, []string{"a", "b"}); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
+
+func TestWordPieceTokenizer(t *testing.T) {
+	vocab := map[string]int64{"[PAD]": 0, "[UNK]": 1, "[CLS]": 2, "[SEP]": 3, "hello": 4, ",": 5, "world": 6, "!": 7, "embed": 8, "##ding": 9, "##s": 10, "cafe": 11, "中": 12}
+	tokenizer, err := NewWordPieceTokenizer(vocab, 16)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := map[string][]int64{
+		"Hello, World!":         {2, 4, 5, 6, 7, 3},
+		"Embeddings\tcafé":      {2, 8, 9, 10, 11, 3}, // word pieces, lowercase without accents
+		"hello unknownword 中":   {2, 4, 1, 12, 3},
+		"":                      {2, 3},
+		"hello hello hello ...": {2, 4, 4, 4, 1, 1, 1, 3}, // "." is not in the vocabulary
+	}
+	for text, expected := range tests {
+		if ids := tokenizer.Encode(text); !reflect.DeepEqual(ids, expected) {
+			t.Errorf("Encode(%q) = %v, expected %v", text, ids, expected)
+		}
+	}
+
+	// The texts are truncated to the maximum length, [SEP] included
+	short, _ := NewWordPieceTokenizer(vocab, 4)
+	if ids := short.Encode("hello world hello world"); !reflect.DeepEqual(ids, []int64{2, 4, 6, 3}) {
+		t.Errorf("Expected a truncated text, got %v", ids)
+	}
+	// The tokens are counted without truncation
+	if count := short.CountTokens("hello world hello world"); count != 6 {
+		t.Errorf("Expected 6 tokens, got %d", count)
+	}
+
+	if _, err := NewWordPieceTokenizer(map[string]int64{"hello": 0}, 16); err == nil {
+		t.Error("Expected an error for a vocabulary without special tokens")
+	}
+}
+
+func TestMeanPooling(t *testing.T) {
+	ids, mask, types, length := padBatch([][]int64{{2, 4, 3}, {2, 3}})
+	if length != 3 || !reflect.DeepEqual(ids, []int64{2, 4, 3, 2, 3, 0}) || !reflect.DeepEqual(mask, []int64{1, 1, 1, 1, 1, 0}) || len(types) != 6 {
+		t.Fatalf("Unexpected batch: %v %v %v %d", ids, mask, types, length)
+	}
+
+	// The padding of the second text is ignored
+	hidden := []float32{
+		3, 0, 3, 0, 3, 0,
+		0, 4, 0, 4, 100, 100,
+	}
+	vectors := meanPooling(hidden, mask, 2, length, 2)
+	if !reflect.DeepEqual(vectors, [][]float32{{1, 0}, {0, 1}}) {
+		t.Errorf("Expected normalized mean vectors, got %v", vectors)
+	}
+}
+
+func TestNewLocalEmbedder(t *testing.T) {
+	// Without the onnx build tag, or without model, there is no local embedder
+	if embedder, err := New(ProviderLocal, Config{}); err == nil || embedder != nil {
+		t.Errorf("Expected an error without local model, got %v", embedder)
+	}
+}
@@ -0,0 +1,53 @@
+package embeddings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxErrorBodySize bounds the part of an error response reported in the error message
+const maxErrorBodySize = 512
+
+// postJSON sends a JSON request to an embedding provider and decodes its JSON response
+func postJSON(ctx context.Context, config Config, path string, request, response any) error {
+	body, err := json.Marshal(request)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRequestFailed, err)
+	}
+	httpRequest, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(config.BaseURL, "/")+path, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRequestFailed, err)
+	}
+	httpRequest.Header.Set("Content-Type", "application/json")
+	httpRequest.Header.Set("Accept", "application/json")
+	if config.APIKey != "" {
+		httpRequest.Header.Set("Authorization", "Bearer "+config.APIKey)
+	}
+	for name, value := range config.Headers {
+		httpRequest.Header.Set(name, value)
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	httpResponse, err := client.Do(httpRequest)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrRequestFailed, err)
+	}
+	defer httpResponse.Body.Close()
+
+	if httpResponse.StatusCode < 200 || httpResponse.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(httpResponse.Body, maxErrorBodySize))
+		return fmt.Errorf("%w: %s %s: %d %s", ErrRequestFailed, http.MethodPost, path, httpResponse.StatusCode, strings.TrimSpace(string(message)))
+	}
+	if err := json.NewDecoder(httpResponse.Body).Decode(response); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidResponse, err)
+	}
+	return nil
+}
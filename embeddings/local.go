@@ -0,0 +1,80 @@
+package embeddings
+
+import (
+	"errors"
+	"math"
+	"path/filepath"
+)
+
+// DefaultLocalMaxLength is the maximum number of tokens of a text embedded by a local model (longer texts are truncated)
+const DefaultLocalMaxLength = 512
+
+// ErrLocalUnavailable is returned when VectorMind is built without the onnx build tag (the local models need onnxruntime)
+var ErrLocalUnavailable = errors.New("local embedding models are not available in this build (build with -tags onnx)")
+
+// localVocabPath returns the vocabulary of a local model: the configured one, or vocab.txt next to the model
+func localVocabPath(config Config) string {
+	if config.VocabPath != "" {
+		return config.VocabPath
+	}
+	return filepath.Join(filepath.Dir(config.ModelPath), "vocab.txt")
+}
+
+// padBatch pads the token IDs of several texts to the same length, and returns the flattened IDs,
+// attention mask and token types of the batch with its sequence length
+func padBatch(batch [][]int64) (ids, mask, types []int64, length int) {
+	for _, tokens := range batch {
+		length = max(length, len(tokens))
+	}
+	ids = make([]int64, len(batch)*length)
+	mask = make([]int64, len(batch)*length)
+	types = make([]int64, len(batch)*length)
+	for i, tokens := range batch {
+		for j, id := range tokens {
+			ids[i*length+j] = id
+			mask[i*length+j] = 1
+		}
+	}
+	return ids, mask, types, length
+}
+
+// meanPooling averages the token vectors of each text (ignoring the padding), and normalizes the result.
+// hidden holds the [batch, length, dimension] output of the model.
+func meanPooling(hidden []float32, mask []int64, batch, length, dimension int) [][]float32 {
+	vectors := make([][]float32, batch)
+	for i := range vectors {
+		vector := make([]float32, dimension)
+		count := float32(0)
+		for j := 0; j < length; j++ {
+			if mask[i*length+j] == 0 {
+				continue
+			}
+			count++
+			token := hidden[(i*length+j)*dimension : (i*length+j+1)*dimension]
+			for k, value := range token {
+				vector[k] += value
+			}
+		}
+		for k := range vector {
+			vector[k] /= max(count, 1)
+		}
+		vectors[i] = normalize(vector)
+	}
+	return vectors
+}
+
+// normalize scales a vector to a unit length (the cosine distance of the index then matches the dot product)
+func normalize(vector []float32) []float32 {
+	var sum float64
+	for _, value := range vector {
+		sum += float64(value) * float64(value)
+	}
+	if sum == 0 {
+		return vector
+	}
+	norm := float32(math.Sqrt(sum))
+	for i := range vector {
+		vector[i] /= norm
+	}
+	return vector
+}
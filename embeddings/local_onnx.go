@@ -0,0 +1,155 @@
+//go:build onnx
+
+package embeddings
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sync"
+
+	ort "github.com/yalue/onnxruntime_go"
+)
+
+// onnxEnvironment initializes the onnxruntime environment once per process
+var onnxEnvironment struct {
+	once sync.Once
+	err  error
+}
+
+// LocalEmbedder creates the embeddings in-process with an ONNX model (e.g. all-MiniLM-L6-v2 or bge-small-en-v1.5
+// exported to ONNX) and onnxruntime, without a model runner
+type LocalEmbedder struct {
+	session    *ort.DynamicAdvancedSession
+	tokenizer  *WordPieceTokenizer
+	tokenTypes bool // the model expects the token_type_ids input
+	pooled     bool // the output of the model is already a sentence embedding
+	mutex      sync.Mutex
+}
+
+// NewLocalEmbedder loads the ONNX model of the config (ModelPath) and its vocabulary (VocabPath, default: vocab.txt
+// next to the model). LibraryPath is the onnxruntime shared library, and Threads the number of threads of an inference.
+func NewLocalEmbedder(config Config) (*LocalEmbedder, error) {
+	if config.ModelPath == "" {
+		return nil, fmt.Errorf("the path of the local model is required")
+	}
+	tokenizer, err := LoadWordPieceTokenizer(localVocabPath(config), DefaultLocalMaxLength)
+	if err != nil {
+		return nil, err
+	}
+
+	onnxEnvironment.once.Do(func() {
+		if config.LibraryPath != "" {
+			ort.SetSharedLibraryPath(config.LibraryPath)
+		}
+		onnxEnvironment.err = ort.InitializeEnvironment()
+	})
+	if onnxEnvironment.err != nil {
+		return nil, fmt.Errorf("failed to initialize onnxruntime: %w", onnxEnvironment.err)
+	}
+
+	inputs, outputs, err := ort.GetInputOutputInfo(config.ModelPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the local model: %w", err)
+	}
+	inputNames := []string{"input_ids", "attention_mask"}
+	embedder := &LocalEmbedder{tokenizer: tokenizer}
+	for _, input := range inputs {
+		if input.Name == "token_type_ids" {
+			embedder.tokenTypes = true
+			inputNames = append(inputNames, input.Name)
+		} else if !slices.Contains(inputNames, input.Name) {
+			return nil, fmt.Errorf("unsupported input %q of the local model", input.Name)
+		}
+	}
+	if len(outputs) == 0 {
+		return nil, fmt.Errorf("the local model has no output")
+	}
+	// Prefer the pooled output of the sentence-transformers exports, then the token vectors
+	output := outputs[0]
+	for _, candidate := range outputs {
+		if candidate.Name == "sentence_embedding" {
+			output = candidate
+		}
+	}
+	embedder.pooled = len(output.Dimensions) == 2
+
+	options, err := ort.NewSessionOptions()
+	if err != nil {
+		return nil, fmt.Errorf("failed to create the session options: %w", err)
+	}
+	defer options.Destroy()
+	if config.Threads > 0 {
+		if err := options.SetIntraOpNumThreads(config.Threads); err != nil {
+			return nil, fmt.Errorf("invalid number of threads: %w", err)
+		}
+	}
+	embedder.session, err = ort.NewDynamicAdvancedSession(config.ModelPath, inputNames, []string{output.Name}, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the local model: %w", err)
+	}
+	return embedder, nil
+}
+
+// Embed creates the embedding vectors of several texts with a single inference
+func (embedder *LocalEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRequestFailed, err)
+	}
+	batch := make([][]int64, len(texts))
+	for i, text := range texts {
+		batch[i] = embedder.tokenizer.Encode(text)
+	}
+	ids, mask, types, length := padBatch(batch)
+	shape := ort.NewShape(int64(len(texts)), int64(length))
+
+	inputData := [][]int64{ids, mask}
+	if embedder.tokenTypes {
+		inputData = append(inputData, types)
+	}
+	inputs := []ort.Value{}
+	for _, data := range inputData {
+		tensor, err := ort.NewTensor(shape, data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrRequestFailed, err)
+		}
+		defer tensor.Destroy()
+		inputs = append(inputs, tensor)
+	}
+	outputs := []ort.Value{nil}
+
+	// A session runs an inference at a time (the threads are used within the inference)
+	embedder.mutex.Lock()
+	err := embedder.session.Run(inputs, outputs)
+	embedder.mutex.Unlock()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrRequestFailed, err)
+	}
+	defer outputs[0].Destroy()
+
+	tensor, ok := outputs[0].(*ort.Tensor[float32])
+	if !ok {
+		return nil, fmt.Errorf("%w: the output of the local model is not a float32 tensor", ErrInvalidResponse)
+	}
+	data, dims := tensor.GetData(), tensor.GetShape()
+	var vectors [][]float32
+	switch {
+	case embedder.pooled && len(dims) == 2:
+		for i := 0; i < len(texts); i++ {
+			vectors = append(vectors, normalize(slices.Clone(data[i*int(dims[1]):(i+1)*int(dims[1])])))
+		}
+	case len(dims) == 3:
+		vectors = meanPooling(data, mask, len(texts), length, int(dims[2]))
+	default:
+		return nil, fmt.Errorf("%w: unexpected output shape %v", ErrInvalidResponse, dims)
+	}
+	if err := ValidateVectors(vectors, texts); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// Close releases the model
+func (embedder *LocalEmbedder) Close() error {
+	return embedder.session.Destroy()
+}
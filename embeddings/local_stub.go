@@ -0,0 +1,25 @@
+//go:build !onnx
+
+package embeddings
+
+import (
+	"context"
+)
+
+// LocalEmbedder creates the embeddings in-process with an ONNX model (only available with the onnx build tag)
+type LocalEmbedder struct{}
+
+// NewLocalEmbedder returns ErrLocalUnavailable: VectorMind is built without onnxruntime
+func NewLocalEmbedder(config Config) (*LocalEmbedder, error) {
+	return nil, ErrLocalUnavailable
+}
+
+// Embed returns ErrLocalUnavailable
+func (embedder *LocalEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	return nil, ErrLocalUnavailable
+}
+
+// Close does nothing
+func (embedder *LocalEmbedder) Close() error {
+	return nil
+}
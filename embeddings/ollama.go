@@ -0,0 +1,52 @@
+package embeddings
+
+import (
+	"context"
+)
+
+// DefaultOllamaBaseURL is the address of a local Ollama server
+const DefaultOllamaBaseURL = "http://localhost:11434"
+
+// OllamaEmbedder creates the embeddings with the native API of Ollama (POST /api/embeddings, one text per request)
+type OllamaEmbedder struct {
+	config Config
+}
+
+// NewOllamaEmbedder creates an embedder for the Ollama server at the base URL of the config (e.g. http://localhost:11434)
+func NewOllamaEmbedder(config Config) *OllamaEmbedder {
+	if config.BaseURL == "" {
+		config.BaseURL = DefaultOllamaBaseURL
+	}
+	return &OllamaEmbedder{config: config}
+}
+
+// ollamaRequest is the body of an Ollama embeddings request
+type ollamaRequest struct {
+	Model  string `json:"model"`
+	Prompt string `json:"prompt"`
+}
+
+// ollamaResponse is the body of an Ollama embeddings response
+type ollamaResponse struct {
+	Embedding []float64 `json:"embedding"`
+}
+
+// Embed creates the embedding vectors of several texts, with a request per text
+func (embedder *OllamaEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors := make([][]float32, 0, len(texts))
+	for _, text := range texts {
+		var response ollamaResponse
+		if err := postJSON(ctx, embedder.config, "/api/embeddings", ollamaRequest{Model: embedder.config.Model, Prompt: text}, &response); err != nil {
+			return nil, err
+		}
+		vector := make([]float32, len(response.Embedding))
+		for i, f := range response.Embedding {
+			vector[i] = float32(f)
+		}
+		vectors = append(vectors, vector)
+	}
+	if err := ValidateVectors(vectors, texts); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
@@ -0,0 +1,80 @@
+package embeddings
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/openai/openai-go"
+	"github.com/openai/openai-go/option"
+)
+
+// OpenAIEmbedder creates the embeddings with an OpenAI compatible API (POST /embeddings)
+type OpenAIEmbedder struct {
+	client openai.Client
+	model  string
+}
+
+// NewOpenAIEmbedder creates an embedder using an OpenAI client (e.g. configured for Azure OpenAI)
+func NewOpenAIEmbedder(client openai.Client, model string) *OpenAIEmbedder {
+	return &OpenAIEmbedder{client: client, model: model}
+}
+
+// NewOpenAIEmbedderFromConfig creates an embedder for the OpenAI compatible API at the base URL of the config
+func NewOpenAIEmbedderFromConfig(config Config) *OpenAIEmbedder {
+	options := []option.RequestOption{
+		option.WithBaseURL(config.BaseURL),
+		option.WithAPIKey(config.APIKey),
+	}
+	for name, value := range config.Headers {
+		options = append(options, option.WithHeader(name, value))
+	}
+	if config.HTTPClient != nil {
+		options = append(options, option.WithHTTPClient(config.HTTPClient))
+	}
+	return NewOpenAIEmbedder(openai.NewClient(options...), config.Model)
+}
+
+// Embed creates the embedding vectors of several texts with a single request
+func (embedder *OpenAIEmbedder) Embed(ctx context.Context, texts []string) ([][]float32, error) {
+	vectors, _, err := embedder.EmbedWithTokens(ctx, texts)
+	return vectors, err
+}
+
+// EmbedWithTokens creates the embedding vectors of several texts with a single request,
+// and returns the number of input tokens reported by the provider
+func (embedder *OpenAIEmbedder) EmbedWithTokens(ctx context.Context, texts []string) ([][]float32, int64, error) {
+	input := openai.EmbeddingNewParamsInputUnion{OfArrayOfStrings: texts}
+	if len(texts) == 1 {
+		input = openai.EmbeddingNewParamsInputUnion{OfString: openai.String(texts[0])}
+	}
+	response, err := embedder.client.Embeddings.New(ctx, openai.EmbeddingNewParams{
+		Input: input,
+		Model: embedder.model,
+	})
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", ErrRequestFailed, err)
+	}
+	if response == nil {
+		return nil, 0, fmt.Errorf("%w: empty response", ErrInvalidResponse)
+	}
+	if len(response.Data) != len(texts) {
+		return nil, 0, fmt.Errorf("%w: expected %d embeddings, got %d", ErrInvalidResponse, len(texts), len(response.Data))
+	}
+
+	// convert the embeddings to []float32, ordered by input index
+	vectors := make([][]float32, len(texts))
+	for _, data := range response.Data {
+		if data.Index < 0 || int(data.Index) >= len(texts) || vectors[data.Index] != nil {
+			return nil, 0, fmt.Errorf("%w: invalid embedding index %d", ErrInvalidResponse, data.Index)
+		}
+		if len(data.Embedding) == 0 {
+			return nil, 0, fmt.Errorf("%w: empty embedding vector at index %d", ErrInvalidResponse, data.Index)
+		}
+		vector := make([]float32, len(data.Embedding))
+		for i, f := range data.Embedding {
+			vector[i] = float32(f)
+		}
+		vectors[data.Index] = vector
+	}
+	return vectors, response.Usage.PromptTokens, nil
+}
@@ -0,0 +1,150 @@
+package embeddings
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Special tokens of the BERT vocabularies
+const (
+	tokenClassifier = "[CLS]"
+	tokenSeparator  = "[SEP]"
+	tokenUnknown    = "[UNK]"
+)
+
+// maxWordPieceRunes is the length above which a word is not split into word pieces (it becomes [UNK])
+const maxWordPieceRunes = 100
+
+// WordPieceTokenizer converts texts to the token IDs of a BERT model (vocab.txt of the uncased models:
+// the texts are lowercased and their accents removed)
+type WordPieceTokenizer struct {
+	vocab     map[string]int64
+	maxLength int
+}
+
+// LoadWordPieceTokenizer reads a vocab.txt file (one token per line, the ID of a token is its line number).
+// The texts are truncated to maxLength tokens, including [CLS] and [SEP].
+func LoadWordPieceTokenizer(vocabPath string, maxLength int) (*WordPieceTokenizer, error) {
+	file, err := os.Open(vocabPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the vocabulary: %w", err)
+	}
+	defer file.Close()
+
+	vocab := map[string]int64{}
+	scanner := bufio.NewScanner(file)
+	for id := int64(0); scanner.Scan(); id++ {
+		vocab[strings.TrimRight(scanner.Text(), "\r")] = id
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the vocabulary: %w", err)
+	}
+	return NewWordPieceTokenizer(vocab, maxLength)
+}
+
+// NewWordPieceTokenizer creates a tokenizer from a vocabulary (token to ID)
+func NewWordPieceTokenizer(vocab map[string]int64, maxLength int) (*WordPieceTokenizer, error) {
+	for _, token := range []string{tokenClassifier, tokenSeparator, tokenUnknown} {
+		if _, ok := vocab[token]; !ok {
+			return nil, fmt.Errorf("the vocabulary has no %s token", token)
+		}
+	}
+	if maxLength < 3 {
+		return nil, fmt.Errorf("the maximum length must be at least 3 tokens, got %d", maxLength)
+	}
+	return &WordPieceTokenizer{vocab: vocab, maxLength: maxLength}, nil
+}
+
+// Encode returns the token IDs of a text, between [CLS] and [SEP]
+func (tokenizer *WordPieceTokenizer) Encode(text string) []int64 {
+	ids := []int64{tokenizer.vocab[tokenClassifier]}
+	for _, word := range basicTokens(text) {
+		for _, id := range tokenizer.wordPieces(word) {
+			if len(ids) == tokenizer.maxLength-1 {
+				return append(ids, tokenizer.vocab[tokenSeparator])
+			}
+			ids = append(ids, id)
+		}
+	}
+	return append(ids, tokenizer.vocab[tokenSeparator])
+}
+
+// CountTokens returns the number of tokens of a text, including [CLS] and [SEP], without truncation
+func (tokenizer *WordPieceTokenizer) CountTokens(text string) int {
+	count := 2
+	for _, word := range basicTokens(text) {
+		count += len(tokenizer.wordPieces(word))
+	}
+	return count
+}
+
+// wordPieces splits a word into the longest pieces of the vocabulary (the pieces after the first one start with ##)
+func (tokenizer *WordPieceTokenizer) wordPieces(word string) []int64 {
+	runes := []rune(word)
+	if len(runes) > maxWordPieceRunes {
+		return []int64{tokenizer.vocab[tokenUnknown]}
+	}
+
+	ids := []int64{}
+	for start := 0; start < len(runes); {
+		end := len(runes)
+		var id int64
+		found := false
+		for ; end > start; end-- {
+			piece := string(runes[start:end])
+			if start > 0 {
+				piece = "##" + piece
+			}
+			if id, found = tokenizer.vocab[piece]; found {
+				break
+			}
+		}
+		if !found {
+			return []int64{tokenizer.vocab[tokenUnknown]}
+		}
+		ids = append(ids, id)
+		start = end
+	}
+	return ids
+}
+
+// basicTokens lowercases a text, removes its accents and control characters,
+// and splits it on whitespace and around punctuation and CJK characters
+func basicTokens(text string) []string {
+	var builder strings.Builder
+	for _, r := range norm.NFD.String(strings.ToLower(text)) {
+		switch {
+		case r == 0 || r == unicode.ReplacementChar || unicode.Is(unicode.Mn, r):
+			continue
+		case unicode.IsSpace(r):
+			builder.WriteRune(' ')
+		case unicode.IsControl(r):
+			continue
+		case isPunctuation(r) || isCJK(r):
+			builder.WriteRune(' ')
+			builder.WriteRune(r)
+			builder.WriteRune(' ')
+		default:
+			builder.WriteRune(r)
+		}
+	}
+	return strings.Fields(builder.String())
+}
+
+// isPunctuation reports whether a character is a punctuation for BERT (including the ASCII symbols like $ or ^)
+func isPunctuation(r rune) bool {
+	if (r >= 33 && r <= 47) || (r >= 58 && r <= 64) || (r >= 91 && r <= 96) || (r >= 123 && r <= 126) {
+		return true
+	}
+	return unicode.IsPunct(r)
+}
+
+// isCJK reports whether a character is a CJK ideograph (tokenized as a word of its own)
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r)
+}
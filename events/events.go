@@ -0,0 +1,107 @@
+package events
+
+import (
+	"sync"
+	"time"
+	"vectormind/models"
+)
+
+// Event types
+const (
+	TypeServerStarted     = "server_started"
+	TypeIndexCreated      = "index_created"
+	TypeIndexRebuilt      = "index_rebuilt"
+	TypeIndexReset        = "index_reset"
+	TypeIndexCorrupted    = "index_corrupted"
+	TypeCollectionCreated = "collection_created"
+	TypeCollectionDeleted = "collection_deleted"
+	TypeModelChanged      = "model_changed"
+	TypeJobCompleted      = "job_completed"
+	TypeJobFailed         = "job_failed"
+	TypeCircuitOpened     = "circuit_opened"
+)
+
+// DefaultBufferSize is the number of events kept by default
+const DefaultBufferSize = 1000
+
+// Log is a ring buffer of the recent server events. The oldest events are dropped when the buffer is full.
+type Log struct {
+	mutex  sync.Mutex
+	events []models.ServerEvent
+	next   int    // position of the next event in the buffer
+	lastID uint64 // ID of the last recorded event (IDs start at 1)
+}
+
+// NewLog creates an event log keeping the last size events
+func NewLog(size int) *Log {
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+	return &Log{events: make([]models.ServerEvent, 0, size)}
+}
+
+// Record adds an event to the log
+func (log *Log) Record(eventType, message string, fields map[string]any) {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	log.lastID++
+	event := models.ServerEvent{
+		ID:      log.lastID,
+		Type:    eventType,
+		Time:    time.Now().UTC().Format(time.RFC3339Nano),
+		Message: message,
+		Fields:  fields,
+	}
+	if len(log.events) < cap(log.events) {
+		log.events = append(log.events, event)
+		return
+	}
+	log.events[log.next] = event
+	log.next = (log.next + 1) % len(log.events)
+}
+
+// Since returns the events recorded after the event sinceID (all the kept events when sinceID is 0), oldest first
+func (log *Log) Since(sinceID uint64) []models.ServerEvent {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+
+	events := []models.ServerEvent{}
+	for i := range log.events {
+		event := log.events[(log.next+i)%len(log.events)]
+		if event.ID > sinceID {
+			events = append(events, event)
+		}
+	}
+	return events
+}
+
+// LastID returns the ID of the last recorded event (0 when no event was recorded)
+func (log *Log) LastID() uint64 {
+	log.mutex.Lock()
+	defer log.mutex.Unlock()
+	return log.lastID
+}
+
+// defaultLog is the event log of the server
+var defaultLog = NewLog(DefaultBufferSize)
+
+// SetBufferSize replaces the event log of the server by a log keeping the last size events
+func SetBufferSize(size int) {
+	defaultLog = NewLog(size)
+}
+
+// Record adds an event to the event log of the server
+func Record(eventType, message string, fields map[string]any) {
+	defaultLog.Record(eventType, message, fields)
+}
+
+// Since returns the events of the server recorded after the event sinceID, oldest first
+func Since(sinceID uint64) []models.ServerEvent {
+	return defaultLog.Since(sinceID)
+}
+
+// LastID returns the ID of the last event of the server
+func LastID() uint64 {
+	return defaultLog.LastID()
+}
@@ -0,0 +1,137 @@
+// Package features provides the feature flags gating the experimental features, so that a deployment enables or
+// disables them without code changes (FEATURE_FLAGS, e.g. "semantic_chunking=off,reranking=on").
+package features
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"vectormind/models"
+)
+
+// Feature flags
+const (
+	// HierarchySplitting gates the markdown hierarchy splitter endpoint and tool
+	HierarchySplitting = "hierarchy_splitting"
+	// SemanticChunking gates the semantic chunk and store endpoint and tool
+	SemanticChunking = "semantic_chunking"
+	// Reranking gates the reranking of the search results (rerank parameter and rerank_results tool)
+	Reranking = "reranking"
+	// QueryExpansion gates the expansion of the search queries (expand_query parameter)
+	QueryExpansion = "query_expansion"
+)
+
+// ErrDisabled is returned when a disabled feature is used
+var ErrDisabled = errors.New("feature disabled")
+
+// Flag is a known feature flag, with its value when FEATURE_FLAGS does not set it
+type Flag struct {
+	Name        string
+	Description string
+	Default     bool
+}
+
+// Flags are the known feature flags
+var Flags = []Flag{
+	{Name: HierarchySplitting, Description: "Markdown hierarchy splitter (/split-and-store-markdown-with-hierarchy and its tool)", Default: true},
+	{Name: SemanticChunking, Description: "Semantic chunking (/semantic-chunk-and-store and its tool)", Default: true},
+	{Name: Reranking, Description: "Reranking of the search results (rerank parameter and rerank_results tool)", Default: true},
+	{Name: QueryExpansion, Description: "Expansion of the search queries into paraphrases (expand_query parameter)", Default: true},
+}
+
+var (
+	mutex sync.RWMutex
+	// values are the values set by FEATURE_FLAGS (the other flags have their default value)
+	values = map[string]bool{}
+)
+
+// Parse parses a list of feature flags like "semantic_chunking=off,reranking=on" (on, true, 1, off, false or 0)
+func Parse(spec string) (map[string]bool, error) {
+	parsed := map[string]bool{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, found := strings.Cut(entry, "=")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if !found {
+			return nil, fmt.Errorf("invalid feature flag %q (expected name=on or name=off)", entry)
+		}
+		if _, ok := lookup(name); !ok {
+			return nil, fmt.Errorf("unknown feature flag %q (use %s)", name, strings.Join(names(), ", "))
+		}
+		switch strings.ToLower(strings.TrimSpace(value)) {
+		case "on", "true", "1":
+			parsed[name] = true
+		case "off", "false", "0":
+			parsed[name] = false
+		default:
+			return nil, fmt.Errorf("invalid value %q of feature flag %s (use on or off)", value, name)
+		}
+	}
+	return parsed, nil
+}
+
+// Set sets the values of the feature flags (the flags without value have their default value)
+func Set(flags map[string]bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	values = map[string]bool{}
+	for name, value := range flags {
+		values[name] = value
+	}
+}
+
+// Enabled returns true when a feature is enabled (false for an unknown feature)
+func Enabled(name string) bool {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if value, ok := values[name]; ok {
+		return value
+	}
+	flag, ok := lookup(name)
+	return ok && flag.Default
+}
+
+// Check returns an error wrapping ErrDisabled when a feature is disabled
+func Check(name string) error {
+	if !Enabled(name) {
+		return fmt.Errorf("%w: %s (FEATURE_FLAGS)", ErrDisabled, name)
+	}
+	return nil
+}
+
+// List returns the known feature flags with their current value
+func List() []models.FeatureFlag {
+	list := make([]models.FeatureFlag, len(Flags))
+	for i, flag := range Flags {
+		list[i] = models.FeatureFlag{
+			Name:        flag.Name,
+			Enabled:     Enabled(flag.Name),
+			Default:     flag.Default,
+			Description: flag.Description,
+		}
+	}
+	return list
+}
+
+// lookup returns the known feature flag of a name
+func lookup(name string) (Flag, bool) {
+	for _, flag := range Flags {
+		if flag.Name == name {
+			return flag, true
+		}
+	}
+	return Flag{}, false
+}
+
+// names returns the names of the known feature flags
+func names() []string {
+	list := make([]string, len(Flags))
+	for i, flag := range Flags {
+		list[i] = flag.Name
+	}
+	return list
+}
@@ -0,0 +1,142 @@
+package helpers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// ErrConcurrencyLimitReached is returned when no slot of a concurrency limiter frees up in time
+var ErrConcurrencyLimitReached = errors.New("concurrency limit reached")
+
+// DefaultPressureThreshold is the default pressure of a limiter from which the responses carry a load hint
+const DefaultPressureThreshold = 0.8
+
+// minRetryAfter is the shortest retry delay of a load hint
+const minRetryAfter = 100 * time.Millisecond
+
+// ConcurrencyLimiter is a semaphore limiting the number of requests of an endpoint class processed at the same time.
+// A nil limiter does not limit anything.
+type ConcurrencyLimiter struct {
+	name    string
+	slots   chan struct{}
+	maxWait time.Duration
+	// waiting is the number of requests waiting for a slot, holdTime the moving average of the time a slot is held
+	// (in nanoseconds) and threshold the pressure from which a load hint is given (see Hint)
+	waiting   atomic.Int64
+	holdTime  atomic.Int64
+	threshold float64
+}
+
+// LoadHint tells the callers of a loaded endpoint class to slow down before their requests are refused:
+// Pressure is the number of requests in progress or waiting for a slot divided by the limit (above 1 the requests
+// wait), and RetryAfter the estimated time until a slot frees up for a new request
+type LoadHint struct {
+	Pressure   float64
+	RetryAfter time.Duration
+}
+
+// NewConcurrencyLimiter creates a limiter of limit concurrent requests, a request waits at most maxWait for a slot.
+// It returns nil (no limit) when limit is 0 or negative.
+func NewConcurrencyLimiter(name string, limit int, maxWait time.Duration) *ConcurrencyLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &ConcurrencyLimiter{
+		name:      name,
+		slots:     make(chan struct{}, limit),
+		maxWait:   maxWait,
+		threshold: DefaultPressureThreshold,
+	}
+}
+
+// SetPressureThreshold sets the pressure from which the responses carry a load hint (see Hint)
+func (limiter *ConcurrencyLimiter) SetPressureThreshold(threshold float64) {
+	if limiter != nil {
+		limiter.threshold = threshold
+	}
+}
+
+// Acquire waits for a free slot and returns the function releasing it.
+// It returns ErrConcurrencyLimitReached when no slot frees up within the maximum wait, or the context error.
+func (limiter *ConcurrencyLimiter) Acquire(ctx context.Context) (func(), error) {
+	if limiter == nil {
+		return func() {}, nil
+	}
+
+	var acquiredAt time.Time
+	release := func() {
+		limiter.recordHoldTime(time.Since(acquiredAt))
+		<-limiter.slots
+	}
+
+	// Fast path: a slot is free
+	select {
+	case limiter.slots <- struct{}{}:
+		acquiredAt = time.Now()
+		return release, nil
+	default:
+	}
+
+	limiter.waiting.Add(1)
+	defer limiter.waiting.Add(-1)
+	timer := time.NewTimer(limiter.maxWait)
+	defer timer.Stop()
+	select {
+	case limiter.slots <- struct{}{}:
+		acquiredAt = time.Now()
+		return release, nil
+	case <-timer.C:
+		return nil, fmt.Errorf("%w: %d %s requests in progress", ErrConcurrencyLimitReached, cap(limiter.slots), limiter.name)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// InFlight returns the number of requests holding a slot
+func (limiter *ConcurrencyLimiter) InFlight() int {
+	if limiter == nil {
+		return 0
+	}
+	return len(limiter.slots)
+}
+
+// Limit returns the maximum number of concurrent requests (0 when there is no limit)
+func (limiter *ConcurrencyLimiter) Limit() int {
+	if limiter == nil {
+		return 0
+	}
+	return cap(limiter.slots)
+}
+
+// recordHoldTime updates the moving average of the time a slot is held (each request weighs 1/8)
+func (limiter *ConcurrencyLimiter) recordHoldTime(held time.Duration) {
+	for {
+		average := limiter.holdTime.Load()
+		next := int64(held)
+		if average > 0 {
+			next = average + (int64(held)-average)/8
+		}
+		if limiter.holdTime.CompareAndSwap(average, next) {
+			return
+		}
+	}
+}
+
+// Hint returns the load hint of the limiter, ok is false while the pressure is below the threshold
+// (or without limit). The retry delay is the average time a slot is held, multiplied by the number of requests
+// ahead of a new one for each slot.
+func (limiter *ConcurrencyLimiter) Hint() (hint LoadHint, ok bool) {
+	if limiter == nil {
+		return LoadHint{}, false
+	}
+	waiting := limiter.waiting.Load()
+	pressure := float64(int64(len(limiter.slots))+waiting) / float64(cap(limiter.slots))
+	if pressure < limiter.threshold {
+		return LoadHint{}, false
+	}
+	retryAfter := time.Duration(limiter.holdTime.Load() * (waiting + 1) / int64(cap(limiter.slots)))
+	return LoadHint{Pressure: pressure, RetryAfter: max(retryAfter, minRetryAfter)}, true
+}
@@ -0,0 +1,55 @@
+package helpers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+)
+
+// maxRequestIDLength bounds the length of the request IDs chosen by the callers
+const maxRequestIDLength = 128
+
+type requestIDContextKey struct{}
+
+// NewRequestID returns a new random request ID (16 hex characters)
+func NewRequestID() string {
+	buf := make([]byte, 8)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+// ValidRequestID reports whether a request ID chosen by a caller can be used: 1 to 128 printable ASCII characters,
+// without spaces, so that it cannot forge log lines
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// WithRequestID returns a context carrying the ID of the request it serves
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, id)
+}
+
+// RequestID returns the ID of the request served with the context ("" outside of a request)
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDContextKey{}).(string)
+	return id
+}
+
+// Logf logs a message as log.Printf, followed by the ID of the request served with the context
+func Logf(ctx context.Context, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	if id := RequestID(ctx); id != "" {
+		message += " [request " + id + "]"
+	}
+	log.Print(message)
+}
@@ -0,0 +1,12 @@
+package helpers
+
+import "unicode/utf8"
+
+// TruncateText returns the first maxChars characters (runes) of a text,
+// and whether the text was truncated
+func TruncateText(text string, maxChars int) (string, bool) {
+	if maxChars < 0 || utf8.RuneCountInString(text) <= maxChars {
+		return text, false
+	}
+	return string([]rune(text)[:maxChars]), true
+}
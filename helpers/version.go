@@ -0,0 +1,36 @@
+package helpers
+
+import (
+	"runtime/debug"
+)
+
+// Build information, set at build time with
+// -ldflags "-X vectormind/helpers.Version=0.0.3 -X vectormind/helpers.Commit=abc1234 -X vectormind/helpers.BuildDate=2025-01-01T00:00:00Z"
+var (
+	Version   = "dev"
+	Commit    = ""
+	BuildDate = ""
+)
+
+// BuildInfo returns the version, commit hash and build date of the binary. Without ldflags, the commit and the date
+// are the ones recorded by the Go toolchain when built from a git checkout ("unknown" otherwise).
+func BuildInfo() (version, commit, buildDate string) {
+	commit, buildDate = Commit, BuildDate
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch {
+			case setting.Key == "vcs.revision" && commit == "":
+				commit = setting.Value
+			case setting.Key == "vcs.time" && buildDate == "":
+				buildDate = setting.Value
+			}
+		}
+	}
+	if commit == "" {
+		commit = "unknown"
+	}
+	if buildDate == "" {
+		buildDate = "unknown"
+	}
+	return Version, commit, buildDate
+}
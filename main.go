@@ -2,78 +2,568 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"log"
+	"maps"
 	"net/http"
+	"net/netip"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 	"vectormind/api"
+	"vectormind/archive"
+	"vectormind/embeddings"
+	"vectormind/events"
+	"vectormind/features"
 	"vectormind/helpers"
 	"vectormind/mcptools"
+	"vectormind/ocr"
+	"vectormind/splitter"
 	"vectormind/store"
 
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/openai/openai-go"
-	"github.com/openai/openai-go/option"
+	"github.com/redis/go-redis/v9"
 )
 
+// defaultEmbeddingMaxTokens is the context window used when the embedding model one is unknown
+// (512 tokens is the context window of most embedding models, like mxbai-embed-large)
+const defaultEmbeddingMaxTokens = 512
+
 func main() {
+	migrate := flag.Bool("migrate", false, "re-embed the stored documents when the embedding model dimension does not match the index")
+	flag.Parse()
+
 	ctx := context.Background()
 
+	// Build information, first in the logs to know which version a report comes from
+	version, commit, buildDate := helpers.BuildInfo()
+	fmt.Printf("VectorMind %s (commit %s, built %s)\n", version, commit, buildDate)
+
+	// Recent structured events of the server, available on /events
+	events.SetBufferSize(helpers.StringToInt(helpers.GetEnvOrDefault("EVENTS_BUFFER_SIZE", strconv.Itoa(events.DefaultBufferSize))))
+
 	mcpHttpPort := helpers.GetEnvOrDefault("MCP_HTTP_PORT", "9090")
 	apiRestPort := helpers.GetEnvOrDefault("API_REST_PORT", "8080")
 
 	redisIndexName := helpers.GetEnvOrDefault("REDIS_INDEX_NAME", "vector_idx")
 	redisAddress := helpers.GetEnvOrDefault("REDIS_ADDRESS", "localhost:6379")
 	redisPassword := helpers.GetEnvOrDefault("REDIS_PASSWORD", "")
+	redisDB, err := strconv.Atoi(helpers.GetEnvOrDefault("REDIS_DB", "0"))
+	if err != nil || redisDB != 0 {
+		log.Fatalf("Invalid REDIS_DB: %q (RediSearch only indexes database 0, declare the tenants with REDIS_TENANTS to isolate their documents)", helpers.GetEnvOrDefault("REDIS_DB", "0"))
+	}
+	redisTenants, err := store.ParseTenants(helpers.GetEnvOrDefault("REDIS_TENANTS", ""))
+	if err != nil {
+		log.Fatalf("Invalid REDIS_TENANTS: %v", err)
+	}
+	redisMemoryWatermark, err := store.ParseMemoryWatermark(helpers.GetEnvOrDefault("REDIS_MEMORY_WATERMARK", ""))
+	if err != nil {
+		log.Fatalf("Invalid REDIS_MEMORY_WATERMARK: %v", err)
+	}
+
+	// Roles of the API keys (callers with the metadata_only role do not receive the document content)
+	apiKeyRoles, err := api.ParseAPIKeyRoles(helpers.GetEnvOrDefault("API_KEY_ROLES", ""))
+	if err != nil {
+		log.Fatalf("Invalid API_KEY_ROLES: %v", err)
+	}
+	apiDefaultRole, err := api.ParseRole(helpers.GetEnvOrDefault("API_DEFAULT_ROLE", string(api.RoleFull)))
+	if err != nil {
+		log.Fatalf("Invalid API_DEFAULT_ROLE: %v", err)
+	}
+	api.SetAPIKeyRoles(apiKeyRoles, apiDefaultRole)
+
+	indexOptions := store.IndexOptions{
+		Type:           helpers.GetEnvOrDefault("INDEX_TYPE", store.IndexTypeHNSW),
+		M:              helpers.StringToInt(helpers.GetEnvOrDefault("HNSW_M", "0")),
+		EFConstruction: helpers.StringToInt(helpers.GetEnvOrDefault("HNSW_EF_CONSTRUCTION", "0")),
+		EFRuntime:      helpers.StringToInt(helpers.GetEnvOrDefault("HNSW_EF_RUNTIME", "0")),
+	}
+	if err := store.ValidateIndexOptions(indexOptions); err != nil {
+		log.Fatalf("Invalid index settings: %v", err)
+	}
 
 	embeddingModelId := helpers.GetEnvOrDefault("EMBEDDING_MODEL", "ai/mxbai-embed-large")
 	api.SetEmbeddingModelId(embeddingModelId)
 	mcptools.SetEmbeddingModelId(embeddingModelId)
 	modelRunnerEndpoint := helpers.GetEnvOrDefault("MODEL_RUNNER_BASE_URL", "http://localhost:12434/engines/llama.cpp/v1")
 
-	// Initialize OpenAI client
-	openaiClient := openai.NewClient(
-		option.WithBaseURL(modelRunnerEndpoint),
-		option.WithAPIKey(""),
-	)
+	// Initialize OpenAI client (a hosted provider requires an API key, and sometimes extra headers)
+	modelExtraHeaders, err := store.ParseExtraHeaders(helpers.GetEnvOrDefault("MODEL_EXTRA_HEADERS", ""))
+	if err != nil {
+		log.Fatalf("Invalid MODEL_EXTRA_HEADERS: %v", err)
+	}
+	providerOptions := store.ProviderOptions(modelRunnerEndpoint, helpers.GetEnvOrDefault("MODEL_API_KEY", ""), modelExtraHeaders)
+	if azureEndpoint := helpers.GetEnvOrDefault("AZURE_OPENAI_ENDPOINT", ""); azureEndpoint != "" {
+		// Azure OpenAI serves the model of a deployment (by default, the deployment is named after the model)
+		providerOptions, err = store.AzureProviderOptions(azureEndpoint,
+			helpers.GetEnvOrDefault("AZURE_OPENAI_DEPLOYMENT", embeddingModelId),
+			helpers.GetEnvOrDefault("AZURE_OPENAI_API_VERSION", store.DefaultAzureAPIVersion),
+			helpers.GetEnvOrDefault("AZURE_OPENAI_API_KEY", ""),
+			modelExtraHeaders,
+		)
+		if err != nil {
+			log.Fatalf("Invalid Azure OpenAI settings: %v", err)
+		}
+		fmt.Printf("Using Azure OpenAI deployment: %s\n", helpers.GetEnvOrDefault("AZURE_OPENAI_DEPLOYMENT", embeddingModelId))
+	}
+	openaiClient := openai.NewClient(providerOptions...)
+
+	// Additional embedding models (optional), bound to the collections created with their name
+	extraModelIds, err := store.ParseEmbeddingModels(helpers.GetEnvOrDefault("EMBEDDING_MODELS", ""))
+	if err != nil {
+		log.Fatalf("Invalid EMBEDDING_MODELS: %v", err)
+	}
+	extraModelNames := slices.Sorted(maps.Keys(extraModelIds))
+	if len(extraModelIds) > 0 && helpers.GetEnvOrDefault("AZURE_OPENAI_ENDPOINT", "") != "" {
+		log.Fatalf("EMBEDDING_MODELS is not supported with Azure OpenAI (a single deployment)")
+	}
+
+	// Embedding provider: an OpenAI compatible API (default), Ollama's native API, Cohere or a local ONNX model
+	embeddingProvider := strings.ToLower(helpers.GetEnvOrDefault("EMBEDDING_PROVIDER", embeddings.ProviderOpenAI))
+	if embeddingProvider == embeddings.ProviderLocal && len(extraModelIds) > 0 {
+		log.Fatalf("EMBEDDING_MODELS is not supported by the local embedding provider (a single EMBEDDING_MODEL_PATH)")
+	}
+	if embeddingProvider != embeddings.ProviderOpenAI {
+		for _, modelId := range append([]string{embeddingModelId}, slices.Collect(maps.Values(extraModelIds))...) {
+			embedder, err := embeddings.New(embeddingProvider, embeddings.Config{
+				BaseURL:     helpers.GetEnvOrDefault("MODEL_RUNNER_BASE_URL", ""), // empty: the default address of the provider
+				APIKey:      helpers.GetEnvOrDefault("MODEL_API_KEY", ""),
+				Model:       modelId,
+				Headers:     modelExtraHeaders,
+				ModelPath:   helpers.GetEnvOrDefault("EMBEDDING_MODEL_PATH", ""),
+				VocabPath:   helpers.GetEnvOrDefault("EMBEDDING_VOCAB_PATH", ""),
+				LibraryPath: helpers.GetEnvOrDefault("ONNXRUNTIME_LIBRARY_PATH", ""),
+				Threads:     helpers.StringToInt(helpers.GetEnvOrDefault("EMBEDDING_THREADS", "0")),
+			})
+			if err != nil {
+				log.Fatalf("Invalid EMBEDDING_PROVIDER: %v", err)
+			}
+			store.SetEmbedder(modelId, embedder)
+		}
+	}
+	fmt.Printf("Using embedding provider: %s\n", embeddingProvider)
+
+	// Fallback embedding provider (optional), used when the model runner fails
+	var embeddingFallback *store.EmbeddingFallback
+	if fallbackBaseURL := helpers.GetEnvOrDefault("EMBEDDING_FALLBACK_BASE_URL", ""); fallbackBaseURL != "" {
+		fallbackExtraHeaders, err := store.ParseExtraHeaders(helpers.GetEnvOrDefault("EMBEDDING_FALLBACK_EXTRA_HEADERS", ""))
+		if err != nil {
+			log.Fatalf("Invalid EMBEDDING_FALLBACK_EXTRA_HEADERS: %v", err)
+		}
+		fallbackClient := openai.NewClient(store.ProviderOptions(fallbackBaseURL, helpers.GetEnvOrDefault("EMBEDDING_FALLBACK_API_KEY", ""), fallbackExtraHeaders)...)
+		embeddingFallback = store.NewEmbeddingFallback(fallbackClient,
+			helpers.GetEnvOrDefault("EMBEDDING_FALLBACK_MODEL", embeddingModelId),
+			helpers.StringToInt(helpers.GetEnvOrDefault("EMBEDDING_CIRCUIT_FAILURES", "3")),
+			time.Duration(helpers.StringToInt(helpers.GetEnvOrDefault("EMBEDDING_CIRCUIT_COOLDOWN_MS", "30000")))*time.Millisecond,
+		)
+		store.SetEmbeddingFallback(embeddingFallback)
+	}
+
+	// Feature flags of the experimental features (all enabled by default)
+	featureFlags, err := features.Parse(helpers.GetEnvOrDefault("FEATURE_FLAGS", ""))
+	if err != nil {
+		log.Fatalf("Invalid FEATURE_FLAGS: %v", err)
+	}
+	features.Set(featureFlags)
+	for _, flag := range features.List() {
+		if !flag.Enabled {
+			fmt.Printf("Feature disabled: %s\n", flag.Name)
+		}
+	}
+
+	// Chat model answering the questions of /ask and expanding the search queries (optional), served by the model runner or by another provider
+	if chatModelId := helpers.GetEnvOrDefault("CHAT_MODEL", ""); chatModelId != "" {
+		chatClient := openaiClient
+		if chatBaseURL := helpers.GetEnvOrDefault("CHAT_BASE_URL", ""); chatBaseURL != "" {
+			chatClient = openai.NewClient(store.ProviderOptions(chatBaseURL, helpers.GetEnvOrDefault("CHAT_API_KEY", ""), nil)...)
+		}
+		store.SetChatModel(chatClient, chatModelId)
+		fmt.Printf("Using chat model: %s\n", chatModelId)
+	}
+	// Number of paraphrases of the expanded search queries (expand_query), generated by the chat model
+	if err := store.SetQueryVariants(helpers.StringToInt(helpers.GetEnvOrDefault("QUERY_EXPANSION_VARIANTS", strconv.Itoa(store.DefaultQueryVariants)))); err != nil {
+		log.Fatalf("Invalid QUERY_EXPANSION_VARIANTS: %v", err)
+	}
+
+	// Reranker of the searches with rerank (optional): a rerank API, the chat model, or a registered provider
+	if rerankProvider := strings.ToLower(helpers.GetEnvOrDefault("RERANK_PROVIDER", "")); rerankProvider != "" {
+		reranker, err := store.NewReranker(rerankProvider, store.RerankerConfig{
+			BaseURL: helpers.GetEnvOrDefault("RERANK_BASE_URL", ""),
+			APIKey:  helpers.GetEnvOrDefault("RERANK_API_KEY", ""),
+			Model:   helpers.GetEnvOrDefault("RERANK_MODEL", ""),
+		})
+		if err != nil {
+			log.Fatalf("Invalid RERANK_PROVIDER: %v", err)
+		}
+		store.SetReranker(reranker, helpers.StringToInt(helpers.GetEnvOrDefault("RERANK_CANDIDATES_FACTOR", strconv.Itoa(store.DefaultRerankCandidatesFactor))))
+		fmt.Printf("Using reranker: %s\n", rerankProvider)
+	}
 
 	// Calculate the embedding dimension based on the model
+	// (this first embedding also warms up the model, so that the first query does not wait for the model to load)
 	var embeddingDimension int
+	warmUpStart := time.Now()
 	e, err := store.CreateEmbeddingFromText(ctx, openaiClient, "Hello World", embeddingModelId)
 	if err != nil {
 		log.Fatalf("Failed to create test embedding to determine dimension: %v", err)
 	}
 	embeddingDimension = len(e)
+	fmt.Printf("Embedding model warmed up in %s\n", time.Since(warmUpStart).Round(time.Millisecond))
+	if embeddingFallback != nil {
+		// The fallback vectors are stored in the same index: the fallback model must have the same dimension
+		if err := embeddingFallback.VerifyDimension(ctx, embeddingDimension); err != nil {
+			log.Fatalf("Invalid fallback embedding provider: %v", err)
+		}
+		fmt.Printf("Using fallback embedding provider: %s\n", helpers.GetEnvOrDefault("EMBEDDING_FALLBACK_BASE_URL", ""))
+	}
 	api.SetEmbeddingDimension(embeddingDimension)
 	mcptools.SetEmbeddingDimension(embeddingDimension)
 	fmt.Printf("Using embedding dimension: %d\n", embeddingDimension)
 
-	// Create Redis client
-	redisClient := store.CreateRedisClient(redisAddress, redisPassword)
-	defer store.CloseRedisClient(redisClient)
+	// Keep the embedding model loaded by pinging it when idle (optional, local model runners unload idle models)
+	if keepaliveMs := helpers.StringToInt(helpers.GetEnvOrDefault("EMBEDDING_KEEPALIVE_INTERVAL_MS", "0")); keepaliveMs > 0 {
+		keepaliveInterval := time.Duration(keepaliveMs) * time.Millisecond
+		go store.RunEmbeddingKeepalive(ctx, openaiClient, embeddingModelId, keepaliveInterval)
+		fmt.Printf("Pinging the embedding model when idle for %s\n", keepaliveInterval)
+	}
+
+	// Determine the maximum number of input tokens of the embedding model (from config, or from the model runner)
+	embeddingMaxTokens := embeddingModelMaxTokens(ctx, openaiClient, embeddingProvider, embeddingModelId)
+	store.RegisterEmbeddingModel(store.EmbeddingModel{
+		Name:      store.DefaultEmbeddingModelName,
+		ModelID:   embeddingModelId,
+		Dimension: embeddingDimension,
+		MaxTokens: embeddingMaxTokens,
+	})
+
+	// Warm up the additional embedding models and determine their dimension and max input tokens.
+	// The chunks are sized for all the models: the max input tokens are the ones of the most limited model.
+	for _, name := range extraModelNames {
+		modelId := extraModelIds[name]
+		e, err := store.CreateEmbeddingFromText(ctx, openaiClient, "Hello World", modelId)
+		if err != nil {
+			log.Fatalf("Failed to create test embedding with embedding model %s (%s): %v", name, modelId, err)
+		}
+		model := store.EmbeddingModel{
+			Name:      name,
+			ModelID:   modelId,
+			Dimension: len(e),
+			MaxTokens: embeddingModelMaxTokens(ctx, openaiClient, embeddingProvider, modelId),
+		}
+		store.RegisterEmbeddingModel(model)
+		embeddingMaxTokens = min(embeddingMaxTokens, model.MaxTokens)
+		fmt.Printf("Using embedding model %s: %s (dimension %d, max input tokens %d)\n", name, modelId, model.Dimension, model.MaxTokens)
+	}
+	api.SetEmbeddingMaxTokens(embeddingMaxTokens)
+	mcptools.SetEmbeddingMaxTokens(embeddingMaxTokens)
+	fmt.Printf("Using embedding max input tokens: %d\n", embeddingMaxTokens)
+
+	// Tokenizer of the embedding model (optional): the chunks are sized with the real token counts instead of an estimation
+	tokenizerKind := strings.ToLower(helpers.GetEnvOrDefault("TOKENIZER", tokenizerEstimate))
+	if tokenizerKind != tokenizerEstimate {
+		tokenizer, err := loadTokenizer(tokenizerKind, helpers.GetEnvOrDefault("TOKENIZER_PATH", ""))
+		if err != nil {
+			log.Fatalf("Invalid TOKENIZER: %v", err)
+		}
+		splitter.SetTokenizer(tokenizer)
+	}
+	fmt.Printf("Using tokenizer: %s\n", tokenizerKind)
+
+	// Number of chunks embedded by a single embedding request
+	embeddingBatchSize := helpers.StringToInt(helpers.GetEnvOrDefault("EMBEDDING_BATCH_SIZE", strconv.Itoa(store.DefaultEmbeddingBatchSize)))
+	store.SetEmbeddingBatchSize(embeddingBatchSize)
+	fmt.Printf("Using embedding batch size: %d\n", store.GetEmbeddingBatchSize())
+
+	// Encrypt the content and metadata at rest (optional, the key is provided directly or in a file, e.g. a KMS managed secret)
+	encryptionKey := helpers.GetEnvOrDefault("ENCRYPTION_KEY", "")
+	if encryptionKeyFile := helpers.GetEnvOrDefault("ENCRYPTION_KEY_FILE", ""); encryptionKey == "" && encryptionKeyFile != "" {
+		keyFileContent, err := os.ReadFile(encryptionKeyFile)
+		if err != nil {
+			log.Fatalf("Failed to read ENCRYPTION_KEY_FILE: %v", err)
+		}
+		encryptionKey = string(keyFileContent)
+	}
+	if encryptionKey != "" {
+		key, err := store.ParseEncryptionKey(encryptionKey)
+		if err != nil {
+			log.Fatalf("Invalid encryption key: %v", err)
+		}
+		if err := store.SetEncryptionKey(key); err != nil {
+			log.Fatalf("Failed to enable encryption: %v", err)
+		}
+		fmt.Printf("Encrypting content and metadata at rest (AES-%d-GCM), full-text search on content is disabled\n", len(key)*8)
+	}
+
+	// Default splitting strategy and options of each file type, a JSON object provided directly or in a file
+	splitterConfig := helpers.GetEnvOrDefault("SPLITTER_CONFIG", "")
+	if splitterConfigFile := helpers.GetEnvOrDefault("SPLITTER_CONFIG_FILE", ""); splitterConfig == "" && splitterConfigFile != "" {
+		configFileContent, err := os.ReadFile(splitterConfigFile)
+		if err != nil {
+			log.Fatalf("Failed to read SPLITTER_CONFIG_FILE: %v", err)
+		}
+		splitterConfig = string(configFileContent)
+	}
+	fileTypeConfigs, err := splitter.ParseFileTypeConfigs(splitterConfig)
+	if err != nil {
+		log.Fatalf("Invalid splitter configuration: %v", err)
+	}
+	splitter.SetFileTypeConfigs(fileTypeConfigs)
+	if len(fileTypeConfigs) > 0 {
+		fmt.Printf("Using the splitting strategies of %d file types\n", len(fileTypeConfigs))
+	}
 
-	// Check if index exists, create it if not
-	exists, err := store.IndexExists(ctx, redisClient, redisIndexName)
+	// The filterable metadata fields are stored in clear (to be indexed), they cannot be combined with encryption
+	metadataFields, err := store.ParseMetadataFields(helpers.GetEnvOrDefault("METADATA_FIELDS", ""))
 	if err != nil {
-		fmt.Printf("Error checking index: %v\n", err)
-		return
+		log.Fatalf("Invalid METADATA_FIELDS: %v", err)
+	}
+	if len(metadataFields) > 0 && store.EncryptionEnabled() {
+		log.Fatalf("METADATA_FIELDS cannot be used with ENCRYPTION_KEY: the filterable metadata values would be stored in clear")
+	}
+	store.SetMetadataFields(metadataFields)
+
+	// Layout of the documents: VectorMind's, or the one of a LangChain Redis vector store searched and written in place
+	schema := store.SchemaConfig{
+		Name:           helpers.GetEnvOrDefault("REDIS_SCHEMA", store.SchemaVectorMind),
+		LangChainIndex: helpers.GetEnvOrDefault("LANGCHAIN_INDEX_NAME", ""),
+		ContentField:   helpers.GetEnvOrDefault("LANGCHAIN_CONTENT_FIELD", ""),
+		VectorField:    helpers.GetEnvOrDefault("LANGCHAIN_VECTOR_FIELD", ""),
+	}
+	if err := store.SetSchema(schema); err != nil {
+		log.Fatalf("Invalid REDIS_SCHEMA: %v", err)
+	}
+	if schema.Name == store.SchemaLangChain {
+		if schema.LangChainIndex == redisIndexName {
+			log.Fatalf("REDIS_INDEX_NAME must be another index than the LangChain index %s (both index the same documents)", schema.LangChainIndex)
+		}
+		if store.EncryptionEnabled() {
+			log.Fatalf("REDIS_SCHEMA=%s cannot be used with ENCRYPTION_KEY: LangChain reads the content in clear", store.SchemaLangChain)
+		}
+		if len(redisTenants) > 0 {
+			log.Fatalf("REDIS_SCHEMA=%s cannot be used with REDIS_TENANTS: LangChain only reads the doc:%s:* keys", store.SchemaLangChain, schema.LangChainIndex)
+		}
+		fmt.Printf("Using the documents of the LangChain index %s (doc:%s:* keys)\n", schema.LangChainIndex, schema.LangChainIndex)
+	}
+
+	// Versions of the documents (kept on update, deleted documents marked inactive)
+	if helpers.StringToBool(helpers.GetEnvOrDefault("DOCUMENT_VERSIONING", "false")) {
+		if schema.Name == store.SchemaLangChain {
+			log.Fatalf("DOCUMENT_VERSIONING cannot be used with REDIS_SCHEMA=%s: LangChain would search the previous versions", store.SchemaLangChain)
+		}
+		store.SetVersioning(true)
+		fmt.Println("Document versioning enabled")
+	}
+
+	// Boost of the section titles and hierarchies in the keyword part of the hybrid search
+	fieldWeights, err := store.ParseFieldWeights(helpers.GetEnvOrDefault("HYBRID_FIELD_WEIGHTS", ""))
+	if err != nil {
+		log.Fatalf("Invalid HYBRID_FIELD_WEIGHTS: %v", err)
+	}
+	store.SetFieldWeights(fieldWeights)
+
+	// The pages of the private networks cannot be fetched by default (the server must not be a proxy to them)
+	store.SetFetchPrivateNetworks(helpers.StringToBool(helpers.GetEnvOrDefault("FETCH_ALLOW_PRIVATE_NETWORKS", "false")))
+
+	// GitHub API of the repository ingestion (a token gives access to the private repositories)
+	githubTokenRepos, err := store.ParseGitHubTokenRepos(helpers.GetEnvOrDefault("GITHUB_TOKEN_REPOS", ""))
+	if err != nil {
+		log.Fatalf("Invalid GITHUB_TOKEN_REPOS: %v", err)
+	}
+	if helpers.GetEnvOrDefault("GITHUB_TOKEN", "") != "" && len(githubTokenRepos) == 0 {
+		fmt.Printf("⚠️  GITHUB_TOKEN is set without GITHUB_TOKEN_REPOS: the token is never sent\n")
 	}
+	store.SetGitHubAPI(helpers.GetEnvOrDefault("GITHUB_API_URL", ""), helpers.GetEnvOrDefault("GITHUB_TOKEN", ""), githubTokenRepos)
 
-	if !exists {
-		fmt.Printf("Index '%s' does not exist, creating it...\n", redisIndexName)
-		err = store.CreateEmbeddingIndex(ctx, redisClient, redisIndexName, embeddingDimension)
+	// Create the Redis client, shared by the main index and the indexes of the tenants
+	redisRouter := store.NewRedisRouter(redisAddress, redisPassword, redisDB, redisIndexName, redisTenants)
+	defer redisRouter.Close()
+	redisClient := redisRouter.DefaultClient()
+
+	// Read replicas serving the searches (optional, the writes always go to the primary)
+	replicaAddresses, err := store.ParseReplicaAddresses(helpers.GetEnvOrDefault("REDIS_REPLICA_ADDRESSES", ""))
+	if err != nil {
+		log.Fatalf("Invalid REDIS_REPLICA_ADDRESSES: %v", err)
+	}
+	if len(replicaAddresses) > 0 {
+		redisRouter.SetReplicas(replicaAddresses, helpers.GetEnvOrDefault("REDIS_REPLICA_PASSWORD", redisPassword))
+		redisRouter.CheckReplicas(ctx)
+		go redisRouter.MonitorReplicas(ctx, time.Duration(helpers.StringToInt(helpers.GetEnvOrDefault("REDIS_REPLICA_CHECK_INTERVAL_MS", "5000")))*time.Millisecond)
+		mcptools.SetSearchClient(redisRouter.SearchClient)
+		fmt.Printf("Searches routed to %d Redis replicas: %s\n", len(replicaAddresses), strings.Join(replicaAddresses, ", "))
+	}
+
+	// The usage totals are shared by the instances and kept across restarts
+	store.SetUsageClient(redisClient)
+
+	// Check if the main index and the index of each tenant exist, create them if not
+	for _, indexName := range redisRouter.IndexNames() {
+		exists, err := store.IndexExists(ctx, redisClient, indexName)
 		if err != nil {
-			fmt.Printf("Error creating index: %v\n", err)
+			fmt.Printf("Error checking index '%s': %v\n", indexName, err)
 			return
 		}
-		fmt.Printf("Index '%s' created successfully\n", redisIndexName)
+
+		if !exists {
+			fmt.Printf("Index '%s' does not exist, creating it (%s)...\n", indexName, strings.ToUpper(indexOptions.Type))
+			err = store.CreateEmbeddingIndexWithOptions(ctx, redisClient, indexName, embeddingDimension, indexOptions)
+			if err != nil {
+				fmt.Printf("Error creating index: %v\n", err)
+				return
+			}
+			fmt.Printf("Index '%s' created successfully\n", indexName)
+			events.Record(events.TypeIndexCreated, fmt.Sprintf("Index %s created", indexName), map[string]any{
+				"index":     indexName,
+				"dimension": embeddingDimension,
+				"type":      strings.ToUpper(indexOptions.Type),
+			})
+		} else {
+			fmt.Printf("Index '%s' already exists\n", indexName)
+			verifyIndexDimensions(ctx, openaiClient, redisClient, embeddingModelId, indexName, embeddingDimension, indexOptions, *migrate)
+		}
+	}
+
+	// Archive the original documents (optional)
+	originalsArchive, err := archive.New(ctx, archive.Config{
+		Backend:     helpers.GetEnvOrDefault("ARCHIVE_BACKEND", ""),
+		Dir:         helpers.GetEnvOrDefault("ARCHIVE_DIR", "./originals"),
+		S3Endpoint:  helpers.GetEnvOrDefault("ARCHIVE_S3_ENDPOINT", ""),
+		S3Bucket:    helpers.GetEnvOrDefault("ARCHIVE_S3_BUCKET", "vectormind"),
+		S3AccessKey: helpers.GetEnvOrDefault("ARCHIVE_S3_ACCESS_KEY", ""),
+		S3SecretKey: helpers.GetEnvOrDefault("ARCHIVE_S3_SECRET_KEY", ""),
+		S3UseSSL:    helpers.StringToBool(helpers.GetEnvOrDefault("ARCHIVE_S3_USE_SSL", "false")),
+		S3Prefix:    helpers.GetEnvOrDefault("ARCHIVE_S3_PREFIX", "originals/"),
+	})
+	if err != nil {
+		log.Fatalf("Failed to create the original documents archive: %v", err)
+	}
+	if originalsArchive != nil {
+		store.SetArchive(originalsArchive)
+		fmt.Printf("Archiving original documents (%s backend)\n", helpers.GetEnvOrDefault("ARCHIVE_BACKEND", ""))
+	}
+
+	// Read the text of the images of the Office documents (optional)
+	ocrEngine, err := ocr.New(ocr.Config{
+		Backend:       helpers.GetEnvOrDefault("OCR_BACKEND", ""),
+		TesseractPath: helpers.GetEnvOrDefault("OCR_TESSERACT_PATH", "tesseract"),
+		Languages:     helpers.GetEnvOrDefault("OCR_LANGUAGES", ""),
+		APIURL:        helpers.GetEnvOrDefault("OCR_API_URL", ""),
+		APIKey:        helpers.GetEnvOrDefault("OCR_API_KEY", ""),
+		Timeout:       time.Duration(helpers.StringToInt(helpers.GetEnvOrDefault("OCR_TIMEOUT_SECONDS", "60"))) * time.Second,
+	})
+	if err != nil {
+		log.Fatalf("Failed to create the OCR engine: %v", err)
+	}
+	if err := store.SetOCR(ocrEngine, helpers.StringToInt(helpers.GetEnvOrDefault("OCR_MAX_IMAGES", strconv.Itoa(store.DefaultOCRMaxImages)))); err != nil {
+		log.Fatalf("Invalid OCR_MAX_IMAGES: %v", err)
+	}
+	if ocrEngine != nil {
+		fmt.Printf("Reading the images of the Office documents with OCR (%s backend)\n", helpers.GetEnvOrDefault("OCR_BACKEND", ""))
+	}
+
+	// Transforms of the ingested chunks (optional), registered by the packages imported by the build
+	if err := store.SetIngestTransforms(helpers.GetEnvOrDefault("INGEST_TRANSFORMS", "")); err != nil {
+		log.Fatalf("Invalid INGEST_TRANSFORMS: %v", err)
+	}
+
+	// Keep the markdown and text files of local folders indexed (optional)
+	watchDirs, err := store.ParseWatchDirs(helpers.GetEnvOrDefault("WATCH_DIRS", ""))
+	if err != nil {
+		log.Fatalf("Invalid WATCH_DIRS: %v", err)
+	}
+	if len(watchDirs) > 0 {
+		watchOptions := store.WatchOptions{
+			Dirs:      watchDirs,
+			Interval:  time.Duration(helpers.StringToInt(helpers.GetEnvOrDefault("WATCH_INTERVAL_MS", "10000"))) * time.Millisecond,
+			Label:     helpers.GetEnvOrDefault("WATCH_LABEL", ""),
+			MaxTokens: embeddingMaxTokens,
+			IndexName: redisIndexName,
+		}
+		go store.RunDirectoryWatcher(ctx, openaiClient, redisClient, embeddingModelId, watchOptions)
+		fmt.Printf("Watching %d directories for markdown and text files: %s\n", len(watchDirs), strings.Join(watchDirs, ", "))
+	}
+
+	// Check that Redis will not silently evict the stored vectors
+	memoryInfo, err := store.GetMemoryInfo(ctx, redisClient)
+	if err != nil {
+		fmt.Printf("Unable to get Redis memory info: %v\n", err)
 	} else {
-		fmt.Printf("Index '%s' already exists\n", redisIndexName)
+		if warning := store.EvictionWarning(memoryInfo); warning != "" {
+			fmt.Printf("⚠️  %s\n", warning)
+		}
+		if redisMemoryWatermark.Percent > 0 && memoryInfo.MaxMemory == 0 {
+			fmt.Printf("⚠️  REDIS_MEMORY_WATERMARK is a percentage but Redis has no maxmemory: writes are never refused\n")
+		}
+	}
+	memoryGuard := store.NewMemoryGuard(redisClient, redisMemoryWatermark)
+
+	// Soft quotas: the writes approaching them (or the memory watermark) return warnings, without being refused
+	if err := store.SetQuotas(store.Quotas{
+		MaxDocuments:      helpers.StringToInt(helpers.GetEnvOrDefault("QUOTA_MAX_DOCUMENTS", "0")),
+		MaxLabelDocuments: helpers.StringToInt(helpers.GetEnvOrDefault("QUOTA_MAX_LABEL_DOCUMENTS", "0")),
+		WarningRatio:      helpers.StringToFloat(helpers.GetEnvOrDefault("QUOTA_WARNING_RATIO", strconv.FormatFloat(store.DefaultQuotaWarningRatio, 'f', -1, 64))),
+	}); err != nil {
+		log.Fatalf("Invalid quotas: %v", err)
+	}
+
+	// Limit the concurrent requests per endpoint class, so that ingestion bursts cannot starve the searches
+	concurrencyMaxWait := time.Duration(helpers.StringToInt(helpers.GetEnvOrDefault("CONCURRENCY_MAX_WAIT_MS", "30000"))) * time.Millisecond
+	searchLimiter := helpers.NewConcurrencyLimiter("search", helpers.StringToInt(helpers.GetEnvOrDefault("SEARCH_MAX_CONCURRENCY", "0")), concurrencyMaxWait)
+	ingestLimiter := helpers.NewConcurrencyLimiter("ingest", helpers.StringToInt(helpers.GetEnvOrDefault("INGEST_MAX_CONCURRENCY", "0")), concurrencyMaxWait)
+	// Near their limit, the responses carry a load hint so that the callers slow down before being refused
+	loadHintPressure := helpers.StringToFloat(helpers.GetEnvOrDefault("LOAD_HINT_PRESSURE", strconv.FormatFloat(helpers.DefaultPressureThreshold, 'f', -1, 64)))
+	searchLimiter.SetPressureThreshold(loadHintPressure)
+	ingestLimiter.SetPressureThreshold(loadHintPressure)
+	fmt.Printf("Concurrency limits: %d ingest requests, %d search requests (0 means no limit)\n", ingestLimiter.Limit(), searchLimiter.Limit())
+	// The ingestion jobs (async requests) run after their response, they have their own slots and check the memory
+	if err := store.SetIngestionJobLimits(helpers.StringToInt(helpers.GetEnvOrDefault("INGESTION_JOB_MAX_CONCURRENCY", strconv.Itoa(store.DefaultMaxIngestionJobs))), memoryGuard); err != nil {
+		log.Fatalf("Invalid INGESTION_JOB_MAX_CONCURRENCY: %v", err)
+	}
+
+	// Interval of the progress lines of the bulk ingestion responses
+	api.SetBulkProgressInterval(time.Duration(helpers.StringToInt(helpers.GetEnvOrDefault("BULK_PROGRESS_INTERVAL_MS", "5000"))) * time.Millisecond)
+
+	// Time the results of the ingestion requests with an idempotency key are kept
+	api.SetIdempotencyTTL(time.Duration(helpers.StringToInt(helpers.GetEnvOrDefault("IDEMPOTENCY_TTL_SECONDS", "86400"))) * time.Second)
+
+	// Handling of the documents created with the ID of an existing document (when the request does not choose)
+	if err := store.SetDefaultIDConflict(helpers.GetEnvOrDefault("DOCUMENT_ID_CONFLICT", store.IDConflictError)); err != nil {
+		log.Fatalf("Invalid DOCUMENT_ID_CONFLICT: %v", err)
+	}
+	if err := store.SetDocumentIDGenerator(helpers.GetEnvOrDefault("DOCUMENT_ID_GENERATOR", store.IDGeneratorUUID),
+		helpers.StringToInt(helpers.GetEnvOrDefault("DOCUMENT_ID_NODE", "0"))); err != nil {
+		log.Fatalf("Invalid DOCUMENT_ID_GENERATOR: %v", err)
+	}
+
+	// Number of document changes kept by collection for the watchers (0 disables the change feed)
+	if err := store.SetChangeFeedLength(helpers.StringToInt(helpers.GetEnvOrDefault("CHANGE_FEED_LENGTH", strconv.Itoa(store.DefaultChangeFeedLength)))); err != nil {
+		log.Fatalf("Invalid CHANGE_FEED_LENGTH: %v", err)
 	}
+	// The watchers wait for the changes on their own connections, a watch beyond the limit is refused
+	maxWatchers := helpers.StringToInt(helpers.GetEnvOrDefault("WATCH_MAX_CONCURRENCY", strconv.Itoa(store.DefaultMaxWatchers)))
+	if maxWatchers <= 0 {
+		log.Fatalf("Invalid WATCH_MAX_CONCURRENCY: %d (expected a number of watchers > 0)", maxWatchers)
+	}
+	watchLimiter := helpers.NewConcurrencyLimiter("watch", maxWatchers, 0)
+	watchClient := store.NewWatchClient(redisClient, maxWatchers)
+	defer store.CloseRedisClient(watchClient)
+	store.SetWatchClient(watchClient)
 
 	// Create MCP server
 	mcpServer := server.NewMCPServer(
 		"mcp-vectormind",
-		"0.0.0",
+		version,
+		server.WithToolHandlerMiddleware(mcptools.RequestIDMiddleware()),
+		server.WithToolHandlerMiddleware(mcptools.TenantMiddleware(redisRouter)),
+		server.WithToolHandlerMiddleware(mcptools.MemoryGuardMiddleware(memoryGuard)),
+		server.WithToolHandlerMiddleware(mcptools.QuotaWarningsMiddleware(memoryGuard, redisClient, redisIndexName)),
+		server.WithToolHandlerMiddleware(mcptools.ConcurrencyLimitMiddleware(searchLimiter, ingestLimiter)),
+		server.WithToolHandlerMiddleware(mcptools.FeatureFlagsMiddleware()),
+		server.WithToolFilter(mcptools.FeatureFlagsToolFilter),
 	)
 
 	// Register MCP tools
@@ -85,43 +575,208 @@ func main() {
 	// Add healthcheck endpoint
 	apiMux.HandleFunc("/health", api.HealthCheckHandler)
 
+	// Add version endpoint (build information)
+	apiMux.HandleFunc("/version", api.VersionHandler)
+
+	// Add feature flags endpoint (the experimental features enabled by FEATURE_FLAGS)
+	apiMux.HandleFunc("/admin/flags", api.FeatureFlagsHandler)
+
 	// Add embedding model info endpoint
-	apiMux.HandleFunc("/embedding-model-info", api.GetEmbeddingModelInfoHandler)
+	apiMux.HandleFunc("/embedding-model-info", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.GetEmbeddingModelInfoHandler(w, r, ctx, redisClient, redisIndexName)
+	}))
 
 	// Add create embedding endpoint
-	apiMux.HandleFunc("/embeddings", func(w http.ResponseWriter, r *http.Request) {
-		api.CreateEmbeddingHandler(w, r, ctx, &openaiClient, redisClient, embeddingModelId, redisIndexName)
-	})
+	apiMux.HandleFunc("/embeddings", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.CreateEmbeddingHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	}))))))
+
+	// Add bulk (NDJSON) create embeddings endpoint
+	apiMux.HandleFunc("/embeddings/bulk", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.BulkCreateEmbeddingsHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	})))))
+
+	// Add import endpoint (exports of LangChain, LlamaIndex and the registered importers)
+	apiMux.HandleFunc("/import", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.ImportHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	})))))
 
 	// Add similarity search endpoint
-	apiMux.HandleFunc("/search", func(w http.ResponseWriter, r *http.Request) {
-		api.SimilaritySearchHandler(w, r, ctx, &openaiClient, redisClient, embeddingModelId, redisIndexName)
-	})
+	apiMux.HandleFunc("/search", api.WithConcurrencyLimit(searchLimiter, api.WithTenantSearchClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.SimilaritySearchHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	})))
 
 	// Add similarity search with label endpoint
-	apiMux.HandleFunc("/search_with_label", func(w http.ResponseWriter, r *http.Request) {
-		api.SimilaritySearchWithLabelHandler(w, r, ctx, &openaiClient, redisClient, embeddingModelId, redisIndexName)
-	})
+	apiMux.HandleFunc("/search_with_label", api.WithConcurrencyLimit(searchLimiter, api.WithTenantSearchClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.SimilaritySearchWithLabelHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	})))
+
+	// Add similarity search with several labels endpoint
+	apiMux.HandleFunc("/search_with_labels", api.WithConcurrencyLimit(searchLimiter, api.WithTenantSearchClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.SimilaritySearchWithLabelsHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	})))
+
+	// Add hybrid (full-text and vector) search endpoint
+	apiMux.HandleFunc("/hybrid-search", api.WithConcurrencyLimit(searchLimiter, api.WithTenantSearchClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.HybridSearchHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	})))
+
+	// Add question answering endpoint (retrieved chunks and chat model)
+	apiMux.HandleFunc("/ask", api.WithConcurrencyLimit(searchLimiter, api.WithTenantSearchClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.AskHandler(w, r, &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	})))
 
 	// Add chunk and store endpoint
-	apiMux.HandleFunc("/chunk-and-store", func(w http.ResponseWriter, r *http.Request) {
-		api.ChunkAndStoreHandler(w, r, ctx, &openaiClient, redisClient, embeddingModelId, redisIndexName)
-	})
+	apiMux.HandleFunc("/chunk-and-store", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.ChunkAndStoreHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	}))))))
+
+	// Add recursive chunk and store endpoint (paragraph, sentence and word boundaries)
+	apiMux.HandleFunc("/recursive-chunk-and-store", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.RecursiveChunkAndStoreHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	}))))))
+
+	// Add semantic chunk and store endpoint (groups of related sentences)
+	apiMux.HandleFunc("/semantic-chunk-and-store", api.WithFeature(features.SemanticChunking, api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.SemanticChunkAndStoreHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	})))))))
 
 	// Add split and store markdown sections endpoint
-	apiMux.HandleFunc("/split-and-store-markdown-sections", func(w http.ResponseWriter, r *http.Request) {
-		api.SplitAndStoreMarkdownSectionsHandler(w, r, ctx, &openaiClient, redisClient, embeddingModelId, redisIndexName)
-	})
+	apiMux.HandleFunc("/split-and-store-markdown-sections", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.SplitAndStoreMarkdownSectionsHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	}))))))
 
 	// Add split and store with delimiter endpoint
-	apiMux.HandleFunc("/split-and-store-with-delimiter", func(w http.ResponseWriter, r *http.Request) {
-		api.SplitAndStoreWithDelimiterHandler(w, r, ctx, &openaiClient, redisClient, embeddingModelId, redisIndexName)
-	})
+	apiMux.HandleFunc("/split-and-store-with-delimiter", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.SplitAndStoreWithDelimiterHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	}))))))
 
 	// Add split and store markdown with hierarchy endpoint
-	apiMux.HandleFunc("/split-and-store-markdown-with-hierarchy", func(w http.ResponseWriter, r *http.Request) {
-		api.SplitAndStoreMarkdownWithHierarchyHandler(w, r, ctx, &openaiClient, redisClient, embeddingModelId, redisIndexName)
-	})
+	apiMux.HandleFunc("/split-and-store-markdown-with-hierarchy", api.WithFeature(features.HierarchySplitting, api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.SplitAndStoreMarkdownWithHierarchyHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	})))))))
+
+	// Add generic split and store endpoint (strategy from the splitter registry)
+	apiMux.HandleFunc("/split-and-store", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.SplitAndStoreHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	}))))))
+
+	// Add chunk preview endpoint (dry run of /split-and-store: no embeddings, nothing stored)
+	apiMux.HandleFunc("/chunk-preview", api.ChunkPreviewHandler)
+
+	// Add split and store email archive endpoint (.eml messages and mbox archives)
+	apiMux.HandleFunc("/split-and-store-email", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.SplitAndStoreEmailHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	}))))))
+
+	// Add split and store Office document endpoint (docx and pptx)
+	apiMux.HandleFunc("/split-and-store-office", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.SplitAndStoreOfficeHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	}))))))
+
+	// Add split and store HTML page endpoint (boilerplate removed)
+	apiMux.HandleFunc("/split-and-store-html", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.SplitAndStoreHTMLHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	}))))))
+
+	// Add fetch and store web page endpoint (the URL is the source of the chunks)
+	apiMux.HandleFunc("/fetch-and-store-url", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.FetchAndStoreURLHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	}))))))
+
+	// Add GitHub repository ingestion endpoint (markdown, documentation and code files)
+	apiMux.HandleFunc("/ingest/github", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.IngestGitHubHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	}))))))
+
+	// Add split and store subtitles endpoint (SRT and WebVTT)
+	apiMux.HandleFunc("/split-and-store-subtitles", api.WithConcurrencyLimit(ingestLimiter, api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, api.WithIdempotency(func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.SplitAndStoreSubtitlesHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName)
+	}))))))
+
+	// Add quality report endpoint
+	apiMux.HandleFunc("/quality-report", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.QualityReportHandler(w, r, ctx, redisClient, redisIndexName)
+	}))
+
+	// Add ingestion job endpoint (progress of the asynchronous chunk and store requests)
+	apiMux.HandleFunc("/jobs/{id}", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.IngestionJobHandler(w, r, redisIndexName)
+	}))
+
+	// Add document endpoints (get, update and delete a document, list and restore its versions, get an original
+	// document, bulk delete, delete by label or parent ID)
+	apiMux.HandleFunc("/documents/{id}", api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, api.WithQuotaWarnings(memoryGuard, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.DocumentHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId)
+	}))))
+	apiMux.HandleFunc("/documents/{id}/versions", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.DocumentVersionsHandler(w, r, ctx, redisClient)
+	}))
+	apiMux.HandleFunc("/documents/{id}/versions/{version}/restore", api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.RestoreVersionHandler(w, r, ctx, redisClient)
+	})))
+	apiMux.HandleFunc("/documents/{source_id}/original", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.OriginalDocumentHandler(w, r, ctx, redisIndexName)
+	}))
+	apiMux.HandleFunc("/documents", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.DeleteDocumentsHandler(w, r, ctx, redisClient, redisIndexName)
+	}))
+
+	// Add label endpoints (rename a label, change the labels of the documents matching a filter)
+	apiMux.HandleFunc("/labels/rename", api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.RenameLabelHandler(w, r, ctx, redisClient, redisIndexName)
+	})))
+	apiMux.HandleFunc("/documents/retag", api.WithMemoryGuard(memoryGuard, api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.RetagDocumentsHandler(w, r, ctx, redisClient, redisIndexName)
+	})))
+
+	// Add collection endpoints (list and create collections, delete a collection, watch the changes of a collection)
+	apiMux.HandleFunc("/collections", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.CollectionsHandler(w, r, ctx, redisClient, redisIndexName, indexOptions)
+	}))
+	apiMux.HandleFunc("/collections/{name}", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.DeleteCollectionHandler(w, r, ctx, redisClient, redisIndexName)
+	}))
+	apiMux.HandleFunc("/collections/{name}/watch", api.WithConcurrencyLimit(watchLimiter, api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.WatchCollectionHandler(w, r, redisClient, redisIndexName)
+	})))
+
+	// Add index management endpoints (index information, rebuild, reset, re-embedding and dimension check of the index)
+	apiMux.HandleFunc("/index/info", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.IndexInfoHandler(w, r, ctx, redisClient, redisIndexName, memoryGuard)
+	}))
+	apiMux.HandleFunc("/index/rebuild", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.RebuildIndexHandler(w, r, ctx, redisClient, redisIndexName, indexOptions)
+	}))
+	apiMux.HandleFunc("/index", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.DeleteIndexHandler(w, r, ctx, redisClient, redisIndexName, indexOptions)
+	}))
+	apiMux.HandleFunc("/index/reembed", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.ReembedHandler(w, r, api.UsageContext(ctx, r), &openaiClient, redisClient, embeddingModelId, redisIndexName, indexOptions)
+	}))
+
+	apiMux.HandleFunc("/index/dimensions", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.IndexDimensionsHandler(w, r, ctx, redisClient, redisIndexName)
+	}))
+
+	// Add index repair endpoint (rebuild of a corrupted index, the documents are indexed again without re-embedding)
+	apiMux.HandleFunc("/admin/index/repair", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.IndexRepairHandler(w, r, ctx, redisClient, redisIndexName, indexOptions)
+	}))
+
+	// Add events endpoint (recent structured events of the server)
+	apiMux.HandleFunc("/events", api.EventsHandler)
+
+	// Add stats endpoints (documents per label and memory usage, export of the usage of the embedding providers)
+	apiMux.HandleFunc("/stats", api.WithTenantRedisClient(redisRouter, func(w http.ResponseWriter, r *http.Request, redisClient *redis.Client, redisIndexName string) {
+		api.StatsHandler(w, r, ctx, redisClient, redisIndexName, memoryGuard)
+	}))
+	apiMux.HandleFunc("/stats/usage.csv", api.UsageCSVHandler)
+
+	// Filter the clients of the REST API and MCP listeners by IP address
+	trustedProxies := parseCIDRListEnv("TRUSTED_PROXIES")
+	apiIPFilter := api.NewIPFilter(parseCIDRListEnv("API_ALLOW_CIDRS"), parseCIDRListEnv("API_DENY_CIDRS"), trustedProxies)
+	mcpIPFilter := api.NewIPFilter(parseCIDRListEnv("MCP_ALLOW_CIDRS"), parseCIDRListEnv("MCP_DENY_CIDRS"), trustedProxies)
 
 	// Create MCP mux
 	mcpMux := http.NewServeMux()
@@ -129,18 +784,183 @@ func main() {
 	// Add MCP endpoint
 	httpServer := server.NewStreamableHTTPServer(mcpServer,
 		server.WithEndpointPath("/mcp"),
+		server.WithHTTPContextFunc(func(ctx context.Context, r *http.Request) context.Context {
+			return api.TenantContext(api.RequestIDContext(ctx, r), r)
+		}),
 	)
 	mcpMux.Handle("/mcp", httpServer)
 
+	events.Record(events.TypeServerStarted, "VectorMind started", map[string]any{
+		"model":     embeddingModelId,
+		"dimension": embeddingDimension,
+		"index":     redisIndexName,
+		"version":   version,
+		"commit":    commit,
+	})
+
+	// Chaos mode (testing only): failures injected into the embedding requests, the Redis commands and the response
+	// times, once the startup is done so that it does not fail
+	if helpers.StringToBool(helpers.GetEnvOrDefault("CHAOS_MODE", "false")) {
+		chaosConfig := store.ChaosConfig{
+			EmbeddingTimeoutRate: helpers.StringToFloat(helpers.GetEnvOrDefault("CHAOS_EMBEDDING_TIMEOUT_RATE", "0")),
+			RedisErrorRate:       helpers.StringToFloat(helpers.GetEnvOrDefault("CHAOS_REDIS_ERROR_RATE", "0")),
+			SlowResponseRate:     helpers.StringToFloat(helpers.GetEnvOrDefault("CHAOS_SLOW_RESPONSE_RATE", "0")),
+			Delay:                time.Duration(helpers.StringToInt(helpers.GetEnvOrDefault("CHAOS_DELAY_MS", strconv.Itoa(int(store.DefaultChaosDelay.Milliseconds()))))) * time.Millisecond,
+		}
+		if err := store.SetChaos(chaosConfig); err != nil {
+			log.Fatalf("Invalid chaos mode settings: %v", err)
+		}
+		log.Printf("🔥 Chaos mode enabled: embedding timeouts %g, Redis errors %g, slow responses %g (delay %s)",
+			chaosConfig.EmbeddingTimeoutRate, chaosConfig.RedisErrorRate, chaosConfig.SlowResponseRate, chaosConfig.Delay)
+	}
+
 	// Start REST API server in a goroutine
 	go func() {
 		log.Println("REST API Server is running on port", apiRestPort)
-		if err := http.ListenAndServe(":"+apiRestPort, apiMux); err != nil {
+		if err := http.ListenAndServe(":"+apiRestPort, api.WithRequestID(api.WithIPFilter(apiIPFilter, api.WithChaos(apiMux)))); err != nil {
 			log.Fatal("REST API Server error:", err)
 		}
 	}()
 
 	// Start MCP server on main thread
 	log.Println("MCP Server is running on port", mcpHttpPort)
-	log.Fatal(http.ListenAndServe(":"+mcpHttpPort, mcpMux))
+	log.Fatal(http.ListenAndServe(":"+mcpHttpPort, api.WithIPFilter(mcpIPFilter, api.WithChaos(mcpMux))))
+}
+
+// reportDimensionMismatches lists the documents of a collection embedded with a model of another dimension than its index
+// (they are not indexed, so missing from the search results). Only the indexes with indexing failures are checked.
+func reportDimensionMismatches(ctx context.Context, redisClient *redis.Client, collection store.Collection) {
+	info, err := store.GetIndexInfo(ctx, redisClient, collection.IndexName)
+	if err != nil || info.IndexingFailures == 0 {
+		return
+	}
+	check, err := store.CheckDocumentDimensions(ctx, redisClient, collection, 10)
+	if err != nil {
+		fmt.Printf("Unable to check the dimension of the documents of index '%s': %v\n", collection.IndexName, err)
+		return
+	}
+	if check.Mismatched == 0 {
+		return
+	}
+	ids := make([]string, len(check.Documents))
+	for i, document := range check.Documents {
+		ids[i] = fmt.Sprintf("%s (%d)", document.ID, document.Dimension)
+	}
+	fmt.Printf("Warning: %d documents of index '%s' have vectors of another dimension than the index (%d) and are not searchable, e.g. %s. Re-embed them with POST /index/reembed\n",
+		check.Mismatched, collection.IndexName, check.Dimension, strings.Join(ids, ", "))
+}
+
+// Tokenizers of the embedding models (TOKENIZER)
+const (
+	tokenizerEstimate  = "estimate"
+	tokenizerTiktoken  = "tiktoken"
+	tokenizerWordPiece = "wordpiece"
+)
+
+// loadTokenizer loads the tokenizer of the embedding model: a tiktoken file (OpenAI models) or the vocab.txt
+// of a BERT model (most of the open embedding models)
+func loadTokenizer(kind, path string) (splitter.Tokenizer, error) {
+	if path == "" {
+		return nil, fmt.Errorf("TOKENIZER_PATH is required with the %s tokenizer", kind)
+	}
+	switch kind {
+	case tokenizerTiktoken:
+		return splitter.LoadBPETokenizer(path)
+	case tokenizerWordPiece:
+		return embeddings.LoadWordPieceTokenizer(path, embeddings.DefaultLocalMaxLength)
+	default:
+		return nil, fmt.Errorf("unknown tokenizer %q (use %s, %s or %s)", kind, tokenizerEstimate, tokenizerTiktoken, tokenizerWordPiece)
+	}
+}
+
+// embeddingModelMaxTokens returns the maximum number of input tokens of an embedding model
+// (EMBEDDING_MAX_TOKENS, or the value exposed by the model runner, or the default value)
+func embeddingModelMaxTokens(ctx context.Context, openaiClient openai.Client, embeddingProvider, embeddingModelId string) int {
+	maxTokens := helpers.StringToInt(helpers.GetEnvOrDefault("EMBEDDING_MAX_TOKENS", "0"))
+	if maxTokens <= 0 && embeddingProvider == embeddings.ProviderOpenAI {
+		var err error
+		maxTokens, err = store.GetEmbeddingModelMaxTokens(ctx, openaiClient, embeddingModelId)
+		if err != nil {
+			fmt.Printf("Unable to get the max input tokens of embedding model %s: %v\n", embeddingModelId, err)
+		}
+	}
+	if maxTokens <= 0 {
+		maxTokens = defaultEmbeddingMaxTokens
+		fmt.Printf("Max input tokens of embedding model %s unknown, using default value\n", embeddingModelId)
+	}
+	return maxTokens
+}
+
+// parseCIDRListEnv parses the list of CIDR ranges of an environment variable (empty when not set)
+func parseCIDRListEnv(key string) []netip.Prefix {
+	prefixes, err := api.ParseCIDRList(helpers.GetEnvOrDefault(key, ""))
+	if err != nil {
+		log.Fatalf("Invalid %s: %v", key, err)
+	}
+	return prefixes
+}
+
+// verifyIndexDimensions checks that a main index (of a tenant or not) and its collections have the dimension of the
+// embedding model, which changes with EMBEDDING_MODEL. On a mismatch, the stored documents are re-embedded with migrate,
+// otherwise VectorMind refuses to start.
+func verifyIndexDimensions(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, indexName string, embeddingDimension int, indexOptions store.IndexOptions, migrate bool) {
+	names, err := store.ListCollections(ctx, redisClient, indexName)
+	if err != nil {
+		log.Fatalf("Failed to list the collections of index '%s': %v", indexName, err)
+	}
+	collections := []store.Collection{store.DefaultCollection(indexName)}
+	for _, name := range names {
+		collection, err := store.ResolveCollection(ctx, redisClient, indexName, name)
+		if err != nil {
+			log.Fatalf("Failed to resolve collection %s: %v", name, err)
+		}
+		collections = append(collections, collection)
+	}
+
+	for _, collection := range collections {
+		embeddingModelId := collection.ModelID(embeddingModelId)
+		embeddingDimension := collection.Dimension(embeddingDimension)
+		err := store.VerifyIndexDimension(ctx, redisClient, collection.IndexName, embeddingDimension)
+		if err == nil {
+			// The indexes created by a previous version lack the fields added since (e.g. title and hierarchy)
+			added, err := store.AddMissingIndexFields(ctx, redisClient, collection.IndexName)
+			if err != nil {
+				log.Fatalf("Failed to update the schema of index '%s': %v", collection.IndexName, err)
+			}
+			if len(added) > 0 {
+				fmt.Printf("Added the fields %s to index '%s'\n", strings.Join(added, ", "), collection.IndexName)
+			}
+			reportDimensionMismatches(ctx, redisClient, collection)
+			continue
+		}
+		if !errors.Is(err, store.ErrDimensionMismatch) {
+			log.Fatalf("Failed to check the dimension of index '%s': %v", collection.IndexName, err)
+		}
+		events.Record(events.TypeModelChanged, fmt.Sprintf("Embedding model changed to %s, the dimension of index %s does not match", embeddingModelId, collection.IndexName), map[string]any{
+			"model":     embeddingModelId,
+			"dimension": embeddingDimension,
+			"index":     collection.IndexName,
+			"migrate":   migrate,
+		})
+		if !migrate {
+			log.Fatalf("%v. Restart with --migrate to re-embed the stored documents with %s, or use the previous EMBEDDING_MODEL", err, embeddingModelId)
+		}
+
+		fmt.Printf("%v, re-embedding the stored documents with %s...\n", err, embeddingModelId)
+		progress, err := store.MigrateIndex(ctx, openaiClient, redisClient, embeddingModelId, collection, embeddingDimension, indexOptions, func(progress store.ReembedProgress) {
+			fmt.Printf("Re-embedded %d/%d documents of index '%s' (%d failed)\n", progress.Reembedded, progress.Total, collection.IndexName, progress.Failed)
+		})
+		if err != nil {
+			log.Fatalf("Failed to migrate index '%s': %v", collection.IndexName, err)
+		}
+		fmt.Printf("Index '%s' migrated: %d documents re-embedded, %d failed\n", collection.IndexName, progress.Reembedded, progress.Failed)
+		events.Record(events.TypeJobCompleted, fmt.Sprintf("Migration of index %s completed", collection.IndexName), map[string]any{
+			"job":        "migrate",
+			"index":      collection.IndexName,
+			"model":      embeddingModelId,
+			"total":      progress.Total,
+			"reembedded": progress.Reembedded,
+			"failed":     progress.Failed,
+		})
+	}
 }
@@ -2,6 +2,8 @@ package mcptools
 
 import (
 	"context"
+	"fmt"
+	"vectormind/helpers"
 
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
@@ -10,9 +12,10 @@ import (
 // RegisterAboutTool registers the about_vectormind tool
 func RegisterAboutTool(mcpServer *server.MCPServer) {
 	aboutTool := mcp.NewTool("about_vectormind",
-		mcp.WithDescription("This tool provides information about the VectorMind MCP server."),
+		mcp.WithDescription("This tool provides information about the VectorMind MCP server: what it is, and its version, commit hash and build date."),
 	)
 	mcpServer.AddTool(aboutTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
-		return mcp.NewToolResultText("This MCP Server is a Text RAG System based on Redis"), nil
+		version, commit, buildDate := helpers.BuildInfo()
+		return mcp.NewToolResultText(fmt.Sprintf("This MCP Server is a Text RAG System based on Redis (VectorMind %s, commit %s, built %s)", version, commit, buildDate)), nil
 	})
 }
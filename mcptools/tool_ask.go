@@ -0,0 +1,99 @@
+package mcptools
+
+import (
+	"context"
+	"encoding/json"
+	"vectormind/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// RegisterAskTool registers the ask_vectormind tool
+func RegisterAskTool(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, redisIndexName string) {
+	askTool := mcp.NewTool("ask_vectormind",
+		mcp.WithDescription("Answer a question from the stored documents: the most similar chunks are retrieved and the chat model answers from them. Returns the answer, the IDs of the cited chunks and the retrieved chunks (requires a chat model, CHAT_MODEL)."),
+		mcp.WithString("question",
+			mcp.Required(),
+			mcp.Description("The question to answer"),
+		),
+		mcp.WithNumber("max_count",
+			mcp.Description("Maximum number of chunks given to the chat model (default: 5)"),
+		),
+		mcp.WithString("label",
+			mcp.Description("Optional label of the chunks"),
+		),
+		mcp.WithNumber("expand_context",
+			mcp.Description("Optional number of chunks (up to 10) before and after each retrieved chunk, from the same document, given with it to the chat model (default: 0, no expansion)"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the documents (default: the main index)"),
+		),
+		mcp.WithString("response_language",
+			mcp.Description("Optional language of the answer, e.g. \"French\" (default: the language of the question)"),
+		),
+	)
+	mcpServer.AddTool(askTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		redisClient := searchRedisClient(redisClient)
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
+
+		question, ok := args["question"].(string)
+		if !ok || question == "" {
+			return mcp.NewToolResultError("question parameter is required"), nil
+		}
+
+		maxCount := 5
+		if mc, ok := args["max_count"].(float64); ok && mc > 0 {
+			maxCount = int(mc)
+		}
+		label, _ := args["label"].(string)
+
+		expandContext, _ := args["expand_context"].(float64)
+		if err := store.ValidateExpandContext(int(expandContext)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		language, _ := args["response_language"].(string)
+		if err := store.ValidateResponseLanguage(language); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if store.ChatModelID() == "" {
+			return storeErrorResult("Cannot answer the question", store.ErrChatModelMissing), nil
+		}
+
+		// Resolve the collection of the documents
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		docs, _, err := store.SearchByText(ctx, openaiClient, redisClient, collection.ModelID(embeddingModelId), collection.IndexName, question, maxCount, store.SearchOptions{
+			Label: label,
+		}, store.TextSearchBudget{})
+		if err != nil {
+			return storeErrorResult("Search failed", err), nil
+		}
+		sources := store.TextSearchResults(docs, "", nil)
+		if expandContext > 0 {
+			expandSearchResults(ctx, redisClient, collection.IndexName, sources, int(expandContext))
+		}
+
+		answer, citations, err := store.Ask(ctx, question, sources, language)
+		if err != nil {
+			return storeErrorResult("Failed to answer the question", err), nil
+		}
+
+		resultJSON, _ := json.Marshal(map[string]interface{}{
+			"success":   true,
+			"answer":    answer,
+			"citations": citations,
+			"sources":   sources,
+			"model":     store.ChatModelID(),
+		})
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+}
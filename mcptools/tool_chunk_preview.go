@@ -0,0 +1,64 @@
+package mcptools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"vectormind/splitter"
+	"vectormind/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RegisterChunkPreviewTool registers the preview_chunks tool, a dry run of split_and_store
+func RegisterChunkPreviewTool(mcpServer *server.MCPServer) {
+	previewChunksTool := mcp.NewTool("preview_chunks",
+		mcp.WithDescription("Split a document with a registered splitting strategy and return the chunks with their number of characters and tokens and their quality score, without creating embeddings or storing anything. Use it to tune the strategy and its options (chunk_size, overlap...) before split_and_store."),
+		mcp.WithString("document",
+			mcp.Required(),
+			mcp.Description("The document content to split"),
+		),
+		mcp.WithString("strategy",
+			mcp.Description(fmt.Sprintf("The splitting strategy, one of: %s (required unless filename is set)", strings.Join(splitter.Strategies(), ", "))),
+		),
+		mcp.WithString("filename",
+			mcp.Description("Optional file name of the document: without strategy, the strategy and its default options are chosen from its extension (SPLITTER_CONFIG, or the built-in .md, .markdown, .rst, .adoc, .asciidoc, .asc)"),
+		),
+		mcp.WithObject("options",
+			mcp.Description("Optional strategy options, e.g. {\"chunk_size\": 512, \"overlap\": 64} for 'chunk_overlap' or {\"delimiter\": \"---\"} for 'delimiter'"),
+		),
+	)
+	mcpServer.AddTool(previewChunksTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		document, ok := args["document"].(string)
+		if !ok || document == "" {
+			return mcp.NewToolResultError("document parameter is required"), nil
+		}
+
+		strategy, _ := args["strategy"].(string)
+		options, _ := args["options"].(map[string]interface{})
+
+		// Without strategy, the strategy is chosen from the extension of the file name, with the default options
+		// of the file type (the options of the call take precedence)
+		filename, _ := args["filename"].(string)
+		if config, ok := splitter.ConfigForFile(filename); ok && (strategy == "" || strategy == config.Strategy) {
+			strategy = config.Strategy
+			options = splitter.Options(options).Merge(config.Options)
+		}
+		if strategy == "" {
+			return mcp.NewToolResultError(fmt.Sprintf("strategy parameter is required, or a filename with a known extension (available strategies: %v)", splitter.Strategies())), nil
+		}
+
+		// Split the document as an ingestion would (chunks fit the embedding model context window)
+		chunks, err := splitter.Split(strategy, document, options, GetEmbeddingMaxTokens())
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		resultJSON, _ := json.Marshal(store.ChunkPreviewResponse(strategy, options, chunks, GetEmbeddingMaxTokens()))
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+}
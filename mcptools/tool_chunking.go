@@ -8,7 +8,6 @@ import (
 	"vectormind/splitter"
 	"vectormind/store"
 
-	"github.com/google/uuid"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/openai/openai-go"
@@ -16,7 +15,7 @@ import (
 )
 
 // RegisterChunkingTool registers the chunk_and_store tool
-func RegisterChunkingTool(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId string) {
+func RegisterChunkingTool(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, redisIndexName string) {
 	chunkAndStoreTool := mcp.NewTool("chunk_and_store",
 		mcp.WithDescription("Chunk a document into smaller pieces with overlap and store all chunks with embeddings. All chunks will share the same label and metadata."),
 		mcp.WithString("document",
@@ -26,20 +25,57 @@ func RegisterChunkingTool(mcpServer *server.MCPServer, openaiClient openai.Clien
 		mcp.WithString("label",
 			mcp.Description("Optional label to apply to all chunks"),
 		),
+		mcp.WithArray("labels",
+			mcp.Description("Optional additional labels of the chunks (a document can have several labels)"),
+			mcp.WithStringItems(),
+		),
 		mcp.WithString("metadata",
 			mcp.Description("Optional metadata to apply to all chunks"),
 		),
+		mcp.WithString("id_strategy",
+			mcp.Description("Optional chunk ID strategy: 'uuid' (default, random IDs) or 'content_hash' (IDs derived from source_id, chunk index and content, re-ingesting the same document overwrites the same chunks)"),
+			mcp.Enum("uuid", "content_hash"),
+		),
+		mcp.WithString("source_id",
+			mcp.Description("Optional identifier of the source document, used by the 'content_hash' id_strategy (default: hash of the document)"),
+		),
+		mcp.WithBoolean("continue_on_error",
+			mcp.Description("Optional: keep storing the remaining chunks when a chunk fails and return the status of each chunk (default: false, the first failure aborts)"),
+		),
+		mcp.WithBoolean("rollback",
+			mcp.Description("Optional: delete the chunks already stored when a failed chunk aborts the ingestion (default: false, ignored with continue_on_error)"),
+		),
+		mcp.WithBoolean("atomic",
+			mcp.Description("Optional: create all the embeddings before storing the chunks in a single transaction, so that all the chunks are stored or none (default: false, cannot be combined with continue_on_error)"),
+		),
+		mcp.WithBoolean("include_content",
+			mcp.Description("Optional: return the full text of each stored chunk instead of a preview of its first 100 characters (default: false)"),
+		),
 		mcp.WithNumber("chunk_size",
 			mcp.Required(),
-			mcp.Description("Size of each chunk in characters (must be <= embedding dimension)"),
+			mcp.Description("Size of each chunk in characters (chunks must fit the max input tokens of the embedding model)"),
 		),
 		mcp.WithNumber("overlap",
 			mcp.Required(),
 			mcp.Description("Number of characters to overlap between consecutive chunks (must be < chunk_size)"),
 		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the chunks (default: the main index)"),
+		),
+		mcp.WithNumber("ttl_seconds",
+			mcp.Description("Optional time in seconds after which the chunks are deleted (default: no expiration)"),
+		),
+		mcp.WithString("dedup",
+			mcp.Description("Optional handling of the chunks whose content is already stored: 'off' (default, always store), 'skip' (return the ID of the stored chunk) or 'upsert' (store in place of the stored chunk)"),
+			mcp.Enum("off", "skip", "upsert"),
+		),
+		mcp.WithBoolean("async",
+			mcp.Description("Optional: return an ingestion job immediately and store the chunks in the background, follow it with get_ingestion_status (default: false)"),
+		),
 	)
 	mcpServer.AddTool(chunkAndStoreTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
 
 		document, ok := args["document"].(string)
 		if !ok || document == "" {
@@ -47,8 +83,26 @@ func RegisterChunkingTool(mcpServer *server.MCPServer, openaiClient openai.Clien
 		}
 
 		label, _ := args["label"].(string)
+		label, err := store.JoinLabels(label, stringArrayArgument(args, "labels"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx = store.WithUsageLabel(ctx, label)
 		metadata, _ := args["metadata"].(string)
 
+		idStrategy, _ := args["id_strategy"].(string)
+		if err := store.ValidateIDStrategy(idStrategy); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		sourceID, _ := args["source_id"].(string)
+		continueOnError, _ := args["continue_on_error"].(bool)
+		rollback, _ := args["rollback"].(bool)
+		atomic, _ := args["atomic"].(bool)
+		if err := store.ValidateAtomic(atomic, continueOnError); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		includeContent, _ := args["include_content"].(bool)
+
 		chunkSize, ok := args["chunk_size"].(float64)
 		if !ok || chunkSize <= 0 {
 			return mcp.NewToolResultError("chunk_size must be a positive number"), nil
@@ -67,11 +121,6 @@ func RegisterChunkingTool(mcpServer *server.MCPServer, openaiClient openai.Clien
 			return mcp.NewToolResultError("overlap must be less than chunk_size"), nil
 		}
 
-		// Validate chunk_size <= embeddingDimension
-		if chunkSizeInt > GetEmbeddingDimension() {
-			return mcp.NewToolResultError(fmt.Sprintf("chunk_size (%d) must be less than or equal to embedding dimension (%d)", chunkSizeInt, GetEmbeddingDimension())), nil
-		}
-
 		// Chunk the document
 		chunks := splitter.ChunkText(document, chunkSizeInt, overlapInt)
 
@@ -79,36 +128,453 @@ func RegisterChunkingTool(mcpServer *server.MCPServer, openaiClient openai.Clien
 			return mcp.NewToolResultError("No chunks generated from the document"), nil
 		}
 
+		// Validate that every chunk fits the context window of the embedding model
+		maxTokens := GetEmbeddingMaxTokens()
+		for _, chunk := range chunks {
+			if tokens := splitter.CountTokens(chunk); tokens > maxTokens {
+				return mcp.NewToolResultError(fmt.Sprintf("chunk_size (%d characters) produces chunks of about %d tokens, above the embedding model limit (%d tokens)", chunkSizeInt, tokens, maxTokens)), nil
+			}
+		}
+
+		// Resolve the collection of the chunks
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
+		ttl, err := ttlArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		dedup, err := dedupArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		chunkOptions := store.ChunkOptions{
+			Label:           label,
+			Metadata:        metadata,
+			IDStrategy:      idStrategy,
+			SourceID:        sourceID,
+			ContinueOnError: continueOnError,
+			Rollback:        rollback,
+			Atomic:          atomic,
+			Original:        document,
+			KeyPrefix:       collection.KeyPrefix,
+			TTL:             ttl,
+			Dedup:           dedup,
+			IndexName:       collection.IndexName,
+		}
+
+		// Store the chunks in the background: the job reports the progress
+		if async, _ := args["async"].(bool); async {
+			return ingestionJobResult(store.StartIngestionJob(ctx, openaiClient, redisClient, modelId, chunks, chunkOptions)), nil
+		}
+
 		// Store all chunks
-		chunkIDs := make([]string, 0, len(chunks))
 		createdAt := time.Now()
+		statuses, err := store.StoreChunks(ctx, openaiClient, redisClient, modelId, chunks, chunkOptions)
+		if err != nil {
+			return chunkStoreError(statuses, err), nil
+		}
+
+		chunkIDs, chunksFailed := store.StoredChunkIDs(statuses)
+		if len(chunkIDs) == 0 && chunksFailed > 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("All %d chunks failed to be stored: %s", chunksFailed, statuses[0].Error)), nil
+		}
+
+		// Success response (or partial success when some chunks failed in continue_on_error mode)
+		result := map[string]interface{}{
+			"success":       chunksFailed == 0,
+			"source_id":     store.OriginalSourceID(sourceID, document),
+			"chunk_ids":     chunkIDs,
+			"chunks":        store.ChunkPreviews(chunks, statuses, includeContent),
+			"chunks_stored": len(chunkIDs),
+			"created_at":    createdAt.Format(time.RFC3339),
+		}
+		if continueOnError {
+			result["chunks_failed"] = chunksFailed
+			result["chunk_statuses"] = statuses
+		}
+
+		resultJSON, _ := json.Marshal(result)
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+}
+
+// RegisterRecursiveChunkingTool registers the recursive_chunk_and_store tool
+func RegisterRecursiveChunkingTool(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, redisIndexName string) {
+	recursiveChunkAndStoreTool := mcp.NewTool("recursive_chunk_and_store",
+		mcp.WithDescription("Chunk a document at paragraph, then sentence, then word boundaries (never in the middle of a word unless a word is longer than chunk_size) and store all chunks with embeddings. All chunks will share the same label and metadata."),
+		mcp.WithString("document",
+			mcp.Required(),
+			mcp.Description("The document content to chunk and store"),
+		),
+		mcp.WithString("label",
+			mcp.Description("Optional label to apply to all chunks"),
+		),
+		mcp.WithArray("labels",
+			mcp.Description("Optional additional labels of the chunks (a document can have several labels)"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("metadata",
+			mcp.Description("Optional metadata to apply to all chunks"),
+		),
+		mcp.WithString("id_strategy",
+			mcp.Description("Optional chunk ID strategy: 'uuid' (default, random IDs) or 'content_hash' (IDs derived from source_id, chunk index and content, re-ingesting the same document overwrites the same chunks)"),
+			mcp.Enum("uuid", "content_hash"),
+		),
+		mcp.WithString("source_id",
+			mcp.Description("Optional identifier of the source document, used by the 'content_hash' id_strategy (default: hash of the document)"),
+		),
+		mcp.WithBoolean("continue_on_error",
+			mcp.Description("Optional: keep storing the remaining chunks when a chunk fails and return the status of each chunk (default: false, the first failure aborts)"),
+		),
+		mcp.WithBoolean("rollback",
+			mcp.Description("Optional: delete the chunks already stored when a failed chunk aborts the ingestion (default: false, ignored with continue_on_error)"),
+		),
+		mcp.WithBoolean("atomic",
+			mcp.Description("Optional: create all the embeddings before storing the chunks in a single transaction, so that all the chunks are stored or none (default: false, cannot be combined with continue_on_error)"),
+		),
+		mcp.WithBoolean("include_content",
+			mcp.Description("Optional: return the full text of each stored chunk instead of a preview of its first 100 characters (default: false)"),
+		),
+		mcp.WithNumber("chunk_size",
+			mcp.Required(),
+			mcp.Description("Maximum size of each chunk in characters (chunks must fit the max input tokens of the embedding model)"),
+		),
+		mcp.WithNumber("overlap",
+			mcp.Required(),
+			mcp.Description("Maximum number of characters of whole pieces (paragraphs, sentences or words) repeated from the previous chunk (must be < chunk_size)"),
+		),
+		mcp.WithArray("separators",
+			mcp.Description("Optional boundaries tried from the first to the last, an empty string cuts between characters (default: paragraphs, lines, sentences, words, characters)"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the chunks (default: the main index)"),
+		),
+		mcp.WithNumber("ttl_seconds",
+			mcp.Description("Optional time in seconds after which the chunks are deleted (default: no expiration)"),
+		),
+		mcp.WithString("dedup",
+			mcp.Description("Optional handling of the chunks whose content is already stored: 'off' (default, always store), 'skip' (return the ID of the stored chunk) or 'upsert' (store in place of the stored chunk)"),
+			mcp.Enum("off", "skip", "upsert"),
+		),
+		mcp.WithBoolean("async",
+			mcp.Description("Optional: return an ingestion job immediately and store the chunks in the background, follow it with get_ingestion_status (default: false)"),
+		),
+	)
+	mcpServer.AddTool(recursiveChunkAndStoreTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
+
+		document, ok := args["document"].(string)
+		if !ok || document == "" {
+			return mcp.NewToolResultError("document parameter is required"), nil
+		}
+
+		label, _ := args["label"].(string)
+		label, err := store.JoinLabels(label, stringArrayArgument(args, "labels"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx = store.WithUsageLabel(ctx, label)
+		metadata, _ := args["metadata"].(string)
+
+		idStrategy, _ := args["id_strategy"].(string)
+		if err := store.ValidateIDStrategy(idStrategy); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		sourceID, _ := args["source_id"].(string)
+		continueOnError, _ := args["continue_on_error"].(bool)
+		rollback, _ := args["rollback"].(bool)
+		atomic, _ := args["atomic"].(bool)
+		if err := store.ValidateAtomic(atomic, continueOnError); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		includeContent, _ := args["include_content"].(bool)
+
+		chunkSize, ok := args["chunk_size"].(float64)
+		if !ok || chunkSize <= 0 {
+			return mcp.NewToolResultError("chunk_size must be a positive number"), nil
+		}
+
+		overlap, ok := args["overlap"].(float64)
+		if !ok || overlap < 0 {
+			return mcp.NewToolResultError("overlap must be a non-negative number"), nil
+		}
+
+		chunkSizeInt := int(chunkSize)
+		overlapInt := int(overlap)
+
+		// Validate overlap < chunk_size
+		if overlapInt >= chunkSizeInt {
+			return mcp.NewToolResultError("overlap must be less than chunk_size"), nil
+		}
 
+		// Chunk the document
+		chunks := splitter.RecursiveSplit(document, chunkSizeInt, overlapInt, stringArrayArgument(args, "separators"))
+
+		if len(chunks) == 0 {
+			return mcp.NewToolResultError("No chunks generated from the document"), nil
+		}
+
+		// Validate that every chunk fits the context window of the embedding model
+		maxTokens := GetEmbeddingMaxTokens()
 		for _, chunk := range chunks {
-			// Create embedding from chunk text
-			embedding, err := store.CreateEmbeddingFromText(ctx, openaiClient, chunk, embeddingModelId)
-			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("Failed to create embedding for chunk: %v", err)), nil
+			if tokens := splitter.CountTokens(chunk); tokens > maxTokens {
+				return mcp.NewToolResultError(fmt.Sprintf("chunk_size (%d characters) produces chunks of about %d tokens, above the embedding model limit (%d tokens)", chunkSizeInt, tokens, maxTokens)), nil
 			}
+		}
+
+		// Resolve the collection of the chunks
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
+		ttl, err := ttlArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		dedup, err := dedupArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 
-			// Generate unique document ID for this chunk
-			chunkID := fmt.Sprintf("doc:%s", uuid.New().String())
+		chunkOptions := store.ChunkOptions{
+			Label:           label,
+			Metadata:        metadata,
+			IDStrategy:      idStrategy,
+			SourceID:        sourceID,
+			ContinueOnError: continueOnError,
+			Rollback:        rollback,
+			Atomic:          atomic,
+			Original:        document,
+			KeyPrefix:       collection.KeyPrefix,
+			TTL:             ttl,
+			Dedup:           dedup,
+			IndexName:       collection.IndexName,
+		}
 
-			// Store embedding in Redis with the same label and metadata for all chunks
-			err = store.StoreEmbedding(ctx, redisClient, chunkID, chunk, embedding, label, metadata)
-			if err != nil {
-				return mcp.NewToolResultError(fmt.Sprintf("Failed to store chunk embedding: %v", err)), nil
+		// Store the chunks in the background: the job reports the progress
+		if async, _ := args["async"].(bool); async {
+			return ingestionJobResult(store.StartIngestionJob(ctx, openaiClient, redisClient, modelId, chunks, chunkOptions)), nil
+		}
+
+		// Store all chunks
+		createdAt := time.Now()
+		statuses, err := store.StoreChunks(ctx, openaiClient, redisClient, modelId, chunks, chunkOptions)
+		if err != nil {
+			return chunkStoreError(statuses, err), nil
+		}
+
+		chunkIDs, chunksFailed := store.StoredChunkIDs(statuses)
+		if len(chunkIDs) == 0 && chunksFailed > 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("All %d chunks failed to be stored: %s", chunksFailed, statuses[0].Error)), nil
+		}
+
+		// Success response (or partial success when some chunks failed in continue_on_error mode)
+		result := map[string]interface{}{
+			"success":       chunksFailed == 0,
+			"source_id":     store.OriginalSourceID(sourceID, document),
+			"chunk_ids":     chunkIDs,
+			"chunks":        store.ChunkPreviews(chunks, statuses, includeContent),
+			"chunks_stored": len(chunkIDs),
+			"created_at":    createdAt.Format(time.RFC3339),
+		}
+		if continueOnError {
+			result["chunks_failed"] = chunksFailed
+			result["chunk_statuses"] = statuses
+		}
+
+		resultJSON, _ := json.Marshal(result)
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+}
+
+// RegisterSemanticChunkingTool registers the semantic_chunk_and_store tool
+func RegisterSemanticChunkingTool(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, redisIndexName string) {
+	semanticChunkAndStoreTool := mcp.NewTool("semantic_chunk_and_store",
+		mcp.WithDescription("Chunk a document into groups of adjacent sentences of related content: the sentences are embedded, and a new chunk starts when the similarity between the current chunk and the next sentence drops below a threshold. Store all chunks with embeddings. All chunks will share the same label and metadata."),
+		mcp.WithString("document",
+			mcp.Required(),
+			mcp.Description("The document content to chunk and store"),
+		),
+		mcp.WithString("label",
+			mcp.Description("Optional label to apply to all chunks"),
+		),
+		mcp.WithArray("labels",
+			mcp.Description("Optional additional labels of the chunks (a document can have several labels)"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("metadata",
+			mcp.Description("Optional metadata to apply to all chunks"),
+		),
+		mcp.WithString("id_strategy",
+			mcp.Description("Optional chunk ID strategy: 'uuid' (default, random IDs) or 'content_hash' (IDs derived from source_id, chunk index and content, re-ingesting the same document overwrites the same chunks)"),
+			mcp.Enum("uuid", "content_hash"),
+		),
+		mcp.WithString("source_id",
+			mcp.Description("Optional identifier of the source document, used by the 'content_hash' id_strategy (default: hash of the document)"),
+		),
+		mcp.WithBoolean("continue_on_error",
+			mcp.Description("Optional: keep storing the remaining chunks when a chunk fails and return the status of each chunk (default: false, the first failure aborts)"),
+		),
+		mcp.WithBoolean("rollback",
+			mcp.Description("Optional: delete the chunks already stored when a failed chunk aborts the ingestion (default: false, ignored with continue_on_error)"),
+		),
+		mcp.WithBoolean("atomic",
+			mcp.Description("Optional: create all the embeddings before storing the chunks in a single transaction, so that all the chunks are stored or none (default: false, cannot be combined with continue_on_error)"),
+		),
+		mcp.WithBoolean("include_content",
+			mcp.Description("Optional: return the full text of each stored chunk instead of a preview of its first 100 characters (default: false)"),
+		),
+		mcp.WithNumber("threshold",
+			mcp.Description("Optional cosine similarity, from -1 to 1, below which a sentence starts a new chunk (default: 0.75, higher gives smaller chunks)"),
+		),
+		mcp.WithNumber("max_chunk_size",
+			mcp.Description("Optional maximum size of each chunk in characters (default: 2000, chunks must fit the max input tokens of the embedding model)"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the chunks (default: the main index)"),
+		),
+		mcp.WithNumber("ttl_seconds",
+			mcp.Description("Optional time in seconds after which the chunks are deleted (default: no expiration)"),
+		),
+		mcp.WithString("dedup",
+			mcp.Description("Optional handling of the chunks whose content is already stored: 'off' (default, always store), 'skip' (return the ID of the stored chunk) or 'upsert' (store in place of the stored chunk)"),
+			mcp.Enum("off", "skip", "upsert"),
+		),
+		mcp.WithBoolean("async",
+			mcp.Description("Optional: return an ingestion job immediately and store the chunks in the background, follow it with get_ingestion_status (default: false)"),
+		),
+	)
+	mcpServer.AddTool(semanticChunkAndStoreTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
+
+		document, ok := args["document"].(string)
+		if !ok || document == "" {
+			return mcp.NewToolResultError("document parameter is required"), nil
+		}
+
+		label, _ := args["label"].(string)
+		label, err := store.JoinLabels(label, stringArrayArgument(args, "labels"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx = store.WithUsageLabel(ctx, label)
+		metadata, _ := args["metadata"].(string)
+
+		idStrategy, _ := args["id_strategy"].(string)
+		if err := store.ValidateIDStrategy(idStrategy); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		sourceID, _ := args["source_id"].(string)
+		continueOnError, _ := args["continue_on_error"].(bool)
+		rollback, _ := args["rollback"].(bool)
+		atomic, _ := args["atomic"].(bool)
+		if err := store.ValidateAtomic(atomic, continueOnError); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		includeContent, _ := args["include_content"].(bool)
+
+		threshold := splitter.DefaultSemanticThreshold
+		if value, ok := args["threshold"].(float64); ok {
+			if value < -1 || value > 1 {
+				return mcp.NewToolResultError("threshold must be between -1 and 1"), nil
+			}
+			threshold = value
+		}
+
+		maxChunkSize := splitter.DefaultSemanticChunkSize
+		if value, ok := args["max_chunk_size"].(float64); ok {
+			if value <= 0 {
+				return mcp.NewToolResultError("max_chunk_size must be a positive number"), nil
 			}
+			maxChunkSize = int(value)
+		}
+
+		// Resolve the collection of the chunks
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
 
-			chunkIDs = append(chunkIDs, chunkID)
+		// Chunk the document (the sentences are embedded with the model of the collection)
+		chunks, err := store.SemanticChunks(ctx, openaiClient, document, modelId, threshold, maxChunkSize)
+		if err != nil {
+			return storeErrorResult("Failed to chunk the document", err), nil
+		}
+		if len(chunks) == 0 {
+			return mcp.NewToolResultError("No chunks generated from the document"), nil
 		}
 
-		// Success response
+		// Validate that every chunk fits the context window of the embedding model
+		maxTokens := GetEmbeddingMaxTokens()
+		for _, chunk := range chunks {
+			if tokens := splitter.CountTokens(chunk); tokens > maxTokens {
+				return mcp.NewToolResultError(fmt.Sprintf("max_chunk_size (%d characters) produces chunks of about %d tokens, above the embedding model limit (%d tokens)", maxChunkSize, tokens, maxTokens)), nil
+			}
+		}
+
+		ttl, err := ttlArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		dedup, err := dedupArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		chunkOptions := store.ChunkOptions{
+			Label:           label,
+			Metadata:        metadata,
+			IDStrategy:      idStrategy,
+			SourceID:        sourceID,
+			ContinueOnError: continueOnError,
+			Rollback:        rollback,
+			Atomic:          atomic,
+			Original:        document,
+			KeyPrefix:       collection.KeyPrefix,
+			TTL:             ttl,
+			Dedup:           dedup,
+			IndexName:       collection.IndexName,
+		}
+
+		// Store the chunks in the background: the job reports the progress
+		if async, _ := args["async"].(bool); async {
+			return ingestionJobResult(store.StartIngestionJob(ctx, openaiClient, redisClient, modelId, chunks, chunkOptions)), nil
+		}
+
+		// Store all chunks
+		createdAt := time.Now()
+		statuses, err := store.StoreChunks(ctx, openaiClient, redisClient, modelId, chunks, chunkOptions)
+		if err != nil {
+			return chunkStoreError(statuses, err), nil
+		}
+
+		chunkIDs, chunksFailed := store.StoredChunkIDs(statuses)
+		if len(chunkIDs) == 0 && chunksFailed > 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("All %d chunks failed to be stored: %s", chunksFailed, statuses[0].Error)), nil
+		}
+
+		// Success response (or partial success when some chunks failed in continue_on_error mode)
 		result := map[string]interface{}{
-			"success":       true,
+			"success":       chunksFailed == 0,
+			"source_id":     store.OriginalSourceID(sourceID, document),
 			"chunk_ids":     chunkIDs,
+			"chunks":        store.ChunkPreviews(chunks, statuses, includeContent),
 			"chunks_stored": len(chunkIDs),
 			"created_at":    createdAt.Format(time.RFC3339),
 		}
+		if continueOnError {
+			result["chunks_failed"] = chunksFailed
+			result["chunk_statuses"] = statuses
+		}
 
 		resultJSON, _ := json.Marshal(result)
 		return mcp.NewToolResultText(string(resultJSON)), nil
@@ -3,19 +3,20 @@ package mcptools
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 	"vectormind/store"
 
-	"github.com/google/uuid"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/openai/openai-go"
 	"github.com/redis/go-redis/v9"
 )
 
-// RegisterEmbeddingTools registers the create_embedding and get_embedding_model_info tools
-func RegisterEmbeddingTools(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId string) {
+// RegisterEmbeddingTools registers the create_embedding, get_embedding_model_info, get_document, update_embedding,
+// delete_embedding, delete_by_label and delete_by_parent tools
+func RegisterEmbeddingTools(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, redisIndexName string) {
 	// Create embedding tool
 	createEmbeddingTool := mcp.NewTool("create_embedding",
 		mcp.WithDescription("Create and store an embedding from text content with optional label and metadata."),
@@ -26,12 +27,34 @@ func RegisterEmbeddingTools(mcpServer *server.MCPServer, openaiClient openai.Cli
 		mcp.WithString("label",
 			mcp.Description("Optional label/tag for the document"),
 		),
+		mcp.WithArray("labels",
+			mcp.Description("Optional additional labels of the document (a document can have several labels)"),
+			mcp.WithStringItems(),
+		),
 		mcp.WithString("metadata",
 			mcp.Description("Optional metadata for the document"),
 		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the document (default: the main index)"),
+		),
+		mcp.WithNumber("ttl_seconds",
+			mcp.Description("Optional time in seconds after which the document is deleted (default: no expiration)"),
+		),
+		mcp.WithString("dedup",
+			mcp.Description("Optional handling of a content that is already stored: 'off' (default, always store), 'skip' (return the ID of the stored document) or 'upsert' (store in place of the stored document)"),
+			mcp.Enum("off", "skip", "upsert"),
+		),
+		mcp.WithString("id",
+			mcp.Description("Optional ID of the document chosen by the caller (default: a generated ID, the key prefix of the collection is added when missing)"),
+		),
+		mcp.WithString("on_conflict",
+			mcp.Description("Optional handling of an id already used: 'error' (refuse the document) or 'overwrite' (replace the stored document) (default: the server setting)"),
+			mcp.Enum("error", "overwrite"),
+		),
 	)
 	mcpServer.AddTool(createEmbeddingTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
 
 		content, ok := args["content"].(string)
 		if !ok || content == "" {
@@ -39,21 +62,75 @@ func RegisterEmbeddingTools(mcpServer *server.MCPServer, openaiClient openai.Cli
 		}
 
 		label, _ := args["label"].(string)
+		label, err := store.JoinLabels(label, stringArrayArgument(args, "labels"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx = store.WithUsageLabel(ctx, label)
 		metadata, _ := args["metadata"].(string)
 
-		// Create embedding from text
-		embedding, err := store.CreateEmbeddingFromText(ctx, openaiClient, content, embeddingModelId)
+		// Resolve the collection of the document
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to create embedding: %v", err)), nil
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
+		ttl, err := ttlArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		dedup, err := dedupArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
 		}
 
-		// Generate unique document ID
-		docID := fmt.Sprintf("doc:%s", uuid.New().String())
+		callerID, _ := args["id"].(string)
+		onConflict, _ := args["on_conflict"].(string)
+		if err := store.ValidateIDConflict(onConflict); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if callerID != "" && dedup != "" && dedup != store.DedupOff {
+			return mcp.NewToolResultError("id and dedup cannot be combined"), nil
+		}
 
-		// Store embedding in Redis
-		err = store.StoreEmbedding(ctx, redisClient, docID, content, embedding, label, metadata)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to store embedding: %v", err)), nil
+		// The ID of the document: the ID chosen by the caller, a new ID, or the ID of the document with the same content
+		var docID string
+		var duplicate bool
+		overwrite := store.OverwriteOnIDConflict(onConflict)
+		if callerID != "" {
+			docID, err = store.CallerDocumentID(collection.KeyPrefix, callerID)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			if exists, err := store.DocumentExists(ctx, redisClient, docID); err == nil && exists && !overwrite {
+				return mcp.NewToolResultError(fmt.Sprintf("%v: %s", store.ErrDocumentExists, docID)), nil
+			}
+		} else {
+			docID, duplicate, err = store.DedupDocumentID(ctx, redisClient, collection, content, dedup)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+
+		if !duplicate {
+			// Create embedding from text
+			embedding, err := store.CreateEmbeddingFromText(ctx, openaiClient, content, modelId)
+			if err != nil {
+				return storeErrorResult("Failed to create embedding", err), nil
+			}
+
+			// Store embedding in Redis
+			if callerID != "" {
+				err = store.InsertDocument(ctx, redisClient, store.NewDocument(docID, content, embedding, label, metadata, ttl), overwrite)
+			} else {
+				err = store.StoreEmbeddingWithTTL(ctx, redisClient, docID, content, embedding, label, metadata, ttl)
+			}
+			if errors.Is(err, store.ErrDocumentExists) {
+				return mcp.NewToolResultError(fmt.Sprintf("%v: %s", err, docID)), nil
+			}
+			if err != nil {
+				return storeErrorResult("Failed to store embedding", err), nil
+			}
 		}
 
 		// Return success response
@@ -62,9 +139,13 @@ func RegisterEmbeddingTools(mcpServer *server.MCPServer, openaiClient openai.Cli
 			"id":         docID,
 			"content":    content,
 			"label":      label,
+			"labels":     store.SplitLabels(label),
 			"metadata":   metadata,
 			"created_at": time.Now().Format(time.RFC3339),
 		}
+		if duplicate {
+			result["duplicate"] = true
+		}
 
 		resultJSON, _ := json.Marshal(result)
 		return mcp.NewToolResultText(string(resultJSON)), nil
@@ -72,15 +153,270 @@ func RegisterEmbeddingTools(mcpServer *server.MCPServer, openaiClient openai.Cli
 
 	// Get embedding model info tool
 	getEmbeddingModelInfoTool := mcp.NewTool("get_embedding_model_info",
-		mcp.WithDescription("Get information about the embedding model being used, including the model ID and dimension."),
+		mcp.WithDescription("Get information about the embedding model being used, including the model ID, dimension and maximum number of input tokens, and the list of the registered embedding models."),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection whose embedding model is described (default: the default embedding model)"),
+		),
 	)
 	mcpServer.AddTool(getEmbeddingModelInfoTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
+
+		model := store.EmbeddingModel{
+			Name:      store.DefaultEmbeddingModelName,
+			ModelID:   GetEmbeddingModelId(),
+			Dimension: GetEmbeddingDimension(),
+			MaxTokens: GetEmbeddingMaxTokens(),
+		}
+		name, _ := args["collection"].(string)
+		if name != "" {
+			collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+			if err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			model = collection.Model(model)
+		}
+
+		result := map[string]interface{}{
+			"embedding_model": model.Name,
+			"model_id":        model.ModelID,
+			"dimension":       model.Dimension,
+			"max_tokens":      model.MaxTokens,
+			"models":          store.ListEmbeddingModels(),
+		}
+		if name != "" {
+			result["collection"] = name
+		}
+
+		resultJSON, _ := json.Marshal(result)
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+	// Delete embedding tool
+	deleteEmbeddingTool := mcp.NewTool("delete_embedding",
+		mcp.WithDescription("Delete stored documents and their embeddings by ID. Pass 'id' to delete a single document or 'ids' to delete several documents at once."),
+		mcp.WithString("id",
+			mcp.Description("The ID of the document to delete (e.g. doc:uuid)"),
+		),
+		mcp.WithArray("ids",
+			mcp.Description("The IDs of the documents to delete (bulk deletion)"),
+			mcp.WithStringItems(),
+		),
+	)
+	mcpServer.AddTool(deleteEmbeddingTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		ids := []string{}
+		if id, ok := args["id"].(string); ok && id != "" {
+			ids = append(ids, id)
+		}
+		if values, ok := args["ids"].([]interface{}); ok {
+			for _, value := range values {
+				id, ok := value.(string)
+				if !ok || id == "" {
+					return mcp.NewToolResultError("ids must be a list of document IDs"), nil
+				}
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) == 0 {
+			return mcp.NewToolResultError("id or ids parameter is required"), nil
+		}
+		for _, id := range ids {
+			if err := store.ValidateTenantDocumentID(tenantIndexName(ctx, redisIndexName), id); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+		}
+
+		deleted, notFound, err := store.DeleteDocuments(ctx, redisClient, ids)
+		if err != nil {
+			return storeErrorResult("Failed to delete documents", err), nil
+		}
+
+		// A single document that does not exist is an error, unknown IDs of a bulk deletion are only reported
+		if len(ids) == 1 && len(deleted) == 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("Document not found: %s", ids[0])), nil
+		}
+
+		result := map[string]interface{}{
+			"success":       true,
+			"deleted":       deleted,
+			"not_found":     notFound,
+			"deleted_count": len(deleted),
+		}
+
+		resultJSON, _ := json.Marshal(result)
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+	// Delete by label tool
+	deleteByLabelTool := mcp.NewTool("delete_by_label",
+		mcp.WithDescription("Delete all the stored documents having a label, e.g. to purge a dataset. Returns the number of deleted documents."),
+		mcp.WithString("label",
+			mcp.Required(),
+			mcp.Description("The label of the documents to delete"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the documents (default: the main index)"),
+		),
+	)
+	mcpServer.AddTool(deleteByLabelTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
+
+		label, ok := args["label"].(string)
+		if !ok || label == "" {
+			return mcp.NewToolResultError("label parameter is required"), nil
+		}
+		return deleteMatchingDocuments(ctx, redisClient, redisIndexName, args, store.SearchOptions{Labels: []string{label}}), nil
+	})
+	// Delete by parent tool
+	deleteByParentTool := mcp.NewTool("delete_by_parent",
+		mcp.WithDescription("Delete all the chunks split from a parent document (the parent_id of the search results), e.g. before ingesting the document again. Returns the number of deleted chunks."),
+		mcp.WithString("parent_id",
+			mcp.Required(),
+			mcp.Description("The parent ID of the chunks to delete"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the chunks (default: the main index)"),
+		),
+	)
+	mcpServer.AddTool(deleteByParentTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
+
+		parentID, ok := args["parent_id"].(string)
+		if !ok || parentID == "" {
+			return mcp.NewToolResultError("parent_id parameter is required"), nil
+		}
+		return deleteMatchingDocuments(ctx, redisClient, redisIndexName, args, store.SearchOptions{ParentID: parentID}), nil
+	})
+	// Update embedding tool
+	updateEmbeddingTool := mcp.NewTool("update_embedding",
+		mcp.WithDescription("Replace the content of a stored document and regenerate its embedding. The label and metadata are kept unless new values are provided."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("The ID of the document to update (e.g. doc:uuid)"),
+		),
+		mcp.WithString("content",
+			mcp.Required(),
+			mcp.Description("The new text content of the document"),
+		),
+		mcp.WithString("label",
+			mcp.Description("Optional new label of the document (default: keep the current label)"),
+		),
+		mcp.WithString("metadata",
+			mcp.Description("Optional new metadata of the document (default: keep the current metadata)"),
+		),
+	)
+	mcpServer.AddTool(updateEmbeddingTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		id, ok := args["id"].(string)
+		if !ok || id == "" {
+			return mcp.NewToolResultError("id parameter is required"), nil
+		}
+		if err := store.ValidateTenantDocumentID(tenantIndexName(ctx, redisIndexName), id); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		content, ok := args["content"].(string)
+		if !ok || content == "" {
+			return mcp.NewToolResultError("content parameter is required"), nil
+		}
+
+		update := store.DocumentUpdate{Content: content}
+		if label, ok := args["label"].(string); ok {
+			update.Label = &label
+		}
+		if metadata, ok := args["metadata"].(string); ok {
+			update.Metadata = &metadata
+		}
+
+		// Avoid creating an embedding for a document that does not exist
+		exists, err := store.DocumentExists(ctx, redisClient, id)
+		if err != nil {
+			return storeErrorResult("Failed to update document", err), nil
+		}
+		if !exists {
+			return mcp.NewToolResultError(fmt.Sprintf("Document not found: %s", id)), nil
+		}
+
+		// Create embedding from the new content, with the embedding model of the collection of the document
+		modelId, err := store.DocumentModelID(ctx, redisClient, id, embeddingModelId)
+		if err != nil {
+			return storeErrorResult("Failed to update document", err), nil
+		}
+		update.Embedding, err = store.CreateEmbeddingFromText(ctx, openaiClient, content, modelId)
+		if err != nil {
+			return storeErrorResult("Failed to create embedding", err), nil
+		}
+
+		doc, err := store.UpdateDocument(ctx, redisClient, id, update)
+		if errors.Is(err, store.ErrDocumentNotFound) {
+			return mcp.NewToolResultError(fmt.Sprintf("Document not found: %s", id)), nil
+		}
+		if err != nil {
+			return storeErrorResult("Failed to update document", err), nil
+		}
+
 		result := map[string]interface{}{
-			"model_id":  GetEmbeddingModelId(),
-			"dimension": GetEmbeddingDimension(),
+			"success":    true,
+			"id":         doc.ID,
+			"content":    doc.Content,
+			"label":      doc.Label,
+			"metadata":   doc.Metadata,
+			"updated_at": time.Now().Format(time.RFC3339),
 		}
 
 		resultJSON, _ := json.Marshal(result)
 		return mcp.NewToolResultText(string(resultJSON)), nil
 	})
+	// Get document tool
+	getDocumentTool := mcp.NewTool("get_document",
+		mcp.WithDescription("Get a stored document by ID: content, label, metadata, quality score, creation date and optionally its embedding vector."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("The ID of the document to get (e.g. doc:uuid)"),
+		),
+		mcp.WithBoolean("include_embedding",
+			mcp.Description("Optional: also return the embedding vector of the document (default: false)"),
+		),
+		mcp.WithNumber("content_offset",
+			mcp.Description("Optional offset in characters of the returned content, e.g. the next_offset of a truncated search result (default: 0)"),
+		),
+		mcp.WithNumber("max_content_chars",
+			mcp.Description("Optional maximum number of characters of the returned content (default: 0, up to the end)"),
+		),
+	)
+	mcpServer.AddTool(getDocumentTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		id, ok := args["id"].(string)
+		if !ok || id == "" {
+			return mcp.NewToolResultError("id parameter is required"), nil
+		}
+		if err := store.ValidateTenantDocumentID(tenantIndexName(ctx, redisIndexName), id); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		includeEmbedding, _ := args["include_embedding"].(bool)
+		contentOffset, _ := args["content_offset"].(float64)
+		if err := store.ValidateContentOffset(int(contentOffset)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		maxContentChars, _ := args["max_content_chars"].(float64)
+		if err := store.ValidateMaxContentChars(int(maxContentChars)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		document, err := store.GetDocument(ctx, redisClient, id, includeEmbedding)
+		if errors.Is(err, store.ErrDocumentNotFound) {
+			return mcp.NewToolResultError(fmt.Sprintf("Document not found: %s", id)), nil
+		}
+		if err != nil {
+			return storeErrorResult("Failed to get document", err), nil
+		}
+		store.DocumentContentWindow(&document, int(contentOffset), int(maxContentChars))
+
+		resultJSON, _ := json.Marshal(document)
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
 }
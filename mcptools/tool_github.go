@@ -0,0 +1,209 @@
+package mcptools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+	"vectormind/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// RegisterGitHubTool registers the ingest_github tool
+func RegisterGitHubTool(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, redisIndexName string) {
+	ingestGitHubTool := mcp.NewTool("ingest_github",
+		mcp.WithDescription("Index a GitHub repository: download the markdown, documentation and code files of a branch and store all chunks with embeddings. The documents are split by section and the code files at their blank lines. The repo, path, branch and commit of the file of each chunk are added to its metadata."),
+		mcp.WithString("repo_url",
+			mcp.Required(),
+			mcp.Description("The repository: https://github.com/owner/repo, github.com/owner/repo or owner/repo"),
+		),
+		mcp.WithString("branch",
+			mcp.Description("Optional branch, tag or commit of the files (default: the default branch of the repository)"),
+		),
+		mcp.WithArray("include",
+			mcp.Description("Optional glob patterns of the files to ingest, e.g. 'docs/**/*.md' or '*.go' (default: all the documentation and code files)"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithArray("exclude",
+			mcp.Description("Optional glob patterns of the files to skip, e.g. 'vendor/**'"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("label",
+			mcp.Description("Optional label to apply to all chunks"),
+		),
+		mcp.WithArray("labels",
+			mcp.Description("Optional additional labels of the chunks (a document can have several labels)"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("metadata",
+			mcp.Description("Optional metadata to apply to all chunks (a JSON object, completed with the repo, path, branch and commit fields)"),
+		),
+		mcp.WithString("id_strategy",
+			mcp.Description("Optional chunk ID strategy: 'uuid' (default, random IDs) or 'content_hash' (IDs derived from source_id, chunk index and content, re-ingesting the same document overwrites the same chunks)"),
+			mcp.Enum("uuid", "content_hash"),
+		),
+		mcp.WithString("source_id",
+			mcp.Description("Optional identifier of the source document, used by the 'content_hash' id_strategy (default: github.com/owner/repo)"),
+		),
+		mcp.WithBoolean("continue_on_error",
+			mcp.Description("Optional: keep storing the remaining chunks when a chunk fails and return the status of each chunk (default: false, the first failure aborts)"),
+		),
+		mcp.WithBoolean("rollback",
+			mcp.Description("Optional: delete the chunks already stored when a failed chunk aborts the ingestion (default: false, ignored with continue_on_error)"),
+		),
+		mcp.WithBoolean("atomic",
+			mcp.Description("Optional: create all the embeddings before storing the chunks in a single transaction, so that all the chunks are stored or none (default: false, cannot be combined with continue_on_error)"),
+		),
+		mcp.WithBoolean("include_content",
+			mcp.Description("Optional: return the full text of each stored chunk instead of a preview of its first 100 characters (default: false)"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the chunks (default: the main index)"),
+		),
+		mcp.WithNumber("ttl_seconds",
+			mcp.Description("Optional time in seconds after which the chunks are deleted (default: no expiration)"),
+		),
+		mcp.WithString("dedup",
+			mcp.Description("Optional handling of the chunks whose content is already stored: 'off' (default, always store), 'skip' (return the ID of the stored chunk) or 'upsert' (store in place of the stored chunk)"),
+			mcp.Enum("off", "skip", "upsert"),
+		),
+		mcp.WithBoolean("async",
+			mcp.Description("Optional: return an ingestion job immediately and store the chunks in the background, follow it with get_ingestion_status (default: false)"),
+		),
+	)
+	mcpServer.AddTool(ingestGitHubTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
+
+		repoURL, ok := args["repo_url"].(string)
+		if !ok || repoURL == "" {
+			return mcp.NewToolResultError("repo_url parameter is required"), nil
+		}
+		repo, err := store.ParseGitHubRepository(repoURL)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		branch, _ := args["branch"].(string)
+		if err := store.ValidateGitHubBranch(branch); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		include := stringArrayArgument(args, "include")
+		exclude := stringArrayArgument(args, "exclude")
+		if err := store.ValidateGlobs(slices.Concat(include, exclude)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		label, _ := args["label"].(string)
+		label, err = store.JoinLabels(label, stringArrayArgument(args, "labels"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx = store.WithUsageLabel(ctx, label)
+		metadata, _ := args["metadata"].(string)
+
+		idStrategy, _ := args["id_strategy"].(string)
+		if err := store.ValidateIDStrategy(idStrategy); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		sourceID, _ := args["source_id"].(string)
+		continueOnError, _ := args["continue_on_error"].(bool)
+		rollback, _ := args["rollback"].(bool)
+		atomic, _ := args["atomic"].(bool)
+		if err := store.ValidateAtomic(atomic, continueOnError); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		includeContent, _ := args["include_content"].(bool)
+		ttl, err := ttlArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		dedup, err := dedupArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		// Download the files of the repository
+		repository, err := store.FetchGitHubRepository(ctx, repo, branch, include, exclude)
+		if err != nil {
+			return storeErrorResult("Failed to download the repository", err), nil
+		}
+
+		// The chunks keep the repo, path, branch and commit of their file in their metadata
+		chunks, chunkMetadata, err := store.GitHubChunks(repository, metadata, GetEmbeddingMaxTokens())
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if len(chunks) == 0 {
+			return mcp.NewToolResultError("No chunks generated from the repository (no file matching the filters)"), nil
+		}
+		if sourceID == "" {
+			sourceID = "github.com/" + repo
+		}
+
+		// Resolve the collection of the chunks
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
+
+		chunkOptions := store.ChunkOptions{
+			Label:           label,
+			ChunkMetadata:   chunkMetadata,
+			IDStrategy:      idStrategy,
+			SourceID:        sourceID,
+			ContinueOnError: continueOnError,
+			Rollback:        rollback,
+			Atomic:          atomic,
+			KeyPrefix:       collection.KeyPrefix,
+			TTL:             ttl,
+			Dedup:           dedup,
+			IndexName:       collection.IndexName,
+		}
+
+		// Store the chunks in the background: the job reports the progress
+		if async, _ := args["async"].(bool); async {
+			return ingestionJobResult(store.StartIngestionJob(ctx, openaiClient, redisClient, modelId, chunks, chunkOptions)), nil
+		}
+
+		// Store all chunks
+		createdAt := time.Now()
+		statuses, err := store.StoreChunks(ctx, openaiClient, redisClient, modelId, chunks, chunkOptions)
+		if err != nil {
+			return chunkStoreError(statuses, err), nil
+		}
+
+		chunkIDs, chunksFailed := store.StoredChunkIDs(statuses)
+		if len(chunkIDs) == 0 && chunksFailed > 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("All %d chunks failed to be stored: %s", chunksFailed, statuses[0].Error)), nil
+		}
+
+		// Success response (or partial success when some chunks failed in continue_on_error mode)
+		result := map[string]interface{}{
+			"success":        chunksFailed == 0,
+			"source_id":      sourceID,
+			"repo":           repo,
+			"branch":         repository.Branch,
+			"commit":         repository.Commit,
+			"files_ingested": len(repository.Files),
+			"files_skipped":  repository.Skipped,
+			"chunk_ids":      chunkIDs,
+			"chunks":         store.ChunkPreviews(chunks, statuses, includeContent),
+			"chunks_stored":  len(chunkIDs),
+			"created_at":     createdAt.Format(time.RFC3339),
+		}
+		if continueOnError {
+			result["chunks_failed"] = chunksFailed
+			result["chunk_statuses"] = statuses
+		}
+
+		resultJSON, _ := json.Marshal(result)
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+}
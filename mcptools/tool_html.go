@@ -0,0 +1,177 @@
+package mcptools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"vectormind/splitter"
+	"vectormind/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// RegisterHTMLTool registers the split_and_store_html tool
+func RegisterHTMLTool(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, redisIndexName string) {
+	splitAndStoreHTMLTool := mcp.NewTool("split_and_store_html",
+		mcp.WithDescription("Convert an HTML page to markdown, dropping the boilerplate (scripts, styles, navigation, header, footer, sidebars, forms, cookie banners), and store all chunks with embeddings. The chunks are split by heading and carry their title and hierarchy."),
+		mcp.WithString("document",
+			mcp.Required(),
+			mcp.Description("The HTML page"),
+		),
+		mcp.WithString("label",
+			mcp.Description("Optional label to apply to all chunks"),
+		),
+		mcp.WithArray("labels",
+			mcp.Description("Optional additional labels of the chunks (a document can have several labels)"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("metadata",
+			mcp.Description("Optional metadata to apply to all chunks"),
+		),
+		mcp.WithString("id_strategy",
+			mcp.Description("Optional chunk ID strategy: 'uuid' (default, random IDs) or 'content_hash' (IDs derived from source_id, chunk index and content, re-ingesting the same document overwrites the same chunks)"),
+			mcp.Enum("uuid", "content_hash"),
+		),
+		mcp.WithString("source_id",
+			mcp.Description("Optional identifier of the source document, used by the 'content_hash' id_strategy (default: hash of the document)"),
+		),
+		mcp.WithBoolean("continue_on_error",
+			mcp.Description("Optional: keep storing the remaining chunks when a chunk fails and return the status of each chunk (default: false, the first failure aborts)"),
+		),
+		mcp.WithBoolean("rollback",
+			mcp.Description("Optional: delete the chunks already stored when a failed chunk aborts the ingestion (default: false, ignored with continue_on_error)"),
+		),
+		mcp.WithBoolean("atomic",
+			mcp.Description("Optional: create all the embeddings before storing the chunks in a single transaction, so that all the chunks are stored or none (default: false, cannot be combined with continue_on_error)"),
+		),
+		mcp.WithBoolean("include_content",
+			mcp.Description("Optional: return the full text of each stored chunk instead of a preview of its first 100 characters (default: false)"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the chunks (default: the main index)"),
+		),
+		mcp.WithNumber("ttl_seconds",
+			mcp.Description("Optional time in seconds after which the chunks are deleted (default: no expiration)"),
+		),
+		mcp.WithString("dedup",
+			mcp.Description("Optional handling of the chunks whose content is already stored: 'off' (default, always store), 'skip' (return the ID of the stored chunk) or 'upsert' (store in place of the stored chunk)"),
+			mcp.Enum("off", "skip", "upsert"),
+		),
+		mcp.WithBoolean("async",
+			mcp.Description("Optional: return an ingestion job immediately and store the chunks in the background, follow it with get_ingestion_status (default: false)"),
+		),
+	)
+	mcpServer.AddTool(splitAndStoreHTMLTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
+
+		document, ok := args["document"].(string)
+		if !ok || document == "" {
+			return mcp.NewToolResultError("document parameter is required"), nil
+		}
+
+		label, _ := args["label"].(string)
+		label, err := store.JoinLabels(label, stringArrayArgument(args, "labels"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx = store.WithUsageLabel(ctx, label)
+		metadata, _ := args["metadata"].(string)
+
+		idStrategy, _ := args["id_strategy"].(string)
+		if err := store.ValidateIDStrategy(idStrategy); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		sourceID, _ := args["source_id"].(string)
+		continueOnError, _ := args["continue_on_error"].(bool)
+		rollback, _ := args["rollback"].(bool)
+		atomic, _ := args["atomic"].(bool)
+		if err := store.ValidateAtomic(atomic, continueOnError); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		includeContent, _ := args["include_content"].(bool)
+
+		// Convert the page to markdown, its headings giving the hierarchy of the chunks
+		markdown, err := splitter.HTMLToMarkdown(document)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		chunks, err := splitter.Split("markdown_hierarchy", markdown, nil, GetEmbeddingMaxTokens())
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		if len(chunks) == 0 {
+			return mcp.NewToolResultError("No chunks generated from the document (no content left without the boilerplate)"), nil
+		}
+
+		// Resolve the collection of the chunks
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
+		ttl, err := ttlArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		dedup, err := dedupArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		chunkOptions := store.ChunkOptions{
+			Label:           label,
+			Metadata:        metadata,
+			IDStrategy:      idStrategy,
+			SourceID:        sourceID,
+			ContinueOnError: continueOnError,
+			Rollback:        rollback,
+			Atomic:          atomic,
+			Original:        document,
+			KeyPrefix:       collection.KeyPrefix,
+			TTL:             ttl,
+			Dedup:           dedup,
+			IndexName:       collection.IndexName,
+		}
+
+		// Store the chunks in the background: the job reports the progress
+		if async, _ := args["async"].(bool); async {
+			return ingestionJobResult(store.StartIngestionJob(ctx, openaiClient, redisClient, modelId, chunks, chunkOptions)), nil
+		}
+
+		// Store all chunks
+		createdAt := time.Now()
+		statuses, err := store.StoreChunks(ctx, openaiClient, redisClient, modelId, chunks, chunkOptions)
+		if err != nil {
+			return chunkStoreError(statuses, err), nil
+		}
+
+		chunkIDs, chunksFailed := store.StoredChunkIDs(statuses)
+		if len(chunkIDs) == 0 && chunksFailed > 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("All %d chunks failed to be stored: %s", chunksFailed, statuses[0].Error)), nil
+		}
+
+		// Success response (or partial success when some chunks failed in continue_on_error mode)
+		result := map[string]interface{}{
+			"success":       chunksFailed == 0,
+			"source_id":     store.OriginalSourceID(sourceID, document),
+			"chunk_ids":     chunkIDs,
+			"chunks":        store.ChunkPreviews(chunks, statuses, includeContent),
+			"chunks_stored": len(chunkIDs),
+			"created_at":    createdAt.Format(time.RFC3339),
+		}
+		if continueOnError {
+			result["chunks_failed"] = chunksFailed
+			result["chunk_statuses"] = statuses
+		}
+
+		resultJSON, _ := json.Marshal(result)
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+}
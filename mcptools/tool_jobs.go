@@ -0,0 +1,45 @@
+package mcptools
+
+import (
+	"context"
+	"encoding/json"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// ingestionJobResult returns the result of a chunk and store tool called with async: the job storing the chunks
+func ingestionJobResult(job models.IngestionJob) *mcp.CallToolResult {
+	resultJSON, _ := json.Marshal(map[string]interface{}{
+		"success": true,
+		"job":     job,
+	})
+	return mcp.NewToolResultText(string(resultJSON))
+}
+
+// RegisterJobTools registers the get_ingestion_status tool
+func RegisterJobTools(mcpServer *server.MCPServer, redisIndexName string) {
+	getIngestionStatusTool := mcp.NewTool("get_ingestion_status",
+		mcp.WithDescription("Get the progress of an ingestion job started by a chunk and store tool with async: status (queued, running, completed or failed), chunks done/total, stored chunk IDs and errors."),
+		mcp.WithString("id",
+			mcp.Required(),
+			mcp.Description("The ID of the ingestion job"),
+		),
+	)
+	mcpServer.AddTool(getIngestionStatusTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		id, ok := args["id"].(string)
+		if !ok || id == "" {
+			return mcp.NewToolResultError("id parameter is required"), nil
+		}
+
+		job, ok := store.GetIngestionJob(tenantIndexName(ctx, redisIndexName), id)
+		if !ok {
+			return mcp.NewToolResultError("Ingestion job not found (completed jobs are kept for one hour): " + id), nil
+		}
+		return ingestionJobResult(job), nil
+	})
+}
@@ -4,12 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"time"
+	"vectormind/helpers"
 	"vectormind/splitter"
 	"vectormind/store"
 
-	"github.com/google/uuid"
 	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/openai/openai-go"
@@ -17,10 +16,10 @@ import (
 )
 
 // RegisterMarkdownTools registers all markdown-related tools
-func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId string) {
+func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, redisIndexName string) {
 	// Split and store markdown sections tool
 	splitAndStoreMarkdownSectionsTool := mcp.NewTool("split_and_store_markdown_sections",
-		mcp.WithDescription("Split a markdown document by sections (headers like #, ##, ###) and store all sections with embeddings. Sections larger than embedding dimension are automatically subdivided. All chunks will share the same label and metadata."),
+		mcp.WithDescription("Split a markdown document by sections (headers like #, ##, ###) and store all sections with embeddings. Sections larger than the embedding model max input tokens are automatically subdivided. All chunks will share the same label and metadata."),
 		mcp.WithString("document",
 			mcp.Required(),
 			mcp.Description("The markdown document content to split and store"),
@@ -28,12 +27,49 @@ func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Clie
 		mcp.WithString("label",
 			mcp.Description("Optional label to apply to all sections/chunks"),
 		),
+		mcp.WithArray("labels",
+			mcp.Description("Optional additional labels of the chunks (a document can have several labels)"),
+			mcp.WithStringItems(),
+		),
 		mcp.WithString("metadata",
 			mcp.Description("Optional metadata to apply to all sections/chunks"),
 		),
+		mcp.WithString("id_strategy",
+			mcp.Description("Optional chunk ID strategy: 'uuid' (default, random IDs) or 'content_hash' (IDs derived from source_id, chunk index and content, re-ingesting the same document overwrites the same chunks)"),
+			mcp.Enum("uuid", "content_hash"),
+		),
+		mcp.WithString("source_id",
+			mcp.Description("Optional identifier of the source document, used by the 'content_hash' id_strategy (default: hash of the document)"),
+		),
+		mcp.WithBoolean("continue_on_error",
+			mcp.Description("Optional: keep storing the remaining chunks when a chunk fails and return the status of each chunk (default: false, the first failure aborts)"),
+		),
+		mcp.WithBoolean("rollback",
+			mcp.Description("Optional: delete the chunks already stored when a failed chunk aborts the ingestion (default: false, ignored with continue_on_error)"),
+		),
+		mcp.WithBoolean("atomic",
+			mcp.Description("Optional: create all the embeddings before storing the chunks in a single transaction, so that all the chunks are stored or none (default: false, cannot be combined with continue_on_error)"),
+		),
+		mcp.WithBoolean("include_content",
+			mcp.Description("Optional: return the full text of each stored chunk instead of a preview of its first 100 characters (default: false)"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the chunks (default: the main index)"),
+		),
+		mcp.WithNumber("ttl_seconds",
+			mcp.Description("Optional time in seconds after which the chunks are deleted (default: no expiration)"),
+		),
+		mcp.WithString("dedup",
+			mcp.Description("Optional handling of the chunks whose content is already stored: 'off' (default, always store), 'skip' (return the ID of the stored chunk) or 'upsert' (store in place of the stored chunk)"),
+			mcp.Enum("off", "skip", "upsert"),
+		),
+		mcp.WithBoolean("async",
+			mcp.Description("Optional: return an ingestion job immediately and store the chunks in the background, follow it with get_ingestion_status (default: false)"),
+		),
 	)
 	mcpServer.AddTool(splitAndStoreMarkdownSectionsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
 
 		document, ok := args["document"].(string)
 		if !ok || document == "" {
@@ -41,8 +77,26 @@ func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Clie
 		}
 
 		label, _ := args["label"].(string)
+		label, err := store.JoinLabels(label, stringArrayArgument(args, "labels"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx = store.WithUsageLabel(ctx, label)
 		metadata, _ := args["metadata"].(string)
 
+		idStrategy, _ := args["id_strategy"].(string)
+		if err := store.ValidateIDStrategy(idStrategy); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		sourceID, _ := args["source_id"].(string)
+		continueOnError, _ := args["continue_on_error"].(bool)
+		rollback, _ := args["rollback"].(bool)
+		atomic, _ := args["atomic"].(bool)
+		if err := store.ValidateAtomic(atomic, continueOnError); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		includeContent, _ := args["include_content"].(bool)
+
 		// Split markdown by sections
 		sections := splitter.SplitMarkdownBySections(document)
 
@@ -50,65 +104,83 @@ func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Clie
 			return mcp.NewToolResultError("No sections generated from the document"), nil
 		}
 
-		// Get embedding dimension for validation
-		embeddingDim := GetEmbeddingDimension()
+		// Get the embedding model context window for validation
+		maxTokens := GetEmbeddingMaxTokens()
 
-		// Store all sections (subdividing if necessary)
-		chunkIDs := make([]string, 0)
-		createdAt := time.Now()
+		// Collect all sections to store (subdividing if necessary)
+		allChunks := make([]string, 0, len(sections))
 
 		for _, section := range sections {
-			// Extract section header (if any)
-			sectionHeader := splitter.ExtractSectionHeader(section)
-
-			// If section is larger than embedding dimension, subdivide it
-			var chunksToStore []string
-			if len(section) > embeddingDim {
-				// Subdivide the section into smaller chunks without overlap
-				chunksToStore = splitter.ChunkText(section, embeddingDim, 0)
-				log.Println("🟠 Section exceeded embedding dimension, subdivided into", len(chunksToStore), "chunks")
-
-				// If we have a header and subdivided chunks, prepend the header to each sub-chunk
-				// (except the first one which already contains it)
-				if sectionHeader != "" && len(chunksToStore) > 1 {
-					for i := 1; i < len(chunksToStore); i++ {
-						chunksToStore[i] = sectionHeader + "\n\n" + chunksToStore[i]
-						log.Println("🟡", i+1, chunksToStore[i])
-					}
-				}
-
-			} else {
-				chunksToStore = []string{section}
+			// If section is larger than the embedding model context window, subdivide it
+			// and prepend the section header (if any) to each sub-chunk (except the first one which already contains it)
+			chunksToStore := splitter.SubdivideWithHeader(section, splitter.ExtractSectionHeader(section), maxTokens)
+			if len(chunksToStore) > 1 {
+				helpers.Logf(ctx, "🟠 Section exceeded embedding model max tokens, subdivided into %d chunks", len(chunksToStore))
 			}
 
-			// Store each chunk
-			for _, chunk := range chunksToStore {
-				// Create embedding from chunk text
-				embedding, err := store.CreateEmbeddingFromText(ctx, openaiClient, chunk, embeddingModelId)
-				if err != nil {
-					return mcp.NewToolResultError(fmt.Sprintf("Failed to create embedding for chunk: %v", err)), nil
-				}
+			allChunks = append(allChunks, chunksToStore...)
+		}
+
+		// Resolve the collection of the chunks
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
+		ttl, err := ttlArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		dedup, err := dedupArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		chunkOptions := store.ChunkOptions{
+			Label:           label,
+			Metadata:        metadata,
+			IDStrategy:      idStrategy,
+			SourceID:        sourceID,
+			ContinueOnError: continueOnError,
+			Rollback:        rollback,
+			Atomic:          atomic,
+			Original:        document,
+			KeyPrefix:       collection.KeyPrefix,
+			TTL:             ttl,
+			Dedup:           dedup,
+			IndexName:       collection.IndexName,
+		}
 
-				// Generate unique document ID for this chunk
-				chunkID := fmt.Sprintf("doc:%s", uuid.New().String())
+		// Store the chunks in the background: the job reports the progress
+		if async, _ := args["async"].(bool); async {
+			return ingestionJobResult(store.StartIngestionJob(ctx, openaiClient, redisClient, modelId, allChunks, chunkOptions)), nil
+		}
 
-				// Store embedding in Redis with the same label and metadata for all chunks
-				err = store.StoreEmbedding(ctx, redisClient, chunkID, chunk, embedding, label, metadata)
-				if err != nil {
-					return mcp.NewToolResultError(fmt.Sprintf("Failed to store chunk embedding: %v", err)), nil
-				}
+		// Store all chunks
+		createdAt := time.Now()
+		statuses, err := store.StoreChunks(ctx, openaiClient, redisClient, modelId, allChunks, chunkOptions)
+		if err != nil {
+			return chunkStoreError(statuses, err), nil
+		}
 
-				chunkIDs = append(chunkIDs, chunkID)
-			}
+		chunkIDs, chunksFailed := store.StoredChunkIDs(statuses)
+		if len(chunkIDs) == 0 && chunksFailed > 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("All %d chunks failed to be stored: %s", chunksFailed, statuses[0].Error)), nil
 		}
 
-		// Success response
+		// Success response (or partial success when some chunks failed in continue_on_error mode)
 		result := map[string]interface{}{
-			"success":       true,
+			"success":       chunksFailed == 0,
+			"source_id":     store.OriginalSourceID(sourceID, document),
 			"chunk_ids":     chunkIDs,
+			"chunks":        store.ChunkPreviews(allChunks, statuses, includeContent),
 			"chunks_stored": len(chunkIDs),
 			"created_at":    createdAt.Format(time.RFC3339),
 		}
+		if continueOnError {
+			result["chunks_failed"] = chunksFailed
+			result["chunk_statuses"] = statuses
+		}
 
 		resultJSON, _ := json.Marshal(result)
 		return mcp.NewToolResultText(string(resultJSON)), nil
@@ -116,7 +188,7 @@ func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Clie
 
 	// Split and store with delimiter tool
 	splitAndStoreWithDelimiterTool := mcp.NewTool("split_and_store_with_delimiter",
-		mcp.WithDescription("Split a document by a custom delimiter and store all chunks with embeddings. Chunks larger than embedding dimension are automatically subdivided with the first 2 non-empty lines prepended to preserve context. All chunks will share the same label and metadata."),
+		mcp.WithDescription("Split a document by a custom delimiter and store all chunks with embeddings. Chunks larger than the embedding model max input tokens are automatically subdivided with the first 2 non-empty lines prepended to preserve context. All chunks will share the same label and metadata."),
 		mcp.WithString("document",
 			mcp.Required(),
 			mcp.Description("The document content to split and store"),
@@ -128,12 +200,49 @@ func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Clie
 		mcp.WithString("label",
 			mcp.Description("Optional label to apply to all chunks"),
 		),
+		mcp.WithArray("labels",
+			mcp.Description("Optional additional labels of the chunks (a document can have several labels)"),
+			mcp.WithStringItems(),
+		),
 		mcp.WithString("metadata",
 			mcp.Description("Optional metadata to apply to all chunks"),
 		),
+		mcp.WithString("id_strategy",
+			mcp.Description("Optional chunk ID strategy: 'uuid' (default, random IDs) or 'content_hash' (IDs derived from source_id, chunk index and content, re-ingesting the same document overwrites the same chunks)"),
+			mcp.Enum("uuid", "content_hash"),
+		),
+		mcp.WithString("source_id",
+			mcp.Description("Optional identifier of the source document, used by the 'content_hash' id_strategy (default: hash of the document)"),
+		),
+		mcp.WithBoolean("continue_on_error",
+			mcp.Description("Optional: keep storing the remaining chunks when a chunk fails and return the status of each chunk (default: false, the first failure aborts)"),
+		),
+		mcp.WithBoolean("rollback",
+			mcp.Description("Optional: delete the chunks already stored when a failed chunk aborts the ingestion (default: false, ignored with continue_on_error)"),
+		),
+		mcp.WithBoolean("atomic",
+			mcp.Description("Optional: create all the embeddings before storing the chunks in a single transaction, so that all the chunks are stored or none (default: false, cannot be combined with continue_on_error)"),
+		),
+		mcp.WithBoolean("include_content",
+			mcp.Description("Optional: return the full text of each stored chunk instead of a preview of its first 100 characters (default: false)"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the chunks (default: the main index)"),
+		),
+		mcp.WithNumber("ttl_seconds",
+			mcp.Description("Optional time in seconds after which the chunks are deleted (default: no expiration)"),
+		),
+		mcp.WithString("dedup",
+			mcp.Description("Optional handling of the chunks whose content is already stored: 'off' (default, always store), 'skip' (return the ID of the stored chunk) or 'upsert' (store in place of the stored chunk)"),
+			mcp.Enum("off", "skip", "upsert"),
+		),
+		mcp.WithBoolean("async",
+			mcp.Description("Optional: return an ingestion job immediately and store the chunks in the background, follow it with get_ingestion_status (default: false)"),
+		),
 	)
 	mcpServer.AddTool(splitAndStoreWithDelimiterTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
 
 		document, ok := args["document"].(string)
 		if !ok || document == "" {
@@ -146,8 +255,26 @@ func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Clie
 		}
 
 		label, _ := args["label"].(string)
+		label, err := store.JoinLabels(label, stringArrayArgument(args, "labels"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx = store.WithUsageLabel(ctx, label)
 		metadata, _ := args["metadata"].(string)
 
+		idStrategy, _ := args["id_strategy"].(string)
+		if err := store.ValidateIDStrategy(idStrategy); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		sourceID, _ := args["source_id"].(string)
+		continueOnError, _ := args["continue_on_error"].(bool)
+		rollback, _ := args["rollback"].(bool)
+		atomic, _ := args["atomic"].(bool)
+		if err := store.ValidateAtomic(atomic, continueOnError); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		includeContent, _ := args["include_content"].(bool)
+
 		// Split text by delimiter
 		chunks := splitter.SplitTextWithDelimiter(document, delimiter)
 
@@ -155,65 +282,83 @@ func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Clie
 			return mcp.NewToolResultError("No chunks generated from the document"), nil
 		}
 
-		// Get embedding dimension for validation
-		embeddingDim := GetEmbeddingDimension()
+		// Get the embedding model context window for validation
+		maxTokens := GetEmbeddingMaxTokens()
 
-		// Store all chunks (subdividing if necessary)
-		chunkIDs := make([]string, 0)
-		createdAt := time.Now()
+		// Collect all chunks to store (subdividing if necessary)
+		allChunks := make([]string, 0, len(chunks))
 
 		for _, chunk := range chunks {
-			// Extract first 2 non-empty lines from the chunk
-			chunkHeader := splitter.ExtractFirstNonEmptyLines(chunk, 2)
-
-			// If chunk is larger than embedding dimension, subdivide it
-			var chunksToStore []string
-			if len(chunk) > embeddingDim {
-				// Subdivide the chunk into smaller pieces without overlap
-				chunksToStore = splitter.ChunkText(chunk, embeddingDim, 0)
-				log.Println("🟠 Chunk exceeded embedding dimension, subdivided into", len(chunksToStore), "chunks")
-
-				// If we have a header and subdivided chunks, prepend the header to each sub-chunk
-				// (except the first one which already contains it)
-				if chunkHeader != "" && len(chunksToStore) > 1 {
-					for i := 1; i < len(chunksToStore); i++ {
-						chunksToStore[i] = chunkHeader + "\n\n" + chunksToStore[i]
-						log.Println("🟡", i+1, "Prepended header to sub-chunk")
-					}
-				}
-
-			} else {
-				chunksToStore = []string{chunk}
+			// If chunk is larger than the embedding model context window, subdivide it
+			// and prepend its first 2 non-empty lines to each sub-chunk (except the first one which already contains them)
+			chunksToStore := splitter.SubdivideWithHeader(chunk, splitter.ExtractFirstNonEmptyLines(chunk, 2), maxTokens)
+			if len(chunksToStore) > 1 {
+				helpers.Logf(ctx, "🟠 Chunk exceeded embedding model max tokens, subdivided into %d chunks", len(chunksToStore))
 			}
 
-			// Store each chunk
-			for _, chunkToStore := range chunksToStore {
-				// Create embedding from chunk text
-				embedding, err := store.CreateEmbeddingFromText(ctx, openaiClient, chunkToStore, embeddingModelId)
-				if err != nil {
-					return mcp.NewToolResultError(fmt.Sprintf("Failed to create embedding for chunk: %v", err)), nil
-				}
+			allChunks = append(allChunks, chunksToStore...)
+		}
 
-				// Generate unique document ID for this chunk
-				chunkID := fmt.Sprintf("doc:%s", uuid.New().String())
+		// Resolve the collection of the chunks
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
+		ttl, err := ttlArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		dedup, err := dedupArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 
-				// Store embedding in Redis with the same label and metadata for all chunks
-				err = store.StoreEmbedding(ctx, redisClient, chunkID, chunkToStore, embedding, label, metadata)
-				if err != nil {
-					return mcp.NewToolResultError(fmt.Sprintf("Failed to store chunk embedding: %v", err)), nil
-				}
+		chunkOptions := store.ChunkOptions{
+			Label:           label,
+			Metadata:        metadata,
+			IDStrategy:      idStrategy,
+			SourceID:        sourceID,
+			ContinueOnError: continueOnError,
+			Rollback:        rollback,
+			Atomic:          atomic,
+			Original:        document,
+			KeyPrefix:       collection.KeyPrefix,
+			TTL:             ttl,
+			Dedup:           dedup,
+			IndexName:       collection.IndexName,
+		}
 
-				chunkIDs = append(chunkIDs, chunkID)
-			}
+		// Store the chunks in the background: the job reports the progress
+		if async, _ := args["async"].(bool); async {
+			return ingestionJobResult(store.StartIngestionJob(ctx, openaiClient, redisClient, modelId, allChunks, chunkOptions)), nil
+		}
+
+		// Store all chunks
+		createdAt := time.Now()
+		statuses, err := store.StoreChunks(ctx, openaiClient, redisClient, modelId, allChunks, chunkOptions)
+		if err != nil {
+			return chunkStoreError(statuses, err), nil
 		}
 
-		// Success response
+		chunkIDs, chunksFailed := store.StoredChunkIDs(statuses)
+		if len(chunkIDs) == 0 && chunksFailed > 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("All %d chunks failed to be stored: %s", chunksFailed, statuses[0].Error)), nil
+		}
+
+		// Success response (or partial success when some chunks failed in continue_on_error mode)
 		result := map[string]interface{}{
-			"success":       true,
+			"success":       chunksFailed == 0,
+			"source_id":     store.OriginalSourceID(sourceID, document),
 			"chunk_ids":     chunkIDs,
+			"chunks":        store.ChunkPreviews(allChunks, statuses, includeContent),
 			"chunks_stored": len(chunkIDs),
 			"created_at":    createdAt.Format(time.RFC3339),
 		}
+		if continueOnError {
+			result["chunks_failed"] = chunksFailed
+			result["chunk_statuses"] = statuses
+		}
 
 		resultJSON, _ := json.Marshal(result)
 		return mcp.NewToolResultText(string(resultJSON)), nil
@@ -221,7 +366,7 @@ func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Clie
 
 	// Split and store markdown with hierarchy tool (EXPERIMENTAL)
 	splitAndStoreMarkdownWithHierarchyTool := mcp.NewTool("split_and_store_markdown_with_hierarchy",
-		mcp.WithDescription("EXPERIMENTAL: Split a markdown document by headers, preserving hierarchical context (parent headers) in each chunk. Each chunk includes TITLE, HIERARCHY, and CONTENT metadata. Chunks larger than embedding dimension are automatically subdivided. All chunks share the same label and metadata."),
+		mcp.WithDescription("EXPERIMENTAL: Split a markdown document by headers, preserving hierarchical context (parent headers) in each chunk. Each chunk includes TITLE, HIERARCHY, and CONTENT metadata. Chunks larger than the embedding model max input tokens are automatically subdivided. All chunks share the same label and metadata."),
 		mcp.WithString("document",
 			mcp.Required(),
 			mcp.Description("The markdown document content to split and store"),
@@ -229,12 +374,49 @@ func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Clie
 		mcp.WithString("label",
 			mcp.Description("Optional label to apply to all chunks"),
 		),
+		mcp.WithArray("labels",
+			mcp.Description("Optional additional labels of the chunks (a document can have several labels)"),
+			mcp.WithStringItems(),
+		),
 		mcp.WithString("metadata",
 			mcp.Description("Optional metadata to apply to all chunks"),
 		),
+		mcp.WithString("id_strategy",
+			mcp.Description("Optional chunk ID strategy: 'uuid' (default, random IDs) or 'content_hash' (IDs derived from source_id, chunk index and content, re-ingesting the same document overwrites the same chunks)"),
+			mcp.Enum("uuid", "content_hash"),
+		),
+		mcp.WithString("source_id",
+			mcp.Description("Optional identifier of the source document, used by the 'content_hash' id_strategy (default: hash of the document)"),
+		),
+		mcp.WithBoolean("continue_on_error",
+			mcp.Description("Optional: keep storing the remaining chunks when a chunk fails and return the status of each chunk (default: false, the first failure aborts)"),
+		),
+		mcp.WithBoolean("rollback",
+			mcp.Description("Optional: delete the chunks already stored when a failed chunk aborts the ingestion (default: false, ignored with continue_on_error)"),
+		),
+		mcp.WithBoolean("atomic",
+			mcp.Description("Optional: create all the embeddings before storing the chunks in a single transaction, so that all the chunks are stored or none (default: false, cannot be combined with continue_on_error)"),
+		),
+		mcp.WithBoolean("include_content",
+			mcp.Description("Optional: return the full text of each stored chunk instead of a preview of its first 100 characters (default: false)"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the chunks (default: the main index)"),
+		),
+		mcp.WithNumber("ttl_seconds",
+			mcp.Description("Optional time in seconds after which the chunks are deleted (default: no expiration)"),
+		),
+		mcp.WithString("dedup",
+			mcp.Description("Optional handling of the chunks whose content is already stored: 'off' (default, always store), 'skip' (return the ID of the stored chunk) or 'upsert' (store in place of the stored chunk)"),
+			mcp.Enum("off", "skip", "upsert"),
+		),
+		mcp.WithBoolean("async",
+			mcp.Description("Optional: return an ingestion job immediately and store the chunks in the background, follow it with get_ingestion_status (default: false)"),
+		),
 	)
 	mcpServer.AddTool(splitAndStoreMarkdownWithHierarchyTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
 
 		document, ok := args["document"].(string)
 		if !ok || document == "" {
@@ -242,8 +424,26 @@ func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Clie
 		}
 
 		label, _ := args["label"].(string)
+		label, err := store.JoinLabels(label, stringArrayArgument(args, "labels"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		ctx = store.WithUsageLabel(ctx, label)
 		metadata, _ := args["metadata"].(string)
 
+		idStrategy, _ := args["id_strategy"].(string)
+		if err := store.ValidateIDStrategy(idStrategy); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		sourceID, _ := args["source_id"].(string)
+		continueOnError, _ := args["continue_on_error"].(bool)
+		rollback, _ := args["rollback"].(bool)
+		atomic, _ := args["atomic"].(bool)
+		if err := store.ValidateAtomic(atomic, continueOnError); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		includeContent, _ := args["include_content"].(bool)
+
 		// Split markdown with hierarchy
 		chunks := splitter.ChunkWithMarkdownHierarchy(document)
 
@@ -251,52 +451,82 @@ func RegisterMarkdownTools(mcpServer *server.MCPServer, openaiClient openai.Clie
 			return mcp.NewToolResultError("No chunks generated from the document"), nil
 		}
 
-		// Get embedding dimension for validation
-		embeddingDim := GetEmbeddingDimension()
+		// Get the embedding model context window for validation
+		maxTokens := GetEmbeddingMaxTokens()
 
-		// Store all chunks (subdividing if necessary)
-		chunkIDs := make([]string, 0)
-		createdAt := time.Now()
+		// Collect all chunks to store (subdividing if necessary)
+		allChunks := make([]string, 0, len(chunks))
 
 		for _, chunk := range chunks {
-			// If chunk is larger than embedding dimension, subdivide it
-			var chunksToStore []string
-			if len(chunk) > embeddingDim {
-				// Subdivide the chunk into smaller chunks without overlap
-				chunksToStore = splitter.ChunkText(chunk, embeddingDim, 0)
-				log.Println("🟠 Chunk exceeded embedding dimension, subdivided into", len(chunksToStore), "chunks")
-			} else {
-				chunksToStore = []string{chunk}
+			// If chunk is larger than the embedding model context window, subdivide it
+			chunksToStore := splitter.ChunkTextByTokens(chunk, maxTokens)
+			if len(chunksToStore) > 1 {
+				helpers.Logf(ctx, "🟠 Chunk exceeded embedding model max tokens, subdivided into %d chunks", len(chunksToStore))
 			}
 
-			// Store each sub-chunk
-			for _, subChunk := range chunksToStore {
-				// Create embedding from chunk text
-				embedding, err := store.CreateEmbeddingFromText(ctx, openaiClient, subChunk, embeddingModelId)
-				if err != nil {
-					return mcp.NewToolResultError(fmt.Sprintf("Failed to create embedding for chunk: %v", err)), nil
-				}
+			allChunks = append(allChunks, chunksToStore...)
+		}
 
-				// Generate unique document ID for this chunk
-				chunkID := fmt.Sprintf("doc:%s", uuid.New().String())
+		// Resolve the collection of the chunks
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
+		ttl, err := ttlArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		dedup, err := dedupArgument(args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
 
-				// Store embedding in Redis with the same label and metadata for all chunks
-				err = store.StoreEmbedding(ctx, redisClient, chunkID, subChunk, embedding, label, metadata)
-				if err != nil {
-					return mcp.NewToolResultError(fmt.Sprintf("Failed to store chunk embedding: %v", err)), nil
-				}
+		chunkOptions := store.ChunkOptions{
+			Label:           label,
+			Metadata:        metadata,
+			IDStrategy:      idStrategy,
+			SourceID:        sourceID,
+			ContinueOnError: continueOnError,
+			Rollback:        rollback,
+			Atomic:          atomic,
+			Original:        document,
+			KeyPrefix:       collection.KeyPrefix,
+			TTL:             ttl,
+			Dedup:           dedup,
+			IndexName:       collection.IndexName,
+		}
 
-				chunkIDs = append(chunkIDs, chunkID)
-			}
+		// Store the chunks in the background: the job reports the progress
+		if async, _ := args["async"].(bool); async {
+			return ingestionJobResult(store.StartIngestionJob(ctx, openaiClient, redisClient, modelId, allChunks, chunkOptions)), nil
+		}
+
+		// Store all chunks
+		createdAt := time.Now()
+		statuses, err := store.StoreChunks(ctx, openaiClient, redisClient, modelId, allChunks, chunkOptions)
+		if err != nil {
+			return chunkStoreError(statuses, err), nil
 		}
 
-		// Success response
+		chunkIDs, chunksFailed := store.StoredChunkIDs(statuses)
+		if len(chunkIDs) == 0 && chunksFailed > 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("All %d chunks failed to be stored: %s", chunksFailed, statuses[0].Error)), nil
+		}
+
+		// Success response (or partial success when some chunks failed in continue_on_error mode)
 		result := map[string]interface{}{
-			"success":       true,
+			"success":       chunksFailed == 0,
+			"source_id":     store.OriginalSourceID(sourceID, document),
 			"chunk_ids":     chunkIDs,
+			"chunks":        store.ChunkPreviews(allChunks, statuses, includeContent),
 			"chunks_stored": len(chunkIDs),
 			"created_at":    createdAt.Format(time.RFC3339),
 		}
+		if continueOnError {
+			result["chunks_failed"] = chunksFailed
+			result["chunk_statuses"] = statuses
+		}
 
 		resultJSON, _ := json.Marshal(result)
 		return mcp.NewToolResultText(string(resultJSON)), nil
@@ -0,0 +1,62 @@
+package mcptools
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+)
+
+// RegisterRerankTool registers the rerank_results tool
+func RegisterRerankTool(mcpServer *server.MCPServer) {
+	rerankResultsTool := mcp.NewTool("rerank_results",
+		mcp.WithDescription("Rerank search results by relevance to a query with the reranker (a rerank model or the chat model, RERANK_PROVIDER), e.g. the results of similarity_search retrieved with a max_count 3 to 5 times larger than needed. Returns the most relevant results first, with their rerank_score."),
+		mcp.WithString("query",
+			mcp.Required(),
+			mcp.Description("The query the results are ranked for"),
+		),
+		mcp.WithArray("results",
+			mcp.Required(),
+			mcp.Description("The results to rerank, as returned by the search tools (objects with at least a content, and usually an id)"),
+			mcp.Items(map[string]any{"type": "object"}),
+		),
+		mcp.WithNumber("max_count",
+			mcp.Description("Maximum number of results to return (default: all the results)"),
+		),
+	)
+	mcpServer.AddTool(rerankResultsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+
+		query, ok := args["query"].(string)
+		if !ok || query == "" {
+			return mcp.NewToolResultError("query parameter is required"), nil
+		}
+
+		// The results keep all the fields of the search tools
+		var results []models.SimilaritySearchResult
+		rawResults, _ := json.Marshal(args["results"])
+		if err := json.Unmarshal(rawResults, &results); err != nil || len(results) == 0 {
+			return mcp.NewToolResultError(fmt.Sprintf("results parameter must be a non-empty array of search results (%v)", err)), nil
+		}
+
+		maxCount := len(results)
+		if mc, ok := args["max_count"].(float64); ok && mc > 0 {
+			maxCount = int(mc)
+		}
+
+		reranked, err := store.RerankResults(ctx, query, results, maxCount)
+		if err != nil {
+			return storeErrorResult("Rerank failed", err), nil
+		}
+
+		resultJSON, _ := json.Marshal(map[string]interface{}{
+			"success": true,
+			"results": reranked,
+		})
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+}
@@ -4,9 +4,8 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"sort"
-	"strconv"
 	"time"
+	"vectormind/helpers"
 	"vectormind/models"
 	"vectormind/store"
 
@@ -16,7 +15,25 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// RegisterSearchTools registers the similarity_search and similarity_search_with_label tools
+// searchClient returns the Redis client of the searches, a read replica of the database of the tools (nil: the
+// searches use the client of the tools)
+var searchClient func() *redis.Client
+
+// SetSearchClient routes the searches of the tools to the client returned by the function, e.g. a read replica
+func SetSearchClient(client func() *redis.Client) {
+	searchClient = client
+}
+
+// searchRedisClient returns the Redis client of a search (see SetSearchClient)
+func searchRedisClient(redisClient *redis.Client) *redis.Client {
+	if searchClient == nil {
+		return redisClient
+	}
+	return searchClient()
+}
+
+// RegisterSearchTools registers the similarity_search, similarity_search_with_label, similarity_search_with_labels
+// and hybrid_search tools
 func RegisterSearchTools(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, redisIndexName string) {
 	// Similarity search tool
 	similaritySearchTool := mcp.NewTool("similarity_search",
@@ -31,9 +48,47 @@ func RegisterSearchTools(mcpServer *server.MCPServer, openaiClient openai.Client
 		mcp.WithNumber("distance_threshold",
 			mcp.Description("Optional distance threshold. Only returns documents with distance <= threshold"),
 		),
+		mcp.WithNumber("min_quality",
+			mcp.Description("Optional minimum quality score (0 to 1). Only returns documents with quality >= min_quality"),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description("Optional time budget of the search in milliseconds (query embedding and vector search)"),
+		),
+		mcp.WithBoolean("keyword_fallback",
+			mcp.Description("Optional: return keyword search results (without distance) when the query embedding does not complete within timeout_ms (default: false)"),
+		),
+		mcp.WithObject("filters",
+			mcp.Description(`Optional filters on the JSON metadata fields, e.g. {"source":"wiki","tags":["go","redis"],"year":{"gte":2020}} (operators: eq, in, gt, gte, lt, lte)`),
+		),
+		mcp.WithNumber("snippet_size",
+			mcp.Description("Optional size in characters (up to 1000) of a snippet added to each result, centered on its sentence most similar to the query (default: 0, no snippets)"),
+		),
+		mcp.WithNumber("max_content_chars",
+			mcp.Description("Optional maximum number of characters of the content of each result: a longer content is cut and flagged with is_truncated, get_document returns the rest from next_offset (default: 0, whole content)"),
+		),
+		mcp.WithNumber("expand_context",
+			mcp.Description("Optional number of chunks (up to 10) before and after each chunk of the results, from the same document, concatenated with it in expanded_content (default: 0, no expansion)"),
+		),
+		mcp.WithNumber("diversity",
+			mcp.Description("Optional diversity of the results from 0 to 1: the results are selected among more candidates by maximal marginal relevance, so that they are not near duplicates of each other, e.g. 0.3 (default: 0, ordered by similarity)"),
+		),
+		mcp.WithNumber("recency_half_life_hours",
+			mcp.Description("Optional half-life in hours of the freshness of the documents: the results are ranked by similarity and freshness, so that the recent memories outrank the stale ones, e.g. 168 for a week (default: 0, ordered by similarity)"),
+		),
+		mcp.WithNumber("recency_weight",
+			mcp.Description("Optional share of the freshness in the recency ranking, from 0 to 1 (default: 0.3)"),
+		),
+		mcp.WithBoolean("expand_query",
+			mcp.Description("Optional: also search 3 to 5 paraphrases of the query generated by the chat model, and fuse the rankings, to find more relevant documents for a short or ambiguous query (default: false)"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the documents (default: the main index)"),
+		),
 	)
 	mcpServer.AddTool(similaritySearchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args := request.GetArguments()
+		redisClient := searchRedisClient(redisClient)
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
 
 		text, ok := args["text"].(string)
 		if !ok || text == "" {
@@ -53,54 +108,97 @@ func RegisterSearchTools(mcpServer *server.MCPServer, openaiClient openai.Client
 			distanceThreshold = &dt
 		}
 
-		// Create embedding from query text
-		queryEmbedding, err := store.CreateEmbeddingFromText(ctx, openaiClient, text, embeddingModelId)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to create embedding: %v", err)), nil
-		}
-
-		// Perform similarity search
-		docs, err := store.SimilaritySearch(ctx, redisClient, redisIndexName, queryEmbedding, maxCount)
-		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to perform similarity search: %v", err)), nil
+		var minQuality *float64
+		if mq, ok := args["min_quality"].(float64); ok {
+			minQuality = &mq
 		}
 
-		// Convert results to response format
-		results := make([]models.SimilaritySearchResult, 0, len(docs))
-		for _, doc := range docs {
-			str := doc.Fields["vector_distance"]
-			distance, err := strconv.ParseFloat(str, 32)
-			if err != nil {
-				distance = 0.0
-			}
+		timeoutMs, _ := args["timeout_ms"].(float64)
+		keywordFallback, _ := args["keyword_fallback"].(bool)
 
-			if distanceThreshold != nil && distance > *distanceThreshold {
-				continue
-			}
+		snippetSize, _ := args["snippet_size"].(float64)
+		if err := store.ValidateSnippetSize(int(snippetSize)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		maxContentChars, _ := args["max_content_chars"].(float64)
+		if err := store.ValidateMaxContentChars(int(maxContentChars)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		expandContext, _ := args["expand_context"].(float64)
+		if err := store.ValidateExpandContext(int(expandContext)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		diversity, _ := args["diversity"].(float64)
+		if err := store.ValidateDiversity(diversity); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		recencyHalfLife, _ := args["recency_half_life_hours"].(float64)
+		recencyWeight, _ := args["recency_weight"].(float64)
+		if err := store.ValidateRecency(recencyHalfLife, recencyWeight); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		expand, _ := args["expand_query"].(bool)
+		if expand && !store.ExpansionEnabled() {
+			return mcp.NewToolResultError("Query expansion is not available (CHAT_MODEL is not set, or the query expansion feature is disabled)"), nil
+		}
 
-			createdAtUnix, _ := strconv.ParseInt(doc.Fields["created_at"], 10, 64)
-			createdAt := time.Unix(createdAtUnix, 0).Format(time.RFC3339)
+		rawFilters, _ := args["filters"].(map[string]interface{})
+		filters, err := store.ParseMetadataFilters(rawFilters)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid filters: %v", err)), nil
+		}
 
-			result := models.SimilaritySearchResult{
-				ID:        doc.ID,
-				Content:   doc.Fields["content"],
-				Label:     doc.Fields["label"],
-				Metadata:  doc.Fields["metadata"],
-				Distance:  distance,
-				CreatedAt: createdAt,
-			}
+		// Resolve the collection of the documents
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
 
-			results = append(results, result)
+		// Paraphrases of the query searched with it (the search goes on without them when the chat model fails)
+		var variants []string
+		if expand {
+			variants = expandQuery(ctx, text)
 		}
 
-		sort.Slice(results, func(i, j int) bool {
-			return results[i].Distance < results[j].Distance
+		// Perform similarity search (query embedding and vector search within the time budget)
+		docs, fallback, err := store.SearchByText(ctx, openaiClient, redisClient, modelId, collection.IndexName, text, searchCount(maxCount, recencyHalfLife > 0), store.SearchOptions{
+			MinQuality:    minQuality,
+			MaxDistance:   distanceThreshold,
+			Filters:       filters,
+			Diversity:     diversity,
+			QueryVariants: variants,
+		}, store.TextSearchBudget{
+			Timeout:         time.Duration(timeoutMs) * time.Millisecond,
+			KeywordFallback: keywordFallback,
 		})
+		if err != nil {
+			return storeErrorResult("Search failed", err), nil
+		}
+
+		// Convert results to response format
+		results := store.TextSearchResults(docs, fallback, distanceThreshold)
+		if recencyHalfLife > 0 {
+			results = rankByRecency(results, fallback, recencyHalfLife, recencyWeight, maxCount)
+		}
+		if snippetSize > 0 && fallback == "" {
+			addSnippets(ctx, openaiClient, text, results, modelId, int(snippetSize))
+		}
+		if expandContext > 0 {
+			expandSearchResults(ctx, redisClient, collection.IndexName, results, int(expandContext))
+		}
+		store.TruncateSearchResults(results, int(maxContentChars))
 
 		response := map[string]interface{}{
 			"success": true,
 			"results": results,
 		}
+		if fallback != "" {
+			response["fallback"] = fallback
+		}
+		if len(variants) > 0 {
+			response["expanded_queries"] = variants
+		}
 
 		resultJSON, _ := json.Marshal(response)
 		return mcp.NewToolResultText(string(resultJSON)), nil
@@ -123,9 +221,47 @@ func RegisterSearchTools(mcpServer *server.MCPServer, openaiClient openai.Client
 		mcp.WithNumber("distance_threshold",
 			mcp.Description("Optional distance threshold. Only returns documents with distance <= threshold"),
 		),
+		mcp.WithNumber("min_quality",
+			mcp.Description("Optional minimum quality score (0 to 1). Only returns documents with quality >= min_quality"),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description("Optional time budget of the search in milliseconds (query embedding and vector search)"),
+		),
+		mcp.WithBoolean("keyword_fallback",
+			mcp.Description("Optional: return keyword search results (without distance) when the query embedding does not complete within timeout_ms (default: false)"),
+		),
+		mcp.WithObject("filters",
+			mcp.Description(`Optional filters on the JSON metadata fields, e.g. {"source":"wiki","tags":["go","redis"],"year":{"gte":2020}} (operators: eq, in, gt, gte, lt, lte)`),
+		),
+		mcp.WithNumber("snippet_size",
+			mcp.Description("Optional size in characters (up to 1000) of a snippet added to each result, centered on its sentence most similar to the query (default: 0, no snippets)"),
+		),
+		mcp.WithNumber("max_content_chars",
+			mcp.Description("Optional maximum number of characters of the content of each result: a longer content is cut and flagged with is_truncated, get_document returns the rest from next_offset (default: 0, whole content)"),
+		),
+		mcp.WithNumber("expand_context",
+			mcp.Description("Optional number of chunks (up to 10) before and after each chunk of the results, from the same document, concatenated with it in expanded_content (default: 0, no expansion)"),
+		),
+		mcp.WithNumber("diversity",
+			mcp.Description("Optional diversity of the results from 0 to 1: the results are selected among more candidates by maximal marginal relevance, so that they are not near duplicates of each other, e.g. 0.3 (default: 0, ordered by similarity)"),
+		),
+		mcp.WithNumber("recency_half_life_hours",
+			mcp.Description("Optional half-life in hours of the freshness of the documents: the results are ranked by similarity and freshness, so that the recent memories outrank the stale ones, e.g. 168 for a week (default: 0, ordered by similarity)"),
+		),
+		mcp.WithNumber("recency_weight",
+			mcp.Description("Optional share of the freshness in the recency ranking, from 0 to 1 (default: 0.3)"),
+		),
+		mcp.WithBoolean("expand_query",
+			mcp.Description("Optional: also search 3 to 5 paraphrases of the query generated by the chat model, and fuse the rankings, to find more relevant documents for a short or ambiguous query (default: false)"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the documents (default: the main index)"),
+		),
 	)
 	mcpServer.AddTool(similaritySearchWithLabelTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
 		args := request.GetArguments()
+		redisClient := searchRedisClient(redisClient)
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
 
 		text, ok := args["text"].(string)
 		if !ok || text == "" {
@@ -150,52 +286,475 @@ func RegisterSearchTools(mcpServer *server.MCPServer, openaiClient openai.Client
 			distanceThreshold = &dt
 		}
 
-		// Create embedding from query text
-		queryEmbedding, err := store.CreateEmbeddingFromText(ctx, openaiClient, text, embeddingModelId)
+		var minQuality *float64
+		if mq, ok := args["min_quality"].(float64); ok {
+			minQuality = &mq
+		}
+
+		timeoutMs, _ := args["timeout_ms"].(float64)
+		keywordFallback, _ := args["keyword_fallback"].(bool)
+
+		snippetSize, _ := args["snippet_size"].(float64)
+		if err := store.ValidateSnippetSize(int(snippetSize)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		maxContentChars, _ := args["max_content_chars"].(float64)
+		if err := store.ValidateMaxContentChars(int(maxContentChars)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		expandContext, _ := args["expand_context"].(float64)
+		if err := store.ValidateExpandContext(int(expandContext)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		diversity, _ := args["diversity"].(float64)
+		if err := store.ValidateDiversity(diversity); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		recencyHalfLife, _ := args["recency_half_life_hours"].(float64)
+		recencyWeight, _ := args["recency_weight"].(float64)
+		if err := store.ValidateRecency(recencyHalfLife, recencyWeight); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		expand, _ := args["expand_query"].(bool)
+		if expand && !store.ExpansionEnabled() {
+			return mcp.NewToolResultError("Query expansion is not available (CHAT_MODEL is not set, or the query expansion feature is disabled)"), nil
+		}
+
+		rawFilters, _ := args["filters"].(map[string]interface{})
+		filters, err := store.ParseMetadataFilters(rawFilters)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid filters: %v", err)), nil
+		}
+
+		// Resolve the collection of the documents
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to create embedding: %v", err)), nil
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
+
+		// Paraphrases of the query searched with it (the search goes on without them when the chat model fails)
+		var variants []string
+		if expand {
+			variants = expandQuery(ctx, text)
 		}
 
-		// Perform similarity search with label filter
-		docs, err := store.SimilaritySearchWithLabel(ctx, redisClient, redisIndexName, queryEmbedding, maxCount, label)
+		// Perform similarity search with label filter (query embedding and vector search within the time budget)
+		docs, fallback, err := store.SearchByText(ctx, openaiClient, redisClient, modelId, collection.IndexName, text, searchCount(maxCount, recencyHalfLife > 0), store.SearchOptions{
+			Label:         label,
+			MinQuality:    minQuality,
+			MaxDistance:   distanceThreshold,
+			Filters:       filters,
+			Diversity:     diversity,
+			QueryVariants: variants,
+		}, store.TextSearchBudget{
+			Timeout:         time.Duration(timeoutMs) * time.Millisecond,
+			KeywordFallback: keywordFallback,
+		})
 		if err != nil {
-			return mcp.NewToolResultError(fmt.Sprintf("Failed to perform similarity search: %v", err)), nil
+			return storeErrorResult("Search failed", err), nil
 		}
 
 		// Convert results to response format
-		results := make([]models.SimilaritySearchResult, 0, len(docs))
-		for _, doc := range docs {
-			str := doc.Fields["vector_distance"]
-			distance, err := strconv.ParseFloat(str, 32)
-			if err != nil {
-				distance = 0.0
-			}
+		results := store.TextSearchResults(docs, fallback, distanceThreshold)
+		if recencyHalfLife > 0 {
+			results = rankByRecency(results, fallback, recencyHalfLife, recencyWeight, maxCount)
+		}
+		if snippetSize > 0 && fallback == "" {
+			addSnippets(ctx, openaiClient, text, results, modelId, int(snippetSize))
+		}
+		if expandContext > 0 {
+			expandSearchResults(ctx, redisClient, collection.IndexName, results, int(expandContext))
+		}
+		store.TruncateSearchResults(results, int(maxContentChars))
 
-			if distanceThreshold != nil && distance > *distanceThreshold {
-				continue
-			}
+		response := map[string]interface{}{
+			"success": true,
+			"results": results,
+		}
+		if fallback != "" {
+			response["fallback"] = fallback
+		}
+		if len(variants) > 0 {
+			response["expanded_queries"] = variants
+		}
 
-			createdAtUnix, _ := strconv.ParseInt(doc.Fields["created_at"], 10, 64)
-			createdAt := time.Unix(createdAtUnix, 0).Format(time.RFC3339)
+		resultJSON, _ := json.Marshal(response)
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
 
-			result := models.SimilaritySearchResult{
-				ID:        doc.ID,
-				Content:   doc.Fields["content"],
-				Label:     doc.Fields["label"],
-				Metadata:  doc.Fields["metadata"],
-				Distance:  distance,
-				CreatedAt: createdAt,
-			}
+	// Similarity search with several labels tool
+	similaritySearchWithLabelsTool := mcp.NewTool("similarity_search_with_labels",
+		mcp.WithDescription("Search for similar documents filtered by several labels: documents having any of the labels (match: any) or all of them (match: all). Returns documents ordered by similarity (closest first)."),
+		mcp.WithString("text",
+			mcp.Required(),
+			mcp.Description("The text query to search for similar documents"),
+		),
+		mcp.WithArray("labels",
+			mcp.Required(),
+			mcp.Description("The labels to filter documents by"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithString("match",
+			mcp.Description("Optional: 'any' (documents having any of the labels, default) or 'all' (documents having all the labels)"),
+			mcp.Enum(store.LabelMatchAny, store.LabelMatchAll),
+		),
+		mcp.WithNumber("max_count",
+			mcp.Description("Maximum number of results to return (default: 1)"),
+		),
+		mcp.WithNumber("distance_threshold",
+			mcp.Description("Optional distance threshold. Only returns documents with distance <= threshold"),
+		),
+		mcp.WithNumber("min_quality",
+			mcp.Description("Optional minimum quality score (0 to 1). Only returns documents with quality >= min_quality"),
+		),
+		mcp.WithNumber("timeout_ms",
+			mcp.Description("Optional time budget of the search in milliseconds (query embedding and vector search)"),
+		),
+		mcp.WithBoolean("keyword_fallback",
+			mcp.Description("Optional: return keyword search results (without distance) when the query embedding does not complete within timeout_ms (default: false)"),
+		),
+		mcp.WithObject("filters",
+			mcp.Description(`Optional filters on the JSON metadata fields, e.g. {"source":"wiki","tags":["go","redis"],"year":{"gte":2020}} (operators: eq, in, gt, gte, lt, lte)`),
+		),
+		mcp.WithNumber("snippet_size",
+			mcp.Description("Optional size in characters (up to 1000) of a snippet added to each result, centered on its sentence most similar to the query (default: 0, no snippets)"),
+		),
+		mcp.WithNumber("max_content_chars",
+			mcp.Description("Optional maximum number of characters of the content of each result: a longer content is cut and flagged with is_truncated, get_document returns the rest from next_offset (default: 0, whole content)"),
+		),
+		mcp.WithNumber("expand_context",
+			mcp.Description("Optional number of chunks (up to 10) before and after each chunk of the results, from the same document, concatenated with it in expanded_content (default: 0, no expansion)"),
+		),
+		mcp.WithNumber("diversity",
+			mcp.Description("Optional diversity of the results from 0 to 1: the results are selected among more candidates by maximal marginal relevance, so that they are not near duplicates of each other, e.g. 0.3 (default: 0, ordered by similarity)"),
+		),
+		mcp.WithNumber("recency_half_life_hours",
+			mcp.Description("Optional half-life in hours of the freshness of the documents: the results are ranked by similarity and freshness, so that the recent memories outrank the stale ones, e.g. 168 for a week (default: 0, ordered by similarity)"),
+		),
+		mcp.WithNumber("recency_weight",
+			mcp.Description("Optional share of the freshness in the recency ranking, from 0 to 1 (default: 0.3)"),
+		),
+		mcp.WithBoolean("expand_query",
+			mcp.Description("Optional: also search 3 to 5 paraphrases of the query generated by the chat model, and fuse the rankings, to find more relevant documents for a short or ambiguous query (default: false)"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the documents (default: the main index)"),
+		),
+	)
+	mcpServer.AddTool(similaritySearchWithLabelsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		redisClient := searchRedisClient(redisClient)
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
+
+		text, ok := args["text"].(string)
+		if !ok || text == "" {
+			return mcp.NewToolResultError("text parameter is required"), nil
+		}
+
+		labels, err := store.JoinLabels("", stringArrayArgument(args, "labels"))
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		if labels == "" {
+			return mcp.NewToolResultError("labels parameter is required"), nil
+		}
+
+		match, _ := args["match"].(string)
+		if err := store.ValidateLabelMatch(match); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		maxCount := 1
+		if mc, ok := args["max_count"].(float64); ok {
+			maxCount = int(mc)
+		}
+		if maxCount <= 0 {
+			maxCount = 1
+		}
+
+		var distanceThreshold *float64
+		if dt, ok := args["distance_threshold"].(float64); ok {
+			distanceThreshold = &dt
+		}
+
+		var minQuality *float64
+		if mq, ok := args["min_quality"].(float64); ok {
+			minQuality = &mq
+		}
+
+		timeoutMs, _ := args["timeout_ms"].(float64)
+		keywordFallback, _ := args["keyword_fallback"].(bool)
+
+		snippetSize, _ := args["snippet_size"].(float64)
+		if err := store.ValidateSnippetSize(int(snippetSize)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		maxContentChars, _ := args["max_content_chars"].(float64)
+		if err := store.ValidateMaxContentChars(int(maxContentChars)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		expandContext, _ := args["expand_context"].(float64)
+		if err := store.ValidateExpandContext(int(expandContext)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		diversity, _ := args["diversity"].(float64)
+		if err := store.ValidateDiversity(diversity); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		recencyHalfLife, _ := args["recency_half_life_hours"].(float64)
+		recencyWeight, _ := args["recency_weight"].(float64)
+		if err := store.ValidateRecency(recencyHalfLife, recencyWeight); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		expand, _ := args["expand_query"].(bool)
+		if expand && !store.ExpansionEnabled() {
+			return mcp.NewToolResultError("Query expansion is not available (CHAT_MODEL is not set, or the query expansion feature is disabled)"), nil
+		}
+
+		rawFilters, _ := args["filters"].(map[string]interface{})
+		filters, err := store.ParseMetadataFilters(rawFilters)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid filters: %v", err)), nil
+		}
+
+		// Resolve the collection of the documents
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
 
-			results = append(results, result)
+		// Paraphrases of the query searched with it (the search goes on without them when the chat model fails)
+		var variants []string
+		if expand {
+			variants = expandQuery(ctx, text)
 		}
 
-		sort.Slice(results, func(i, j int) bool {
-			return results[i].Distance < results[j].Distance
+		// Perform similarity search with labels filter (query embedding and vector search within the time budget)
+		docs, fallback, err := store.SearchByText(ctx, openaiClient, redisClient, modelId, collection.IndexName, text, searchCount(maxCount, recencyHalfLife > 0), store.SearchOptions{
+			Labels:         store.SplitLabels(labels),
+			MatchAllLabels: match == store.LabelMatchAll,
+			MinQuality:     minQuality,
+			MaxDistance:    distanceThreshold,
+			Filters:        filters,
+			Diversity:      diversity,
+			QueryVariants:  variants,
+		}, store.TextSearchBudget{
+			Timeout:         time.Duration(timeoutMs) * time.Millisecond,
+			KeywordFallback: keywordFallback,
 		})
+		if err != nil {
+			return storeErrorResult("Search failed", err), nil
+		}
+
+		// Convert results to response format
+		results := store.TextSearchResults(docs, fallback, distanceThreshold)
+		if recencyHalfLife > 0 {
+			results = rankByRecency(results, fallback, recencyHalfLife, recencyWeight, maxCount)
+		}
+		if snippetSize > 0 && fallback == "" {
+			addSnippets(ctx, openaiClient, text, results, modelId, int(snippetSize))
+		}
+		if expandContext > 0 {
+			expandSearchResults(ctx, redisClient, collection.IndexName, results, int(expandContext))
+		}
+		store.TruncateSearchResults(results, int(maxContentChars))
+
+		response := map[string]interface{}{
+			"success": true,
+			"results": results,
+		}
+		if fallback != "" {
+			response["fallback"] = fallback
+		}
+		if len(variants) > 0 {
+			response["expanded_queries"] = variants
+		}
+
+		resultJSON, _ := json.Marshal(response)
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+
+	// Hybrid search tool
+	hybridSearchTool := mcp.NewTool("hybrid_search",
+		mcp.WithDescription("Search documents combining full-text (BM25) relevance on the content with vector similarity. Finds exact keyword matches (IDs, error codes, proper nouns) missed by pure vector search. Returns documents ordered by fused score (best first)."),
+		mcp.WithString("text",
+			mcp.Required(),
+			mcp.Description("The text query to search for"),
+		),
+		mcp.WithNumber("max_count",
+			mcp.Description("Maximum number of results to return (default: 1)"),
+		),
+		mcp.WithString("label",
+			mcp.Description("Optional label to filter documents by"),
+		),
+		mcp.WithNumber("min_quality",
+			mcp.Description("Optional minimum quality score (0 to 1). Only returns documents with quality >= min_quality"),
+		),
+		mcp.WithString("fusion",
+			mcp.Description("Optional fusion method: 'rrf' (reciprocal rank fusion, default) or 'weighted' (weighted sum of the normalized scores)"),
+			mcp.Enum(store.FusionRRF, store.FusionWeighted),
+		),
+		mcp.WithNumber("vector_weight",
+			mcp.Description("Optional weight (0 to 1) of the vector score with the 'weighted' fusion (default: 0.5)"),
+		),
+		mcp.WithObject("filters",
+			mcp.Description(`Optional filters on the JSON metadata fields, e.g. {"source":"wiki","tags":["go","redis"],"year":{"gte":2020}} (operators: eq, in, gt, gte, lt, lte)`),
+		),
+		mcp.WithArray("search_fields",
+			mcp.Description("Optional fields matched by the text search: 'content' (default), 'metadata' (the whole JSON metadata) or metadata fields of type text, e.g. ['content', 'title', 'url']"),
+			mcp.WithStringItems(),
+		),
+		mcp.WithObject("field_weights",
+			mcp.Description(`Optional weights of the section fields of the chunks in the text search, relative to the content, e.g. {"title":5,"hierarchy":2} (default: the configured weights, 0 disables the boost of a field)`),
+		),
+		mcp.WithNumber("snippet_size",
+			mcp.Description("Optional size in characters (up to 1000) of a snippet added to each result, centered on its sentence most similar to the query (default: 0, no snippets)"),
+		),
+		mcp.WithNumber("max_content_chars",
+			mcp.Description("Optional maximum number of characters of the content of each result: a longer content is cut and flagged with is_truncated, get_document returns the rest from next_offset (default: 0, whole content)"),
+		),
+		mcp.WithNumber("expand_context",
+			mcp.Description("Optional number of chunks (up to 10) before and after each chunk of the results, from the same document, concatenated with it in expanded_content (default: 0, no expansion)"),
+		),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection of the documents (default: the main index)"),
+		),
+	)
+	mcpServer.AddTool(hybridSearchTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		redisClient := searchRedisClient(redisClient)
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
+
+		text, ok := args["text"].(string)
+		if !ok || text == "" {
+			return mcp.NewToolResultError("text parameter is required"), nil
+		}
+
+		maxCount := 1
+		if mc, ok := args["max_count"].(float64); ok {
+			maxCount = int(mc)
+		}
+		if maxCount <= 0 {
+			maxCount = 1
+		}
+
+		label, _ := args["label"].(string)
+
+		var minQuality *float64
+		if mq, ok := args["min_quality"].(float64); ok {
+			minQuality = &mq
+		}
+
+		hybridOptions := store.HybridOptions{Fusion: store.FusionRRF, VectorWeight: 0.5}
+		if fusion, ok := args["fusion"].(string); ok && fusion != "" {
+			hybridOptions.Fusion = fusion
+		}
+		if vw, ok := args["vector_weight"].(float64); ok {
+			hybridOptions.VectorWeight = vw
+		}
+		if err := store.ValidateHybridOptions(hybridOptions); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		rawFilters, _ := args["filters"].(map[string]interface{})
+		filters, err := store.ParseMetadataFilters(rawFilters)
+		if err != nil {
+			return mcp.NewToolResultError(fmt.Sprintf("Invalid filters: %v", err)), nil
+		}
+
+		snippetSize, _ := args["snippet_size"].(float64)
+		if err := store.ValidateSnippetSize(int(snippetSize)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		maxContentChars, _ := args["max_content_chars"].(float64)
+		if err := store.ValidateMaxContentChars(int(maxContentChars)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		expandContext, _ := args["expand_context"].(float64)
+		if err := store.ValidateExpandContext(int(expandContext)); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		searchFields := stringArrayArgument(args, "search_fields")
+		if err := store.ValidateSearchFields(searchFields); err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		var fieldWeights *store.FieldWeights
+		if rawWeights, ok := args["field_weights"].(map[string]interface{}); ok {
+			weightsByField := map[string]float64{}
+			for name, value := range rawWeights {
+				weight, ok := value.(float64)
+				if !ok {
+					return mcp.NewToolResultError(fmt.Sprintf("Invalid field weights: the weight of field %s must be a number", name)), nil
+				}
+				weightsByField[name] = weight
+			}
+			weights, err := store.ApplyFieldWeights(store.GetFieldWeights(), weightsByField)
+			if err != nil {
+				return mcp.NewToolResultError(fmt.Sprintf("Invalid field weights: %v", err)), nil
+			}
+			fieldWeights = &weights
+		}
+
+		// Resolve the collection of the documents
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+		modelId := collection.ModelID(embeddingModelId)
+
+		// Create embedding from query text
+		queryEmbedding, err := store.CreateQueryEmbeddingFromText(ctx, openaiClient, text, modelId)
+		if err != nil {
+			return storeErrorResult("Failed to create embedding", err), nil
+		}
+
+		// Perform hybrid search
+		results, err := store.HybridSearch(ctx, redisClient, collection.IndexName, text, queryEmbedding, maxCount, store.SearchOptions{
+			Label:        label,
+			MinQuality:   minQuality,
+			Filters:      filters,
+			SearchFields: searchFields,
+			FieldWeights: fieldWeights,
+		}, hybridOptions)
+		if err != nil {
+			return storeErrorResult("Failed to perform hybrid search", err), nil
+		}
+
+		if snippetSize > 0 {
+			contents := make([]string, len(results))
+			for i, result := range results {
+				contents[i] = result.Content
+			}
+			snippets, err := store.SearchSnippets(ctx, openaiClient, text, contents, modelId, int(snippetSize))
+			if err != nil {
+				helpers.Logf(ctx, "🟠 Failed to create the snippets of the search results: %v", err)
+			}
+			for i := range snippets {
+				results[i].Snippet = snippets[i]
+			}
+		}
+		if expandContext > 0 {
+			chunks := make([]models.ChunkProvenance, len(results))
+			for i, result := range results {
+				chunks[i] = result.ChunkProvenance
+			}
+			contexts, err := store.ExpandContexts(ctx, redisClient, collection.IndexName, chunks, int(expandContext))
+			if err != nil {
+				helpers.Logf(ctx, "🟠 Failed to expand the context of the search results: %v", err)
+			}
+			for i := range contexts {
+				results[i].ExpandedContent = contexts[i]
+			}
+		}
+		store.TruncateHybridResults(results, int(maxContentChars))
 
 		response := map[string]interface{}{
 			"success": true,
+			"fusion":  hybridOptions.Fusion,
 			"results": results,
 		}
 
@@ -0,0 +1,42 @@
+package mcptools
+
+import (
+	"context"
+	"encoding/json"
+	"vectormind/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/mark3labs/mcp-go/server"
+	"github.com/redis/go-redis/v9"
+)
+
+// RegisterStatsTool registers the get_store_stats tool
+func RegisterStatsTool(mcpServer *server.MCPServer, redisClient *redis.Client, redisIndexName string) {
+	getStoreStatsTool := mcp.NewTool("get_store_stats",
+		mcp.WithDescription("Get the statistics of the store: total number of documents, number of documents per label, memory usage of the index and embedding dimension."),
+		mcp.WithString("collection",
+			mcp.Description("Optional collection (default: the main index)"),
+		),
+	)
+	mcpServer.AddTool(getStoreStatsTool, func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+		args := request.GetArguments()
+		redisIndexName := tenantIndexName(ctx, redisIndexName)
+
+		collection, err := collectionArgument(ctx, redisClient, redisIndexName, args)
+		if err != nil {
+			return mcp.NewToolResultError(err.Error()), nil
+		}
+
+		stats, err := store.GetIndexStats(ctx, redisClient, collection.IndexName)
+		if err != nil {
+			return storeErrorResult("Failed to get stats", err), nil
+		}
+		stats.Collection, _ = args["collection"].(string)
+
+		resultJSON, _ := json.Marshal(map[string]interface{}{
+			"success": true,
+			"stats":   stats,
+		})
+		return mcp.NewToolResultText(string(resultJSON)), nil
+	})
+}
@@ -1,17 +1,262 @@
 package mcptools
 
 import (
+	"context"
+	"encoding/json"
+	"strings"
+	"vectormind/features"
+	"vectormind/helpers"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
 	"github.com/mark3labs/mcp-go/server"
 	"github.com/openai/openai-go"
 	"github.com/redis/go-redis/v9"
 )
 
+// writeTools are the tools storing data, refused by the memory guard above the memory watermark
+var writeTools = map[string]bool{
+	"create_embedding":                        true,
+	"update_embedding":                        true,
+	"chunk_and_store":                         true,
+	"recursive_chunk_and_store":               true,
+	"semantic_chunk_and_store":                true,
+	"split_and_store_markdown_sections":       true,
+	"split_and_store_with_delimiter":          true,
+	"split_and_store_markdown_with_hierarchy": true,
+	"split_and_store":                         true,
+	"split_and_store_email":                   true,
+	"split_and_store_office":                  true,
+	"split_and_store_html":                    true,
+	"fetch_and_store_url":                     true,
+	"ingest_github":                           true,
+	"split_and_store_subtitles":               true,
+}
+
+// searchTools are the interactive search tools, limited separately from the write tools
+var searchTools = map[string]bool{
+	"similarity_search":             true,
+	"similarity_search_with_label":  true,
+	"similarity_search_with_labels": true,
+	"hybrid_search":                 true,
+	"ask_vectormind":                true,
+	"rerank_results":                true,
+}
+
+// featureTools are the tools of the experimental features, hidden and refused when their feature flag is off
+var featureTools = map[string]string{
+	"semantic_chunk_and_store":                features.SemanticChunking,
+	"split_and_store_markdown_with_hierarchy": features.HierarchySplitting,
+	"rerank_results":                          features.Reranking,
+}
+
 // RegisterTools registers all MCP tools with the server
 func RegisterTools(mcpServer *server.MCPServer, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, redisIndexName string) {
 	// Register all tools organized by category
 	RegisterAboutTool(mcpServer)
-	RegisterEmbeddingTools(mcpServer, openaiClient, redisClient, embeddingModelId)
+	RegisterEmbeddingTools(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
 	RegisterSearchTools(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
-	RegisterChunkingTool(mcpServer, openaiClient, redisClient, embeddingModelId)
-	RegisterMarkdownTools(mcpServer, openaiClient, redisClient, embeddingModelId)
+	RegisterChunkingTool(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
+	RegisterRecursiveChunkingTool(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
+	RegisterSemanticChunkingTool(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
+	RegisterMarkdownTools(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
+	RegisterSplitTool(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
+	RegisterChunkPreviewTool(mcpServer)
+	RegisterEmailTool(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
+	RegisterOfficeTool(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
+	RegisterHTMLTool(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
+	RegisterFetchTool(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
+	RegisterGitHubTool(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
+	RegisterSubtitlesTool(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
+	RegisterStatsTool(mcpServer, redisClient, redisIndexName)
+	RegisterAskTool(mcpServer, openaiClient, redisClient, embeddingModelId, redisIndexName)
+	RegisterRerankTool(mcpServer)
+	RegisterJobTools(mcpServer, redisIndexName)
+}
+
+// TenantMiddleware refuses the tool calls of an unknown tenant (the X-Tenant header of the MCP request): the tools
+// are served from the index of the tenant, as the REST endpoints
+func TenantMiddleware(router *store.RedisRouter) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if _, err := router.IndexName(store.ContextTenant(ctx)); err != nil {
+				return mcp.NewToolResultError(err.Error()), nil
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// MemoryGuardMiddleware refuses the calls of the write tools when Redis uses more memory than the watermark
+func MemoryGuardMiddleware(memoryGuard *store.MemoryGuard) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if writeTools[request.Params.Name] {
+				if err := memoryGuard.CheckWrite(ctx); err != nil {
+					return mcp.NewToolResultError("Write refused: " + err.Error()), nil
+				}
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// QuotaWarningsMiddleware adds to the successful results of the write tools the warnings of the quotas approached by
+// the documents they wrote (see store.QuotaWarnings), as "warnings" in their JSON result
+func QuotaWarningsMiddleware(memoryGuard *store.MemoryGuard, redisClient *redis.Client, indexName string) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if !writeTools[request.Params.Name] {
+				return next(ctx, request)
+			}
+			ctx = store.TrackWrites(ctx)
+			result, err := next(ctx, request)
+			if result == nil || result.IsError {
+				return result, err
+			}
+			if warnings := store.QuotaWarnings(ctx, redisClient, tenantIndexName(ctx, indexName), memoryGuard); len(warnings) > 0 {
+				addQuotaWarnings(result, warnings)
+			}
+			return result, err
+		}
+	}
+}
+
+// addQuotaWarnings adds quota warnings to the JSON object of a tool result, or to its _meta when the result is not
+// a JSON object
+func addQuotaWarnings(result *mcp.CallToolResult, warnings []models.QuotaWarning) {
+	for i, content := range result.Content {
+		text, ok := content.(mcp.TextContent)
+		if !ok {
+			continue
+		}
+		var object map[string]any
+		decoder := json.NewDecoder(strings.NewReader(text.Text))
+		decoder.UseNumber()
+		if err := decoder.Decode(&object); err != nil || object == nil {
+			break
+		}
+		object["warnings"] = warnings
+		if encoded, err := json.Marshal(object); err == nil {
+			text.Text = string(encoded)
+			result.Content[i] = text
+			return
+		}
+		break
+	}
+	resultMeta(result)["warnings"] = warnings
+}
+
+// FeatureFlagsMiddleware refuses the calls of the tools whose feature is disabled (see features.Enabled)
+func FeatureFlagsMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if feature, ok := featureTools[request.Params.Name]; ok {
+				if err := features.Check(feature); err != nil {
+					return mcp.NewToolResultError("Feature not available: " + err.Error()), nil
+				}
+			}
+			return next(ctx, request)
+		}
+	}
+}
+
+// FeatureFlagsToolFilter removes the tools whose feature is disabled from the tool list
+func FeatureFlagsToolFilter(ctx context.Context, tools []mcp.Tool) []mcp.Tool {
+	enabled := make([]mcp.Tool, 0, len(tools))
+	for _, tool := range tools {
+		if feature, ok := featureTools[tool.Name]; ok && !features.Enabled(feature) {
+			continue
+		}
+		enabled = append(enabled, tool)
+	}
+	return enabled
+}
+
+// ConcurrencyLimitMiddleware limits the number of search and write tool calls processed at the same time,
+// so that a burst of ingestion calls cannot starve the searches. Near the limit, the results carry a load hint
+// in their _meta ("pressure" and "retry_after_ms"), so that the agents slow down before their calls are refused.
+func ConcurrencyLimitMiddleware(searchLimiter, ingestLimiter *helpers.ConcurrencyLimiter) server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			var limiter *helpers.ConcurrencyLimiter
+			switch {
+			case searchTools[request.Params.Name]:
+				limiter = searchLimiter
+			case writeTools[request.Params.Name]:
+				limiter = ingestLimiter
+			}
+
+			release, err := limiter.Acquire(ctx)
+			if err != nil {
+				result := mcp.NewToolResultError("Server busy: " + err.Error())
+				if hint, ok := limiter.Hint(); ok {
+					setLoadHint(result, hint)
+				}
+				return result, nil
+			}
+			defer release()
+			hint, hinted := limiter.Hint()
+			result, err := next(ctx, request)
+			if result != nil && hinted {
+				setLoadHint(result, hint)
+			}
+			return result, err
+		}
+	}
+}
+
+// RequestIDMiddleware identifies each tool call with the request ID of its HTTP request (see api.RequestIDContext),
+// or a new ID with the stdio transport. The ID is added as request_id to the _meta of the result, and logged with
+// the failed calls and the warnings of the tools (see helpers.Logf).
+func RequestIDMiddleware() server.ToolHandlerMiddleware {
+	return func(next server.ToolHandlerFunc) server.ToolHandlerFunc {
+		return func(ctx context.Context, request mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			if helpers.RequestID(ctx) == "" {
+				ctx = helpers.WithRequestID(ctx, helpers.NewRequestID())
+			}
+			result, err := next(ctx, request)
+			if err != nil {
+				helpers.Logf(ctx, "🟠 Tool %s failed: %v", request.Params.Name, err)
+			}
+			if result == nil {
+				return result, err
+			}
+			if result.IsError {
+				helpers.Logf(ctx, "🟠 Tool %s failed: %s", request.Params.Name, resultText(result))
+			}
+			resultMeta(result)["request_id"] = helpers.RequestID(ctx)
+			return result, err
+		}
+	}
+}
+
+// setLoadHint adds a load hint to the _meta of a tool result
+func setLoadHint(result *mcp.CallToolResult, hint helpers.LoadHint) {
+	meta := resultMeta(result)
+	meta["pressure"] = hint.Pressure
+	meta["retry_after_ms"] = hint.RetryAfter.Milliseconds()
+}
+
+// resultMeta returns the additional fields of the _meta of a tool result, created if needed
+func resultMeta(result *mcp.CallToolResult) map[string]any {
+	if result.Meta == nil {
+		result.Meta = &mcp.Meta{}
+	}
+	if result.Meta.AdditionalFields == nil {
+		result.Meta.AdditionalFields = map[string]any{}
+	}
+	return result.Meta.AdditionalFields
+}
+
+// resultText returns the text content of a tool result
+func resultText(result *mcp.CallToolResult) string {
+	texts := []string{}
+	for _, content := range result.Content {
+		if text, ok := content.(mcp.TextContent); ok {
+			texts = append(texts, text.Text)
+		}
+	}
+	return strings.Join(texts, " ")
 }
@@ -1,7 +1,22 @@
 package mcptools
 
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+	"vectormind/helpers"
+	"vectormind/models"
+	"vectormind/store"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
 var embeddingDimension int
 var embeddingModelId string
+var embeddingMaxTokens int
 
 func SetEmbeddingDimension(dim int) {
 	embeddingDimension = dim
@@ -11,6 +26,14 @@ func GetEmbeddingDimension() int {
 	return embeddingDimension
 }
 
+func SetEmbeddingMaxTokens(maxTokens int) {
+	embeddingMaxTokens = maxTokens
+}
+
+func GetEmbeddingMaxTokens() int {
+	return embeddingMaxTokens
+}
+
 func SetEmbeddingModelId(modelId string) {
 	embeddingModelId = modelId
 }
@@ -18,3 +41,134 @@ func SetEmbeddingModelId(modelId string) {
 func GetEmbeddingModelId() string {
 	return embeddingModelId
 }
+
+// stringArrayArgument returns the strings of an array argument of a tool (nil when the argument is missing)
+func stringArrayArgument(args map[string]interface{}, name string) []string {
+	values, ok := args[name].([]interface{})
+	if !ok {
+		return nil
+	}
+	items := make([]string, 0, len(values))
+	for _, value := range values {
+		if item, ok := value.(string); ok {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// tenantIndexName returns the main index of the tenant of a tool call (the X-Tenant header of the MCP request, see
+// TenantMiddleware)
+func tenantIndexName(ctx context.Context, indexName string) string {
+	return store.TenantIndexName(indexName, store.ContextTenant(ctx))
+}
+
+// collectionArgument resolves the collection argument of a tool (the default collection when the argument is missing)
+func collectionArgument(ctx context.Context, redisClient *redis.Client, indexName string, args map[string]interface{}) (store.Collection, error) {
+	name, _ := args["collection"].(string)
+	return store.ResolveCollection(ctx, redisClient, indexName, name)
+}
+
+// deleteMatchingDocuments deletes the documents of the collection argument of a tool matching a filter (see
+// store.DeleteMatchingDocuments)
+func deleteMatchingDocuments(ctx context.Context, redisClient *redis.Client, indexName string, args map[string]interface{}, filter store.SearchOptions) *mcp.CallToolResult {
+	collection, err := collectionArgument(ctx, redisClient, indexName, args)
+	if err != nil {
+		return mcp.NewToolResultError(err.Error())
+	}
+
+	deleted, err := store.DeleteMatchingDocuments(ctx, redisClient, collection.IndexName, filter)
+	if err != nil {
+		return storeErrorResult("Failed to delete documents", err)
+	}
+
+	result := map[string]interface{}{
+		"success":       true,
+		"deleted_count": deleted,
+	}
+	resultJSON, _ := json.Marshal(result)
+	return mcp.NewToolResultText(string(resultJSON))
+}
+
+// ttlArgument returns the expiration of the ttl_seconds argument of a tool (0, no expiration, when the argument is missing)
+func ttlArgument(args map[string]interface{}) (time.Duration, error) {
+	seconds, _ := args["ttl_seconds"].(float64)
+	return store.DocumentTTL(int(seconds))
+}
+
+// dedupArgument returns the deduplication mode of the dedup argument of a tool (DedupOff when the argument is missing)
+func dedupArgument(args map[string]interface{}) (string, error) {
+	mode, _ := args["dedup"].(string)
+	if err := store.ValidateDedupMode(mode); err != nil {
+		return "", err
+	}
+	return mode, nil
+}
+
+// chunkStoreError returns the result of a chunk and store tool whose ingestion aborted, with the chunks stored
+// (or rolled back) before the failure
+func chunkStoreError(statuses []models.ChunkStatus, err error) *mcp.CallToolResult {
+	chunkIDs, _ := store.StoredChunkIDs(statuses)
+	if len(statuses) == 0 {
+		return storeErrorResult("Failed to store chunks", err)
+	}
+	statusesJSON, _ := json.Marshal(statuses)
+	result := storeErrorResult("Failed to store chunks", err)
+	result.Content = []mcp.Content{mcp.NewTextContent(fmt.Sprintf("Failed to store chunks: %v (%d chunks stored: %v, chunk statuses: %s)", err, len(chunkIDs), chunkIDs, statusesJSON))}
+	return result
+}
+
+// storeErrorResult returns the error result of a tool whose store operation failed. The structured content holds the
+// error code of store.ErrorCode, so that the clients can handle the error without matching its text:
+// {"error": "<message>: <error>", "code": "not_found"}
+func storeErrorResult(message string, err error) *mcp.CallToolResult {
+	text := fmt.Sprintf("%s: %v", message, err)
+	result := mcp.NewToolResultError(text)
+	result.StructuredContent = map[string]string{"error": text, "code": store.ErrorCode(err)}
+	return result
+}
+
+// addSnippets sets the snippets of the search results (see store.AddSnippets), the results are returned without
+// snippets when the sentences cannot be embedded
+func addSnippets(ctx context.Context, openaiClient openai.Client, query string, results []models.SimilaritySearchResult, embeddingModelId string, size int) {
+	if err := store.AddSnippets(ctx, openaiClient, query, results, embeddingModelId, size); err != nil {
+		helpers.Logf(ctx, "🟠 Failed to create the snippets of the search results: %v", err)
+	}
+}
+
+// expandSearchResults sets the expanded content of the search results (see store.ExpandSearchResults), the results
+// are returned without expanded content when their neighbouring chunks cannot be read
+func expandSearchResults(ctx context.Context, redisClient *redis.Client, indexName string, results []models.SimilaritySearchResult, n int) {
+	if err := store.ExpandSearchResults(ctx, redisClient, indexName, results, n); err != nil {
+		helpers.Logf(ctx, "🟠 Failed to expand the context of the search results: %v", err)
+	}
+}
+
+// searchCount returns the number of results retrieved by a search returning n results: more candidates are
+// retrieved for the recency ranking
+func searchCount(n int, recency bool) int {
+	if recency {
+		return store.RecencyCandidates(n)
+	}
+	return n
+}
+
+// rankByRecency returns the n best search results by similarity and freshness (see store.RankByRecency), the
+// keyword fallback results keep their order
+func rankByRecency(results []models.SimilaritySearchResult, fallback string, halfLifeHours, weight float64, n int) []models.SimilaritySearchResult {
+	if fallback != "" {
+		return results[:min(n, len(results))]
+	}
+	return store.RankByRecency(results, store.RecencyHalfLife(halfLifeHours), weight, time.Now(), n)
+}
+
+// expandQuery returns the paraphrases of a query generated by the chat model (see store.ExpandQuery), the query is
+// searched alone when the chat model fails
+func expandQuery(ctx context.Context, query string) []string {
+	variants, err := store.ExpandQuery(ctx, query)
+	if err != nil {
+		helpers.Logf(ctx, "🟠 Failed to expand the search query: %v", err)
+		return nil
+	}
+	return variants
+}
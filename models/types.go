@@ -4,20 +4,37 @@ import "time"
 
 // CreateEmbeddingRequest represents the request to create an embedding
 type CreateEmbeddingRequest struct {
-	Content  string `json:"content"`
-	Label    string `json:"label"`
-	Metadata string `json:"metadata"`
+	Content  string   `json:"content"`
+	Label    string   `json:"label"`
+	Labels   []string `json:"labels,omitempty"` // additional labels of the document
+	Metadata string   `json:"metadata"`
+	// Collection is the collection of the document (default: the main index)
+	Collection string `json:"collection,omitempty"`
+	// TTLSeconds is the time after which the document is deleted (0 means no expiration)
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+	// Dedup is the handling of a content already stored: "off" (default), "skip" or "upsert"
+	Dedup string `json:"dedup,omitempty"`
+	// DocID identifies the request like the Idempotency-Key header: a retried request does not store the document twice
+	DocID string `json:"doc_id,omitempty"`
+	// ID is the ID of the document chosen by the caller (default: a generated ID)
+	ID string `json:"id,omitempty"`
+	// OnConflict is the handling of an ID already used: "error" or "overwrite" (default: DOCUMENT_ID_CONFLICT)
+	OnConflict string `json:"on_conflict,omitempty"`
 }
 
 // CreateEmbeddingResponse represents the response after creating an embedding
 type CreateEmbeddingResponse struct {
 	ID        string    `json:"id"`
+	URL       string    `json:"url,omitempty"` // URL of the stored document (GET /documents/{id})
 	Content   string    `json:"content"`
 	Label     string    `json:"label"`
+	Labels    []string  `json:"labels,omitempty"`
 	Metadata  string    `json:"metadata"`
 	CreatedAt time.Time `json:"created_at"`
-	Success   bool      `json:"success"`
-	Error     string    `json:"error,omitempty"`
+	// Duplicate is true when the content was already stored (dedup "skip"), ID is the ID of the stored document
+	Duplicate bool   `json:"duplicate,omitempty"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
 }
 
 // SimilaritySearchRequest represents the request for similarity search
@@ -25,6 +42,37 @@ type SimilaritySearchRequest struct {
 	Text              string   `json:"text"`
 	MaxCount          int      `json:"max_count"`
 	DistanceThreshold *float64 `json:"distance_threshold,omitempty"`
+	MinQuality        *float64 `json:"min_quality,omitempty"`
+	// TimeoutMs bounds the total time of the search (query embedding and vector search), 0 means no limit
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// KeywordFallback returns keyword search results when the query embedding does not complete in time
+	KeywordFallback bool `json:"keyword_fallback,omitempty"`
+	// Filters restrict the search to the documents whose JSON metadata matches, e.g. {"source":"wiki","year":{"gte":2020}}
+	Filters map[string]interface{} `json:"filters,omitempty"`
+	// Collection is the collection of the documents (default: the main index)
+	Collection string `json:"collection,omitempty"`
+	// SnippetSize adds to each result a snippet of at most SnippetSize characters centered on its sentence most
+	// similar to the query (0: no snippets)
+	SnippetSize int `json:"snippet_size,omitempty"`
+	// MaxContentChars cuts the content of each result to MaxContentChars characters (0: whole content)
+	MaxContentChars int `json:"max_content_chars,omitempty"`
+	// ExpandContext adds to each chunk of the results the ExpandContext chunks before and after it of its document,
+	// concatenated (0: no expansion)
+	ExpandContext int `json:"expand_context,omitempty"`
+	// Rerank retrieves more candidates than max_count and returns the max_count most relevant according to the reranker
+	Rerank bool `json:"rerank,omitempty"`
+	// Diversity selects the results by maximal marginal relevance among more candidates, from 0 (by similarity) to 1
+	// (the most different from each other), so that the results are not near duplicates
+	Diversity float64 `json:"diversity,omitempty"`
+	// RecencyHalfLifeHours ranks the results by similarity and freshness, the freshness of a document halving every
+	// RecencyHalfLifeHours hours (0: by similarity)
+	RecencyHalfLifeHours float64 `json:"recency_half_life_hours,omitempty"`
+	// RecencyWeight is the share of the freshness in the recency ranking, up to 1 (0: the default weight, 0.3)
+	RecencyWeight float64 `json:"recency_weight,omitempty"`
+	// ExpandQuery also searches paraphrases of the query generated by the chat model, and fuses the rankings
+	ExpandQuery bool `json:"expand_query,omitempty"`
+	// Debug adds the timings of the search to the response
+	Debug bool `json:"debug,omitempty"`
 }
 
 // SimilaritySearchWithLabelRequest represents the request for similarity search with label filter
@@ -33,88 +81,1099 @@ type SimilaritySearchWithLabelRequest struct {
 	Label             string   `json:"label"`
 	MaxCount          int      `json:"max_count"`
 	DistanceThreshold *float64 `json:"distance_threshold,omitempty"`
+	MinQuality        *float64 `json:"min_quality,omitempty"`
+	// TimeoutMs bounds the total time of the search (query embedding and vector search), 0 means no limit
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// KeywordFallback returns keyword search results when the query embedding does not complete in time
+	KeywordFallback bool `json:"keyword_fallback,omitempty"`
+	// Filters restrict the search to the documents whose JSON metadata matches, e.g. {"source":"wiki","year":{"gte":2020}}
+	Filters map[string]interface{} `json:"filters,omitempty"`
+	// Collection is the collection of the documents (default: the main index)
+	Collection string `json:"collection,omitempty"`
+	// SnippetSize adds to each result a snippet of at most SnippetSize characters centered on its sentence most
+	// similar to the query (0: no snippets)
+	SnippetSize int `json:"snippet_size,omitempty"`
+	// MaxContentChars cuts the content of each result to MaxContentChars characters (0: whole content)
+	MaxContentChars int `json:"max_content_chars,omitempty"`
+	// ExpandContext adds to each chunk of the results the ExpandContext chunks before and after it of its document,
+	// concatenated (0: no expansion)
+	ExpandContext int `json:"expand_context,omitempty"`
+	// Rerank retrieves more candidates than max_count and returns the max_count most relevant according to the reranker
+	Rerank bool `json:"rerank,omitempty"`
+	// Diversity selects the results by maximal marginal relevance among more candidates, from 0 (by similarity) to 1
+	// (the most different from each other), so that the results are not near duplicates
+	Diversity float64 `json:"diversity,omitempty"`
+	// RecencyHalfLifeHours ranks the results by similarity and freshness, the freshness of a document halving every
+	// RecencyHalfLifeHours hours (0: by similarity)
+	RecencyHalfLifeHours float64 `json:"recency_half_life_hours,omitempty"`
+	// RecencyWeight is the share of the freshness in the recency ranking, up to 1 (0: the default weight, 0.3)
+	RecencyWeight float64 `json:"recency_weight,omitempty"`
+	// ExpandQuery also searches paraphrases of the query generated by the chat model, and fuses the rankings
+	ExpandQuery bool `json:"expand_query,omitempty"`
+	// Debug adds the timings of the search to the response
+	Debug bool `json:"debug,omitempty"`
+}
+
+// SimilaritySearchWithLabelsRequest represents the request for similarity search filtered by several labels
+type SimilaritySearchWithLabelsRequest struct {
+	Text   string   `json:"text"`
+	Labels []string `json:"labels"`
+	// Match is "any" (documents having any of the labels, default) or "all" (documents having all the labels)
+	Match             string   `json:"match,omitempty"`
+	MaxCount          int      `json:"max_count"`
+	DistanceThreshold *float64 `json:"distance_threshold,omitempty"`
+	MinQuality        *float64 `json:"min_quality,omitempty"`
+	// TimeoutMs bounds the total time of the search (query embedding and vector search), 0 means no limit
+	TimeoutMs int `json:"timeout_ms,omitempty"`
+	// KeywordFallback returns keyword search results when the query embedding does not complete in time
+	KeywordFallback bool `json:"keyword_fallback,omitempty"`
+	// Filters restrict the search to the documents whose JSON metadata matches, e.g. {"source":"wiki","year":{"gte":2020}}
+	Filters map[string]interface{} `json:"filters,omitempty"`
+	// Collection is the collection of the documents (default: the main index)
+	Collection string `json:"collection,omitempty"`
+	// SnippetSize adds to each result a snippet of at most SnippetSize characters centered on its sentence most
+	// similar to the query (0: no snippets)
+	SnippetSize int `json:"snippet_size,omitempty"`
+	// MaxContentChars cuts the content of each result to MaxContentChars characters (0: whole content)
+	MaxContentChars int `json:"max_content_chars,omitempty"`
+	// ExpandContext adds to each chunk of the results the ExpandContext chunks before and after it of its document,
+	// concatenated (0: no expansion)
+	ExpandContext int `json:"expand_context,omitempty"`
+	// Rerank retrieves more candidates than max_count and returns the max_count most relevant according to the reranker
+	Rerank bool `json:"rerank,omitempty"`
+	// Diversity selects the results by maximal marginal relevance among more candidates, from 0 (by similarity) to 1
+	// (the most different from each other), so that the results are not near duplicates
+	Diversity float64 `json:"diversity,omitempty"`
+	// RecencyHalfLifeHours ranks the results by similarity and freshness, the freshness of a document halving every
+	// RecencyHalfLifeHours hours (0: by similarity)
+	RecencyHalfLifeHours float64 `json:"recency_half_life_hours,omitempty"`
+	// RecencyWeight is the share of the freshness in the recency ranking, up to 1 (0: the default weight, 0.3)
+	RecencyWeight float64 `json:"recency_weight,omitempty"`
+	// ExpandQuery also searches paraphrases of the query generated by the chat model, and fuses the rankings
+	ExpandQuery bool `json:"expand_query,omitempty"`
+	// Debug adds the timings of the search to the response
+	Debug bool `json:"debug,omitempty"`
+}
+
+// ChunkProvenance locates a chunk in the document it was split from: the chunks of a document share its parent ID
+// and are numbered from 0 by chunk index, and the offsets are the position of the chunk in the document, in characters
+// (without offsets when the chunk is not found verbatim in the document). The documents stored whole have none.
+type ChunkProvenance struct {
+	ParentID    string `json:"parent_id,omitempty"`
+	ChunkIndex  *int   `json:"chunk_index,omitempty"`
+	StartOffset *int   `json:"start_offset,omitempty"`
+	EndOffset   *int   `json:"end_offset,omitempty"`
 }
 
 // SimilaritySearchResult represents a single search result
 type SimilaritySearchResult struct {
-	ID        string  `json:"id"`
-	Content   string  `json:"content"`
-	Label     string  `json:"label"`
-	Metadata  string  `json:"metadata"`
-	Distance  float64 `json:"distance"`
-	CreatedAt string  `json:"created_at"`
+	ID        string   `json:"id"`
+	Content   string   `json:"content"`
+	Label     string   `json:"label"`
+	Labels    []string `json:"labels,omitempty"` // labels of the document (label holds them comma separated)
+	Metadata  string   `json:"metadata"`
+	Distance  float64  `json:"distance"`
+	Quality   float64  `json:"quality"`
+	CreatedAt string   `json:"created_at"`
+	// Title and Hierarchy are the section title and the breadcrumb (e.g. "Manual > Setup > TLS") of the chunks
+	// stored by the markdown hierarchy splitter
+	Title     string `json:"title,omitempty"`
+	Hierarchy string `json:"hierarchy,omitempty"`
+	ChunkProvenance
+	// Snippet is the part of the content around its sentence most similar to the query, only with snippet_size
+	Snippet string `json:"snippet,omitempty"`
+	// ExpandedContent is the content with its neighbouring chunks of the same document, only with expand_context
+	ExpandedContent string `json:"expanded_content,omitempty"`
+	// IsTruncated is true when the content is cut to max_content_chars characters: ContentLength is the number of
+	// characters of the whole content, and the document gives the rest from NextOffset (content_offset parameter)
+	IsTruncated   bool `json:"is_truncated,omitempty"`
+	ContentLength int  `json:"content_length,omitempty"`
+	NextOffset    int  `json:"next_offset,omitempty"`
+	// RerankScore is the relevance score given by the reranker (higher is more relevant), only with rerank
+	RerankScore *float64 `json:"rerank_score,omitempty"`
+	// RecencyScore combines the similarity and the freshness of the document (higher is better), only with
+	// recency_half_life_hours
+	RecencyScore *float64 `json:"recency_score,omitempty"`
+}
+
+// QuotaWarning warns that the documents written by a request approach a quota (see store.Quotas)
+type QuotaWarning struct {
+	Quota      string `json:"quota"` // documents, label_documents or memory
+	Collection string `json:"collection,omitempty"`
+	Label      string `json:"label,omitempty"`
+	Used       int64  `json:"used"`
+	Limit      int64  `json:"limit"`
+	Message    string `json:"message"`
 }
 
 // SimilaritySearchResponse represents the response for similarity search
 type SimilaritySearchResponse struct {
 	Results []SimilaritySearchResult `json:"results"`
-	Success bool                     `json:"success"`
-	Error   string                   `json:"error,omitempty"`
+	// Redacted is true when the content of the results is withheld because of the role of the caller
+	Redacted bool `json:"redacted,omitempty"`
+	// Fallback is "keyword" when the results come from a keyword search (the query embedding timed out)
+	Fallback string `json:"fallback,omitempty"`
+	// Reranked is true when the results are ordered by the reranker (a failing reranker keeps the vector order)
+	Reranked bool `json:"reranked,omitempty"`
+	// ExpandedQueries are the paraphrases of the query searched with it, only with expand_query (none when the
+	// chat model fails)
+	ExpandedQueries []string `json:"expanded_queries,omitempty"`
+	// Timings is the time spent by the search, only with debug
+	Timings *SearchTimings `json:"timings,omitempty"`
+	Success bool           `json:"success"`
+	Error   string         `json:"error,omitempty"`
+}
+
+// AskRequest represents a question answered by the chat model from the chunks retrieved for it
+type AskRequest struct {
+	Question          string   `json:"question"`
+	MaxCount          int      `json:"max_count,omitempty"` // number of chunks given to the chat model (default: 5)
+	Label             string   `json:"label,omitempty"`
+	DistanceThreshold *float64 `json:"distance_threshold,omitempty"`
+	MinQuality        *float64 `json:"min_quality,omitempty"`
+	// Filters restrict the chunks to the documents whose JSON metadata matches (see SimilaritySearchRequest)
+	Filters    map[string]interface{} `json:"filters,omitempty"`
+	Collection string                 `json:"collection,omitempty"`
+	// ExpandContext gives the chat model each chunk with the ExpandContext chunks before and after it (0: the chunk)
+	ExpandContext int `json:"expand_context,omitempty"`
+	// ResponseLanguage is the language of the answer, e.g. "French" (default: the language of the question)
+	ResponseLanguage string `json:"response_language,omitempty"`
+}
+
+// AskResponse represents the answer to a question, with the chunks cited by the answer and all the chunks retrieved
+type AskResponse struct {
+	Answer    string                   `json:"answer"`
+	Citations []string                 `json:"citations"` // IDs of the chunks cited by the answer
+	Sources   []SimilaritySearchResult `json:"sources"`
+	Model     string                   `json:"model,omitempty"`
+	Success   bool                     `json:"success"`
+	Error     string                   `json:"error,omitempty"`
+}
+
+// SearchTimings represents the time spent by a search in milliseconds: query embedding, search of the store,
+// post-processing of the results and total time of the request
+type SearchTimings struct {
+	EmbedMs  float64 `json:"embed_ms"`
+	SearchMs float64 `json:"search_ms"`
+	PostMs   float64 `json:"post_ms"`
+	TotalMs  float64 `json:"total_ms"`
+}
+
+// HybridSearchRequest represents the request for hybrid (full-text and vector) search
+type HybridSearchRequest struct {
+	Text       string   `json:"text"`
+	MaxCount   int      `json:"max_count"`
+	Label      string   `json:"label,omitempty"`
+	MinQuality *float64 `json:"min_quality,omitempty"`
+	// Fusion is "rrf" (reciprocal rank fusion, default) or "weighted" (weighted sum of the normalized scores)
+	Fusion string `json:"fusion,omitempty"`
+	// VectorWeight is the weight of the vector score with the weighted fusion (default: 0.5)
+	VectorWeight *float64 `json:"vector_weight,omitempty"`
+	// Filters restrict the search to the documents whose JSON metadata matches, e.g. {"source":"wiki","year":{"gte":2020}}
+	Filters map[string]interface{} `json:"filters,omitempty"`
+	// SearchFields are the fields matched by the text search: "content" (default), "metadata" (the whole JSON metadata)
+	// or metadata fields of type text, e.g. ["content","title","url"]
+	SearchFields []string `json:"search_fields,omitempty"`
+	// FieldWeights override the weights of the section fields in the text search, e.g. {"title":5,"hierarchy":2}
+	FieldWeights map[string]float64 `json:"field_weights,omitempty"`
+	// Collection is the collection of the documents (default: the main index)
+	Collection string `json:"collection,omitempty"`
+	// SnippetSize adds to each result a snippet of at most SnippetSize characters centered on its sentence most
+	// similar to the query (0: no snippets)
+	SnippetSize int `json:"snippet_size,omitempty"`
+	// MaxContentChars cuts the content of each result to MaxContentChars characters (0: whole content)
+	MaxContentChars int `json:"max_content_chars,omitempty"`
+	// ExpandContext adds to each chunk of the results the ExpandContext chunks before and after it of its document,
+	// concatenated (0: no expansion)
+	ExpandContext int `json:"expand_context,omitempty"`
+	// Rerank retrieves more candidates than max_count and returns the max_count most relevant according to the reranker
+	Rerank bool `json:"rerank,omitempty"`
+	// Debug adds the timings of the search to the response
+	Debug bool `json:"debug,omitempty"`
+}
+
+// HybridSearchResult represents a single hybrid search result.
+// Distance is only set for documents found by the vector search, TextScore for documents found by the text search.
+type HybridSearchResult struct {
+	ID         string   `json:"id"`
+	Content    string   `json:"content"`
+	Label      string   `json:"label"`
+	Labels     []string `json:"labels,omitempty"`
+	Metadata   string   `json:"metadata"`
+	Score      float64  `json:"score"`
+	Distance   *float64 `json:"distance,omitempty"`
+	TextScore  *float64 `json:"text_score,omitempty"`
+	VectorRank int      `json:"vector_rank,omitempty"`
+	TextRank   int      `json:"text_rank,omitempty"`
+	Quality    float64  `json:"quality"`
+	CreatedAt  string   `json:"created_at"`
+	Title      string   `json:"title,omitempty"`     // section title of a markdown hierarchy chunk
+	Hierarchy  string   `json:"hierarchy,omitempty"` // breadcrumb of a markdown hierarchy chunk
+	Snippet    string   `json:"snippet,omitempty"`   // part of the content around its sentence most similar to the query
+	ChunkProvenance
+	// ExpandedContent is the content with its neighbouring chunks of the same document (see SimilaritySearchResult)
+	ExpandedContent string `json:"expanded_content,omitempty"`
+	// IsTruncated, ContentLength and NextOffset describe a content cut to max_content_chars (see SimilaritySearchResult)
+	IsTruncated   bool `json:"is_truncated,omitempty"`
+	ContentLength int  `json:"content_length,omitempty"`
+	NextOffset    int  `json:"next_offset,omitempty"`
+	// RerankScore is the relevance score given by the reranker, only with rerank (see SimilaritySearchResult)
+	RerankScore *float64 `json:"rerank_score,omitempty"`
+}
+
+// HybridSearchResponse represents the response for hybrid search
+type HybridSearchResponse struct {
+	Results  []HybridSearchResult `json:"results"`
+	Fusion   string               `json:"fusion,omitempty"`
+	Redacted bool                 `json:"redacted,omitempty"` // content withheld because of the role of the caller
+	Reranked bool                 `json:"reranked,omitempty"` // results ordered by the reranker
+	Timings  *SearchTimings       `json:"timings,omitempty"`  // only with debug
+	Success  bool                 `json:"success"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// ChunkStoreOptions represents the options shared by all the chunk and store requests
+type ChunkStoreOptions struct {
+	IDStrategy string `json:"id_strategy,omitempty"` // "uuid" (default) or "content_hash"
+	SourceID   string `json:"source_id,omitempty"`   // identifies the source document when id_strategy is "content_hash"
+	// ContinueOnError stores what it can instead of aborting on the first failed chunk
+	ContinueOnError bool `json:"continue_on_error,omitempty"`
+	// IncludeContent returns the full text of each stored chunk instead of a preview
+	IncludeContent bool `json:"include_content,omitempty"`
+	// Collection is the collection of the chunks (default: the main index)
+	Collection string `json:"collection,omitempty"`
+	// TTLSeconds is the time after which the chunks are deleted (0 means no expiration)
+	TTLSeconds int `json:"ttl_seconds,omitempty"`
+	// Dedup is the handling of the chunks whose content is already stored: "off" (default), "skip" or "upsert"
+	Dedup string `json:"dedup,omitempty"`
+	// DocID identifies the request like the Idempotency-Key header: a retried request does not store the chunks twice
+	DocID string `json:"doc_id,omitempty"`
+	// Rollback deletes the chunks already stored when a failed chunk aborts the ingestion (not with ContinueOnError)
+	Rollback bool `json:"rollback,omitempty"`
+	// Atomic creates all the embeddings before storing the chunks in a single transaction: all or none are stored
+	Atomic bool `json:"atomic,omitempty"`
+	// Async returns an ingestion job immediately (202 Accepted) and stores the chunks in the background
+	Async bool `json:"async,omitempty"`
+}
+
+// ChunkPreview represents a stored chunk returned by the chunk and store requests
+type ChunkPreview struct {
+	ID      string `json:"id"`
+	URL     string `json:"url,omitempty"` // URL of the chunk stored by the request (GET /documents/{id}), set by the REST API
+	Index   int    `json:"index"`
+	Preview string `json:"preview,omitempty"`
+	Content string `json:"content,omitempty"`
+	// Duplicate is true when the chunk was already stored (dedup "skip"), ID is the ID of the stored chunk
+	Duplicate bool `json:"duplicate,omitempty"`
+}
+
+// Chunk statuses
+const (
+	ChunkStatusStored     = "stored"
+	ChunkStatusFailed     = "failed"
+	ChunkStatusDuplicate  = "duplicate"   // already stored (dedup "skip"), not stored again
+	ChunkStatusRolledBack = "rolled_back" // stored, then deleted when the ingestion failed (rollback)
+)
+
+// ChunkStatus represents the outcome of the ingestion of a chunk
+type ChunkStatus struct {
+	Index  int    `json:"index"`
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
 }
 
 // ChunkAndStoreRequest represents the request to chunk and store a document
 type ChunkAndStoreRequest struct {
-	Document  string `json:"document"`
-	Label     string `json:"label"`
-	Metadata  string `json:"metadata"`
-	ChunkSize int    `json:"chunk_size"`
-	Overlap   int    `json:"overlap"`
+	Document  string   `json:"document"`
+	Label     string   `json:"label"`
+	Labels    []string `json:"labels,omitempty"` // additional labels of the chunks
+	Metadata  string   `json:"metadata"`
+	ChunkSize int      `json:"chunk_size"`
+	Overlap   int      `json:"overlap"`
+	ChunkStoreOptions
+}
+
+// RecursiveChunkAndStoreRequest represents the request to chunk a document at paragraph, sentence and word boundaries and store all chunks
+type RecursiveChunkAndStoreRequest struct {
+	Document   string   `json:"document"`
+	Label      string   `json:"label"`
+	Labels     []string `json:"labels,omitempty"` // additional labels of the chunks
+	Metadata   string   `json:"metadata"`
+	ChunkSize  int      `json:"chunk_size"`
+	Overlap    int      `json:"overlap"`
+	Separators []string `json:"separators,omitempty"` // boundaries tried from the first to the last (default: paragraphs, lines, sentences, words, characters)
+	ChunkStoreOptions
+}
+
+// SemanticChunkAndStoreRequest represents the request to chunk a document into groups of related sentences and store all chunks
+type SemanticChunkAndStoreRequest struct {
+	Document     string   `json:"document"`
+	Label        string   `json:"label"`
+	Labels       []string `json:"labels,omitempty"` // additional labels of the chunks
+	Metadata     string   `json:"metadata"`
+	Threshold    *float64 `json:"threshold,omitempty"`      // similarity below which a new chunk starts, from -1 to 1 (default: 0.75)
+	MaxChunkSize int      `json:"max_chunk_size,omitempty"` // maximum size of a chunk in characters (default: 2000)
+	ChunkStoreOptions
 }
 
 // ChunkAndStoreResponse represents the response after chunking and storing a document
 type ChunkAndStoreResponse struct {
-	ChunkIDs     []string  `json:"chunk_ids"`
-	ChunksStored int       `json:"chunks_stored"`
-	CreatedAt    time.Time `json:"created_at"`
-	Success      bool      `json:"success"`
-	Error        string    `json:"error,omitempty"`
+	SourceID      string         `json:"source_id,omitempty"`
+	ChunkIDs      []string       `json:"chunk_ids"`
+	Chunks        []ChunkPreview `json:"chunks"`
+	ChunksStored  int            `json:"chunks_stored"`
+	ChunksFailed  int            `json:"chunks_failed,omitempty"`
+	ChunkStatuses []ChunkStatus  `json:"chunk_statuses,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Success       bool           `json:"success"`
+	Error         string         `json:"error,omitempty"`
 }
 
 // SplitAndStoreMarkdownSectionsRequest represents the request to split markdown by sections and store
 type SplitAndStoreMarkdownSectionsRequest struct {
-	Document string `json:"document"`
-	Label    string `json:"label"`
-	Metadata string `json:"metadata"`
+	Document string   `json:"document"`
+	Label    string   `json:"label"`
+	Labels   []string `json:"labels,omitempty"` // additional labels of the chunks
+	Metadata string   `json:"metadata"`
+	ChunkStoreOptions
 }
 
 // SplitAndStoreMarkdownSectionsResponse represents the response after splitting and storing markdown sections
 type SplitAndStoreMarkdownSectionsResponse struct {
-	ChunkIDs     []string  `json:"chunk_ids"`
-	ChunksStored int       `json:"chunks_stored"`
-	CreatedAt    time.Time `json:"created_at"`
-	Success      bool      `json:"success"`
-	Error        string    `json:"error,omitempty"`
+	SourceID      string         `json:"source_id,omitempty"`
+	ChunkIDs      []string       `json:"chunk_ids"`
+	Chunks        []ChunkPreview `json:"chunks"`
+	ChunksStored  int            `json:"chunks_stored"`
+	ChunksFailed  int            `json:"chunks_failed,omitempty"`
+	ChunkStatuses []ChunkStatus  `json:"chunk_statuses,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Success       bool           `json:"success"`
+	Error         string         `json:"error,omitempty"`
 }
 
 // SplitAndStoreWithDelimiterRequest represents the request to split text with a delimiter and store
 type SplitAndStoreWithDelimiterRequest struct {
-	Document  string `json:"document"`
-	Delimiter string `json:"delimiter"`
-	Label     string `json:"label"`
-	Metadata  string `json:"metadata"`
+	Document  string   `json:"document"`
+	Delimiter string   `json:"delimiter"`
+	Label     string   `json:"label"`
+	Labels    []string `json:"labels,omitempty"` // additional labels of the chunks
+	Metadata  string   `json:"metadata"`
+	ChunkStoreOptions
 }
 
 // SplitAndStoreWithDelimiterResponse represents the response after splitting and storing with delimiter
 type SplitAndStoreWithDelimiterResponse struct {
-	ChunkIDs     []string  `json:"chunk_ids"`
-	ChunksStored int       `json:"chunks_stored"`
-	CreatedAt    time.Time `json:"created_at"`
-	Success      bool      `json:"success"`
-	Error        string    `json:"error,omitempty"`
+	SourceID      string         `json:"source_id,omitempty"`
+	ChunkIDs      []string       `json:"chunk_ids"`
+	Chunks        []ChunkPreview `json:"chunks"`
+	ChunksStored  int            `json:"chunks_stored"`
+	ChunksFailed  int            `json:"chunks_failed,omitempty"`
+	ChunkStatuses []ChunkStatus  `json:"chunk_statuses,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Success       bool           `json:"success"`
+	Error         string         `json:"error,omitempty"`
 }
 
 // SplitAndStoreMarkdownWithHierarchyRequest represents the request to split markdown with hierarchy and store
 type SplitAndStoreMarkdownWithHierarchyRequest struct {
-	Document string `json:"document"`
-	Label    string `json:"label"`
-	Metadata string `json:"metadata"`
+	Document string   `json:"document"`
+	Label    string   `json:"label"`
+	Labels   []string `json:"labels,omitempty"` // additional labels of the chunks
+	Metadata string   `json:"metadata"`
+	ChunkStoreOptions
 }
 
 // SplitAndStoreMarkdownWithHierarchyResponse represents the response after splitting and storing markdown with hierarchy
 type SplitAndStoreMarkdownWithHierarchyResponse struct {
-	ChunkIDs     []string  `json:"chunk_ids"`
-	ChunksStored int       `json:"chunks_stored"`
-	CreatedAt    time.Time `json:"created_at"`
-	Success      bool      `json:"success"`
-	Error        string    `json:"error,omitempty"`
+	SourceID      string         `json:"source_id,omitempty"`
+	ChunkIDs      []string       `json:"chunk_ids"`
+	Chunks        []ChunkPreview `json:"chunks"`
+	ChunksStored  int            `json:"chunks_stored"`
+	ChunksFailed  int            `json:"chunks_failed,omitempty"`
+	ChunkStatuses []ChunkStatus  `json:"chunk_statuses,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Success       bool           `json:"success"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// SplitAndStoreRequest represents the request to split a document with a registered strategy and store all chunks
+type SplitAndStoreRequest struct {
+	Document string                 `json:"document"`
+	Strategy string                 `json:"strategy"`
+	Filename string                 `json:"filename,omitempty"` // selects the strategy from the file extension when strategy is not set
+	Options  map[string]interface{} `json:"options,omitempty"`
+	Label    string                 `json:"label"`
+	Labels   []string               `json:"labels,omitempty"` // additional labels of the chunks
+	Metadata string                 `json:"metadata"`
+	ChunkStoreOptions
+}
+
+// SplitAndStoreResponse represents the response after splitting a document with a registered strategy and storing it
+type SplitAndStoreResponse struct {
+	Strategy      string         `json:"strategy,omitempty"`
+	SourceID      string         `json:"source_id,omitempty"`
+	ChunkIDs      []string       `json:"chunk_ids"`
+	Chunks        []ChunkPreview `json:"chunks"`
+	ChunksStored  int            `json:"chunks_stored"`
+	ChunksFailed  int            `json:"chunks_failed,omitempty"`
+	ChunkStatuses []ChunkStatus  `json:"chunk_statuses,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Success       bool           `json:"success"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// ChunkPreviewRequest represents the request to split a document with a registered strategy without storing it
+type ChunkPreviewRequest struct {
+	Document string                 `json:"document"`
+	Strategy string                 `json:"strategy"`
+	Filename string                 `json:"filename,omitempty"` // selects the strategy from the file extension when strategy is not set
+	Options  map[string]interface{} `json:"options,omitempty"`
+}
+
+// PreviewedChunk is a chunk of a chunk preview, with its size and the quality score it would be stored with
+type PreviewedChunk struct {
+	Index      int     `json:"index"`
+	Content    string  `json:"content"`
+	Characters int     `json:"characters"`
+	Tokens     int     `json:"tokens"`
+	Quality    float64 `json:"quality"`
+}
+
+// ChunkPreviewResponse represents the chunks of a document split without creating embeddings or storing them
+type ChunkPreviewResponse struct {
+	Strategy       string                 `json:"strategy,omitempty"`
+	Options        map[string]interface{} `json:"options,omitempty"` // options of the strategy, with the defaults of the file type
+	Chunks         []PreviewedChunk       `json:"chunks"`
+	ChunkCount     int                    `json:"chunk_count"`
+	MinTokens      int                    `json:"min_tokens"`
+	MaxTokens      int                    `json:"max_tokens"`
+	AvgTokens      float64                `json:"avg_tokens"`
+	MaxInputTokens int                    `json:"max_input_tokens,omitempty"` // limit of the embedding model
+	Success        bool                   `json:"success"`
+	Error          string                 `json:"error,omitempty"`
+}
+
+// SplitAndStoreEmailRequest represents the request to split an email archive (.eml or mbox) by message and store
+type SplitAndStoreEmailRequest struct {
+	Document string   `json:"document"` // a single message, or an mbox archive
+	Label    string   `json:"label"`
+	Labels   []string `json:"labels,omitempty"` // additional labels of the chunks
+	Metadata string   `json:"metadata"`         // JSON object completed with the headers of each message
+	ChunkStoreOptions
+}
+
+// SplitAndStoreEmailResponse represents the response after splitting and storing an email archive
+type SplitAndStoreEmailResponse struct {
+	SourceID      string         `json:"source_id,omitempty"`
+	Messages      int            `json:"messages"`
+	ChunkIDs      []string       `json:"chunk_ids"`
+	Chunks        []ChunkPreview `json:"chunks"`
+	ChunksStored  int            `json:"chunks_stored"`
+	ChunksFailed  int            `json:"chunks_failed,omitempty"`
+	ChunkStatuses []ChunkStatus  `json:"chunk_statuses,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Success       bool           `json:"success"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// SplitAndStoreSubtitlesRequest represents the request to split a subtitle file (SRT or WebVTT) by time window and store
+type SplitAndStoreSubtitlesRequest struct {
+	Document      string   `json:"document"` // the SRT or WebVTT file
+	Label         string   `json:"label"`
+	Labels        []string `json:"labels,omitempty"`         // additional labels of the chunks
+	Metadata      string   `json:"metadata"`                 // JSON object completed with the time interval of each chunk
+	WindowSeconds int      `json:"window_seconds,omitempty"` // duration of the time windows (default: 60)
+	VideoURL      string   `json:"video_url,omitempty"`      // URL of the video, for the deep links of the chunks
+	ChunkStoreOptions
+}
+
+// SplitAndStoreSubtitlesResponse represents the response after splitting and storing a subtitle file
+type SplitAndStoreSubtitlesResponse struct {
+	SourceID      string         `json:"source_id,omitempty"`
+	Captions      int            `json:"captions"`
+	ChunkIDs      []string       `json:"chunk_ids"`
+	Chunks        []ChunkPreview `json:"chunks"`
+	ChunksStored  int            `json:"chunks_stored"`
+	ChunksFailed  int            `json:"chunks_failed,omitempty"`
+	ChunkStatuses []ChunkStatus  `json:"chunk_statuses,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Success       bool           `json:"success"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// SplitAndStoreOfficeRequest represents the request to convert an Office document (docx or pptx) and store its chunks
+type SplitAndStoreOfficeRequest struct {
+	Document []byte   `json:"document"`           // the document file (base64 in JSON)
+	Filename string   `json:"filename,omitempty"` // file name of the document, giving its format from its extension
+	Format   string   `json:"format,omitempty"`   // "docx" or "pptx" (default: from the file name or the content type)
+	Label    string   `json:"label"`
+	Labels   []string `json:"labels,omitempty"` // additional labels of the chunks
+	Metadata string   `json:"metadata"`
+	ChunkStoreOptions
+}
+
+// OCRImage is an image of an Office document whose text was read by OCR
+type OCRImage struct {
+	Image string `json:"image"` // part of the image in the document, e.g. "word/media/image1.png"
+	Page  int    `json:"page"`  // page (docx) or slide (pptx) of the image
+	// Position and size of the image in its page or slide, in EMUs (914400 per inch)
+	X          int64  `json:"x"`
+	Y          int64  `json:"y"`
+	Width      int64  `json:"width"`
+	Height     int64  `json:"height"`
+	Characters int    `json:"characters"`      // characters of the text read in the image
+	Error      string `json:"error,omitempty"` // the image was not read
+}
+
+// SplitAndStoreOfficeResponse represents the response after converting and storing an Office document
+type SplitAndStoreOfficeResponse struct {
+	SourceID      string         `json:"source_id,omitempty"`
+	Format        string         `json:"format,omitempty"`
+	OCRImages     []OCRImage     `json:"ocr_images,omitempty"`
+	ChunkIDs      []string       `json:"chunk_ids"`
+	Chunks        []ChunkPreview `json:"chunks"`
+	ChunksStored  int            `json:"chunks_stored"`
+	ChunksFailed  int            `json:"chunks_failed,omitempty"`
+	ChunkStatuses []ChunkStatus  `json:"chunk_statuses,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Success       bool           `json:"success"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// SplitAndStoreHTMLRequest represents the request to convert an HTML page to markdown (without its boilerplate) and store its chunks
+type SplitAndStoreHTMLRequest struct {
+	Document string   `json:"document"`
+	Label    string   `json:"label"`
+	Labels   []string `json:"labels,omitempty"` // additional labels of the chunks
+	Metadata string   `json:"metadata"`
+	ChunkStoreOptions
+}
+
+// FetchAndStoreURLRequest represents the request to download a web page and store its chunks
+type FetchAndStoreURLRequest struct {
+	URL      string   `json:"url"` // http or https URL of the page (HTML, markdown or plain text)
+	Label    string   `json:"label"`
+	Labels   []string `json:"labels,omitempty"` // additional labels of the chunks
+	Metadata string   `json:"metadata"`         // completed with the url and fetched_at fields
+	ChunkStoreOptions
+}
+
+// FetchAndStoreURLResponse represents the response after downloading a web page and storing its chunks
+type FetchAndStoreURLResponse struct {
+	SourceID      string         `json:"source_id,omitempty"`
+	URL           string         `json:"url,omitempty"`          // URL of the page, after the redirects
+	ContentType   string         `json:"content_type,omitempty"` // media type of the page
+	ChunkIDs      []string       `json:"chunk_ids"`
+	Chunks        []ChunkPreview `json:"chunks"`
+	ChunksStored  int            `json:"chunks_stored"`
+	ChunksFailed  int            `json:"chunks_failed,omitempty"`
+	ChunkStatuses []ChunkStatus  `json:"chunk_statuses,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Success       bool           `json:"success"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// IngestGitHubRequest represents the request to store the chunks of the files of a GitHub repository
+type IngestGitHubRequest struct {
+	RepoURL  string   `json:"repo_url"`          // https://github.com/owner/repo, github.com/owner/repo or owner/repo
+	Branch   string   `json:"branch,omitempty"`  // branch, tag or commit (default: the default branch of the repository)
+	Include  []string `json:"include,omitempty"` // glob patterns of the files to ingest, e.g. "docs/**/*.md" (default: all)
+	Exclude  []string `json:"exclude,omitempty"` // glob patterns of the files to skip, e.g. "vendor/**"
+	Label    string   `json:"label"`
+	Labels   []string `json:"labels,omitempty"` // additional labels of the chunks
+	Metadata string   `json:"metadata"`         // completed with the repo, path, branch and commit fields
+	ChunkStoreOptions
+}
+
+// IngestGitHubResponse represents the response after storing the chunks of the files of a GitHub repository
+type IngestGitHubResponse struct {
+	SourceID      string         `json:"source_id,omitempty"`
+	Repo          string         `json:"repo,omitempty"`   // "owner/name"
+	Branch        string         `json:"branch,omitempty"` // branch, tag or commit of the files
+	Commit        string         `json:"commit,omitempty"` // SHA of the commit of the files
+	FilesIngested int            `json:"files_ingested"`
+	FilesSkipped  int            `json:"files_skipped,omitempty"` // binary or too large files, files beyond the limit
+	ChunkIDs      []string       `json:"chunk_ids"`
+	Chunks        []ChunkPreview `json:"chunks"`
+	ChunksStored  int            `json:"chunks_stored"`
+	ChunksFailed  int            `json:"chunks_failed,omitempty"`
+	ChunkStatuses []ChunkStatus  `json:"chunk_statuses,omitempty"`
+	CreatedAt     time.Time      `json:"created_at"`
+	Success       bool           `json:"success"`
+	Error         string         `json:"error,omitempty"`
+}
+
+// DocumentRecord represents a stored document
+type DocumentRecord struct {
+	ID          string    `json:"id"`
+	Content     string    `json:"content"`
+	Label       string    `json:"label"`
+	Labels      []string  `json:"labels,omitempty"`
+	Metadata    string    `json:"metadata"`
+	Quality     float64   `json:"quality"`
+	CreatedAt   string    `json:"created_at"`
+	UpdatedAt   string    `json:"updated_at,omitempty"`
+	ExpiresAt   string    `json:"expires_at,omitempty"` // only for the documents stored with a TTL
+	Version     int       `json:"version,omitempty"`    // only with the document versioning (DOCUMENT_VERSIONING)
+	Deleted     bool      `json:"deleted,omitempty"`    // deleted document, listed with its versions
+	SourceID    string    `json:"source_id,omitempty"`
+	OriginalRef string    `json:"original_ref,omitempty"`
+	Dimension   int       `json:"dimension"` // dimension of the vector of the document
+	Embedding   []float32 `json:"embedding,omitempty"`
+	ChunkProvenance
+	// ContentLength and NextOffset are set when a part of the content is requested (content_offset and
+	// max_content_chars): the number of characters of the whole content, and the offset of the rest (0: none left)
+	ContentLength int  `json:"content_length,omitempty"`
+	NextOffset    int  `json:"next_offset,omitempty"`
+	IsTruncated   bool `json:"is_truncated,omitempty"`
+}
+
+// GetDocumentResponse represents the response for a document retrieval
+type GetDocumentResponse struct {
+	Document *DocumentRecord `json:"document,omitempty"`
+	Redacted bool            `json:"redacted,omitempty"` // content withheld because of the role of the caller
+	Success  bool            `json:"success"`
+	Error    string          `json:"error,omitempty"`
+}
+
+// DocumentVersionsResponse represents the response listing the versions of a document (GET /documents/{id}/versions):
+// the previous versions, oldest first, then the current version
+type DocumentVersionsResponse struct {
+	ID       string           `json:"id"`
+	Version  int              `json:"version,omitempty"` // current version
+	Deleted  bool             `json:"deleted,omitempty"`
+	Versions []DocumentRecord `json:"versions,omitempty"`
+	Success  bool             `json:"success"`
+	Error    string           `json:"error,omitempty"`
+}
+
+// RestoreVersionResponse represents the response of the restoration of a version of a document
+// (POST /documents/{id}/versions/{version}/restore)
+type RestoreVersionResponse struct {
+	ID       string `json:"id"`
+	Restored int    `json:"restored,omitempty"` // restored version
+	Version  int    `json:"version,omitempty"`  // new current version
+	Success  bool   `json:"success"`
+	Error    string `json:"error,omitempty"`
+}
+
+// UpdateDocumentRequest represents the request to update a document (label and metadata are kept when omitted)
+type UpdateDocumentRequest struct {
+	Content  string  `json:"content"`
+	Label    *string `json:"label,omitempty"`
+	Metadata *string `json:"metadata,omitempty"`
+}
+
+// UpdateDocumentResponse represents the response after updating a document
+type UpdateDocumentResponse struct {
+	ID        string    `json:"id"`
+	Content   string    `json:"content"`
+	Label     string    `json:"label"`
+	Metadata  string    `json:"metadata"`
+	UpdatedAt time.Time `json:"updated_at"`
+	Success   bool      `json:"success"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// DeleteDocumentResponse represents the response after deleting a document
+type DeleteDocumentResponse struct {
+	ID      string `json:"id"`
+	Deleted bool   `json:"deleted"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// DeleteDocumentsRequest represents the request to delete several documents
+type DeleteDocumentsRequest struct {
+	IDs []string `json:"ids"`
+}
+
+// DeleteDocumentsResponse represents the response after deleting several documents
+type DeleteDocumentsResponse struct {
+	Deleted      []string `json:"deleted"`
+	NotFound     []string `json:"not_found"`
+	DeletedCount int      `json:"deleted_count"`
+	Success      bool     `json:"success"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// DeleteMatchingDocumentsResponse represents the response after deleting the documents having a label or split from
+// a parent document
+type DeleteMatchingDocumentsResponse struct {
+	Label        string `json:"label,omitempty"`
+	ParentID     string `json:"parent_id,omitempty"`
+	DeletedCount int    `json:"deleted_count"`
+	Success      bool   `json:"success"`
+	Error        string `json:"error,omitempty"`
+}
+
+// RenameLabelRequest represents the request to rename a label in all the documents having it
+type RenameLabelRequest struct {
+	From       string `json:"from"`
+	To         string `json:"to"`
+	Collection string `json:"collection,omitempty"` // collection of the documents (default: the main index)
+}
+
+// RetagDocumentsRequest represents the request to change the labels of the documents matching a filter
+type RetagDocumentsRequest struct {
+	// Label, Labels (with Match "any" or "all"), MinQuality and Filters select the documents, at least one is required
+	Label      string                 `json:"label,omitempty"`
+	Labels     []string               `json:"labels,omitempty"`
+	Match      string                 `json:"match,omitempty"`
+	MinQuality *float64               `json:"min_quality,omitempty"`
+	Filters    map[string]interface{} `json:"filters,omitempty"`
+	// SetLabels replaces the labels of the documents, AddLabels and RemoveLabels keep their other labels
+	SetLabels    []string `json:"set_labels,omitempty"`
+	AddLabels    []string `json:"add_labels,omitempty"`
+	RemoveLabels []string `json:"remove_labels,omitempty"`
+	Collection   string   `json:"collection,omitempty"`
+}
+
+// RetagDocumentsResponse represents the response after renaming a label or changing the labels of documents
+type RetagDocumentsResponse struct {
+	Matched int    `json:"matched"` // documents matching the filter
+	Updated int    `json:"updated"` // documents whose labels changed
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
+}
+
+// MemoryStats represents the memory usage of Redis
+type MemoryStats struct {
+	UsedMemory      int64    `json:"used_memory_bytes"`
+	UsedMemoryHuman string   `json:"used_memory_human"`
+	MaxMemory       int64    `json:"max_memory_bytes"`
+	MaxMemoryPolicy string   `json:"max_memory_policy"`
+	UsageRatio      *float64 `json:"usage_ratio,omitempty"`
+	WriteWatermark  int64    `json:"write_watermark_bytes,omitempty"`
+	WritesRefused   bool     `json:"writes_refused"`
+	EvictionWarning string   `json:"eviction_warning,omitempty"`
+}
+
+// EmbeddingStats represents the usage of the primary and fallback embedding providers
+type EmbeddingStats struct {
+	PrimaryRequests  int64 `json:"primary_requests"`
+	PrimaryFailures  int64 `json:"primary_failures"`
+	PrimarySkipped   int64 `json:"primary_skipped"` // requests sent to the fallback while the circuit was open
+	FallbackRequests int64 `json:"fallback_requests"`
+	FallbackFailures int64 `json:"fallback_failures"`
+	CircuitOpen      bool  `json:"circuit_open"`
+}
+
+// UsageTotal represents the usage of the embedding providers charged to a tenant, an API key and a label, for a model
+type UsageTotal struct {
+	Tenant          string `json:"tenant"`
+	APIKey          string `json:"api_key"` // fingerprint of the API key
+	Label           string `json:"label"`
+	Model           string `json:"model"`
+	Requests        int64  `json:"requests"`
+	Inputs          int64  `json:"inputs"`
+	EmbeddingTokens int64  `json:"embedding_tokens"`
+}
+
+// IndexStats represents the documents of an index: their number, per label, and the memory of the index
+type IndexStats struct {
+	Index              string         `json:"index"`
+	Collection         string         `json:"collection,omitempty"`
+	Documents          int            `json:"documents"`
+	Labels             map[string]int `json:"labels"` // number of documents per label (lowercased)
+	IndexMemoryMB      float64        `json:"index_memory_mb"`
+	EmbeddingDimension int            `json:"embedding_dimension"`
+}
+
+// FeatureFlag represents a feature flag and its current value
+type FeatureFlag struct {
+	Name        string `json:"name"`
+	Enabled     bool   `json:"enabled"`
+	Default     bool   `json:"default"`
+	Description string `json:"description"`
+}
+
+// FeatureFlagsResponse represents the response of the feature flags endpoint
+type FeatureFlagsResponse struct {
+	Flags   []FeatureFlag `json:"flags"`
+	Success bool          `json:"success"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// VersionResponse represents the build information of the server (version endpoint)
+type VersionResponse struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	BuildDate string `json:"build_date"`
+	GoVersion string `json:"go_version"`
+	Success   bool   `json:"success"`
+	Error     string `json:"error,omitempty"`
+}
+
+// StatsResponse represents the response of the stats endpoint
+type StatsResponse struct {
+	Index      *IndexStats     `json:"index,omitempty"`
+	Memory     *MemoryStats    `json:"memory,omitempty"`
+	Embeddings *EmbeddingStats `json:"embeddings,omitempty"` // only with a fallback embedding provider
+	Usage      []UsageTotal    `json:"usage,omitempty"`      // usage of the embedding providers
+	Success    bool            `json:"success"`
+	Error      string          `json:"error,omitempty"`
+}
+
+// QualityReportEntry represents a stored chunk listed in the quality report
+type QualityReportEntry struct {
+	ID        string  `json:"id"`
+	Content   string  `json:"content"`
+	Label     string  `json:"label"`
+	Metadata  string  `json:"metadata"`
+	Quality   float64 `json:"quality"`
+	CreatedAt string  `json:"created_at"`
+}
+
+// QualityReportResponse represents the response listing the lowest quality chunks
+type QualityReportResponse struct {
+	Chunks   []QualityReportEntry `json:"chunks"`
+	Redacted bool                 `json:"redacted,omitempty"` // content withheld because of the role of the caller
+	Reranked bool                 `json:"reranked,omitempty"` // results ordered by the reranker
+	Timings  *SearchTimings       `json:"timings,omitempty"`  // only with debug
+	Success  bool                 `json:"success"`
+	Error    string               `json:"error,omitempty"`
+}
+
+// ImportResponse represents the response of an import of the export of another vector store
+type ImportResponse struct {
+	Format string `json:"format,omitempty"`
+	// Imported and Failed count the stored and the failed documents, EmbeddingsKept the stored documents keeping
+	// the embedding of the export (keep_embeddings)
+	Imported       int           `json:"imported"`
+	Failed         int           `json:"failed,omitempty"`
+	EmbeddingsKept int           `json:"embeddings_kept,omitempty"`
+	IDs            []string      `json:"ids"`
+	Statuses       []ChunkStatus `json:"statuses,omitempty"` // status of each document, only when some failed
+	Success        bool          `json:"success"`
+	Error          string        `json:"error,omitempty"`
+}
+
+// Bulk ingestion event types
+const (
+	BulkEventDocument = "document"
+	BulkEventProgress = "progress"
+	BulkEventSummary  = "summary"
+)
+
+// BulkDocumentEvent is the line of the NDJSON response of the bulk ingestion endpoint reporting
+// the outcome of the document of a request line
+type BulkDocumentEvent struct {
+	Type   string `json:"type"`
+	Line   int    `json:"line"` // 1-based line number of the document in the request
+	ID     string `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// BulkProgressEvent is the line of the NDJSON response of the bulk ingestion endpoint counting the lines
+// processed so far (sent periodically, it also keeps the connection alive) or in total (final summary)
+type BulkProgressEvent struct {
+	Type       string `json:"type"`
+	Lines      int    `json:"lines"`
+	Stored     int    `json:"stored"`
+	Failed     int    `json:"failed"`
+	Duplicates int    `json:"duplicates,omitempty"` // lines already stored (dedup "skip")
+	Success    bool   `json:"success,omitempty"`    // summary only
+	Error      string `json:"error,omitempty"`      // summary only, when the request body could not be read to the end
+}
+
+// CreateCollectionRequest represents the request to create a collection
+type CreateCollectionRequest struct {
+	Name           string `json:"name"`
+	EmbeddingModel string `json:"embedding_model,omitempty"` // name of a registered embedding model (default: EMBEDDING_MODEL)
+}
+
+// CollectionResponse represents the response after creating or deleting a collection
+type CollectionResponse struct {
+	Name      string `json:"name,omitempty"`
+	IndexName string `json:"index_name,omitempty"`
+	KeyPrefix string `json:"key_prefix,omitempty"`
+	// EmbeddingModel is the name of the embedding model of the collection (empty for the default model)
+	EmbeddingModel string `json:"embedding_model,omitempty"`
+	Success        bool   `json:"success"`
+	Error          string `json:"error,omitempty"`
+}
+
+// CollectionsResponse represents the response listing the collections
+type CollectionsResponse struct {
+	Collections []string `json:"collections"`
+	Success     bool     `json:"success"`
+	Error       string   `json:"error,omitempty"`
+}
+
+// IndexInfo represents the document count, the indexing state and the memory usage of an index
+type IndexInfo struct {
+	Name               string   `json:"name"`
+	Collection         string   `json:"collection,omitempty"`
+	KeyPrefixes        []string `json:"key_prefixes"`
+	NumDocs            int      `json:"num_docs"`
+	NumRecords         int      `json:"num_records"`
+	Indexing           bool     `json:"indexing"`
+	PercentIndexed     float64  `json:"percent_indexed"`
+	IndexingFailures   int      `json:"indexing_failures"`
+	VectorIndexSizeMB  float64  `json:"vector_index_size_mb"`
+	InvertedSizeMB     float64  `json:"inverted_size_mb"`
+	DocTableSizeMB     float64  `json:"doc_table_size_mb"`
+	KeyTableSizeMB     float64  `json:"key_table_size_mb"`
+	TotalIndexMemoryMB float64  `json:"total_index_memory_mb"`
+	EmbeddingDimension int      `json:"embedding_dimension"`
+}
+
+// DocumentDimension is a stored document whose vector does not have the dimension of its index
+type DocumentDimension struct {
+	ID        string `json:"id"`
+	Dimension int    `json:"dimension"`
+}
+
+// IndexDimensionsResponse represents the response of the check of the dimension of the stored vectors of an index
+type IndexDimensionsResponse struct {
+	IndexName  string              `json:"index_name,omitempty"`
+	Collection string              `json:"collection,omitempty"`
+	Dimension  int                 `json:"dimension,omitempty"` // dimension of the index
+	Checked    int                 `json:"checked"`
+	Mismatched int                 `json:"mismatched"`
+	Documents  []DocumentDimension `json:"documents,omitempty"` // the first mismatched documents (up to limit)
+	Success    bool                `json:"success"`
+	Error      string              `json:"error,omitempty"`
+}
+
+// IndexInfoResponse represents the response of the index information endpoint
+type IndexInfoResponse struct {
+	Index   *IndexInfo   `json:"index,omitempty"`
+	Memory  *MemoryStats `json:"memory,omitempty"` // memory usage of the whole Redis server
+	Success bool         `json:"success"`
+	Error   string       `json:"error,omitempty"`
+}
+
+// IndexResponse represents the response after rebuilding or deleting an index
+type IndexResponse struct {
+	IndexName  string `json:"index_name,omitempty"`
+	Collection string `json:"collection,omitempty"`
+	Success    bool   `json:"success"`
+	Error      string `json:"error,omitempty"`
+}
+
+// Re-embedding job statuses
+const (
+	ReembedStatusRunning   = "running"
+	ReembedStatusCompleted = "completed"
+	ReembedStatusFailed    = "failed"
+)
+
+// ReembedJob represents the state of a job re-encoding the stored documents with the embedding model
+type ReembedJob struct {
+	IndexName      string `json:"index_name"`
+	Collection     string `json:"collection,omitempty"`
+	EmbeddingModel string `json:"embedding_model"`
+	Status         string `json:"status"`
+	Total          int    `json:"total"`
+	Reembedded     int    `json:"reembedded"`
+	Failed         int    `json:"failed"`
+	StartedAt      string `json:"started_at"`
+	CompletedAt    string `json:"completed_at,omitempty"`
+	Error          string `json:"error,omitempty"` // the last error (of a batch, or of the job when it failed)
+}
+
+// Ingestion job statuses
+const (
+	IngestionStatusQueued    = "queued" // waiting for a free slot (see INGESTION_JOB_MAX_CONCURRENCY)
+	IngestionStatusRunning   = "running"
+	IngestionStatusCompleted = "completed"
+	IngestionStatusFailed    = "failed"
+)
+
+// IngestionJob represents the state of an asynchronous chunk and store request
+type IngestionJob struct {
+	ID           string   `json:"id"`
+	Status       string   `json:"status"`
+	SourceID     string   `json:"source_id,omitempty"`
+	ChunksTotal  int      `json:"chunks_total"`
+	ChunksDone   int      `json:"chunks_done"` // chunks processed (stored, duplicate or failed)
+	ChunksStored int      `json:"chunks_stored"`
+	ChunksFailed int      `json:"chunks_failed"`
+	ChunkIDs     []string `json:"chunk_ids,omitempty"` // IDs of the chunks stored so far
+	Errors       []string `json:"errors,omitempty"`    // errors of the failed chunks
+	CreatedAt    string   `json:"created_at"`
+	CompletedAt  string   `json:"completed_at,omitempty"`
+	Error        string   `json:"error,omitempty"` // error of the job when it failed
+}
+
+// IngestionJobResponse represents the response of the asynchronous chunk and store requests and of the jobs endpoint
+type IngestionJobResponse struct {
+	Job     *IngestionJob `json:"job,omitempty"`
+	Success bool          `json:"success"`
+	Error   string        `json:"error,omitempty"`
+}
+
+// ReembedJobResponse represents the response of the re-embedding endpoint
+type ReembedJobResponse struct {
+	Job     *ReembedJob `json:"job,omitempty"`
+	Success bool        `json:"success"`
+	Error   string      `json:"error,omitempty"`
+}
+
+// IndexTombstone marks an index whose searches failed with an error showing a corruption, until it is repaired
+type IndexTombstone struct {
+	Reason     string `json:"reason"` // the first search error
+	DetectedAt string `json:"detected_at"`
+}
+
+// IndexRepairJob represents the state of a job rebuilding the definition of an index and indexing its documents again
+type IndexRepairJob struct {
+	IndexName        string  `json:"index_name"`
+	Collection       string  `json:"collection,omitempty"`
+	Status           string  `json:"status"` // running, completed or failed (same values as the re-embedding jobs)
+	Total            int     `json:"total"`  // stored documents
+	Indexed          int     `json:"indexed"`
+	PercentIndexed   float64 `json:"percent_indexed"`
+	IndexingFailures int     `json:"indexing_failures"`
+	StartedAt        string  `json:"started_at"`
+	CompletedAt      string  `json:"completed_at,omitempty"`
+	Error            string  `json:"error,omitempty"`
+}
+
+// IndexRepairResponse represents the response of the index repair endpoint
+type IndexRepairResponse struct {
+	Job       *IndexRepairJob `json:"job,omitempty"`
+	Tombstone *IndexTombstone `json:"tombstone,omitempty"` // set while the index is marked as corrupted
+	Success   bool            `json:"success"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// DocumentChange represents a change of a document of a collection (change feed of GET /collections/{name}/watch)
+type DocumentChange struct {
+	Cursor string `json:"cursor"` // cursor of the change, to watch the following changes
+	Type   string `json:"type"`   // "stored", "updated" or "deleted"
+	ID     string `json:"id"`
+	Time   string `json:"time"`
+}
+
+// WatchCollectionResponse represents the response of a long-poll of the changes of a collection
+type WatchCollectionResponse struct {
+	Collection string           `json:"collection"`
+	Changes    []DocumentChange `json:"changes"`
+	Cursor     string           `json:"cursor"`              // cursor of the next watch request
+	Truncated  bool             `json:"truncated,omitempty"` // changes may have been dropped since the cursor, reload the collection
+	Success    bool             `json:"success"`
+	Error      string           `json:"error,omitempty"`
+}
+
+// ServerEvent represents a structured event of the server (index created, embedding model changed, job completed, ...)
+type ServerEvent struct {
+	ID      uint64         `json:"id"`
+	Type    string         `json:"type"`
+	Time    string         `json:"time"`
+	Message string         `json:"message"`
+	Fields  map[string]any `json:"fields,omitempty"`
+}
+
+// EventsResponse represents the response of the events endpoint
+type EventsResponse struct {
+	Events []ServerEvent `json:"events"`
+	// LastID is the ID of the last event of the server, to be passed as since to get the next events
+	LastID  uint64 `json:"last_id"`
+	Success bool   `json:"success"`
+	Error   string `json:"error,omitempty"`
 }
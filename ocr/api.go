@@ -0,0 +1,82 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// maxAPIResponseSize bounds the size of a response of the OCR API
+const maxAPIResponseSize = 8 << 20
+
+// APIEngine reads the text of the images with a hosted OCR API. The image is the body of a POST request (with its
+// media type as Content-Type, and the languages in the "languages" query parameter), and the API answers with
+// a JSON object holding the text of the image: {"text": "..."}.
+type APIEngine struct {
+	url    string
+	apiKey string
+	client *http.Client
+}
+
+// NewAPIEngine creates an OCR API engine
+func NewAPIEngine(config Config) (*APIEngine, error) {
+	if config.APIURL == "" {
+		return nil, fmt.Errorf("OCR API URL is required")
+	}
+	apiURL, err := url.Parse(config.APIURL)
+	if err != nil || (apiURL.Scheme != "http" && apiURL.Scheme != "https") || apiURL.Host == "" {
+		return nil, fmt.Errorf("invalid OCR API URL %q (expected an http or https URL)", config.APIURL)
+	}
+	if config.Languages != "" {
+		query := apiURL.Query()
+		query.Set("languages", config.Languages)
+		apiURL.RawQuery = query.Encode()
+	}
+	return &APIEngine{
+		url:    apiURL.String(),
+		apiKey: config.APIKey,
+		client: &http.Client{Timeout: config.Timeout},
+	}, nil
+}
+
+// Recognize sends an image to the OCR API and returns its text
+func (e *APIEngine) Recognize(ctx context.Context, image []byte, mediaType string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(image))
+	if err != nil {
+		return "", fmt.Errorf("failed to create the OCR API request: %w", err)
+	}
+	req.Header.Set("Content-Type", mediaType)
+	req.Header.Set("Accept", "application/json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("OCR API request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("OCR API returned %s", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxAPIResponseSize+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read the OCR API response: %w", err)
+	}
+	if len(body) > maxAPIResponseSize {
+		return "", fmt.Errorf("OCR API response is too large (%d MB max)", maxAPIResponseSize>>20)
+	}
+
+	var result struct {
+		Text *string `json:"text"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil || result.Text == nil {
+		return "", fmt.Errorf("invalid OCR API response (expected a JSON object with a text field)")
+	}
+	return strings.TrimSpace(*result.Text), nil
+}
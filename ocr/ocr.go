@@ -0,0 +1,60 @@
+// Package ocr reads the text of images (e.g. the scanned pages and the screenshots of a document)
+// with the Tesseract command line or a hosted OCR API.
+package ocr
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+)
+
+// Engine reads the text of the images
+type Engine interface {
+	// Recognize returns the text of an image of the given media type (e.g. "image/png")
+	Recognize(ctx context.Context, image []byte, mediaType string) (string, error)
+}
+
+// Config holds the OCR settings
+type Config struct {
+	Backend       string        // "tesseract" or "api" ("" disables the OCR)
+	TesseractPath string        // tesseract binary (default: "tesseract", found in the PATH)
+	Languages     string        // languages of the text, e.g. "eng+fra" (default: the default of the backend)
+	APIURL        string        // URL of the OCR API
+	APIKey        string        // bearer token of the OCR API (optional)
+	Timeout       time.Duration // maximum time spent on an image (0: no limit)
+}
+
+// New creates the OCR engine of the configured backend (nil when the OCR is disabled)
+func New(config Config) (Engine, error) {
+	switch config.Backend {
+	case "":
+		return nil, nil
+	case "tesseract":
+		return NewTesseractEngine(config)
+	case "api":
+		return NewAPIEngine(config)
+	default:
+		return nil, fmt.Errorf("unknown OCR backend %q (use \"tesseract\" or \"api\")", config.Backend)
+	}
+}
+
+// mediaTypes are the media types of the image formats read by the OCR, by file extension
+var mediaTypes = map[string]string{
+	".png":  "image/png",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".bmp":  "image/bmp",
+	".gif":  "image/gif",
+	".webp": "image/webp",
+}
+
+// MediaType returns the media type of an image from the extension of its name, and false for the formats the OCR
+// does not read (e.g. the vector images, .emf, .wmf or .svg)
+func MediaType(name string) (string, bool) {
+	mediaType, ok := mediaTypes[strings.ToLower(path.Ext(name))]
+	return mediaType, ok
+}
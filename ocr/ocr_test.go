@@ -0,0 +1,125 @@
+package ocr
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew(t *testing.T) {
+	engine, err := New(Config{})
+	if engine != nil || err != nil {
+		t.Errorf("Expected no engine without backend, got %v (%v)", engine, err)
+	}
+
+	for _, config := range []Config{
+		{Backend: "cloud"},
+		{Backend: "api"},
+		{Backend: "api", APIURL: "ftp://ocr.example.com"},
+		{Backend: "tesseract", TesseractPath: filepath.Join(t.TempDir(), "missing-tesseract")},
+	} {
+		if _, err := New(config); err == nil {
+			t.Errorf("Expected an error for %+v", config)
+		}
+	}
+}
+
+func TestMediaType(t *testing.T) {
+	tests := map[string]string{
+		"word/media/image1.png":   "image/png",
+		"ppt/media/image2.JPEG":   "image/jpeg",
+		"word/media/scan.tiff":    "image/tiff",
+		"word/media/image3.emf":   "",
+		"ppt/media/diagram.svg":   "",
+		"word/media/no-extension": "",
+	}
+	for name, expected := range tests {
+		mediaType, ok := MediaType(name)
+		if mediaType != expected || ok != (expected != "") {
+			t.Errorf("MediaType(%q) = %q, %v, expected %q", name, mediaType, ok, expected)
+		}
+	}
+}
+
+func TestTesseractEngine(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The fake tesseract binary is a shell script")
+	}
+
+	// The fake tesseract prints its arguments and the image read on its standard input
+	binary := filepath.Join(t.TempDir(), "tesseract")
+	script := "#!/bin/sh\necho \"$@\"\ncat\n"
+	if err := os.WriteFile(binary, []byte(script), 0o755); err != nil {
+		t.Fatalf("Failed to write the fake tesseract: %v", err)
+	}
+
+	engine, err := New(Config{Backend: "tesseract", TesseractPath: binary, Languages: "eng+fra"})
+	if err != nil {
+		t.Fatalf("Failed to create the engine: %v", err)
+	}
+	text, err := engine.Recognize(context.Background(), []byte("scanned page\n"), "image/png")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if text != "stdin stdout -l eng+fra\nscanned page" {
+		t.Errorf("Expected the arguments and the image, got %q", text)
+	}
+
+	// A failure reports the standard error of tesseract, a slow run is stopped at the timeout
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\necho 'Error in pixReadStream' >&2\nexit 1\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write the fake tesseract: %v", err)
+	}
+	if _, err := engine.Recognize(context.Background(), []byte("image"), "image/png"); err == nil || !strings.Contains(err.Error(), "pixReadStream") {
+		t.Errorf("Expected the error of tesseract, got %v", err)
+	}
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\nexec sleep 5\n"), 0o755); err != nil {
+		t.Fatalf("Failed to write the fake tesseract: %v", err)
+	}
+	engine, _ = New(Config{Backend: "tesseract", TesseractPath: binary, Timeout: 100 * time.Millisecond})
+	start := time.Now()
+	if _, err := engine.Recognize(context.Background(), []byte("image"), "image/png"); err == nil || time.Since(start) > 3*time.Second {
+		t.Errorf("Expected the timeout to stop tesseract, got %v after %v", err, time.Since(start))
+	}
+}
+
+func TestAPIEngine(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		image, _ := io.ReadAll(r.Body)
+		switch {
+		case r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer secret":
+			w.WriteHeader(http.StatusUnauthorized)
+		case string(image) == "not json":
+			w.Write([]byte("<html>"))
+		default:
+			json.NewEncoder(w).Encode(map[string]string{
+				"text": " " + r.Header.Get("Content-Type") + " " + r.URL.Query().Get("languages") + " " + string(image) + "\n",
+			})
+		}
+	}))
+	defer server.Close()
+
+	engine, err := New(Config{Backend: "api", APIURL: server.URL + "/ocr", APIKey: "secret", Languages: "deu"})
+	if err != nil {
+		t.Fatalf("Failed to create the engine: %v", err)
+	}
+	text, err := engine.Recognize(context.Background(), []byte("scan"), "image/tiff")
+	if err != nil || text != "image/tiff deu scan" {
+		t.Errorf("Expected the text of the image, got %q (%v)", text, err)
+	}
+	if _, err := engine.Recognize(context.Background(), []byte("not json"), "image/png"); err == nil {
+		t.Error("Expected an error for an invalid response")
+	}
+
+	engine, _ = New(Config{Backend: "api", APIURL: server.URL + "/ocr"})
+	if _, err := engine.Recognize(context.Background(), []byte("scan"), "image/png"); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected the status of the API, got %v", err)
+	}
+}
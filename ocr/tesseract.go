@@ -0,0 +1,55 @@
+package ocr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// TesseractEngine reads the text of the images with the tesseract command line (Tesseract 4 or later)
+type TesseractEngine struct {
+	path      string
+	languages string
+	timeout   time.Duration
+}
+
+// NewTesseractEngine creates a Tesseract engine, checking that the binary can be found
+func NewTesseractEngine(config Config) (*TesseractEngine, error) {
+	binary := config.TesseractPath
+	if binary == "" {
+		binary = "tesseract"
+	}
+	resolved, err := exec.LookPath(binary)
+	if err != nil {
+		return nil, fmt.Errorf("tesseract binary not found: %w", err)
+	}
+	return &TesseractEngine{path: resolved, languages: config.Languages, timeout: config.Timeout}, nil
+}
+
+// Recognize runs tesseract on an image sent on its standard input, and returns the text it prints
+func (e *TesseractEngine) Recognize(ctx context.Context, image []byte, mediaType string) (string, error) {
+	if e.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, e.timeout)
+		defer cancel()
+	}
+
+	args := []string{"stdin", "stdout"}
+	if e.languages != "" {
+		args = append(args, "-l", e.languages)
+	}
+	cmd := exec.CommandContext(ctx, e.path, args...)
+	cmd.Stdin = bytes.NewReader(image)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return "", fmt.Errorf("tesseract: %w", ctx.Err())
+		}
+		return "", fmt.Errorf("tesseract failed: %v (%s)", err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,81 @@
+package splitter
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// LocateChunks returns each chunk with its position in the document it was split from, in characters (for
+// provenance). The chunks are searched in order, each one from the start of the previous found chunk (overlapping
+// chunks are found), so that a repeated text is located at its own occurrence. The chunks of the markdown hierarchy
+// splitter are located by their content, without their TITLE and HIERARCHY lines. A chunk whose text is not in the
+// document (e.g. a chunk of an HTML page converted to markdown) is not found: its Start and End are -1.
+func LocateChunks(document string, chunks []string) []RuneChunk {
+	located := make([]RuneChunk, len(chunks))
+	// byte and character offsets from which the next chunk is searched
+	cursor, cursorChars := 0, 0
+	for i, chunk := range chunks {
+		located[i] = RuneChunk{Start: -1, End: -1}
+
+		text := LocatedText(chunk)
+		if text == "" {
+			continue
+		}
+
+		index := strings.Index(document[cursor:], text)
+		if index < 0 {
+			continue
+		}
+		start := cursorChars + utf8.RuneCountInString(document[cursor:cursor+index])
+		located[i] = RuneChunk{Text: text, Start: start, End: start + utf8.RuneCountInString(text)}
+
+		// The next chunk is searched after the first character of this one
+		_, size := utf8.DecodeRuneInString(text)
+		cursor, cursorChars = cursor+index+size, start+1
+	}
+	return located
+}
+
+// LocatedText returns the text of a chunk searched in its document by LocateChunks: the chunk without its leading and
+// trailing spaces, and without the TITLE and HIERARCHY lines of a markdown hierarchy chunk
+func LocatedText(chunk string) string {
+	if _, _, ok := ParseHierarchyChunk(chunk); ok {
+		_, chunk, _ = strings.Cut(chunk, "\nCONTENT: ")
+	}
+	return strings.TrimSpace(chunk)
+}
+
+// JoinChunks concatenates consecutive chunks of a document. Two chunks located in the document (see LocateChunks)
+// are joined by their located text as in the document: the text they share (chunks split with overlap) is kept once,
+// they are joined without separator when they touch and with a space when a single character separates them.
+// The other chunks are separated by a blank line.
+func JoinChunks(chunks []RuneChunk) string {
+	var builder strings.Builder
+	previousEnd := -1 // end of the previous chunk, -1 when it is not located
+	for i, chunk := range chunks {
+		if chunk.Start < 0 {
+			if i > 0 {
+				builder.WriteString("\n\n")
+			}
+			builder.WriteString(chunk.Text)
+			previousEnd = -1
+			continue
+		}
+
+		text := LocatedText(chunk.Text)
+		switch {
+		case i == 0:
+		case previousEnd < 0 || chunk.Start > previousEnd+1:
+			builder.WriteString("\n\n")
+		case chunk.Start == previousEnd+1:
+			builder.WriteString(" ")
+		case chunk.Start < previousEnd:
+			// The text shared with the previous chunk is skipped
+			runes := []rune(text)
+			text = string(runes[min(previousEnd-chunk.Start, len(runes)):])
+		}
+		builder.WriteString(text)
+		previousEnd = max(previousEnd, chunk.End)
+	}
+	return builder.String()
+}
@@ -0,0 +1,86 @@
+package splitter
+
+import (
+	"testing"
+)
+
+func TestLocateChunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		document string
+		chunks   []string
+		expected [][2]int
+	}{
+		{name: "Consecutive chunks", document: "alpha beta gamma", chunks: []string{"alpha", "beta", "gamma"}, expected: [][2]int{{0, 5}, {6, 10}, {11, 16}}},
+		{name: "Overlapping chunks", document: "abcdefgh", chunks: []string{"abcd", "cdef", "efgh"}, expected: [][2]int{{0, 4}, {2, 6}, {4, 8}}},
+		{name: "Repeated text", document: "same\nsame\nsame", chunks: []string{"same", "same", "same"}, expected: [][2]int{{0, 4}, {5, 9}, {10, 14}}},
+		{name: "Multi-byte characters", document: "héllo wörld", chunks: []string{"héllo", "wörld"}, expected: [][2]int{{0, 5}, {6, 11}}},
+		{name: "Trimmed chunk", document: "one\n\ntwo", chunks: []string{"one\n\n", "two"}, expected: [][2]int{{0, 3}, {5, 8}}},
+		{name: "Missing chunk", document: "one two", chunks: []string{"one", "three", "two"}, expected: [][2]int{{0, 3}, {-1, -1}, {4, 7}}},
+		{name: "Hierarchy chunk", document: "# Title\nSome text", chunks: []string{"TITLE: # Title\nHIERARCHY: Title\nCONTENT: Some text"}, expected: [][2]int{{8, 17}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			located := LocateChunks(tt.document, tt.chunks)
+			if len(located) != len(tt.expected) {
+				t.Fatalf("Expected %d chunks, got %d", len(tt.expected), len(located))
+			}
+			for i, chunk := range located {
+				if chunk.Start != tt.expected[i][0] || chunk.End != tt.expected[i][1] {
+					t.Errorf("Chunk %d: expected [%d, %d[, got [%d, %d[", i, tt.expected[i][0], tt.expected[i][1], chunk.Start, chunk.End)
+				}
+				if chunk.Start >= 0 && string([]rune(tt.document)[chunk.Start:chunk.End]) != chunk.Text {
+					t.Errorf("Chunk %d: offsets do not match the text %q", i, chunk.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestJoinChunks(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunks   []RuneChunk
+		expected string
+	}{
+		{
+			name:     "Overlapping chunks",
+			chunks:   []RuneChunk{{Text: "abcd", Start: 0, End: 4}, {Text: "cdef", Start: 2, End: 6}, {Text: "efgh", Start: 4, End: 8}},
+			expected: "abcdefgh",
+		},
+		{
+			name:     "Chunks separated by a character",
+			chunks:   []RuneChunk{{Text: "alpha", Start: 0, End: 5}, {Text: "beta", Start: 6, End: 10}},
+			expected: "alpha beta",
+		},
+		{
+			name:     "Chunks separated by several characters",
+			chunks:   []RuneChunk{{Text: "one", Start: 0, End: 3}, {Text: "two", Start: 5, End: 8}},
+			expected: "one\n\ntwo",
+		},
+		{
+			name:     "Chunks not located",
+			chunks:   []RuneChunk{{Text: "one", Start: -1, End: -1}, {Text: "two", Start: 4, End: 7}, {Text: "three", Start: -1, End: -1}},
+			expected: "one\n\ntwo\n\nthree",
+		},
+		{
+			name:     "Hierarchy chunks",
+			chunks:   []RuneChunk{{Text: "TITLE: # A\nHIERARCHY: A\nCONTENT: First", Start: 4, End: 9}, {Text: "TITLE: # B\nHIERARCHY: B\nCONTENT: Second", Start: 14, End: 20}},
+			expected: "First\n\nSecond",
+		},
+		{
+			name:     "Chunk inside the previous one",
+			chunks:   []RuneChunk{{Text: "abcdef", Start: 0, End: 6}, {Text: "cd", Start: 2, End: 4}, {Text: "gh", Start: 6, End: 8}},
+			expected: "abcdefgh",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if joined := JoinChunks(tt.chunks); joined != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, joined)
+			}
+		})
+	}
+}
@@ -1,20 +1,47 @@
 package splitter
 
+// RuneChunk is a chunk of a text with its position in the text, in runes (characters)
+type RuneChunk struct {
+	Text  string
+	Start int // offset of the first character of the chunk
+	End   int // offset after the last character of the chunk
+}
+
 // ChunkText takes a text string and divides it into chunks of a specified size with a given overlap.
 // It returns a slice of strings, where each string represents a chunk of the original text.
 //
 // Parameters:
 //   - text: The input text to be chunked.
-//   - chunkSize: The size of each chunk.
-//   - overlap: The amount of overlap between consecutive chunks.
+//   - chunkSize: The size of each chunk, in characters (runes).
+//   - overlap: The amount of overlap between consecutive chunks, in characters (runes).
 //
 // Returns:
 //   - []string: A slice of strings representing the chunks of the original text.
 func ChunkText(text string, chunkSize, overlap int) []string {
-	chunks := []string{}
-	for start := 0; start < len(text); start += chunkSize - overlap {
-		end := min(start + chunkSize, len(text))
-		chunks = append(chunks, text[start:end])
+	runeChunks := ChunkTextRunes(text, chunkSize, overlap)
+	chunks := make([]string, len(runeChunks))
+	for i, chunk := range runeChunks {
+		chunks[i] = chunk.Text
+	}
+	return chunks
+}
+
+// ChunkTextRunes divides a text into chunks of chunkSize characters, consecutive chunks sharing overlap characters,
+// and returns each chunk with its offsets in the text (for provenance). A multi-byte character is never cut.
+// A chunkSize of 0 or less gives no chunk; an overlap not less than chunkSize is reduced to chunkSize-1,
+// so that each chunk starts at least one character after the previous one.
+func ChunkTextRunes(text string, chunkSize, overlap int) []RuneChunk {
+	chunks := []RuneChunk{}
+	if chunkSize <= 0 {
+		return chunks
+	}
+	overlap = min(max(overlap, 0), chunkSize-1)
+	step := chunkSize - overlap
+
+	runes := []rune(text)
+	for start := 0; start < len(runes); start += step {
+		end := min(start+chunkSize, len(runes))
+		chunks = append(chunks, RuneChunk{Text: string(runes[start:end]), Start: start, End: end})
 	}
 	return chunks
 }
@@ -0,0 +1,93 @@
+package splitter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"testing/quick"
+	"unicode/utf8"
+)
+
+func TestChunkText(t *testing.T) {
+	tests := []struct {
+		name      string
+		text      string
+		chunkSize int
+		overlap   int
+		expected  []string
+	}{
+		{name: "Overlap", text: "abcdefgh", chunkSize: 4, overlap: 2, expected: []string{"abcd", "cdef", "efgh", "gh"}},
+		{name: "No overlap", text: "abcdefgh", chunkSize: 3, expected: []string{"abc", "def", "gh"}},
+		{name: "Multi-byte characters", text: "héllo wörld", chunkSize: 4, expected: []string{"héll", "o wö", "rld"}},
+		{name: "Emoji", text: "🐿️🐦🐸", chunkSize: 2, overlap: 1, expected: []string{"🐿️", "️🐦", "🐦🐸", "🐸"}},
+		{name: "Empty text", text: "", chunkSize: 4, expected: []string{}},
+		{name: "Zero chunk size", text: "abcdefgh", chunkSize: 0, expected: []string{}},
+		{name: "Overlap equal to chunk size", text: "abcd", chunkSize: 2, overlap: 2, expected: []string{"ab", "bc", "cd", "d"}},
+		{name: "Negative overlap", text: "abcd", chunkSize: 2, overlap: -1, expected: []string{"ab", "cd"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if chunks := ChunkText(tt.text, tt.chunkSize, tt.overlap); !reflect.DeepEqual(chunks, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, chunks)
+			}
+		})
+	}
+}
+
+func TestChunkTextRunes_Properties(t *testing.T) {
+	property := func(text string, size, overlapSize uint8) bool {
+		chunkSize := int(size%64) + 1
+		overlap := int(overlapSize % 80) // can be above chunkSize
+		runes := []rune(text)
+		step := chunkSize - min(overlap, chunkSize-1)
+
+		chunks := ChunkTextRunes(text, chunkSize, overlap)
+		if len(chunks) != (len(runes)+step-1)/step {
+			return false
+		}
+		for i, chunk := range chunks {
+			if !utf8.ValidString(chunk.Text) || chunk.Start != i*step || chunk.End-chunk.Start > chunkSize {
+				return false
+			}
+			if chunk.Text != string(runes[chunk.Start:chunk.End]) {
+				return false
+			}
+		}
+		return len(chunks) == 0 || chunks[len(chunks)-1].End == len(runes)
+	}
+	if err := quick.Check(property, &quick.Config{MaxCount: 500}); err != nil {
+		t.Error(err)
+	}
+}
+
+func FuzzChunkText(f *testing.F) {
+	f.Add("abcdefgh", 4, 2)
+	f.Add("héllo wörld", 4, 0)
+	f.Add("🐿️🐦🐸", 2, 1)
+	f.Add("invalid \xff\xfe utf-8", 3, 1)
+	f.Fuzz(func(t *testing.T, text string, chunkSize, overlap int) {
+		chunkSize, overlap = chunkSize%128, overlap%128
+		chunks := ChunkText(text, chunkSize, overlap)
+		if chunkSize <= 0 {
+			if len(chunks) != 0 {
+				t.Fatalf("Expected no chunk with a chunk size of %d, got %d", chunkSize, len(chunks))
+			}
+			return
+		}
+		if text != "" && len(chunks) == 0 {
+			t.Fatalf("Expected chunks for a non empty text")
+		}
+		for i, chunk := range chunks {
+			if !utf8.ValidString(chunk) {
+				t.Errorf("Chunk %d is not valid UTF-8: %q", i, chunk)
+			}
+			if utf8.RuneCountInString(chunk) > chunkSize {
+				t.Errorf("Chunk %d has more than %d characters: %q", i, chunkSize, chunk)
+			}
+		}
+		if utf8.ValidString(text) && len(chunks) > 0 && !strings.HasPrefix(text, chunks[0]) {
+			t.Errorf("The first chunk %q does not start the text", chunks[0])
+		}
+	})
+}
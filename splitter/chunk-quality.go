@@ -0,0 +1,154 @@
+package splitter
+
+import (
+	"math"
+	"strings"
+	"unicode"
+)
+
+// idealChunkLength is the number of characters above which a chunk gets the full length score
+const idealChunkLength = 200
+
+// ChunkQuality holds the signals used to score a chunk and the resulting global score.
+// Every value is between 0 (poor) and 1 (good).
+type ChunkQuality struct {
+	Length      float64 `json:"length"`
+	AlphaRatio  float64 `json:"alpha_ratio"`
+	Language    float64 `json:"language"`
+	Duplication float64 `json:"duplication"`
+	Score       float64 `json:"score"`
+}
+
+// ScoreChunk computes the quality of a single chunk.
+//
+// The score combines four signals:
+//   - Length: short chunks carry little meaning
+//   - AlphaRatio: proportion of letters among non-space characters (tables, numbers, markup score low)
+//   - Language: proportion of tokens that look like natural language words
+//   - Duplication: proportion of unique words (repetitive content scores low)
+func ScoreChunk(chunk string) ChunkQuality {
+	text := strings.TrimSpace(chunk)
+	if text == "" {
+		return ChunkQuality{}
+	}
+
+	quality := ChunkQuality{
+		Length:      lengthScore(text),
+		AlphaRatio:  alphaRatio(text),
+		Language:    languageConfidence(text),
+		Duplication: uniqueWordsRatio(text),
+	}
+	quality.Score = weightedScore(quality)
+
+	return quality
+}
+
+// ScoreChunks computes the quality of all the chunks of a same document.
+// A chunk that is an exact copy (ignoring case and spaces) of a previous chunk gets a duplication score of 0.
+func ScoreChunks(chunks []string) []ChunkQuality {
+	qualities := make([]ChunkQuality, len(chunks))
+	seen := make(map[string]bool, len(chunks))
+
+	for i, chunk := range chunks {
+		qualities[i] = ScoreChunk(chunk)
+
+		normalized := strings.Join(strings.Fields(strings.ToLower(chunk)), " ")
+		if normalized == "" {
+			continue
+		}
+		if seen[normalized] {
+			qualities[i].Duplication = 0
+			qualities[i].Score = weightedScore(qualities[i])
+		}
+		seen[normalized] = true
+	}
+
+	return qualities
+}
+
+// weightedScore combines the signals: duplication acts as a multiplier
+// so that a duplicated chunk never scores more than half of its original
+func weightedScore(q ChunkQuality) float64 {
+	score := (0.25*q.Length + 0.375*q.AlphaRatio + 0.375*q.Language) * (0.5 + 0.5*q.Duplication)
+	return math.Round(score*1000) / 1000
+}
+
+func lengthScore(text string) float64 {
+	length := len([]rune(text))
+	return math.Min(1, float64(length)/idealChunkLength)
+}
+
+func alphaRatio(text string) float64 {
+	letters, total := 0, 0
+	for _, r := range text {
+		if unicode.IsSpace(r) {
+			continue
+		}
+		total++
+		if unicode.IsLetter(r) {
+			letters++
+		}
+	}
+	if total == 0 {
+		return 0
+	}
+	return float64(letters) / float64(total)
+}
+
+// languageConfidence estimates how much the text looks like natural language:
+// a token counts as a word when it is made of letters (once punctuation is trimmed),
+// has a plausible length and, for latin words, contains at least one vowel
+func languageConfidence(text string) float64 {
+	tokens := strings.Fields(text)
+	if len(tokens) == 0 {
+		return 0
+	}
+
+	words := 0
+	for _, token := range tokens {
+		if isWordLike(token) {
+			words++
+		}
+	}
+	return float64(words) / float64(len(tokens))
+}
+
+func isWordLike(token string) bool {
+	word := strings.TrimFunc(token, func(r rune) bool {
+		return unicode.IsPunct(r) || unicode.IsSymbol(r)
+	})
+	runes := []rune(word)
+	if len(runes) == 0 || len(runes) > 25 {
+		return false
+	}
+
+	latin, hasVowel := false, false
+	for _, r := range runes {
+		if !unicode.IsLetter(r) && r != '\'' && r != '-' {
+			return false
+		}
+		if unicode.Is(unicode.Latin, r) {
+			latin = true
+			if strings.ContainsRune("aeiouyàâäáéèêëíîïóôöòúùûüÿœæ", unicode.ToLower(r)) {
+				hasVowel = true
+			}
+		}
+	}
+
+	return !latin || hasVowel
+}
+
+func uniqueWordsRatio(text string) float64 {
+	words := strings.Fields(strings.ToLower(text))
+	// Too few words to measure repetition
+	if len(words) < 10 {
+		return 1
+	}
+
+	unique := make(map[string]struct{}, len(words))
+	for _, word := range words {
+		unique[word] = struct{}{}
+	}
+	// A natural text rarely has more than 70% of unique words
+	return math.Min(1, float64(len(unique))/float64(len(words))/0.7)
+}
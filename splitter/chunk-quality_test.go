@@ -0,0 +1,75 @@
+package splitter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestScoreChunk(t *testing.T) {
+	prose := "The Chronicles of Aethelgard is a tabletop role-playing game set in a world of ancient magic. " +
+		"Players create heroes, explore forgotten ruins and face the monsters that roam the northern kingdoms."
+
+	tests := []struct {
+		name     string
+		chunk    string
+		minScore float64
+		maxScore float64
+	}{
+		{
+			name:     "Natural language prose",
+			chunk:    prose,
+			minScore: 0.8,
+			maxScore: 1,
+		},
+		{
+			name:     "Empty chunk",
+			chunk:    "   ",
+			minScore: 0,
+			maxScore: 0,
+		},
+		{
+			name:     "Numbers and symbols only",
+			chunk:    "| 12 | 34.5 | 67 |\n|----|------|----|\n| 89 | 10.1 | 11 |",
+			minScore: 0,
+			maxScore: 0.3,
+		},
+		{
+			name:     "Repetitive content",
+			chunk:    strings.Repeat("buy now ", 40),
+			minScore: 0,
+			maxScore: 0.8,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quality := ScoreChunk(tt.chunk)
+			if quality.Score < tt.minScore || quality.Score > tt.maxScore {
+				t.Errorf("Expected score between %.2f and %.2f, got %.3f (%+v)", tt.minScore, tt.maxScore, quality.Score, quality)
+			}
+		})
+	}
+}
+
+func TestScoreChunks_Duplicates(t *testing.T) {
+	chunks := []string{
+		"Squirrels run in the forest",
+		"Birds fly in the sky",
+		"  squirrels RUN in the   forest ",
+	}
+
+	qualities := ScoreChunks(chunks)
+
+	if len(qualities) != len(chunks) {
+		t.Fatalf("Expected %d qualities, got %d", len(chunks), len(qualities))
+	}
+	if qualities[0].Duplication == 0 {
+		t.Error("First occurrence should not be flagged as duplicate")
+	}
+	if qualities[2].Duplication != 0 {
+		t.Errorf("Expected duplicate chunk to have a duplication score of 0, got %f", qualities[2].Duplication)
+	}
+	if qualities[2].Score >= qualities[0].Score {
+		t.Errorf("Expected duplicate chunk to score lower than the original (%f >= %f)", qualities[2].Score, qualities[0].Score)
+	}
+}
@@ -0,0 +1,123 @@
+package splitter
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// charsPerToken is a conservative estimation of the number of characters per token.
+// English text is closer to 4 characters per token, but code, numbers and
+// non-English languages produce more tokens per character.
+const charsPerToken = 3
+
+// wordRegex matches a word followed by its trailing whitespace
+var wordRegex = regexp.MustCompile(`\S+\s*`)
+
+// EstimateTokens estimates the number of tokens of a text for an embedding model.
+// The estimation is deliberately pessimistic so that chunks fitting the estimation
+// fit the real context window of the model.
+func EstimateTokens(text string) int {
+	byChars := (utf8.RuneCountInString(text) + charsPerToken - 1) / charsPerToken
+	byWords := (len(strings.Fields(text))*4 + 2) / 3
+	return max(byChars, byWords)
+}
+
+// ChunkTextByTokens splits a text into chunks of at most maxTokens tokens (see CountTokens).
+// Chunks are cut on whitespace; a single word larger than maxTokens is cut on rune boundaries.
+func ChunkTextByTokens(text string, maxTokens int) []string {
+	if maxTokens <= 0 || text == "" {
+		return []string{}
+	}
+	if CountTokens(text) <= maxTokens {
+		return []string{text}
+	}
+
+	chunks := []string{}
+	var current strings.Builder
+
+	flush := func() {
+		if strings.TrimSpace(current.String()) != "" {
+			chunks = append(chunks, current.String())
+		}
+		current.Reset()
+	}
+
+	for _, word := range wordRegex.FindAllString(text, -1) {
+		if CountTokens(current.String()+word) <= maxTokens {
+			current.WriteString(word)
+			continue
+		}
+		flush()
+
+		// The word alone is larger than the limit: cut it
+		for CountTokens(word) > maxTokens {
+			runes := []rune(word)
+			cut := min(maxTokens*charsPerToken, len(runes))
+			// A tokenizer can count more tokens than the estimation: shorten the cut until it fits
+			for tokens := CountTokens(string(runes[:cut])); tokens > maxTokens && cut > 1; tokens = CountTokens(string(runes[:cut])) {
+				cut = max(1, min(cut-1, cut*maxTokens/tokens))
+			}
+			chunks = append(chunks, string(runes[:cut]))
+			word = string(runes[cut:])
+		}
+		current.WriteString(word)
+	}
+	flush()
+
+	return chunks
+}
+
+// ChunkTextByTokensWithOverlap splits a text into chunks of at most maxTokens tokens (see CountTokens), each chunk
+// starting with the last words of the previous chunk, up to overlapTokens tokens.
+// overlapTokens must be less than maxTokens.
+func ChunkTextByTokensWithOverlap(text string, maxTokens, overlapTokens int) []string {
+	if overlapTokens <= 0 || overlapTokens >= maxTokens {
+		return ChunkTextByTokens(text, maxTokens)
+	}
+
+	// The chunks leave room for the overlap, the overhead of the special tokens is only counted once
+	chunks := ChunkTextByTokens(text, maxTokens-overlapTokens+inputOverhead())
+	for i := len(chunks) - 1; i > 0; i-- {
+		words := wordRegex.FindAllString(chunks[i-1], -1)
+		start := len(words)
+		for start > 0 && CountTokens(strings.Join(words[start-1:], "")) <= overlapTokens {
+			start--
+		}
+		// The merges of the tokenizer can differ at the junction, the overlap is shortened until the chunk fits
+		for ; start < len(words); start++ {
+			if overlapped := strings.Join(words[start:], "") + chunks[i]; CountTokens(overlapped) <= maxTokens {
+				chunks[i] = overlapped
+				break
+			}
+		}
+	}
+	return chunks
+}
+
+// SubdivideWithHeader splits a chunk exceeding maxTokens into smaller chunks.
+// When a header is provided, it is prepended to every sub-chunk except the first one
+// (which already contains it), and the sub-chunks are sized so that they still fit maxTokens.
+// A chunk that fits maxTokens is returned as is.
+func SubdivideWithHeader(chunk, header string, maxTokens int) []string {
+	if CountTokens(chunk) <= maxTokens {
+		return []string{chunk}
+	}
+
+	budget := maxTokens
+	if header != "" {
+		budget -= CountTokens(header+"\n\n") - inputOverhead()
+	}
+	if budget <= 0 {
+		// The header alone is too large to be repeated
+		return ChunkTextByTokens(chunk, maxTokens)
+	}
+
+	subChunks := ChunkTextByTokens(chunk, budget)
+	if header != "" {
+		for i := 1; i < len(subChunks); i++ {
+			subChunks[i] = header + "\n\n" + subChunks[i]
+		}
+	}
+	return subChunks
+}
@@ -0,0 +1,174 @@
+package splitter
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	if tokens := EstimateTokens(""); tokens != 0 {
+		t.Errorf("Expected 0 tokens for empty text, got %d", tokens)
+	}
+
+	text := "Squirrels run in the forest"
+	if tokens := EstimateTokens(text); tokens < len(strings.Fields(text)) {
+		t.Errorf("Expected at least one token per word, got %d", tokens)
+	}
+}
+
+func TestChunkTextByTokens(t *testing.T) {
+	text := strings.Repeat("Birds fly in the sky. ", 100)
+	maxTokens := 50
+
+	chunks := ChunkTextByTokens(text, maxTokens)
+
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the text to be subdivided, got %d chunk(s)", len(chunks))
+	}
+	if strings.Join(chunks, "") != text {
+		t.Error("Expected the chunks to reconstruct the original text")
+	}
+	for i, chunk := range chunks {
+		if tokens := EstimateTokens(chunk); tokens > maxTokens {
+			t.Errorf("Chunk %d has %d tokens, above the limit of %d", i, tokens, maxTokens)
+		}
+		if strings.HasPrefix(chunk, "ird") || strings.HasPrefix(chunk, "ly ") {
+			t.Errorf("Chunk %d was cut in the middle of a word: %q", i, chunk)
+		}
+	}
+}
+
+func TestChunkTextByTokens_LongWord(t *testing.T) {
+	chunks := ChunkTextByTokens(strings.Repeat("x", 100), 10)
+
+	for i, chunk := range chunks {
+		if tokens := EstimateTokens(chunk); tokens > 10 {
+			t.Errorf("Chunk %d has %d tokens, above the limit of 10", i, tokens)
+		}
+	}
+}
+
+func TestSubdivideWithHeader(t *testing.T) {
+	header := "## Deep Dive into the Monsters of Aethelgard"
+	section := header + "\n\n" + strings.Repeat("Goblins live in the caves of the north. ", 50)
+
+	chunks := SubdivideWithHeader(section, header, 100)
+
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the section to be subdivided, got %d chunk(s)", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if !strings.HasPrefix(chunk, header) {
+			t.Errorf("Chunk %d should start with the section header", i)
+		}
+		if tokens := EstimateTokens(chunk); tokens > 100 {
+			t.Errorf("Chunk %d has %d tokens, above the limit of 100", i, tokens)
+		}
+	}
+
+	small := SubdivideWithHeader("## Title\n\nShort content", "## Title", 100)
+	if len(small) != 1 {
+		t.Errorf("Expected a small section to be kept as is, got %d chunks", len(small))
+	}
+}
+
+// wordTokenizer counts a token per word, and 2 special tokens per input
+type wordTokenizer struct{}
+
+func (wordTokenizer) CountTokens(text string) int {
+	return len(strings.Fields(text)) + 2
+}
+
+func TestChunkTextByTokensWithOverlap(t *testing.T) {
+	SetTokenizer(wordTokenizer{})
+	defer SetTokenizer(nil)
+
+	words := []string{}
+	for i := 0; i < 100; i++ {
+		words = append(words, fmt.Sprintf("w%d", i))
+	}
+	text := strings.Join(words, " ")
+
+	chunks := ChunkTextByTokensWithOverlap(text, 22, 5)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the text to be subdivided, got %d chunk(s)", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if tokens := CountTokens(chunk); tokens > 22 {
+			t.Errorf("Chunk %d has %d tokens, above the limit of 22", i, tokens)
+		}
+		if i == 0 {
+			continue
+		}
+		// Each chunk starts with the last words of the previous chunk
+		previous := strings.Fields(chunks[i-1])
+		overlap := strings.Join(previous[len(previous)-3:], " ")
+		if !strings.HasPrefix(chunk, overlap+" ") {
+			t.Errorf("Expected chunk %d to start with %q, got %q", i, overlap, chunk)
+		}
+	}
+	if last := strings.Fields(chunks[len(chunks)-1]); last[len(last)-1] != "w99" {
+		t.Errorf("Expected the last chunk to end the text, got %v", last)
+	}
+
+	// Without overlap, the chunks reconstruct the text
+	if chunks := ChunkTextByTokensWithOverlap(text, 22, 0); strings.Join(chunks, "") != text {
+		t.Error("Expected the chunks to reconstruct the original text")
+	}
+}
+
+func TestBPETokenizer(t *testing.T) {
+	ranks := map[string]int{}
+	for b := 0; b < 256; b++ {
+		ranks[string([]byte{byte(b)})] = b
+	}
+	ranks["ab"] = 256
+	ranks["cd"] = 257
+	ranks["abcd"] = 258
+	tokenizer, err := NewBPETokenizer(ranks)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := map[string]int{
+		"":      0,
+		"abcd":  1, // a token
+		"abcde": 2, // ab + cd, then abcd, and e
+		"ab cd": 3, // ab, and " " + cd (the pieces are encoded separately)
+		"dcba":  4,
+	}
+	for text, expected := range tests {
+		if count := tokenizer.CountTokens(text); count != expected {
+			t.Errorf("CountTokens(%q) = %d, expected %d", text, count, expected)
+		}
+	}
+
+	if _, err := NewBPETokenizer(map[string]int{"ab": 0}); err == nil {
+		t.Error("Expected an error for a tokenizer without the byte tokens")
+	}
+}
+
+func TestSplitTokens(t *testing.T) {
+	document := strings.Repeat("Birds fly in the sky. ", 100)
+	chunks, err := Split("tokens", document, Options{"max_tokens": float64(40), "overlap_tokens": float64(8)}, 100)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, chunk := range chunks {
+		if tokens := CountTokens(chunk); tokens > 40 {
+			t.Errorf("Chunk %d has %d tokens, above the limit of 40", i, tokens)
+		}
+	}
+
+	for _, options := range []Options{
+		{"max_tokens": float64(200)},
+		{"max_tokens": float64(0)},
+		{"max_tokens": float64(40), "overlap_tokens": float64(40)},
+		{"overlap_tokens": float64(-1)},
+	} {
+		if _, err := Split("tokens", document, options, 100); err == nil {
+			t.Errorf("Expected an error for options %v", options)
+		}
+	}
+}
@@ -0,0 +1,397 @@
+package splitter
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// htmlSkippedElements are the elements whose content is never part of the text of a page: scripts and styles,
+// navigation and page chrome, forms and embedded objects
+var htmlSkippedElements = map[atom.Atom]bool{
+	atom.Head: true, atom.Script: true, atom.Style: true, atom.Noscript: true, atom.Template: true,
+	atom.Nav: true, atom.Footer: true, atom.Aside: true, atom.Form: true, atom.Button: true,
+	atom.Select: true, atom.Textarea: true, atom.Input: true, atom.Iframe: true, atom.Object: true,
+	atom.Embed: true, atom.Svg: true, atom.Canvas: true, atom.Audio: true, atom.Video: true, atom.Dialog: true,
+}
+
+// htmlBoilerplateRoles are the ARIA roles of the page chrome
+var htmlBoilerplateRoles = map[string]bool{
+	"navigation": true, "banner": true, "contentinfo": true, "complementary": true, "search": true, "dialog": true, "alertdialog": true,
+}
+
+// htmlBoilerplateNames are the words of the classes and IDs of the page chrome (cookie banners, menus, sharing buttons, ads)
+var htmlBoilerplateNames = map[string]bool{
+	"nav": true, "navbar": true, "navigation": true, "menu": true, "breadcrumb": true, "breadcrumbs": true,
+	"sidebar": true, "cookie": true, "cookies": true, "consent": true, "banner": true, "advert": true,
+	"advertisement": true, "ads": true, "share": true, "social": true, "newsletter": true, "popup": true,
+	"modal": true, "skip": true,
+}
+
+// htmlLineBreaks replaces the line breaks of the source of a page, which are spaces in the rendered text (only <br> breaks a line)
+var htmlLineBreaks = strings.NewReplacer("\r\n", " ", "\r", " ", "\n", " ")
+
+// HTMLToMarkdown extracts the content of an HTML page as markdown, so that it can be split with
+// ChunkWithMarkdownHierarchy: the headings, paragraphs, lists, tables, quotes and code blocks of the main content
+// (<main>, a single <article>, or <body>) are kept, and the boilerplate (scripts, styles, navigation, header and
+// footer, forms, cookie banners...) is dropped. The page title is the first header when the content has no <h1>.
+func HTMLToMarkdown(document string) (string, error) {
+	root, err := html.Parse(strings.NewReader(document))
+	if err != nil {
+		return "", fmt.Errorf("invalid HTML document: %w", err)
+	}
+
+	converter := &htmlConverter{}
+	converter.blocks(htmlContentRoot(root))
+	converter.flush()
+	markdown := strings.Join(converter.output, "\n\n")
+
+	if title := singleLine(htmlText(findElement(root, atom.Title))); title != "" && findElement(htmlContentRoot(root), atom.H1) == nil {
+		markdown = strings.TrimSpace("# " + title + "\n\n" + markdown)
+	}
+	return markdown, nil
+}
+
+// htmlContentRoot returns the element holding the main content of a page
+func htmlContentRoot(root *html.Node) *html.Node {
+	if main := findElement(root, atom.Main); main != nil {
+		return main
+	}
+	var articles []*html.Node
+	walkElements(root, func(n *html.Node) bool {
+		if n.DataAtom == atom.Article {
+			articles = append(articles, n)
+			return false
+		}
+		return true
+	})
+	if len(articles) == 1 {
+		return articles[0]
+	}
+	if body := findElement(root, atom.Body); body != nil {
+		return body
+	}
+	return root
+}
+
+// findElement returns the first element of a tree with the given tag (nil when there is none)
+func findElement(root *html.Node, tag atom.Atom) *html.Node {
+	var found *html.Node
+	walkElements(root, func(n *html.Node) bool {
+		if found == nil && n.DataAtom == tag {
+			found = n
+		}
+		return found == nil
+	})
+	return found
+}
+
+// walkElements calls visit for the elements of a tree in document order; visit returns false to skip the children
+func walkElements(n *html.Node, visit func(*html.Node) bool) {
+	if n == nil {
+		return
+	}
+	if n.Type == html.ElementNode && !visit(n) {
+		return
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walkElements(child, visit)
+	}
+}
+
+// isHTMLBoilerplate reports whether an element is page chrome or hidden content
+func isHTMLBoilerplate(n *html.Node) bool {
+	if htmlSkippedElements[n.DataAtom] {
+		return true
+	}
+	// The header of an article holds its title, the header of the page its banner
+	if n.DataAtom == atom.Header && !hasAncestor(n, atom.Article, atom.Main) {
+		return true
+	}
+	for _, attr := range n.Attr {
+		switch attr.Key {
+		case "hidden":
+			return true
+		case "aria-hidden":
+			if attr.Val == "true" {
+				return true
+			}
+		case "role":
+			if htmlBoilerplateRoles[strings.ToLower(attr.Val)] {
+				return true
+			}
+		case "class", "id":
+			for _, word := range strings.FieldsFunc(strings.ToLower(attr.Val), func(r rune) bool {
+				return r == ' ' || r == '-' || r == '_'
+			}) {
+				if htmlBoilerplateNames[word] {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// hasAncestor reports whether an element is inside an element with one of the tags
+func hasAncestor(n *html.Node, tags ...atom.Atom) bool {
+	for parent := n.Parent; parent != nil; parent = parent.Parent {
+		for _, tag := range tags {
+			if parent.DataAtom == tag {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isHTMLBlock reports whether an element starts a new block of text
+func isHTMLBlock(n *html.Node) bool {
+	switch n.DataAtom {
+	case atom.Address, atom.Article, atom.Blockquote, atom.Body, atom.Dd, atom.Details, atom.Div, atom.Dl, atom.Dt,
+		atom.Fieldset, atom.Figcaption, atom.Figure, atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6, atom.Header,
+		atom.Hr, atom.Html, atom.Li, atom.Main, atom.Ol, atom.P, atom.Pre, atom.Section, atom.Summary, atom.Table,
+		atom.Ul:
+		return true
+	}
+	return false
+}
+
+// htmlConverter writes the blocks of an HTML tree as markdown blocks
+type htmlConverter struct {
+	output []string
+	inline strings.Builder // text of the current paragraph (inline content between blocks)
+}
+
+// flush ends the current paragraph
+func (c *htmlConverter) flush() {
+	if text := collapseSpaces(c.inline.String()); text != "" {
+		c.output = append(c.output, text)
+	}
+	c.inline.Reset()
+}
+
+// block adds a markdown block
+func (c *htmlConverter) block(text string) {
+	c.flush()
+	if text = strings.TrimRight(text, " \n"); strings.TrimSpace(text) != "" {
+		c.output = append(c.output, text)
+	}
+}
+
+// blocks converts the children of an element
+func (c *htmlConverter) blocks(n *html.Node) {
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		c.node(child)
+	}
+}
+
+// node converts a node: a block element becomes a markdown block, inline content is added to the current paragraph
+func (c *htmlConverter) node(n *html.Node) {
+	switch n.Type {
+	case html.TextNode:
+		c.inline.WriteString(htmlLineBreaks.Replace(n.Data))
+		return
+	case html.ElementNode:
+	default:
+		c.blocks(n)
+		return
+	}
+	if isHTMLBoilerplate(n) {
+		return
+	}
+
+	switch n.DataAtom {
+	case atom.H1, atom.H2, atom.H3, atom.H4, atom.H5, atom.H6:
+		level, _ := strconv.Atoi(n.Data[1:])
+		if text := singleLine(htmlInline(n)); text != "" {
+			c.block(strings.Repeat("#", level) + " " + text)
+		}
+	case atom.P, atom.Dt, atom.Dd, atom.Figcaption, atom.Summary:
+		c.block(collapseSpaces(htmlInline(n)))
+	case atom.Pre:
+		code := strings.Trim(htmlText(n), "\n")
+		if strings.TrimSpace(code) != "" {
+			c.block("```\n" + code + "\n```")
+		}
+	case atom.Ul, atom.Ol:
+		c.block(strings.Join(htmlList(n, ""), "\n"))
+	case atom.Table:
+		c.block(htmlTable(n))
+	case atom.Blockquote:
+		quote := &htmlConverter{}
+		quote.blocks(n)
+		quote.flush()
+		lines := strings.Split(strings.Join(quote.output, "\n\n"), "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight("> "+line, " ")
+		}
+		c.block(strings.Join(lines, "\n"))
+	case atom.Br:
+		c.inline.WriteString("\n")
+	case atom.Hr, atom.Img:
+		c.flush()
+	default:
+		if isHTMLBlock(n) {
+			c.flush()
+			c.blocks(n)
+			c.flush()
+			return
+		}
+		c.inline.WriteString(htmlInline(n))
+	}
+}
+
+// htmlInline returns the inline content of an element as markdown text (inline code between backticks)
+func htmlInline(n *html.Node) string {
+	var text strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		switch {
+		case n.Type == html.TextNode:
+			text.WriteString(htmlLineBreaks.Replace(n.Data))
+		case n.Type != html.ElementNode:
+		case isHTMLBoilerplate(n):
+		case n.DataAtom == atom.Br:
+			text.WriteString("\n")
+		case n.DataAtom == atom.Code:
+			if code := strings.TrimSpace(htmlText(n)); code != "" {
+				text.WriteString("`" + code + "`")
+			}
+		default:
+			if isHTMLBlock(n) {
+				text.WriteString(" ")
+			}
+			for child := n.FirstChild; child != nil; child = child.NextSibling {
+				walk(child)
+			}
+			if isHTMLBlock(n) {
+				text.WriteString(" ")
+			}
+		}
+	}
+	for child := n.FirstChild; child != nil; child = child.NextSibling {
+		walk(child)
+	}
+	return text.String()
+}
+
+// htmlText returns the raw text of an element (the text of <pre> blocks, of the title)
+func htmlText(n *html.Node) string {
+	if n == nil {
+		return ""
+	}
+	var text strings.Builder
+	var walk func(*html.Node)
+	walk = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			text.WriteString(n.Data)
+		}
+		if n.Type == html.ElementNode && n.DataAtom == atom.Br {
+			text.WriteString("\n")
+		}
+		for child := n.FirstChild; child != nil; child = child.NextSibling {
+			walk(child)
+		}
+	}
+	walk(n)
+	return text.String()
+}
+
+// htmlList returns the lines of a markdown list (nested lists are indented)
+func htmlList(n *html.Node, indent string) []string {
+	lines := []string{}
+	number := 1
+	for item := n.FirstChild; item != nil; item = item.NextSibling {
+		if item.Type != html.ElementNode || item.DataAtom != atom.Li || isHTMLBoilerplate(item) {
+			continue
+		}
+		marker := "- "
+		if n.DataAtom == atom.Ol {
+			marker = strconv.Itoa(number) + ". "
+			number++
+		}
+
+		// The text of the item, then its nested lists
+		var text strings.Builder
+		var nested []string
+		for child := item.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == html.ElementNode && (child.DataAtom == atom.Ul || child.DataAtom == atom.Ol) {
+				nested = append(nested, htmlList(child, indent+strings.Repeat(" ", len(marker)))...)
+				continue
+			}
+			if child.Type == html.TextNode {
+				text.WriteString(htmlLineBreaks.Replace(child.Data))
+			} else if child.Type == html.ElementNode && !isHTMLBoilerplate(child) {
+				text.WriteString(" " + htmlInline(child) + " ")
+			}
+		}
+		if itemText := singleLine(text.String()); itemText != "" || len(nested) > 0 {
+			lines = append(lines, strings.TrimRight(indent+marker+itemText, " "))
+		}
+		lines = append(lines, nested...)
+	}
+	return lines
+}
+
+// htmlTable returns a table as a markdown table, the first row being the header
+func htmlTable(n *html.Node) string {
+	var rows [][]string
+	walkElements(n, func(e *html.Node) bool {
+		if e != n && e.DataAtom == atom.Table {
+			return false // nested table, read as the text of its cell
+		}
+		if e.DataAtom != atom.Tr {
+			return true
+		}
+		var cells []string
+		for cell := e.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.Type == html.ElementNode && (cell.DataAtom == atom.Td || cell.DataAtom == atom.Th) {
+				cells = append(cells, strings.ReplaceAll(singleLine(htmlInline(cell)), "|", `\|`))
+			}
+		}
+		if len(cells) > 0 {
+			rows = append(rows, cells)
+		}
+		return false
+	})
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	lines := make([]string, 0, len(rows)+1)
+	for i, row := range rows {
+		for len(row) < columns {
+			row = append(row, "")
+		}
+		lines = append(lines, "| "+strings.Join(row, " | ")+" |")
+		if i == 0 {
+			lines = append(lines, "|"+strings.Repeat(" --- |", columns))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// collapseSpaces replaces the runs of white space of a text with a single space (line breaks are kept)
+func collapseSpaces(text string) string {
+	lines := strings.Split(text, "\n")
+	kept := lines[:0]
+	for _, line := range lines {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// singleLine replaces the runs of white space of a text, line breaks included, with a single space
+func singleLine(text string) string {
+	return strings.Join(strings.Fields(text), " ")
+}
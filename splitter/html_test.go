@@ -0,0 +1,79 @@
+package splitter
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHTMLToMarkdown(t *testing.T) {
+	page := `<!DOCTYPE html>
+<html>
+<head><title>Ignored title</title><style>body { color: red; }</style><script>var tracking = 1;</script></head>
+<body>
+  <header><a href="/">Home</a> <nav><a href="/blog">Blog</a></nav></header>
+  <div class="cookie-banner">We use cookies</div>
+  <main>
+    <h1>Squirrels</h1>
+    <p>Squirrels   run in
+       the <a href="/forest">forest</a>.<br>They store <code>nuts</code>.</p>
+    <h2>Food</h2>
+    <ul>
+      <li>Nuts
+        <ol><li>Acorns</li><li>Hazelnuts</li></ol>
+      </li>
+      <li>Seeds</li>
+    </ul>
+    <table>
+      <tr><th>Species</th><th>Color</th></tr>
+      <tr><td>Red squirrel</td><td>Red | brown</td></tr>
+    </table>
+    <blockquote><p>Squirrels plant trees.</p></blockquote>
+    <pre>for nut in nuts:
+    store(nut)</pre>
+    <div>Loose text <em>inside</em> a div</div>
+    <div aria-hidden="true">Hidden</div>
+    <aside>Related posts</aside>
+    <form><input name="q"><button>Search</button></form>
+  </main>
+  <footer>Copyright</footer>
+</body>
+</html>`
+
+	markdown, err := HTMLToMarkdown(page)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "# Squirrels\n\n" +
+		"Squirrels run in the forest.\nThey store `nuts`.\n\n" +
+		"## Food\n\n" +
+		"- Nuts\n  1. Acorns\n  2. Hazelnuts\n- Seeds\n\n" +
+		"| Species | Color |\n| --- | --- |\n| Red squirrel | Red \\| brown |\n\n" +
+		"> Squirrels plant trees.\n\n" +
+		"```\nfor nut in nuts:\n    store(nut)\n```\n\n" +
+		"Loose text inside a div"
+	if markdown != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, markdown)
+	}
+}
+
+func TestHTMLToMarkdown_TitleAndArticle(t *testing.T) {
+	page := `<html><head><title>Birds of the forest</title></head><body>
+<div id="sidebar">Archives</div>
+<article><header><h2>Birds</h2><span class="share-buttons">Share</span></header><p>Birds fly.</p></article>
+</body></html>`
+
+	markdown, err := HTMLToMarkdown(page)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "# Birds of the forest\n\n## Birds\n\nBirds fly."
+	if markdown != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, markdown)
+	}
+
+	// The markdown is split by the hierarchy splitter
+	chunks, err := Split("html", page, nil, 512)
+	if err != nil || len(chunks) == 0 || !strings.Contains(chunks[len(chunks)-1], "HIERARCHY: Birds of the forest > Birds\nCONTENT: Birds fly.") {
+		t.Errorf("Expected the chunks of the page, got %q (%v)", chunks, err)
+	}
+}
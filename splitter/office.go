@@ -0,0 +1,627 @@
+package splitter
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Office document formats
+const (
+	// OfficeFormatDocx is a Word document (paragraphs and headings)
+	OfficeFormatDocx = "docx"
+	// OfficeFormatPptx is a PowerPoint presentation (slide text and notes)
+	OfficeFormatPptx = "pptx"
+)
+
+// maxOfficePartSize bounds the uncompressed size of a part of an Office document (protects against zip bombs)
+const maxOfficePartSize = 64 << 20
+
+// relationshipsNamespace is the namespace of the r:id attributes referencing the relationships of a part
+const relationshipsNamespace = "http://schemas.openxmlformats.org/officeDocument/2006/relationships"
+
+// OfficeFormat returns the Office format of a document from the extension of its file name ("docx" or "pptx")
+func OfficeFormat(filename string) (string, bool) {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".docx":
+		return OfficeFormatDocx, true
+	case ".pptx":
+		return OfficeFormatPptx, true
+	default:
+		return "", false
+	}
+}
+
+// OfficeImage is an image of an Office document (e.g. a scanned page or a screenshot)
+type OfficeImage struct {
+	Name string // part of the image in the document, e.g. "word/media/image1.png"
+	Data []byte
+	Page int      // page of a Word document (see DocxToMarkdown), slide of a presentation
+	Box  ImageBox // position and size of the image in its page or slide
+}
+
+// ImageBox is the position and size of an image in its page or slide, in EMUs (914400 per inch).
+// The images inline with the text of a Word document have no position (0, 0).
+type ImageBox struct {
+	X, Y, Width, Height int64
+}
+
+// ImageTextFunc returns the text of an image of a document ("" when it has none), e.g. read by OCR.
+// An error aborts the conversion of the document.
+type ImageTextFunc func(image OfficeImage) (string, error)
+
+// OfficeToMarkdown converts an Office document (OOXML) to markdown: the headings of a Word document and the slides
+// of a presentation become markdown headers, so that the document can be split with ChunkWithMarkdownHierarchy.
+// With imageText (nil: the images are ignored), the text of each image is added where the image is.
+func OfficeToMarkdown(format string, data []byte, imageText ImageTextFunc) (string, error) {
+	switch format {
+	case OfficeFormatDocx:
+		return DocxToMarkdown(data, imageText)
+	case OfficeFormatPptx:
+		return PptxToMarkdown(data, imageText)
+	default:
+		return "", fmt.Errorf("unknown office format %q (use %q or %q)", format, OfficeFormatDocx, OfficeFormatPptx)
+	}
+}
+
+// officePackage is the content of an Office document: a zip archive of XML parts
+type officePackage map[string]*zip.File
+
+// openOfficePackage opens the zip archive of an Office document
+func openOfficePackage(data []byte) (officePackage, error) {
+	reader, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid office document (not a zip archive): %w", err)
+	}
+	parts := officePackage{}
+	for _, file := range reader.File {
+		parts[file.Name] = file
+	}
+	return parts, nil
+}
+
+// read returns the content of a part (nil when the part does not exist)
+func (parts officePackage) read(name string) ([]byte, error) {
+	file, ok := parts[name]
+	if !ok {
+		return nil, nil
+	}
+	reader, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	defer reader.Close()
+	content, err := io.ReadAll(io.LimitReader(reader, maxOfficePartSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if len(content) > maxOfficePartSize {
+		return nil, fmt.Errorf("%s is too large (%d MB max)", name, maxOfficePartSize>>20)
+	}
+	return content, nil
+}
+
+// officeRelationship is a relationship of a part to another part of an Office document
+type officeRelationship struct {
+	ID     string `xml:"Id,attr"`
+	Type   string `xml:"Type,attr"`
+	Target string `xml:"Target,attr"`
+}
+
+// relationships returns the relationships of a part, with their targets resolved against the folder of the part
+func (parts officePackage) relationships(name string) ([]officeRelationship, error) {
+	content, err := parts.read(path.Join(path.Dir(name), "_rels", path.Base(name)+".rels"))
+	if err != nil || content == nil {
+		return nil, err
+	}
+	var rels struct {
+		Relationships []officeRelationship `xml:"Relationship"`
+	}
+	if err := xml.Unmarshal(content, &rels); err != nil {
+		return nil, fmt.Errorf("invalid relationships of %s: %w", name, err)
+	}
+	for i := range rels.Relationships {
+		rels.Relationships[i].Target = path.Join(path.Dir(name), rels.Relationships[i].Target)
+	}
+	return rels.Relationships, nil
+}
+
+// title returns the title of the document properties (empty when not set)
+func (parts officePackage) title() string {
+	content, err := parts.read("docProps/core.xml")
+	if err != nil || content == nil {
+		return ""
+	}
+	var properties struct {
+		Title string `xml:"title"`
+	}
+	if err := xml.Unmarshal(content, &properties); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(properties.Title)
+}
+
+// officeImages reads the text of the images of a document, once per image part (an image repeated in the document,
+// e.g. a logo on each slide, is only read where it first appears)
+type officeImages struct {
+	parts     officePackage
+	imageText ImageTextFunc
+	seen      map[string]bool
+}
+
+// newOfficeImages returns the reader of the images of a document (nil when imageText is nil)
+func newOfficeImages(parts officePackage, imageText ImageTextFunc) *officeImages {
+	if imageText == nil {
+		return nil
+	}
+	return &officeImages{parts: parts, imageText: imageText, seen: map[string]bool{}}
+}
+
+// imageTargets returns the image parts of the relationships of a part, by relationship ID
+func imageTargets(rels []officeRelationship) map[string]string {
+	targets := map[string]string{}
+	for _, rel := range rels {
+		if strings.HasSuffix(rel.Type, "/image") {
+			targets[rel.ID] = rel.Target
+		}
+	}
+	return targets
+}
+
+// block returns the markdown block of the text of an image part: a "[Image <part>, <location>]" line followed by its
+// text ("" when the image has no text or was already read)
+func (images *officeImages) block(name, location string, page int, box ImageBox) (string, error) {
+	if images == nil || name == "" || images.seen[name] {
+		return "", nil
+	}
+	images.seen[name] = true
+	data, err := images.parts.read(name)
+	if err != nil || data == nil {
+		return "", err
+	}
+	text, err := images.imageText(OfficeImage{Name: name, Data: data, Page: page, Box: box})
+	if err != nil {
+		return "", fmt.Errorf("image %s: %w", name, err)
+	}
+	if text = strings.TrimSpace(text); text == "" {
+		return "", nil
+	}
+	return fmt.Sprintf("[Image %s, %s]\n%s", name, location, text), nil
+}
+
+// officeDrawing is a picture of a document: the relationship ID of its image and its position
+type officeDrawing struct {
+	relID string
+	box   ImageBox
+}
+
+// attribute returns the value of the attribute with the given local name of an element
+func attribute(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// int64Attribute returns the integer value of an attribute (0 when missing or invalid)
+func int64Attribute(element xml.StartElement, name string) int64 {
+	value, _ := strconv.ParseInt(attribute(element, name), 10, 64)
+	return value
+}
+
+// headingStylePattern matches the names and IDs of the heading styles ("heading 1", "Heading2")
+var headingStylePattern = regexp.MustCompile(`(?i)^heading\s?([1-9])$`)
+
+// docxHeadingLevels returns the heading level of the paragraph styles of a Word document by style ID:
+// the outline level of the style, or the level of the built-in "heading N" and "Title" styles
+func docxHeadingLevels(parts officePackage) (map[string]int, error) {
+	content, err := parts.read("word/styles.xml")
+	if err != nil || content == nil {
+		return map[string]int{}, err
+	}
+	var styles struct {
+		Styles []struct {
+			ID   string `xml:"styleId,attr"`
+			Name struct {
+				Value string `xml:"val,attr"`
+			} `xml:"name"`
+			OutlineLevel *struct {
+				Value int `xml:"val,attr"`
+			} `xml:"pPr>outlineLvl"`
+		} `xml:"style"`
+	}
+	if err := xml.Unmarshal(content, &styles); err != nil {
+		return nil, fmt.Errorf("invalid word/styles.xml: %w", err)
+	}
+	levels := map[string]int{}
+	for _, style := range styles.Styles {
+		switch {
+		case style.OutlineLevel != nil && style.OutlineLevel.Value < 9:
+			levels[style.ID] = style.OutlineLevel.Value + 1
+		case strings.EqualFold(style.Name.Value, "title"):
+			levels[style.ID] = 1
+		default:
+			if matches := headingStylePattern.FindStringSubmatch(style.Name.Value); matches != nil {
+				levels[style.ID], _ = strconv.Atoi(matches[1])
+			}
+		}
+	}
+	return levels, nil
+}
+
+// docxStyleLevel returns the heading level of a paragraph style (0 for a body paragraph)
+func docxStyleLevel(levels map[string]int, styleID string) int {
+	if level, ok := levels[styleID]; ok {
+		return level
+	}
+	if matches := headingStylePattern.FindStringSubmatch(styleID); matches != nil {
+		level, _ := strconv.Atoi(matches[1])
+		return level
+	}
+	if strings.EqualFold(styleID, "title") {
+		return 1
+	}
+	return 0
+}
+
+// DocxToMarkdown converts a Word document to markdown: the headings (heading styles or outline levels) become
+// markdown headers, the list items "- " items and the table rows lines of cells separated by " | ".
+// The paragraphs before the first heading get the title of the document (or "Document") as header.
+// The text of the images of the drawings (see OfficeToMarkdown) follows their paragraph. Their page is the page
+// of the last layout saved by Word, or counted from the page breaks when the document has no layout.
+func DocxToMarkdown(data []byte, imageText ImageTextFunc) (string, error) {
+	parts, err := openOfficePackage(data)
+	if err != nil {
+		return "", err
+	}
+	content, err := parts.read("word/document.xml")
+	if err != nil {
+		return "", err
+	}
+	if content == nil {
+		return "", fmt.Errorf("invalid docx document: word/document.xml is missing")
+	}
+	levels, err := docxHeadingLevels(parts)
+	if err != nil {
+		return "", err
+	}
+	images := newOfficeImages(parts, imageText)
+	var targets map[string]string
+	if images != nil {
+		rels, err := parts.relationships("word/document.xml")
+		if err != nil {
+			return "", err
+		}
+		targets = imageTargets(rels)
+	}
+	renderedLayout := bytes.Contains(content, []byte("lastRenderedPageBreak"))
+
+	var blocks []string
+	var paragraph strings.Builder
+	var cells, row []string
+	var drawing officeDrawing
+	var drawings []officeDrawing
+	level, listItem, inText, tableDepth, page := 0, false, false, 0, 1
+	inDrawing, inOffset, axis := false, false, ""
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("invalid word/document.xml: %w", err)
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "p":
+				paragraph.Reset()
+				level, listItem = 0, false
+			case "pStyle":
+				level = docxStyleLevel(levels, attribute(element, "val"))
+			case "outlineLvl":
+				if value, err := strconv.Atoi(attribute(element, "val")); err == nil && value < 9 {
+					level = value + 1
+				}
+			case "numPr":
+				listItem = true
+			case "t":
+				inText = true
+			case "tab":
+				paragraph.WriteString("\t")
+			case "br", "cr":
+				paragraph.WriteString("\n")
+				if attribute(element, "type") == "page" && !renderedLayout {
+					page++
+				}
+			case "lastRenderedPageBreak":
+				page++
+			case "drawing":
+				inDrawing, drawing = true, officeDrawing{}
+			case "extent":
+				drawing.box.Width, drawing.box.Height = int64Attribute(element, "cx"), int64Attribute(element, "cy")
+			case "positionH", "positionV":
+				axis = element.Name.Local
+			case "posOffset":
+				inOffset = true
+			case "blip":
+				if inDrawing {
+					drawing.relID = attribute(element, "embed")
+				}
+			case "tbl":
+				tableDepth++
+			case "tr":
+				row = nil
+			case "tc":
+				cells = nil
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(element)
+			}
+			if inOffset {
+				offset, _ := strconv.ParseInt(strings.TrimSpace(string(element)), 10, 64)
+				if axis == "positionH" {
+					drawing.box.X = offset
+				} else {
+					drawing.box.Y = offset
+				}
+			}
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "t":
+				inText = false
+			case "posOffset":
+				inOffset = false
+			case "drawing":
+				inDrawing = false
+				if drawing.relID != "" {
+					drawings = append(drawings, drawing)
+				}
+			case "p":
+				text := strings.TrimSpace(paragraph.String())
+				switch {
+				case text == "":
+				case tableDepth > 0:
+					cells = append(cells, text)
+				case level > 0:
+					blocks = append(blocks, strings.Repeat("#", min(level, 6))+" "+strings.ReplaceAll(text, "\n", " "))
+				case listItem:
+					blocks = append(blocks, "- "+text)
+				default:
+					blocks = append(blocks, text)
+				}
+				// The text of the images of the paragraph follows it (on a single line in a table cell)
+				for _, drawing := range drawings {
+					block, err := images.block(targets[drawing.relID], fmt.Sprintf("page %d", page), page, drawing.box)
+					if err != nil {
+						return "", err
+					}
+					switch {
+					case block == "":
+					case tableDepth > 0:
+						cells = append(cells, strings.Join(strings.Fields(block), " "))
+					default:
+						blocks = append(blocks, block)
+					}
+				}
+				drawings = nil
+			case "tc":
+				row = append(row, strings.Join(cells, " "))
+			case "tr":
+				if strings.TrimSpace(strings.Join(row, "")) != "" {
+					blocks = append(blocks, strings.Join(row, " | "))
+				}
+			case "tbl":
+				tableDepth--
+			}
+		}
+	}
+	// The paragraphs before the first heading are kept under the title of the document
+	if len(blocks) > 0 && !strings.HasPrefix(blocks[0], "#") {
+		title := parts.title()
+		if title == "" {
+			title = "Document"
+		}
+		blocks = append([]string{"# " + title}, blocks...)
+	}
+	return strings.Join(blocks, "\n\n"), nil
+}
+
+// pptxSlideText returns the title (the text of the title placeholder), the other paragraphs and the pictures
+// of a slide part. With bodyOnly, only the body placeholders are kept (the notes of a notes slide, without the slide
+// image and number). The position of a grouped picture is relative to its group.
+func pptxSlideText(content []byte, bodyOnly bool) (string, []string, []officeDrawing, error) {
+	var title string
+	var paragraphs []string
+	var pictures []officeDrawing
+	var picture officeDrawing
+	var paragraph strings.Builder
+	placeholder, inText, inShape, inPicture := "", false, false, false
+
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "sp":
+				inShape, placeholder = true, ""
+			case "pic":
+				inPicture, picture = true, officeDrawing{}
+			case "blip":
+				if inPicture {
+					picture.relID = attribute(element, "embed")
+				}
+			case "off":
+				if inPicture {
+					picture.box.X, picture.box.Y = int64Attribute(element, "x"), int64Attribute(element, "y")
+				}
+			case "ext":
+				// The extensions (a:ext of an a:extLst) have no size
+				if inPicture && attribute(element, "cx") != "" {
+					picture.box.Width, picture.box.Height = int64Attribute(element, "cx"), int64Attribute(element, "cy")
+				}
+			case "ph":
+				placeholder = attribute(element, "type")
+			case "p":
+				paragraph.Reset()
+			case "t":
+				inText = true
+			case "br":
+				paragraph.WriteString("\n")
+			}
+		case xml.CharData:
+			if inText {
+				paragraph.Write(element)
+			}
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "t":
+				inText = false
+			case "sp":
+				inShape = false
+			case "pic":
+				inPicture = false
+				if picture.relID != "" && !bodyOnly {
+					pictures = append(pictures, picture)
+				}
+			case "p":
+				text := strings.TrimSpace(paragraph.String())
+				if text == "" || !inShape && bodyOnly {
+					continue
+				}
+				switch {
+				case placeholder == "title" || placeholder == "ctrTitle":
+					title = strings.TrimSpace(title + " " + strings.ReplaceAll(text, "\n", " "))
+				case bodyOnly && placeholder != "body":
+				default:
+					paragraphs = append(paragraphs, text)
+				}
+			}
+		}
+	}
+	return title, paragraphs, pictures, nil
+}
+
+// PptxToMarkdown converts a presentation to markdown: each slide becomes a "# Slide N: <title>" section
+// with its text, the text of its pictures (see OfficeToMarkdown), and its speaker notes a "## Notes" sub-section
+func PptxToMarkdown(data []byte, imageText ImageTextFunc) (string, error) {
+	parts, err := openOfficePackage(data)
+	if err != nil {
+		return "", err
+	}
+	content, err := parts.read("ppt/presentation.xml")
+	if err != nil {
+		return "", err
+	}
+	if content == nil {
+		return "", fmt.Errorf("invalid pptx document: ppt/presentation.xml is missing")
+	}
+	rels, err := parts.relationships("ppt/presentation.xml")
+	if err != nil {
+		return "", err
+	}
+	targets := map[string]string{}
+	for _, rel := range rels {
+		targets[rel.ID] = rel.Target
+	}
+
+	// The slides, in the order of the presentation
+	var presentation struct {
+		Slides []struct {
+			Attrs []xml.Attr `xml:",any,attr"`
+		} `xml:"sldIdLst>sldId"`
+	}
+	if err := xml.Unmarshal(content, &presentation); err != nil {
+		return "", fmt.Errorf("invalid ppt/presentation.xml: %w", err)
+	}
+
+	images := newOfficeImages(parts, imageText)
+	var sections []string
+	for i, slide := range presentation.Slides {
+		var slidePart string
+		for _, attr := range slide.Attrs {
+			if attr.Name.Space == relationshipsNamespace && attr.Name.Local == "id" {
+				slidePart = targets[attr.Value]
+			}
+		}
+		slideContent, err := parts.read(slidePart)
+		if err != nil {
+			return "", err
+		}
+		if slideContent == nil {
+			continue
+		}
+		title, paragraphs, pictures, err := pptxSlideText(slideContent, false)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s: %w", slidePart, err)
+		}
+		slideRels, err := parts.relationships(slidePart)
+		if err != nil {
+			return "", err
+		}
+
+		header := fmt.Sprintf("# Slide %d", i+1)
+		if title != "" {
+			header += ": " + title
+		}
+		section := []string{header}
+		section = append(section, paragraphs...)
+
+		// The text of the pictures of the slide
+		targets := imageTargets(slideRels)
+		for _, picture := range pictures {
+			block, err := images.block(targets[picture.relID], fmt.Sprintf("slide %d", i+1), i+1, picture.box)
+			if err != nil {
+				return "", err
+			}
+			if block != "" {
+				section = append(section, block)
+			}
+		}
+
+		// The speaker notes of the slide
+		for _, rel := range slideRels {
+			if !strings.HasSuffix(rel.Type, "/notesSlide") {
+				continue
+			}
+			notesContent, err := parts.read(rel.Target)
+			if err != nil {
+				return "", err
+			}
+			if notesContent == nil {
+				continue
+			}
+			_, notes, _, err := pptxSlideText(notesContent, true)
+			if err != nil {
+				return "", fmt.Errorf("invalid %s: %w", rel.Target, err)
+			}
+			if len(notes) > 0 {
+				section = append(section, "## Notes")
+				section = append(section, notes...)
+			}
+		}
+		sections = append(sections, strings.Join(section, "\n\n"))
+	}
+	return strings.Join(sections, "\n\n"), nil
+}
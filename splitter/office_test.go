@@ -0,0 +1,265 @@
+package splitter
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// officeDocument builds an Office document (a zip archive) from its parts
+func officeDocument(t *testing.T, parts map[string]string) []byte {
+	t.Helper()
+	var buffer bytes.Buffer
+	writer := zip.NewWriter(&buffer)
+	for name, content := range parts {
+		part, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("Failed to create %s: %v", name, err)
+		}
+		part.Write([]byte(content))
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("Failed to close the archive: %v", err)
+	}
+	return buffer.Bytes()
+}
+
+const testDocx = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:body>
+<w:p><w:r><w:t>Introduction paragraph.</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="Titre1"/></w:pPr><w:r><w:t>Installation</w:t></w:r></w:p>
+<w:p><w:r><w:t xml:space="preserve">Download the </w:t></w:r><w:r><w:rPr><w:b/></w:rPr><w:t>binary</w:t></w:r><w:r><w:t>.</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="ListParagraph"/><w:numPr><w:ilvl w:val="0"/><w:numId w:val="1"/></w:numPr></w:pPr><w:r><w:t>Linux</w:t></w:r></w:p>
+<w:p><w:pPr><w:pStyle w:val="Heading2"/></w:pPr><w:r><w:t>Configuration</w:t></w:r></w:p>
+<w:tbl>
+<w:tr><w:tc><w:p><w:r><w:t>Option</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>Default</w:t></w:r></w:p></w:tc></w:tr>
+<w:tr><w:tc><w:p><w:r><w:t>port</w:t></w:r></w:p></w:tc><w:tc><w:p><w:r><w:t>8080</w:t></w:r></w:p></w:tc></w:tr>
+</w:tbl>
+<w:p><w:pPr><w:outlineLvl w:val="0"/></w:pPr><w:r><w:t>Usage</w:t></w:r></w:p>
+<w:p><w:r><w:t>Run it.</w:t></w:r></w:p>
+</w:body>
+</w:document>`
+
+const testDocxStyles = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<w:styles xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main">
+<w:style w:type="paragraph" w:styleId="Titre1"><w:name w:val="heading 1"/></w:style>
+<w:style w:type="paragraph" w:styleId="ListParagraph"><w:name w:val="List Paragraph"/></w:style>
+</w:styles>`
+
+func TestDocxToMarkdown(t *testing.T) {
+	data := officeDocument(t, map[string]string{
+		"word/document.xml": testDocx,
+		"word/styles.xml":   testDocxStyles,
+		"docProps/core.xml": `<cp:coreProperties xmlns:cp="http://schemas.openxmlformats.org/package/2006/metadata/core-properties" xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>User guide</dc:title></cp:coreProperties>`,
+	})
+
+	markdown, err := DocxToMarkdown(data, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "# User guide\n\nIntroduction paragraph.\n\n" +
+		"# Installation\n\nDownload the binary.\n\n- Linux\n\n" +
+		"## Configuration\n\nOption | Default\n\nport | 8080\n\n" +
+		"# Usage\n\nRun it."
+	if markdown != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, markdown)
+	}
+
+	chunks, err := Split("markdown_hierarchy", markdown, nil, 1000)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(chunks) != 4 || !strings.Contains(chunks[2], "HIERARCHY: Installation > Configuration") {
+		t.Errorf("Expected the headings to give the hierarchy of the chunks, got %q", chunks)
+	}
+}
+
+// imageRelationships returns the relationships of a part to images
+func imageRelationships(targets map[string]string) string {
+	rels := `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`
+	for id, target := range targets {
+		rels += `<Relationship Id="` + id + `" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/image" Target="` + target + `"/>`
+	}
+	return rels + `</Relationships>`
+}
+
+// recordImages returns an ImageTextFunc answering "Text of <part>" and the images it read
+func recordImages() (ImageTextFunc, *[]OfficeImage) {
+	var images []OfficeImage
+	return func(image OfficeImage) (string, error) {
+		images = append(images, image)
+		return "Text of " + image.Name, nil
+	}, &images
+}
+
+func TestDocxToMarkdown_Images(t *testing.T) {
+	drawing := func(id, position string) string {
+		return `<w:r><w:drawing><wp:` + position + `><wp:extent cx="914400" cy="457200"/>` +
+			`<a:graphic><a:graphicData><pic:pic><pic:blipFill><a:blip r:embed="` + id + `"/></pic:blipFill></pic:pic></a:graphicData></a:graphic>` +
+			`</wp:` + position + `></w:drawing></w:r>`
+	}
+	anchored := `<w:r><w:drawing><wp:anchor><wp:positionH><wp:posOffset>1828800</wp:posOffset></wp:positionH><wp:positionV><wp:posOffset>914400</wp:posOffset></wp:positionV>` +
+		`<wp:extent cx="2743200" cy="3657600"/><a:graphic><a:graphicData><pic:pic><pic:blipFill><a:blip r:embed="rId8"/></pic:blipFill></pic:pic></a:graphicData></a:graphic></wp:anchor></w:drawing></w:r>`
+	document := `<w:document xmlns:w="http://schemas.openxmlformats.org/wordprocessingml/2006/main" xmlns:wp="http://schemas.openxmlformats.org/drawingml/2006/wordprocessingDrawing" ` +
+		`xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:pic="http://schemas.openxmlformats.org/drawingml/2006/picture" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><w:body>` +
+		`<w:p><w:pPr><w:pStyle w:val="Heading1"/></w:pPr><w:r><w:t>Scans</w:t></w:r></w:p>` +
+		`<w:p><w:r><w:t>Signed contract:</w:t></w:r>` + drawing("rId7", "inline") + `</w:p>` +
+		`<w:p><w:r><w:br w:type="page"/></w:r>` + anchored + `</w:p>` +
+		`<w:p>` + drawing("rId7", "inline") + `</w:p>` +
+		`<w:tbl><w:tr><w:tc><w:p>` + drawing("rId9", "inline") + `</w:p></w:tc><w:tc><w:p><w:r><w:t>Stamp</w:t></w:r></w:p></w:tc></w:tr></w:tbl>` +
+		`</w:body></w:document>`
+	data := officeDocument(t, map[string]string{
+		"word/document.xml":            document,
+		"word/_rels/document.xml.rels": imageRelationships(map[string]string{"rId7": "media/image1.png", "rId8": "media/image2.jpeg", "rId9": "media/image3.png"}),
+		"word/media/image1.png":        "png",
+		"word/media/image2.jpeg":       "jpeg",
+		"word/media/image3.png":        "png",
+	})
+
+	// Without imageText, the images are ignored
+	markdown, err := DocxToMarkdown(data, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := "# Scans\n\nSigned contract:\n\n | Stamp"; markdown != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, markdown)
+	}
+
+	imageText, images := recordImages()
+	markdown, err = DocxToMarkdown(data, imageText)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "# Scans\n\nSigned contract:\n\n[Image word/media/image1.png, page 1]\nText of word/media/image1.png\n\n" +
+		"[Image word/media/image2.jpeg, page 2]\nText of word/media/image2.jpeg\n\n" +
+		"[Image word/media/image3.png, page 2] Text of word/media/image3.png | Stamp"
+	if markdown != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, markdown)
+	}
+
+	// The repeated image is read once, with the page and the position of the images
+	expectedImages := []OfficeImage{
+		{Name: "word/media/image1.png", Data: []byte("png"), Page: 1, Box: ImageBox{Width: 914400, Height: 457200}},
+		{Name: "word/media/image2.jpeg", Data: []byte("jpeg"), Page: 2, Box: ImageBox{X: 1828800, Y: 914400, Width: 2743200, Height: 3657600}},
+		{Name: "word/media/image3.png", Data: []byte("png"), Page: 2, Box: ImageBox{Width: 914400, Height: 457200}},
+	}
+	if len(*images) != len(expectedImages) {
+		t.Fatalf("Expected %d images, got %+v", len(expectedImages), *images)
+	}
+	for i, image := range *images {
+		expected := expectedImages[i]
+		if image.Name != expected.Name || string(image.Data) != string(expected.Data) || image.Page != expected.Page || image.Box != expected.Box {
+			t.Errorf("Expected image %+v, got %+v", expected, image)
+		}
+	}
+
+	// An error of imageText aborts the conversion
+	if _, err := DocxToMarkdown(data, func(OfficeImage) (string, error) { return "", errors.New("OCR failed") }); err == nil {
+		t.Error("Expected the error of imageText")
+	}
+}
+
+func TestDocxToMarkdown_Invalid(t *testing.T) {
+	if _, err := DocxToMarkdown([]byte("not a zip archive"), nil); err == nil {
+		t.Error("Expected an error for a document that is not a zip archive")
+	}
+	if _, err := DocxToMarkdown(officeDocument(t, map[string]string{"ppt/presentation.xml": "<p:presentation/>"}), nil); err == nil {
+		t.Error("Expected an error for a document without word/document.xml")
+	}
+}
+
+func TestPptxToMarkdown(t *testing.T) {
+	slide := func(title, body string) string {
+		return `<p:sld xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"><p:cSld><p:spTree>` +
+			`<p:sp><p:nvSpPr><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>` + title + `</a:t></a:r></a:p></p:txBody></p:sp>` +
+			`<p:sp><p:nvSpPr><p:nvPr><p:ph idx="1"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>` + body + `</a:t></a:r></a:p><a:p></a:p></p:txBody></p:sp>` +
+			`</p:spTree></p:cSld></p:sld>`
+	}
+	data := officeDocument(t, map[string]string{
+		"ppt/presentation.xml": `<p:presentation xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<p:sldIdLst><p:sldId id="256" r:id="rId3"/><p:sldId id="257" r:id="rId2"/></p:sldIdLst></p:presentation>`,
+		"ppt/_rels/presentation.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide" Target="slides/slide1.xml"/>` +
+			`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide" Target="slides/slide2.xml"/>` +
+			`</Relationships>`,
+		"ppt/slides/slide2.xml": slide("Roadmap", "Ship the v2 in March"),
+		"ppt/slides/slide1.xml": slide("Questions", "Ask away"),
+		"ppt/slides/_rels/slide2.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/notesSlide" Target="../notesSlides/notesSlide1.xml"/>` +
+			`</Relationships>`,
+		"ppt/notesSlides/notesSlide1.xml": `<p:notes xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main"><p:cSld><p:spTree>` +
+			`<p:sp><p:nvSpPr><p:nvPr><p:ph type="body" idx="1"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>Mention the beta testers</a:t></a:r></a:p></p:txBody></p:sp>` +
+			`<p:sp><p:nvSpPr><p:nvPr><p:ph type="sldNum" idx="5"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>1</a:t></a:r></a:p></p:txBody></p:sp>` +
+			`</p:spTree></p:cSld></p:notes>`,
+	})
+
+	markdown, err := PptxToMarkdown(data, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := "# Slide 1: Roadmap\n\nShip the v2 in March\n\n## Notes\n\nMention the beta testers\n\n" +
+		"# Slide 2: Questions\n\nAsk away"
+	if markdown != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, markdown)
+	}
+}
+
+func TestPptxToMarkdown_Images(t *testing.T) {
+	slide := func(title string) string {
+		return `<p:sld xmlns:a="http://schemas.openxmlformats.org/drawingml/2006/main" xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><p:cSld><p:spTree>` +
+			`<p:sp><p:nvSpPr><p:nvPr><p:ph type="title"/></p:nvPr></p:nvSpPr><p:txBody><a:p><a:r><a:t>` + title + `</a:t></a:r></a:p></p:txBody></p:sp>` +
+			`<p:pic><p:blipFill><a:blip r:embed="rId2"><a:extLst><a:ext uri="{28A0092B-C50C-407E-A947-70E740481C1C}"/></a:extLst></a:blip></p:blipFill>` +
+			`<p:spPr><a:xfrm><a:off x="457200" y="1371600"/><a:ext cx="8229600" cy="4525963"/></a:xfrm></p:spPr></p:pic>` +
+			`</p:spTree></p:cSld></p:sld>`
+	}
+	data := officeDocument(t, map[string]string{
+		"ppt/presentation.xml": `<p:presentation xmlns:p="http://schemas.openxmlformats.org/presentationml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<p:sldIdLst><p:sldId id="256" r:id="rId2"/><p:sldId id="257" r:id="rId3"/></p:sldIdLst></p:presentation>`,
+		"ppt/_rels/presentation.xml.rels": `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide" Target="slides/slide1.xml"/>` +
+			`<Relationship Id="rId3" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/slide" Target="slides/slide2.xml"/>` +
+			`</Relationships>`,
+		"ppt/slides/slide1.xml":            slide("Architecture"),
+		"ppt/slides/slide2.xml":            slide("Logo"),
+		"ppt/slides/_rels/slide1.xml.rels": imageRelationships(map[string]string{"rId2": "../media/image1.png"}),
+		"ppt/slides/_rels/slide2.xml.rels": imageRelationships(map[string]string{"rId2": "../media/image1.png"}),
+		"ppt/media/image1.png":             "png",
+	})
+
+	imageText, images := recordImages()
+	markdown, err := PptxToMarkdown(data, imageText)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	// The image of both slides is read on the first one
+	expected := "# Slide 1: Architecture\n\n[Image ppt/media/image1.png, slide 1]\nText of ppt/media/image1.png\n\n# Slide 2: Logo"
+	if markdown != expected {
+		t.Errorf("Expected:\n%s\n\nGot:\n%s", expected, markdown)
+	}
+	box := ImageBox{X: 457200, Y: 1371600, Width: 8229600, Height: 4525963}
+	if len(*images) != 1 || (*images)[0].Page != 1 || (*images)[0].Box != box {
+		t.Errorf("Expected the image of slide 1 at %+v, got %+v", box, *images)
+	}
+}
+
+func TestOfficeFormat(t *testing.T) {
+	tests := map[string]string{
+		"guide.docx":     OfficeFormatDocx,
+		"Slides/Q3.PPTX": OfficeFormatPptx,
+		"report.pdf":     "",
+		"notes.docx.bak": "",
+	}
+	for filename, expected := range tests {
+		format, ok := OfficeFormat(filename)
+		if format != expected || ok != (expected != "") {
+			t.Errorf("OfficeFormat(%q) = %q, %v, expected %q", filename, format, ok, expected)
+		}
+	}
+
+	if _, err := OfficeToMarkdown("xlsx", nil, nil); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
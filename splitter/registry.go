@@ -0,0 +1,397 @@
+package splitter
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Options holds the strategy specific options of a split request (decoded from JSON)
+type Options map[string]interface{}
+
+// SplitFunc splits a document into chunks that fit maxTokens (estimated) tokens.
+// It returns an error when the options are invalid for the strategy.
+type SplitFunc func(document string, options Options, maxTokens int) ([]string, error)
+
+var (
+	registryMutex sync.RWMutex
+	registry      = make(map[string]SplitFunc)
+)
+
+// Register makes a splitting strategy available by name to the split-and-store endpoint and tool.
+// It panics if the name is empty, the function is nil or the name is already registered.
+func Register(name string, fn SplitFunc) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if name == "" {
+		panic("splitter: Register with an empty name")
+	}
+	if fn == nil {
+		panic("splitter: Register with a nil split function for " + name)
+	}
+	if _, exists := registry[name]; exists {
+		panic("splitter: Register called twice for " + name)
+	}
+	registry[name] = fn
+}
+
+// Lookup returns the splitting strategy registered with the given name
+func Lookup(name string) (SplitFunc, bool) {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	fn, ok := registry[name]
+	return fn, ok
+}
+
+// Strategies returns the sorted names of the registered splitting strategies
+func Strategies() []string {
+	registryMutex.RLock()
+	defer registryMutex.RUnlock()
+
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// extensionStrategies are the splitting strategies of the document file extensions
+var extensionStrategies = map[string]string{
+	".md":       "markdown_sections",
+	".markdown": "markdown_sections",
+	".rst":      "rst_sections",
+	".adoc":     "asciidoc_sections",
+	".asciidoc": "asciidoc_sections",
+	".asc":      "asciidoc_sections",
+	".html":     "html",
+	".htm":      "html",
+}
+
+// FileTypeConfig is the default splitting strategy of a file type, with its default options
+type FileTypeConfig struct {
+	Strategy string  `json:"strategy"`
+	Options  Options `json:"options,omitempty"`
+}
+
+// fileTypeConfigs are the splitting strategies configured by file extension, taking precedence over the built-in
+// extensionStrategies (guarded by registryMutex)
+var fileTypeConfigs = map[string]FileTypeConfig{}
+
+// normalizeExtension returns a file extension in lower case with its leading dot ("MD" becomes ".md")
+func normalizeExtension(extension string) string {
+	extension = strings.ToLower(strings.TrimSpace(extension))
+	if extension != "" && !strings.HasPrefix(extension, ".") {
+		extension = "." + extension
+	}
+	return extension
+}
+
+// ParseFileTypeConfigs parses the splitting strategies of the file types, a JSON object by file extension like
+// {".txt": {"strategy": "chunk_overlap", "options": {"chunk_size": 1024, "overlap": 128}}, "md": {"strategy": "markdown_hierarchy"}}.
+// The strategies must be registered, and their options are checked by splitting a sample text.
+func ParseFileTypeConfigs(spec string) (map[string]FileTypeConfig, error) {
+	configs := map[string]FileTypeConfig{}
+	if strings.TrimSpace(spec) == "" {
+		return configs, nil
+	}
+	var entries map[string]FileTypeConfig
+	if err := json.Unmarshal([]byte(spec), &entries); err != nil {
+		return nil, fmt.Errorf("expected a JSON object of file extensions: %w", err)
+	}
+	for extension, config := range entries {
+		normalized := normalizeExtension(extension)
+		if normalized == "" || normalized == "." {
+			return nil, fmt.Errorf("empty file extension")
+		}
+		if _, exists := configs[normalized]; exists {
+			return nil, fmt.Errorf("duplicate file extension %s", normalized)
+		}
+		if _, err := Split(config.Strategy, "sample", config.Options, math.MaxInt32); err != nil {
+			return nil, fmt.Errorf("file extension %s: %w", normalized, err)
+		}
+		configs[normalized] = config
+	}
+	return configs, nil
+}
+
+// SetFileTypeConfigs sets the splitting strategies configured by file extension (see ParseFileTypeConfigs)
+func SetFileTypeConfigs(configs map[string]FileTypeConfig) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	fileTypeConfigs = configs
+}
+
+// ConfigForFile returns the splitting strategy and default options of a document from the extension of its file
+// name: the configured strategy of the extension, else the built-in strategy (e.g. "rst_sections" for "docs/setup.rst")
+func ConfigForFile(filename string) (FileTypeConfig, bool) {
+	extension := strings.ToLower(filepath.Ext(filename))
+
+	registryMutex.RLock()
+	config, ok := fileTypeConfigs[extension]
+	registryMutex.RUnlock()
+	if ok {
+		return config, true
+	}
+
+	strategy, ok := extensionStrategies[extension]
+	return FileTypeConfig{Strategy: strategy}, ok
+}
+
+// StrategyForFile returns the splitting strategy of a document from the extension of its file name (see ConfigForFile)
+func StrategyForFile(filename string) (string, bool) {
+	config, ok := ConfigForFile(filename)
+	return config.Strategy, ok
+}
+
+// Merge returns the options completed with the default options: the options set in o take precedence
+func (o Options) Merge(defaults Options) Options {
+	merged := Options{}
+	for key, value := range defaults {
+		merged[key] = value
+	}
+	for key, value := range o {
+		merged[key] = value
+	}
+	return merged
+}
+
+// Split splits a document with the splitting strategy registered with the given name
+func Split(strategy, document string, options Options, maxTokens int) ([]string, error) {
+	fn, ok := Lookup(strategy)
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q (available strategies: %v)", strategy, Strategies())
+	}
+	if options == nil {
+		options = Options{}
+	}
+	return fn(document, options, maxTokens)
+}
+
+// Int returns the integer option with the given key, or defaultValue when the option is not set
+func (o Options) Int(key string, defaultValue int) (int, error) {
+	value, ok := o[key]
+	if !ok || value == nil {
+		return defaultValue, nil
+	}
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case float64:
+		if v != float64(int(v)) {
+			return 0, fmt.Errorf("option %s must be an integer", key)
+		}
+		return int(v), nil
+	default:
+		return 0, fmt.Errorf("option %s must be an integer", key)
+	}
+}
+
+// String returns the string option with the given key, or defaultValue when the option is not set
+func (o Options) String(key, defaultValue string) (string, error) {
+	value, ok := o[key]
+	if !ok || value == nil {
+		return defaultValue, nil
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("option %s must be a string", key)
+	}
+	return s, nil
+}
+
+// Strings returns the string list option with the given key, or defaultValue when the option is not set
+func (o Options) Strings(key string, defaultValue []string) ([]string, error) {
+	value, ok := o[key]
+	if !ok || value == nil {
+		return defaultValue, nil
+	}
+	switch v := value.(type) {
+	case []string:
+		return v, nil
+	case []interface{}:
+		values := make([]string, len(v))
+		for i, item := range v {
+			s, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("option %s must be a list of strings", key)
+			}
+			values[i] = s
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("option %s must be a list of strings", key)
+	}
+}
+
+// Built-in strategies, matching the dedicated chunk and split endpoints
+func init() {
+	Register("chunk_overlap", splitChunkOverlap)
+	Register("markdown_sections", splitMarkdownSections)
+	Register("delimiter", splitWithDelimiter)
+	Register("markdown_hierarchy", splitMarkdownWithHierarchy)
+	Register("rst_sections", splitRSTSections)
+	Register("asciidoc_sections", splitAsciiDocSections)
+	Register("tokens", splitTokens)
+	Register("recursive", splitRecursive)
+	Register("html", splitHTML)
+}
+
+// splitChunkOverlap splits a document into chunks of chunk_size characters with overlap (options: chunk_size, overlap)
+func splitChunkOverlap(document string, options Options, maxTokens int) ([]string, error) {
+	chunkSize, err := options.Int("chunk_size", 0)
+	if err != nil {
+		return nil, err
+	}
+	overlap, err := options.Int("overlap", 0)
+	if err != nil {
+		return nil, err
+	}
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("option chunk_size must be greater than 0")
+	}
+	if overlap < 0 {
+		return nil, fmt.Errorf("option overlap cannot be negative")
+	}
+	if overlap >= chunkSize {
+		return nil, fmt.Errorf("option overlap must be less than chunk_size")
+	}
+
+	chunks := ChunkText(document, chunkSize, overlap)
+	for _, chunk := range chunks {
+		if tokens := CountTokens(chunk); tokens > maxTokens {
+			return nil, fmt.Errorf("chunk_size (%d characters) produces chunks of about %d tokens, above the embedding model limit (%d tokens)", chunkSize, tokens, maxTokens)
+		}
+	}
+	return chunks, nil
+}
+
+// splitMarkdownSections splits a markdown document by sections, repeating the section header in sub-chunks
+func splitMarkdownSections(document string, options Options, maxTokens int) ([]string, error) {
+	chunks := []string{}
+	for _, section := range SplitMarkdownBySections(document) {
+		chunks = append(chunks, SubdivideWithHeader(section, ExtractSectionHeader(section), maxTokens)...)
+	}
+	return chunks, nil
+}
+
+// splitWithDelimiter splits a document with a delimiter, repeating the first 2 non-empty lines in sub-chunks (options: delimiter)
+func splitWithDelimiter(document string, options Options, maxTokens int) ([]string, error) {
+	delimiter, err := options.String("delimiter", "")
+	if err != nil {
+		return nil, err
+	}
+	if delimiter == "" {
+		return nil, fmt.Errorf("option delimiter is required")
+	}
+
+	chunks := []string{}
+	for _, chunk := range SplitTextWithDelimiter(document, delimiter) {
+		chunks = append(chunks, SubdivideWithHeader(chunk, ExtractFirstNonEmptyLines(chunk, 2), maxTokens)...)
+	}
+	return chunks, nil
+}
+
+// splitMarkdownWithHierarchy splits a markdown document into chunks carrying their title and hierarchy
+func splitMarkdownWithHierarchy(document string, options Options, maxTokens int) ([]string, error) {
+	chunks := []string{}
+	for _, chunk := range ChunkWithMarkdownHierarchy(document) {
+		chunks = append(chunks, ChunkTextByTokens(chunk, maxTokens)...)
+	}
+	return chunks, nil
+}
+
+// splitRSTSections splits a reStructuredText document by sections, repeating the section title in sub-chunks
+func splitRSTSections(document string, options Options, maxTokens int) ([]string, error) {
+	chunks := []string{}
+	for _, section := range SplitRSTBySections(document) {
+		chunks = append(chunks, SubdivideWithHeader(section, ExtractRSTSectionHeader(section), maxTokens)...)
+	}
+	return chunks, nil
+}
+
+// splitAsciiDocSections splits an AsciiDoc document by sections, repeating the section title in sub-chunks
+func splitAsciiDocSections(document string, options Options, maxTokens int) ([]string, error) {
+	chunks := []string{}
+	for _, section := range SplitAsciiDocBySections(document) {
+		chunks = append(chunks, SubdivideWithHeader(section, ExtractAsciiDocSectionHeader(section), maxTokens)...)
+	}
+	return chunks, nil
+}
+
+// splitTokens splits a document into chunks of max_tokens tokens with an overlap of overlap_tokens tokens, counted with
+// the tokenizer of the embedding model (options: max_tokens, default: the embedding model limit, and overlap_tokens)
+func splitTokens(document string, options Options, maxTokens int) ([]string, error) {
+	chunkTokens, err := options.Int("max_tokens", maxTokens)
+	if err != nil {
+		return nil, err
+	}
+	overlapTokens, err := options.Int("overlap_tokens", 0)
+	if err != nil {
+		return nil, err
+	}
+	if chunkTokens <= 0 {
+		return nil, fmt.Errorf("option max_tokens must be greater than 0")
+	}
+	if chunkTokens > maxTokens {
+		return nil, fmt.Errorf("option max_tokens (%d) is above the embedding model limit (%d tokens)", chunkTokens, maxTokens)
+	}
+	if overlapTokens < 0 {
+		return nil, fmt.Errorf("option overlap_tokens cannot be negative")
+	}
+	if overlapTokens >= chunkTokens {
+		return nil, fmt.Errorf("option overlap_tokens must be less than max_tokens")
+	}
+	return ChunkTextByTokensWithOverlap(document, chunkTokens, overlapTokens), nil
+}
+
+// splitRecursive splits a document into chunks of chunk_size characters at paragraph, then sentence, then word boundaries
+// (options: chunk_size, overlap and separators, see RecursiveSplit)
+func splitRecursive(document string, options Options, maxTokens int) ([]string, error) {
+	chunkSize, err := options.Int("chunk_size", 0)
+	if err != nil {
+		return nil, err
+	}
+	overlap, err := options.Int("overlap", 0)
+	if err != nil {
+		return nil, err
+	}
+	separators, err := options.Strings("separators", nil)
+	if err != nil {
+		return nil, err
+	}
+	if chunkSize <= 0 {
+		return nil, fmt.Errorf("option chunk_size must be greater than 0")
+	}
+	if overlap < 0 {
+		return nil, fmt.Errorf("option overlap cannot be negative")
+	}
+	if overlap >= chunkSize {
+		return nil, fmt.Errorf("option overlap must be less than chunk_size")
+	}
+
+	chunks := RecursiveSplit(document, chunkSize, overlap, separators)
+	for _, chunk := range chunks {
+		if tokens := CountTokens(chunk); tokens > maxTokens {
+			return nil, fmt.Errorf("chunk_size (%d characters) produces chunks of about %d tokens, above the embedding model limit (%d tokens)", chunkSize, tokens, maxTokens)
+		}
+	}
+	return chunks, nil
+}
+
+// splitHTML converts an HTML page to markdown without its boilerplate (see HTMLToMarkdown) and splits it into chunks
+// carrying their title and hierarchy
+func splitHTML(document string, options Options, maxTokens int) ([]string, error) {
+	markdown, err := HTMLToMarkdown(document)
+	if err != nil {
+		return nil, err
+	}
+	return splitMarkdownWithHierarchy(markdown, options, maxTokens)
+}
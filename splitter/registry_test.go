@@ -0,0 +1,246 @@
+package splitter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestBuiltinStrategies(t *testing.T) {
+	expected := []string{"asciidoc_sections", "chunk_overlap", "delimiter", "markdown_hierarchy", "markdown_sections", "recursive", "rst_sections", "tokens"}
+	for _, name := range expected {
+		if _, ok := Lookup(name); !ok {
+			t.Errorf("Expected built-in strategy %q to be registered", name)
+		}
+	}
+}
+
+func TestRegister(t *testing.T) {
+	Register("test_lines", func(document string, options Options, maxTokens int) ([]string, error) {
+		return strings.Split(document, "\n"), nil
+	})
+
+	chunks, err := Split("test_lines", "line 1\nline 2", nil, 100)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(chunks, []string{"line 1", "line 2"}) {
+		t.Errorf("Unexpected chunks: %v", chunks)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected Register to panic on a duplicate name")
+		}
+	}()
+	Register("test_lines", func(document string, options Options, maxTokens int) ([]string, error) {
+		return nil, nil
+	})
+}
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		name      string
+		strategy  string
+		document  string
+		options   Options
+		expected  []string
+		expectErr bool
+	}{
+		{
+			name:     "Chunk overlap",
+			strategy: "chunk_overlap",
+			document: "abcdefgh",
+			options:  Options{"chunk_size": float64(4), "overlap": float64(2)},
+			expected: []string{"abcd", "cdef", "efgh", "gh"},
+		},
+		{
+			name:      "Chunk overlap with overlap >= chunk_size",
+			strategy:  "chunk_overlap",
+			document:  "abcdefgh",
+			options:   Options{"chunk_size": float64(4), "overlap": float64(4)},
+			expectErr: true,
+		},
+		{
+			name:      "Chunk overlap with non integer chunk_size",
+			strategy:  "chunk_overlap",
+			document:  "abcdefgh",
+			options:   Options{"chunk_size": "four"},
+			expectErr: true,
+		},
+		{
+			name:     "Delimiter",
+			strategy: "delimiter",
+			document: "part 1---part 2",
+			options:  Options{"delimiter": "---"},
+			expected: []string{"part 1", "part 2"},
+		},
+		{
+			name:      "Delimiter without delimiter option",
+			strategy:  "delimiter",
+			document:  "part 1---part 2",
+			expectErr: true,
+		},
+		{
+			name:     "Markdown sections",
+			strategy: "markdown_sections",
+			document: "# Title\nIntro\n## Section\nContent",
+			expected: []string{"# Title\nIntro", "## Section\nContent"},
+		},
+		{
+			name:     "RST sections",
+			strategy: "rst_sections",
+			document: "=====\nGuide\n=====\nIntro\n\nSetup\n-----\nInstall it.\n\n:: \n\n    code\n    ----",
+			expected: []string{"=====\nGuide\n=====\nIntro", "Setup\n-----\nInstall it.\n\n:: \n\n    code\n    ----"},
+		},
+		{
+			name:     "AsciiDoc sections",
+			strategy: "asciidoc_sections",
+			document: "= Guide\nIntro\n\n== Setup\n----\n== not a title\n----\n=== TLS\nUse a certificate.",
+			expected: []string{"= Guide\nIntro", "== Setup\n----\n== not a title\n----", "=== TLS\nUse a certificate."},
+		},
+		{
+			name:     "Recursive",
+			strategy: "recursive",
+			document: "Frogs swim. Birds fly.\n\nFishes swim in the sea",
+			options:  Options{"chunk_size": float64(24)},
+			expected: []string{"Frogs swim. Birds fly.", "Fishes swim in the sea"},
+		},
+		{
+			name:     "Recursive with separators",
+			strategy: "recursive",
+			document: "a;b;c",
+			options:  Options{"chunk_size": float64(2), "separators": []interface{}{";"}},
+			expected: []string{"a;", "b;", "c"},
+		},
+		{
+			name:      "Recursive with invalid separators",
+			strategy:  "recursive",
+			document:  "a;b;c",
+			options:   Options{"chunk_size": float64(2), "separators": ";"},
+			expectErr: true,
+		},
+		{
+			name:      "Unknown strategy",
+			strategy:  "by_sentence",
+			document:  "Some content",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, err := Split(tt.strategy, tt.document, tt.options, 100)
+			if tt.expectErr {
+				if err == nil {
+					t.Errorf("Expected an error, got chunks %v", chunks)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !reflect.DeepEqual(chunks, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, chunks)
+			}
+		})
+	}
+}
+
+func TestStrategyForFile(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected string
+		ok       bool
+	}{
+		{filename: "README.md", expected: "markdown_sections", ok: true},
+		{filename: "docs/setup.rst", expected: "rst_sections", ok: true},
+		{filename: "docs/Guide.ADOC", expected: "asciidoc_sections", ok: true},
+		{filename: "manual.asciidoc", expected: "asciidoc_sections", ok: true},
+		{filename: "notes.txt", ok: false},
+		{filename: "Makefile", ok: false},
+	}
+
+	for _, tt := range tests {
+		strategy, ok := StrategyForFile(tt.filename)
+		if strategy != tt.expected || ok != tt.ok {
+			t.Errorf("StrategyForFile(%q) = %q, %v, expected %q, %v", tt.filename, strategy, ok, tt.expected, tt.ok)
+		}
+	}
+}
+
+func TestParseFileTypeConfigs(t *testing.T) {
+	configs, err := ParseFileTypeConfigs(`{".TXT": {"strategy": "chunk_overlap", "options": {"chunk_size": 1024, "overlap": 128}}, "md": {"strategy": "markdown_hierarchy"}}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]FileTypeConfig{
+		".txt": {Strategy: "chunk_overlap", Options: Options{"chunk_size": float64(1024), "overlap": float64(128)}},
+		".md":  {Strategy: "markdown_hierarchy"},
+	}
+	if !reflect.DeepEqual(configs, expected) {
+		t.Errorf("Expected %v, got %v", expected, configs)
+	}
+
+	if configs, err := ParseFileTypeConfigs(""); err != nil || len(configs) != 0 {
+		t.Errorf("Expected no configuration, got %v (error: %v)", configs, err)
+	}
+
+	invalid := map[string]string{
+		"Not JSON":            `.txt=chunk_overlap`,
+		"Unknown strategy":    `{".txt": {"strategy": "sentences"}}`,
+		"Missing strategy":    `{".txt": {"options": {"chunk_size": 512}}}`,
+		"Invalid options":     `{".txt": {"strategy": "chunk_overlap", "options": {"chunk_size": 512, "overlap": 512}}}`,
+		"Empty extension":     `{"": {"strategy": "markdown_sections"}}`,
+		"Duplicate extension": `{".md": {"strategy": "markdown_sections"}, "MD": {"strategy": "markdown_hierarchy"}}`,
+	}
+	for name, spec := range invalid {
+		if _, err := ParseFileTypeConfigs(spec); err == nil {
+			t.Errorf("%s: expected an error for %s", name, spec)
+		}
+	}
+}
+
+func TestConfigForFile(t *testing.T) {
+	SetFileTypeConfigs(map[string]FileTypeConfig{
+		".txt": {Strategy: "chunk_overlap", Options: Options{"chunk_size": float64(1024), "overlap": float64(128)}},
+		".md":  {Strategy: "markdown_hierarchy"},
+	})
+	defer SetFileTypeConfigs(map[string]FileTypeConfig{})
+
+	config, ok := ConfigForFile("notes/Meeting.TXT")
+	if !ok || config.Strategy != "chunk_overlap" || config.Options.Merge(nil)["chunk_size"] != float64(1024) {
+		t.Errorf("Expected the configured strategy of .txt, got %+v, %v", config, ok)
+	}
+	if strategy, _ := StrategyForFile("README.md"); strategy != "markdown_hierarchy" {
+		t.Errorf("Expected the configured strategy to take precedence over the built-in one, got %q", strategy)
+	}
+	if strategy, _ := StrategyForFile("setup.rst"); strategy != "rst_sections" {
+		t.Errorf("Expected the built-in strategy of .rst, got %q", strategy)
+	}
+
+	// The options of a request take precedence over the default options
+	merged := Options{"overlap": float64(0)}.Merge(config.Options)
+	if !reflect.DeepEqual(merged, Options{"chunk_size": float64(1024), "overlap": float64(0)}) {
+		t.Errorf("Expected the request options merged with the defaults, got %v", merged)
+	}
+}
+
+func TestExtractSectionHeaders(t *testing.T) {
+	if header := ExtractRSTSectionHeader("Setup\n-----\nInstall it."); header != "Setup\n-----" {
+		t.Errorf("Expected the RST title with its underline, got %q", header)
+	}
+	if header := ExtractRSTSectionHeader("Intro text\nmore text"); header != "" {
+		t.Errorf("Expected no RST title, got %q", header)
+	}
+	// An underline shorter than the title is not an adornment
+	if sections := SplitRSTBySections("A long title\n--\ntext"); len(sections) != 1 {
+		t.Errorf("Expected a single section, got %q", sections)
+	}
+	if header := ExtractAsciiDocSectionHeader("== Setup\nInstall it."); header != "== Setup" {
+		t.Errorf("Expected the AsciiDoc title, got %q", header)
+	}
+	if header := ExtractAsciiDocSectionHeader("====\nExample block\n===="); header != "" {
+		t.Errorf("Expected no AsciiDoc title for a block delimiter, got %q", header)
+	}
+}
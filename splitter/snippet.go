@@ -0,0 +1,55 @@
+package splitter
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// snippetEllipsis marks the text cut before or after a snippet
+const snippetEllipsis = "…"
+
+// SnippetSentences returns the sentences of a chunk a snippet is made of (see SplitSentences), without the TITLE and
+// HIERARCHY lines of the chunks of ChunkWithMarkdownHierarchy. The sentences have at most size characters.
+func SnippetSentences(chunk string, size int) []string {
+	if _, _, ok := ParseHierarchyChunk(chunk); ok {
+		lines := strings.SplitN(chunk, "\n", 3)
+		chunk = ""
+		if len(lines) == 3 {
+			chunk = strings.TrimPrefix(lines[2], "CONTENT: ")
+		}
+	}
+	return SplitSentences(chunk, size)
+}
+
+// Snippet returns the text of the sentence at index center with as many of its neighbours as fit in size characters,
+// taken alternately after and before it. The text is trimmed, its line breaks are spaces, and an ellipsis marks each
+// end that is not an end of the sentences.
+func Snippet(sentences []string, center, size int) string {
+	if center < 0 || center >= len(sentences) {
+		return ""
+	}
+	first, last := center, center
+	length := utf8.RuneCountInString(sentences[center])
+	for grown := true; grown; {
+		grown = false
+		if last+1 < len(sentences) && length+utf8.RuneCountInString(sentences[last+1]) <= size {
+			last++
+			length += utf8.RuneCountInString(sentences[last])
+			grown = true
+		}
+		if first > 0 && length+utf8.RuneCountInString(sentences[first-1]) <= size {
+			first--
+			length += utf8.RuneCountInString(sentences[first])
+			grown = true
+		}
+	}
+
+	snippet := strings.Join(strings.Fields(strings.Join(sentences[first:last+1], "")), " ")
+	if first > 0 {
+		snippet = snippetEllipsis + snippet
+	}
+	if last < len(sentences)-1 {
+		snippet += snippetEllipsis
+	}
+	return snippet
+}
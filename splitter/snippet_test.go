@@ -0,0 +1,39 @@
+package splitter
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSnippet(t *testing.T) {
+	sentences := []string{"Birds fly. ", "Birds sing at dawn. ", "Squirrels store nuts. ", "Frogs swim. ", "Frogs jump."}
+	tests := []struct {
+		name     string
+		center   int
+		size     int
+		expected string
+	}{
+		{name: "Centered sentence with its neighbours", center: 2, size: 45, expected: "…Squirrels store nuts. Frogs swim. Frogs jump."},
+		{name: "Neighbours before and after", center: 2, size: 60, expected: "…Birds sing at dawn. Squirrels store nuts. Frogs swim.…"},
+		{name: "Sentence alone", center: 2, size: 10, expected: "…Squirrels store nuts.…"},
+		{name: "Whole text", center: 0, size: 1000, expected: "Birds fly. Birds sing at dawn. Squirrels store nuts. Frogs swim. Frogs jump."},
+		{name: "Out of range", center: 5, size: 100, expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if snippet := Snippet(sentences, tt.center, tt.size); snippet != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, snippet)
+			}
+		})
+	}
+}
+
+func TestSnippetSentences(t *testing.T) {
+	// The title and the hierarchy of a markdown hierarchy chunk are not part of the snippets
+	sentences := SnippetSentences("TITLE: ## Nuts\nHIERARCHY: Squirrels > Nuts\nCONTENT: Squirrels store nuts. They hide them.", 0)
+	expected := []string{"Squirrels store nuts. ", "They hide them."}
+	if !reflect.DeepEqual(sentences, expected) {
+		t.Errorf("Expected %q, got %q", expected, sentences)
+	}
+}
@@ -0,0 +1,58 @@
+package splitter
+
+import (
+	"regexp"
+	"strings"
+)
+
+// asciiDocHeaderRegex matches an AsciiDoc section title (= Document title, == Section, === Subsection, etc.)
+var asciiDocHeaderRegex = regexp.MustCompile(`^=+\s+\S`)
+
+// asciiDocDelimiterRegex matches the delimiter lines of the AsciiDoc blocks (listing, literal, example, sidebar,
+// quote, passthrough and comment blocks), whose content cannot contain section titles
+var asciiDocDelimiterRegex = regexp.MustCompile(`^(-{4,}|\.{4,}|={4,}|\*{4,}|_{4,}|\+{4,}|/{4,})$`)
+
+// SplitAsciiDocBySections splits AsciiDoc content by section titles (=, ==, === etc. at the start of a line,
+// outside of the delimited blocks)
+// Returns a slice where each element contains a section starting with its title
+func SplitAsciiDocBySections(asciiDoc string) []string {
+	if strings.TrimSpace(asciiDoc) == "" {
+		return []string{}
+	}
+
+	var sections []string
+	var current []string
+	blockDelimiter := ""
+	for _, line := range strings.Split(asciiDoc, "\n") {
+		trimmed := strings.TrimRight(line, " \t")
+		switch {
+		case blockDelimiter != "":
+			// Inside a block, until its closing delimiter
+			if trimmed == blockDelimiter {
+				blockDelimiter = ""
+			}
+		case asciiDocDelimiterRegex.MatchString(trimmed):
+			blockDelimiter = trimmed
+		case asciiDocHeaderRegex.MatchString(line):
+			if section := strings.TrimSpace(strings.Join(current, "\n")); section != "" {
+				sections = append(sections, section)
+			}
+			current = nil
+		}
+		current = append(current, line)
+	}
+	if section := strings.TrimSpace(strings.Join(current, "\n")); section != "" {
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// ExtractAsciiDocSectionHeader extracts the title line of an AsciiDoc section
+// Returns the title line (e.g., "== Setup") or empty string if the section does not start with a title
+func ExtractAsciiDocSectionHeader(section string) string {
+	firstLine, _, _ := strings.Cut(section, "\n")
+	if asciiDocHeaderRegex.MatchString(firstLine) {
+		return strings.TrimSpace(firstLine)
+	}
+	return ""
+}
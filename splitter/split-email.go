@@ -0,0 +1,352 @@
+package splitter
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// EmailMessage is a message of an email archive, with the headers used to filter and thread the messages
+type EmailMessage struct {
+	From      string   // address of the sender
+	FromName  string   // display name of the sender (may be empty)
+	To        []string // addresses of the recipients (To and Cc)
+	Date      time.Time
+	Subject   string
+	MessageID string
+	ThreadID  string // Message-ID of the first message of the thread
+	Body      string // text body, without the quoted replies and the signature
+}
+
+// mboxSeparator matches the "From " lines separating the messages of an mbox archive
+var mboxSeparator = regexp.MustCompile(`(?m)^From .*\n`)
+
+// mboxQuotedFrom matches the body lines starting with "From " escaped by the mbox format (">From ", ">>From "...)
+var mboxQuotedFrom = regexp.MustCompile(`(?m)^>(>*From )`)
+
+// ParseEmailArchive parses an email archive: a single message (.eml) or an mbox archive of messages
+// separated by "From " lines. The quoted replies and the signatures are removed from the message bodies.
+func ParseEmailArchive(archive string) ([]EmailMessage, error) {
+	archive = strings.ReplaceAll(archive, "\r\n", "\n")
+	if strings.TrimSpace(archive) == "" {
+		return []EmailMessage{}, nil
+	}
+
+	rawMessages := []string{archive}
+	if strings.HasPrefix(archive, "From ") {
+		rawMessages = nil
+		for _, raw := range mboxSeparator.Split(archive, -1) {
+			if strings.TrimSpace(raw) != "" {
+				rawMessages = append(rawMessages, mboxQuotedFrom.ReplaceAllString(raw, "$1"))
+			}
+		}
+	}
+
+	messages := make([]EmailMessage, 0, len(rawMessages))
+	for i, raw := range rawMessages {
+		message, err := ParseEmail(raw)
+		if err != nil {
+			return nil, fmt.Errorf("message %d: %w", i+1, err)
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// ParseEmail parses a single email message (RFC 5322), keeping its text body without quoted replies and signature
+func ParseEmail(raw string) (EmailMessage, error) {
+	msg, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return EmailMessage{}, fmt.Errorf("invalid email message: %w", err)
+	}
+
+	message := EmailMessage{Subject: decodeEmailHeader(msg.Header.Get("Subject"))}
+	if from, err := mail.ParseAddress(msg.Header.Get("From")); err == nil {
+		message.From = strings.ToLower(from.Address)
+		message.FromName = from.Name
+	} else {
+		message.From = decodeEmailHeader(msg.Header.Get("From"))
+	}
+	for _, name := range []string{"To", "Cc"} {
+		addresses, _ := msg.Header.AddressList(name)
+		for _, address := range addresses {
+			message.To = append(message.To, strings.ToLower(address.Address))
+		}
+	}
+	if date, err := msg.Header.Date(); err == nil {
+		message.Date = date
+	}
+
+	// The thread of a message is identified by its first reference: the first message of the thread
+	if ids := messageIDs(msg.Header.Get("Message-Id")); len(ids) > 0 {
+		message.MessageID = ids[0]
+	}
+	for _, header := range []string{"References", "In-Reply-To"} {
+		if ids := messageIDs(msg.Header.Get(header)); len(ids) > 0 {
+			message.ThreadID = ids[0]
+			break
+		}
+	}
+	if message.ThreadID == "" {
+		message.ThreadID = message.MessageID
+	}
+
+	body, err := emailTextBody(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body)
+	if err != nil {
+		return EmailMessage{}, err
+	}
+	message.Body = StripEmailSignature(StripQuotedReply(body))
+	return message, nil
+}
+
+// decodeEmailHeader decodes the encoded words (RFC 2047) of a header value
+func decodeEmailHeader(value string) string {
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(value); err == nil {
+		value = decoded
+	}
+	return strings.TrimSpace(value)
+}
+
+// messageIDPattern matches the message IDs of the Message-ID, In-Reply-To and References headers
+var messageIDPattern = regexp.MustCompile(`<([^<>\s]+)>`)
+
+// messageIDs returns the message IDs of a header, without their angle brackets
+func messageIDs(value string) []string {
+	var ids []string
+	for _, match := range messageIDPattern.FindAllStringSubmatch(value, -1) {
+		ids = append(ids, match[1])
+	}
+	if len(ids) == 0 && strings.TrimSpace(value) != "" {
+		ids = append(ids, strings.TrimSpace(value))
+	}
+	return ids
+}
+
+// emailTextBody returns the text of a message body: the text/plain part of a multipart body is preferred,
+// a text/html part is converted to text when there is no text/plain part
+func emailTextBody(contentType, transferEncoding string, body io.Reader) (string, error) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = "text/plain"
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		reader := multipart.NewReader(body, params["boundary"])
+		var htmlText string
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return "", fmt.Errorf("invalid multipart body: %w", err)
+			}
+			partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type"))
+			if partType == "" {
+				partType = "text/plain"
+			}
+			if strings.HasPrefix(part.Header.Get("Content-Disposition"), "attachment") {
+				continue
+			}
+			if partType != "text/plain" && partType != "text/html" && !strings.HasPrefix(partType, "multipart/") {
+				continue
+			}
+			text, err := emailTextBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part)
+			if err != nil {
+				return "", err
+			}
+			if partType == "text/html" {
+				if htmlText == "" {
+					htmlText = text
+				}
+				continue
+			}
+			if strings.TrimSpace(text) != "" {
+				return text, nil
+			}
+		}
+		return htmlText, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(transferEncoding)) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+	content, err := io.ReadAll(body)
+	if err != nil {
+		return "", fmt.Errorf("invalid %s body: %w", transferEncoding, err)
+	}
+
+	text := decodeCharset(content, params["charset"])
+	if mediaType == "text/html" {
+		text = htmlToText(text)
+	}
+	return strings.ReplaceAll(text, "\r\n", "\n"), nil
+}
+
+// decodeCharset converts a body to UTF-8 (the Latin-1 charsets are converted, the others are kept as is)
+func decodeCharset(content []byte, charset string) string {
+	switch strings.ToLower(charset) {
+	case "iso-8859-1", "latin1", "windows-1252":
+		runes := make([]rune, len(content))
+		for i, b := range content {
+			runes[i] = rune(b)
+		}
+		return string(runes)
+	default:
+		return string(content)
+	}
+}
+
+var (
+	htmlBreaks = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>|</li>|</tr>|</h[1-6]>`)
+	htmlHidden = regexp.MustCompile(`(?is)<(script|style|head)[^>]*>.*?</(script|style|head)>`)
+	htmlQuotes = regexp.MustCompile(`(?is)<blockquote[^>]*>.*?</blockquote>`)
+	htmlTags   = regexp.MustCompile(`(?s)<[^>]*>`)
+)
+
+// htmlToText converts an HTML body to text: the quoted replies (blockquotes) are removed and the line breaks kept
+func htmlToText(body string) string {
+	body = htmlHidden.ReplaceAllString(body, "")
+	body = htmlQuotes.ReplaceAllString(body, "")
+	body = htmlBreaks.ReplaceAllString(body, "\n")
+	return html.UnescapeString(htmlTags.ReplaceAllString(body, ""))
+}
+
+// replyHeaderPatterns match the lines introducing a quoted reply chain
+var replyHeaderPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^On\s.+\swrote:$`),             // "On Mon, 1 Jan 2024, Alice <alice@example.com> wrote:"
+	regexp.MustCompile(`^Le\s.+\sa écrit\s?:$`),        // French clients
+	regexp.MustCompile(`^-+\s*Original Message\s*-+$`), // Outlook
+	regexp.MustCompile(`^_{10,}$`),                     // Outlook separator before the quoted headers
+}
+
+// StripQuotedReply removes the quoted reply chain of a message body: the lines quoted with ">"
+// and everything after a reply header ("On ... wrote:", "-----Original Message-----", quoted "From:" headers...)
+func StripQuotedReply(body string) string {
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+	kept := make([]string, 0, len(lines))
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, ">") {
+			continue
+		}
+		// "On ... wrote:" may be wrapped on two lines
+		if i+1 < len(lines) && strings.HasPrefix(trimmed, "On ") && !strings.HasSuffix(trimmed, "wrote:") &&
+			strings.HasSuffix(strings.TrimSpace(lines[i+1]), "wrote:") {
+			trimmed += " " + strings.TrimSpace(lines[i+1])
+		}
+		// Quoted headers without separator ("From: ..." followed by "Sent: ..." or "Date: ...")
+		if strings.HasPrefix(trimmed, "From:") && i+1 < len(lines) {
+			next := strings.TrimSpace(lines[i+1])
+			if strings.HasPrefix(next, "Sent:") || strings.HasPrefix(next, "Date:") {
+				break
+			}
+		}
+		if isReplyHeader(trimmed) {
+			break
+		}
+		kept = append(kept, line)
+	}
+	return strings.TrimSpace(strings.Join(kept, "\n"))
+}
+
+// isReplyHeader reports whether a line introduces a quoted reply chain
+func isReplyHeader(line string) bool {
+	for _, pattern := range replyHeaderPatterns {
+		if pattern.MatchString(line) {
+			return true
+		}
+	}
+	return false
+}
+
+// mobileSignaturePattern matches the signatures added by the mobile mail clients
+var mobileSignaturePattern = regexp.MustCompile(`^(Sent from my .+|Get Outlook for .+|Envoyé de mon .+)$`)
+
+// StripEmailSignature removes the signature of a message body: everything after the "-- " signature delimiter,
+// and the trailing signature of the mobile mail clients ("Sent from my iPhone")
+func StripEmailSignature(body string) string {
+	lines := strings.Split(body, "\n")
+	for i, line := range lines {
+		if line == "-- " || line == "--" {
+			lines = lines[:i]
+			break
+		}
+	}
+	for len(lines) > 0 {
+		last := strings.TrimSpace(lines[len(lines)-1])
+		if last != "" && !mobileSignaturePattern.MatchString(last) {
+			break
+		}
+		lines = lines[:len(lines)-1]
+	}
+	return strings.TrimSpace(strings.Join(lines, "\n"))
+}
+
+// EmailHeader returns the header of the chunks of a message: its subject, sender and date
+func EmailHeader(message EmailMessage) string {
+	lines := []string{}
+	if message.Subject != "" {
+		lines = append(lines, "Subject: "+message.Subject)
+	}
+	from := message.From
+	if message.FromName != "" {
+		from = fmt.Sprintf("%s <%s>", message.FromName, message.From)
+	}
+	if from != "" {
+		lines = append(lines, "From: "+from)
+	}
+	if !message.Date.IsZero() {
+		lines = append(lines, "Date: "+message.Date.Format(time.RFC1123Z))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ChunkEmail returns the chunks of a message: the message with its header, subdivided with the header repeated
+// when it exceeds maxTokens. A message without body (only quoted text) has no chunk.
+func ChunkEmail(message EmailMessage, maxTokens int) []string {
+	if message.Body == "" {
+		return []string{}
+	}
+	header := EmailHeader(message)
+	if header == "" {
+		return ChunkTextByTokens(message.Body, maxTokens)
+	}
+	return SubdivideWithHeader(header+"\n\n"+message.Body, header, maxTokens)
+}
+
+// EmailMetadata returns the metadata of the chunks of a message: from, to, date (RFC 3339) and timestamp
+// (Unix time, for range filters), subject, message_id and thread_id. The empty headers are left out.
+func EmailMetadata(message EmailMessage) map[string]any {
+	metadata := map[string]any{}
+	values := map[string]string{
+		"from":       message.From,
+		"subject":    message.Subject,
+		"message_id": message.MessageID,
+		"thread_id":  message.ThreadID,
+	}
+	for name, value := range values {
+		if value != "" {
+			metadata[name] = value
+		}
+	}
+	if len(message.To) > 0 {
+		metadata["to"] = message.To
+	}
+	if !message.Date.IsZero() {
+		metadata["date"] = message.Date.UTC().Format(time.RFC3339)
+		metadata["timestamp"] = message.Date.Unix()
+	}
+	return metadata
+}
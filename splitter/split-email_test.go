@@ -0,0 +1,194 @@
+package splitter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const testMbox = `From alice@example.com Mon Jan  1 10:00:00 2024
+From: Alice Martin <Alice@Example.com>
+To: bob@example.com
+Cc: carol@example.com
+Date: Mon, 1 Jan 2024 10:00:00 +0000
+Subject: Release planning
+Message-ID: <first@example.com>
+
+Can we ship the release on Friday?
+>From the last meeting, the tests are green.
+
+--
+Alice Martin
+Release manager
+
+From bob@example.com Mon Jan  1 11:00:00 2024
+From: bob@example.com
+To: alice@example.com
+Date: Mon, 1 Jan 2024 11:00:00 +0000
+Subject: =?UTF-8?Q?Re:_Release_planning_=E2=9C=94?=
+Message-ID: <second@example.com>
+In-Reply-To: <first@example.com>
+References: <first@example.com>
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+Friday works for me, the changelog is r=C3=A9vis=C3=A9.
+
+Sent from my iPhone
+
+On Mon, 1 Jan 2024 at 10:00, Alice Martin <alice@example.com> wrote:
+> Can we ship the release on Friday?
+`
+
+func TestParseEmailArchive(t *testing.T) {
+	messages, err := ParseEmailArchive(testMbox)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(messages) != 2 {
+		t.Fatalf("Expected 2 messages, got %d", len(messages))
+	}
+
+	first, second := messages[0], messages[1]
+	if first.From != "alice@example.com" || first.FromName != "Alice Martin" {
+		t.Errorf("Unexpected sender: %q %q", first.From, first.FromName)
+	}
+	if !reflect.DeepEqual(first.To, []string{"bob@example.com", "carol@example.com"}) {
+		t.Errorf("Expected To and Cc recipients, got %v", first.To)
+	}
+	if first.MessageID != "first@example.com" || first.ThreadID != "first@example.com" {
+		t.Errorf("Expected the first message to start its thread, got %q and %q", first.MessageID, first.ThreadID)
+	}
+	if first.Body != "Can we ship the release on Friday?\nFrom the last meeting, the tests are green." {
+		t.Errorf("Expected the body without signature and with the mbox escaping removed, got %q", first.Body)
+	}
+
+	if second.Subject != "Re: Release planning ✔" {
+		t.Errorf("Expected the decoded subject, got %q", second.Subject)
+	}
+	if second.ThreadID != "first@example.com" {
+		t.Errorf("Expected the reply in the thread of the first message, got %q", second.ThreadID)
+	}
+	if second.Body != "Friday works for me, the changelog is révisé." {
+		t.Errorf("Expected the reply without quotes and signature, got %q", second.Body)
+	}
+	if second.Date.Hour() != 11 {
+		t.Errorf("Expected the date of the message, got %v", second.Date)
+	}
+}
+
+func TestParseEmail_Multipart(t *testing.T) {
+	raw := "From: alice@example.com\r\n" +
+		"Subject: Photos\r\n" +
+		"Content-Type: multipart/mixed; boundary=outer\r\n\r\n" +
+		"--outer\r\n" +
+		"Content-Type: multipart/alternative; boundary=inner\r\n\r\n" +
+		"--inner\r\n" +
+		"Content-Type: text/html\r\n\r\n" +
+		"<p>The photos of the <b>trip</b></p><blockquote>old message</blockquote>\r\n" +
+		"--inner--\r\n" +
+		"--outer\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"Content-Disposition: attachment; filename=notes.txt\r\n\r\n" +
+		"attached notes\r\n" +
+		"--outer--\r\n"
+
+	message, err := ParseEmail(raw)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if message.Body != "The photos of the trip" {
+		t.Errorf("Expected the text of the HTML part without quotes and attachment, got %q", message.Body)
+	}
+}
+
+func TestStripQuotedReply(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		expected string
+	}{
+		{
+			name:     "Quoted lines",
+			body:     "Sounds good.\n> previous message\n>> older message",
+			expected: "Sounds good.",
+		},
+		{
+			name:     "Wrapped reply header",
+			body:     "Agreed.\n\nOn Mon, 1 Jan 2024 at 10:00, Alice Martin\n<alice@example.com> wrote:\nprevious message",
+			expected: "Agreed.",
+		},
+		{
+			name:     "Outlook original message",
+			body:     "See below.\n\n-----Original Message-----\nFrom: Bob\nprevious message",
+			expected: "See below.",
+		},
+		{
+			name:     "Outlook quoted headers",
+			body:     "Thanks!\n\nFrom: Bob <bob@example.com>\nSent: Monday, January 1, 2024\nprevious message",
+			expected: "Thanks!",
+		},
+		{
+			name:     "No reply",
+			body:     "From: the release notes, the tests pass.",
+			expected: "From: the release notes, the tests pass.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if result := StripQuotedReply(tt.body); result != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, result)
+			}
+		})
+	}
+}
+
+func TestChunkEmail(t *testing.T) {
+	messages, err := ParseEmailArchive(testMbox)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	chunks := ChunkEmail(messages[0], 1000)
+	if len(chunks) != 1 || !strings.HasPrefix(chunks[0], "Subject: Release planning\nFrom: Alice Martin <alice@example.com>\nDate: ") {
+		t.Errorf("Expected a chunk starting with the message header, got %q", chunks)
+	}
+
+	messages[0].Body = strings.Repeat("A long paragraph about the release. ", 100)
+	chunks = ChunkEmail(messages[0], 200)
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the long message to be subdivided, got %d chunks", len(chunks))
+	}
+	for _, chunk := range chunks {
+		if !strings.HasPrefix(chunk, "Subject: Release planning") {
+			t.Errorf("Expected every sub-chunk to start with the header, got %q", chunk[:40])
+		}
+	}
+
+	messages[0].Body = ""
+	if chunks := ChunkEmail(messages[0], 200); len(chunks) != 0 {
+		t.Errorf("Expected no chunk for an empty message, got %v", chunks)
+	}
+}
+
+func TestEmailMetadata(t *testing.T) {
+	messages, err := ParseEmailArchive(testMbox)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	metadata := EmailMetadata(messages[1])
+	expected := map[string]any{
+		"from":       "bob@example.com",
+		"to":         []string{"alice@example.com"},
+		"subject":    "Re: Release planning ✔",
+		"message_id": "second@example.com",
+		"thread_id":  "first@example.com",
+		"date":       "2024-01-01T11:00:00Z",
+		"timestamp":  int64(1704106800),
+	}
+	if !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("Expected %v, got %v", expected, metadata)
+	}
+}
@@ -37,7 +37,10 @@ func SplitMarkdownBySections(markdown string) []string {
 	headerMatches := headerRegex.FindAllStringIndex(markdown, -1)
 
 	if len(headerMatches) == 0 {
-		// No headers found, return the entire content as one section
+		// No headers found, return the entire content as one section (no section for blank content)
+		if strings.TrimSpace(markdown) == "" {
+			return []string{}
+		}
 		return []string{strings.TrimSpace(markdown)}
 	}
 
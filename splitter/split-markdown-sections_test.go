@@ -0,0 +1,31 @@
+package splitter
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func FuzzSplitMarkdownBySections(f *testing.F) {
+	f.Add("# Title\nIntro\n## Section\nText")
+	f.Add("Before the first header\n\n# Header\n")
+	f.Add("  #   Indented header\n#no header\n")
+	f.Add("#\n##\t\n### \xff")
+	f.Fuzz(func(t *testing.T, markdown string) {
+		sections := SplitMarkdownBySections(markdown)
+		for i, section := range sections {
+			if section == "" || section != strings.TrimSpace(section) {
+				t.Errorf("Section %d is empty or not trimmed: %q", i, section)
+			}
+			if !strings.Contains(markdown, section) {
+				t.Errorf("Section %d is not part of the document: %q", i, section)
+			}
+			if header := ExtractSectionHeader(section); header != "" && !strings.Contains(section, header) {
+				t.Errorf("Header %q is not part of section %d", header, i)
+			}
+		}
+		if utf8.ValidString(markdown) && strings.TrimSpace(markdown) != "" && len(sections) == 0 {
+			t.Errorf("Expected sections for a non empty document")
+		}
+	})
+}
@@ -112,4 +112,17 @@ func ChunkWithMarkdownHierarchy(content string) []string {
 		chunks = append(chunks, chunkContent)
 	}
 	return chunks
-}
\ No newline at end of file
+}
+
+// ParseHierarchyChunk returns the title (without the markdown prefix) and the hierarchy of a chunk
+// produced by ChunkWithMarkdownHierarchy, e.g. "TLS" and "Manual > Setup > TLS".
+// ok is false when the chunk does not start with the TITLE and HIERARCHY lines.
+func ParseHierarchyChunk(chunk string) (title string, hierarchy string, ok bool) {
+	lines := strings.SplitN(chunk, "\n", 3)
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "TITLE: ") || !strings.HasPrefix(lines[1], "HIERARCHY: ") {
+		return "", "", false
+	}
+	title = strings.TrimSpace(strings.TrimLeft(strings.TrimPrefix(lines[0], "TITLE: "), "#"))
+	hierarchy = strings.TrimSpace(strings.TrimPrefix(lines[1], "HIERARCHY: "))
+	return title, hierarchy, true
+}
@@ -3,6 +3,7 @@ package splitter
 import (
 	"strings"
 	"testing"
+	"unicode/utf8"
 )
 
 func TestParseMarkdownHierarchy(t *testing.T) {
@@ -349,3 +350,61 @@ func TestMarkdownChunkStruct(t *testing.T) {
 		t.Errorf("Expected Hierarchy 'Parent > Test Header', got %s", chunk.Hierarchy)
 	}
 }
+
+func TestParseHierarchyChunk(t *testing.T) {
+	markdown := `# Manual
+## Setup
+### TLS
+Use a certificate.`
+
+	chunks := ChunkWithMarkdownHierarchy(markdown)
+	if len(chunks) != 3 {
+		t.Fatalf("Expected 3 chunks, got %d", len(chunks))
+	}
+
+	title, hierarchy, ok := ParseHierarchyChunk(chunks[2])
+	if !ok {
+		t.Fatalf("Expected a hierarchy chunk: %s", chunks[2])
+	}
+	if title != "TLS" {
+		t.Errorf("Expected title 'TLS', got '%s'", title)
+	}
+	if hierarchy != "Manual > Setup > TLS" {
+		t.Errorf("Expected hierarchy 'Manual > Setup > TLS', got '%s'", hierarchy)
+	}
+
+	if _, _, ok := ParseHierarchyChunk("Squirrels run in the forest"); ok {
+		t.Error("Expected a plain chunk not to be a hierarchy chunk")
+	}
+}
+
+func FuzzChunkWithMarkdownHierarchy(f *testing.F) {
+	f.Add("# Manual\nIntro\n## Setup\nSteps\n### TLS\nCertificates")
+	f.Add("## Orphan\n# Root\n### Deep\ntext")
+	f.Add("# #hash\n#\tTab\n#no header")
+	f.Add("# Title\r\nWindows line\r\n## Sub \xff\n")
+	f.Fuzz(func(t *testing.T, markdown string) {
+		chunks := ChunkWithMarkdownHierarchy(markdown)
+		if len(chunks) != len(ParseMarkdownHierarchy(markdown)) {
+			t.Fatalf("Expected a chunk per header")
+		}
+		for i, chunk := range chunks {
+			title, hierarchy, ok := ParseHierarchyChunk(chunk)
+			if !ok {
+				t.Fatalf("Chunk %d cannot be parsed: %q", i, chunk)
+			}
+			if !strings.HasSuffix(hierarchy, title) {
+				t.Errorf("Chunk %d: hierarchy %q does not end with the title %q", i, hierarchy, title)
+			}
+		}
+		if !utf8.ValidString(markdown) {
+			return
+		}
+		runes := []rune(markdown)
+		for i, chunk := range LocateChunks(markdown, chunks) {
+			if chunk.Start >= 0 && string(runes[chunk.Start:chunk.End]) != chunk.Text {
+				t.Errorf("Chunk %d: offsets [%d, %d[ do not match the text %q", i, chunk.Start, chunk.End, chunk.Text)
+			}
+		}
+	})
+}
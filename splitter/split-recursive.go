@@ -0,0 +1,101 @@
+package splitter
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultSeparators are the boundaries tried by RecursiveSplit, from the coarsest to the finest:
+// paragraphs, lines, sentences, words, and finally characters ("")
+var DefaultSeparators = []string{"\n\n", "\n", ". ", "! ", "? ", " ", ""}
+
+// RecursiveSplit splits a text into chunks of at most maxSize characters (runes, as ChunkText), cutting at the
+// coarsest boundary possible: the text is split with the first separator it contains, and the pieces still larger
+// than maxSize are split again with the next separators. The pieces are then merged back into chunks, the
+// consecutive chunks sharing up to overlap characters of whole pieces.
+// The separators are kept at the end of the pieces; "" cuts between characters (none: DefaultSeparators).
+func RecursiveSplit(text string, maxSize, overlap int, separators []string) []string {
+	if len(separators) == 0 {
+		separators = DefaultSeparators
+	}
+	chunks := []string{}
+	for _, chunk := range recursiveSplit(text, maxSize, overlap, separators) {
+		if chunk = strings.TrimSpace(chunk); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+	}
+	return chunks
+}
+
+// recursiveSplit splits a text with the first separator it contains, splitting the larger pieces with the next separators
+func recursiveSplit(text string, maxSize, overlap int, separators []string) []string {
+	if utf8.RuneCountInString(text) <= maxSize {
+		return []string{text}
+	}
+
+	separator, next := "", []string(nil)
+	for i, candidate := range separators {
+		if candidate == "" || strings.Contains(text, candidate) {
+			separator, next = candidate, separators[i+1:]
+			break
+		}
+	}
+
+	chunks := []string{}
+	small := []string{}
+	for _, piece := range splitAfterSeparator(text, separator) {
+		if utf8.RuneCountInString(piece) <= maxSize {
+			small = append(small, piece)
+			continue
+		}
+		chunks = append(chunks, mergePieces(small, maxSize, overlap)...)
+		small = small[:0]
+		if separator == "" {
+			// A single character, larger than a maxSize of 0
+			chunks = append(chunks, piece)
+		} else if len(next) == 0 {
+			chunks = append(chunks, recursiveSplit(piece, maxSize, overlap, []string{""})...)
+		} else {
+			chunks = append(chunks, recursiveSplit(piece, maxSize, overlap, next)...)
+		}
+	}
+	return append(chunks, mergePieces(small, maxSize, overlap)...)
+}
+
+// splitAfterSeparator splits a text after each occurrence of a separator, or between its characters when the separator is empty
+func splitAfterSeparator(text, separator string) []string {
+	if separator != "" {
+		return strings.SplitAfter(text, separator)
+	}
+	pieces := make([]string, 0, utf8.RuneCountInString(text))
+	for len(text) > 0 {
+		_, size := utf8.DecodeRuneInString(text)
+		pieces = append(pieces, text[:size])
+		text = text[size:]
+	}
+	return pieces
+}
+
+// mergePieces joins consecutive pieces into chunks of at most maxSize characters. A new chunk starts with the last
+// pieces of the previous chunk, up to overlap characters.
+func mergePieces(pieces []string, maxSize, overlap int) []string {
+	chunks := []string{}
+	current := []string{}
+	size := 0
+	for _, piece := range pieces {
+		pieceSize := utf8.RuneCountInString(piece)
+		if size+pieceSize > maxSize && len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, ""))
+			for len(current) > 0 && (size > overlap || size+pieceSize > maxSize) {
+				size -= utf8.RuneCountInString(current[0])
+				current = current[1:]
+			}
+		}
+		current = append(current, piece)
+		size += pieceSize
+	}
+	if len(current) > 0 {
+		chunks = append(chunks, strings.Join(current, ""))
+	}
+	return chunks
+}
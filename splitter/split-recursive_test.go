@@ -0,0 +1,103 @@
+package splitter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestRecursiveSplit(t *testing.T) {
+	tests := []struct {
+		name       string
+		text       string
+		maxSize    int
+		overlap    int
+		separators []string
+		expected   []string
+	}{
+		{
+			name:     "Short text",
+			text:     "Frogs swim in the pond",
+			maxSize:  100,
+			expected: []string{"Frogs swim in the pond"},
+		},
+		{
+			name:     "Paragraphs",
+			text:     "Frogs swim in the pond.\n\nBirds fly in the sky.",
+			maxSize:  30,
+			expected: []string{"Frogs swim in the pond.", "Birds fly in the sky."},
+		},
+		{
+			name:     "Paragraphs merged up to the size",
+			text:     "Frogs swim.\n\nBirds fly.\n\nFishes swim in the sea.",
+			maxSize:  30,
+			expected: []string{"Frogs swim.\n\nBirds fly.", "Fishes swim in the sea."},
+		},
+		{
+			name:     "Sentences of a long paragraph",
+			text:     "Frogs swim in the pond. Birds fly in the sky. Fishes swim.",
+			maxSize:  30,
+			expected: []string{"Frogs swim in the pond.", "Birds fly in the sky.", "Fishes swim."},
+		},
+		{
+			name:     "Words of a long sentence",
+			text:     "Squirrels run in the forest",
+			maxSize:  12,
+			expected: []string{"Squirrels", "run in the", "forest"},
+		},
+		{
+			name:     "Characters of a long word",
+			text:     "abcdefgh",
+			maxSize:  3,
+			expected: []string{"abc", "def", "gh"},
+		},
+		{
+			name:     "Overlap of whole words",
+			text:     "one two three four five",
+			maxSize:  14,
+			overlap:  6,
+			expected: []string{"one two three", "three four", "four five"},
+		},
+		{
+			name:     "Multi-byte characters",
+			text:     "Élan été",
+			maxSize:  4,
+			expected: []string{"Élan", "été"},
+		},
+		{
+			name:       "Custom separators, then characters",
+			text:       "a|b|cdefg",
+			maxSize:    3,
+			separators: []string{"|"},
+			expected:   []string{"a|", "b|", "cde", "fg"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks := RecursiveSplit(tt.text, tt.maxSize, tt.overlap, tt.separators)
+			if !reflect.DeepEqual(chunks, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, chunks)
+			}
+		})
+	}
+}
+
+func TestRecursiveSplit_Sizes(t *testing.T) {
+	text := strings.Repeat("Goblins live in the caves of the north. Dragons sleep on their gold.\n\n", 30)
+
+	chunks := RecursiveSplit(text, 100, 20, nil)
+
+	if len(chunks) < 2 {
+		t.Fatalf("Expected the text to be subdivided, got %d chunk(s)", len(chunks))
+	}
+	for i, chunk := range chunks {
+		if size := utf8.RuneCountInString(chunk); size > 100 {
+			t.Errorf("Chunk %d has %d characters, above the limit of 100", i, size)
+		}
+		if !strings.HasSuffix(chunk, ".") {
+			t.Errorf("Chunk %d does not end at a sentence boundary: %q", i, chunk)
+		}
+	}
+}
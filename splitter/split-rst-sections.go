@@ -0,0 +1,74 @@
+package splitter
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// rstAdornmentCharacters are the characters of the reStructuredText section title adornments
+const rstAdornmentCharacters = "=-`:'\"~^_*+#<>."
+
+// isRSTAdornment reports whether a line is a section title adornment (a line of a repeated punctuation character)
+func isRSTAdornment(line string) bool {
+	line = strings.TrimRight(line, " \t")
+	if len(line) < 2 || !strings.ContainsRune(rstAdornmentCharacters, rune(line[0])) {
+		return false
+	}
+	return strings.Count(line, line[:1]) == len(line)
+}
+
+// isRSTTitle reports whether a line can be a section title: not indented, not blank and not an adornment
+func isRSTTitle(line string) bool {
+	return strings.TrimSpace(line) != "" && !strings.HasPrefix(line, " ") && !strings.HasPrefix(line, "\t") && !isRSTAdornment(line)
+}
+
+// rstTitleAt returns the number of lines of the section title starting at line i
+// (2 for an underlined title, 3 for an overlined and underlined title, 0 when there is no title)
+func rstTitleAt(lines []string, i int) int {
+	if i+2 < len(lines) && isRSTAdornment(lines[i]) && isRSTTitle(lines[i+1]) &&
+		strings.TrimRight(lines[i+2], " \t") == strings.TrimRight(lines[i], " \t") {
+		return 3
+	}
+	if i+1 < len(lines) && isRSTTitle(lines[i]) && isRSTAdornment(lines[i+1]) &&
+		utf8.RuneCountInString(strings.TrimRight(lines[i+1], " \t")) >= utf8.RuneCountInString(strings.TrimSpace(lines[i])) {
+		return 2
+	}
+	return 0
+}
+
+// SplitRSTBySections splits reStructuredText content by section titles (underlined, or overlined and underlined)
+// Returns a slice where each element contains a section starting with its title
+func SplitRSTBySections(rst string) []string {
+	if strings.TrimSpace(rst) == "" {
+		return []string{}
+	}
+
+	lines := strings.Split(rst, "\n")
+	var sections []string
+	var current []string
+	for i := 0; i < len(lines); i++ {
+		if n := rstTitleAt(lines, i); n > 0 {
+			if section := strings.TrimSpace(strings.Join(current, "\n")); section != "" {
+				sections = append(sections, section)
+			}
+			current = append([]string{}, lines[i:i+n]...)
+			i += n - 1
+			continue
+		}
+		current = append(current, lines[i])
+	}
+	if section := strings.TrimSpace(strings.Join(current, "\n")); section != "" {
+		sections = append(sections, section)
+	}
+	return sections
+}
+
+// ExtractRSTSectionHeader extracts the title of a reStructuredText section with its adornments
+// Returns the title lines (e.g., "Setup\n=====") or empty string if the section does not start with a title
+func ExtractRSTSectionHeader(section string) string {
+	lines := strings.Split(section, "\n")
+	if n := rstTitleAt(lines, 0); n > 0 {
+		return strings.Join(lines[:n], "\n")
+	}
+	return ""
+}
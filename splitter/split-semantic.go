@@ -0,0 +1,101 @@
+package splitter
+
+import (
+	"math"
+	"strings"
+	"unicode/utf8"
+)
+
+// DefaultSemanticThreshold is the default similarity below which SemanticMerge starts a new chunk
+const DefaultSemanticThreshold = 0.75
+
+// DefaultSemanticChunkSize is the default maximum size of the chunks of SemanticMerge, in characters
+const DefaultSemanticChunkSize = 2000
+
+// sentenceSeparators are the boundaries of the sentences of SplitSentences (lines, then sentence ends)
+var sentenceSeparators = []string{"\n", ". ", "! ", "? "}
+
+// SplitSentences splits a text into sentences, at line breaks and sentence ends. The separators and the spaces are kept
+// (the sentences joined together give the text back), a blank piece is appended to the previous sentence, and a
+// sentence longer than maxSize characters is split at word boundaries (see RecursiveSplit; 0: no limit).
+func SplitSentences(text string, maxSize int) []string {
+	pieces := []string{text}
+	for _, separator := range sentenceSeparators {
+		next := make([]string, 0, len(pieces))
+		for _, piece := range pieces {
+			next = append(next, strings.SplitAfter(piece, separator)...)
+		}
+		pieces = next
+	}
+
+	sentences := []string{}
+	for _, piece := range pieces {
+		if strings.TrimSpace(piece) == "" {
+			if len(sentences) > 0 {
+				sentences[len(sentences)-1] += piece
+			}
+			continue
+		}
+		if maxSize > 0 && utf8.RuneCountInString(piece) > maxSize {
+			sentences = append(sentences, recursiveSplit(piece, maxSize, 0, []string{" ", ""})...)
+			continue
+		}
+		sentences = append(sentences, piece)
+	}
+	return sentences
+}
+
+// SemanticMerge merges adjacent sentences into chunks of related content: a sentence is added to the running chunk
+// while its cosine similarity with the chunk (the mean of the vectors of its sentences) is at least threshold, and
+// while the chunk stays within maxSize characters (0: no limit). vectors are the embeddings of the sentences, in order.
+// The chunks are trimmed; the blank ones are dropped.
+func SemanticMerge(sentences []string, vectors [][]float32, threshold float64, maxSize int) []string {
+	chunks := []string{}
+	var current strings.Builder
+	var sum []float64
+	size := 0
+
+	flush := func() {
+		if chunk := strings.TrimSpace(current.String()); chunk != "" {
+			chunks = append(chunks, chunk)
+		}
+		current.Reset()
+		sum = nil
+		size = 0
+	}
+
+	for i, sentence := range sentences {
+		sentenceSize := utf8.RuneCountInString(sentence)
+		if sum != nil {
+			tooLarge := maxSize > 0 && size+sentenceSize > maxSize
+			if tooLarge || CosineSimilarity(sum, vectors[i]) < threshold {
+				flush()
+			}
+		}
+		current.WriteString(sentence)
+		size += sentenceSize
+		if sum == nil {
+			sum = make([]float64, len(vectors[i]))
+		}
+		// The sum has the direction of the mean of the vectors
+		for j := range min(len(sum), len(vectors[i])) {
+			sum[j] += float64(vectors[i][j])
+		}
+	}
+	flush()
+	return chunks
+}
+
+// CosineSimilarity returns the cosine similarity of two vectors (0 when one of them is null)
+func CosineSimilarity(a []float64, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range min(len(a), len(b)) {
+		dot += a[i] * float64(b[i])
+		normA += a[i] * a[i]
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
@@ -0,0 +1,60 @@
+package splitter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitSentences(t *testing.T) {
+	text := "Squirrels run. They eat nuts.\n\nBirds fly! Do fish swim? Yes"
+	sentences := SplitSentences(text, 0)
+	expected := []string{"Squirrels run. ", "They eat nuts.\n\n", "Birds fly! ", "Do fish swim? ", "Yes"}
+	if !reflect.DeepEqual(sentences, expected) {
+		t.Errorf("Expected %q, got %q", expected, sentences)
+	}
+	if joined := strings.Join(sentences, ""); joined != text {
+		t.Errorf("Expected the sentences to give the text back, got %q", joined)
+	}
+
+	// A long sentence is split at word boundaries
+	for _, sentence := range SplitSentences("Frogs swim in the green pond all day long.", 12) {
+		if utf8.RuneCountInString(sentence) > 12 {
+			t.Errorf("Expected sentences of at most 12 characters, got %q", sentence)
+		}
+	}
+
+	if sentences := SplitSentences("  \n ", 0); len(sentences) != 0 {
+		t.Errorf("Expected no sentence, got %q", sentences)
+	}
+}
+
+func TestSemanticMerge(t *testing.T) {
+	sentences := []string{"Squirrels run. ", "They eat nuts. ", "Birds fly. ", "Birds sing."}
+	vectors := [][]float32{{1, 0}, {0.9, 0.1}, {0, 1}, {0.1, 0.9}}
+
+	tests := []struct {
+		name      string
+		threshold float64
+		maxSize   int
+		expected  []string
+	}{
+		{name: "Topics", threshold: 0.75, expected: []string{"Squirrels run. They eat nuts.", "Birds fly. Birds sing."}},
+		{name: "Low threshold", threshold: -1, expected: []string{"Squirrels run. They eat nuts. Birds fly. Birds sing."}},
+		{name: "High threshold", threshold: 1, expected: []string{"Squirrels run.", "They eat nuts.", "Birds fly.", "Birds sing."}},
+		{name: "Max size", threshold: -1, maxSize: 30, expected: []string{"Squirrels run. They eat nuts.", "Birds fly. Birds sing."}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if chunks := SemanticMerge(sentences, vectors, tt.threshold, tt.maxSize); !reflect.DeepEqual(chunks, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, chunks)
+			}
+		})
+	}
+
+	if chunks := SemanticMerge(nil, nil, 0.75, 0); len(chunks) != 0 {
+		t.Errorf("Expected no chunk, got %q", chunks)
+	}
+}
@@ -0,0 +1,173 @@
+package splitter
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSubtitleWindow is the default duration of the time windows grouping the captions of a subtitle file
+const DefaultSubtitleWindow = 60 * time.Second
+
+// Caption is a cue of a subtitle file: its text and display interval
+type Caption struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string // text of the cue on a single line, without formatting tags
+}
+
+// SubtitleChunk is a group of consecutive captions, from the start of the first one to the end of the last one
+type SubtitleChunk struct {
+	Start time.Duration
+	End   time.Duration
+	Text  string
+}
+
+// cueSeparator matches the blank lines separating the cues of a subtitle file
+var cueSeparator = regexp.MustCompile(`\n[ \t]*\n`)
+
+// timestampPattern matches the timestamps of the SRT ("00:01:05,250") and WebVTT ("00:01:05.250", "01:05.250") cues
+var timestampPattern = regexp.MustCompile(`^(?:(\d+):)?(\d{1,2}):(\d{1,2})[,.](\d{1,3})$`)
+
+// voiceTagPattern matches the WebVTT voice tags ("<v Alice>", "<v.loud Alice>"), kept as "Alice: "
+var voiceTagPattern = regexp.MustCompile(`<v(?:\.[^\s>]*)?\s+([^>]+)>`)
+
+// subtitleTagPattern matches the formatting tags of the cues: HTML-like tags ("<i>", "<c.yellow>", "<00:01:05.250>")
+// and the SRT positioning tags ("{\an8}")
+var subtitleTagPattern = regexp.MustCompile(`<[^>]*>|\{\\[^}]*\}`)
+
+// parseTimestamp parses the timestamp of a cue
+func parseTimestamp(value string) (time.Duration, error) {
+	matches := timestampPattern.FindStringSubmatch(value)
+	if matches == nil {
+		return 0, fmt.Errorf("invalid timestamp %q", value)
+	}
+	hours, _ := strconv.Atoi("0" + matches[1])
+	minutes, _ := strconv.Atoi(matches[2])
+	seconds, _ := strconv.Atoi(matches[3])
+	milliseconds, _ := strconv.Atoi((matches[4] + "00")[:3])
+	return time.Duration(hours)*time.Hour + time.Duration(minutes)*time.Minute +
+		time.Duration(seconds)*time.Second + time.Duration(milliseconds)*time.Millisecond, nil
+}
+
+// FormatTimestamp formats a position in a video as "HH:MM:SS.mmm"
+func FormatTimestamp(position time.Duration) string {
+	milliseconds := position.Milliseconds()
+	return fmt.Sprintf("%02d:%02d:%02d.%03d", milliseconds/3600000, milliseconds/60000%60, milliseconds/1000%60, milliseconds%1000)
+}
+
+// captionText returns the text of a cue on a single line, without its formatting tags
+func captionText(lines []string) string {
+	text := strings.Join(lines, " ")
+	text = voiceTagPattern.ReplaceAllString(text, "$1: ")
+	text = subtitleTagPattern.ReplaceAllString(text, "")
+	return strings.Join(strings.Fields(html.UnescapeString(text)), " ")
+}
+
+// ParseSubtitles parses the cues of a subtitle file, SubRip (.srt) or WebVTT (.vtt).
+// The WebVTT header, notes, styles and regions are skipped, as well as the cues without text.
+func ParseSubtitles(document string) ([]Caption, error) {
+	document = strings.TrimPrefix(document, "\ufeff")
+	document = strings.ReplaceAll(document, "\r\n", "\n")
+
+	captions := []Caption{}
+	for _, block := range cueSeparator.Split(strings.TrimSpace(document), -1) {
+		lines := strings.Split(strings.TrimSpace(block), "\n")
+
+		// The timing line, after the optional cue identifier (the index of a SRT cue)
+		timing := -1
+		for i, line := range lines {
+			if strings.Contains(line, "-->") {
+				timing = i
+				break
+			}
+		}
+		if timing < 0 {
+			continue
+		}
+
+		start, rest, _ := strings.Cut(lines[timing], "-->")
+		end := strings.Fields(rest) // the end timestamp is followed by the WebVTT cue settings
+		if len(end) == 0 {
+			return nil, fmt.Errorf("invalid cue timing %q", lines[timing])
+		}
+		startTime, err := parseTimestamp(strings.TrimSpace(start))
+		if err != nil {
+			return nil, fmt.Errorf("invalid cue timing %q: %w", lines[timing], err)
+		}
+		endTime, err := parseTimestamp(end[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cue timing %q: %w", lines[timing], err)
+		}
+
+		if text := captionText(lines[timing+1:]); text != "" {
+			captions = append(captions, Caption{Start: startTime, End: max(startTime, endTime), Text: text})
+		}
+	}
+
+	if len(captions) == 0 {
+		return nil, fmt.Errorf("no caption found (expected SRT or WebVTT cues)")
+	}
+	return captions, nil
+}
+
+// SubtitleHeader returns the header of the chunks of a group of captions: its time interval
+func SubtitleHeader(chunk SubtitleChunk) string {
+	return fmt.Sprintf("[%s - %s]", FormatTimestamp(chunk.Start), FormatTimestamp(chunk.End))
+}
+
+// GroupCaptions groups consecutive captions into chunks covering time windows of the given duration:
+// a caption starting window after the start of the current chunk starts a new chunk.
+// A chunk is also closed early when the next caption would make it exceed maxTokens.
+func GroupCaptions(captions []Caption, window time.Duration, maxTokens int) []SubtitleChunk {
+	chunks := []SubtitleChunk{}
+	var current *SubtitleChunk
+	for _, caption := range captions {
+		if current != nil {
+			candidate := SubtitleChunk{Start: current.Start, End: max(current.End, caption.End), Text: current.Text + " " + caption.Text}
+			if caption.Start-current.Start < window && CountTokens(SubtitleHeader(candidate)+"\n"+candidate.Text) <= maxTokens {
+				*current = candidate
+				continue
+			}
+			chunks = append(chunks, *current)
+		}
+		current = &SubtitleChunk{Start: caption.Start, End: caption.End, Text: caption.Text}
+	}
+	if current != nil {
+		chunks = append(chunks, *current)
+	}
+	return chunks
+}
+
+// ChunkSubtitle returns the chunks of a group of captions: its text after its time interval,
+// subdivided with the time interval repeated when a single caption exceeds maxTokens
+func ChunkSubtitle(chunk SubtitleChunk, maxTokens int) []string {
+	header := SubtitleHeader(chunk)
+	return SubdivideWithHeader(header+"\n"+chunk.Text, header, maxTokens)
+}
+
+// SubtitleMetadata returns the metadata of the chunks of a group of captions: start and end ("HH:MM:SS.mmm"),
+// start_seconds and end_seconds (for range filters), and the url of the video at the start of the chunk when
+// videoURL is set. The "{start}" placeholder of videoURL is replaced by the start in seconds
+// (e.g. "https://youtu.be/abc?t={start}"), otherwise the media fragment "#t=<start>" is appended.
+func SubtitleMetadata(chunk SubtitleChunk, videoURL string) map[string]any {
+	metadata := map[string]any{
+		"start":         FormatTimestamp(chunk.Start),
+		"end":           FormatTimestamp(chunk.End),
+		"start_seconds": chunk.Start.Seconds(),
+		"end_seconds":   chunk.End.Seconds(),
+	}
+	if videoURL != "" {
+		start := strconv.Itoa(int(chunk.Start.Seconds()))
+		if strings.Contains(videoURL, "{start}") {
+			metadata["url"] = strings.ReplaceAll(videoURL, "{start}", start)
+		} else {
+			videoURL, _, _ = strings.Cut(videoURL, "#")
+			metadata["url"] = videoURL + "#t=" + start
+		}
+	}
+	return metadata
+}
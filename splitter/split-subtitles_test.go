@@ -0,0 +1,126 @@
+package splitter
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testSRT = "\ufeff1\r\n" +
+	"00:00:01,000 --> 00:00:04,500\r\n" +
+	"<i>Welcome</i> to the\r\n" +
+	"release review.\r\n" +
+	"\r\n" +
+	"2\r\n" +
+	"00:00:05,000 --> 00:00:09,000\r\n" +
+	"{\\an8}Tom &amp; Jerry joined.\r\n" +
+	"\r\n" +
+	"3\r\n" +
+	"00:01:10,250 --> 00:01:15,000\r\n" +
+	"Let's talk about the roadmap.\r\n"
+
+const testVTT = `WEBVTT - Release review
+
+NOTE This is a comment
+with two lines
+
+STYLE
+::cue { color: yellow }
+
+intro
+00:01.000 --> 00:04.500 align:start position:10%
+<v Alice>Welcome to the <c.highlight>release</c> review.
+
+00:05.000 --> 00:09.000
+
+01:02:03.040 --> 01:02:05.000
+<v.loud Bob>Questions?</v>
+`
+
+func TestParseSubtitles(t *testing.T) {
+	captions, err := ParseSubtitles(testSRT)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := []Caption{
+		{Start: time.Second, End: 4500 * time.Millisecond, Text: "Welcome to the release review."},
+		{Start: 5 * time.Second, End: 9 * time.Second, Text: "Tom & Jerry joined."},
+		{Start: 70250 * time.Millisecond, End: 75 * time.Second, Text: "Let's talk about the roadmap."},
+	}
+	if !reflect.DeepEqual(captions, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, captions)
+	}
+
+	captions, err = ParseSubtitles(testVTT)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected = []Caption{
+		{Start: time.Second, End: 4500 * time.Millisecond, Text: "Alice: Welcome to the release review."},
+		{Start: time.Hour + 2*time.Minute + 3040*time.Millisecond, End: time.Hour + 2*time.Minute + 5*time.Second, Text: "Bob: Questions?"},
+	}
+	if !reflect.DeepEqual(captions, expected) {
+		t.Errorf("Expected the cues with text, without notes and styles, got %+v", captions)
+	}
+}
+
+func TestParseSubtitles_Invalid(t *testing.T) {
+	if _, err := ParseSubtitles("Just a transcript without timings."); err == nil {
+		t.Error("Expected an error for a document without cues")
+	}
+	if _, err := ParseSubtitles("1\n00:00:01 --> 00:00:02\nHello"); err == nil {
+		t.Error("Expected an error for a timestamp without milliseconds")
+	}
+}
+
+func TestGroupCaptions(t *testing.T) {
+	captions, err := ParseSubtitles(testSRT)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	chunks := GroupCaptions(captions, 30*time.Second, 1000)
+	if len(chunks) != 2 {
+		t.Fatalf("Expected 2 time windows, got %+v", chunks)
+	}
+	if chunks[0].Start != time.Second || chunks[0].End != 9*time.Second || chunks[0].Text != "Welcome to the release review. Tom & Jerry joined." {
+		t.Errorf("Unexpected first chunk %+v", chunks[0])
+	}
+	if texts := ChunkSubtitle(chunks[1], 1000); len(texts) != 1 || texts[0] != "[00:01:10.250 - 00:01:15.000]\nLet's talk about the roadmap." {
+		t.Errorf("Expected the chunk text after its time interval, got %q", texts)
+	}
+
+	// The token limit closes a chunk before the end of its time window
+	if chunks := GroupCaptions(captions, time.Hour, 15); len(chunks) != 3 {
+		t.Errorf("Expected the token limit to split the window, got %+v", chunks)
+	}
+
+	long := SubtitleChunk{Start: 0, End: time.Minute, Text: strings.Repeat("A very long caption. ", 50)}
+	for _, chunk := range ChunkSubtitle(long, 50) {
+		if !strings.HasPrefix(chunk, "[00:00:00.000 - 00:01:00.000]") {
+			t.Errorf("Expected every sub-chunk to start with the time interval, got %q", chunk)
+		}
+	}
+}
+
+func TestSubtitleMetadata(t *testing.T) {
+	chunk := SubtitleChunk{Start: 70250 * time.Millisecond, End: 75 * time.Second}
+
+	expected := map[string]any{
+		"start":         "00:01:10.250",
+		"end":           "00:01:15.000",
+		"start_seconds": 70.25,
+		"end_seconds":   75.0,
+	}
+	if metadata := SubtitleMetadata(chunk, ""); !reflect.DeepEqual(metadata, expected) {
+		t.Errorf("Expected %v, got %v", expected, metadata)
+	}
+
+	if url := SubtitleMetadata(chunk, "https://videos.example.com/review.mp4#t=5")["url"]; url != "https://videos.example.com/review.mp4#t=70" {
+		t.Errorf("Expected a media fragment deep link, got %v", url)
+	}
+	if url := SubtitleMetadata(chunk, "https://youtu.be/abc?t={start}")["url"]; url != "https://youtu.be/abc?t=70" {
+		t.Errorf("Expected the placeholder to be replaced, got %v", url)
+	}
+}
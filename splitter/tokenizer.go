@@ -0,0 +1,133 @@
+package splitter
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Tokenizer counts the tokens of a text as the embedding model does, including the special tokens
+// the model adds to each input (e.g. [CLS] and [SEP] for the BERT models)
+type Tokenizer interface {
+	CountTokens(text string) int
+}
+
+// tokenizer is the tokenizer of the embedding model (nil: the tokens are estimated, see EstimateTokens)
+var tokenizer Tokenizer
+
+// SetTokenizer sets the tokenizer of the embedding model, used to size the chunks instead of the estimation.
+// It is set at startup, before the documents are split.
+func SetTokenizer(t Tokenizer) {
+	tokenizer = t
+}
+
+// CountTokens returns the number of tokens of a text for the embedding model: counted with the tokenizer
+// of the model when one is set, estimated otherwise
+func CountTokens(text string) int {
+	if tokenizer == nil {
+		return EstimateTokens(text)
+	}
+	return tokenizer.CountTokens(text)
+}
+
+// inputOverhead returns the number of special tokens added to each input by the tokenizer
+func inputOverhead() int {
+	if tokenizer == nil {
+		return 0
+	}
+	return tokenizer.CountTokens("")
+}
+
+// bpePattern splits a text into the pieces encoded separately by the cl100k_base style tokenizers
+// (the pattern of tiktoken without its lookahead, which Go regular expressions do not support)
+var bpePattern = regexp.MustCompile(`(?i:'s|'t|'re|'ve|'m|'ll|'d)|[^\r\n\p{L}\p{N}]?\p{L}+|\p{N}{1,3}| ?[^\s\p{L}\p{N}]+[\r\n]*|\s*[\r\n]+|\s+`)
+
+// BPETokenizer counts the tokens of the byte pair encoding of the OpenAI models (tiktoken)
+type BPETokenizer struct {
+	ranks map[string]int
+}
+
+// LoadBPETokenizer reads a tiktoken file (e.g. cl100k_base.tiktoken): one token per line, encoded in base64,
+// followed by its rank
+func LoadBPETokenizer(path string) (*BPETokenizer, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the tokenizer: %w", err)
+	}
+	defer file.Close()
+
+	ranks := map[string]int{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid tokenizer line %d (expected a token and its rank)", line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid token at line %d: %w", line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid rank at line %d: %w", line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the tokenizer: %w", err)
+	}
+	return NewBPETokenizer(ranks)
+}
+
+// NewBPETokenizer creates a tokenizer from the ranks of its tokens (byte sequences)
+func NewBPETokenizer(ranks map[string]int) (*BPETokenizer, error) {
+	// Any text can be encoded: each byte is a token
+	for b := 0; b < 256; b++ {
+		if _, ok := ranks[string([]byte{byte(b)})]; !ok {
+			return nil, fmt.Errorf("the tokenizer has no token for byte %d", b)
+		}
+	}
+	return &BPETokenizer{ranks: ranks}, nil
+}
+
+// CountTokens returns the number of tokens of a text
+func (t *BPETokenizer) CountTokens(text string) int {
+	count := 0
+	for _, piece := range bpePattern.FindAllString(text, -1) {
+		count += t.pieceTokens(piece)
+	}
+	return count
+}
+
+// pieceTokens returns the number of tokens of a piece of text: its bytes are merged pair by pair,
+// the pair of lowest rank first, until no pair is a token
+func (t *BPETokenizer) pieceTokens(piece string) int {
+	if _, ok := t.ranks[piece]; ok {
+		return 1
+	}
+	bounds := make([]int, len(piece)+1)
+	for i := range bounds {
+		bounds[i] = i
+	}
+	for len(bounds) > 2 {
+		best, bestRank := -1, math.MaxInt
+		for i := 0; i+2 < len(bounds); i++ {
+			if rank, ok := t.ranks[piece[bounds[i]:bounds[i+2]]]; ok && rank < bestRank {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		bounds = append(bounds[:best+1], bounds[best+2:]...)
+	}
+	return len(bounds) - 1
+}
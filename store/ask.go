@@ -0,0 +1,140 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"vectormind/models"
+
+	"github.com/openai/openai-go"
+)
+
+// ErrChatModelMissing is returned when a question is asked while no chat model is configured (see SetChatModel)
+var ErrChatModelMissing = errors.New("no chat model configured")
+
+// ErrChatRequestFailed is returned when the chat model cannot be reached, answers with an error or without answer
+var ErrChatRequestFailed = errors.New("chat request failed")
+
+// chatClient is the client of the chat model answering the questions, chatModelId the model ("": no chat model)
+var (
+	chatClient  openai.Client
+	chatModelId = ""
+)
+
+// SetChatModel sets the chat model answering the questions (see Ask) and the client of its OpenAI compatible API.
+// The chat model is set at startup, before the questions are asked.
+func SetChatModel(client openai.Client, modelId string) {
+	chatClient = client
+	chatModelId = modelId
+}
+
+// ChatModelID returns the ID of the chat model answering the questions ("" when none is configured)
+func ChatModelID() string {
+	return chatModelId
+}
+
+// askSystemPrompt tells the chat model to answer from the sources only, citing them by number
+const askSystemPrompt = "You answer questions using only the numbered sources given with the question. " +
+	"Cite the sources supporting each statement with their number in brackets, e.g. [1] or [2][3]. " +
+	"If the sources do not contain the answer, say that you do not know. Do not cite sources you did not use."
+
+// citationPattern matches a citation of a source in an answer, e.g. [2]
+var citationPattern = regexp.MustCompile(`\[(\d+)\]`)
+
+// BuildAskPrompt returns the user message of a question: the retrieved chunks, numbered from 1 in the order of the
+// results, followed by the question
+func BuildAskPrompt(question string, sources []models.SimilaritySearchResult) string {
+	var prompt strings.Builder
+	prompt.WriteString("Sources:\n\n")
+	for i, source := range sources {
+		content := source.Content
+		if source.ExpandedContent != "" {
+			content = source.ExpandedContent
+		}
+		fmt.Fprintf(&prompt, "[%d] %s\n\n", i+1, strings.TrimSpace(content))
+	}
+	prompt.WriteString("Question: " + question)
+	return prompt.String()
+}
+
+// CitedSources returns the IDs of the sources cited by an answer (see BuildAskPrompt), in the order of their first
+// citation. The numbers that do not designate a source are ignored.
+func CitedSources(answer string, sources []models.SimilaritySearchResult) []string {
+	cited := []string{}
+	seen := map[int]bool{}
+	for _, match := range citationPattern.FindAllStringSubmatch(answer, -1) {
+		number, err := strconv.Atoi(match[1])
+		if err != nil || number < 1 || number > len(sources) || seen[number] {
+			continue
+		}
+		seen[number] = true
+		cited = append(cited, sources[number-1].ID)
+	}
+	return cited
+}
+
+// Ask answers a question from the retrieved chunks with the chat model (see SetChatModel), and returns the answer
+// with the IDs of the chunks it cites. The answer is written in the response language (see ValidateResponseLanguage),
+// or in the language of the question when it is empty. It returns ErrChatModelMissing when no chat model is configured, and
+// ErrChatRequestFailed when the chat model fails.
+func Ask(ctx context.Context, question string, sources []models.SimilaritySearchResult, language string) (string, []string, error) {
+	if chatModelId == "" {
+		return "", nil, ErrChatModelMissing
+	}
+
+	completion, err := chatClient.Chat.Completions.New(ctx, askParams(question, sources, language))
+	if err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrChatRequestFailed, err)
+	}
+	if len(completion.Choices) == 0 {
+		return "", nil, fmt.Errorf("%w: no answer returned", ErrChatRequestFailed)
+	}
+
+	answer := strings.TrimSpace(completion.Choices[0].Message.Content)
+	return answer, CitedSources(answer, sources), nil
+}
+
+// AskStream answers a question as Ask, streaming the answer: onToken is called with each piece of the answer as the
+// chat model generates it. The streaming stops when onToken returns an error (e.g. the client is gone), and
+// AskStream returns that error.
+func AskStream(ctx context.Context, question string, sources []models.SimilaritySearchResult, language string, onToken func(token string) error) (string, []string, error) {
+	if chatModelId == "" {
+		return "", nil, ErrChatModelMissing
+	}
+
+	stream := chatClient.Chat.Completions.NewStreaming(ctx, askParams(question, sources, language))
+	defer stream.Close()
+
+	var answer strings.Builder
+	for stream.Next() {
+		chunk := stream.Current()
+		if len(chunk.Choices) == 0 || chunk.Choices[0].Delta.Content == "" {
+			continue
+		}
+		token := chunk.Choices[0].Delta.Content
+		answer.WriteString(token)
+		if err := onToken(token); err != nil {
+			return "", nil, err
+		}
+	}
+	if err := stream.Err(); err != nil {
+		return "", nil, fmt.Errorf("%w: %v", ErrChatRequestFailed, err)
+	}
+
+	text := strings.TrimSpace(answer.String())
+	return text, CitedSources(text, sources), nil
+}
+
+// askParams returns the chat completion request of a question, answered in the response language
+func askParams(question string, sources []models.SimilaritySearchResult, language string) openai.ChatCompletionNewParams {
+	return openai.ChatCompletionNewParams{
+		Model: chatModelId,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(askSystemPrompt + " " + responseLanguageInstruction(question, language)),
+			openai.UserMessage(BuildAskPrompt(question, sources)),
+		},
+	}
+}
@@ -0,0 +1,244 @@
+package store
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+	"vectormind/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// changesKeyPrefix is the prefix of the Redis streams holding the changes of the documents of a collection
+// ("vectormind:changes:<key prefix of the collection>")
+const changesKeyPrefix = "vectormind:changes:"
+
+// DefaultChangeFeedLength is the default number of changes kept by collection
+const DefaultChangeFeedLength = 10000
+
+// Change types
+const (
+	ChangeStored  = "stored"  // document stored (created, or replaced)
+	ChangeUpdated = "updated" // document updated (PUT /documents/{id})
+	ChangeDeleted = "deleted" // document deleted
+)
+
+// ErrInvalidCursor is returned when a watch cursor is not a change ID
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// changeFeedLength is the approximate number of changes kept by collection (0: the changes are not recorded)
+var changeFeedLength int64 = DefaultChangeFeedLength
+
+// SetChangeFeedLength sets the number of changes kept by collection for the watchers (0 disables the change feed)
+func SetChangeFeedLength(length int) error {
+	if length < 0 {
+		return fmt.Errorf("invalid change feed length %d (use 0 to disable the change feed)", length)
+	}
+	changeFeedLength = int64(length)
+	return nil
+}
+
+// ChangeFeedEnabled reports whether the changes of the documents are recorded
+func ChangeFeedEnabled() bool {
+	return changeFeedLength > 0
+}
+
+// DefaultMaxWatchers is the default number of watch requests waiting for changes at the same time
+const DefaultMaxWatchers = 64
+
+// watchClient is the client of the blocking reads of the change feeds (nil: the client of the request, see
+// SetWatchClient)
+var watchClient *redis.Client
+
+// NewWatchClient creates a client for the blocking reads of the change feeds, on the server and the database of a
+// client but with its own pool of poolSize connections: the waiting watchers cannot exhaust the pool of the searches
+// and of the ingestion. Each waiting watcher holds a connection, poolSize is the number of watchers (see SetWatchClient).
+func NewWatchClient(redisClient *redis.Client, poolSize int) *redis.Client {
+	options := *redisClient.Options()
+	options.PoolSize = poolSize
+	options.MinIdleConns = 0
+	options.MaxIdleConns = 0
+	client := redis.NewClient(&options)
+	client.AddHook(errorClassificationHook{})
+	client.AddHook(chaosHook{})
+	return client
+}
+
+// SetWatchClient sets the client of the blocking reads of the change feeds (see NewWatchClient)
+func SetWatchClient(client *redis.Client) {
+	watchClient = client
+}
+
+// blockingClient returns the client of the blocking reads of the change feeds
+func blockingClient(redisClient *redis.Client) *redis.Client {
+	if watchClient != nil {
+		return watchClient
+	}
+	return redisClient
+}
+
+// changesKey returns the key of the change stream of the collection of a key prefix
+func changesKey(keyPrefix string) string {
+	if keyPrefix == "" {
+		keyPrefix = documentKeyPrefix
+	}
+	return changesKeyPrefix + keyPrefix
+}
+
+// documentKeyPrefixOf returns the key prefix of the collection of a document ID ("col:<name>:" or "doc:", after the
+// prefix of its tenant)
+func documentKeyPrefixOf(id string) string {
+	namespace := tenantNamespace(id)
+	if name := documentCollectionName(id); name != "" {
+		return namespace + collectionKeyPrefix + name + ":"
+	}
+	return namespace + documentKeyPrefix
+}
+
+// queueChange queues the record of the change of a document, so that it is applied with the change itself
+func queueChange(ctx context.Context, pipe redis.Pipeliner, changeType, id string) {
+	if !ChangeFeedEnabled() {
+		return
+	}
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: changesKey(documentKeyPrefixOf(id)),
+		MaxLen: changeFeedLength,
+		Approx: true,
+		Values: []any{"type", changeType, "id", id},
+	})
+}
+
+// recordChanges records the changes of documents already applied (a failure is only logged: the documents are changed)
+func recordChanges(ctx context.Context, redisClient *redis.Client, changeType string, ids []string) {
+	if !ChangeFeedEnabled() || len(ids) == 0 {
+		return
+	}
+	_, err := redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, id := range ids {
+			queueChange(ctx, pipe, changeType, id)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("🟠 Unable to record the %s documents in the change feed: %v", changeType, err)
+	}
+}
+
+// ChangeBatch is a batch of changes of a collection read from a cursor
+type ChangeBatch struct {
+	Changes []models.DocumentChange
+	Cursor  string // cursor of the next read (the last change of the batch, or the cursor of the read)
+	// Truncated is true when changes following the cursor may have been dropped (the change feed only keeps
+	// the last changes): the client has to reload the collection
+	Truncated bool
+}
+
+// ValidateChangeCursor checks that a cursor is "0" or the ID of a change ("<milliseconds>-<sequence>")
+func ValidateChangeCursor(cursor string) error {
+	if cursor == "0" {
+		return nil
+	}
+	milliseconds, sequence, found := strings.Cut(cursor, "-")
+	if _, err := strconv.ParseUint(milliseconds, 10, 64); err != nil || !found {
+		return fmt.Errorf("%w %q (use a cursor returned by a previous watch, or 0 for all the kept changes)", ErrInvalidCursor, cursor)
+	}
+	if _, err := strconv.ParseUint(sequence, 10, 64); err != nil {
+		return fmt.Errorf("%w %q (use a cursor returned by a previous watch, or 0 for all the kept changes)", ErrInvalidCursor, cursor)
+	}
+	return nil
+}
+
+// LatestChangeCursor returns the cursor of the last change of a collection ("0" when there is no change)
+func LatestChangeCursor(ctx context.Context, redisClient *redis.Client, collection Collection) (string, error) {
+	messages, err := redisClient.XRevRangeN(ctx, changesKey(collection.KeyPrefix), "+", "-", 1).Result()
+	if err != nil {
+		return "", fmt.Errorf("failed to read the change feed: %w", err)
+	}
+	if len(messages) == 0 {
+		return "0", nil
+	}
+	return messages[0].ID, nil
+}
+
+// WaitForChanges returns the changes of the documents of a collection following a cursor (up to limit changes).
+// When there is no change yet, it waits for one up to timeout, and returns an empty batch with the same cursor
+// when the timeout expires. An empty cursor designates the last change (only the next changes are returned).
+// The blocking read uses the watch client (see SetWatchClient).
+func WaitForChanges(ctx context.Context, redisClient *redis.Client, collection Collection, cursor string, limit int, timeout time.Duration) (ChangeBatch, error) {
+	var err error
+	if cursor == "" {
+		if cursor, err = LatestChangeCursor(ctx, redisClient, collection); err != nil {
+			return ChangeBatch{}, err
+		}
+	} else if err := ValidateChangeCursor(cursor); err != nil {
+		return ChangeBatch{}, err
+	}
+	key := changesKey(collection.KeyPrefix)
+	batch := ChangeBatch{Changes: []models.DocumentChange{}, Cursor: cursor}
+
+	// The changes following the cursor may have been trimmed when the cursor is older than the first kept change
+	if cursor != "0" {
+		first, err := redisClient.XRangeN(ctx, key, "-", "+", 1).Result()
+		if err != nil {
+			return ChangeBatch{}, fmt.Errorf("failed to read the change feed: %w", err)
+		}
+		batch.Truncated = len(first) > 0 && compareStreamIDs(cursor, first[0].ID) < 0
+	}
+
+	block := timeout
+	if block <= 0 {
+		block = -1 // BLOCK 0 would wait forever
+	}
+	streams, err := blockingClient(redisClient).XRead(ctx, &redis.XReadArgs{
+		Streams: []string{key, cursor},
+		Count:   int64(limit),
+		Block:   block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return batch, nil // no change before the timeout
+	}
+	if err != nil {
+		return ChangeBatch{}, fmt.Errorf("failed to read the change feed: %w", err)
+	}
+	for _, stream := range streams {
+		for _, message := range stream.Messages {
+			batch.Changes = append(batch.Changes, documentChange(message))
+			batch.Cursor = message.ID
+		}
+	}
+	return batch, nil
+}
+
+// documentChange converts a message of a change stream
+func documentChange(message redis.XMessage) models.DocumentChange {
+	change := models.DocumentChange{Cursor: message.ID}
+	change.Type, _ = message.Values["type"].(string)
+	change.ID, _ = message.Values["id"].(string)
+	if milliseconds, _, found := strings.Cut(message.ID, "-"); found {
+		if ms, err := strconv.ParseInt(milliseconds, 10, 64); err == nil {
+			change.Time = time.UnixMilli(ms).UTC().Format(time.RFC3339Nano)
+		}
+	}
+	return change
+}
+
+// compareStreamIDs compares two valid stream IDs ("0" is the lowest one)
+func compareStreamIDs(a, b string) int {
+	parse := func(id string) (uint64, uint64) {
+		milliseconds, sequence, _ := strings.Cut(id, "-")
+		ms, _ := strconv.ParseUint(milliseconds, 10, 64)
+		seq, _ := strconv.ParseUint(sequence, 10, 64)
+		return ms, seq
+	}
+	aMs, aSeq := parse(a)
+	bMs, bSeq := parse(b)
+	if c := cmp.Compare(aMs, bMs); c != 0 {
+		return c
+	}
+	return cmp.Compare(aSeq, bSeq)
+}
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultChaosDelay is the default duration of the injected embedding timeouts and slow responses
+const DefaultChaosDelay = 2 * time.Second
+
+// ErrChaosInjected is wrapped by the failures injected by the chaos mode, to tell them from the real ones
+var ErrChaosInjected = errors.New("injected by the chaos mode")
+
+// ChaosConfig holds the rates (from 0: never, to 1: always) of the failures injected by the chaos mode (CHAOS_MODE),
+// so that the clients can verify their retries and fallbacks against realistic failures
+type ChaosConfig struct {
+	EmbeddingTimeoutRate float64 // requests to the primary embedding provider failing with a timeout
+	RedisErrorRate       float64 // Redis commands (and pipelines) failing as if the connection was lost
+	SlowResponseRate     float64 // API and MCP requests delayed before being handled
+	// Delay is the duration of the injected embedding timeouts and the delay of the slow responses
+	// (default: DefaultChaosDelay)
+	Delay time.Duration
+}
+
+// chaos is the configuration of the chaos mode (no failure by default)
+var chaos ChaosConfig
+
+// SetChaos sets the failures injected by the chaos mode (a zero config disables it)
+func SetChaos(config ChaosConfig) error {
+	for name, rate := range map[string]float64{
+		"embedding timeout rate": config.EmbeddingTimeoutRate,
+		"redis error rate":       config.RedisErrorRate,
+		"slow response rate":     config.SlowResponseRate,
+	} {
+		if rate < 0 || rate > 1 {
+			return fmt.Errorf("the %s must be between 0 and 1", name)
+		}
+	}
+	if config.Delay < 0 {
+		return fmt.Errorf("the delay cannot be negative")
+	}
+	if config.Delay == 0 {
+		config.Delay = DefaultChaosDelay
+	}
+	chaos = config
+	return nil
+}
+
+// ChaosEnabled reports whether the chaos mode injects failures
+func ChaosEnabled() bool {
+	return chaos.EmbeddingTimeoutRate > 0 || chaos.RedisErrorRate > 0 || chaos.SlowResponseRate > 0
+}
+
+// chaosHit draws whether a failure of the given rate is injected
+func chaosHit(rate float64) bool {
+	return rate > 0 && rand.Float64() < rate
+}
+
+// ChaosResponseDelay returns the delay of a request drawn as a slow response by the chaos mode (0: not delayed)
+func ChaosResponseDelay() time.Duration {
+	if !chaosHit(chaos.SlowResponseRate) {
+		return 0
+	}
+	return chaos.Delay
+}
+
+// chaosEmbeddingTimeout injects a timeout of the embedding provider: the request hangs for the chaos delay (or until
+// the caller gives up), then fails like an unreachable provider
+func chaosEmbeddingTimeout(ctx context.Context) error {
+	if !chaosHit(chaos.EmbeddingTimeoutRate) {
+		return nil
+	}
+	timer := time.NewTimer(chaos.Delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+	}
+	return fmt.Errorf("%w: timeout after %s (%w)", ErrEmbeddingRequestFailed, chaos.Delay, ErrChaosInjected)
+}
+
+// errChaosRedis is the error of the Redis commands failed by the chaos mode: it is classified as a lost connection
+var errChaosRedis = &classifiedError{
+	kind: ErrBackendUnavailable,
+	err:  fmt.Errorf("connection reset by peer (%w)", ErrChaosInjected),
+}
+
+// chaosHook fails the commands of a Redis client at the Redis error rate of the chaos mode, without sending them
+type chaosHook struct{}
+
+func (chaosHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (chaosHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if chaosHit(chaos.RedisErrorRate) {
+			cmd.SetErr(errChaosRedis)
+			return errChaosRedis
+		}
+		return next(ctx, cmd)
+	}
+}
+
+// ProcessPipelineHook fails a whole pipeline (or transaction), like a connection lost during its round trip
+func (chaosHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		if chaosHit(chaos.RedisErrorRate) {
+			for _, cmd := range cmds {
+				cmd.SetErr(errChaosRedis)
+			}
+			return errChaosRedis
+		}
+		return next(ctx, cmds)
+	}
+}
@@ -0,0 +1,50 @@
+package store
+
+import (
+	"math"
+	"unicode/utf8"
+	"vectormind/models"
+	"vectormind/splitter"
+)
+
+// PreviewChunks returns the chunks of a document with their number of characters and tokens, and the quality score
+// they would be stored with (see splitter.ScoreChunks), without creating their embeddings
+func PreviewChunks(chunks []string) []models.PreviewedChunk {
+	qualities := splitter.ScoreChunks(chunks)
+	previews := make([]models.PreviewedChunk, len(chunks))
+	for i, chunk := range chunks {
+		previews[i] = models.PreviewedChunk{
+			Index:      i,
+			Content:    chunk,
+			Characters: utf8.RuneCountInString(chunk),
+			Tokens:     splitter.CountTokens(chunk),
+			Quality:    qualities[i].Score,
+		}
+	}
+	return previews
+}
+
+// ChunkPreviewResponse returns the response of a chunk preview: the previewed chunks with the statistics of their
+// numbers of tokens
+func ChunkPreviewResponse(strategy string, options map[string]interface{}, chunks []string, maxInputTokens int) models.ChunkPreviewResponse {
+	response := models.ChunkPreviewResponse{
+		Strategy:       strategy,
+		Options:        options,
+		Chunks:         PreviewChunks(chunks),
+		ChunkCount:     len(chunks),
+		MaxInputTokens: maxInputTokens,
+		Success:        true,
+	}
+	total := 0
+	for i, chunk := range response.Chunks {
+		if i == 0 || chunk.Tokens < response.MinTokens {
+			response.MinTokens = chunk.Tokens
+		}
+		response.MaxTokens = max(response.MaxTokens, chunk.Tokens)
+		total += chunk.Tokens
+	}
+	if len(chunks) > 0 {
+		response.AvgTokens = math.Round(float64(total)/float64(len(chunks))*10) / 10
+	}
+	return response
+}
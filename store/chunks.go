@@ -0,0 +1,486 @@
+package store
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+	"vectormind/helpers"
+	"vectormind/models"
+	"vectormind/splitter"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// Chunk ID strategies
+const (
+	// IDStrategyUUID generates a random ID for each chunk (default)
+	IDStrategyUUID = "uuid"
+	// IDStrategyContentHash derives the chunk ID from the source ID, the chunk index and the chunk content,
+	// so that ingesting the same document twice overwrites the same keys
+	IDStrategyContentHash = "content_hash"
+)
+
+// ChunkOptions holds the options applied to all the chunks of a document
+type ChunkOptions struct {
+	Label    string
+	Metadata string
+	// ChunkMetadata is the metadata of each chunk, in place of Metadata (optional, same length as the chunks)
+	ChunkMetadata []string
+	IDStrategy    string // IDStrategyUUID (default) or IDStrategyContentHash
+	SourceID      string // identifies the source document (used by IDStrategyContentHash, and as parent ID of the chunks)
+	// ContinueOnError keeps storing the next chunks when a chunk fails, instead of aborting
+	ContinueOnError bool
+	// Original is the document before chunking, archived (when enabled) under the source ID; the offsets of the
+	// chunks are their positions in it
+	Original string
+	// KeyPrefix is the key prefix of the collection of the chunks (default: "doc:")
+	KeyPrefix string
+	// TTL is the time after which Redis deletes the chunks (0 means no expiration)
+	TTL time.Duration
+	// Dedup is the deduplication mode of the chunks whose content is already stored in the index (DedupOff by default)
+	Dedup     string
+	IndexName string // index of the collection of the chunks, searched for the stored contents
+	// Rollback deletes the chunks stored by the ingestion when a failed chunk aborts it (not with ContinueOnError)
+	Rollback bool
+	// Atomic creates all the embeddings before storing the chunks in a single transaction (not with ContinueOnError)
+	Atomic bool
+	// Progress is called after each batch with the statuses of the chunks processed so far (optional)
+	Progress func(statuses []models.ChunkStatus)
+}
+
+// chunkMetadata returns the metadata of the chunk at the given index
+func (options ChunkOptions) chunkMetadata(index int) string {
+	if index < len(options.ChunkMetadata) {
+		return options.ChunkMetadata[index]
+	}
+	return options.Metadata
+}
+
+// DefaultEmbeddingBatchSize is the default number of chunks embedded by a single embedding request
+const DefaultEmbeddingBatchSize = 32
+
+var embeddingBatchSize = DefaultEmbeddingBatchSize
+
+// SetEmbeddingBatchSize sets the number of chunks embedded by a single embedding request (1 disables batching)
+func SetEmbeddingBatchSize(batchSize int) {
+	if batchSize <= 0 {
+		batchSize = DefaultEmbeddingBatchSize
+	}
+	embeddingBatchSize = batchSize
+}
+
+// GetEmbeddingBatchSize returns the number of chunks embedded by a single embedding request
+func GetEmbeddingBatchSize() int {
+	return embeddingBatchSize
+}
+
+// ValidateIDStrategy checks that the ID strategy is supported (an empty strategy means IDStrategyUUID)
+func ValidateIDStrategy(idStrategy string) error {
+	switch idStrategy {
+	case "", IDStrategyUUID, IDStrategyContentHash:
+		return nil
+	default:
+		return fmt.Errorf("unknown id_strategy %q (use %q or %q)", idStrategy, IDStrategyUUID, IDStrategyContentHash)
+	}
+}
+
+// ValidateAtomic checks that an atomic ingestion does not continue on error (all the chunks are stored, or none)
+func ValidateAtomic(atomic, continueOnError bool) error {
+	if atomic && continueOnError {
+		return fmt.Errorf("atomic cannot be combined with continue_on_error (an atomic ingestion stores all the chunks or none)")
+	}
+	return nil
+}
+
+// HashContent returns the hex encoded SHA-256 of a content
+func HashContent(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// ContentHashChunkID derives a stable chunk ID from the source ID, the chunk index and the chunk content
+func ContentHashChunkID(sourceID string, chunkIndex int, chunk string) string {
+	return contentHashChunkKey(documentKeyPrefix, sourceID, chunkIndex, chunk)
+}
+
+// contentHashChunkKey derives a stable chunk ID with the key prefix of a collection
+func contentHashChunkKey(keyPrefix, sourceID string, chunkIndex int, chunk string) string {
+	return fmt.Sprintf("%s%s:%d:%s", keyPrefix, sourceID, chunkIndex, HashContent(chunk)[:16])
+}
+
+// chunkIDs generates the IDs of the chunks according to the ID strategy
+func chunkIDs(chunks []string, options ChunkOptions) []string {
+	ids := make([]string, len(chunks))
+	keyPrefix := options.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = documentKeyPrefix
+	}
+
+	if options.IDStrategy != IDStrategyContentHash {
+		for i := range chunks {
+			ids[i] = NewDocumentID(keyPrefix)
+		}
+		return ids
+	}
+
+	sourceID := chunkParentID(chunks, options)
+	for i, chunk := range chunks {
+		ids[i] = contentHashChunkKey(keyPrefix, sourceID, i, chunk)
+	}
+	return ids
+}
+
+// chunkParentID returns the ID shared by the chunks of a document: its source ID. Without source ID, the source is
+// identified by the content of all its chunks with IDStrategyContentHash (the same chunks have the same keys),
+// and by a new ID otherwise.
+func chunkParentID(chunks []string, options ChunkOptions) string {
+	switch {
+	case options.SourceID != "":
+		return options.SourceID
+	case options.IDStrategy == IDStrategyContentHash:
+		return HashContent(strings.Join(chunks, ""))[:16]
+	default:
+		return documentIDGenerator.NewID()
+	}
+}
+
+// StoreChunks creates an embedding for each chunk and stores it in Redis.
+// Embeddings are created by batches of GetEmbeddingBatchSize chunks (one request per batch),
+// and the chunks of a batch are stored with a single Redis round trip (StoreEmbeddingsPipelined).
+// All chunks share the same label and metadata (unless ChunkMetadata is set), and each one is stored with its quality score
+// and its provenance: the parent ID shared by the chunks, its index and its offsets in the original document.
+// It returns the status of each processed chunk, in the same order as the chunks.
+//
+// By default, the first failing chunk aborts the ingestion: the statuses of the chunks processed so far
+// are returned with the error (the other chunks of the batch of a chunk that failed to be stored may be stored,
+// as reported by their status). With ContinueOnError, failed chunks are reported in their status
+// and the remaining chunks are still stored. With Atomic, all the embeddings are created before the chunks are
+// stored in a single transaction: all the chunks are stored, or none.
+func StoreChunks(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId string, chunks []string, options ChunkOptions) ([]models.ChunkStatus, error) {
+	if err := ValidateIDStrategy(options.IDStrategy); err != nil {
+		return nil, err
+	}
+	if err := ValidateDedupMode(options.Dedup); err != nil {
+		return nil, err
+	}
+	if err := ValidateAtomic(options.Atomic, options.ContinueOnError); err != nil {
+		return nil, err
+	}
+
+	// Archive the original document first: chunks always reference an archived original
+	options.SourceID = OriginalSourceID(options.SourceID, options.Original)
+	originalRef, err := archiveOriginal(ctx, options.KeyPrefix, options.SourceID, options.Original)
+	if err != nil {
+		return nil, err
+	}
+
+	// The transformed chunks are scored, identified and deduplicated
+	chunks, err = transformChunks(ctx, chunks, &options)
+	if err != nil {
+		return nil, err
+	}
+
+	qualities := splitter.ScoreChunks(chunks)
+	ids := chunkIDs(chunks, options)
+	duplicates, err := dedupChunks(ctx, redisClient, chunks, ids, options)
+	if err != nil {
+		return nil, err
+	}
+	parentID := chunkParentID(chunks, options)
+	located := splitter.LocateChunks(options.Original, chunks)
+	chunkDocument := func(i int, embedding []float32) Document {
+		return Document{
+			ID:          ids[i],
+			Content:     chunks[i],
+			Embedding:   embedding,
+			Label:       options.Label,
+			Metadata:    options.chunkMetadata(i),
+			Quality:     qualities[i].Score,
+			SourceID:    options.SourceID,
+			OriginalRef: originalRef,
+			ParentID:    parentID,
+			ChunkIndex:  i,
+			StartOffset: located[i].Start,
+			EndOffset:   located[i].End,
+			TTL:         options.TTL,
+		}
+	}
+	if options.Atomic {
+		return storeChunksAtomic(ctx, openaiClient, redisClient, embeddingModelId, chunks, duplicates, chunkDocument, options)
+	}
+	statuses := make([]models.ChunkStatus, 0, len(chunks))
+	// IDs of the chunks that replaced documents stored before the ingestion: a rollback keeps them
+	preexisting := map[string]bool{}
+
+	batchSize := GetEmbeddingBatchSize()
+	for start := 0; start < len(chunks); start += batchSize {
+		end := min(start+batchSize, len(chunks))
+
+		// Embed the chunks of the batch (but the skipped duplicates) with a single request. When the batch fails,
+		// its chunks are embedded one by one so that the failing chunks are identified.
+		var texts []string
+		for i := start; i < end; i++ {
+			if !duplicates[i] {
+				texts = append(texts, chunks[i])
+			}
+		}
+		embeddings := make([][]float32, 0, len(texts))
+		if len(texts) > 0 {
+			if batch, err := CreateEmbeddingsFromTexts(ctx, openaiClient, texts, embeddingModelId); err == nil {
+				embeddings = batch
+			}
+		}
+
+		// The chunks of the batch are stored with a single round trip, once the embeddings are created
+		batchStatuses := make([]models.ChunkStatus, 0, end-start)
+		var docs []Document
+		var docStatuses []int
+		var abort error
+		for i := start; i < end; i++ {
+			if duplicates[i] {
+				batchStatuses = append(batchStatuses, models.ChunkStatus{
+					Index:  i,
+					ID:     ids[i],
+					Status: models.ChunkStatusDuplicate,
+				})
+				continue
+			}
+
+			var embedding []float32
+			if len(embeddings) > 0 {
+				embedding, embeddings = embeddings[0], embeddings[1:]
+			} else if embedding, err = CreateEmbeddingFromText(ctx, openaiClient, chunks[i], embeddingModelId); err != nil {
+				err = fmt.Errorf("failed to create embedding for chunk: %w", err)
+				batchStatuses = append(batchStatuses, models.ChunkStatus{
+					Index:  i,
+					Status: models.ChunkStatusFailed,
+					Error:  err.Error(),
+				})
+				if !options.ContinueOnError {
+					abort = err
+					break
+				}
+				continue
+			}
+
+			docs = append(docs, chunkDocument(i, embedding))
+			docStatuses = append(docStatuses, len(batchStatuses))
+			batchStatuses = append(batchStatuses, models.ChunkStatus{
+				Index:  i,
+				ID:     ids[i],
+				Status: models.ChunkStatusStored,
+			})
+		}
+
+		if options.Rollback && len(docs) > 0 {
+			ids := make([]string, len(docs))
+			for j, doc := range docs {
+				ids[j] = doc.ID
+			}
+			existing, err := existingDocuments(ctx, redisClient, ids)
+			for j, id := range ids {
+				// When the documents cannot be checked, they are all kept by a rollback
+				if err != nil || existing[j] {
+					preexisting[id] = true
+				}
+			}
+		}
+		for j, err := range StoreEmbeddingsPipelined(ctx, redisClient, docs) {
+			if err == nil {
+				continue
+			}
+			err = fmt.Errorf("failed to store chunk embedding: %w", err)
+			status := &batchStatuses[docStatuses[j]]
+			status.ID = ""
+			status.Status = models.ChunkStatusFailed
+			status.Error = err.Error()
+			if abort == nil && !options.ContinueOnError {
+				abort = err
+			}
+		}
+
+		statuses = append(statuses, batchStatuses...)
+		if options.Progress != nil {
+			options.Progress(statuses)
+		}
+		if abort != nil {
+			if options.Rollback {
+				if err := rollbackChunks(ctx, redisClient, statuses, preexisting); err != nil {
+					abort = fmt.Errorf("%w (rollback failed: %v)", abort, err)
+				}
+			}
+			return statuses, abort
+		}
+	}
+
+	return statuses, nil
+}
+
+// storeChunksAtomic creates the embeddings of all the chunks (but the skipped duplicates) before storing them
+// in a single transaction (StoreEmbeddingsAtomic). Nothing is stored when a chunk fails: its status is returned
+// with the error.
+func storeChunksAtomic(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId string, chunks []string, duplicates []bool, chunkDocument func(i int, embedding []float32) Document, options ChunkOptions) ([]models.ChunkStatus, error) {
+	embeddings := make([][]float32, len(chunks))
+	batchSize := GetEmbeddingBatchSize()
+	for start := 0; start < len(chunks); start += batchSize {
+		end := min(start+batchSize, len(chunks))
+		var indexes []int
+		var texts []string
+		for i := start; i < end; i++ {
+			if !duplicates[i] {
+				indexes = append(indexes, i)
+				texts = append(texts, chunks[i])
+			}
+		}
+		if len(texts) == 0 {
+			continue
+		}
+		if batch, err := CreateEmbeddingsFromTexts(ctx, openaiClient, texts, embeddingModelId); err == nil {
+			for j, i := range indexes {
+				embeddings[i] = batch[j]
+			}
+			continue
+		}
+
+		// The batch failed: its chunks are embedded one by one so that the failing chunk is identified
+		for _, i := range indexes {
+			embedding, err := CreateEmbeddingFromText(ctx, openaiClient, chunks[i], embeddingModelId)
+			if err != nil {
+				err = fmt.Errorf("failed to create embedding for chunk: %w", err)
+				status := models.ChunkStatus{Index: i, Status: models.ChunkStatusFailed, Error: err.Error()}
+				return []models.ChunkStatus{status}, fmt.Errorf("chunk %d: %w (no chunk was stored)", i, err)
+			}
+			embeddings[i] = embedding
+		}
+	}
+
+	docs := make([]Document, 0, len(chunks))
+	statuses := make([]models.ChunkStatus, len(chunks))
+	for i := range chunks {
+		doc := chunkDocument(i, embeddings[i])
+		if duplicates[i] {
+			statuses[i] = models.ChunkStatus{Index: i, ID: doc.ID, Status: models.ChunkStatusDuplicate}
+			continue
+		}
+		docs = append(docs, doc)
+		statuses[i] = models.ChunkStatus{Index: i, ID: doc.ID, Status: models.ChunkStatusStored}
+	}
+	if err := StoreEmbeddingsAtomic(ctx, redisClient, docs); err != nil {
+		return nil, fmt.Errorf("failed to store the chunks atomically: %w", err)
+	}
+
+	if options.Progress != nil {
+		options.Progress(statuses)
+	}
+	return statuses, nil
+}
+
+// rollbackChunks deletes the chunks created by an aborted ingestion, their statuses become ChunkStatusRolledBack.
+// The duplicates skipped by the deduplication and the chunks that replaced a document stored before the ingestion
+// (preexisting, e.g. content_hash IDs or upserts) are kept: deleting them would lose the previous data.
+func rollbackChunks(ctx context.Context, redisClient *redis.Client, statuses []models.ChunkStatus, preexisting map[string]bool) error {
+	var ids []string
+	for _, status := range statuses {
+		if status.Status == models.ChunkStatusStored && !preexisting[status.ID] {
+			ids = append(ids, status.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	if _, _, err := purgeDocuments(ctx, redisClient, ids); err != nil {
+		return err
+	}
+	for i := range statuses {
+		if statuses[i].Status == models.ChunkStatusStored && !preexisting[statuses[i].ID] {
+			statuses[i].Status = models.ChunkStatusRolledBack
+		}
+	}
+	return nil
+}
+
+// dedupChunks applies the deduplication mode to the chunks: a chunk whose content is already stored in the index
+// (or in a previous chunk of the document) takes the ID of the stored chunk. It returns the chunks to skip.
+func dedupChunks(ctx context.Context, redisClient *redis.Client, chunks []string, ids []string, options ChunkOptions) ([]bool, error) {
+	duplicates := make([]bool, len(chunks))
+	if !dedupEnabled(options.Dedup) {
+		return duplicates, nil
+	}
+	if options.IndexName == "" {
+		return nil, fmt.Errorf("the index of the chunks is required to deduplicate them")
+	}
+
+	existingIDs, err := FindDocumentsByContent(ctx, redisClient, options.IndexName, chunks)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]string{}
+	for i, chunk := range chunks {
+		digest := ContentDigest(chunk)
+		existingID := existingIDs[i]
+		if existingID == "" {
+			existingID = seen[digest]
+		}
+		if existingID == "" {
+			seen[digest] = ids[i]
+			continue
+		}
+		ids[i] = existingID
+		duplicates[i] = options.Dedup == DedupSkip
+	}
+	return duplicates, nil
+}
+
+// StoredChunkIDs returns the IDs of the stored chunks (including the skipped duplicates, already stored,
+// but not the chunks rolled back)
+// and the number of failed chunks
+func StoredChunkIDs(statuses []models.ChunkStatus) ([]string, int) {
+	ids := make([]string, 0, len(statuses))
+	failed := 0
+	for _, status := range statuses {
+		switch status.Status {
+		case models.ChunkStatusFailed:
+			failed++
+		case models.ChunkStatusRolledBack:
+			// deleted again
+		default:
+			ids = append(ids, status.ID)
+		}
+	}
+	return ids, failed
+}
+
+// chunkPreviewLength is the number of characters of the chunk previews
+const chunkPreviewLength = 100
+
+// ChunkPreviews returns the ID and a preview (or the full content) of each stored chunk,
+// so that callers can check the splitting without reading the chunks back
+func ChunkPreviews(chunks []string, statuses []models.ChunkStatus, includeContent bool) []models.ChunkPreview {
+	previews := make([]models.ChunkPreview, 0, len(statuses))
+	for _, status := range statuses {
+		if status.Status == models.ChunkStatusFailed || status.Status == models.ChunkStatusRolledBack {
+			continue
+		}
+
+		preview := models.ChunkPreview{
+			ID:        status.ID,
+			Index:     status.Index,
+			Duplicate: status.Status == models.ChunkStatusDuplicate,
+		}
+		if includeContent {
+			preview.Content = chunks[status.Index]
+		} else {
+			text, truncated := helpers.TruncateText(chunks[status.Index], chunkPreviewLength)
+			if truncated {
+				text += "…"
+			}
+			preview.Preview = text
+		}
+		previews = append(previews, preview)
+	}
+	return previews
+}
@@ -0,0 +1,231 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"vectormind/events"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// collectionKeyPrefix is the prefix of the keys of the documents stored in a collection ("col:<name>:<id>")
+const collectionKeyPrefix = "col:"
+
+// collectionsKey is the Redis set holding the names of the collections (one set per tenant, see collectionsKeyOf)
+const collectionsKey = "vectormind:collections"
+
+// collectionModelsKey is the Redis hash holding the embedding model of the collections not using the default model
+const collectionModelsKey = "vectormind:collection-models"
+
+// ErrCollectionNotFound is returned when a collection does not exist
+var ErrCollectionNotFound = fmt.Errorf("collection %w", ErrNotFound)
+
+// ErrCollectionExists is returned when creating a collection that already exists
+var ErrCollectionExists = errors.New("collection already exists")
+
+// ErrInvalidCollectionName is returned for collection names that cannot be used in keys and index names
+var ErrInvalidCollectionName = errors.New("invalid collection name")
+
+// collectionNamePattern restricts the collection names to the characters allowed in keys and index names
+var collectionNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// Collection designates the index and the key prefix of a set of documents.
+// The default collection (no name) is the main index over the "doc:" keys;
+// each named collection has its own index over the "col:<name>:" keys.
+// The keys of the collections of a tenant start with "tenant:<name>:" (see TenantIndexName).
+type Collection struct {
+	Name           string
+	IndexName      string
+	KeyPrefix      string
+	EmbeddingModel string // name of the registered embedding model of the collection ("" for the default model)
+}
+
+// ValidateCollectionName checks that a collection name is valid
+func ValidateCollectionName(name string) error {
+	if !collectionNamePattern.MatchString(name) {
+		return fmt.Errorf("%w %q (use up to 64 letters, digits, '_' or '-', starting with a letter or a digit)", ErrInvalidCollectionName, name)
+	}
+	return nil
+}
+
+// DefaultCollection returns the default collection of an index
+func DefaultCollection(indexName string) Collection {
+	return Collection{IndexName: indexName, KeyPrefix: tenantNamespace(indexName) + documentKeyPrefix}
+}
+
+// namedCollection returns the index and key prefix of a named collection
+func namedCollection(indexName, name string) Collection {
+	return Collection{
+		Name:      name,
+		IndexName: indexName + ":" + name,
+		KeyPrefix: tenantNamespace(indexName) + collectionKeyPrefix + name + ":",
+	}
+}
+
+// collectionsKeyOf returns the keys of the set of the collections and of the hash of their embedding models of the
+// tenant of an index name or a document ID
+func collectionsKeyOf(name string) (string, string) {
+	namespace := tenantNamespace(name)
+	return namespace + collectionsKey, namespace + collectionModelsKey
+}
+
+// ResolveCollection returns the collection of a request (the default collection when name is empty).
+// It returns ErrCollectionNotFound when the collection has not been created.
+func ResolveCollection(ctx context.Context, redisClient *redis.Client, indexName, name string) (Collection, error) {
+	if name == "" {
+		return DefaultCollection(indexName), nil
+	}
+	if err := ValidateCollectionName(name); err != nil {
+		return Collection{}, err
+	}
+
+	namesKey, modelsKey := collectionsKeyOf(indexName)
+	var exists *redis.BoolCmd
+	var model *redis.StringCmd
+	_, err := redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		exists = pipe.SIsMember(ctx, namesKey, name)
+		model = pipe.HGet(ctx, modelsKey, name)
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return Collection{}, fmt.Errorf("failed to check collection %s: %w", name, err)
+	}
+	if !exists.Val() {
+		return Collection{}, fmt.Errorf("%w: %s", ErrCollectionNotFound, name)
+	}
+	collection := namedCollection(indexName, name)
+	collection.EmbeddingModel = model.Val()
+	if _, ok := LookupEmbeddingModel(collection.EmbeddingModel); collection.EmbeddingModel != "" && !ok {
+		return Collection{}, fmt.Errorf("%w %q of collection %s (see EMBEDDING_MODELS)", ErrUnknownEmbeddingModel, collection.EmbeddingModel, name)
+	}
+	return collection, nil
+}
+
+// CreateCollection creates the index of a collection, with the same settings as the main index.
+// embeddingModel is the name of a registered embedding model ("" for the default model, whose vectors have embeddingDimension).
+// It returns ErrCollectionExists when the collection already exists.
+func CreateCollection(ctx context.Context, redisClient *redis.Client, indexName, name, embeddingModel string, embeddingDimension int, options IndexOptions) (Collection, error) {
+	if err := ValidateCollectionName(name); err != nil {
+		return Collection{}, err
+	}
+	if embeddingModel == DefaultEmbeddingModelName {
+		embeddingModel = ""
+	}
+	if _, ok := LookupEmbeddingModel(embeddingModel); embeddingModel != "" && !ok {
+		return Collection{}, fmt.Errorf("%w: %s", ErrUnknownEmbeddingModel, embeddingModel)
+	}
+
+	namesKey, modelsKey := collectionsKeyOf(indexName)
+	exists, err := redisClient.SIsMember(ctx, namesKey, name).Result()
+	if err != nil {
+		return Collection{}, fmt.Errorf("failed to check collection %s: %w", name, err)
+	}
+	if exists {
+		return Collection{}, fmt.Errorf("%w: %s", ErrCollectionExists, name)
+	}
+
+	collection := namedCollection(indexName, name)
+	collection.EmbeddingModel = embeddingModel
+	options.KeyPrefix = collection.KeyPrefix
+	if err := CreateEmbeddingIndexWithOptions(ctx, redisClient, collection.IndexName, collection.Dimension(embeddingDimension), options); err != nil {
+		if strings.Contains(err.Error(), "Index already exists") {
+			return Collection{}, fmt.Errorf("%w: %s", ErrCollectionExists, name)
+		}
+		return Collection{}, fmt.Errorf("failed to create the index of collection %s: %w", name, err)
+	}
+	_, err = redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if embeddingModel != "" {
+			pipe.HSet(ctx, modelsKey, name, embeddingModel)
+		}
+		pipe.SAdd(ctx, namesKey, name)
+		return nil
+	})
+	if err != nil {
+		return Collection{}, fmt.Errorf("failed to register collection %s: %w", name, err)
+	}
+	events.Record(events.TypeCollectionCreated, fmt.Sprintf("Collection %s created", name), map[string]any{
+		"collection":      name,
+		"index":           collection.IndexName,
+		"db":              redisClient.Options().DB,
+		"embedding_model": embeddingModel,
+	})
+	return collection, nil
+}
+
+// DeleteCollection drops the index of a collection and deletes its documents.
+// It returns ErrCollectionNotFound when the collection does not exist.
+func DeleteCollection(ctx context.Context, redisClient *redis.Client, indexName, name string) error {
+	collection, err := ResolveCollection(ctx, redisClient, indexName, name)
+	if errors.Is(err, ErrUnknownEmbeddingModel) {
+		// The model is no longer registered, the collection can still be deleted
+		collection, err = namedCollection(indexName, name), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := DropIndex(ctx, redisClient, collection.IndexName).Err(); err != nil {
+		exists, existsErr := IndexExists(ctx, redisClient, collection.IndexName)
+		if existsErr != nil || exists {
+			return fmt.Errorf("failed to drop the index of collection %s: %w", name, err)
+		}
+	}
+	namesKey, modelsKey := collectionsKeyOf(indexName)
+	_, err = redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.SRem(ctx, namesKey, name)
+		pipe.HDel(ctx, modelsKey, name)
+		pipe.Del(ctx, changesKey(collection.KeyPrefix))
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to unregister collection %s: %w", name, err)
+	}
+	events.Record(events.TypeCollectionDeleted, fmt.Sprintf("Collection %s deleted with its documents", name), map[string]any{
+		"collection": name,
+		"index":      collection.IndexName,
+		"db":         redisClient.Options().DB,
+	})
+	return nil
+}
+
+// ListCollections returns the sorted names of the collections of the tenant of an index
+func ListCollections(ctx context.Context, redisClient *redis.Client, indexName string) ([]string, error) {
+	namesKey, _ := collectionsKeyOf(indexName)
+	names, err := redisClient.SMembers(ctx, namesKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list collections: %w", err)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// NewDocumentID generates a document ID with the key prefix of a collection (see SetDocumentIDGenerator)
+func NewDocumentID(keyPrefix string) string {
+	if keyPrefix == "" {
+		keyPrefix = documentKeyPrefix
+	}
+	return keyPrefix + documentIDGenerator.NewID()
+}
+
+// isCollectionDocumentID checks that an ID designates a document of a named collection ("col:<name>:<id>")
+func isCollectionDocumentID(id string) bool {
+	return documentCollectionName(id) != ""
+}
+
+// documentCollectionName returns the name of the named collection of a document ID ("col:<name>:<id>", after the
+// prefix of its tenant), or "" for the documents of the default collection
+func documentCollectionName(id string) string {
+	rest, found := strings.CutPrefix(strings.TrimPrefix(id, tenantNamespace(id)), collectionKeyPrefix)
+	if !found {
+		return ""
+	}
+	name, docID, found := strings.Cut(rest, ":")
+	if !found || docID == "" || !collectionNamePattern.MatchString(name) {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,78 @@
+package store
+
+import (
+	"errors"
+	"unicode/utf8"
+	"vectormind/models"
+)
+
+// ValidateMaxContentChars checks the maximum size of the content returned by a search or a document (0: no limit)
+func ValidateMaxContentChars(maxChars int) error {
+	if maxChars < 0 {
+		return errors.New("max_content_chars cannot be negative")
+	}
+	return nil
+}
+
+// ValidateContentOffset checks the offset of the content returned by a document, in characters
+func ValidateContentOffset(offset int) error {
+	if offset < 0 {
+		return errors.New("content_offset cannot be negative")
+	}
+	return nil
+}
+
+// ContentWindow returns at most maxChars characters of a content from the character offset (0: up to the end), the
+// number of characters of the content, and the offset of the characters left after the window (0 when none are)
+func ContentWindow(content string, offset, maxChars int) (window string, length int, nextOffset int) {
+	length = utf8.RuneCountInString(content)
+	if offset >= length {
+		return "", length, 0
+	}
+	runes := []rune(content)[offset:]
+	if maxChars > 0 && maxChars < len(runes) {
+		return string(runes[:maxChars]), length, offset + maxChars
+	}
+	return string(runes), length, 0
+}
+
+// TruncateSearchResults cuts the content of the search results to maxChars characters (0: no limit), the truncated
+// results give the offset of the rest of their content (see ContentWindow)
+func TruncateSearchResults(results []models.SimilaritySearchResult, maxChars int) {
+	if maxChars <= 0 {
+		return
+	}
+	for i := range results {
+		if window, length, next := ContentWindow(results[i].Content, 0, maxChars); next > 0 {
+			results[i].Content = window
+			results[i].IsTruncated = true
+			results[i].ContentLength = length
+			results[i].NextOffset = next
+		}
+	}
+}
+
+// TruncateHybridResults cuts the content of the hybrid search results to maxChars characters (see TruncateSearchResults)
+func TruncateHybridResults(results []models.HybridSearchResult, maxChars int) {
+	if maxChars <= 0 {
+		return
+	}
+	for i := range results {
+		if window, length, next := ContentWindow(results[i].Content, 0, maxChars); next > 0 {
+			results[i].Content = window
+			results[i].IsTruncated = true
+			results[i].ContentLength = length
+			results[i].NextOffset = next
+		}
+	}
+}
+
+// DocumentContentWindow keeps the part of the content of a document from the character offset, at most maxChars
+// characters of it (0: up to the end), and sets its content length and the offset of the rest
+func DocumentContentWindow(document *models.DocumentRecord, offset, maxChars int) {
+	if offset == 0 && maxChars == 0 {
+		return
+	}
+	document.Content, document.ContentLength, document.NextOffset = ContentWindow(document.Content, offset, maxChars)
+	document.IsTruncated = document.NextOffset > 0
+}
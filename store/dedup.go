@@ -0,0 +1,109 @@
+package store
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Deduplication modes of the ingestion, applied when the content of a document is already stored
+const (
+	// DedupOff always stores a new document (default)
+	DedupOff = "off"
+	// DedupSkip does not store the document and returns the ID of the stored one
+	DedupSkip = "skip"
+	// DedupUpsert stores the document in place of the stored one (same ID, new label, metadata and expiration)
+	DedupUpsert = "upsert"
+)
+
+// ValidateDedupMode checks that the deduplication mode is supported (an empty mode means DedupOff)
+func ValidateDedupMode(mode string) error {
+	switch mode {
+	case "", DedupOff, DedupSkip, DedupUpsert:
+		return nil
+	default:
+		return fmt.Errorf("unknown dedup mode %q (use %q, %q or %q)", mode, DedupOff, DedupSkip, DedupUpsert)
+	}
+}
+
+// dedupEnabled reports whether a deduplication mode looks for the stored documents
+func dedupEnabled(mode string) bool {
+	return mode == DedupSkip || mode == DedupUpsert
+}
+
+// NormalizeContent normalizes a content before hashing: the leading and trailing white spaces are removed
+// and the other runs of white spaces are replaced by a single space
+func NormalizeContent(content string) string {
+	return strings.Join(strings.Fields(content), " ")
+}
+
+// ContentDigest returns the SHA-256 of the normalized content, stored in the content_hash field of the documents.
+// When the encryption is enabled, it is an HMAC-SHA256 keyed with the encryption key.
+func ContentDigest(content string) string {
+	if contentHashKey == nil {
+		return HashContent(NormalizeContent(content))
+	}
+	mac := hmac.New(sha256.New, contentHashKey)
+	mac.Write([]byte(NormalizeContent(content)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// FindDocumentsByContent returns, for each content, the ID of a document of the index with the same normalized
+// content ("" when there is none). The contents are looked up with a single round trip.
+func FindDocumentsByContent(ctx context.Context, redisClient *redis.Client, indexName string, contents []string) ([]string, error) {
+	pipe := redisClient.Pipeline()
+	cmds := make([]*redis.FTSearchCmd, len(contents))
+	for i, content := range contents {
+		cmds[i] = pipe.FTSearchWithArgs(ctx,
+			indexName,
+			activeVersionsQuery(fmt.Sprintf("@content_hash:{%s}", ContentDigest(content))),
+			&redis.FTSearchOptions{
+				NoContent:      true,
+				LimitOffset:    0,
+				Limit:          1,
+				DialectVersion: 2,
+			},
+		)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, fmt.Errorf("failed to look for duplicate documents (the index may need a rebuild): %w", err)
+	}
+
+	ids := make([]string, len(contents))
+	for i, cmd := range cmds {
+		if docs := cmd.Val().Docs; len(docs) > 0 {
+			ids[i] = docs[0].ID
+		}
+	}
+	return ids, nil
+}
+
+// FindDocumentByContent returns the ID of a document of the index with the same normalized content ("" when there is none)
+func FindDocumentByContent(ctx context.Context, redisClient *redis.Client, indexName, content string) (string, error) {
+	ids, err := FindDocumentsByContent(ctx, redisClient, indexName, []string{content})
+	if err != nil {
+		return "", err
+	}
+	return ids[0], nil
+}
+
+// DedupDocumentID returns the ID under which a document of a collection is stored with the deduplication mode
+// (a new ID, or the ID of the stored document with the same content), and whether the document is a duplicate
+// to skip (already stored under this ID)
+func DedupDocumentID(ctx context.Context, redisClient *redis.Client, collection Collection, content, mode string) (string, bool, error) {
+	if dedupEnabled(mode) {
+		id, err := FindDocumentByContent(ctx, redisClient, collection.IndexName, content)
+		if err != nil {
+			return "", false, err
+		}
+		if id != "" {
+			return id, mode == DedupSkip, nil
+		}
+	}
+	return NewDocumentID(collection.KeyPrefix), false, nil
+}
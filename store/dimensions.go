@@ -0,0 +1,131 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// dimensionScanCount is the number of keys requested by each SCAN of a dimension check
+const dimensionScanCount = 500
+
+// queryDimensionMarkers are the parts of the search errors of RediSearch when the size of the query vector
+// does not match the dimension of the index (lowercase)
+var queryDimensionMarkers = []string{
+	"vector blob size",
+	"does not match index's expected size",
+}
+
+// DimensionMismatch is a stored document whose vector does not have the dimension of its index
+type DimensionMismatch struct {
+	ID        string
+	Dimension int
+}
+
+// DimensionCheck is the result of the check of the dimension of the stored vectors of an index
+type DimensionCheck struct {
+	Dimension  int // dimension of the index
+	Checked    int
+	Mismatched int
+	Documents  []DimensionMismatch // the first mismatched documents (up to the limit of the check)
+}
+
+// isQueryDimensionError reports whether a search error shows that the query vector does not have the dimension of the index
+func isQueryDimensionError(err error) bool {
+	message := strings.ToLower(err.Error())
+	for _, marker := range queryDimensionMarkers {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// queryDimensionError returns the ErrDimensionMismatch error of a search whose query vector does not have the dimension of the index
+func queryDimensionError(ctx context.Context, redisClient *redis.Client, indexName string, queryDimension int, err error) error {
+	indexDimension, dimensionErr := IndexEmbeddingDimension(ctx, redisClient, indexName)
+	if dimensionErr != nil {
+		return fmt.Errorf("%w: index %s: the query vector has dimension %d: %w", ErrDimensionMismatch, indexName, queryDimension, err)
+	}
+	return fmt.Errorf("%w: index %s stores vectors of dimension %d, the query vector has dimension %d (the embedding model has changed, re-embed the documents)", ErrDimensionMismatch, indexName, indexDimension, queryDimension)
+}
+
+// CheckDocumentDimensions compares the dimension of the vectors of the documents of a collection with the dimension of its index.
+// The documents whose vector has another dimension are not indexed by Redis: they are missing from the search results.
+// At most limit mismatched documents are returned (all of them are counted).
+func CheckDocumentDimensions(ctx context.Context, redisClient *redis.Client, collection Collection, limit int) (DimensionCheck, error) {
+	indexDimension, err := IndexEmbeddingDimension(ctx, redisClient, collection.IndexName)
+	if err != nil {
+		return DimensionCheck{}, err
+	}
+	check := DimensionCheck{Dimension: indexDimension, Documents: []DimensionMismatch{}}
+
+	keyPrefix := collection.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = documentKeyPrefix
+	}
+	var cursor uint64
+	for {
+		var ids []string
+		ids, cursor, err = redisClient.ScanType(ctx, cursor, keyPrefix+"*", dimensionScanCount, "hash").Result()
+		if err != nil {
+			return DimensionCheck{}, fmt.Errorf("failed to list the documents of index %s: %w", collection.IndexName, err)
+		}
+		dimensions, err := documentDimensions(ctx, redisClient, ids)
+		if err != nil {
+			return DimensionCheck{}, err
+		}
+		for i, dimension := range dimensions {
+			if dimension < 0 {
+				continue // deleted meanwhile, or not a document
+			}
+			check.Checked++
+			if dimension == indexDimension {
+				continue
+			}
+			check.Mismatched++
+			if len(check.Documents) < limit {
+				check.Documents = append(check.Documents, DimensionMismatch{ID: ids[i], Dimension: dimension})
+			}
+		}
+		if cursor == 0 {
+			return check, nil
+		}
+	}
+}
+
+// documentDimensions returns the dimension of the vectors of documents, in the same order as the IDs (-1 for the
+// missing documents and the hashes without vector). The documents stored without dimension field get the size of their vector.
+func documentDimensions(ctx context.Context, redisClient *redis.Client, ids []string) ([]int, error) {
+	dimensionCmds := make([]*redis.StringCmd, len(ids))
+	sizeCmds := make([]*redis.IntCmd, len(ids))
+	_, err := redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			dimensionCmds[i] = pipe.HGet(ctx, id, "dimension")
+			sizeCmds[i] = pipe.HStrLen(ctx, id, vectorField)
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("failed to read the dimension of the documents: %w", err)
+	}
+
+	dimensions := make([]int, len(ids))
+	for i := range ids {
+		size := sizeCmds[i].Val()
+		if size == 0 {
+			dimensions[i] = -1
+			continue
+		}
+		dimension, err := strconv.Atoi(dimensionCmds[i].Val())
+		if err != nil {
+			dimension = int(size / 4) // float32 vector
+		}
+		dimensions[i] = dimension
+	}
+	return dimensions, nil
+}
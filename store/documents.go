@@ -0,0 +1,403 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"vectormind/models"
+	"vectormind/splitter"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// documentKeyPrefix is the prefix of the keys indexed by the embedding index ("doc:", or the keys of the LangChain
+// index with its schema, see SetSchema)
+var documentKeyPrefix = "doc:"
+
+// ErrDocumentNotFound is returned when a document does not exist
+var ErrDocumentNotFound = fmt.Errorf("document %w", ErrNotFound)
+
+// ErrDocumentExists is returned when a document is created with the ID of an existing document
+var ErrDocumentExists = errors.New("document already exists")
+
+// maxCallerDocumentIDLength is the maximum length of the document IDs chosen by the callers
+const maxCallerDocumentIDLength = 512
+
+// Handling of a document created with the ID of an existing document
+const (
+	// IDConflictError refuses to create the document (default)
+	IDConflictError = "error"
+	// IDConflictOverwrite replaces the existing document
+	IDConflictOverwrite = "overwrite"
+)
+
+var defaultIDConflict = IDConflictError
+
+// ValidateIDConflict checks that the handling of the ID conflicts is supported (an empty value means the default handling)
+func ValidateIDConflict(onConflict string) error {
+	switch onConflict {
+	case "", IDConflictError, IDConflictOverwrite:
+		return nil
+	default:
+		return fmt.Errorf("unknown on_conflict %q (use %q or %q)", onConflict, IDConflictError, IDConflictOverwrite)
+	}
+}
+
+// SetDefaultIDConflict sets the handling of the ID conflicts of the requests that do not choose one
+func SetDefaultIDConflict(onConflict string) error {
+	if err := ValidateIDConflict(onConflict); err != nil {
+		return err
+	}
+	if onConflict != "" {
+		defaultIDConflict = onConflict
+	}
+	return nil
+}
+
+// OverwriteOnIDConflict reports whether a document created with the ID of an existing document replaces it
+func OverwriteOnIDConflict(onConflict string) bool {
+	if onConflict == "" {
+		onConflict = defaultIDConflict
+	}
+	return onConflict == IDConflictOverwrite
+}
+
+// CallerDocumentID returns the document ID of an ID chosen by the caller (e.g. the ID of the document in an external system),
+// in the collection of the key prefix: the key prefix is added unless the ID already starts with it
+func CallerDocumentID(keyPrefix, id string) (string, error) {
+	if keyPrefix == "" {
+		keyPrefix = documentKeyPrefix
+	}
+	if id == "" || id == keyPrefix {
+		return "", fmt.Errorf("the document id cannot be empty")
+	}
+	if len(id) > maxCallerDocumentIDLength {
+		return "", fmt.Errorf("the document id is too long (%d characters max)", maxCallerDocumentIDLength)
+	}
+	if strings.ContainsFunc(id, func(r rune) bool { return unicode.IsSpace(r) || unicode.IsControl(r) }) {
+		return "", fmt.Errorf("invalid document id %q (spaces and control characters are not allowed)", id)
+	}
+	if !strings.HasPrefix(id, keyPrefix) {
+		id = keyPrefix + id
+	}
+	return id, nil
+}
+
+// DocumentTTL converts the ttl_seconds of a request to the expiration of the stored documents (0 means no expiration)
+func DocumentTTL(seconds int) (time.Duration, error) {
+	if seconds < 0 {
+		return 0, fmt.Errorf("ttl_seconds cannot be negative")
+	}
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// ValidateDocumentID checks that an ID designates a document (and not another Redis key), of any tenant
+// (see ValidateTenantDocumentID)
+func ValidateDocumentID(id string) error {
+	if isCollectionDocumentID(id) {
+		return nil
+	}
+	local := strings.TrimPrefix(id, tenantNamespace(id))
+	if !strings.HasPrefix(local, documentKeyPrefix) || len(local) == len(documentKeyPrefix) {
+		return fmt.Errorf("invalid document id %q (document ids start with %q, or %q in a collection)", id, documentKeyPrefix, collectionKeyPrefix+"<collection>:")
+	}
+	return nil
+}
+
+// DeleteDocument removes a document and its embedding from Redis (with the document versioning, the document is only
+// marked deleted, see SetVersioning).
+// It returns false when the document does not exist.
+func DeleteDocument(ctx context.Context, redisClient *redis.Client, id string) (bool, error) {
+	if err := ValidateDocumentID(id); err != nil {
+		return false, err
+	}
+	if versioning {
+		deleted, err := softDeleteDocument(ctx, redisClient, id)
+		if err != nil {
+			return false, fmt.Errorf("failed to delete document %s: %w", id, err)
+		}
+		return deleted, nil
+	}
+
+	deleted, err := redisClient.Del(ctx, id).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to delete document %s: %w", id, err)
+	}
+	if deleted > 0 {
+		recordChanges(ctx, redisClient, ChangeDeleted, []string{id})
+	}
+	return deleted > 0, nil
+}
+
+// DeleteDocuments removes several documents in a single round trip (with the document versioning, the documents are
+// marked deleted with a transaction each).
+// It returns the IDs of the deleted documents and the IDs of the documents that do not exist.
+func DeleteDocuments(ctx context.Context, redisClient *redis.Client, ids []string) ([]string, []string, error) {
+	for _, id := range ids {
+		if err := ValidateDocumentID(id); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if versioning {
+		// The documents are marked deleted one by one (see softDeleteDocument)
+		deleted := make([]string, 0, len(ids))
+		notFound := make([]string, 0)
+		for _, id := range ids {
+			ok, err := softDeleteDocument(ctx, redisClient, id)
+			if err != nil {
+				return deleted, notFound, fmt.Errorf("failed to delete documents: %w", err)
+			}
+			if ok {
+				deleted = append(deleted, id)
+			} else {
+				notFound = append(notFound, id)
+			}
+		}
+		return deleted, notFound, nil
+	}
+	return purgeDocuments(ctx, redisClient, ids)
+}
+
+// purgeDocuments deletes documents from Redis with a single round trip, even with the document versioning: the
+// rollbacks remove the documents they created instead of marking them deleted. It returns the deleted IDs and the
+// IDs not found.
+func purgeDocuments(ctx context.Context, redisClient *redis.Client, ids []string) ([]string, []string, error) {
+	pipe := redisClient.Pipeline()
+	cmds := make([]*redis.IntCmd, len(ids))
+	for i, id := range ids {
+		cmds[i] = pipe.Del(ctx, id)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, nil, fmt.Errorf("failed to delete documents: %w", err)
+	}
+
+	deleted := make([]string, 0, len(ids))
+	notFound := make([]string, 0)
+	for i, cmd := range cmds {
+		if cmd.Val() > 0 {
+			deleted = append(deleted, ids[i])
+		} else {
+			notFound = append(notFound, ids[i])
+		}
+	}
+	recordChanges(ctx, redisClient, ChangeDeleted, deleted)
+	return deleted, notFound, nil
+}
+
+// DeleteMatchingDocuments deletes all the documents of an index matching a filter (labels and parent ID of the
+// search options, e.g. the chunks of a document to ingest again, or a dataset). The IDs of the documents are listed
+// first, then the documents are deleted by batches of retagBatchSize with a single round trip each (see
+// DeleteDocuments). It returns the number of deleted documents (the documents deleted meanwhile are not counted).
+func DeleteMatchingDocuments(ctx context.Context, redisClient *redis.Client, indexName string, filter SearchOptions) (int, error) {
+	if len(filterExpressions(filter)) == 0 {
+		return 0, ErrFilterRequired
+	}
+	ids, err := matchingDocumentIDs(ctx, redisClient, indexName, filter)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for start := 0; start < len(ids); start += retagBatchSize {
+		deleted, _, err := DeleteDocuments(ctx, redisClient, ids[start:min(start+retagBatchSize, len(ids))])
+		if err != nil {
+			return count, err
+		}
+		count += len(deleted)
+	}
+	return count, nil
+}
+
+// DocumentUpdate holds the new content and embedding of a document.
+// A nil label or metadata keeps the stored value.
+type DocumentUpdate struct {
+	Content   string
+	Embedding []float32
+	Label     *string
+	Metadata  *string
+}
+
+// UpdateDocument replaces the content and the embedding of an existing document, and its label and metadata when provided.
+// With the document versioning, the replaced version is kept (see SetVersioning).
+// The update is applied in a transaction watching the document, so that a document deleted (or updated) meanwhile
+// is never partially updated or recreated. It returns ErrDocumentNotFound when the document does not exist.
+func UpdateDocument(ctx context.Context, redisClient *redis.Client, id string, update DocumentUpdate) (Document, error) {
+	if err := ValidateDocumentID(id); err != nil {
+		return Document{}, err
+	}
+
+	var doc Document
+	err := redisClient.Watch(ctx, func(tx *redis.Tx) error {
+		stored, err := tx.HMGet(ctx, id, contentField, "label", "metadata", versionStateField, versionField).Result()
+		if err != nil {
+			return err
+		}
+		if stored[0] == nil || stored[3] == versionInactive {
+			return ErrDocumentNotFound
+		}
+		// With the document versioning, the current version is kept as a previous version
+		var previous map[string]string
+		if versioning {
+			if previous, err = tx.HGetAll(ctx, id).Result(); err != nil {
+				return err
+			}
+		}
+
+		doc = Document{
+			ID:        id,
+			Content:   update.Content,
+			Embedding: update.Embedding,
+			Quality:   splitter.ScoreChunk(update.Content).Score,
+		}
+		doc.Label, _ = stored[1].(string)
+		storedMetadata, _ := stored[2].(string)
+		if doc.Metadata, err = DecryptField(storedMetadata); err != nil {
+			return err
+		}
+		if update.Label != nil {
+			doc.Label = *update.Label
+		}
+		if update.Metadata != nil {
+			doc.Metadata = *update.Metadata
+		}
+		content, metadata, err := encryptDocumentFields(doc.Content, doc.Metadata)
+		if err != nil {
+			return err
+		}
+
+		// created_at is kept, the update time is stored in updated_at
+		fields := map[string]any{
+			contentField:   content,
+			"label":        doc.Label,
+			"metadata":     metadata,
+			"updated_at":   time.Now().Unix(),
+			"quality":      doc.Quality,
+			vectorField:    floatsToBytes(doc.Embedding),
+			"dimension":    len(doc.Embedding),
+			"content_hash": ContentDigest(doc.Content),
+		}
+		for field, value := range flattenMetadata(doc.Metadata) {
+			fields[field] = value
+		}
+		for field, value := range sectionFields(doc.Content) {
+			fields[field] = value
+		}
+		version := storedVersion(stored[4])
+		if previous != nil {
+			fields[versionField] = version + 1
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if previous != nil {
+				pipe.HSet(ctx, VersionID(id, version), archivedVersion(previous, version))
+			}
+			// The flattened values of the previous metadata and the previous section fields are replaced, and the
+			// offsets of a chunk in its original document no longer match its content (its parent and index are kept)
+			pipe.HDel(ctx, id, append(metadataHashFields(), titleField, hierarchyField, "start_offset", "end_offset")...)
+			pipe.HSet(ctx, id, fields)
+			queueChange(ctx, pipe, ChangeUpdated, id)
+			return nil
+		})
+		if err == nil {
+			recordWrite(ctx, doc)
+		}
+		return err
+	}, id)
+
+	if errors.Is(err, ErrDocumentNotFound) {
+		return Document{}, err
+	}
+	if err != nil {
+		return Document{}, fmt.Errorf("failed to update document %s: %w", id, err)
+	}
+	return doc, nil
+}
+
+// existingDocuments reports which of the documents exist, with a single round trip
+func existingDocuments(ctx context.Context, redisClient redis.Cmdable, ids []string) ([]bool, error) {
+	cmds := make([]*redis.IntCmd, len(ids))
+	_, err := redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.Exists(ctx, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check the documents: %w", err)
+	}
+	existing := make([]bool, len(ids))
+	for i, cmd := range cmds {
+		existing[i] = cmd.Val() > 0
+	}
+	return existing, nil
+}
+
+// DocumentExists checks if a document exists
+func DocumentExists(ctx context.Context, redisClient *redis.Client, id string) (bool, error) {
+	count, err := redisClient.Exists(ctx, id).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check document %s: %w", id, err)
+	}
+	return count > 0, nil
+}
+
+// GetDocument returns a stored document, with its embedding vector when includeEmbedding is true.
+// It returns ErrDocumentNotFound when the document does not exist.
+func GetDocument(ctx context.Context, redisClient *redis.Client, id string, includeEmbedding bool) (models.DocumentRecord, error) {
+	if err := ValidateDocumentID(id); err != nil {
+		return models.DocumentRecord{}, err
+	}
+
+	fields, err := redisClient.HGetAll(ctx, id).Result()
+	if err != nil {
+		return models.DocumentRecord{}, fmt.Errorf("failed to get document %s: %w", id, err)
+	}
+	if len(fields) == 0 || fields[versionStateField] == versionInactive {
+		// The previous versions and the deleted documents are only read with their versions (see DocumentVersions)
+		return models.DocumentRecord{}, ErrDocumentNotFound
+	}
+
+	record := documentRecord(id, fields, includeEmbedding)
+	if ttl, err := redisClient.TTL(ctx, id).Result(); err == nil && ttl > 0 {
+		record.ExpiresAt = time.Now().Add(ttl).Format(time.RFC3339)
+	}
+	return record, nil
+}
+
+// documentRecord converts the hash fields of a document read from Redis to a document record
+func documentRecord(id string, fields map[string]string, includeEmbedding bool) models.DocumentRecord {
+	fields = schemaDocumentFields(fields)
+
+	result := DocumentToSearchResult(redis.Document{ID: id, Fields: fields})
+	record := models.DocumentRecord{
+		ID:          result.ID,
+		Content:     result.Content,
+		Label:       result.Label,
+		Labels:      result.Labels,
+		Metadata:    result.Metadata,
+		Quality:     result.Quality,
+		CreatedAt:   result.CreatedAt,
+		SourceID:    fields["source_id"],
+		OriginalRef: fields["original_ref"],
+	}
+	record.ChunkProvenance = result.ChunkProvenance
+	if updatedAtUnix, err := strconv.ParseInt(fields["updated_at"], 10, 64); err == nil {
+		record.UpdatedAt = time.Unix(updatedAtUnix, 0).Format(time.RFC3339)
+	}
+	if version, err := strconv.Atoi(fields[versionField]); err == nil {
+		record.Version = version
+	}
+	if dimension, err := strconv.Atoi(fields["dimension"]); err == nil {
+		record.Dimension = dimension
+	} else {
+		record.Dimension = len(fields["embedding"]) / 4 // stored without dimension field
+	}
+	if includeEmbedding {
+		record.Embedding = bytesToFloats([]byte(fields["embedding"]))
+	}
+	return record
+}
@@ -0,0 +1,27 @@
+package store
+
+import (
+	"vectormind/splitter"
+)
+
+// EmailChunks returns the chunks of the messages of an email archive (see splitter.ChunkEmail) with the metadata
+// of each chunk: the metadata of the request completed with the headers of its message (see splitter.EmailMetadata)
+func EmailChunks(messages []splitter.EmailMessage, metadata string, maxTokens int) ([]string, []string, error) {
+	chunks := []string{}
+	chunkMetadata := []string{}
+	for _, message := range messages {
+		messageChunks := splitter.ChunkEmail(message, maxTokens)
+		if len(messageChunks) == 0 {
+			continue
+		}
+		messageMetadata, err := MergeMetadata(metadata, splitter.EmailMetadata(message))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, chunk := range messageChunks {
+			chunks = append(chunks, chunk)
+			chunkMetadata = append(chunkMetadata, messageMetadata)
+		}
+	}
+	return chunks, chunkMetadata, nil
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+	"vectormind/events"
+	"vectormind/models"
+
+	"github.com/openai/openai-go"
+)
+
+// EmbeddingFallback is the embedding provider used when the primary provider fails.
+// After FailureThreshold consecutive failures of the primary provider, the circuit opens:
+// the primary provider is skipped during the cooldown, then tried again.
+type EmbeddingFallback struct {
+	client           openai.Client
+	modelId          string
+	failureThreshold int
+	cooldown         time.Duration
+	dimension        int // expected dimension of the vectors (0 until verified)
+
+	mutex               sync.Mutex
+	consecutiveFailures int
+	openUntil           time.Time
+	stats               models.EmbeddingStats
+}
+
+// embeddingFallback is the fallback embedding provider, nil when there is no fallback
+var embeddingFallback *EmbeddingFallback
+
+// NewEmbeddingFallback creates a fallback embedding provider.
+// A failure threshold of 0 or less never opens the circuit.
+func NewEmbeddingFallback(client openai.Client, modelId string, failureThreshold int, cooldown time.Duration) *EmbeddingFallback {
+	return &EmbeddingFallback{
+		client:           client,
+		modelId:          modelId,
+		failureThreshold: failureThreshold,
+		cooldown:         cooldown,
+	}
+}
+
+// SetEmbeddingFallback sets the fallback embedding provider (nil disables the fallback)
+func SetEmbeddingFallback(fallback *EmbeddingFallback) {
+	embeddingFallback = fallback
+}
+
+// VerifyDimension checks that the fallback provider returns vectors of the dimension of the index,
+// afterwards fallback vectors of another dimension are rejected
+func (fallback *EmbeddingFallback) VerifyDimension(ctx context.Context, dimension int) error {
+	embeddings, err := requestEmbeddings(ctx, fallback.client, []string{"Hello World"}, fallback.modelId)
+	if err != nil {
+		return fmt.Errorf("failed to create a test embedding with the fallback provider: %w", err)
+	}
+	if len(embeddings[0]) != dimension {
+		return fmt.Errorf("the fallback model %s returns vectors of dimension %d, the primary model %d", fallback.modelId, len(embeddings[0]), dimension)
+	}
+
+	fallback.mutex.Lock()
+	defer fallback.mutex.Unlock()
+	fallback.dimension = dimension
+	return nil
+}
+
+// Stats returns the usage statistics of the primary and fallback providers
+func (fallback *EmbeddingFallback) Stats() models.EmbeddingStats {
+	fallback.mutex.Lock()
+	defer fallback.mutex.Unlock()
+
+	stats := fallback.stats
+	stats.CircuitOpen = time.Now().Before(fallback.openUntil)
+	return stats
+}
+
+// GetEmbeddingStats returns the usage statistics of the embedding providers (nil when there is no fallback)
+func GetEmbeddingStats() *models.EmbeddingStats {
+	if embeddingFallback == nil {
+		return nil
+	}
+	stats := embeddingFallback.Stats()
+	return &stats
+}
+
+// circuitOpen reports whether the primary provider is skipped
+func (fallback *EmbeddingFallback) circuitOpen() bool {
+	fallback.mutex.Lock()
+	defer fallback.mutex.Unlock()
+
+	if time.Now().Before(fallback.openUntil) {
+		fallback.stats.PrimarySkipped++
+		return true
+	}
+	return false
+}
+
+// recordPrimary records the outcome of a request to the primary provider, and opens the circuit
+// after too many consecutive failures
+func (fallback *EmbeddingFallback) recordPrimary(err error) {
+	fallback.mutex.Lock()
+	defer fallback.mutex.Unlock()
+
+	fallback.stats.PrimaryRequests++
+	if err == nil {
+		fallback.consecutiveFailures = 0
+		return
+	}
+
+	fallback.stats.PrimaryFailures++
+	fallback.consecutiveFailures++
+	if fallback.failureThreshold > 0 && fallback.consecutiveFailures >= fallback.failureThreshold {
+		log.Printf("🟠 Primary embedding provider failed %d times in a row, using the fallback provider for %s: %v", fallback.consecutiveFailures, fallback.cooldown, err)
+		fallback.openUntil = time.Now().Add(fallback.cooldown)
+		events.Record(events.TypeCircuitOpened, "Primary embedding provider circuit opened, using the fallback provider", map[string]any{
+			"failures":    fallback.consecutiveFailures,
+			"cooldown_ms": fallback.cooldown.Milliseconds(),
+			"error":       err.Error(),
+		})
+		fallback.consecutiveFailures = 0
+	}
+}
+
+// embed creates the embeddings with the fallback provider
+func (fallback *EmbeddingFallback) embed(ctx context.Context, texts []string) ([][]float32, error) {
+	embeddings, err := requestEmbeddings(ctx, fallback.client, texts, fallback.modelId)
+	if err == nil && fallback.dimension > 0 {
+		for _, embedding := range embeddings {
+			if len(embedding) != fallback.dimension {
+				err = fmt.Errorf("the fallback provider returned a vector of dimension %d instead of %d", len(embedding), fallback.dimension)
+				break
+			}
+		}
+	}
+
+	fallback.mutex.Lock()
+	defer fallback.mutex.Unlock()
+	fallback.stats.FallbackRequests++
+	if err != nil {
+		fallback.stats.FallbackFailures++
+		return nil, fmt.Errorf("fallback embedding provider: %w", err)
+	}
+	return embeddings, nil
+}
@@ -0,0 +1,137 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultEmbeddingModelName is the name of the embedding model of EMBEDDING_MODEL, used by the collections
+// without a model of their own
+const DefaultEmbeddingModelName = "default"
+
+// ErrUnknownEmbeddingModel is returned when a collection designates an embedding model that is not registered
+var ErrUnknownEmbeddingModel = errors.New("unknown embedding model")
+
+// EmbeddingModel is a registered embedding model, designated by its name when creating a collection
+type EmbeddingModel struct {
+	Name      string `json:"name"`
+	ModelID   string `json:"model_id"`
+	Dimension int    `json:"dimension"`
+	MaxTokens int    `json:"max_tokens"`
+}
+
+// embeddingModels holds the registered embedding models by name
+var (
+	embeddingModels      = map[string]EmbeddingModel{}
+	embeddingModelsMutex sync.RWMutex
+)
+
+// ParseEmbeddingModels parses a list of named embedding models, e.g. "fast=ai/all-minilm,accurate=ai/mxbai-embed-large"
+// (the names use the characters of the collection names, and "default" is reserved)
+func ParseEmbeddingModels(spec string) (map[string]string, error) {
+	modelIds := map[string]string{}
+	for _, entry := range strings.Split(spec, ",") {
+		if entry = strings.TrimSpace(entry); entry == "" {
+			continue
+		}
+		name, modelId, found := strings.Cut(entry, "=")
+		name, modelId = strings.TrimSpace(name), strings.TrimSpace(modelId)
+		if !found || modelId == "" {
+			return nil, fmt.Errorf("invalid embedding model %q (expected name=model)", entry)
+		}
+		if !collectionNamePattern.MatchString(name) || name == DefaultEmbeddingModelName {
+			return nil, fmt.Errorf("invalid embedding model name %q (use letters, digits, '_' or '-', %q is reserved)", name, DefaultEmbeddingModelName)
+		}
+		if _, exists := modelIds[name]; exists {
+			return nil, fmt.Errorf("duplicate embedding model name %q", name)
+		}
+		modelIds[name] = modelId
+	}
+	return modelIds, nil
+}
+
+// RegisterEmbeddingModel registers an embedding model (replacing a model of the same name)
+func RegisterEmbeddingModel(model EmbeddingModel) {
+	embeddingModelsMutex.Lock()
+	defer embeddingModelsMutex.Unlock()
+	embeddingModels[model.Name] = model
+}
+
+// LookupEmbeddingModel returns a registered embedding model by name
+func LookupEmbeddingModel(name string) (EmbeddingModel, bool) {
+	embeddingModelsMutex.RLock()
+	defer embeddingModelsMutex.RUnlock()
+	model, ok := embeddingModels[name]
+	return model, ok
+}
+
+// ListEmbeddingModels returns the registered embedding models, sorted by name
+func ListEmbeddingModels() []EmbeddingModel {
+	embeddingModelsMutex.RLock()
+	defer embeddingModelsMutex.RUnlock()
+	models := make([]EmbeddingModel, 0, len(embeddingModels))
+	for _, model := range embeddingModels {
+		models = append(models, model)
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Name < models[j].Name })
+	return models
+}
+
+// isDefaultEmbeddingModel reports whether a model ID is the one of the default embedding model
+// (true when no model is registered)
+func isDefaultEmbeddingModel(modelId string) bool {
+	model, ok := LookupEmbeddingModel(DefaultEmbeddingModelName)
+	return !ok || model.ModelID == modelId
+}
+
+// Model returns the embedding model of a collection (defaultModel for the collections using the default model)
+func (collection Collection) Model(defaultModel EmbeddingModel) EmbeddingModel {
+	if model, ok := LookupEmbeddingModel(collection.EmbeddingModel); ok {
+		return model
+	}
+	return defaultModel
+}
+
+// ModelID returns the ID of the embedding model of a collection (defaultModelId for the collections using the default model)
+func (collection Collection) ModelID(defaultModelId string) string {
+	if model, ok := LookupEmbeddingModel(collection.EmbeddingModel); ok {
+		return model.ModelID
+	}
+	return defaultModelId
+}
+
+// Dimension returns the dimension of the vectors of a collection (defaultDimension for the collections using the default model)
+func (collection Collection) Dimension(defaultDimension int) int {
+	if model, ok := LookupEmbeddingModel(collection.EmbeddingModel); ok {
+		return model.Dimension
+	}
+	return defaultDimension
+}
+
+// DocumentModelID returns the ID of the embedding model of the collection of a document
+// (defaultModelId for the documents of the default collection and of the collections using the default model)
+func DocumentModelID(ctx context.Context, redisClient *redis.Client, id, defaultModelId string) (string, error) {
+	name := documentCollectionName(id)
+	if name == "" {
+		return defaultModelId, nil
+	}
+	_, modelsKey := collectionsKeyOf(id)
+	modelName, err := redisClient.HGet(ctx, modelsKey, name).Result()
+	if errors.Is(err, redis.Nil) {
+		return defaultModelId, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get the embedding model of collection %s: %w", name, err)
+	}
+	model, ok := LookupEmbeddingModel(modelName)
+	if !ok {
+		return "", fmt.Errorf("%w %q of collection %s (see EMBEDDING_MODELS)", ErrUnknownEmbeddingModel, modelName, name)
+	}
+	return model.ModelID, nil
+}
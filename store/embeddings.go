@@ -2,24 +2,172 @@ package store
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
+	"vectormind/embeddings"
 
 	"github.com/openai/openai-go"
 )
 
-// CreateEmbeddingFromText creates an embedding vector from text using OpenAI API
+// ErrEmbeddingRequestFailed is returned when the embedding provider cannot be reached or answers with an error
+var ErrEmbeddingRequestFailed = embeddings.ErrRequestFailed
+
+// ErrInvalidEmbeddingResponse is returned when the embedding provider answers without the expected vectors
+var ErrInvalidEmbeddingResponse = embeddings.ErrInvalidResponse
+
+// embedders are the primary embedding providers of the models, by model ID, when the provider is not
+// the OpenAI compatible API (see SetEmbedder)
+var embedders = map[string]embeddings.Embedder{}
+
+// SetEmbedder sets the primary embedding provider of a model (e.g. Ollama or Cohere, see EMBEDDING_PROVIDER).
+// Without embedder, the embeddings of the model are created with the OpenAI client passed to the functions.
+// The embedders are set at startup, before the embeddings are created.
+func SetEmbedder(embeddingModelId string, e embeddings.Embedder) {
+	embedders[embeddingModelId] = e
+}
+
+// CreateEmbeddingFromText creates an embedding vector from text using the embedding provider
 func CreateEmbeddingFromText(ctx context.Context, openaiClient openai.Client, text, embeddingModelId string) ([]float32, error) {
-	embeddingsResponse, err := openaiClient.Embeddings.New(ctx, openai.EmbeddingNewParams{
-		Input: openai.EmbeddingNewParamsInputUnion{
-			OfString: openai.String(text),
-		},
-		Model: embeddingModelId,
-	})
+	vectors, err := CreateEmbeddingsFromTexts(ctx, openaiClient, []string{text}, embeddingModelId)
+	if err != nil {
+		return nil, err
+	}
+	if len(vectors) != 1 || len(vectors[0]) == 0 {
+		return nil, fmt.Errorf("%w: no embedding vector returned", ErrInvalidEmbeddingResponse)
+	}
+	return vectors[0], nil
+}
+
+// CreateQueryEmbeddingFromText creates the embedding vector of a search query
+// (the providers distinguishing queries from documents, like Cohere, embed it as a query)
+func CreateQueryEmbeddingFromText(ctx context.Context, openaiClient openai.Client, text, embeddingModelId string) ([]float32, error) {
+	return CreateEmbeddingFromText(embeddings.WithQuery(ctx), openaiClient, text, embeddingModelId)
+}
 
-	// convert the embedding to a []float32
-	embedding := make([]float32, len(embeddingsResponse.Data[0].Embedding))
-	for i, f := range embeddingsResponse.Data[0].Embedding {
-		embedding[i] = float32(f)
+// CreateEmbeddingsFromTexts creates the embedding vectors of several texts with a single request to the embedding provider.
+// The vectors are returned in the same order as the texts.
+// When a fallback provider is set, it is used when the request fails or when the circuit of the primary provider is open
+// (only for the default embedding model: the fallback model has its dimension).
+func CreateEmbeddingsFromTexts(ctx context.Context, openaiClient openai.Client, texts []string, embeddingModelId string) ([][]float32, error) {
+	if len(texts) == 0 {
+		return [][]float32{}, nil
 	}
 
-	return embedding, err
+	fallback := embeddingFallback
+	if fallback == nil || !isDefaultEmbeddingModel(embeddingModelId) {
+		vectors, err := primaryEmbeddings(ctx, openaiClient, texts, embeddingModelId)
+		if err == nil {
+			recordEmbeddingActivity()
+		}
+		return vectors, err
+	}
+
+	if !fallback.circuitOpen() {
+		vectors, err := primaryEmbeddings(ctx, openaiClient, texts, embeddingModelId)
+		if ctx.Err() != nil {
+			// The caller gave up (cancellation or time budget), this is not a failure of the provider
+			return vectors, err
+		}
+		fallback.recordPrimary(err)
+		if err == nil {
+			recordEmbeddingActivity()
+			return vectors, nil
+		}
+	}
+	return fallback.embed(ctx, texts)
+}
+
+// primaryEmbeddings creates the embedding vectors of several texts with the primary provider:
+// the embedder set with SetEmbedder, or the OpenAI compatible API
+// (the chaos mode injects its embedding timeouts here, so that they open the circuit of the fallback provider)
+func primaryEmbeddings(ctx context.Context, openaiClient openai.Client, texts []string, embeddingModelId string) ([][]float32, error) {
+	if err := chaosEmbeddingTimeout(ctx); err != nil {
+		return nil, err
+	}
+	if embedder, ok := embedders[embeddingModelId]; ok {
+		return embedWith(ctx, embedder, texts, embeddingModelId)
+	}
+	return requestEmbeddings(ctx, openaiClient, texts, embeddingModelId)
+}
+
+// requestEmbeddings creates the embedding vectors of several texts with a single request to an OpenAI compatible API.
+// The errors of the provider wrap ErrEmbeddingRequestFailed, and the responses without one non-empty vector
+// per text ErrInvalidEmbeddingResponse.
+func requestEmbeddings(ctx context.Context, openaiClient openai.Client, texts []string, embeddingModelId string) ([][]float32, error) {
+	return embedWith(ctx, embeddings.NewOpenAIEmbedder(openaiClient, embeddingModelId), texts, embeddingModelId)
+}
+
+// embedWith creates the embedding vectors of several texts with an embedder, and records the usage
+// (the number of tokens reported by the provider, or estimated when the provider does not report it)
+func embedWith(ctx context.Context, e embeddings.Embedder, texts []string, embeddingModelId string) ([][]float32, error) {
+	var vectors [][]float32
+	var tokens int64
+	var err error
+	if counter, ok := e.(embeddings.TokenCounter); ok {
+		vectors, tokens, err = counter.EmbedWithTokens(ctx, texts)
+	} else {
+		vectors, err = e.Embed(ctx, texts)
+	}
+	if err != nil {
+		return nil, err
+	}
+	recordEmbeddingUsage(ctx, embeddingModelId, texts, tokens)
+	if err := embeddings.ValidateVectors(vectors, texts); err != nil {
+		return nil, err
+	}
+	return vectors, nil
+}
+
+// maxInputTokensKeys lists the fields used by the OpenAI-compatible runners
+// (llama.cpp, vLLM, Ollama, ...) to expose the context window of a model
+var maxInputTokensKeys = []string{
+	"max_input_tokens",
+	"max_model_len",
+	"context_length",
+	"context_window",
+	"n_ctx",
+	"n_ctx_train",
+	"max_position_embeddings",
+}
+
+// GetEmbeddingModelMaxTokens queries the model runner for the maximum number of input tokens of the embedding model.
+// It returns 0 (without error) when the runner does not expose this information.
+func GetEmbeddingModelMaxTokens(ctx context.Context, openaiClient openai.Client, embeddingModelId string) (int, error) {
+	page, err := openaiClient.Models.List(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, model := range page.Data {
+		if model.ID != embeddingModelId && len(page.Data) > 1 {
+			continue
+		}
+
+		var fields map[string]any
+		if err := json.Unmarshal([]byte(model.RawJSON()), &fields); err != nil {
+			return 0, err
+		}
+		if maxTokens := findMaxInputTokens(fields); maxTokens > 0 {
+			return maxTokens, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// findMaxInputTokens looks for a context window field in the model description (including nested objects like "meta")
+func findMaxInputTokens(fields map[string]any) int {
+	for _, key := range maxInputTokensKeys {
+		if value, ok := fields[key].(float64); ok && value > 0 {
+			return int(value)
+		}
+	}
+	for _, value := range fields {
+		if nested, ok := value.(map[string]any); ok {
+			if maxTokens := findMaxInputTokens(nested); maxTokens > 0 {
+				return maxTokens
+			}
+		}
+	}
+	return 0
 }
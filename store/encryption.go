@@ -0,0 +1,133 @@
+package store
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+)
+
+// encryptedValuePrefix marks the field values encrypted at rest (the rest of the value is base64(nonce + ciphertext))
+const encryptedValuePrefix = "enc:v1:"
+
+// ErrDecryptionFailed is returned when an encrypted field cannot be decrypted (wrong key or corrupted value)
+var ErrDecryptionFailed = errors.New("failed to decrypt field")
+
+// fieldCipher encrypts the content and metadata fields of the stored documents, nil when disabled
+var fieldCipher cipher.AEAD
+
+// contentHashKey keys the content hashes when the encryption is enabled, so that they do not reveal the content
+var contentHashKey []byte
+
+// ParseEncryptionKey decodes an AES key (16, 24 or 32 bytes) encoded in hex or in base64
+func ParseEncryptionKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	if encoded == "" {
+		return nil, fmt.Errorf("encryption key is empty")
+	}
+
+	// hex is tried first, a hex key is also valid base64
+	key, err := hex.DecodeString(encoded)
+	if err != nil || !validAESKeyLength(len(key)) {
+		key, err = base64.StdEncoding.DecodeString(encoded)
+	}
+	if err != nil || !validAESKeyLength(len(key)) {
+		return nil, fmt.Errorf("invalid encryption key (expected 16, 24 or 32 bytes encoded in base64 or hex)")
+	}
+	return key, nil
+}
+
+func validAESKeyLength(length int) bool {
+	return length == 16 || length == 24 || length == 32
+}
+
+// SetEncryptionKey enables the AES-GCM encryption of the content and metadata fields (a nil key disables it)
+func SetEncryptionKey(key []byte) error {
+	if key == nil {
+		fieldCipher = nil
+		contentHashKey = nil
+		return nil
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return fmt.Errorf("invalid encryption key: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return fmt.Errorf("failed to create AES-GCM cipher: %w", err)
+	}
+	fieldCipher = gcm
+	contentHashKey = key
+	return nil
+}
+
+// EncryptionEnabled reports whether the content and metadata fields are encrypted at rest
+func EncryptionEnabled() bool {
+	return fieldCipher != nil
+}
+
+// EncryptField encrypts a field value (the value is returned as is when encryption is disabled or the value is empty)
+func EncryptField(value string) (string, error) {
+	if fieldCipher == nil || value == "" {
+		return value, nil
+	}
+
+	nonce := make([]byte, fieldCipher.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	sealed := fieldCipher.Seal(nonce, nonce, []byte(value), nil)
+	return encryptedValuePrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// DecryptField decrypts a field value encrypted by EncryptField.
+// Values stored before encryption was enabled (without the encrypted prefix) are returned as is.
+func DecryptField(value string) (string, error) {
+	encoded, encrypted := strings.CutPrefix(value, encryptedValuePrefix)
+	if !encrypted {
+		return value, nil
+	}
+	if fieldCipher == nil {
+		return "", fmt.Errorf("%w: encryption key is not configured", ErrDecryptionFailed)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < fieldCipher.NonceSize() {
+		return "", fmt.Errorf("%w: invalid encrypted value", ErrDecryptionFailed)
+	}
+	nonce, ciphertext := sealed[:fieldCipher.NonceSize()], sealed[fieldCipher.NonceSize():]
+	plaintext, err := fieldCipher.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return "", fmt.Errorf("%w: %v", ErrDecryptionFailed, err)
+	}
+	return string(plaintext), nil
+}
+
+// encryptDocumentFields encrypts the content and the metadata of a document before it is stored
+func encryptDocumentFields(content, metadata string) (string, string, error) {
+	encryptedContent, err := EncryptField(content)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encrypt content: %w", err)
+	}
+	encryptedMetadata, err := EncryptField(metadata)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to encrypt metadata: %w", err)
+	}
+	return encryptedContent, encryptedMetadata, nil
+}
+
+// decryptStoredField decrypts a stored field for a read path, an undecryptable value is logged and returned empty
+func decryptStoredField(id, field, value string) string {
+	plaintext, err := DecryptField(value)
+	if err != nil {
+		log.Printf("🔴 Unable to decrypt the %s of %s: %v", field, id, err)
+		return ""
+	}
+	return plaintext
+}
@@ -0,0 +1,158 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"vectormind/features"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNotFound is wrapped by the errors of the missing resources (documents, collections, indexes):
+// errors.Is(err, ErrNotFound) matches all of them
+var ErrNotFound = errors.New("not found")
+
+// ErrIndexMissing is returned when a search index does not exist (the errors of Redis for an unknown index wrap it)
+var ErrIndexMissing = fmt.Errorf("index %w", ErrNotFound)
+
+// ErrBackendUnavailable is wrapped by the errors of the Redis commands that could not reach the server
+// (connection refused or lost, network timeout, connection pool exhausted, database loading)
+var ErrBackendUnavailable = errors.New("redis backend unavailable")
+
+// Error codes of ErrorCode, returned to the API and MCP clients with the errors
+const (
+	ErrorCodeInvalidRequest      = "invalid_request"
+	ErrorCodeNotFound            = "not_found"
+	ErrorCodeIndexMissing        = "index_missing"
+	ErrorCodeConflict            = "conflict"
+	ErrorCodeDimensionMismatch   = "dimension_mismatch"
+	ErrorCodeIndexCorrupted      = "index_corrupted"
+	ErrorCodeBackendUnavailable  = "backend_unavailable"
+	ErrorCodeInsufficientStorage = "insufficient_storage"
+	ErrorCodeEmbeddingFailed     = "embedding_failed"
+	ErrorCodeFetchFailed         = "fetch_failed"
+	ErrorCodeChatFailed          = "chat_failed"
+	ErrorCodeRerankFailed        = "rerank_failed"
+	ErrorCodeNotConfigured       = "not_configured"
+	ErrorCodeTimeout             = "timeout"
+	ErrorCodeInternal            = "internal"
+)
+
+// ErrorCode returns the code of an error of the store package, so that the handlers and the tools report the same
+// error the same way without matching its text (ErrorCodeInternal for an unknown error)
+func ErrorCode(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidCollectionName), errors.Is(err, ErrInvalidCursor), errors.Is(err, ErrUnknownEmbeddingModel),
+		errors.Is(err, ErrKeywordSearchDisabled), errors.Is(err, ErrURLNotAllowed), errors.Is(err, ErrFilterRequired):
+		return ErrorCodeInvalidRequest
+	case errors.Is(err, ErrIndexCorrupted):
+		return ErrorCodeIndexCorrupted
+	case errors.Is(err, ErrIndexMissing):
+		return ErrorCodeIndexMissing
+	case errors.Is(err, ErrNotFound):
+		return ErrorCodeNotFound
+	case errors.Is(err, ErrDocumentExists), errors.Is(err, ErrCollectionExists), errors.Is(err, ErrIdempotencyKeyInProgress):
+		return ErrorCodeConflict
+	case errors.Is(err, ErrDimensionMismatch):
+		return ErrorCodeDimensionMismatch
+	case errors.Is(err, ErrBackendUnavailable):
+		return ErrorCodeBackendUnavailable
+	case errors.Is(err, ErrMemoryWatermarkExceeded):
+		return ErrorCodeInsufficientStorage
+	case errors.Is(err, ErrEmbeddingRequestFailed), errors.Is(err, ErrInvalidEmbeddingResponse):
+		return ErrorCodeEmbeddingFailed
+	case errors.Is(err, ErrFetchFailed):
+		return ErrorCodeFetchFailed
+	case errors.Is(err, ErrChatRequestFailed):
+		return ErrorCodeChatFailed
+	case errors.Is(err, ErrRerankerMissing), errors.Is(err, features.ErrDisabled), errors.Is(err, ErrVersioningDisabled):
+		return ErrorCodeNotConfigured
+	case errors.Is(err, ErrRerankFailed):
+		return ErrorCodeRerankFailed
+	case errors.Is(err, ErrChatModelMissing):
+		return ErrorCodeNotConfigured
+	case errors.Is(err, ErrSearchTimeout):
+		return ErrorCodeTimeout
+	default:
+		return ErrorCodeInternal
+	}
+}
+
+// classifiedError keeps the error of a Redis command (its text and type, for redis.HasErrorPrefix) and adds the
+// sentinel error of its kind
+type classifiedError struct {
+	kind error
+	err  error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.kind, e.err}
+}
+
+// classifyRedisError wraps the error of a Redis command with ErrBackendUnavailable or ErrIndexMissing
+func classifyRedisError(err error) error {
+	if err == nil || errors.Is(err, redis.Nil) || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	var classified *classifiedError
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	var redisErr redis.Error
+	if errors.As(err, &redisErr) {
+		message := strings.TrimPrefix(redisErr.Error(), "ERR ")
+		switch {
+		case strings.HasPrefix(message, "LOADING"):
+			return &classifiedError{kind: ErrBackendUnavailable, err: err}
+		case message == "Unknown index name", strings.Contains(message, ": no such index"):
+			return &classifiedError{kind: ErrIndexMissing, err: err}
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, redis.ErrClosed) || err.Error() == "redis: connection pool timeout" { // the pool error is not exported
+		return &classifiedError{kind: ErrBackendUnavailable, err: err}
+	}
+	return err
+}
+
+// errorClassificationHook classifies the errors of the commands of a Redis client (see classifyRedisError)
+type errorClassificationHook struct{}
+
+// DialHook keeps the dial errors as is: the client retries the commands according to their type
+func (errorClassificationHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (errorClassificationHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := classifyRedisError(next(ctx, cmd))
+		if err != nil {
+			cmd.SetErr(err)
+		}
+		return err
+	}
+}
+
+func (errorClassificationHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		for _, cmd := range cmds {
+			if cmdErr := cmd.Err(); cmdErr != nil {
+				cmd.SetErr(classifyRedisError(cmdErr))
+			}
+		}
+		return classifyRedisError(err)
+	}
+}
@@ -0,0 +1,92 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"vectormind/models"
+	"vectormind/splitter"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// MaxExpandContext is the largest number of chunks added before and after each search result by expand_context
+const MaxExpandContext = 10
+
+// ValidateExpandContext checks the number of neighbouring chunks of the search results (0: no expansion)
+func ValidateExpandContext(chunks int) error {
+	if chunks < 0 || chunks > MaxExpandContext {
+		return fmt.Errorf("expand_context must be between 0 and %d", MaxExpandContext)
+	}
+	return nil
+}
+
+// ExpandContexts returns the context of each chunk: the chunk with the n chunks before and after it of the same
+// parent document, concatenated in order (see splitter.JoinChunks). The chunks without provenance (documents stored
+// whole, or stored before the provenance) have no context. The neighbours of all the chunks are read with a single
+// round trip (one search per chunk).
+func ExpandContexts(ctx context.Context, redisClient *redis.Client, indexName string, chunks []models.ChunkProvenance, n int) ([]string, error) {
+	contexts := make([]string, len(chunks))
+	cmds := make([]*redis.FTSearchCmd, len(chunks))
+	pipe := redisClient.Pipeline()
+	for i, chunk := range chunks {
+		if chunk.ParentID == "" || chunk.ChunkIndex == nil {
+			continue
+		}
+		query := fmt.Sprintf("@parent_id:{%s} @chunk_index:[%d %d]", escapeTagValue(chunk.ParentID), *chunk.ChunkIndex-n, *chunk.ChunkIndex+n)
+		cmds[i] = pipe.FTSearchWithArgs(ctx, indexName, activeVersionsQuery(query), &redis.FTSearchOptions{
+			Return: []redis.FTSearchReturn{
+				returnField(contentField, "content"),
+				{FieldName: "start_offset"},
+				{FieldName: "end_offset"},
+			},
+			SortBy:         []redis.FTSearchSortBy{{FieldName: "chunk_index", Asc: true}},
+			Limit:          2*n + 1,
+			DialectVersion: 2,
+		})
+	}
+	if pipe.Len() == 0 {
+		return contexts, nil
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, checkSearchError(ctx, redisClient, indexName, err)
+	}
+
+	for i, cmd := range cmds {
+		if cmd == nil {
+			continue
+		}
+		results, err := cmd.Result()
+		if err != nil {
+			return nil, err
+		}
+		neighbours := make([]splitter.RuneChunk, 0, len(results.Docs))
+		for _, doc := range results.Docs {
+			neighbour := splitter.RuneChunk{Text: decryptStoredField(doc.ID, "content", doc.Fields["content"]), Start: -1, End: -1}
+			start, startErr := strconv.Atoi(doc.Fields["start_offset"])
+			end, endErr := strconv.Atoi(doc.Fields["end_offset"])
+			if startErr == nil && endErr == nil {
+				neighbour.Start, neighbour.End = start, end
+			}
+			neighbours = append(neighbours, neighbour)
+		}
+		contexts[i] = splitter.JoinChunks(neighbours)
+	}
+	return contexts, nil
+}
+
+// ExpandSearchResults sets the expanded content of the search results (see ExpandContexts)
+func ExpandSearchResults(ctx context.Context, redisClient *redis.Client, indexName string, results []models.SimilaritySearchResult, n int) error {
+	chunks := make([]models.ChunkProvenance, len(results))
+	for i, result := range results {
+		chunks[i] = result.ChunkProvenance
+	}
+	contexts, err := ExpandContexts(ctx, redisClient, indexName, chunks, n)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		results[i].ExpandedContent = contexts[i]
+	}
+	return nil
+}
@@ -0,0 +1,163 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"vectormind/features"
+	"vectormind/helpers"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// Number of paraphrases generated by a query expansion
+const (
+	MinQueryVariants     = 3
+	MaxQueryVariants     = 5
+	DefaultQueryVariants = 3
+)
+
+// queryVariants is the number of paraphrases generated by ExpandQuery (see SetQueryVariants)
+var queryVariants = DefaultQueryVariants
+
+// expansionSystemPrompt tells the chat model to paraphrase a search query, one paraphrase by line
+const expansionSystemPrompt = "You rewrite search queries to improve the recall of a semantic search. " +
+	"Write %d different paraphrases of the query given by the user, using synonyms and making implicit terms explicit. " +
+	"Keep the language of the query. Answer with one paraphrase by line, without numbering or any other text."
+
+// variantPrefixPattern matches the numbering or the bullet a chat model may put before a paraphrase, e.g. "2. " or "- "
+var variantPrefixPattern = regexp.MustCompile(`^\s*(\d+[.)]|[-*•])\s*`)
+
+// SetQueryVariants sets the number of paraphrases generated by the query expansions (QUERY_EXPANSION_VARIANTS,
+// from 3 to 5)
+func SetQueryVariants(n int) error {
+	if n < MinQueryVariants || n > MaxQueryVariants {
+		return fmt.Errorf("the number of query variants must be between %d and %d", MinQueryVariants, MaxQueryVariants)
+	}
+	queryVariants = n
+	return nil
+}
+
+// ExpansionEnabled returns true when a chat model is configured and the query expansion feature is enabled
+func ExpansionEnabled() bool {
+	return chatModelId != "" && features.Enabled(features.QueryExpansion)
+}
+
+// ExpandQuery asks the chat model (see SetChatModel) for paraphrases of a search query, to search them with the
+// query (see SearchOptions.QueryVariants). It returns ErrChatModelMissing when no chat model is configured, and
+// ErrChatRequestFailed when the chat model fails or returns no paraphrase.
+func ExpandQuery(ctx context.Context, query string) ([]string, error) {
+	if chatModelId == "" {
+		return nil, ErrChatModelMissing
+	}
+
+	completion, err := chatClient.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Model: chatModelId,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(fmt.Sprintf(expansionSystemPrompt, queryVariants)),
+			openai.UserMessage(query),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrChatRequestFailed, err)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("%w: no paraphrase returned", ErrChatRequestFailed)
+	}
+	variants := ParseQueryVariants(completion.Choices[0].Message.Content, query, queryVariants)
+	if len(variants) == 0 {
+		return nil, fmt.Errorf("%w: no paraphrase returned", ErrChatRequestFailed)
+	}
+	return variants, nil
+}
+
+// ParseQueryVariants returns the first n paraphrases of the answer of the chat model, one by line, without their
+// numbering. The empty lines, the repeated paraphrases and the query itself are ignored.
+func ParseQueryVariants(answer, query string, n int) []string {
+	variants := []string{}
+	seen := map[string]bool{strings.ToLower(strings.TrimSpace(query)): true}
+	for _, line := range strings.Split(answer, "\n") {
+		variant := strings.Trim(variantPrefixPattern.ReplaceAllString(line, ""), " \t\"")
+		if variant == "" || seen[strings.ToLower(variant)] {
+			continue
+		}
+		seen[strings.ToLower(variant)] = true
+		variants = append(variants, variant)
+		if len(variants) == n {
+			break
+		}
+	}
+	return variants
+}
+
+// multiQuerySearch performs the similarity searches of a query and of its paraphrases in parallel, and fuses their
+// rankings (see FuseRankings). A paraphrase that cannot be searched is ignored, the search of the query must succeed.
+func multiQuerySearch(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, indexName string, queryVector []float32, limit int, options SearchOptions) ([]redis.Document, error) {
+	rankings := make([][]redis.Document, len(options.QueryVariants)+1)
+	var wg sync.WaitGroup
+	for i, variant := range options.QueryVariants {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			vector, err := CreateQueryEmbeddingFromText(ctx, openaiClient, variant, embeddingModelId)
+			if err == nil {
+				rankings[i+1], err = SimilaritySearchWithOptions(ctx, redisClient, indexName, vector, limit, options)
+			}
+			if err != nil && ctx.Err() == nil {
+				helpers.Logf(ctx, "🟠 Failed to search the query variant %q: %v", variant, err)
+			}
+		}()
+	}
+	docs, err := SimilaritySearchWithOptions(ctx, redisClient, indexName, queryVector, limit, options)
+	wg.Wait()
+	if err != nil {
+		return nil, err
+	}
+	rankings[0] = docs
+	return FuseRankings(rankings, limit), nil
+}
+
+// FuseRankings merges the rankings of several similarity searches by reciprocal rank fusion (score = sum of
+// 1 / (60 + rank)) and returns the limit best documents. A document found by several searches keeps its smallest
+// vector distance.
+func FuseRankings(rankings [][]redis.Document, limit int) []redis.Document {
+	scores := map[string]float64{}
+	fused := map[string]redis.Document{}
+	for _, ranking := range rankings {
+		for rank, doc := range ranking {
+			scores[doc.ID] += 1 / float64(rrfK+rank+1)
+			if existing, ok := fused[doc.ID]; !ok || documentDistance(doc) < documentDistance(existing) {
+				fused[doc.ID] = doc
+			}
+		}
+	}
+
+	docs := make([]redis.Document, 0, len(fused))
+	for _, doc := range fused {
+		docs = append(docs, doc)
+	}
+	sort.Slice(docs, func(i, j int) bool {
+		if scores[docs[i].ID] != scores[docs[j].ID] {
+			return scores[docs[i].ID] > scores[docs[j].ID]
+		}
+		return docs[i].ID < docs[j].ID
+	})
+	if len(docs) > limit {
+		docs = docs[:limit]
+	}
+	return docs
+}
+
+// documentDistance returns the vector distance of a document of a similarity search
+func documentDistance(doc redis.Document) float64 {
+	distance, err := strconv.ParseFloat(doc.Fields["vector_distance"], 64)
+	if err != nil {
+		return 9.9
+	}
+	return distance
+}
@@ -0,0 +1,180 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+	"vectormind/splitter"
+
+	"golang.org/x/net/html/charset"
+)
+
+// ErrURLNotAllowed is returned when a URL cannot be fetched: not an http(s) URL, or an address of a private network
+var ErrURLNotAllowed = errors.New("URL not allowed")
+
+// ErrFetchFailed is returned when a web page cannot be downloaded (network error, error status, unsupported content)
+var ErrFetchFailed = errors.New("failed to fetch the URL")
+
+const (
+	// fetchTimeout bounds the download of a web page, redirects included
+	fetchTimeout = 30 * time.Second
+	// fetchMaxRedirects is the highest number of redirects followed by a download
+	fetchMaxRedirects = 5
+	// FetchMaxSize is the largest web page that can be downloaded, in bytes
+	FetchMaxSize = 10 << 20
+)
+
+// fetchContentTypes are the media types of the downloaded pages that can be stored, with their splitting strategy
+var fetchContentTypes = map[string]string{
+	"text/html":             "html",
+	"application/xhtml+xml": "html",
+	"text/markdown":         "markdown_hierarchy",
+	"text/plain":            "tokens",
+}
+
+// fetchPrivateNetworks allows the downloads from the loopback, private and link-local addresses (disabled by default)
+var fetchPrivateNetworks = false
+
+// SetFetchPrivateNetworks allows or forbids the downloads from the addresses of private networks
+func SetFetchPrivateNetworks(allowed bool) {
+	fetchPrivateNetworks = allowed
+}
+
+// FetchedPage is a web page downloaded by FetchURL
+type FetchedPage struct {
+	URL         string // URL of the page, after the redirects
+	ContentType string // media type of the page, e.g. "text/html"
+	Body        string // content of the page, decoded to UTF-8
+	FetchedAt   time.Time
+}
+
+// ValidateFetchURL checks that a URL can be fetched (an absolute http or https URL)
+func ValidateFetchURL(rawURL string) error {
+	parsed, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%w: only http and https URLs can be fetched", ErrURLNotAllowed)
+	}
+	if parsed.Hostname() == "" {
+		return fmt.Errorf("%w: the URL has no host", ErrURLNotAllowed)
+	}
+	return nil
+}
+
+// FetchURL downloads a web page (HTML, markdown or plain text, up to FetchMaxSize bytes). The addresses of the
+// private networks are refused when the connection is made, so that a host name cannot designate them either.
+func FetchURL(ctx context.Context, rawURL string) (FetchedPage, error) {
+	if err := ValidateFetchURL(rawURL); err != nil {
+		return FetchedPage{}, err
+	}
+
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: checkFetchAddress}
+	client := &http.Client{
+		Timeout:   fetchTimeout,
+		Transport: &http.Transport{DialContext: dialer.DialContext, TLSHandshakeTimeout: 10 * time.Second, DisableKeepAlives: true},
+		CheckRedirect: func(request *http.Request, via []*http.Request) error {
+			if len(via) >= fetchMaxRedirects {
+				return fmt.Errorf("%w: more than %d redirects", ErrFetchFailed, fetchMaxRedirects)
+			}
+			return ValidateFetchURL(request.URL.String())
+		},
+	}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSpace(rawURL), nil)
+	if err != nil {
+		return FetchedPage{}, fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	request.Header.Set("User-Agent", "VectorMind")
+	request.Header.Set("Accept", "text/html, application/xhtml+xml, text/markdown, text/plain;q=0.9")
+
+	response, err := client.Do(request)
+	if err != nil {
+		if errors.Is(err, ErrURLNotAllowed) {
+			return FetchedPage{}, err
+		}
+		return FetchedPage{}, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	defer response.Body.Close()
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return FetchedPage{}, fmt.Errorf("%w: %s returned %s", ErrFetchFailed, response.Request.URL, response.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(response.Body, FetchMaxSize+1))
+	if err != nil {
+		return FetchedPage{}, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	if len(body) > FetchMaxSize {
+		return FetchedPage{}, fmt.Errorf("%w: the page is larger than %d bytes", ErrFetchFailed, FetchMaxSize)
+	}
+
+	contentType := response.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = http.DetectContentType(body)
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if _, ok := fetchContentTypes[mediaType]; err != nil || !ok {
+		return FetchedPage{}, fmt.Errorf("%w: unsupported content type %q (HTML, markdown or plain text)", ErrFetchFailed, contentType)
+	}
+
+	// The page is decoded from its charset (Content-Type parameter, byte order mark or <meta> of an HTML page)
+	decoded, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return FetchedPage{}, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	text, err := io.ReadAll(decoded)
+	if err != nil || !utf8.Valid(text) {
+		return FetchedPage{}, fmt.Errorf("%w: the page cannot be decoded to UTF-8", ErrFetchFailed)
+	}
+
+	return FetchedPage{
+		URL:         response.Request.URL.String(),
+		ContentType: mediaType,
+		Body:        string(text),
+		FetchedAt:   time.Now(),
+	}, nil
+}
+
+// checkFetchAddress refuses the connections of the downloads to the loopback, private, link-local and unspecified
+// addresses, unless the private networks are allowed
+func checkFetchAddress(network, address string, _ syscall.RawConn) error {
+	if fetchPrivateNetworks {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsMulticast() || ip.IsUnspecified() {
+		return fmt.Errorf("%w: %s is an address of a private network", ErrURLNotAllowed, host)
+	}
+	return nil
+}
+
+// PageChunks splits a downloaded page with the strategy of its content type: the HTML pages are converted to markdown
+// without their boilerplate (see splitter.HTMLToMarkdown) and split with the markdown hierarchy, as the markdown
+// pages, and the plain text is split by tokens
+func PageChunks(page FetchedPage, maxTokens int) ([]string, error) {
+	return splitter.Split(fetchContentTypes[page.ContentType], page.Body, nil, maxTokens)
+}
+
+// PageMetadata completes the metadata of the chunks of a downloaded page with its URL and its download time
+func PageMetadata(metadata string, page FetchedPage) (string, error) {
+	return MergeMetadata(metadata, map[string]any{
+		"url":        page.URL,
+		"fetched_at": page.FetchedAt.UTC().Format(time.RFC3339),
+	})
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"vectormind/splitter"
+)
+
+// Hash fields holding the title and the hierarchy of the chunks of the markdown hierarchy splitter (full-text indexed)
+const (
+	titleField     = "title"
+	hierarchyField = "hierarchy"
+)
+
+// FieldWeights are the weights of the section fields of the chunks in the keyword searches, relative to the content
+// (a match in the title of a section ranks above a match in its body). A weight of 0 does not boost the field.
+type FieldWeights struct {
+	Title     float64 // section title of a markdown hierarchy chunk
+	Hierarchy float64 // breadcrumb of the headers of a markdown hierarchy chunk
+}
+
+// DefaultFieldWeights are the default weights of the section fields
+var DefaultFieldWeights = FieldWeights{Title: 3, Hierarchy: 2}
+
+// fieldWeights are the configured weights of the section fields
+var fieldWeights = DefaultFieldWeights
+
+// SetFieldWeights sets the weights of the section fields in the keyword searches
+func SetFieldWeights(weights FieldWeights) {
+	fieldWeights = weights
+}
+
+// GetFieldWeights returns the weights of the section fields in the keyword searches
+func GetFieldWeights() FieldWeights {
+	return fieldWeights
+}
+
+// ParseFieldWeights parses field weights like "title:3,hierarchy:2" (the missing fields keep their default weight)
+func ParseFieldWeights(spec string) (FieldWeights, error) {
+	weights := map[string]float64{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, found := strings.Cut(entry, ":")
+		if !found {
+			return FieldWeights{}, fmt.Errorf("invalid field weight %q (use field:weight)", entry)
+		}
+		weight, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return FieldWeights{}, fmt.Errorf("invalid weight %q of field %s", value, name)
+		}
+		weights[strings.TrimSpace(name)] = weight
+	}
+	return ApplyFieldWeights(DefaultFieldWeights, weights)
+}
+
+// ApplyFieldWeights returns the field weights with the given weights by field name ("title" or "hierarchy")
+func ApplyFieldWeights(base FieldWeights, weights map[string]float64) (FieldWeights, error) {
+	for name, weight := range weights {
+		if !(weight >= 0) || math.IsInf(weight, 0) {
+			return FieldWeights{}, fmt.Errorf("the weight of field %s must be a number >= 0", name)
+		}
+		switch name {
+		case titleField:
+			base.Title = weight
+		case hierarchyField:
+			base.Hierarchy = weight
+		default:
+			return FieldWeights{}, fmt.Errorf("unknown weighted field %q (use %s or %s)", name, titleField, hierarchyField)
+		}
+	}
+	return base, nil
+}
+
+// sectionFields returns the title and hierarchy fields of a chunk of the markdown hierarchy splitter (none for the
+// other documents, and none when the content is encrypted: they hold a part of the content)
+func sectionFields(content string) map[string]any {
+	fields := map[string]any{}
+	if EncryptionEnabled() {
+		return fields
+	}
+	if title, hierarchy, ok := splitter.ParseHierarchyChunk(content); ok {
+		fields[titleField] = title
+		fields[hierarchyField] = hierarchy
+	}
+	return fields
+}
+
+// boostedSectionClauses returns the clauses matching the terms of a keyword query in the section fields, weighted
+// by the field weights, e.g. `(@title:(tls|setup) => { $weight: 3; })`
+func boostedSectionClauses(terms string, weights FieldWeights) []string {
+	clauses := []string{}
+	for _, field := range []struct {
+		name   string
+		weight float64
+	}{{titleField, weights.Title}, {hierarchyField, weights.Hierarchy}} {
+		if field.weight > 0 {
+			clauses = append(clauses, fmt.Sprintf("(@%s:%s => { $weight: %s; })", field.name, terms, strconv.FormatFloat(field.weight, 'f', -1, 64)))
+		}
+	}
+	return clauses
+}
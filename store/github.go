@@ -0,0 +1,377 @@
+package store
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+	"vectormind/splitter"
+)
+
+const (
+	// githubTimeout bounds the download of a repository archive
+	githubTimeout = 5 * time.Minute
+	// GitHubMaxArchiveSize is the largest repository archive that can be downloaded, in bytes
+	GitHubMaxArchiveSize = 200 << 20
+	// GitHubMaxFileSize is the largest file of a repository that is ingested, in bytes (larger files are skipped)
+	GitHubMaxFileSize = 1 << 20
+	// GitHubMaxFiles is the highest number of files of a repository ingested by a single request
+	GitHubMaxFiles = 5000
+	// GitHubMaxTotalSize is the largest total size of the files of a repository ingested by a single request, in bytes
+	// (the files beyond are skipped): the files are held in memory until they are split
+	GitHubMaxTotalSize = 50 << 20
+)
+
+// githubCodeExtensions are the extensions of the code and text files ingested from a repository, in addition to
+// the documents with a splitting strategy (see splitter.ConfigForFile)
+var githubCodeExtensions = map[string]bool{
+	".go": true, ".py": true, ".js": true, ".jsx": true, ".ts": true, ".tsx": true, ".java": true, ".kt": true,
+	".rb": true, ".rs": true, ".c": true, ".h": true, ".cc": true, ".cpp": true, ".hpp": true, ".cs": true,
+	".php": true, ".swift": true, ".scala": true, ".sh": true, ".sql": true, ".proto": true, ".txt": true,
+	".yaml": true, ".yml": true, ".toml": true, ".json": true,
+}
+
+// githubCodeOptions split the code files at their blank lines, then at their lines, in chunks of up to 2000 characters
+var githubCodeOptions = splitter.Options{"chunk_size": 2000, "separators": []string{"\n\n", "\n", " ", ""}}
+
+// githubRepositoryPattern matches the repositories of the GitHub URLs: "https://github.com/owner/repo(.git)",
+// "github.com/owner/repo" or "owner/repo"
+var githubRepositoryPattern = regexp.MustCompile(`^(?:(?:https?://)?(?:www\.)?github\.com/)?([A-Za-z0-9_.-]+)/([A-Za-z0-9_.-]+?)(?:\.git)?/?$`)
+
+// githubBranchPattern matches the branch, tag and commit names that can be requested
+var githubBranchPattern = regexp.MustCompile(`^[A-Za-z0-9._/-]+$`)
+
+// githubCommitPattern matches the SHA of a commit
+var githubCommitPattern = regexp.MustCompile(`^[0-9a-f]{40,64}$`)
+
+// githubAPIURL is the base URL of the GitHub REST API (a GitHub Enterprise server, or a test server)
+var githubAPIURL = "https://api.github.com"
+
+// githubToken authenticates the requests to the GitHub API (optional: private repositories, higher rate limit), only
+// for the repositories of githubTokenRepos
+var (
+	githubToken      = ""
+	githubTokenRepos = []string{}
+)
+
+// githubOwnerPattern matches the owners of the repositories
+var githubOwnerPattern = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+
+// SetGitHubAPI sets the base URL of the GitHub REST API ("" keeps https://api.github.com) and its access token, sent
+// only for the repositories of tokenRepos (see ParseGitHubTokenRepos): the callers choose the repositories, the token
+// must not give them access to the other private repositories it can read
+func SetGitHubAPI(apiURL, token string, tokenRepos []string) {
+	if apiURL != "" {
+		githubAPIURL = strings.TrimSuffix(apiURL, "/")
+	}
+	githubToken = token
+	githubTokenRepos = tokenRepos
+}
+
+// ParseGitHubTokenRepos parses the comma separated owners ("owner": all their repositories) and repositories
+// ("owner/repo") for which the GitHub token is sent
+func ParseGitHubTokenRepos(spec string) ([]string, error) {
+	repos := []string{}
+	for _, repo := range strings.Split(spec, ",") {
+		repo = strings.TrimSpace(repo)
+		if repo == "" {
+			continue
+		}
+		owner, name, hasName := strings.Cut(repo, "/")
+		if !githubOwnerPattern.MatchString(owner) || (hasName && !githubOwnerPattern.MatchString(name)) {
+			return nil, fmt.Errorf("invalid GitHub owner or repository %q (use owner or owner/repo)", repo)
+		}
+		repos = append(repos, strings.ToLower(repo))
+	}
+	return repos, nil
+}
+
+// githubTokenAllowed reports whether the token is sent for the requests of a repository ("owner/name")
+func githubTokenAllowed(repo string) bool {
+	if githubToken == "" {
+		return false
+	}
+	repo = strings.ToLower(repo)
+	owner, _, _ := strings.Cut(repo, "/")
+	for _, allowed := range githubTokenRepos {
+		if allowed == repo || allowed == owner {
+			return true
+		}
+	}
+	return false
+}
+
+// GitHubRepository is a repository downloaded by FetchGitHubRepository
+type GitHubRepository struct {
+	Repo    string // "owner/name"
+	Branch  string // branch, tag or commit requested (the default branch when none is given)
+	Commit  string // SHA of the downloaded commit
+	Files   []GitHubFile
+	Skipped int // files matching the filters that are not ingested (binary, too large, beyond GitHubMaxFiles or GitHubMaxTotalSize)
+}
+
+// GitHubFile is a text file of a downloaded repository
+type GitHubFile struct {
+	Path    string // path in the repository, e.g. "docs/setup.md"
+	Content string
+}
+
+// ParseGitHubRepository returns the "owner/name" repository of a GitHub URL
+func ParseGitHubRepository(rawURL string) (string, error) {
+	matches := githubRepositoryPattern.FindStringSubmatch(strings.TrimSpace(rawURL))
+	if matches == nil || matches[1] == "." || matches[1] == ".." || matches[2] == "." || matches[2] == ".." {
+		return "", fmt.Errorf("%w: %q is not a GitHub repository URL (https://github.com/owner/repo)", ErrURLNotAllowed, rawURL)
+	}
+	return matches[1] + "/" + matches[2], nil
+}
+
+// ValidateGitHubBranch checks the name of a branch, tag or commit of a repository ("" is the default branch)
+func ValidateGitHubBranch(branch string) error {
+	if branch != "" && (!githubBranchPattern.MatchString(branch) || strings.Contains(branch, "..")) {
+		return fmt.Errorf("invalid branch %q", branch)
+	}
+	return nil
+}
+
+// ValidateGlobs checks the glob patterns of the files of a repository (see MatchGlob)
+func ValidateGlobs(patterns []string) error {
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			return errors.New("glob patterns cannot be empty")
+		}
+		for _, segment := range strings.Split(pattern, "/") {
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid glob pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	return nil
+}
+
+// MatchGlob reports whether a slash separated path matches a glob pattern: "*", "?" and "[...]" match within a path
+// segment (see path.Match) and a "**" segment matches any number of directories, e.g. "docs/**/*.md". A pattern
+// without slash matches the name of the file in any directory, e.g. "*.go".
+func MatchGlob(pattern, name string) bool {
+	if !strings.Contains(pattern, "/") {
+		matched, _ := path.Match(pattern, path.Base(name))
+		return matched
+	}
+	return matchGlobSegments(strings.Split(strings.TrimPrefix(pattern, "/"), "/"), strings.Split(name, "/"))
+}
+
+// matchGlobSegments matches the segments of a path with the segments of a glob pattern
+func matchGlobSegments(pattern, segments []string) bool {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			for skipped := 0; skipped <= len(segments); skipped++ {
+				if matchGlobSegments(pattern[1:], segments[skipped:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(pattern[0], segments[0]); !matched {
+			return false
+		}
+		pattern, segments = pattern[1:], segments[1:]
+	}
+	return len(segments) == 0
+}
+
+// githubFileSelected reports whether a file of a repository is ingested: a document or code file matching one of the
+// include patterns (any file when none is given) and none of the exclude patterns
+func githubFileSelected(name string, include, exclude []string) bool {
+	if _, ok := splitter.ConfigForFile(name); !ok && !githubCodeExtensions[strings.ToLower(path.Ext(name))] {
+		return false
+	}
+	included := len(include) == 0
+	for _, pattern := range include {
+		if MatchGlob(pattern, name) {
+			included = true
+			break
+		}
+	}
+	for _, pattern := range exclude {
+		if MatchGlob(pattern, name) {
+			return false
+		}
+	}
+	return included
+}
+
+// FetchGitHubRepository downloads the markdown, documentation and code files of a GitHub repository at a branch,
+// tag or commit (the default branch when branch is empty), filtered by glob patterns (see MatchGlob). The commit is
+// resolved first, and its archive is downloaded with the GitHub API, so that all the files come from the same commit.
+// The token is only sent for the allowed repositories (see SetGitHubAPI).
+func FetchGitHubRepository(ctx context.Context, repo, branch string, include, exclude []string) (GitHubRepository, error) {
+	if err := ValidateGitHubBranch(branch); err != nil {
+		return GitHubRepository{}, err
+	}
+	client := &githubClient{http: &http.Client{Timeout: githubTimeout}, authenticated: githubTokenAllowed(repo)}
+	repository := GitHubRepository{Repo: repo, Branch: branch, Files: []GitHubFile{}}
+
+	if repository.Branch == "" {
+		var info struct {
+			DefaultBranch string `json:"default_branch"`
+		}
+		body, err := githubRequest(ctx, client, "/repos/"+repo, "application/vnd.github+json", 1<<20)
+		if err != nil {
+			return repository, err
+		}
+		if err := json.Unmarshal(body, &info); err != nil || info.DefaultBranch == "" {
+			return repository, fmt.Errorf("%w: the default branch of %s cannot be read", ErrFetchFailed, repo)
+		}
+		repository.Branch = info.DefaultBranch
+	}
+
+	commit, err := githubRequest(ctx, client, "/repos/"+repo+"/commits/"+repository.Branch, "application/vnd.github.sha", 1<<10)
+	if err != nil {
+		return repository, err
+	}
+	repository.Commit = strings.TrimSpace(string(commit))
+	if !githubCommitPattern.MatchString(repository.Commit) {
+		return repository, fmt.Errorf("%w: invalid commit of %s returned by the GitHub API", ErrFetchFailed, repository.Branch)
+	}
+
+	archive, err := githubStream(ctx, client, "/repos/"+repo+"/tarball/"+repository.Commit, "application/vnd.github+json")
+	if err != nil {
+		return repository, err
+	}
+	defer archive.Close()
+
+	gzipReader, err := gzip.NewReader(io.LimitReader(archive, GitHubMaxArchiveSize))
+	if err != nil {
+		return repository, fmt.Errorf("%w: invalid archive of %s: %w", ErrFetchFailed, repo, err)
+	}
+	tarReader := tar.NewReader(gzipReader)
+	var totalSize int64
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return repository, fmt.Errorf("%w: invalid archive of %s: %w", ErrFetchFailed, repo, err)
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		// The files of the archive are in a "<owner>-<name>-<commit>/" directory
+		_, name, ok := strings.Cut(header.Name, "/")
+		if !ok || !githubFileSelected(name, include, exclude) {
+			continue
+		}
+		if header.Size > GitHubMaxFileSize || len(repository.Files) >= GitHubMaxFiles || totalSize+header.Size > GitHubMaxTotalSize {
+			repository.Skipped++
+			continue
+		}
+		content, err := io.ReadAll(tarReader)
+		if err != nil {
+			return repository, fmt.Errorf("%w: invalid archive of %s: %w", ErrFetchFailed, repo, err)
+		}
+		// The binary files are skipped
+		if !utf8.Valid(content) || strings.ContainsRune(string(content), 0) {
+			repository.Skipped++
+			continue
+		}
+		totalSize += int64(len(content))
+		repository.Files = append(repository.Files, GitHubFile{Path: name, Content: string(content)})
+	}
+	return repository, nil
+}
+
+// githubClient sends the requests of a repository to the GitHub API, with the token when authenticated
+type githubClient struct {
+	http          *http.Client
+	authenticated bool
+}
+
+// githubRequest returns the body of a response of the GitHub API (up to maxSize bytes)
+func githubRequest(ctx context.Context, client *githubClient, endpoint, accept string, maxSize int64) ([]byte, error) {
+	body, err := githubStream(ctx, client, endpoint, accept)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close()
+	content, err := io.ReadAll(io.LimitReader(body, maxSize))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	return content, nil
+}
+
+// githubStream sends a request to the GitHub API and returns the body of its response. A missing repository or
+// branch wraps ErrNotFound.
+func githubStream(ctx context.Context, client *githubClient, endpoint, accept string) (io.ReadCloser, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, githubAPIURL+endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrURLNotAllowed, err)
+	}
+	request.Header.Set("User-Agent", "VectorMind")
+	request.Header.Set("Accept", accept)
+	request.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	if client.authenticated {
+		request.Header.Set("Authorization", "Bearer "+githubToken)
+	}
+
+	response, err := client.http.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrFetchFailed, err)
+	}
+	switch {
+	case response.StatusCode == http.StatusNotFound, response.StatusCode == http.StatusUnprocessableEntity:
+		response.Body.Close()
+		return nil, fmt.Errorf("GitHub repository or branch %w: %s", ErrNotFound, endpoint)
+	case response.StatusCode < 200 || response.StatusCode > 299:
+		response.Body.Close()
+		return nil, fmt.Errorf("%w: GitHub API returned %s for %s", ErrFetchFailed, response.Status, endpoint)
+	}
+	return response.Body, nil
+}
+
+// GitHubChunks returns the chunks of the files of a repository with the metadata of each chunk: the metadata of the
+// request completed with the repo, path, branch and commit of its file. The documents are split with the strategy of
+// their extension (see splitter.ConfigForFile) and the code files at their blank lines.
+func GitHubChunks(repository GitHubRepository, metadata string, maxTokens int) ([]string, []string, error) {
+	chunks := []string{}
+	chunkMetadata := []string{}
+	for _, file := range repository.Files {
+		config, ok := splitter.ConfigForFile(file.Path)
+		if !ok {
+			config = splitter.FileTypeConfig{Strategy: "recursive", Options: githubCodeOptions}
+		}
+		fileChunks, err := splitter.Split(config.Strategy, file.Content, config.Options, maxTokens)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to split %s: %w", file.Path, err)
+		}
+		if len(fileChunks) == 0 {
+			continue
+		}
+		fileMetadata, err := MergeMetadata(metadata, map[string]any{
+			"repo":   repository.Repo,
+			"path":   file.Path,
+			"branch": repository.Branch,
+			"commit": repository.Commit,
+		})
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, chunk := range fileChunks {
+			chunks = append(chunks, chunk)
+			chunkMetadata = append(chunkMetadata, fileMetadata)
+		}
+	}
+	return chunks, chunkMetadata, nil
+}
@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"vectormind/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Hybrid search fusion methods
+const (
+	// FusionRRF ranks the documents by reciprocal rank fusion of the vector and text rankings (default)
+	FusionRRF = "rrf"
+	// FusionWeighted ranks the documents by weighted sum of the normalized vector and text scores
+	FusionWeighted = "weighted"
+)
+
+// rrfK is the constant of the reciprocal rank fusion (score = sum of 1 / (rrfK + rank))
+const rrfK = 60
+
+// hybridCandidatesFactor is the number of candidates fetched from each search per requested result
+const hybridCandidatesFactor = 4
+
+// HybridOptions holds the fusion settings of a hybrid search
+type HybridOptions struct {
+	Fusion       string  // FusionRRF (default) or FusionWeighted
+	VectorWeight float64 // weight of the vector score with FusionWeighted (the text score weight is 1 - VectorWeight)
+}
+
+// ValidateHybridOptions checks the fusion method and the vector weight
+func ValidateHybridOptions(options HybridOptions) error {
+	switch options.Fusion {
+	case "", FusionRRF, FusionWeighted:
+	default:
+		return fmt.Errorf("unknown fusion %q (use %q or %q)", options.Fusion, FusionRRF, FusionWeighted)
+	}
+	if options.VectorWeight < 0 || options.VectorWeight > 1 {
+		return fmt.Errorf("vector_weight must be between 0 and 1")
+	}
+	return nil
+}
+
+// hybridCandidate is a document found by the vector search, the text search, or both
+type hybridCandidate struct {
+	doc        redis.Document
+	distance   *float64
+	textScore  *float64
+	vectorRank int // 1-based, 0 when not found by the vector search
+	textRank   int // 1-based, 0 when not found by the text search
+}
+
+// HybridSearch combines a BM25 full-text search on the content (or the SearchFields of the options) with a vector KNN
+// search, and fuses both rankings.
+// It returns ErrKeywordSearchDisabled when the content is encrypted at rest.
+func HybridSearch(ctx context.Context, redisClient *redis.Client, indexName, text string, queryVector []float32, limit int, options SearchOptions, hybridOptions HybridOptions) ([]models.HybridSearchResult, error) {
+	if err := ValidateHybridOptions(hybridOptions); err != nil {
+		return nil, err
+	}
+	candidatesCount := limit * hybridCandidatesFactor
+
+	textDocs, err := KeywordSearch(ctx, redisClient, indexName, text, candidatesCount, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform text search: %w", err)
+	}
+	vectorDocs, err := SimilaritySearchWithOptions(ctx, redisClient, indexName, queryVector, candidatesCount, options)
+	if err != nil {
+		return nil, fmt.Errorf("failed to perform similarity search: %w", err)
+	}
+
+	// Collect the candidates of both searches
+	candidates := map[string]*hybridCandidate{}
+	candidate := func(doc redis.Document) *hybridCandidate {
+		if c, ok := candidates[doc.ID]; ok {
+			return c
+		}
+		c := &hybridCandidate{doc: doc}
+		candidates[doc.ID] = c
+		return c
+	}
+
+	vectorResults := DocumentsToSearchResults(vectorDocs, options.MaxDistance) // closest first
+	vectorDocsByID := map[string]redis.Document{}
+	for _, doc := range vectorDocs {
+		vectorDocsByID[doc.ID] = doc
+	}
+	for i, result := range vectorResults {
+		c := candidate(vectorDocsByID[result.ID])
+		distance := result.Distance
+		c.distance = &distance
+		c.vectorRank = i + 1
+	}
+	for i, doc := range textDocs { // most relevant first
+		c := candidate(doc)
+		c.textRank = i + 1
+		c.textScore = doc.Score
+	}
+
+	results := make([]models.HybridSearchResult, 0, len(candidates))
+	maxTextScore := maxScore(textDocs)
+	for _, c := range candidates {
+		result := DocumentToSearchResult(c.doc)
+		results = append(results, models.HybridSearchResult{
+			ID:              result.ID,
+			Content:         result.Content,
+			Label:           result.Label,
+			Labels:          result.Labels,
+			Metadata:        result.Metadata,
+			Quality:         result.Quality,
+			CreatedAt:       result.CreatedAt,
+			Title:           result.Title,
+			Hierarchy:       result.Hierarchy,
+			ChunkProvenance: result.ChunkProvenance,
+			Distance:        c.distance,
+			TextScore:       c.textScore,
+			VectorRank:      c.vectorRank,
+			TextRank:        c.textRank,
+			Score:           fusedScore(c, hybridOptions, maxTextScore),
+		})
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].ID < results[j].ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// fusedScore computes the hybrid score of a candidate (higher is better)
+func fusedScore(c *hybridCandidate, options HybridOptions, maxTextScore float64) float64 {
+	if options.Fusion == FusionWeighted {
+		// The distance is turned into a similarity in ]0, 1], the BM25 score is normalized by the best text score
+		vectorScore, textScore := 0.0, 0.0
+		if c.distance != nil {
+			vectorScore = 1 / (1 + *c.distance)
+		}
+		if c.textScore != nil && maxTextScore > 0 {
+			textScore = *c.textScore / maxTextScore
+		}
+		return options.VectorWeight*vectorScore + (1-options.VectorWeight)*textScore
+	}
+
+	score := 0.0
+	if c.vectorRank > 0 {
+		score += 1 / float64(rrfK+c.vectorRank)
+	}
+	if c.textRank > 0 {
+		score += 1 / float64(rrfK+c.textRank)
+	}
+	return score
+}
+
+// maxScore returns the best text score of the documents of a keyword search
+func maxScore(docs []redis.Document) float64 {
+	best := 0.0
+	for _, doc := range docs {
+		if doc.Score != nil && *doc.Score > best {
+			best = *doc.Score
+		}
+	}
+	return best
+}
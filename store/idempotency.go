@@ -0,0 +1,86 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// idempotencyKeyPrefix is the prefix of the keys holding the results of the requests with an idempotency key
+const idempotencyKeyPrefix = "idempotency:"
+
+// idempotencyPending marks an idempotency key whose request is in progress
+const idempotencyPending = "pending"
+
+// idempotencyPendingTTL bounds the time a key stays reserved when the server stops before the request completes
+const idempotencyPendingTTL = 10 * time.Minute
+
+// DefaultIdempotencyTTL is the default time the results of the requests with an idempotency key are kept
+const DefaultIdempotencyTTL = 24 * time.Hour
+
+// ErrIdempotencyKeyInProgress is returned when a request with the same idempotency key is in progress
+var ErrIdempotencyKeyInProgress = errors.New("a request with this idempotency key is in progress")
+
+// IdempotentResult is the stored response of a request with an idempotency key
+type IdempotentResult struct {
+	RequestHash string `json:"request_hash"` // hash of the request, a key cannot be reused for another request
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Location    string `json:"location,omitempty"` // Location header of the created resource
+	Body        string `json:"body"`
+}
+
+// idempotencyRedisKey returns the Redis key of an idempotency key of an endpoint
+func idempotencyRedisKey(endpoint, key string) string {
+	return idempotencyKeyPrefix + endpoint + ":" + HashContent(key)
+}
+
+// ReserveIdempotencyKey reserves an idempotency key of an endpoint for a request.
+// It returns the stored result when a request with this key has already completed (nil when the key is reserved),
+// and ErrIdempotencyKeyInProgress when a request with this key is in progress.
+func ReserveIdempotencyKey(ctx context.Context, redisClient *redis.Client, endpoint, key string) (*IdempotentResult, error) {
+	redisKey := idempotencyRedisKey(endpoint, key)
+	reserved, err := redisClient.SetNX(ctx, redisKey, idempotencyPending, idempotencyPendingTTL).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve the idempotency key: %w", err)
+	}
+	if reserved {
+		return nil, nil
+	}
+
+	value, err := redisClient.Get(ctx, redisKey).Result()
+	if errors.Is(err, redis.Nil) {
+		// Released meanwhile (the request failed): try again
+		return ReserveIdempotencyKey(ctx, redisClient, endpoint, key)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the idempotency key: %w", err)
+	}
+	if value == idempotencyPending {
+		return nil, ErrIdempotencyKeyInProgress
+	}
+
+	var result IdempotentResult
+	if err := json.Unmarshal([]byte(value), &result); err != nil {
+		return nil, fmt.Errorf("invalid stored result of the idempotency key: %w", err)
+	}
+	return &result, nil
+}
+
+// SaveIdempotentResult stores the result of the request of a reserved idempotency key, kept for the TTL
+func SaveIdempotentResult(ctx context.Context, redisClient *redis.Client, endpoint, key string, result IdempotentResult, ttl time.Duration) error {
+	value, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	return redisClient.Set(ctx, idempotencyRedisKey(endpoint, key), value, ttl).Err()
+}
+
+// ReleaseIdempotencyKey releases a reserved idempotency key without result (the request failed and can be retried)
+func ReleaseIdempotencyKey(ctx context.Context, redisClient *redis.Client, endpoint, key string) error {
+	return redisClient.Del(ctx, idempotencyRedisKey(endpoint, key)).Err()
+}
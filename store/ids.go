@@ -0,0 +1,176 @@
+package store
+
+import (
+	"crypto/rand"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Document ID generators
+const (
+	// IDGeneratorUUID generates random UUIDs (version 4)
+	IDGeneratorUUID = "uuid"
+	// IDGeneratorUUIDv7 generates time-ordered UUIDs (version 7)
+	IDGeneratorUUIDv7 = "uuidv7"
+	// IDGeneratorULID generates ULIDs: 26 characters, sorted by creation time
+	IDGeneratorULID = "ulid"
+	// IDGeneratorSnowflake generates snowflake IDs: 64-bit integers made of a timestamp, a node and a sequence number
+	IDGeneratorSnowflake = "snowflake"
+)
+
+// MaxSnowflakeNode is the highest node number of the snowflake IDs (10 bits)
+const MaxSnowflakeNode = 1<<10 - 1
+
+// snowflakeEpoch is the origin of the timestamps of the snowflake IDs
+var snowflakeEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// crockfordAlphabet is the base32 alphabet of the ULIDs (without I, L, O and U)
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// IDGenerator generates the IDs of the documents (without key prefix)
+type IDGenerator interface {
+	NewID() string
+}
+
+var documentIDGenerator IDGenerator = uuidGenerator{}
+
+// NewIDGenerator returns the ID generator of a kind ("uuid", "uuidv7", "ulid" or "snowflake").
+// node is the number of the instance in the snowflake IDs (0 to MaxSnowflakeNode), so that several instances
+// do not generate the same IDs; it is ignored by the other generators.
+func NewIDGenerator(kind string, node int) (IDGenerator, error) {
+	switch strings.ToLower(kind) {
+	case IDGeneratorUUID:
+		return uuidGenerator{}, nil
+	case IDGeneratorUUIDv7:
+		return uuidv7Generator{}, nil
+	case IDGeneratorULID:
+		return &ulidGenerator{}, nil
+	case IDGeneratorSnowflake:
+		if node < 0 || node > MaxSnowflakeNode {
+			return nil, fmt.Errorf("invalid snowflake node %d (use 0 to %d)", node, MaxSnowflakeNode)
+		}
+		return &snowflakeGenerator{node: int64(node)}, nil
+	default:
+		return nil, fmt.Errorf("unknown document id generator %q (use %s, %s, %s or %s)", kind, IDGeneratorUUID, IDGeneratorUUIDv7, IDGeneratorULID, IDGeneratorSnowflake)
+	}
+}
+
+// SetDocumentIDGenerator sets the generator of the IDs of the new documents (see NewIDGenerator)
+func SetDocumentIDGenerator(kind string, node int) error {
+	generator, err := NewIDGenerator(kind, node)
+	if err != nil {
+		return err
+	}
+	documentIDGenerator = generator
+	return nil
+}
+
+// uuidGenerator generates random UUIDs
+type uuidGenerator struct{}
+
+func (uuidGenerator) NewID() string {
+	return uuid.New().String()
+}
+
+// uuidv7Generator generates time-ordered UUIDs
+type uuidv7Generator struct{}
+
+func (uuidv7Generator) NewID() string {
+	id, err := uuid.NewV7()
+	if err != nil {
+		return uuid.New().String()
+	}
+	return id.String()
+}
+
+// ulidGenerator generates monotonic ULIDs: the random part of the IDs generated in the same millisecond is incremented,
+// so that they are also sorted
+type ulidGenerator struct {
+	mutex    sync.Mutex
+	lastTime int64
+	random   [10]byte
+}
+
+func (g *ulidGenerator) NewID() string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	now := time.Now().UnixMilli()
+	if now > g.lastTime || !incrementBytes(g.random[:]) {
+		rand.Read(g.random[:])
+		g.lastTime = max(now, g.lastTime)
+	}
+
+	var id [16]byte
+	for i := 0; i < 6; i++ {
+		id[i] = byte(g.lastTime >> (40 - 8*i))
+	}
+	copy(id[6:], g.random[:])
+	return encodeULID(id)
+}
+
+// incrementBytes increments a big-endian number, and returns false when it overflows
+func incrementBytes(b []byte) bool {
+	for i := len(b) - 1; i >= 0; i-- {
+		b[i]++
+		if b[i] != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// encodeULID encodes the 128 bits of a ULID in 26 characters of Crockford's base32 (the first character holds 3 bits)
+func encodeULID(id [16]byte) string {
+	var encoded [26]byte
+	var bits uint16
+	var count uint
+	position := len(encoded) - 1
+	for i := len(id) - 1; i >= 0; i-- {
+		bits |= uint16(id[i]) << count
+		count += 8
+		for count >= 5 {
+			encoded[position] = crockfordAlphabet[bits&31]
+			position--
+			bits >>= 5
+			count -= 5
+		}
+	}
+	encoded[position] = crockfordAlphabet[bits&31]
+	return string(encoded[:])
+}
+
+// snowflakeGenerator generates snowflake IDs: 41 bits of milliseconds since snowflakeEpoch, 10 bits of node
+// and 12 bits of sequence number, written with 19 digits so that the IDs are sorted as strings
+type snowflakeGenerator struct {
+	mutex    sync.Mutex
+	node     int64
+	lastTime int64
+	sequence int64
+}
+
+func (g *snowflakeGenerator) NewID() string {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+
+	// A clock set back keeps the last timestamp, the IDs stay sorted
+	now := max(time.Since(snowflakeEpoch).Milliseconds(), g.lastTime)
+	if now == g.lastTime {
+		g.sequence = (g.sequence + 1) & 0xfff
+		if g.sequence == 0 {
+			// 4096 IDs in this millisecond, wait for the next one
+			for now <= g.lastTime {
+				time.Sleep(100 * time.Microsecond)
+				now = time.Since(snowflakeEpoch).Milliseconds()
+			}
+		}
+	} else {
+		g.sequence = 0
+	}
+	g.lastTime = now
+	return fmt.Sprintf("%019d", now<<22|g.node<<12|g.sequence)
+}
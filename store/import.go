@@ -0,0 +1,368 @@
+package store
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"vectormind/models"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// Import formats of the built-in importers
+const (
+	ImportFormatLangChain  = "langchain"
+	ImportFormatLlamaIndex = "llamaindex"
+)
+
+// importBatchSize is the number of imported documents embedded by request to the embedding model
+const importBatchSize = 64
+
+// ImportedDocument is a document read from the export of another vector store
+type ImportedDocument struct {
+	ID        string // ID of the document in the exported store ("" when unknown)
+	Content   string
+	Metadata  map[string]any
+	Embedding []float32 // embedding of the export (nil without embedding)
+}
+
+// Importer reads the documents of an export of another vector store
+type Importer func(data []byte) ([]ImportedDocument, error)
+
+var (
+	importersMutex sync.RWMutex
+	importers      = make(map[string]Importer)
+)
+
+// RegisterImporter makes an importer available by name to ParseExport (the format parameter of POST /import), e.g.
+// from the init function of a package imported by a fork. It panics if the name is empty, the importer is nil or the
+// name is already registered.
+func RegisterImporter(name string, importer Importer) {
+	importersMutex.Lock()
+	defer importersMutex.Unlock()
+
+	if name == "" {
+		panic("store: RegisterImporter with an empty name")
+	}
+	if importer == nil {
+		panic("store: RegisterImporter with a nil importer for " + name)
+	}
+	if _, exists := importers[name]; exists {
+		panic("store: RegisterImporter called twice for " + name)
+	}
+	importers[name] = importer
+}
+
+// Importers returns the sorted names of the registered importers
+func Importers() []string {
+	importersMutex.RLock()
+	defer importersMutex.RUnlock()
+
+	return slices.Sorted(maps.Keys(importers))
+}
+
+// ParseExport reads the documents of an export with the importer of its format. The documents without content are
+// skipped.
+func ParseExport(format string, data []byte) ([]ImportedDocument, error) {
+	importersMutex.RLock()
+	importer, ok := importers[format]
+	importersMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown import format %q (registered: %s)", format, strings.Join(Importers(), ", "))
+	}
+
+	docs, err := importer(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s export: %w", format, err)
+	}
+	return slices.DeleteFunc(docs, func(doc ImportedDocument) bool { return strings.TrimSpace(doc.Content) == "" }), nil
+}
+
+func init() {
+	RegisterImporter(ImportFormatLangChain, importLangChain)
+	RegisterImporter(ImportFormatLlamaIndex, importLlamaIndex)
+}
+
+// langChainContentFields and langChainVectorFields are the fields of the content and of the embedding of the
+// LangChain documents: page_content for the serialized documents, content and content_vector for the hashes of the
+// Redis vector store of langchain_community, text and embedding for the ones of langchain-redis
+var (
+	langChainContentFields = []string{"page_content", "content", "text"}
+	langChainVectorFields  = []string{"content_vector", "embedding", "vector"}
+)
+
+// importLangChain reads a JSON array, or JSON lines, of LangChain documents: serialized documents
+// ({"page_content", "metadata", "id"}, also wrapped in the {"lc": 1, "kwargs": {...}} of dumpd), or hashes of the
+// LangChain Redis vector store (the content, the embedding as an array of numbers or as base64 float32 bytes, and the
+// metadata as a JSON object, a JSON string or as the other fields of the hash)
+func importLangChain(data []byte) ([]ImportedDocument, error) {
+	records, err := decodeJSONRecords(data)
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]ImportedDocument, 0, len(records))
+	for i, record := range records {
+		if kwargs, ok := record["kwargs"].(map[string]any); ok && record["lc"] != nil {
+			record = kwargs
+		}
+		doc := ImportedDocument{Metadata: map[string]any{}}
+		doc.ID, _ = record["id"].(string)
+
+		fields := slices.Sorted(maps.Keys(record))
+		for _, field := range fields {
+			value := record[field]
+			switch {
+			case field == "id" || field == "type" && value == "Document":
+			case slices.Contains(langChainContentFields, field) && doc.Content == "":
+				doc.Content, _ = value.(string)
+			case slices.Contains(langChainVectorFields, field) && doc.Embedding == nil:
+				if doc.Embedding, err = decodeExportVector(value); err != nil {
+					return nil, fmt.Errorf("document %d: %w", i+1, err)
+				}
+			case field == "metadata":
+				metadata, err := decodeExportMetadata(value)
+				if err != nil {
+					return nil, fmt.Errorf("document %d: %w", i+1, err)
+				}
+				maps.Copy(doc.Metadata, metadata)
+			case value != nil && !slices.Contains(langChainContentFields, field) && !slices.Contains(langChainVectorFields, field):
+				// The Redis vector store stores each metadata field in the hash
+				doc.Metadata[field] = value
+			}
+		}
+		docs = append(docs, doc)
+	}
+	return docs, nil
+}
+
+// llamaIndexDocstore is the JSON of a LlamaIndex docstore (docstore.json of a persisted storage context)
+type llamaIndexDocstore struct {
+	Data map[string]struct {
+		Data json.RawMessage `json:"__data__"`
+	} `json:"docstore/data"`
+}
+
+// llamaIndexNode holds the fields of a LlamaIndex node or document read from a docstore
+type llamaIndexNode struct {
+	ID           string `json:"id_"`
+	Text         string `json:"text"`
+	TextResource *struct {
+		Text string `json:"text"`
+	} `json:"text_resource"`
+	Metadata  map[string]any `json:"metadata"`
+	Embedding []float32      `json:"embedding"`
+}
+
+// importLlamaIndex reads the nodes of a LlamaIndex docstore JSON ({"docstore/data": {id: {"__data__": node}}}), in the
+// order of their IDs. The __data__ of the older versions of LlamaIndex is a JSON string.
+func importLlamaIndex(data []byte) ([]ImportedDocument, error) {
+	var docstore llamaIndexDocstore
+	if err := json.Unmarshal(data, &docstore); err != nil {
+		return nil, err
+	}
+	if docstore.Data == nil {
+		return nil, fmt.Errorf("no docstore/data object")
+	}
+
+	ids := slices.Sorted(maps.Keys(docstore.Data))
+	docs := make([]ImportedDocument, 0, len(ids))
+	for _, id := range ids {
+		raw := docstore.Data[id].Data
+		var encoded string
+		if json.Unmarshal(raw, &encoded) == nil {
+			raw = json.RawMessage(encoded)
+		}
+		var node llamaIndexNode
+		if err := json.Unmarshal(raw, &node); err != nil {
+			return nil, fmt.Errorf("node %s: %w", id, err)
+		}
+		if node.Text == "" && node.TextResource != nil {
+			node.Text = node.TextResource.Text
+		}
+		if node.ID == "" {
+			node.ID = id
+		}
+		docs = append(docs, ImportedDocument{ID: node.ID, Content: node.Text, Metadata: node.Metadata, Embedding: node.Embedding})
+	}
+	return docs, nil
+}
+
+// decodeJSONRecords decodes a JSON array of objects, or JSON lines of objects
+func decodeJSONRecords(data []byte) ([]map[string]any, error) {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("[")) {
+		var records []map[string]any
+		if err := json.Unmarshal(data, &records); err != nil {
+			return nil, err
+		}
+		return records, nil
+	}
+
+	records := []map[string]any{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	for decoder.More() {
+		var record map[string]any
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("document %d: %w", len(records)+1, err)
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// decodeExportVector decodes an exported embedding: an array of numbers, or the base64 of its float32 bytes
+// (little endian, as stored in the Redis hashes)
+func decodeExportVector(value any) ([]float32, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case []any:
+		vector := make([]float32, len(value))
+		for i, component := range value {
+			number, ok := component.(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid embedding component %v", component)
+			}
+			vector[i] = float32(number)
+		}
+		return vector, nil
+	case string:
+		buf, err := base64.StdEncoding.DecodeString(value)
+		if err != nil || len(buf)%4 != 0 {
+			return nil, fmt.Errorf("invalid embedding: an array of numbers or the base64 of float32 bytes is expected")
+		}
+		return bytesToFloats(buf), nil
+	}
+	return nil, fmt.Errorf("invalid embedding: an array of numbers or the base64 of float32 bytes is expected")
+}
+
+// decodeExportMetadata decodes exported metadata: a JSON object, or a string holding one
+func decodeExportMetadata(value any) (map[string]any, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case map[string]any:
+		return value, nil
+	case string:
+		if value == "" {
+			return nil, nil
+		}
+		var metadata map[string]any
+		if err := json.Unmarshal([]byte(value), &metadata); err != nil {
+			return nil, fmt.Errorf("invalid metadata: %w", err)
+		}
+		return metadata, nil
+	}
+	return nil, fmt.Errorf("invalid metadata: a JSON object is expected")
+}
+
+// ImportOptions are the options of an import
+type ImportOptions struct {
+	Label string // label of the imported documents
+	// KeepIDs stores the documents with their IDs in the exported store (see CallerDocumentID), replacing the
+	// documents already imported with them, instead of new IDs
+	KeepIDs bool
+	// KeepEmbeddings stores the embeddings of the export having the dimension of the index, instead of embedding
+	// the content again (only when the export was embedded with the embedding model of the collection)
+	KeepEmbeddings bool
+}
+
+// ImportDocuments stores the documents read from an export in a collection (see ParseExport): their content is
+// embedded with the embedding model of the collection, by batches, unless KeepEmbeddings keeps the exported
+// embeddings. It returns the status of each document, and the number of exported embeddings kept; the documents that
+// cannot be embedded or stored are reported as failed and the import goes on.
+func ImportDocuments(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId string, collection Collection, docs []ImportedDocument, options ImportOptions) ([]models.ChunkStatus, int, error) {
+	dimension := 0
+	if options.KeepEmbeddings {
+		var err error
+		if dimension, err = IndexEmbeddingDimension(ctx, redisClient, collection.IndexName); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	statuses := make([]models.ChunkStatus, len(docs))
+	kept := 0
+	for start := 0; start < len(docs); start += importBatchSize {
+		batch := docs[start:min(start+importBatchSize, len(docs))]
+
+		// The documents without a kept embedding are embedded together
+		toEmbed := []int{}
+		texts := []string{}
+		for i, doc := range batch {
+			if !options.KeepEmbeddings || len(doc.Embedding) != dimension {
+				toEmbed = append(toEmbed, i)
+				texts = append(texts, doc.Content)
+			}
+		}
+		embeddings := make([][]float32, len(batch))
+		var embedErr error
+		if len(texts) > 0 {
+			var vectors [][]float32
+			vectors, embedErr = CreateEmbeddingsFromTexts(WithUsageLabel(ctx, options.Label), openaiClient, texts, collection.ModelID(embeddingModelId))
+			for j, i := range toEmbed {
+				if embedErr == nil {
+					embeddings[i] = vectors[j]
+				}
+			}
+		}
+
+		for i, doc := range batch {
+			index := start + i
+			status := models.ChunkStatus{Index: index, Status: models.ChunkStatusStored}
+			embedding := embeddings[i]
+			if embedding == nil && slices.Contains(toEmbed, i) {
+				status.Status = models.ChunkStatusFailed
+				status.Error = fmt.Sprintf("Failed to create embedding: %v", embedErr)
+				statuses[index] = status
+				continue
+			}
+			if embedding == nil {
+				embedding = doc.Embedding
+			}
+
+			id, err := storeImportedDocument(ctx, redisClient, collection, doc, embedding, options)
+			status.ID = id
+			if err != nil {
+				status.Status = models.ChunkStatusFailed
+				status.Error = err.Error()
+			} else if !slices.Contains(toEmbed, i) {
+				kept++
+			}
+			statuses[index] = status
+		}
+		if ctx.Err() != nil {
+			return statuses[:start+len(batch)], kept, ctx.Err()
+		}
+	}
+	return statuses, kept, nil
+}
+
+// storeImportedDocument stores an imported document with its embedding and returns its ID
+func storeImportedDocument(ctx context.Context, redisClient *redis.Client, collection Collection, doc ImportedDocument, embedding []float32, options ImportOptions) (string, error) {
+	metadata := ""
+	if len(doc.Metadata) > 0 {
+		encoded, err := json.Marshal(doc.Metadata)
+		if err != nil {
+			return "", fmt.Errorf("invalid metadata: %w", err)
+		}
+		metadata = string(encoded)
+	}
+
+	if !options.KeepIDs || doc.ID == "" {
+		id := NewDocumentID(collection.KeyPrefix)
+		return id, StoreEmbedding(ctx, redisClient, id, doc.Content, embedding, options.Label, metadata)
+	}
+	id, err := CallerDocumentID(collection.KeyPrefix, doc.ID)
+	if err != nil {
+		return "", err
+	}
+	return id, InsertDocument(ctx, redisClient, NewDocument(id, doc.Content, embedding, options.Label, metadata, 0), true)
+}
@@ -0,0 +1,240 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"vectormind/events"
+	"vectormind/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrIndexNotFound is returned when an index does not exist
+//
+// Deprecated: use ErrIndexMissing, which it is.
+var ErrIndexNotFound = ErrIndexMissing
+
+// ErrDimensionMismatch is returned when the vectors of an index do not have the dimension of the embedding model
+var ErrDimensionMismatch = errors.New("embedding dimension mismatch")
+
+// IndexInfo holds the document count, the indexing state and the memory usage of an index (FT.INFO)
+type IndexInfo struct {
+	Name             string
+	KeyPrefixes      []string
+	NumDocs          int
+	NumRecords       int
+	Indexing         bool
+	PercentIndexed   float64
+	IndexingFailures int
+	// Memory usage of the index structures in MB (the documents themselves are stored in the hashes)
+	VectorIndexSizeMB  float64
+	InvertedSizeMB     float64
+	DocTableSizeMB     float64
+	KeyTableSizeMB     float64
+	TotalIndexMemoryMB float64
+}
+
+// isUnknownIndexError checks if an error indicates that an index does not exist
+// (the clients of CreateRedisClient already classify these errors as ErrIndexMissing)
+func isUnknownIndexError(err error, indexName string) bool {
+	return errors.Is(err, ErrIndexMissing) || err.Error() == "Unknown index name" ||
+		redis.HasErrorPrefix(err, "vectormind_index: no such index") ||
+		redis.HasErrorPrefix(err, indexName+": no such index")
+}
+
+// GetIndexInfo returns the information of an index.
+// It returns ErrIndexNotFound when the index does not exist.
+func GetIndexInfo(ctx context.Context, redisClient *redis.Client, indexName string) (IndexInfo, error) {
+	info, err := redisClient.FTInfo(ctx, indexName).Result()
+	if err != nil {
+		if isUnknownIndexError(err, indexName) {
+			return IndexInfo{}, fmt.Errorf("%w: %s", ErrIndexNotFound, indexName)
+		}
+		return IndexInfo{}, fmt.Errorf("failed to get the information of index %s: %w", indexName, err)
+	}
+
+	indexInfo := IndexInfo{
+		Name:               indexName,
+		KeyPrefixes:        info.IndexDefinition.Prefixes,
+		NumDocs:            info.NumDocs,
+		NumRecords:         info.NumRecords,
+		Indexing:           info.Indexing != 0,
+		PercentIndexed:     info.PercentIndexed,
+		IndexingFailures:   info.HashIndexingFailures,
+		VectorIndexSizeMB:  info.VectorIndexSzMB,
+		InvertedSizeMB:     info.InvertedSzMB,
+		DocTableSizeMB:     info.DocTableSizeMB,
+		KeyTableSizeMB:     info.KeyTableSizeMB,
+		TotalIndexMemoryMB: info.TotalIndexMemorySzMB,
+	}
+	// Older Redis versions do not report the total memory of the index
+	if indexInfo.TotalIndexMemoryMB == 0 {
+		indexInfo.TotalIndexMemoryMB = info.VectorIndexSzMB + info.InvertedSzMB + info.DocTableSizeMB + info.KeyTableSizeMB +
+			info.OffsetVectorsSzMB + info.SortableValuesSizeMB + info.TagOverheadSzMB + info.TextOverheadSzMB
+	}
+	return indexInfo, nil
+}
+
+// GetIndexStats returns the number of documents of an index, per label, the memory of the index and the dimension of
+// its vectors. It returns ErrIndexNotFound when the index does not exist.
+func GetIndexStats(ctx context.Context, redisClient *redis.Client, indexName string) (models.IndexStats, error) {
+	info, err := GetIndexInfo(ctx, redisClient, indexName)
+	if err != nil {
+		return models.IndexStats{}, err
+	}
+	labels, err := LabelCounts(ctx, redisClient, indexName)
+	if err != nil {
+		return models.IndexStats{}, err
+	}
+	dimension, err := IndexEmbeddingDimension(ctx, redisClient, indexName)
+	if err != nil {
+		return models.IndexStats{}, err
+	}
+	return models.IndexStats{
+		Index:              indexName,
+		Documents:          info.NumDocs,
+		Labels:             labels,
+		IndexMemoryMB:      info.TotalIndexMemoryMB,
+		EmbeddingDimension: dimension,
+	}, nil
+}
+
+// IndexEmbeddingDimension returns the dimension of the vector field of an index.
+// It returns ErrIndexNotFound when the index does not exist.
+func IndexEmbeddingDimension(ctx context.Context, redisClient *redis.Client, indexName string) (int, error) {
+	// The parsed FT.INFO result does not include the vector settings, the attributes are read from the raw reply
+	reply, err := redisClient.Do(ctx, "FT.INFO", indexName).Slice()
+	if err != nil {
+		if isUnknownIndexError(err, indexName) {
+			return 0, fmt.Errorf("%w: %s", ErrIndexNotFound, indexName)
+		}
+		return 0, fmt.Errorf("failed to get the information of index %s: %w", indexName, err)
+	}
+
+	for i := 0; i+1 < len(reply); i += 2 {
+		if key, _ := reply[i].(string); key != "attributes" {
+			continue
+		}
+		attributes, _ := reply[i+1].([]interface{})
+		for _, attribute := range attributes {
+			if dimension := vectorAttributeDimension(attribute); dimension > 0 {
+				return dimension, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("index %s has no vector field", indexName)
+}
+
+// vectorAttributeDimension returns the dimension of an attribute of the FT.INFO reply (0 when it is not a vector field).
+// The settings of an attribute are a flat list of names and values, e.g. [identifier embedding type VECTOR dim 1024 ...]
+func vectorAttributeDimension(attribute interface{}) int {
+	settings, _ := attribute.([]interface{})
+	for i := 0; i+1 < len(settings); i++ {
+		if name, _ := settings[i].(string); strings.EqualFold(name, "dim") {
+			switch value := settings[i+1].(type) {
+			case int64:
+				return int(value)
+			case string:
+				dimension, _ := strconv.Atoi(value)
+				return dimension
+			}
+		}
+	}
+	return 0
+}
+
+// VerifyIndexDimension checks that the vectors of an existing index have the dimension of the embedding model.
+// It returns ErrDimensionMismatch when the embedding model has changed for a model of another dimension.
+func VerifyIndexDimension(ctx context.Context, redisClient *redis.Client, indexName string, embeddingDimension int) error {
+	indexDimension, err := IndexEmbeddingDimension(ctx, redisClient, indexName)
+	if err != nil {
+		return err
+	}
+	if indexDimension != embeddingDimension {
+		return fmt.Errorf("%w: index %s stores vectors of dimension %d, the embedding model produces vectors of dimension %d", ErrDimensionMismatch, indexName, indexDimension, embeddingDimension)
+	}
+	return nil
+}
+
+// AddMissingIndexFields adds to an existing index the fields of the current schema that it does not have (FT.ALTER),
+// e.g. the title and hierarchy fields of an index created before them, and returns their names. Redis indexes the
+// stored documents again in the background. The vector field is never added: its dimension is checked by
+// VerifyIndexDimension.
+func AddMissingIndexFields(ctx context.Context, redisClient *redis.Client, indexName string) ([]string, error) {
+	info, err := redisClient.FTInfo(ctx, indexName).Result()
+	if err != nil {
+		if isUnknownIndexError(err, indexName) {
+			return nil, fmt.Errorf("%w: %s", ErrIndexNotFound, indexName)
+		}
+		return nil, fmt.Errorf("failed to get the information of index %s: %w", indexName, err)
+	}
+	existing := make(map[string]bool, len(info.Attributes))
+	for _, attribute := range info.Attributes {
+		existing[attribute.Identifier] = true
+	}
+
+	var added []string
+	for _, field := range documentFieldSchemas() {
+		if existing[field.FieldName] {
+			continue
+		}
+		definition := []any{field.FieldName}
+		if field.As != "" {
+			definition = append(definition, "AS", field.As)
+		}
+		definition = append(definition, field.FieldType.String())
+		if field.Sortable {
+			definition = append(definition, "SORTABLE")
+		}
+		if field.NoIndex {
+			definition = append(definition, "NOINDEX")
+		}
+		if err := redisClient.FTAlter(ctx, indexName, false, definition).Err(); err != nil {
+			return added, fmt.Errorf("failed to add field %s to index %s: %w", field.FieldName, indexName, err)
+		}
+		added = append(added, field.FieldName)
+	}
+	return added, nil
+}
+
+// RebuildIndex drops the definition of an index, keeping its documents, and creates it again with the given settings.
+// Redis then indexes the existing documents again in the background (see IndexInfo.Indexing).
+// A missing index is created.
+func RebuildIndex(ctx context.Context, redisClient *redis.Client, indexName string, embeddingDimension int, options IndexOptions) error {
+	if err := ValidateIndexOptions(options); err != nil {
+		return err
+	}
+	if err := redisClient.FTDropIndex(ctx, indexName).Err(); err != nil && !isUnknownIndexError(err, indexName) {
+		return fmt.Errorf("failed to drop index %s: %w", indexName, err)
+	}
+	if err := CreateEmbeddingIndexWithOptions(ctx, redisClient, indexName, embeddingDimension, options); err != nil {
+		return fmt.Errorf("failed to create index %s: %w", indexName, err)
+	}
+	events.Record(events.TypeIndexRebuilt, fmt.Sprintf("Index %s rebuilt", indexName), map[string]any{
+		"index": indexName,
+		"db":    redisClient.Options().DB,
+	})
+	return nil
+}
+
+// ResetIndex drops an index and deletes all its documents, then creates it again, empty, with the given settings.
+// A missing index is created.
+func ResetIndex(ctx context.Context, redisClient *redis.Client, indexName string, embeddingDimension int, options IndexOptions) error {
+	if err := ValidateIndexOptions(options); err != nil {
+		return err
+	}
+	if err := DropIndex(ctx, redisClient, indexName).Err(); err != nil && !isUnknownIndexError(err, indexName) {
+		return fmt.Errorf("failed to drop index %s: %w", indexName, err)
+	}
+	if err := CreateEmbeddingIndexWithOptions(ctx, redisClient, indexName, embeddingDimension, options); err != nil {
+		return fmt.Errorf("failed to create index %s: %w", indexName, err)
+	}
+	events.Record(events.TypeIndexReset, fmt.Sprintf("Index %s reset, its documents are deleted", indexName), map[string]any{
+		"index": indexName,
+		"db":    redisClient.Options().DB,
+	})
+	return nil
+}
@@ -0,0 +1,159 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+	"vectormind/events"
+	"vectormind/models"
+
+	"github.com/google/uuid"
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// ingestionJobRetention is the time a completed ingestion job can still be read
+const ingestionJobRetention = time.Hour
+
+// maxIngestionJobErrors bounds the errors of the failed chunks kept in an ingestion job
+const maxIngestionJobErrors = 100
+
+// DefaultMaxIngestionJobs is the default number of ingestion jobs storing their chunks at the same time
+const DefaultMaxIngestionJobs = 2
+
+// ingestionJobSlots bounds the ingestion jobs storing their chunks at the same time (the next ones are queued), and
+// ingestionJobGuard refuses to start a job when Redis uses more memory than the watermark (see SetIngestionJobLimits)
+var (
+	ingestionJobSlots = make(chan struct{}, DefaultMaxIngestionJobs)
+	ingestionJobGuard *MemoryGuard
+)
+
+// SetIngestionJobLimits sets the number of ingestion jobs storing their chunks at the same time, and the memory guard
+// checked before a job starts (nil: not checked). The jobs run after the response of their request, out of the
+// concurrency limit and of the memory guard of the ingestion requests.
+func SetIngestionJobLimits(maxJobs int, memoryGuard *MemoryGuard) error {
+	if maxJobs <= 0 {
+		return fmt.Errorf("invalid maximum number of ingestion jobs %d (expected a number > 0)", maxJobs)
+	}
+	ingestionJobSlots = make(chan struct{}, maxJobs)
+	ingestionJobGuard = memoryGuard
+	return nil
+}
+
+// ingestionJob is an ingestion job of a tenant
+type ingestionJob struct {
+	tenant      string // "tenant:<name>:" prefix of the keys of the tenant ("" outside the tenants)
+	job         models.IngestionJob
+	completedAt time.Time
+}
+
+// ingestionJobs holds the ingestion jobs by ID (the running ones, and the completed ones for ingestionJobRetention)
+var (
+	ingestionJobs      = map[string]*ingestionJob{}
+	ingestionJobsMutex sync.Mutex
+)
+
+// StartIngestionJob stores the chunks in the background (see StoreChunks) and returns the job following their
+// progress. The job outlives the request that started it: it is not canceled with the context. The job is queued
+// until one of the slots of the ingestion jobs is free (see SetIngestionJobLimits).
+func StartIngestionJob(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId string, chunks []string, options ChunkOptions) models.IngestionJob {
+	now := time.Now()
+	entry := &ingestionJob{
+		tenant: tenantNamespace(options.KeyPrefix),
+		job: models.IngestionJob{
+			ID:          uuid.New().String(),
+			Status:      models.IngestionStatusQueued,
+			SourceID:    OriginalSourceID(options.SourceID, options.Original),
+			ChunksTotal: len(chunks),
+			CreatedAt:   now.Format(time.RFC3339),
+		},
+	}
+
+	ingestionJobsMutex.Lock()
+	for id, previous := range ingestionJobs {
+		if !previous.completedAt.IsZero() && now.Sub(previous.completedAt) > ingestionJobRetention {
+			delete(ingestionJobs, id)
+		}
+	}
+	ingestionJobs[entry.job.ID] = entry
+	snapshot := entry.job
+	ingestionJobsMutex.Unlock()
+
+	go runIngestionJob(context.WithoutCancel(ctx), openaiClient, redisClient, embeddingModelId, chunks, options, entry, ingestionJobSlots, ingestionJobGuard)
+	return snapshot
+}
+
+// runIngestionJob waits for a slot, then stores the chunks of an ingestion job and records the progress in the job
+func runIngestionJob(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId string, chunks []string, options ChunkOptions, entry *ingestionJob, slots chan struct{}, memoryGuard *MemoryGuard) {
+	slots <- struct{}{}
+	defer func() { <-slots }()
+	ingestionJobsMutex.Lock()
+	entry.job.Status = models.IngestionStatusRunning
+	ingestionJobsMutex.Unlock()
+
+	updateJob := func(statuses []models.ChunkStatus) {
+		chunkIDs, failed := StoredChunkIDs(statuses)
+		chunkErrors := []string{}
+		for _, status := range statuses {
+			if status.Status == models.ChunkStatusFailed && len(chunkErrors) < maxIngestionJobErrors {
+				chunkErrors = append(chunkErrors, fmt.Sprintf("chunk %d: %s", status.Index, status.Error))
+			}
+		}
+
+		ingestionJobsMutex.Lock()
+		defer ingestionJobsMutex.Unlock()
+		entry.job.ChunksDone = len(statuses)
+		entry.job.ChunksStored = len(chunkIDs)
+		entry.job.ChunksFailed = failed
+		entry.job.ChunkIDs = chunkIDs
+		entry.job.Errors = chunkErrors
+	}
+	options.Progress = updateJob
+
+	// Redis may have filled up while the job was queued
+	var statuses []models.ChunkStatus
+	err := memoryGuard.CheckWrite(ctx)
+	if err == nil {
+		statuses, err = StoreChunks(ctx, openaiClient, redisClient, embeddingModelId, chunks, options)
+	}
+	updateJob(statuses)
+
+	ingestionJobsMutex.Lock()
+	job := &entry.job
+	job.Status = models.IngestionStatusCompleted
+	if err != nil || (job.ChunksStored == 0 && job.ChunksFailed > 0) {
+		job.Status = models.IngestionStatusFailed
+	}
+	if err != nil {
+		job.Error = err.Error()
+	}
+	entry.completedAt = time.Now()
+	job.CompletedAt = entry.completedAt.Format(time.RFC3339)
+	snapshot := *job
+	ingestionJobsMutex.Unlock()
+
+	eventType, message := events.TypeJobCompleted, fmt.Sprintf("Ingestion job %s completed", snapshot.ID)
+	if snapshot.Status == models.IngestionStatusFailed {
+		eventType, message = events.TypeJobFailed, fmt.Sprintf("Ingestion job %s failed", snapshot.ID)
+	}
+	events.Record(eventType, message, map[string]any{
+		"job":    "ingestion",
+		"id":     snapshot.ID,
+		"total":  snapshot.ChunksTotal,
+		"stored": snapshot.ChunksStored,
+		"failed": snapshot.ChunksFailed,
+		"error":  snapshot.Error,
+	})
+}
+
+// GetIngestionJob returns the ingestion job with this ID started by the tenant of an index
+func GetIngestionJob(indexName, id string) (models.IngestionJob, bool) {
+	ingestionJobsMutex.Lock()
+	defer ingestionJobsMutex.Unlock()
+	entry, ok := ingestionJobs[id]
+	if !ok || entry.tenant != tenantNamespace(indexName) {
+		return models.IngestionJob{}, false
+	}
+	return entry.job, true
+}
@@ -0,0 +1,62 @@
+package store
+
+import (
+	"context"
+	"log"
+	"math"
+	"sync/atomic"
+	"time"
+
+	"github.com/openai/openai-go"
+)
+
+// keepaliveText is the text embedded by the keepalive pings
+const keepaliveText = "keepalive"
+
+// lastEmbeddingActivity is the time (Unix nanoseconds) of the last successful request to the primary embedding provider
+var lastEmbeddingActivity atomic.Int64
+
+// recordEmbeddingActivity records a successful request to the primary embedding provider
+func recordEmbeddingActivity() {
+	lastEmbeddingActivity.Store(time.Now().UnixNano())
+}
+
+// EmbeddingIdleDuration returns the time since the last successful request to the primary embedding provider
+func EmbeddingIdleDuration() time.Duration {
+	last := lastEmbeddingActivity.Load()
+	if last == 0 {
+		return time.Duration(math.MaxInt64)
+	}
+	return time.Since(time.Unix(0, last))
+}
+
+// RunEmbeddingKeepalive keeps the embedding model loaded by the model runner (local runners unload idle models):
+// every interval, the model is pinged with a small embedding request when it has been idle for the interval.
+// The pings go to the primary provider only, and are not counted in the embedding statistics.
+// It returns when the context is done.
+func RunEmbeddingKeepalive(ctx context.Context, openaiClient openai.Client, embeddingModelId string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// The model was used recently, no need to ping it
+			if EmbeddingIdleDuration() < interval {
+				continue
+			}
+			pingCtx, cancel := context.WithTimeout(ctx, interval)
+			_, err := primaryEmbeddings(pingCtx, openaiClient, []string{keepaliveText}, embeddingModelId)
+			cancel()
+			if err != nil {
+				if ctx.Err() == nil {
+					log.Printf("🟠 Embedding model keepalive ping failed: %v", err)
+				}
+				continue
+			}
+			recordEmbeddingActivity()
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// labelSeparator separates the labels of a document in the label field (the separator of the TAG fields)
+const labelSeparator = ","
+
+// Label matching of the searches filtered by several labels
+const (
+	// LabelMatchAny returns the documents having any of the labels (default)
+	LabelMatchAny = "any"
+	// LabelMatchAll returns the documents having all the labels
+	LabelMatchAll = "all"
+)
+
+// JoinLabels merges a label and a list of labels into the value of the label field of a document
+// (duplicates and empty labels are dropped). Labels cannot contain the label separator.
+func JoinLabels(label string, labels []string) (string, error) {
+	joined := []string{}
+	seen := map[string]bool{}
+	for _, l := range append([]string{label}, labels...) {
+		l = strings.TrimSpace(l)
+		if l == "" || seen[l] {
+			continue
+		}
+		if strings.Contains(l, labelSeparator) {
+			return "", fmt.Errorf("label %q cannot contain %q", l, labelSeparator)
+		}
+		seen[l] = true
+		joined = append(joined, l)
+	}
+	return strings.Join(joined, labelSeparator), nil
+}
+
+// SplitLabels returns the labels of a document from the value of its label field
+func SplitLabels(label string) []string {
+	if label == "" {
+		return nil
+	}
+	return strings.Split(label, labelSeparator)
+}
+
+// ValidateLabelMatch checks the label matching of a search filtered by several labels
+func ValidateLabelMatch(match string) error {
+	switch match {
+	case "", LabelMatchAny, LabelMatchAll:
+		return nil
+	default:
+		return fmt.Errorf("unknown label match %q (use %q or %q)", match, LabelMatchAny, LabelMatchAll)
+	}
+}
+
+// buildLabelsFilterQuery builds the RediSearch expression matching any or all of the labels (empty labels are
+// ignored, "" without label)
+func buildLabelsFilterQuery(labels []string, matchAll bool) string {
+	escaped := make([]string, 0, len(labels))
+	for _, label := range labels {
+		if label != "" {
+			escaped = append(escaped, escapeTagValue(label))
+		}
+	}
+	if len(escaped) == 0 {
+		return ""
+	}
+	if !matchAll {
+		return "@label:{" + strings.Join(escaped, " | ") + "}"
+	}
+	filters := make([]string, len(escaped))
+	for i, label := range escaped {
+		filters[i] = "@label:{" + label + "}"
+	}
+	return strings.Join(filters, " ")
+}
+
+// LabelCounts returns the number of documents of an index having each label. The labels are listed by FT.TAGVALS,
+// lowercased (the label filters ignore the case), and the documents of all the labels are counted with a single round
+// trip (one search per label). A document having several labels is counted for each of them.
+func LabelCounts(ctx context.Context, redisClient *redis.Client, indexName string) (map[string]int, error) {
+	labels, err := redisClient.FTTagVals(ctx, indexName, "label").Result()
+	if err != nil {
+		return nil, checkSearchError(ctx, redisClient, indexName, err)
+	}
+
+	counts := make(map[string]int, len(labels))
+	if len(labels) == 0 {
+		return counts, nil
+	}
+	documentCounts, err := labelDocumentCounts(ctx, redisClient, indexName, labels)
+	if err != nil {
+		return nil, err
+	}
+	for i, count := range documentCounts {
+		counts[labels[i]] = count
+	}
+	return counts, nil
+}
+
+// labelDocumentCounts returns the number of documents of an index having each label, in a single round trip
+func labelDocumentCounts(ctx context.Context, redisClient *redis.Client, indexName string, labels []string) ([]int, error) {
+	cmds := make([]*redis.FTSearchCmd, len(labels))
+	pipe := redisClient.Pipeline()
+	for i, label := range labels {
+		cmds[i] = pipe.FTSearchWithArgs(ctx, indexName, activeVersionsQuery(buildLabelsFilterQuery([]string{label}, false)), &redis.FTSearchOptions{
+			NoContent:      true,
+			CountOnly:      true,
+			DialectVersion: 2,
+		})
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, checkSearchError(ctx, redisClient, indexName, err)
+	}
+	counts := make([]int, len(labels))
+	for i, cmd := range cmds {
+		counts[i] = cmd.Val().Total
+	}
+	return counts, nil
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// maxResponseLanguageLength is the longest response language of a question, e.g. "Brazilian Portuguese" or "pt-BR"
+const maxResponseLanguageLength = 40
+
+// languageStopwords are frequent words of the languages told apart in the latin script (see DetectLanguage),
+// chosen to be rare in the other languages of the list
+var languageStopwords = map[string][]string{
+	"English":    {"the", "is", "are", "what", "how", "why", "which", "who", "does", "do", "can", "of", "and", "to", "in", "with", "for"},
+	"French":     {"le", "la", "les", "est", "sont", "quel", "quelle", "quels", "comment", "pourquoi", "qui", "des", "du", "et", "une", "avec", "pour", "dans"},
+	"German":     {"der", "die", "das", "ist", "sind", "wie", "warum", "welche", "wer", "und", "mit", "für", "ein", "eine", "nicht", "wird"},
+	"Spanish":    {"el", "los", "las", "es", "son", "qué", "cómo", "cuál", "por", "quién", "y", "del", "una", "con", "para", "está"},
+	"Italian":    {"il", "gli", "sono", "che", "cosa", "come", "perché", "quale", "chi", "e", "di", "della", "una", "con", "per", "è"},
+	"Portuguese": {"o", "os", "é", "são", "que", "como", "qual", "quem", "e", "do", "da", "uma", "com", "para", "não"},
+	"Dutch":      {"het", "is", "zijn", "worden", "wordt", "wat", "hoe", "waarom", "welke", "wie", "en", "van", "een", "met", "voor", "niet"},
+}
+
+// DetectLanguage returns the English name of the language of a text, e.g. "French" ("" when it cannot be told).
+// The languages of their own script (Japanese, Korean, Chinese, Greek, Hebrew, Thai) are told from their characters,
+// a few latin languages from their frequent words: the short or mixed texts are not detected.
+func DetectLanguage(text string) string {
+	scripts := map[string]int{}
+	letters := 0
+	for _, r := range text {
+		if !unicode.IsLetter(r) {
+			continue
+		}
+		letters++
+		switch {
+		case unicode.In(r, unicode.Hiragana, unicode.Katakana):
+			scripts["Japanese"]++
+		case unicode.Is(unicode.Hangul, r):
+			scripts["Korean"]++
+		case unicode.Is(unicode.Han, r):
+			scripts["Chinese"]++
+		case unicode.Is(unicode.Greek, r):
+			scripts["Greek"]++
+		case unicode.Is(unicode.Hebrew, r):
+			scripts["Hebrew"]++
+		case unicode.Is(unicode.Thai, r):
+			scripts["Thai"]++
+		case unicode.Is(unicode.Latin, r):
+			scripts["latin"]++
+		}
+	}
+	if letters == 0 {
+		return ""
+	}
+	// The Japanese texts mix kana and kanji (Han characters)
+	if scripts["Japanese"] > 0 {
+		return "Japanese"
+	}
+	for _, language := range []string{"Korean", "Chinese", "Greek", "Hebrew", "Thai"} {
+		if scripts[language]*2 > letters {
+			return language
+		}
+	}
+	if scripts["latin"]*2 <= letters {
+		return ""
+	}
+
+	scores := map[string]int{}
+	for _, word := range strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && r != '\''
+	}) {
+		for language, stopwords := range languageStopwords {
+			for _, stopword := range stopwords {
+				if word == stopword {
+					scores[language]++
+				}
+			}
+		}
+	}
+	best, bestScore, tie := "", 0, false
+	for language, score := range scores {
+		switch {
+		case score > bestScore:
+			best, bestScore, tie = language, score, false
+		case score == bestScore:
+			tie = true
+		}
+	}
+	if tie {
+		return ""
+	}
+	return best
+}
+
+// ValidateResponseLanguage checks the language requested for an answer: a language name or tag of letters, spaces
+// and '-' (e.g. "French", "pt-BR"), as it is written in the prompt of the chat model. "" and "auto" detect the
+// language of the question.
+func ValidateResponseLanguage(language string) error {
+	if len(language) > maxResponseLanguageLength {
+		return fmt.Errorf("invalid response language (up to %d characters)", maxResponseLanguageLength)
+	}
+	for _, r := range language {
+		if !unicode.IsLetter(r) && r != ' ' && r != '-' {
+			return fmt.Errorf("invalid response language %q (use letters, spaces or '-', e.g. \"French\" or \"pt-BR\")", language)
+		}
+	}
+	return nil
+}
+
+// responseLanguageInstruction returns the instruction of the prompt setting the language of the answer: the requested
+// language, else the detected language of the question
+func responseLanguageInstruction(question, language string) string {
+	if language == "" || strings.EqualFold(language, "auto") {
+		language = DetectLanguage(question)
+	}
+	if language == "" {
+		return "Answer in the language of the question, whatever the language of the sources."
+	}
+	return "Answer in " + language + ", whatever the language of the sources."
+}
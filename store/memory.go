@@ -0,0 +1,168 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrMemoryWatermarkExceeded is returned when a write is refused because Redis uses more memory than the watermark
+var ErrMemoryWatermarkExceeded = errors.New("redis memory watermark exceeded")
+
+// MemoryInfo holds the memory usage and the eviction settings of the Redis server
+type MemoryInfo struct {
+	UsedMemory      int64
+	UsedMemoryHuman string
+	MaxMemory       int64 // 0 means no limit
+	MaxMemoryPolicy string
+}
+
+// GetMemoryInfo returns the memory usage of the Redis server (INFO memory)
+func GetMemoryInfo(ctx context.Context, redisClient *redis.Client) (MemoryInfo, error) {
+	info, err := redisClient.Info(ctx, "memory").Result()
+	if err != nil {
+		return MemoryInfo{}, fmt.Errorf("failed to get redis memory info: %w", err)
+	}
+	return ParseMemoryInfo(info), nil
+}
+
+// ParseMemoryInfo parses the output of the INFO memory command
+func ParseMemoryInfo(info string) MemoryInfo {
+	var memoryInfo MemoryInfo
+	for _, line := range strings.Split(info, "\n") {
+		key, value, found := strings.Cut(strings.TrimSpace(line), ":")
+		if !found {
+			continue
+		}
+		switch key {
+		case "used_memory":
+			memoryInfo.UsedMemory, _ = strconv.ParseInt(value, 10, 64)
+		case "used_memory_human":
+			memoryInfo.UsedMemoryHuman = value
+		case "maxmemory":
+			memoryInfo.MaxMemory, _ = strconv.ParseInt(value, 10, 64)
+		case "maxmemory_policy":
+			memoryInfo.MaxMemoryPolicy = value
+		}
+	}
+	return memoryInfo
+}
+
+// EvictionWarning describes how the eviction policy of Redis can silently drop stored vectors.
+// It returns an empty string when no document can be evicted.
+func EvictionWarning(memoryInfo MemoryInfo) string {
+	if memoryInfo.MaxMemory == 0 || memoryInfo.MaxMemoryPolicy == "" || memoryInfo.MaxMemoryPolicy == "noeviction" {
+		return ""
+	}
+	if strings.HasPrefix(memoryInfo.MaxMemoryPolicy, "volatile-") {
+		return fmt.Sprintf("Redis maxmemory-policy is %s: documents with an expiration can be evicted when maxmemory (%d bytes) is reached, use noeviction to keep them", memoryInfo.MaxMemoryPolicy, memoryInfo.MaxMemory)
+	}
+	return fmt.Sprintf("Redis maxmemory-policy is %s: stored vectors can be silently evicted when maxmemory (%d bytes) is reached, use noeviction to keep them", memoryInfo.MaxMemoryPolicy, memoryInfo.MaxMemory)
+}
+
+// MemoryWatermark is the memory usage above which writes are refused,
+// either in bytes or as a percentage of the Redis maxmemory
+type MemoryWatermark struct {
+	Bytes   int64
+	Percent float64
+}
+
+// ParseMemoryWatermark parses a watermark like "90%", "512mb", "2gb" or a number of bytes.
+// An empty value disables the watermark.
+func ParseMemoryWatermark(spec string) (MemoryWatermark, error) {
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "" {
+		return MemoryWatermark{}, nil
+	}
+
+	if percent, found := strings.CutSuffix(spec, "%"); found {
+		value, err := strconv.ParseFloat(strings.TrimSpace(percent), 64)
+		if err != nil || value <= 0 || value > 100 {
+			return MemoryWatermark{}, fmt.Errorf("invalid memory watermark %q (percentage must be between 0 and 100)", spec)
+		}
+		return MemoryWatermark{Percent: value}, nil
+	}
+
+	units := []struct {
+		suffix     string
+		multiplier int64
+	}{
+		{"gb", 1 << 30},
+		{"mb", 1 << 20},
+		{"kb", 1 << 10},
+		{"b", 1},
+	}
+	multiplier := int64(1)
+	for _, unit := range units {
+		if value, found := strings.CutSuffix(spec, unit.suffix); found {
+			spec, multiplier = strings.TrimSpace(value), unit.multiplier
+			break
+		}
+	}
+	value, err := strconv.ParseInt(spec, 10, 64)
+	if err != nil || value <= 0 {
+		return MemoryWatermark{}, fmt.Errorf("invalid memory watermark %q (use a percentage like 90%% or a size like 512mb)", spec)
+	}
+	return MemoryWatermark{Bytes: value * multiplier}, nil
+}
+
+// Limit returns the watermark in bytes for a Redis maxmemory (0 when the watermark is disabled).
+// A percentage watermark is disabled when Redis has no maxmemory.
+func (watermark MemoryWatermark) Limit(maxMemory int64) int64 {
+	if watermark.Bytes > 0 {
+		return watermark.Bytes
+	}
+	if watermark.Percent > 0 && maxMemory > 0 {
+		return int64(float64(maxMemory) * watermark.Percent / 100)
+	}
+	return 0
+}
+
+// MemoryGuard refuses writes when Redis uses more memory than the watermark
+type MemoryGuard struct {
+	redisClient *redis.Client
+	watermark   MemoryWatermark
+}
+
+// NewMemoryGuard creates a memory guard (a zero watermark never refuses writes)
+func NewMemoryGuard(redisClient *redis.Client, watermark MemoryWatermark) *MemoryGuard {
+	return &MemoryGuard{redisClient: redisClient, watermark: watermark}
+}
+
+// Enabled reports whether the guard can refuse writes
+func (guard *MemoryGuard) Enabled() bool {
+	return guard != nil && (guard.watermark.Bytes > 0 || guard.watermark.Percent > 0)
+}
+
+// Watermark returns the watermark in bytes for a Redis maxmemory (0 when disabled)
+func (guard *MemoryGuard) Watermark(maxMemory int64) int64 {
+	if !guard.Enabled() {
+		return 0
+	}
+	return guard.watermark.Limit(maxMemory)
+}
+
+// CheckWrite returns ErrMemoryWatermarkExceeded when Redis uses more memory than the watermark.
+// When the memory usage cannot be read, the write is allowed (Redis reports its own errors).
+func (guard *MemoryGuard) CheckWrite(ctx context.Context) error {
+	if !guard.Enabled() {
+		return nil
+	}
+
+	memoryInfo, err := GetMemoryInfo(ctx, guard.redisClient)
+	if err != nil {
+		log.Printf("🟠 Unable to check the memory watermark: %v", err)
+		return nil
+	}
+
+	limit := guard.watermark.Limit(memoryInfo.MaxMemory)
+	if limit > 0 && memoryInfo.UsedMemory >= limit {
+		return fmt.Errorf("%w: redis uses %d bytes, the write watermark is %d bytes", ErrMemoryWatermarkExceeded, memoryInfo.UsedMemory, limit)
+	}
+	return nil
+}
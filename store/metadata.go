@@ -0,0 +1,433 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Metadata field types
+const (
+	// MetadataFieldTag is a metadata field filtered by exact value (strings, booleans, arrays of values)
+	MetadataFieldTag = "tag"
+	// MetadataFieldNumeric is a metadata field filtered by value or range (numbers)
+	MetadataFieldNumeric = "numeric"
+	// MetadataFieldText is a metadata field searched by words with the keyword and hybrid searches (titles, file names, URLs)
+	MetadataFieldText = "text"
+)
+
+// Search fields of the keyword searches, besides the text metadata fields
+const (
+	// SearchFieldContent is the content of the documents (the default search field)
+	SearchFieldContent = "content"
+	// SearchFieldMetadata is the whole JSON metadata of the documents (its keys and its values)
+	SearchFieldMetadata = "metadata"
+)
+
+// metadataFieldPrefix is the prefix of the hash fields holding the flattened metadata values
+const metadataFieldPrefix = "meta_"
+
+// metadataFieldNamePattern matches the metadata keys that can be declared as filterable fields
+var metadataFieldNamePattern = regexp.MustCompile(`^[A-Za-z0-9_]+$`)
+
+// MetadataField is a top-level key of the JSON metadata that is indexed and can be used in search filters
+type MetadataField struct {
+	Name string
+	Type string // MetadataFieldTag, MetadataFieldNumeric or MetadataFieldText
+}
+
+// metadataFields are the filterable metadata fields (none by default)
+var metadataFields = []MetadataField{}
+
+// ParseMetadataFields parses a list of indexed metadata fields like "source:tag,year:numeric,title:text"
+// (the type defaults to tag)
+func ParseMetadataFields(spec string) ([]MetadataField, error) {
+	fields := []MetadataField{}
+	names := map[string]bool{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, fieldType, found := strings.Cut(entry, ":")
+		name = strings.TrimSpace(name)
+		fieldType = strings.ToLower(strings.TrimSpace(fieldType))
+		if !found {
+			fieldType = MetadataFieldTag
+		}
+		if !metadataFieldNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid metadata field name %q (use letters, digits and underscores)", name)
+		}
+		if fieldType != MetadataFieldTag && fieldType != MetadataFieldNumeric && fieldType != MetadataFieldText {
+			return nil, fmt.Errorf("unknown type %q of metadata field %s (use %s, %s or %s)", fieldType, name, MetadataFieldTag, MetadataFieldNumeric, MetadataFieldText)
+		}
+		if names[name] {
+			return nil, fmt.Errorf("duplicate metadata field %s", name)
+		}
+		names[name] = true
+		fields = append(fields, MetadataField{Name: name, Type: fieldType})
+	}
+	return fields, nil
+}
+
+// SetMetadataFields sets the filterable metadata fields.
+// The fields are part of the index schema: an existing index must be recreated to index new fields.
+func SetMetadataFields(fields []MetadataField) {
+	if fields == nil {
+		fields = []MetadataField{}
+	}
+	metadataFields = fields
+}
+
+// GetMetadataFields returns the filterable metadata fields
+func GetMetadataFields() []MetadataField {
+	return metadataFields
+}
+
+// lookupMetadataField returns the filterable metadata field with the given name
+func lookupMetadataField(name string) (MetadataField, bool) {
+	for _, field := range metadataFields {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return MetadataField{}, false
+}
+
+// metadataFieldSchemas returns the index schema of the indexed metadata fields
+func metadataFieldSchemas() []*redis.FieldSchema {
+	schemas := make([]*redis.FieldSchema, 0, len(metadataFields))
+	for _, field := range metadataFields {
+		fieldType := redis.SearchFieldTypeTag
+		switch field.Type {
+		case MetadataFieldNumeric:
+			fieldType = redis.SearchFieldTypeNumeric
+		case MetadataFieldText:
+			fieldType = redis.SearchFieldTypeText
+		}
+		schemas = append(schemas, &redis.FieldSchema{
+			FieldName: metadataFieldPrefix + field.Name,
+			FieldType: fieldType,
+		})
+	}
+	return schemas
+}
+
+// metadataHashFields returns the names of the hash fields of all the filterable metadata fields
+func metadataHashFields() []string {
+	names := make([]string, 0, len(metadataFields))
+	for _, field := range metadataFields {
+		names = append(names, metadataFieldPrefix+field.Name)
+	}
+	return names
+}
+
+// flattenMetadata extracts the values of the indexed fields from JSON object metadata.
+// Metadata that is not a JSON object, and values that do not match the type of their field, are not indexed.
+func flattenMetadata(metadata string) map[string]any {
+	flattened := map[string]any{}
+	if len(metadataFields) == 0 || !strings.HasPrefix(strings.TrimSpace(metadata), "{") {
+		return flattened
+	}
+
+	var values map[string]any
+	if err := json.Unmarshal([]byte(metadata), &values); err != nil {
+		return flattened
+	}
+
+	for _, field := range metadataFields {
+		value, ok := values[field.Name]
+		if !ok {
+			continue
+		}
+		switch field.Type {
+		case MetadataFieldNumeric:
+			if number, ok := value.(float64); ok {
+				flattened[metadataFieldPrefix+field.Name] = number
+			}
+		case MetadataFieldText:
+			words := []string{}
+			items, isArray := value.([]any)
+			if !isArray {
+				items = []any{value}
+			}
+			for _, item := range items {
+				if text, ok := tagValue(item); ok {
+					words = append(words, text)
+				}
+			}
+			if len(words) > 0 {
+				flattened[metadataFieldPrefix+field.Name] = strings.Join(words, " ")
+			}
+		default:
+			tags := []string{}
+			items, isArray := value.([]any)
+			if !isArray {
+				items = []any{value}
+			}
+			for _, item := range items {
+				if tag, ok := tagValue(item); ok {
+					tags = append(tags, tag)
+				}
+			}
+			if len(tags) > 0 {
+				// the default separator of the TAG fields
+				flattened[metadataFieldPrefix+field.Name] = strings.Join(tags, ",")
+			}
+		}
+	}
+	return flattened
+}
+
+// MergeMetadata adds fields to the JSON object metadata of a document (the fields replace the keys with the same name).
+// The metadata must be empty or a JSON object.
+func MergeMetadata(metadata string, fields map[string]any) (string, error) {
+	values := map[string]any{}
+	if strings.TrimSpace(metadata) != "" {
+		if err := json.Unmarshal([]byte(metadata), &values); err != nil {
+			return "", fmt.Errorf("metadata must be a JSON object to add fields to it: %w", err)
+		}
+		if values == nil {
+			values = map[string]any{} // "null"
+		}
+	}
+	for name, value := range fields {
+		values[name] = value
+	}
+	merged, err := json.Marshal(values)
+	if err != nil {
+		return "", err
+	}
+	return string(merged), nil
+}
+
+// tagValue converts a scalar JSON value to a tag
+func tagValue(value any) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, v != ""
+	case bool:
+		return strconv.FormatBool(v), true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	default:
+		return "", false
+	}
+}
+
+// MetadataFilter restricts a search to the documents whose metadata field matches the values or the range
+type MetadataFilter struct {
+	Field  string
+	Values []string // tag fields: any of the values; numeric fields: any of the numbers
+	// Numeric range bounds (nil for no bound)
+	Min          *float64
+	Max          *float64
+	MinExclusive bool
+	MaxExclusive bool
+}
+
+// ParseMetadataFilters parses search filters like {"source": "wiki", "tags": ["go", "redis"], "year": {"gte": 2020}}:
+// a value is an equality, an array is a membership (any of the values), an object holds the operators
+// eq, in, gt, gte, lt and lte (ranges only apply to numeric fields). Only the filterable metadata fields can be used.
+func ParseMetadataFilters(filters map[string]any) ([]MetadataFilter, error) {
+	names := make([]string, 0, len(filters))
+	for name := range filters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parsed := make([]MetadataFilter, 0, len(filters))
+	for _, name := range names {
+		field, ok := lookupMetadataField(name)
+		if !ok {
+			return nil, fmt.Errorf("metadata field %q is not filterable", name)
+		}
+		if field.Type == MetadataFieldText {
+			return nil, fmt.Errorf("metadata field %q is a %s field, it is searched with the search fields, not filtered", name, MetadataFieldText)
+		}
+
+		filter := MetadataFilter{Field: name}
+		var err error
+		switch value := filters[name].(type) {
+		case map[string]any:
+			err = parseFilterOperators(field, value, &filter)
+		case []any:
+			filter.Values, err = filterValues(field, value)
+		default:
+			filter.Values, err = filterValues(field, []any{value})
+		}
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, filter)
+	}
+	return parsed, nil
+}
+
+// parseFilterOperators parses the operators of an object filter
+func parseFilterOperators(field MetadataField, operators map[string]any, filter *MetadataFilter) error {
+	if len(operators) == 0 {
+		return fmt.Errorf("empty filter on metadata field %s", field.Name)
+	}
+	for operator, operand := range operators {
+		switch operator {
+		case "eq", "in":
+			items, isArray := operand.([]any)
+			if !isArray {
+				items = []any{operand}
+			}
+			values, err := filterValues(field, items)
+			if err != nil {
+				return err
+			}
+			filter.Values = append(filter.Values, values...)
+		case "gt", "gte", "lt", "lte":
+			if field.Type != MetadataFieldNumeric {
+				return fmt.Errorf("operator %s needs a numeric metadata field, %s is a %s field", operator, field.Name, field.Type)
+			}
+			number, ok := operand.(float64)
+			if !ok {
+				return fmt.Errorf("operator %s of metadata field %s needs a number", operator, field.Name)
+			}
+			if operator == "gt" || operator == "gte" {
+				filter.Min, filter.MinExclusive = &number, operator == "gt"
+			} else {
+				filter.Max, filter.MaxExclusive = &number, operator == "lt"
+			}
+		default:
+			return fmt.Errorf("unknown filter operator %q on metadata field %s (use eq, in, gt, gte, lt or lte)", operator, field.Name)
+		}
+	}
+	if len(filter.Values) > 0 && (filter.Min != nil || filter.Max != nil) {
+		return fmt.Errorf("metadata field %s cannot be filtered by values and by range at once", field.Name)
+	}
+	return nil
+}
+
+// filterValues checks that the filter values match the type of the field
+func filterValues(field MetadataField, items []any) ([]string, error) {
+	if len(items) == 0 {
+		return nil, fmt.Errorf("empty list of values for metadata field %s", field.Name)
+	}
+	values := make([]string, 0, len(items))
+	for _, item := range items {
+		if field.Type == MetadataFieldNumeric {
+			number, ok := item.(float64)
+			if !ok {
+				return nil, fmt.Errorf("metadata field %s is numeric, %v is not a number", field.Name, item)
+			}
+			values = append(values, strconv.FormatFloat(number, 'f', -1, 64))
+			continue
+		}
+		value, ok := tagValue(item)
+		if !ok {
+			return nil, fmt.Errorf("invalid value %v for metadata field %s", item, field.Name)
+		}
+		values = append(values, value)
+	}
+	return values, nil
+}
+
+// buildMetadataFilterQuery builds the RediSearch expression of a metadata filter
+func buildMetadataFilterQuery(filter MetadataFilter) string {
+	attribute := "@" + metadataFieldPrefix + filter.Field
+	field, _ := lookupMetadataField(filter.Field)
+
+	if field.Type != MetadataFieldNumeric {
+		escaped := make([]string, len(filter.Values))
+		for i, value := range filter.Values {
+			escaped[i] = escapeTagValue(value)
+		}
+		return attribute + ":{" + strings.Join(escaped, " | ") + "}"
+	}
+
+	if len(filter.Values) > 0 {
+		ranges := make([]string, len(filter.Values))
+		for i, value := range filter.Values {
+			ranges[i] = fmt.Sprintf("%s:[%s %s]", attribute, value, value)
+		}
+		if len(ranges) == 1 {
+			return ranges[0]
+		}
+		return "(" + strings.Join(ranges, " | ") + ")"
+	}
+
+	return fmt.Sprintf("%s:[%s %s]", attribute, rangeBound(filter.Min, filter.MinExclusive, "-inf"), rangeBound(filter.Max, filter.MaxExclusive, "+inf"))
+}
+
+// rangeBound formats a bound of a numeric range ("(" marks an exclusive bound)
+func rangeBound(bound *float64, exclusive bool, unbounded string) string {
+	if bound == nil {
+		return unbounded
+	}
+	value := strconv.FormatFloat(*bound, 'f', -1, 64)
+	if exclusive {
+		return "(" + value
+	}
+	return value
+}
+
+// escapeTagValue escapes the punctuation and the spaces of a tag value. The bytes of an invalid UTF-8 sequence are
+// kept as they are, so that the value still matches the stored one.
+func escapeTagValue(value string) string {
+	var builder strings.Builder
+	for i, r := range value {
+		if !(r == '_' || r >= '0' && r <= '9' || r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r > 127) {
+			builder.WriteRune('\\')
+		}
+		if r == utf8.RuneError {
+			_, size := utf8.DecodeRuneInString(value[i:])
+			builder.WriteString(value[i : i+size])
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+// ValidateSearchFields checks the search fields of a keyword search: SearchFieldContent, SearchFieldMetadata or
+// the name of a metadata field of type text
+func ValidateSearchFields(fields []string) error {
+	for _, name := range fields {
+		if name == SearchFieldContent || name == SearchFieldMetadata {
+			continue
+		}
+		if field, ok := lookupMetadataField(name); !ok || field.Type != MetadataFieldText {
+			return fmt.Errorf("unknown search field %q (use %s, %s or a metadata field of type %s)", name, SearchFieldContent, SearchFieldMetadata, MetadataFieldText)
+		}
+	}
+	return nil
+}
+
+// searchFieldAttributes returns the index attributes of the search fields, "content|metadata|meta_title"
+// (the content without search fields)
+func searchFieldAttributes(fields []string) string {
+	if len(fields) == 0 {
+		return SearchFieldContent
+	}
+	attributes := make([]string, 0, len(fields))
+	seen := map[string]bool{}
+	for _, name := range fields {
+		attribute := name
+		if name != SearchFieldContent && name != SearchFieldMetadata {
+			attribute = metadataFieldPrefix + name
+		}
+		if !seen[attribute] {
+			seen[attribute] = true
+			attributes = append(attributes, attribute)
+		}
+	}
+	return strings.Join(attributes, "|")
+}
+
+// searchesContent reports whether the search fields include the content (the default)
+func searchesContent(fields []string) bool {
+	return len(fields) == 0 || slices.Contains(fields, SearchFieldContent)
+}
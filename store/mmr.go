@@ -0,0 +1,90 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"slices"
+	"vectormind/splitter"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DiversityCandidatesFactor is the number of candidates retrieved by result to return, before the diversification
+const DiversityCandidatesFactor = 4
+
+// maxDiversityCandidates bounds the number of candidates of a diversification
+const maxDiversityCandidates = 100
+
+// ValidateDiversity checks the diversity of a search (0: no diversification, 1: only the diversity counts)
+func ValidateDiversity(diversity float64) error {
+	if diversity < 0 || diversity > 1 {
+		return fmt.Errorf("diversity must be between 0 and 1")
+	}
+	return nil
+}
+
+// DiversityCandidates returns the number of candidates to retrieve for a diversified search returning n results
+func DiversityCandidates(n int) int {
+	return max(n, min(n*DiversityCandidatesFactor, maxDiversityCandidates))
+}
+
+// SelectDiverse returns the indexes of the n vectors selected by maximal marginal relevance (MMR), in their order of
+// selection: each step selects the vector maximizing (1 - diversity) * similarity to the query
+// - diversity * highest similarity to the vectors already selected (cosine similarities).
+// With a diversity of 0, the vectors are selected by similarity to the query.
+func SelectDiverse(queryVector []float32, vectors [][]float32, n int, diversity float64) []int {
+	query := make([]float64, len(queryVector))
+	for i, value := range queryVector {
+		query[i] = float64(value)
+	}
+	relevance := make([]float64, len(vectors))
+	for i, vector := range vectors {
+		relevance[i] = splitter.CosineSimilarity(query, vector)
+	}
+
+	// redundancy is the highest similarity of each vector to the selected vectors
+	redundancy := make([]float64, len(vectors))
+	selected := make([]int, 0, min(n, len(vectors)))
+	for len(selected) < cap(selected) {
+		best, bestScore := -1, math.Inf(-1)
+		for i := range vectors {
+			if slices.Contains(selected, i) {
+				continue
+			}
+			score := relevance[i]
+			if len(selected) > 0 {
+				score = (1-diversity)*relevance[i] - diversity*redundancy[i]
+			}
+			if score > bestScore {
+				best, bestScore = i, score
+			}
+		}
+		selected = append(selected, best)
+
+		last := make([]float64, len(vectors[best]))
+		for i, value := range vectors[best] {
+			last[i] = float64(value)
+		}
+		for i, vector := range vectors {
+			if similarity := splitter.CosineSimilarity(last, vector); len(selected) == 1 || similarity > redundancy[i] {
+				redundancy[i] = similarity
+			}
+		}
+	}
+	return selected
+}
+
+// diversifyDocuments returns the n documents of a similarity search selected by SelectDiverse (the documents carry
+// their embedding, which is removed)
+func diversifyDocuments(queryVector []float32, docs []redis.Document, n int, diversity float64) []redis.Document {
+	vectors := make([][]float32, len(docs))
+	for i, doc := range docs {
+		vectors[i] = bytesToFloats([]byte(doc.Fields["embedding"]))
+		delete(doc.Fields, "embedding")
+	}
+	selected := make([]redis.Document, 0, min(n, len(docs)))
+	for _, i := range SelectDiverse(queryVector, vectors, n, diversity) {
+		selected = append(selected, docs[i])
+	}
+	return selected
+}
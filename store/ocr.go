@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"unicode/utf8"
+	"vectormind/models"
+	"vectormind/ocr"
+	"vectormind/splitter"
+)
+
+// DefaultOCRMaxImages is the default number of images of a document read by OCR
+const DefaultOCRMaxImages = 50
+
+// ocrEngine reads the text of the images of the Office documents, nil when disabled (see SetOCR)
+var (
+	ocrEngine    ocr.Engine
+	ocrMaxImages = DefaultOCRMaxImages
+)
+
+// SetOCR sets the engine reading the text of the images of the Office documents (nil disables the OCR), and the
+// number of images read by document
+func SetOCR(engine ocr.Engine, maxImages int) error {
+	if maxImages <= 0 {
+		return fmt.Errorf("invalid maximum number of OCR images %d (expected a number > 0)", maxImages)
+	}
+	ocrEngine, ocrMaxImages = engine, maxImages
+	return nil
+}
+
+// OCREnabled reports whether the text of the images of the Office documents is read
+func OCREnabled() bool {
+	return ocrEngine != nil
+}
+
+// OfficeToMarkdown converts an Office document to markdown (see splitter.OfficeToMarkdown), with the text of its
+// images read by OCR when enabled. It returns the images with their page and position: an image that cannot be read
+// (unsupported format, OCR error, more than the maximum number of images) is reported with its error, and does not
+// fail the document.
+func OfficeToMarkdown(ctx context.Context, format string, data []byte) (string, []models.OCRImage, error) {
+	if ocrEngine == nil {
+		markdown, err := splitter.OfficeToMarkdown(format, data, nil)
+		return markdown, nil, err
+	}
+
+	var images []models.OCRImage
+	read := 0
+	markdown, err := splitter.OfficeToMarkdown(format, data, func(image splitter.OfficeImage) (string, error) {
+		result := models.OCRImage{
+			Image:  image.Name,
+			Page:   image.Page,
+			X:      image.Box.X,
+			Y:      image.Box.Y,
+			Width:  image.Box.Width,
+			Height: image.Box.Height,
+		}
+		defer func() { images = append(images, result) }()
+
+		mediaType, ok := ocr.MediaType(image.Name)
+		if !ok {
+			result.Error = "unsupported image format"
+			return "", nil
+		}
+		if read >= ocrMaxImages {
+			result.Error = fmt.Sprintf("not read (%d images max)", ocrMaxImages)
+			return "", nil
+		}
+		read++
+		text, err := ocrEngine.Recognize(ctx, image.Data, mediaType)
+		if err != nil {
+			// The request is over: the next images would fail too
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			result.Error = err.Error()
+			return "", nil
+		}
+		result.Characters = utf8.RuneCountInString(text)
+		return text, nil
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return markdown, images, nil
+}
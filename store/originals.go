@@ -0,0 +1,73 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"vectormind/archive"
+)
+
+// ErrArchiveDisabled is returned when the original documents are not archived
+var ErrArchiveDisabled = errors.New("original documents archive is disabled")
+
+// originalsArchive stores the original (pre-chunk) documents, nil when disabled
+var originalsArchive archive.Store
+
+// SetArchive sets the store of the original documents (nil disables the archive)
+func SetArchive(store archive.Store) {
+	originalsArchive = store
+}
+
+// ArchiveEnabled reports whether the original documents are archived
+func ArchiveEnabled() bool {
+	return originalsArchive != nil
+}
+
+// OriginalSourceID returns the source ID of a chunked document: the provided source ID,
+// or (when the originals are archived) a hash of the original document
+func OriginalSourceID(sourceID, original string) string {
+	if sourceID != "" || originalsArchive == nil || original == "" {
+		return sourceID
+	}
+	return HashContent(original)[:16]
+}
+
+// originalKey returns the archive key of a source ID in the namespace of the tenant of an index or a key prefix
+// (see tenantNamespace): the tenants never share their originals. The source ID is escaped, so that a source ID of
+// the main index never looks like the key of a tenant.
+func originalKey(name, sourceID string) string {
+	return tenantNamespace(name) + url.QueryEscape(sourceID)
+}
+
+// archiveOriginal archives the original document of chunks stored under a key prefix and returns its reference
+// ("" when the archive is disabled).
+// The document is encrypted like the content of the chunks when the encryption is enabled (see EncryptField).
+func archiveOriginal(ctx context.Context, keyPrefix, sourceID, original string) (string, error) {
+	if originalsArchive == nil || original == "" {
+		return "", nil
+	}
+	encrypted, err := EncryptField(original)
+	if err != nil {
+		return "", fmt.Errorf("failed to encrypt the original document: %w", err)
+	}
+	return originalsArchive.Put(ctx, originalKey(keyPrefix, sourceID), []byte(encrypted))
+}
+
+// GetOriginal returns the archived original document of a source ID of an index, decrypted (the documents archived
+// before the encryption was enabled are returned as is).
+// It returns archive.ErrNotFound when the document is not archived and ErrArchiveDisabled when there is no archive.
+func GetOriginal(ctx context.Context, indexName, sourceID string) ([]byte, error) {
+	if originalsArchive == nil {
+		return nil, ErrArchiveDisabled
+	}
+	data, err := originalsArchive.Get(ctx, originalKey(indexName, sourceID))
+	if err != nil {
+		return nil, err
+	}
+	original, err := DecryptField(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("original document %s: %w", sourceID, err)
+	}
+	return []byte(original), nil
+}
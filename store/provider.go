@@ -0,0 +1,75 @@
+package store
+
+import (
+	"fmt"
+	"net/textproto"
+	"net/url"
+	"strings"
+
+	"github.com/openai/openai-go/option"
+)
+
+// ParseExtraHeaders parses a list of HTTP headers like "X-Org-Id=acme,X-Project=vectormind"
+func ParseExtraHeaders(spec string) (map[string]string, error) {
+	headers := map[string]string{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, value, found := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" || strings.ContainsAny(name, " \t:") {
+			return nil, fmt.Errorf("invalid header %q (expected Name=value)", entry)
+		}
+		name = textproto.CanonicalMIMEHeaderKey(name)
+		if _, exists := headers[name]; exists {
+			return nil, fmt.Errorf("duplicate header %s", name)
+		}
+		headers[name] = strings.TrimSpace(value)
+	}
+	return headers, nil
+}
+
+// ProviderOptions returns the client options of an OpenAI compatible provider: its base URL, its API key
+// (sent as a bearer token, empty for the local model runners) and extra headers (e.g. the organization of a hosted provider)
+func ProviderOptions(baseURL, apiKey string, headers map[string]string) []option.RequestOption {
+	options := []option.RequestOption{
+		option.WithBaseURL(baseURL),
+		option.WithAPIKey(apiKey),
+	}
+	for name, value := range headers {
+		options = append(options, option.WithHeader(name, value))
+	}
+	return options
+}
+
+// DefaultAzureAPIVersion is the Azure OpenAI REST API version used when none is configured
+const DefaultAzureAPIVersion = "2024-10-21"
+
+// AzureProviderOptions returns the client options of an Azure OpenAI resource: the requests are sent to the deployment
+// of the model on the resource endpoint (e.g. https://my-resource.openai.azure.com), with the api-version query parameter
+// and the API key in the api-key header
+func AzureProviderOptions(endpoint, deployment, apiVersion, apiKey string, headers map[string]string) ([]option.RequestOption, error) {
+	if endpoint == "" {
+		return nil, fmt.Errorf("the Azure OpenAI endpoint is required")
+	}
+	if deployment == "" {
+		return nil, fmt.Errorf("the Azure OpenAI deployment is required")
+	}
+	if apiVersion == "" {
+		apiVersion = DefaultAzureAPIVersion
+	}
+
+	baseURL := strings.TrimSuffix(endpoint, "/") + "/openai/deployments/" + url.PathEscape(deployment) + "/"
+	options := []option.RequestOption{
+		option.WithBaseURL(baseURL),
+		option.WithQuery("api-version", apiVersion),
+		option.WithHeaderDel("Authorization"),
+		option.WithHeader("Api-Key", apiKey),
+	}
+	for name, value := range headers {
+		options = append(options, option.WithHeader(name, value))
+	}
+	return options, nil
+}
@@ -0,0 +1,182 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"vectormind/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Quota kinds of the warnings
+const (
+	QuotaDocuments      = "documents"
+	QuotaLabelDocuments = "label_documents"
+	QuotaMemory         = "memory"
+)
+
+// DefaultQuotaWarningRatio is the share of a quota from which the writes return warnings
+const DefaultQuotaWarningRatio = 0.8
+
+// Quotas are the soft quotas of the stored documents: the writes approaching them return warnings (see
+// QuotaWarnings), so that the agents consolidate their memory before the hard limits (the memory watermark) refuse
+// their writes. The quotas themselves never refuse a write.
+type Quotas struct {
+	// MaxDocuments is the number of documents of an index: the main index of a tenant, or a collection (0: no quota)
+	MaxDocuments int
+	// MaxLabelDocuments is the number of documents of an index having a label (0: no quota)
+	MaxLabelDocuments int
+	// WarningRatio is the share of a quota, or of the memory watermark, from which the writes return warnings
+	WarningRatio float64
+}
+
+var (
+	quotasMutex sync.RWMutex
+	quotas      = Quotas{WarningRatio: DefaultQuotaWarningRatio}
+)
+
+// SetQuotas sets the soft quotas (QUOTA_MAX_DOCUMENTS, QUOTA_MAX_LABEL_DOCUMENTS and QUOTA_WARNING_RATIO)
+func SetQuotas(q Quotas) error {
+	if q.MaxDocuments < 0 || q.MaxLabelDocuments < 0 {
+		return fmt.Errorf("the quotas cannot be negative (use 0 for no quota)")
+	}
+	if q.WarningRatio <= 0 || q.WarningRatio > 1 {
+		return fmt.Errorf("the quota warning ratio must be greater than 0 and at most 1")
+	}
+	quotasMutex.Lock()
+	defer quotasMutex.Unlock()
+	quotas = q
+	return nil
+}
+
+// GetQuotas returns the soft quotas
+func GetQuotas() Quotas {
+	quotasMutex.RLock()
+	defer quotasMutex.RUnlock()
+	return quotas
+}
+
+// writeTracker records the collections and the labels of the documents written with a context (see TrackWrites)
+type writeTracker struct {
+	mutex       sync.Mutex
+	collections map[string]bool // names of the collections ("" for the main index)
+	labels      map[string]bool
+}
+
+type writeTrackerKey struct{}
+
+// TrackWrites returns a context recording the collections and the labels of the documents written with it, whose
+// quotas are checked by QuotaWarnings
+func TrackWrites(ctx context.Context) context.Context {
+	return context.WithValue(ctx, writeTrackerKey{}, &writeTracker{collections: map[string]bool{}, labels: map[string]bool{}})
+}
+
+// InheritWriteTracking returns ctx recording its writes with the tracker of parent, if any (e.g. the context of a
+// request, while ctx is the context of the server)
+func InheritWriteTracking(ctx, parent context.Context) context.Context {
+	if tracker, ok := parent.Value(writeTrackerKey{}).(*writeTracker); ok {
+		return context.WithValue(ctx, writeTrackerKey{}, tracker)
+	}
+	return ctx
+}
+
+// recordWrite records the collection and the labels of a document written with a tracking context
+func recordWrite(ctx context.Context, doc Document) {
+	tracker, ok := ctx.Value(writeTrackerKey{}).(*writeTracker)
+	if !ok {
+		return
+	}
+	tracker.mutex.Lock()
+	defer tracker.mutex.Unlock()
+	tracker.collections[documentCollectionName(doc.ID)] = true
+	for _, label := range SplitLabels(doc.Label) {
+		tracker.labels[strings.ToLower(label)] = true
+	}
+}
+
+// QuotaWarnings returns the warnings of the quotas approached by the documents written with a tracking context (see
+// TrackWrites): the number of documents of their indexes and of their labels, and the memory of Redis against the
+// watermark of the memory guard. It returns no warning when nothing was written; the quotas that cannot be read are
+// skipped.
+func QuotaWarnings(ctx context.Context, redisClient *redis.Client, indexName string, memoryGuard *MemoryGuard) []models.QuotaWarning {
+	tracker, ok := ctx.Value(writeTrackerKey{}).(*writeTracker)
+	if !ok {
+		return nil
+	}
+	tracker.mutex.Lock()
+	collections := slices.Sorted(maps.Keys(tracker.collections))
+	labels := slices.Sorted(maps.Keys(tracker.labels))
+	tracker.mutex.Unlock()
+	if len(collections) == 0 {
+		return nil
+	}
+
+	q := GetQuotas()
+	warnings := []models.QuotaWarning{}
+	for _, name := range collections {
+		collection := DefaultCollection(indexName)
+		if name != "" {
+			collection = namedCollection(indexName, name)
+		}
+		if q.MaxDocuments > 0 {
+			if info, err := GetIndexInfo(ctx, redisClient, collection.IndexName); err == nil {
+				if warning, ok := quotaWarning(QuotaDocuments, name, "", int64(info.NumDocs), int64(q.MaxDocuments), q.WarningRatio); ok {
+					warnings = append(warnings, warning)
+				}
+			}
+		}
+		if q.MaxLabelDocuments > 0 && len(labels) > 0 {
+			counts, err := labelDocumentCounts(ctx, redisClient, collection.IndexName, labels)
+			if err != nil {
+				continue
+			}
+			for i, label := range labels {
+				if warning, ok := quotaWarning(QuotaLabelDocuments, name, label, int64(counts[i]), int64(q.MaxLabelDocuments), q.WarningRatio); ok {
+					warnings = append(warnings, warning)
+				}
+			}
+		}
+	}
+
+	if memoryGuard.Enabled() {
+		if memoryInfo, err := GetMemoryInfo(ctx, memoryGuard.redisClient); err == nil {
+			limit := memoryGuard.Watermark(memoryInfo.MaxMemory)
+			if warning, ok := quotaWarning(QuotaMemory, "", "", memoryInfo.UsedMemory, limit, q.WarningRatio); ok {
+				warnings = append(warnings, warning)
+			}
+		}
+	}
+	return warnings
+}
+
+// quotaWarning returns the warning of a quota used from the warning ratio (a quota of 0 has no warning)
+func quotaWarning(quota, collection, label string, used, limit int64, ratio float64) (models.QuotaWarning, bool) {
+	if limit <= 0 || float64(used) < ratio*float64(limit) {
+		return models.QuotaWarning{}, false
+	}
+	percent := used * 100 / limit
+	var message string
+	switch quota {
+	case QuotaDocuments:
+		message = fmt.Sprintf("The index stores %d documents, %d%% of the quota of %d: consolidate or delete documents", used, percent, limit)
+	case QuotaLabelDocuments:
+		message = fmt.Sprintf("The label %q has %d documents, %d%% of the quota of %d: consolidate or delete documents", label, used, percent, limit)
+	case QuotaMemory:
+		message = fmt.Sprintf("Redis uses %d bytes, %d%% of the write watermark of %d bytes: the writes are refused above it", used, percent, limit)
+	}
+	if collection != "" {
+		message = fmt.Sprintf("Collection %s: %s", collection, message)
+	}
+	return models.QuotaWarning{
+		Quota:      quota,
+		Collection: collection,
+		Label:      label,
+		Used:       used,
+		Limit:      limit,
+		Message:    message,
+	}, true
+}
@@ -0,0 +1,69 @@
+package store
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"vectormind/models"
+)
+
+// DefaultRecencyWeight is the default share of the freshness in the recency score of a search result
+const DefaultRecencyWeight = 0.3
+
+// RecencyCandidatesFactor is the number of candidates retrieved by result to return, before the recency ranking
+const RecencyCandidatesFactor = 4
+
+// maxRecencyCandidates bounds the number of candidates of a recency ranking
+const maxRecencyCandidates = 100
+
+// ValidateRecency checks the half-life (in hours, 0: no recency ranking) and the weight (0: the default weight, up
+// to 1: only the freshness counts) of a recency ranking
+func ValidateRecency(halfLifeHours, weight float64) error {
+	if halfLifeHours < 0 {
+		return fmt.Errorf("recency_half_life_hours cannot be negative")
+	}
+	if weight < 0 || weight > 1 {
+		return fmt.Errorf("recency_weight must be between 0 and 1")
+	}
+	return nil
+}
+
+// RecencyCandidates returns the number of candidates to retrieve for a search ranked by recency returning n results
+func RecencyCandidates(n int) int {
+	return max(n, min(n*RecencyCandidatesFactor, maxRecencyCandidates))
+}
+
+// RecencyHalfLife converts a half-life in hours to a duration
+func RecencyHalfLife(hours float64) time.Duration {
+	return time.Duration(hours * float64(time.Hour))
+}
+
+// RankByRecency returns the n best search results by recency score, which combines the similarity to the query
+// (1 - distance) with the freshness of the document (0.5 ^ (age / half-life), 1 when it has just been created):
+// (1 - weight) × similarity + weight × freshness. The results carry their recency_score. A weight of 0 is the default
+// weight.
+func RankByRecency(results []models.SimilaritySearchResult, halfLife time.Duration, weight float64, now time.Time, n int) []models.SimilaritySearchResult {
+	if weight == 0 {
+		weight = DefaultRecencyWeight
+	}
+	for i := range results {
+		score := (1-weight)*(1-results[i].Distance) + weight*Freshness(results[i].CreatedAt, halfLife, now)
+		results[i].RecencyScore = &score
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		return *results[i].RecencyScore > *results[j].RecencyScore
+	})
+	return results[:min(n, len(results))]
+}
+
+// Freshness returns the decay of a document created at createdAt (RFC 3339) after its age: 1 when it has just been
+// created, 0.5 after a half-life, 0.25 after two. A document without creation date has a freshness of 0.
+func Freshness(createdAt string, halfLife time.Duration, now time.Time) float64 {
+	created, err := time.Parse(time.RFC3339, createdAt)
+	if err != nil || created.Unix() <= 0 || halfLife <= 0 {
+		return 0
+	}
+	age := max(now.Sub(created), 0)
+	return math.Pow(0.5, float64(age)/float64(halfLife))
+}
@@ -3,21 +3,34 @@ package store
 import (
 	"context"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
+	"strconv"
+	"strings"
 	"time"
+	"vectormind/splitter"
 
 	"github.com/redis/go-redis/v9"
 )
 
-// CreateRedisClient creates a new Redis client
+// CreateRedisClient creates a new Redis client on the default logical database (0)
 func CreateRedisClient(redisAddress, redisPassword string) *redis.Client {
+	return CreateRedisClientWithDB(redisAddress, redisPassword, 0)
+}
+
+// CreateRedisClientWithDB creates a new Redis client on the given logical database
+func CreateRedisClientWithDB(redisAddress, redisPassword string, db int) *redis.Client {
 	client := redis.NewClient(&redis.Options{
 		Addr:     redisAddress,
 		Password: redisPassword,
-		DB:       0, // use default DB
+		DB:       db,
 		Protocol: 2, // specify the Redis protocol version
 	})
+	// The errors of the commands wrap ErrBackendUnavailable or ErrIndexMissing (see ErrorCode)
+	client.AddHook(errorClassificationHook{})
+	// The commands fail at the Redis error rate of the chaos mode (see SetChaos)
+	client.AddHook(chaosHook{})
 
 	return client
 }
@@ -32,10 +45,7 @@ func IndexExists(ctx context.Context, redisClient *redis.Client, indexName strin
 	_, err := redisClient.FTInfo(ctx, indexName).Result()
 	if err != nil {
 		// Check if error indicates index doesn't exist
-		errMsg := err.Error()
-		if errMsg == "Unknown index name" ||
-			redis.HasErrorPrefix(err, "vectormind_index: no such index") ||
-			redis.HasErrorPrefix(err, indexName+": no such index") {
+		if isUnknownIndexError(err, indexName) {
 			return false, nil
 		}
 		return false, err
@@ -43,44 +53,156 @@ func IndexExists(ctx context.Context, redisClient *redis.Client, indexName strin
 	return true, nil
 }
 
-// CreateEmbeddingIndex creates a new Redis search index for embeddings
+// Vector index types
+const (
+	// IndexTypeHNSW is an approximate nearest neighbors index, fast on large datasets (default)
+	IndexTypeHNSW = "HNSW"
+	// IndexTypeFlat is a brute force index, exact and memory efficient on small datasets
+	IndexTypeFlat = "FLAT"
+)
+
+// IndexOptions holds the settings of the vector index.
+// Zero HNSW parameters keep the Redis defaults (M 16, EF_CONSTRUCTION 200, EF_RUNTIME 10).
+type IndexOptions struct {
+	Type           string // IndexTypeHNSW (default) or IndexTypeFlat
+	M              int    // maximum number of edges per node of the HNSW graph
+	EFConstruction int    // number of candidates examined when building the HNSW graph
+	EFRuntime      int    // number of candidates examined by an HNSW search
+	KeyPrefix      string // prefix of the indexed document keys (default: "doc:" after the prefix of the tenant of the index, see collections)
+}
+
+// ValidateIndexOptions checks the index type and its parameters
+func ValidateIndexOptions(options IndexOptions) error {
+	if options.M < 0 || options.EFConstruction < 0 || options.EFRuntime < 0 {
+		return fmt.Errorf("HNSW parameters cannot be negative")
+	}
+	switch strings.ToUpper(options.Type) {
+	case "", IndexTypeHNSW:
+		return nil
+	case IndexTypeFlat:
+		if options.M > 0 || options.EFConstruction > 0 || options.EFRuntime > 0 {
+			return fmt.Errorf("HNSW parameters (M, EF_CONSTRUCTION, EF_RUNTIME) cannot be used with a %s index", IndexTypeFlat)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown index type %q (use %s or %s)", options.Type, IndexTypeHNSW, IndexTypeFlat)
+	}
+}
+
+// CreateEmbeddingIndex creates a new Redis search index for embeddings (HNSW index with the Redis defaults)
 func CreateEmbeddingIndex(ctx context.Context, redisClient *redis.Client, indexName string, embeddingDimension int) error {
+	return CreateEmbeddingIndexWithOptions(ctx, redisClient, indexName, embeddingDimension, IndexOptions{})
+}
+
+// CreateEmbeddingIndexWithOptions creates a new Redis search index for embeddings with the given vector index settings.
+// When the content and metadata are encrypted at rest, they are not full-text indexed (vector search still works).
+// The filterable metadata fields are indexed as TAG or NUMERIC fields.
+func CreateEmbeddingIndexWithOptions(ctx context.Context, redisClient *redis.Client, indexName string, embeddingDimension int, options IndexOptions) error {
+	if err := ValidateIndexOptions(options); err != nil {
+		return err
+	}
+
+	vectorArgs := &redis.FTVectorArgs{
+		HNSWOptions: &redis.FTHNSWOptions{
+			Dim:                    embeddingDimension,
+			DistanceMetric:         "L2",
+			Type:                   "FLOAT32",
+			MaxEdgesPerNode:        options.M,              // M
+			MaxAllowedEdgesPerNode: options.EFConstruction, // EF_CONSTRUCTION
+			EFRunTime:              options.EFRuntime,
+		},
+	}
+	if strings.ToUpper(options.Type) == IndexTypeFlat {
+		vectorArgs = &redis.FTVectorArgs{
+			FlatOptions: &redis.FTFlatOptions{
+				Dim:            embeddingDimension,
+				DistanceMetric: "L2",
+				Type:           "FLOAT32",
+			},
+		}
+	}
+
+	schema := append(documentFieldSchemas(), &redis.FieldSchema{
+		FieldName:  vectorField,
+		As:         schemaAlias(vectorField, "embedding"),
+		FieldType:  redis.SearchFieldTypeVector,
+		VectorArgs: vectorArgs,
+	})
+
+	keyPrefix := options.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = DefaultCollection(indexName).KeyPrefix
+	}
+
 	_, err := redisClient.FTCreate(ctx,
 		indexName,
 		&redis.FTCreateOptions{
 			OnHash: true,
-			Prefix: []any{"doc:"},
+			Prefix: []any{keyPrefix},
 		},
-		&redis.FieldSchema{
-			FieldName: "content",
+		schema...,
+	).Result()
+
+	return err
+}
+
+// documentFieldSchemas returns the fields of the index schema, but the vector field: the content and metadata
+// (not full-text indexed when they are encrypted at rest), the section fields, the filters and the provenance
+func documentFieldSchemas() []*redis.FieldSchema {
+	schema := []*redis.FieldSchema{
+		{
+			FieldName: contentField,
+			As:        schemaAlias(contentField, "content"),
 			FieldType: redis.SearchFieldTypeText,
+			NoIndex:   EncryptionEnabled(),
 		},
-		&redis.FieldSchema{
+		{
+			FieldName: titleField,
+			FieldType: redis.SearchFieldTypeText,
+			NoIndex:   EncryptionEnabled(),
+		},
+		{
+			FieldName: hierarchyField,
+			FieldType: redis.SearchFieldTypeText,
+			NoIndex:   EncryptionEnabled(),
+		},
+		{
 			FieldName: "label",
 			FieldType: redis.SearchFieldTypeTag,
 		},
-		&redis.FieldSchema{
+		{
 			FieldName: "metadata",
 			FieldType: redis.SearchFieldTypeText,
+			NoIndex:   EncryptionEnabled(),
 		},
-		&redis.FieldSchema{
+		{
 			FieldName: "created_at",
 			FieldType: redis.SearchFieldTypeNumeric,
 		},
-		&redis.FieldSchema{
-			FieldName: "embedding",
-			FieldType: redis.SearchFieldTypeVector,
-			VectorArgs: &redis.FTVectorArgs{
-				HNSWOptions: &redis.FTHNSWOptions{
-					Dim:            embeddingDimension,
-					DistanceMetric: "L2",
-					Type:           "FLOAT32",
-				},
-			},
+		{
+			FieldName: "quality",
+			FieldType: redis.SearchFieldTypeNumeric,
+			Sortable:  true,
 		},
-	).Result()
-
-	return err
+		{
+			FieldName: "content_hash",
+			FieldType: redis.SearchFieldTypeTag,
+		},
+		{
+			FieldName: "parent_id",
+			FieldType: redis.SearchFieldTypeTag,
+		},
+		{
+			FieldName: "chunk_index",
+			FieldType: redis.SearchFieldTypeNumeric,
+			Sortable:  true,
+		},
+		{
+			FieldName: versionStateField,
+			FieldType: redis.SearchFieldTypeTag,
+		},
+	}
+	return append(schema, metadataFieldSchemas()...)
 }
 
 // DropIndex drops a Redis search index
@@ -93,57 +215,166 @@ func DropIndex(ctx context.Context, redisClient *redis.Client, indexName string)
 	)
 }
 
+// SearchOptions holds the optional filters applied to a similarity search
+type SearchOptions struct {
+	Label  string   // only return documents with this label
+	Labels []string // only return documents with any of these labels (all of them with MatchAllLabels)
+	// MatchAllLabels only returns the documents having all the Labels
+	MatchAllLabels bool
+	MinQuality     *float64 // only return documents with a quality score >= MinQuality
+	// ParentID only returns the chunks split from this document (see Document)
+	ParentID string
+	// MaxDistance only returns documents with a vector distance <= MaxDistance, with a vector range query
+	// (instead of the KNN query, the results are not limited to the nearest neighbors)
+	MaxDistance *float64
+	Filters     []MetadataFilter // only return documents whose metadata matches all the filters
+	// SearchFields are the fields matched by the keyword searches (see ValidateSearchFields, default: the content)
+	SearchFields []string
+	// FieldWeights are the weights of the section fields in the keyword searches (nil: the configured weights)
+	FieldWeights *FieldWeights
+	// Diversity diversifies the results of the text searches by maximal marginal relevance, from 0 (no
+	// diversification) to 1 (see SelectDiverse)
+	Diversity float64
+	// QueryVariants are paraphrases of the query of the text searches (see ExpandQuery), searched with the query
+	// and fused by reciprocal rank fusion
+	QueryVariants []string
+}
+
+// searchReturnFields lists the fields returned by the search queries (the content field of the schema is returned as
+// content, see SetSchema)
+func searchReturnFields() []redis.FTSearchReturn {
+	return []redis.FTSearchReturn{
+		{FieldName: "vector_distance"},
+		returnField(contentField, "content"),
+		{FieldName: "label"},
+		{FieldName: "metadata"},
+		{FieldName: "created_at"},
+		{FieldName: "quality"},
+		{FieldName: "parent_id"},
+		{FieldName: "chunk_index"},
+		{FieldName: "start_offset"},
+		{FieldName: "end_offset"},
+	}
+}
+
+// buildFilterQuery builds the RediSearch pre-filter expression matching the search options (and the current versions
+// of the documents, with the document versioning)
+func buildFilterQuery(options SearchOptions) string {
+	filters := filterExpressions(options)
+	if versioning {
+		filters = append(filters, inactiveVersionsFilter)
+	}
+
+	if len(filters) == 0 {
+		return "*"
+	}
+	return "(" + strings.Join(filters, " ") + ")"
+}
+
+// filterExpressions returns the filter expressions of the search options (none: all the documents)
+func filterExpressions(options SearchOptions) []string {
+	filters := []string{}
+	if options.Label != "" {
+		filters = append(filters, fmt.Sprintf("@label:{%s}", escapeTagValue(options.Label)))
+	}
+	if labels := buildLabelsFilterQuery(options.Labels, options.MatchAllLabels); labels != "" {
+		filters = append(filters, labels)
+	}
+	if options.ParentID != "" {
+		filters = append(filters, fmt.Sprintf("@parent_id:{%s}", escapeTagValue(options.ParentID)))
+	}
+	if options.MinQuality != nil {
+		filters = append(filters, fmt.Sprintf("@quality:[%s +inf]", strconv.FormatFloat(*options.MinQuality, 'f', -1, 64)))
+	}
+	for _, filter := range options.Filters {
+		filters = append(filters, buildMetadataFilterQuery(filter))
+	}
+	return filters
+}
+
 // SimilaritySearch performs a vector similarity search
 func SimilaritySearch(ctx context.Context, redisClient *redis.Client, indexName string, queryVector []float32, numberOfTopSimilarities int) ([]redis.Document, error) {
-	buffer := floatsToBytes(queryVector) // embedding vector as byte array
+	return SimilaritySearchWithOptions(ctx, redisClient, indexName, queryVector, numberOfTopSimilarities, SearchOptions{})
+}
 
-	query := fmt.Sprintf("*=>[KNN %d @embedding $vec AS vector_distance]", numberOfTopSimilarities)
+// SimilaritySearchWithLabel performs a vector similarity search filtered by label
+func SimilaritySearchWithLabel(ctx context.Context, redisClient *redis.Client, indexName string, queryVector []float32, numberOfTopSimilarities int, label string) ([]redis.Document, error) {
+	return SimilaritySearchWithOptions(ctx, redisClient, indexName, queryVector, numberOfTopSimilarities, SearchOptions{Label: label})
+}
 
-	results, err := redisClient.FTSearchWithArgs(ctx,
-		indexName,
-		query,
-		&redis.FTSearchOptions{
-			Return: []redis.FTSearchReturn{
-				{FieldName: "vector_distance"},
-				{FieldName: "content"},
-				{FieldName: "label"},
-				{FieldName: "metadata"},
-				{FieldName: "created_at"},
-			},
-			DialectVersion: 2,
-			Params: map[string]any{
-				"vec": buffer,
-			},
+// SimilaritySearchWithOptions performs a vector similarity search restricted to the documents matching the options
+func SimilaritySearchWithOptions(ctx context.Context, redisClient *redis.Client, indexName string, queryVector []float32, numberOfTopSimilarities int, options SearchOptions) ([]redis.Document, error) {
+	buffer := floatsToBytes(queryVector) // embedding vector as byte array
+
+	searchOptions := &redis.FTSearchOptions{
+		Return:         searchReturnFields(),
+		DialectVersion: 2,
+		Params: map[string]any{
+			"vec": buffer,
 		},
-	).Result()
+	}
+	if options.Diversity > 0 {
+		// The embeddings of the candidates are compared to each other
+		searchOptions.Return = append(searchReturnFields(), returnField(vectorField, "embedding"))
+	}
+
+	var query string
+	if options.MaxDistance != nil {
+		// Vector range query: all the documents within the distance, closest first
+		query = buildRangeQuery(options)
+		searchOptions.Params["radius"] = *options.MaxDistance
+		searchOptions.SortBy = []redis.FTSearchSortBy{{FieldName: "vector_distance", Asc: true}}
+		searchOptions.LimitOffset = 0
+		searchOptions.Limit = numberOfTopSimilarities
+	} else {
+		query = fmt.Sprintf("%s=>[KNN %d @embedding $vec AS vector_distance]", buildFilterQuery(options), numberOfTopSimilarities)
+	}
+
+	results, err := redisClient.FTSearchWithArgs(ctx, indexName, query, searchOptions).Result()
 	if err != nil {
-		return nil, err
+		// A query vector of another dimension is a model change, not a corrupted index
+		if isQueryDimensionError(err) {
+			return nil, queryDimensionError(ctx, redisClient, indexName, len(queryVector), err)
+		}
+		return nil, checkSearchError(ctx, redisClient, indexName, err)
 	}
 
 	return results.Docs, nil
 }
 
-// SimilaritySearchWithLabel performs a vector similarity search filtered by label
-func SimilaritySearchWithLabel(ctx context.Context, redisClient *redis.Client, indexName string, queryVector []float32, numberOfTopSimilarities int, label string) ([]redis.Document, error) {
-	buffer := floatsToBytes(queryVector) // embedding vector as byte array
+// buildRangeQuery builds the vector range query matching the search options (the radius is the $radius parameter)
+func buildRangeQuery(options SearchOptions) string {
+	rangeQuery := "@embedding:[VECTOR_RANGE $radius $vec]=>{$YIELD_DISTANCE_AS: vector_distance}"
+	if filter := buildFilterQuery(options); filter != "*" {
+		return filter + " " + rangeQuery
+	}
+	return rangeQuery
+}
 
-	query := fmt.Sprintf("@label:{%s}=>[KNN %d @embedding $vec AS vector_distance]", label, numberOfTopSimilarities)
+// LowestQualityDocuments returns the documents with the lowest quality scores (worst first)
+func LowestQualityDocuments(ctx context.Context, redisClient *redis.Client, indexName string, limit int, maxQuality *float64) ([]redis.Document, error) {
+	query := "@quality:[-inf +inf]"
+	if maxQuality != nil {
+		query = fmt.Sprintf("@quality:[-inf %s]", strconv.FormatFloat(*maxQuality, 'f', -1, 64))
+	}
 
 	results, err := redisClient.FTSearchWithArgs(ctx,
 		indexName,
-		query,
+		activeVersionsQuery(query),
 		&redis.FTSearchOptions{
 			Return: []redis.FTSearchReturn{
-				{FieldName: "vector_distance"},
-				{FieldName: "content"},
+				returnField(contentField, "content"),
 				{FieldName: "label"},
 				{FieldName: "metadata"},
 				{FieldName: "created_at"},
+				{FieldName: "quality"},
 			},
-			DialectVersion: 2,
-			Params: map[string]any{
-				"vec": buffer,
+			SortBy: []redis.FTSearchSortBy{
+				{FieldName: "quality", Asc: true},
 			},
+			LimitOffset:    0,
+			Limit:          limit,
+			DialectVersion: 2,
 		},
 	).Result()
 	if err != nil {
@@ -153,23 +384,273 @@ func SimilaritySearchWithLabel(ctx context.Context, redisClient *redis.Client, i
 	return results.Docs, nil
 }
 
+// Document represents a document (or chunk) stored in Redis
+type Document struct {
+	ID        string
+	Content   string
+	Embedding []float32
+	Label     string
+	Metadata  string
+	Quality   float64
+	// SourceID and OriginalRef identify the original document of a chunk (optional)
+	SourceID    string
+	OriginalRef string
+	// ParentID is shared by the chunks split from the same document, ChunkIndex is the position of the chunk
+	// among them and StartOffset and EndOffset its position in the document, in characters (-1 when unknown)
+	ParentID    string
+	ChunkIndex  int
+	StartOffset int
+	EndOffset   int
+	// TTL is the time after which Redis deletes the document (0 means no expiration)
+	TTL time.Duration
+}
+
 // StoreEmbedding stores an embedding in Redis
 func StoreEmbedding(ctx context.Context, redisClient *redis.Client, docID string, content string, embedding []float32, label string, metadata string) error {
-	buffer := floatsToBytes(embedding) // embedding vector as byte array
-	_, err := redisClient.HSet(ctx,
-		docID,
-		map[string]any{
-			"content":    content,
-			"label":      label,
-			"metadata":   metadata,
-			"created_at": time.Now().Unix(),
-			"embedding":  buffer,
-		},
-	).Result()
+	return StoreEmbeddingWithTTL(ctx, redisClient, docID, content, embedding, label, metadata, 0)
+}
+
+// StoreEmbeddingWithTTL stores an embedding in Redis, deleted by Redis after the TTL (0 means no expiration)
+func StoreEmbeddingWithTTL(ctx context.Context, redisClient *redis.Client, docID string, content string, embedding []float32, label string, metadata string, ttl time.Duration) error {
+	return StoreDocument(ctx, redisClient, NewDocument(docID, content, embedding, label, metadata, ttl))
+}
+
+// NewDocument returns a document scored with the quality of its content
+func NewDocument(docID string, content string, embedding []float32, label string, metadata string, ttl time.Duration) Document {
+	return Document{
+		ID:        docID,
+		Content:   content,
+		Embedding: embedding,
+		Label:     label,
+		Metadata:  metadata,
+		Quality:   splitter.ScoreChunk(content).Score,
+		TTL:       ttl,
+	}
+}
+
+// StoreDocument stores a document and its embedding in Redis.
+// The expiration of the document is set from its TTL (a document stored again without TTL no longer expires).
+func StoreDocument(ctx context.Context, redisClient *redis.Client, doc Document) error {
+	fields, err := documentFields(doc)
+	if err != nil {
+		return err
+	}
+	_, err = redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		writeDocument(ctx, pipe, doc, fields)
+		return nil
+	})
+
+	return err
+}
+
+// StoreEmbeddingsPipelined stores documents and their embeddings in Redis with a single round trip (a pipeline,
+// not a transaction: each document is stored on its own). It returns the error of each document,
+// in the same order as the documents (nil when the document is stored).
+func StoreEmbeddingsPipelined(ctx context.Context, redisClient *redis.Client, docs []Document) []error {
+	errs := make([]error, len(docs))
+	cmds := make([][]redis.Cmder, len(docs))
+	pipe := redisClient.Pipeline()
+	for i, doc := range docs {
+		fields, err := documentFields(doc)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		cmds[i] = writeDocument(ctx, pipe, doc, fields)
+	}
+	if pipe.Len() == 0 {
+		return errs
+	}
+
+	// The errors are read from the commands of each document (Exec only returns the first one)
+	_, execErr := pipe.Exec(ctx)
+	sent := execErr == nil
+	for i, docCmds := range cmds {
+		for _, cmd := range docCmds {
+			if err := cmd.Err(); err != nil {
+				errs[i] = err
+				sent = true
+				break
+			}
+		}
+	}
+	if !sent {
+		// The pipeline could not be sent (e.g. no connection to Redis): its commands have no error, none was run
+		for i, docCmds := range cmds {
+			if docCmds != nil {
+				errs[i] = execErr
+			}
+		}
+	}
+	return errs
+}
+
+// StoreEmbeddingsAtomic stores documents and their embeddings in a single transaction (MULTI/EXEC): all the documents
+// are stored, or none. Redis does not roll back a transaction whose command failed, so the documents created
+// by the other commands are deleted. The documents are watched from the check of the existing IDs to the transaction,
+// so that the documents that existed before (replaced by the transaction) are never deleted.
+func StoreEmbeddingsAtomic(ctx context.Context, redisClient *redis.Client, docs []Document) error {
+	docFields := make([]map[string]any, len(docs))
+	ids := make([]string, len(docs))
+	for i, doc := range docs {
+		fields, err := documentFields(doc)
+		if err != nil {
+			return err
+		}
+		docFields[i] = fields
+		ids[i] = doc.ID
+	}
+	if len(docs) == 0 {
+		return nil
+	}
+
+	var existing []bool
+	cmds := make([][]redis.Cmder, len(docs))
+	err := redisClient.Watch(ctx, func(tx *redis.Tx) error {
+		var err error
+		if existing, err = existingDocuments(ctx, tx, ids); err != nil {
+			return err
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, doc := range docs {
+				cmds[i] = writeDocument(ctx, pipe, doc, docFields[i])
+			}
+			return nil
+		})
+		return err
+	}, ids...)
+	if err == nil {
+		return nil
+	}
 
+	// The transaction ran when its commands have a result: a transaction that could not be sent, or aborted because
+	// a document changed meanwhile, wrote nothing
+	ran := false
+	for _, docCmds := range cmds {
+		for _, cmd := range docCmds {
+			if cmd.Err() != nil && !errors.Is(cmd.Err(), redis.TxFailedErr) {
+				ran = true
+			}
+		}
+	}
+	if !ran {
+		return err
+	}
+	var created []string
+	for i, docCmds := range cmds {
+		if docCmds[0].Err() == nil && !existing[i] {
+			created = append(created, docs[i].ID)
+		}
+	}
+	if len(created) > 0 {
+		if _, _, deleteErr := purgeDocuments(context.WithoutCancel(ctx), redisClient, created); deleteErr != nil {
+			return fmt.Errorf("%w (failed to delete the documents already written: %v)", err, deleteErr)
+		}
+	}
 	return err
 }
 
+// InsertDocument stores a document under an ID chosen by the caller. An existing document is replaced
+// (all its fields are removed first) when overwrite is true, and ErrDocumentExists is returned otherwise (the ID of
+// a deleted document is free with the document versioning).
+func InsertDocument(ctx context.Context, redisClient *redis.Client, doc Document, overwrite bool) error {
+	fields, err := documentFields(doc)
+	if err != nil {
+		return err
+	}
+
+	if overwrite {
+		_, err = redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, doc.ID)
+			writeDocument(ctx, pipe, doc, fields)
+			return nil
+		})
+		return err
+	}
+
+	// The document is only created when the ID is free, even with concurrent requests
+	return redisClient.Watch(ctx, func(tx *redis.Tx) error {
+		count, err := tx.Exists(ctx, doc.ID).Result()
+		if err != nil {
+			return err
+		}
+		if count > 0 {
+			// The ID of a deleted document (document versioning) is free, its document is replaced
+			state, err := tx.HGet(ctx, doc.ID, versionStateField).Result()
+			if err != nil && !errors.Is(err, redis.Nil) {
+				return err
+			}
+			if state != versionInactive {
+				return ErrDocumentExists
+			}
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			if count > 0 {
+				pipe.Del(ctx, doc.ID)
+			}
+			writeDocument(ctx, pipe, doc, fields)
+			return nil
+		})
+		return err
+	}, doc.ID)
+}
+
+// documentFields returns the hash fields of a document (the content and metadata are encrypted when enabled)
+func documentFields(doc Document) (map[string]any, error) {
+	content, metadata, err := encryptDocumentFields(doc.Content, doc.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	buffer := floatsToBytes(doc.Embedding) // embedding vector as byte array
+	fields := map[string]any{
+		contentField:   content,
+		"label":        doc.Label,
+		"metadata":     metadata,
+		"created_at":   time.Now().Unix(),
+		"quality":      doc.Quality,
+		vectorField:    buffer,
+		"dimension":    len(doc.Embedding),
+		"content_hash": ContentDigest(doc.Content),
+	}
+	if doc.SourceID != "" {
+		fields["source_id"] = doc.SourceID
+	}
+	if doc.OriginalRef != "" {
+		fields["original_ref"] = doc.OriginalRef
+	}
+	if doc.ParentID != "" {
+		fields["parent_id"] = doc.ParentID
+		fields["chunk_index"] = doc.ChunkIndex
+		if doc.StartOffset >= 0 {
+			fields["start_offset"] = doc.StartOffset
+			fields["end_offset"] = doc.EndOffset
+		}
+	}
+	for field, value := range flattenMetadata(doc.Metadata) {
+		fields[field] = value
+	}
+	for field, value := range sectionFields(doc.Content) {
+		fields[field] = value
+	}
+	return fields, nil
+}
+
+// writeDocument queues the writes of the fields and of the expiration of a document and returns their commands.
+// The change is recorded in the change feed of the collection of the document, and for the quotas (see TrackWrites).
+func writeDocument(ctx context.Context, pipe redis.Pipeliner, doc Document, fields map[string]any) []redis.Cmder {
+	// A document stored again under the ID of a deleted document (document versioning) is searchable again
+	cmds := []redis.Cmder{pipe.HSet(ctx, doc.ID, fields), pipe.HDel(ctx, doc.ID, versionStateField)}
+	if doc.TTL > 0 {
+		cmds = append(cmds, pipe.Expire(ctx, doc.ID, doc.TTL))
+	} else {
+		cmds = append(cmds, pipe.Persist(ctx, doc.ID))
+	}
+	queueChange(ctx, pipe, ChangeStored, doc.ID)
+	recordWrite(ctx, doc)
+	return cmds
+}
+
 // floatsToBytes converts a slice of float32 to bytes
 func floatsToBytes(fs []float32) []byte {
 	buf := make([]byte, len(fs)*4)
@@ -181,3 +662,15 @@ func floatsToBytes(fs []float32) []byte {
 
 	return buf
 }
+
+// bytesToFloats converts bytes (as stored by floatsToBytes) to a slice of float32
+func bytesToFloats(buf []byte) []float32 {
+	fs := make([]float32, len(buf)/4)
+
+	for i := range fs {
+		u := binary.NativeEndian.Uint32(buf[i*4:])
+		fs[i] = math.Float32frombits(u)
+	}
+
+	return fs
+}
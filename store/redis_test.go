@@ -0,0 +1,108 @@
+package store
+
+import (
+	"strings"
+	"testing"
+)
+
+// tagValues returns the values of the tag filters of a query ("@field:{a | b}"), unescaped. Unescaped spaces are
+// only allowed around the pipes separating the values.
+func tagValues(t *testing.T, query string) []string {
+	values := []string{}
+	inTag, escaped, ended := false, false, false
+	var value strings.Builder
+	for i := 0; i < len(query); i++ {
+		r := query[i] // the special characters are ASCII, the other bytes are kept as they are
+		switch {
+		case escaped:
+			value.WriteByte(r)
+			escaped = false
+		case !inTag:
+			inTag = r == '{'
+		case r == '}' || r == '|':
+			values = append(values, value.String())
+			value.Reset()
+			inTag, ended = r == '|', false
+		case r == ' ':
+			ended = value.Len() > 0
+		case ended:
+			t.Fatalf("Unescaped space in a tag value of %q", query)
+		case r == '\\':
+			escaped = true
+		case r == '{' || r == '@':
+			t.Fatalf("Unescaped %q in a tag value of %q", r, query)
+		default:
+			value.WriteByte(r)
+		}
+	}
+	if inTag || escaped {
+		t.Fatalf("Unterminated tag filter in %q", query)
+	}
+	return values
+}
+
+func FuzzBuildFilterQuery(f *testing.F) {
+	f.Add("docs", "release-notes", "report:2024-1", "wiki")
+	f.Add("a b", "x|y", "}{", "@label:{*}")
+	f.Add("é,ü", "\\", "tab\there", "quote\"d")
+	f.Add("", "", "", "\xff\xfe")
+	f.Fuzz(func(t *testing.T, label, otherLabel, parentID, source string) {
+		options := SearchOptions{Label: label, ParentID: parentID}
+		expected := []string{}
+		if label != "" {
+			expected = append(expected, label)
+		}
+		if otherLabel != "" {
+			options.Labels = []string{otherLabel, label}
+			expected = append(expected, otherLabel)
+			if label != "" {
+				expected = append(expected, label)
+			}
+		}
+		if parentID != "" {
+			expected = append(expected, parentID)
+		}
+		if source != "" {
+			options.Filters = []MetadataFilter{{Field: "source", Values: []string{source}}}
+			expected = append(expected, source)
+		}
+
+		query := buildFilterQuery(options)
+		if len(expected) == 0 {
+			if query != "*" {
+				t.Fatalf("Expected the match-all query without filter, got %q", query)
+			}
+			return
+		}
+		values := tagValues(t, query)
+		if len(values) != len(expected) {
+			t.Fatalf("Expected the tag values %q in %q, got %q", expected, query, values)
+		}
+		for i, value := range values {
+			if value != expected[i] {
+				t.Errorf("Expected the tag value %q in %q, got %q", expected[i], query, value)
+			}
+		}
+	})
+}
+
+func TestBuildFilterQuery_Versioning(t *testing.T) {
+	defer SetVersioning(false)
+	SetVersioning(true)
+
+	// The previous versions and the deleted documents are excluded from all the searches
+	if query := buildFilterQuery(SearchOptions{}); query != "(-@version_state:{inactive})" {
+		t.Errorf("Expected the current versions only, got %q", query)
+	}
+	if query := buildFilterQuery(SearchOptions{Label: "docs"}); query != "(@label:{docs} -@version_state:{inactive})" {
+		t.Errorf("Expected the label and the current versions, got %q", query)
+	}
+	if query := activeVersionsQuery("@content_hash:{abc}"); query != "(@content_hash:{abc} -@version_state:{inactive})" {
+		t.Errorf("Unexpected query %q", query)
+	}
+
+	// The exclusion is not a filter of the bulk deletions and relabelings
+	if filters := filterExpressions(SearchOptions{}); len(filters) != 0 {
+		t.Errorf("Expected no filter expression, got %q", filters)
+	}
+}
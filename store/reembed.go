@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// reembedScanCount is the number of keys requested by each SCAN of the documents to re-embed
+const reembedScanCount = 500
+
+// reembedMaxAttempts is the number of attempts to re-embed a batch whose documents are modified meanwhile
+const reembedMaxAttempts = 3
+
+// ReembedProgress counts the documents of a re-embedding
+type ReembedProgress struct {
+	Total      int
+	Reembedded int
+	Failed     int
+	LastError  string
+}
+
+// MigrateIndex re-encodes all the documents of a collection with the embedding model.
+// When the dimension of the index does not match the embedding model, the index is first rebuilt with the new dimension
+// (the documents are kept, they are searchable again once re-embedded). progress is called after each batch.
+func MigrateIndex(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId string, collection Collection, embeddingDimension int, options IndexOptions, progress func(ReembedProgress)) (ReembedProgress, error) {
+	err := VerifyIndexDimension(ctx, redisClient, collection.IndexName, embeddingDimension)
+	if errors.Is(err, ErrDimensionMismatch) || errors.Is(err, ErrIndexNotFound) {
+		options.KeyPrefix = collection.KeyPrefix
+		err = RebuildIndex(ctx, redisClient, collection.IndexName, embeddingDimension, options)
+	}
+	if err != nil {
+		return ReembedProgress{}, err
+	}
+	return ReembedDocuments(ctx, openaiClient, redisClient, embeddingModelId, collection.KeyPrefix, progress)
+}
+
+// ReembedDocuments re-encodes the content of all the documents with the given key prefix with the embedding model,
+// in batches of the embedding batch size. A batch whose documents are updated or deleted meanwhile is read again,
+// so that a newer embedding is never overwritten. progress is called after each batch (it can be nil).
+func ReembedDocuments(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, keyPrefix string, progress func(ReembedProgress)) (ReembedProgress, error) {
+	if keyPrefix == "" {
+		keyPrefix = documentKeyPrefix
+	}
+
+	// The IDs are listed first, so that the total is known and the rewritten keys are not scanned twice
+	var ids []string
+	iter := redisClient.ScanType(ctx, 0, keyPrefix+"*", reembedScanCount, "hash").Iterator()
+	for iter.Next(ctx) {
+		ids = append(ids, iter.Val())
+	}
+	if err := iter.Err(); err != nil {
+		return ReembedProgress{}, fmt.Errorf("failed to list the documents to re-embed: %w", err)
+	}
+
+	result := ReembedProgress{Total: len(ids)}
+	batchSize := GetEmbeddingBatchSize()
+	for start := 0; start < len(ids); start += batchSize {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		batch := ids[start:min(start+batchSize, len(ids))]
+
+		var reembedded, failed int
+		var err error
+		for attempt := 0; attempt < reembedMaxAttempts; attempt++ {
+			reembedded, failed, err = reembedBatch(ctx, openaiClient, redisClient, embeddingModelId, batch)
+			if !errors.Is(err, redis.TxFailedErr) {
+				break
+			}
+		}
+		if err != nil {
+			reembedded, failed = 0, len(batch)
+			result.LastError = err.Error()
+		}
+		result.Reembedded += reembedded
+		result.Failed += failed
+		if progress != nil {
+			progress(result)
+		}
+	}
+	return result, nil
+}
+
+// reembedBatch re-encodes the content of a batch of documents in a transaction watching the documents.
+// It returns the number of documents re-embedded and failed (deleted documents are neither).
+func reembedBatch(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId string, ids []string) (int, int, error) {
+	var reembedded, failed int
+	err := redisClient.Watch(ctx, func(tx *redis.Tx) error {
+		reembedded, failed = 0, 0
+
+		cmds := make([]*redis.StringCmd, len(ids))
+		_, err := tx.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, id := range ids {
+				cmds[i] = pipe.HGet(ctx, id, contentField)
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+
+		var texts, textIDs []string
+		for i, cmd := range cmds {
+			content, err := cmd.Result()
+			if errors.Is(err, redis.Nil) {
+				continue // deleted meanwhile
+			}
+			if content = decryptStoredField(ids[i], "content", content); content == "" {
+				failed++
+				continue
+			}
+			texts = append(texts, content)
+			textIDs = append(textIDs, ids[i])
+		}
+		if len(texts) == 0 {
+			return nil
+		}
+
+		embeddings, err := CreateEmbeddingsFromTexts(ctx, openaiClient, texts, embeddingModelId)
+		if err != nil {
+			return fmt.Errorf("failed to create embeddings: %w", err)
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, id := range textIDs {
+				pipe.HSet(ctx, id, vectorField, floatsToBytes(embeddings[i]), "dimension", len(embeddings[i]))
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		reembedded = len(textIDs)
+		return nil
+	}, ids...)
+	return reembedded, failed, err
+}
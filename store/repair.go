@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"vectormind/events"
+	"vectormind/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrIndexCorrupted is returned when a search fails with an error showing that the index is corrupted or out of sync
+// with its definition: the index must be repaired (see RepairIndex). A missing index is not corrupted, it is reported
+// with ErrIndexMissing.
+var ErrIndexCorrupted = errors.New("index corrupted, it must be repaired")
+
+// tombstoneKeyPrefix is the prefix of the keys marking the corrupted indexes (followed by the index name)
+const tombstoneKeyPrefix = "vectormind:tombstone:"
+
+// repairPollInterval is the interval between two checks of the indexing progress of a repair
+const repairPollInterval = 500 * time.Millisecond
+
+// indexCorruptionMarkers are the parts of the search errors showing a corrupted index or a definition
+// that does not match the stored documents (lowercase)
+var indexCorruptionMarkers = []string{
+	"corrupt",
+	"could not open",
+	"error parsing vector similarity",
+}
+
+// fieldErrorMarkers are the parts of the search errors naming a field the index does not have (lowercase). The index
+// is only out of sync with its definition when the field is a field of the schema: the other fields come from the
+// filters of the caller.
+var fieldErrorMarkers = []string{
+	"unknown field",
+	"no such field",
+	"invalid field type",
+}
+
+// RepairProgress describes the indexing of the documents during a repair
+type RepairProgress struct {
+	Total            int // documents (hashes) of the collection
+	Indexed          int
+	PercentIndexed   float64
+	IndexingFailures int
+}
+
+// IsIndexCorruptionError reports whether a search error shows that an index must be repaired. A missing index, and a
+// field error on a field that is not part of the schema (an undeclared metadata field of a filter), are not.
+func IsIndexCorruptionError(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrIndexMissing) {
+		return false
+	}
+	message := strings.ToLower(err.Error())
+	for _, marker := range indexCorruptionMarkers {
+		if strings.Contains(message, marker) {
+			return true
+		}
+	}
+	for _, marker := range fieldErrorMarkers {
+		if strings.Contains(message, marker) {
+			return namesSchemaField(message)
+		}
+	}
+	return false
+}
+
+// namesSchemaField reports whether a (lowercase) error message names a field of the schema of the indexes, e.g.
+// "unknown field at offset 1 near label"
+func namesSchemaField(message string) bool {
+	fields := make(map[string]bool)
+	for _, field := range documentFieldSchemas() {
+		fields[strings.ToLower(field.FieldName)] = true
+		if field.As != "" {
+			fields[strings.ToLower(field.As)] = true
+		}
+	}
+	for _, word := range strings.FieldsFunc(message, func(r rune) bool {
+		return r == ' ' || r == '\'' || r == '"' || r == '`' || r == '@' || r == ',' || r == ':' || r == '(' || r == ')'
+	}) {
+		if fields[word] {
+			return true
+		}
+	}
+	return false
+}
+
+// checkSearchError marks the index with a tombstone when a search error shows that the index is corrupted,
+// and wraps the error with ErrIndexCorrupted. The other errors are returned as is.
+func checkSearchError(ctx context.Context, redisClient *redis.Client, indexName string, err error) error {
+	if !IsIndexCorruptionError(err) {
+		return err
+	}
+	tombstone, _ := json.Marshal(models.IndexTombstone{
+		Reason:     err.Error(),
+		DetectedAt: time.Now().Format(time.RFC3339),
+	})
+	// Only the first error is recorded, until the index is repaired
+	if created, tombstoneErr := redisClient.SetNX(ctx, tombstoneKeyPrefix+indexName, tombstone, 0).Result(); tombstoneErr == nil && created {
+		events.Record(events.TypeIndexCorrupted, fmt.Sprintf("Index %s corrupted, it must be repaired", indexName), map[string]any{
+			"index": indexName,
+			"db":    redisClient.Options().DB,
+			"error": err.Error(),
+		})
+	}
+	return fmt.Errorf("%w: %s: %w", ErrIndexCorrupted, indexName, err)
+}
+
+// GetIndexTombstone returns the tombstone of a corrupted index (nil when the index is not marked as corrupted)
+func GetIndexTombstone(ctx context.Context, redisClient *redis.Client, indexName string) (*models.IndexTombstone, error) {
+	value, err := redisClient.Get(ctx, tombstoneKeyPrefix+indexName).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the tombstone of index %s: %w", indexName, err)
+	}
+	var tombstone models.IndexTombstone
+	if err := json.Unmarshal([]byte(value), &tombstone); err != nil {
+		return nil, fmt.Errorf("invalid tombstone of index %s: %w", indexName, err)
+	}
+	return &tombstone, nil
+}
+
+// RepairIndex rebuilds the definition of the index of a collection from the current settings, and waits until Redis
+// has indexed the stored documents again (their vectors are kept, nothing is re-embedded).
+// progress is called at each check of the indexing progress (it can be nil). The tombstone of the index is removed
+// once the documents are indexed.
+func RepairIndex(ctx context.Context, redisClient *redis.Client, collection Collection, embeddingDimension int, options IndexOptions, progress func(RepairProgress)) (RepairProgress, error) {
+	keyPrefix := collection.KeyPrefix
+	if keyPrefix == "" {
+		keyPrefix = documentKeyPrefix
+	}
+
+	result := RepairProgress{}
+	iter := redisClient.ScanType(ctx, 0, keyPrefix+"*", reembedScanCount, "hash").Iterator()
+	for iter.Next(ctx) {
+		result.Total++
+	}
+	if err := iter.Err(); err != nil {
+		return result, fmt.Errorf("failed to count the documents to index: %w", err)
+	}
+	if progress != nil {
+		progress(result)
+	}
+
+	options.KeyPrefix = collection.KeyPrefix
+	if err := RebuildIndex(ctx, redisClient, collection.IndexName, embeddingDimension, options); err != nil {
+		return result, err
+	}
+
+	ticker := time.NewTicker(repairPollInterval)
+	defer ticker.Stop()
+	for {
+		info, err := GetIndexInfo(ctx, redisClient, collection.IndexName)
+		if err != nil {
+			return result, err
+		}
+		result.Indexed = info.NumDocs
+		result.PercentIndexed = info.PercentIndexed
+		result.IndexingFailures = info.IndexingFailures
+		if progress != nil {
+			progress(result)
+		}
+		if !info.Indexing {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return result, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	if err := redisClient.Del(ctx, tombstoneKeyPrefix+collection.IndexName).Err(); err != nil {
+		return result, fmt.Errorf("failed to remove the tombstone of index %s: %w", collection.IndexName, err)
+	}
+	return result, nil
+}
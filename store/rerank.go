@@ -0,0 +1,338 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vectormind/features"
+	"vectormind/models"
+
+	"github.com/openai/openai-go"
+)
+
+// Reranking providers
+const (
+	// RerankProviderAPI is a rerank API (POST /rerank) of Cohere, Jina, vLLM, Infinity...
+	RerankProviderAPI = "api"
+	// RerankProviderLLM is the chat model (see SetChatModel), asked to score the relevance of the results
+	RerankProviderLLM = "llm"
+)
+
+// DefaultRerankCandidatesFactor is the default number of candidates retrieved by result to return, before the reranking
+const DefaultRerankCandidatesFactor = 4
+
+// maxRerankCandidates bounds the number of candidates of a reranking
+const maxRerankCandidates = 100
+
+// rerankTimeout bounds the time of a request to a rerank API
+const rerankTimeout = 30 * time.Second
+
+// ErrRerankerMissing is returned when a reranking is requested while no reranker is configured (see SetReranker)
+var ErrRerankerMissing = errors.New("no reranker configured")
+
+// ErrRerankFailed is returned when the reranker cannot be reached, answers with an error or without a score by result
+var ErrRerankFailed = errors.New("rerank request failed")
+
+// Reranker scores the relevance of documents to a query: one score by document, in the order of the documents,
+// higher is more relevant
+type Reranker interface {
+	Rerank(ctx context.Context, query string, documents []string) ([]float64, error)
+}
+
+// RerankerConfig holds the settings of a reranker (RERANK_BASE_URL, RERANK_API_KEY and RERANK_MODEL)
+type RerankerConfig struct {
+	BaseURL string
+	APIKey  string
+	Model   string
+}
+
+// RerankerFactory creates a reranker from its settings
+type RerankerFactory func(config RerankerConfig) (Reranker, error)
+
+var (
+	rerankersMutex sync.RWMutex
+	rerankers      = make(map[string]RerankerFactory)
+)
+
+// RegisterReranker makes a reranking provider available by name to NewReranker (RERANK_PROVIDER), e.g. from the
+// init function of a package imported by a fork. It panics if the name is empty, the factory is nil or the name is
+// already registered.
+func RegisterReranker(name string, factory RerankerFactory) {
+	rerankersMutex.Lock()
+	defer rerankersMutex.Unlock()
+
+	name = strings.ToLower(name)
+	if name == "" {
+		panic("store: RegisterReranker with an empty name")
+	}
+	if factory == nil {
+		panic("store: RegisterReranker with a nil factory for " + name)
+	}
+	if _, exists := rerankers[name]; exists {
+		panic("store: RegisterReranker called twice for " + name)
+	}
+	rerankers[name] = factory
+}
+
+// RerankProviders returns the sorted names of the registered reranking providers
+func RerankProviders() []string {
+	rerankersMutex.RLock()
+	defer rerankersMutex.RUnlock()
+
+	return slices.Sorted(maps.Keys(rerankers))
+}
+
+// NewReranker creates the reranker of a registered provider (RerankProviderAPI, RerankProviderLLM or a provider added
+// with RegisterReranker)
+func NewReranker(provider string, config RerankerConfig) (Reranker, error) {
+	rerankersMutex.RLock()
+	factory, ok := rerankers[strings.ToLower(provider)]
+	rerankersMutex.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown reranking provider %q (use %s)", provider, strings.Join(RerankProviders(), ", "))
+	}
+	return factory(config)
+}
+
+func init() {
+	RegisterReranker(RerankProviderAPI, func(config RerankerConfig) (Reranker, error) {
+		if config.BaseURL == "" {
+			return nil, fmt.Errorf("the %s reranker needs a base URL (RERANK_BASE_URL)", RerankProviderAPI)
+		}
+		return NewAPIReranker(config.BaseURL, config.APIKey, config.Model), nil
+	})
+	RegisterReranker(RerankProviderLLM, func(config RerankerConfig) (Reranker, error) {
+		if chatModelId == "" {
+			return nil, fmt.Errorf("the %s reranker needs a chat model (CHAT_MODEL)", RerankProviderLLM)
+		}
+		return LLMReranker{}, nil
+	})
+}
+
+// reranker is the reranker of the searches (nil: no reranking), rerankCandidatesFactor the number of candidates
+// retrieved by result to return
+var (
+	reranker               Reranker
+	rerankCandidatesFactor = DefaultRerankCandidatesFactor
+)
+
+// SetReranker sets the reranker of the searches and the number of candidates retrieved by result to return
+// (DefaultRerankCandidatesFactor when factor <= 1). The reranker is set at startup, before the searches.
+func SetReranker(r Reranker, factor int) {
+	reranker = r
+	rerankCandidatesFactor = factor
+	if factor <= 1 {
+		rerankCandidatesFactor = DefaultRerankCandidatesFactor
+	}
+}
+
+// RerankEnabled returns true when a reranker is configured and the reranking feature is enabled
+func RerankEnabled() bool {
+	return reranker != nil && features.Enabled(features.Reranking)
+}
+
+// RerankCandidates returns the number of candidates to retrieve for a reranked search returning n results
+func RerankCandidates(n int) int {
+	return max(n, min(n*rerankCandidatesFactor, maxRerankCandidates))
+}
+
+// RerankResults rescores search results with the reranker, and returns the n most relevant ones, ordered by their
+// rerank score (the rerank scores are set). The content of the results is scored, or their expanded content when set.
+func RerankResults(ctx context.Context, query string, results []models.SimilaritySearchResult, n int) ([]models.SimilaritySearchResult, error) {
+	return rerankResults(ctx, query, results, n,
+		func(result models.SimilaritySearchResult) string {
+			return cmp.Or(result.ExpandedContent, result.Content)
+		},
+		func(result *models.SimilaritySearchResult, score float64) { result.RerankScore = &score },
+	)
+}
+
+// RerankHybridResults rescores hybrid search results with the reranker, as RerankResults
+func RerankHybridResults(ctx context.Context, query string, results []models.HybridSearchResult, n int) ([]models.HybridSearchResult, error) {
+	return rerankResults(ctx, query, results, n,
+		func(result models.HybridSearchResult) string { return cmp.Or(result.ExpandedContent, result.Content) },
+		func(result *models.HybridSearchResult, score float64) { result.RerankScore = &score },
+	)
+}
+
+// rerankResults rescores results of any type with the text returned by content, and sets their score with setScore
+func rerankResults[T any](ctx context.Context, query string, results []T, n int, content func(T) string, setScore func(*T, float64)) ([]T, error) {
+	if err := features.Check(features.Reranking); err != nil {
+		return nil, err
+	}
+	if reranker == nil {
+		return nil, ErrRerankerMissing
+	}
+	if len(results) == 0 {
+		return results, nil
+	}
+
+	documents := make([]string, len(results))
+	for i, result := range results {
+		documents[i] = content(result)
+	}
+	scores, err := reranker.Rerank(ctx, query, documents)
+	if err != nil {
+		return nil, err
+	}
+	if len(scores) != len(results) {
+		return nil, fmt.Errorf("%w: expected %d scores, got %d", ErrRerankFailed, len(results), len(scores))
+	}
+
+	// Stable: the results with the same score keep their search order
+	order := make([]int, len(results))
+	for i := range order {
+		order[i] = i
+	}
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(scores[b], scores[a]) })
+
+	reranked := make([]T, min(n, len(results)))
+	for i := range reranked {
+		reranked[i] = results[order[i]]
+		setScore(&reranked[i], scores[order[i]])
+	}
+	return reranked, nil
+}
+
+// APIReranker scores the documents with a rerank API: POST {BaseURL}/rerank with the model, the query and the
+// documents, answering with the relevance score of each document index (Cohere v2, Jina, vLLM, Infinity format)
+type APIReranker struct {
+	BaseURL string // e.g. https://api.cohere.com/v2
+	APIKey  string
+	Model   string
+	client  *http.Client
+}
+
+// NewAPIReranker creates a reranker for a rerank API
+func NewAPIReranker(baseURL, apiKey, model string) *APIReranker {
+	return &APIReranker{BaseURL: baseURL, APIKey: apiKey, Model: model, client: &http.Client{Timeout: rerankTimeout}}
+}
+
+// rerankAPIRequest is the body of a rerank API request
+type rerankAPIRequest struct {
+	Model     string   `json:"model,omitempty"`
+	Query     string   `json:"query"`
+	Documents []string `json:"documents"`
+	TopN      int      `json:"top_n"`
+}
+
+// rerankAPIResponse is the body of a rerank API response (the results are ordered by score, not by index)
+type rerankAPIResponse struct {
+	Results []struct {
+		Index          int     `json:"index"`
+		RelevanceScore float64 `json:"relevance_score"`
+	} `json:"results"`
+}
+
+// Rerank scores the documents with the rerank API
+func (r *APIReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	body, err := json.Marshal(rerankAPIRequest{Model: r.Model, Query: query, Documents: documents, TopN: len(documents)})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRerankFailed, err)
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(r.BaseURL, "/")+"/rerank", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRerankFailed, err)
+	}
+	request.Header.Set("Content-Type", "application/json")
+	if r.APIKey != "" {
+		request.Header.Set("Authorization", "Bearer "+r.APIKey)
+	}
+
+	response, err := r.client.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRerankFailed, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(response.Body, 512))
+		return nil, fmt.Errorf("%w: %d %s", ErrRerankFailed, response.StatusCode, strings.TrimSpace(string(message)))
+	}
+	var decoded rerankAPIResponse
+	if err := json.NewDecoder(response.Body).Decode(&decoded); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRerankFailed, err)
+	}
+
+	scores := make([]float64, len(documents))
+	scored := make([]bool, len(documents))
+	for _, result := range decoded.Results {
+		if result.Index < 0 || result.Index >= len(documents) {
+			return nil, fmt.Errorf("%w: unknown document index %d", ErrRerankFailed, result.Index)
+		}
+		scores[result.Index] = result.RelevanceScore
+		scored[result.Index] = true
+	}
+	if slices.Contains(scored, false) {
+		return nil, fmt.Errorf("%w: expected %d scores, got %d", ErrRerankFailed, len(documents), len(decoded.Results))
+	}
+	return scores, nil
+}
+
+// LLMReranker scores the documents with the chat model (see SetChatModel): the model is asked to rate the relevance
+// of each document from 0 to 10, in a single request
+type LLMReranker struct{}
+
+// llmRerankPrompt asks the chat model for a score by numbered document
+const llmRerankPrompt = "Rate how relevant each numbered document is to the query, from 0 (unrelated) to 10 (answers it). " +
+	"Answer with one line per document, in order, formatted as: [number] score. Do not explain."
+
+// llmScorePattern matches the score of a document in the answer of the chat model, e.g. [2] 7
+var llmScorePattern = regexp.MustCompile(`\[(\d+)\]\s*:?\s*(\d+(?:\.\d+)?)`)
+
+// Rerank scores the documents with the chat model
+func (LLMReranker) Rerank(ctx context.Context, query string, documents []string) ([]float64, error) {
+	if chatModelId == "" {
+		return nil, fmt.Errorf("%w: the llm reranker needs a chat model", ErrRerankerMissing)
+	}
+
+	var prompt strings.Builder
+	fmt.Fprintf(&prompt, "Query: %s\n\nDocuments:\n\n", query)
+	for i, document := range documents {
+		fmt.Fprintf(&prompt, "[%d] %s\n\n", i+1, strings.TrimSpace(document))
+	}
+	completion, err := chatClient.Chat.Completions.New(ctx, openai.ChatCompletionNewParams{
+		Model: chatModelId,
+		Messages: []openai.ChatCompletionMessageParamUnion{
+			openai.SystemMessage(llmRerankPrompt),
+			openai.UserMessage(prompt.String()),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrRerankFailed, err)
+	}
+	if len(completion.Choices) == 0 {
+		return nil, fmt.Errorf("%w: no scores returned", ErrRerankFailed)
+	}
+	return parseLLMScores(completion.Choices[0].Message.Content, len(documents))
+}
+
+// parseLLMScores returns the scores of n documents from the answer of the chat model ([number] score lines)
+func parseLLMScores(answer string, n int) ([]float64, error) {
+	scores := make([]float64, n)
+	scored := make([]bool, n)
+	for _, match := range llmScorePattern.FindAllStringSubmatch(answer, -1) {
+		number, _ := strconv.Atoi(match[1])
+		score, err := strconv.ParseFloat(match[2], 64)
+		if err != nil || number < 1 || number > n || scored[number-1] {
+			continue
+		}
+		scores[number-1] = score
+		scored[number-1] = true
+	}
+	if missing := slices.Index(scored, false); missing >= 0 {
+		return nil, fmt.Errorf("%w: no score for document %d in %q", ErrRerankFailed, missing+1, answer)
+	}
+	return scores, nil
+}
@@ -0,0 +1,94 @@
+package store
+
+import (
+	"sort"
+	"strconv"
+	"time"
+	"vectormind/models"
+	"vectormind/splitter"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DocumentsToSearchResults converts the documents returned by a similarity search into search results.
+// Documents farther than the distance threshold (if any) are dropped, and results are sorted by distance (closest first).
+func DocumentsToSearchResults(docs []redis.Document, distanceThreshold *float64) []models.SimilaritySearchResult {
+	results := make([]models.SimilaritySearchResult, 0, len(docs))
+	for _, doc := range docs {
+		str := doc.Fields["vector_distance"]
+		distance, err := strconv.ParseFloat(str, 32)
+		if err != nil {
+			distance = 9.9
+		}
+
+		// Filter by distance threshold if specified
+		if distanceThreshold != nil && distance > *distanceThreshold {
+			continue
+		}
+
+		result := DocumentToSearchResult(doc)
+		result.Distance = distance
+
+		results = append(results, result)
+	}
+
+	// Sort results by distance in ascending order (closest first)
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Distance < results[j].Distance
+	})
+
+	return results
+}
+
+// TextSearchResults converts the documents returned by SearchByText into search results.
+// Keyword fallback results have no distance: they keep the text relevance order and the distance threshold is not applied.
+func TextSearchResults(docs []redis.Document, fallback string, distanceThreshold *float64) []models.SimilaritySearchResult {
+	if fallback != SearchFallbackKeyword {
+		return DocumentsToSearchResults(docs, distanceThreshold)
+	}
+	results := make([]models.SimilaritySearchResult, 0, len(docs))
+	for _, doc := range docs {
+		results = append(results, DocumentToSearchResult(doc))
+	}
+	return results
+}
+
+// DocumentToSearchResult converts the stored fields of a document into a search result (without distance).
+// Encrypted content and metadata are decrypted.
+func DocumentToSearchResult(doc redis.Document) models.SimilaritySearchResult {
+	createdAtUnix, _ := strconv.ParseInt(doc.Fields["created_at"], 10, 64)
+	createdAt := time.Unix(createdAtUnix, 0).Format(time.RFC3339)
+
+	quality, _ := strconv.ParseFloat(doc.Fields["quality"], 64)
+
+	content := decryptStoredField(doc.ID, "content", doc.Fields["content"])
+	// The chunks of the markdown hierarchy splitter start with their title and hierarchy
+	title, hierarchy, _ := splitter.ParseHierarchyChunk(content)
+
+	return models.SimilaritySearchResult{
+		ID:        doc.ID,
+		Content:   content,
+		Label:     doc.Fields["label"],
+		Labels:    SplitLabels(doc.Fields["label"]),
+		Metadata:  decryptStoredField(doc.ID, "metadata", doc.Fields["metadata"]),
+		Quality:   quality,
+		CreatedAt: createdAt,
+		Title:     title,
+		Hierarchy: hierarchy,
+		ChunkProvenance: models.ChunkProvenance{
+			ParentID:    doc.Fields["parent_id"],
+			ChunkIndex:  optionalIntField(doc.Fields, "chunk_index"),
+			StartOffset: optionalIntField(doc.Fields, "start_offset"),
+			EndOffset:   optionalIntField(doc.Fields, "end_offset"),
+		},
+	}
+}
+
+// optionalIntField returns the integer value of a stored field, nil when the document has no such field
+func optionalIntField(fields map[string]string, field string) *int {
+	value, err := strconv.Atoi(fields[field])
+	if err != nil {
+		return nil
+	}
+	return &value
+}
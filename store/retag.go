@@ -0,0 +1,183 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// retagBatchSize is the number of documents listed by a single search, and relabeled by a single transaction
+const retagBatchSize = 500
+
+// ErrFilterRequired is returned by the operations on all the documents matching a filter (relabeling, deletion) when
+// the filter matches any document, so that they cannot change the whole index by mistake
+var ErrFilterRequired = errors.New("a filter is required")
+
+// retagMaxAttempts is the number of attempts to relabel a batch whose documents are modified meanwhile
+const retagMaxAttempts = 3
+
+// LabelChange describes the new labels of the documents of a re-tag: Set replaces all their labels, Add and
+// Remove keep the other labels
+type LabelChange struct {
+	Set    []string
+	Add    []string
+	Remove []string
+}
+
+// RetagResult counts the documents of a re-tag: the documents matching the filter, and the documents whose labels
+// changed (the documents that already had the new labels are not written)
+type RetagResult struct {
+	Matched int
+	Updated int
+}
+
+// ValidateLabelChange checks that a label change sets or changes labels, and that its labels are valid
+func ValidateLabelChange(change LabelChange) error {
+	if len(change.Set) > 0 && (len(change.Add) > 0 || len(change.Remove) > 0) {
+		return errors.New("set_labels cannot be combined with add_labels or remove_labels")
+	}
+	if len(change.Set) == 0 && len(change.Add) == 0 && len(change.Remove) == 0 {
+		return errors.New("set_labels, add_labels or remove_labels is required")
+	}
+	for _, labels := range [][]string{change.Set, change.Add, change.Remove} {
+		if _, err := JoinLabels("", labels); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// apply returns the label field of a document with the label change applied
+func (change LabelChange) apply(label string) string {
+	labels := SplitLabels(label)
+	if len(change.Set) > 0 {
+		labels = change.Set
+	}
+	labels = slices.DeleteFunc(append(slices.Clone(labels), change.Add...), func(l string) bool {
+		return slices.Contains(change.Remove, l)
+	})
+	joined, _ := JoinLabels("", labels)
+	return joined
+}
+
+// RenameLabel replaces a label by another in all the documents of an index having it, their other labels are kept
+func RenameLabel(ctx context.Context, redisClient *redis.Client, indexName, from, to string) (RetagResult, error) {
+	if from == "" || to == "" {
+		return RetagResult{}, errors.New("from and to are required")
+	}
+	change := LabelChange{Add: []string{to}, Remove: []string{from}}
+	if err := ValidateLabelChange(change); err != nil {
+		return RetagResult{}, err
+	}
+	return RetagDocuments(ctx, redisClient, indexName, SearchOptions{Labels: []string{from}}, change)
+}
+
+// RetagDocuments changes the labels of all the documents of an index matching the filter (labels, quality and
+// metadata filters of the search options), in batches of retagBatchSize documents. The IDs of the documents are listed
+// first, so that the relabeled documents are not listed again; a batch whose documents are modified meanwhile is read
+// again, so that a concurrent update of the labels is not lost.
+func RetagDocuments(ctx context.Context, redisClient *redis.Client, indexName string, filter SearchOptions, change LabelChange) (RetagResult, error) {
+	if err := ValidateLabelChange(change); err != nil {
+		return RetagResult{}, err
+	}
+	if len(filterExpressions(filter)) == 0 {
+		return RetagResult{}, ErrFilterRequired
+	}
+
+	ids, err := matchingDocumentIDs(ctx, redisClient, indexName, filter)
+	if err != nil {
+		return RetagResult{}, err
+	}
+
+	result := RetagResult{Matched: len(ids)}
+	for start := 0; start < len(ids); start += retagBatchSize {
+		batch := ids[start:min(start+retagBatchSize, len(ids))]
+		var updated []string
+		var err error
+		for attempt := 0; attempt < retagMaxAttempts; attempt++ {
+			updated, err = retagBatch(ctx, redisClient, batch, change)
+			if !errors.Is(err, redis.TxFailedErr) {
+				break
+			}
+		}
+		if err != nil {
+			return result, fmt.Errorf("failed to change the labels of the documents: %w", err)
+		}
+		result.Updated += len(updated)
+		recordChanges(ctx, redisClient, ChangeUpdated, updated)
+	}
+	return result, nil
+}
+
+// matchingDocumentIDs lists the IDs of all the documents of an index matching a filter, retagBatchSize IDs
+// by search
+func matchingDocumentIDs(ctx context.Context, redisClient *redis.Client, indexName string, filter SearchOptions) ([]string, error) {
+	ids := []string{}
+	query := buildFilterQuery(filter)
+	for offset := 0; ; offset += retagBatchSize {
+		results, err := redisClient.FTSearchWithArgs(ctx, indexName, query, &redis.FTSearchOptions{
+			NoContent:      true,
+			LimitOffset:    offset,
+			Limit:          retagBatchSize,
+			DialectVersion: 2,
+		}).Result()
+		if err != nil {
+			return nil, checkSearchError(ctx, redisClient, indexName, err)
+		}
+		for _, doc := range results.Docs {
+			ids = append(ids, doc.ID)
+		}
+		if len(results.Docs) < retagBatchSize || len(ids) >= results.Total {
+			return ids, nil
+		}
+	}
+}
+
+// retagBatch applies the label change to a batch of documents in a transaction watching the documents.
+// It returns the IDs of the documents whose labels changed (deleted documents are skipped).
+func retagBatch(ctx context.Context, redisClient *redis.Client, ids []string, change LabelChange) ([]string, error) {
+	var updated []string
+	err := redisClient.Watch(ctx, func(tx *redis.Tx) error {
+		updated = nil
+
+		cmds := make([]*redis.StringCmd, len(ids))
+		_, err := tx.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, id := range ids {
+				cmds[i] = pipe.HGet(ctx, id, "label")
+			}
+			return nil
+		})
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+
+		labels := map[string]string{}
+		for i, cmd := range cmds {
+			label, err := cmd.Result()
+			if errors.Is(err, redis.Nil) {
+				continue // deleted meanwhile
+			}
+			if newLabel := change.apply(label); newLabel != label {
+				labels[ids[i]] = newLabel
+				updated = append(updated, ids[i])
+			}
+		}
+		if len(updated) == 0 {
+			return nil
+		}
+
+		updatedAt := time.Now().Unix()
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, id := range updated {
+				pipe.HSet(ctx, id, "label", labels[id], "updated_at", updatedAt)
+			}
+			return nil
+		})
+		return err
+	}, ids...)
+	return updated, err
+}
@@ -0,0 +1,164 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultReplicaCheckInterval is the default interval between two health checks of the Redis replicas
+const DefaultReplicaCheckInterval = 5 * time.Second
+
+// RedisRouter routes the requests of the tenants to their index, and the searches to the read replicas (see
+// SetReplicas). Requests without tenant use the main index. The tenants share the database (RediSearch only indexes
+// the keys of database 0): each tenant has its own index over the keys of its prefix (see TenantIndexName).
+type RedisRouter struct {
+	indexName   string
+	tenants     map[string]bool
+	client      *redis.Client
+	replicas    []*replica    // clients of the replicas of the database
+	nextReplica atomic.Uint64 // round robin of the searches between the replicas
+}
+
+// replica is the client of a read replica of the database, with the result of its last health check
+type replica struct {
+	client  *redis.Client
+	healthy atomic.Bool
+}
+
+// ParseReplicaAddresses parses a comma separated list of Redis replica addresses like "replica-1:6379,replica-2:6379"
+func ParseReplicaAddresses(spec string) ([]string, error) {
+	addresses := []string{}
+	for _, address := range strings.Split(spec, ",") {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+		if _, _, err := net.SplitHostPort(address); err != nil {
+			return nil, fmt.Errorf("invalid replica address %q (expected host:port)", address)
+		}
+		addresses = append(addresses, address)
+	}
+	return addresses, nil
+}
+
+// NewRedisRouter creates the Redis client of a database, shared by the main index and the indexes of the tenants
+func NewRedisRouter(redisAddress, redisPassword string, db int, indexName string, tenants []string) *RedisRouter {
+	router := &RedisRouter{
+		indexName: indexName,
+		tenants:   make(map[string]bool, len(tenants)),
+		client:    CreateRedisClientWithDB(redisAddress, redisPassword, db),
+	}
+	for _, tenant := range tenants {
+		router.tenants[tenant] = true
+	}
+	return router
+}
+
+// DefaultClient returns the client of the database (the primary)
+func (router *RedisRouter) DefaultClient() *redis.Client {
+	return router.client
+}
+
+// IndexName returns the main index of a tenant (the main index when tenant is empty).
+// Unknown tenants are rejected rather than silently served from the main index.
+func (router *RedisRouter) IndexName(tenant string) (string, error) {
+	if tenant == "" {
+		return router.indexName, nil
+	}
+	if !router.tenants[tenant] {
+		return "", fmt.Errorf("%w %q", ErrUnknownTenant, tenant)
+	}
+	return TenantIndexName(router.indexName, tenant), nil
+}
+
+// IndexNames returns the main index and the indexes of the tenants, sorted
+func (router *RedisRouter) IndexNames() []string {
+	names := []string{router.indexName}
+	for tenant := range router.tenants {
+		names = append(names, TenantIndexName(router.indexName, tenant))
+	}
+	sort.Strings(names[1:])
+	return names
+}
+
+// SetReplicas creates the clients of the read replicas of the database. The replicas serve the searches once a
+// health check succeeded (see CheckReplicas).
+func (router *RedisRouter) SetReplicas(addresses []string, redisPassword string) {
+	router.replicas = nil
+	for _, address := range addresses {
+		router.replicas = append(router.replicas, &replica{client: CreateRedisClientWithDB(address, redisPassword, router.client.Options().DB)})
+	}
+}
+
+// HasReplicas reports whether the searches can be routed to read replicas
+func (router *RedisRouter) HasReplicas() bool {
+	return len(router.replicas) > 0
+}
+
+// SearchClient returns the client of the searches: a healthy replica, in turn, else the primary. The replicas are
+// updated asynchronously, so a search may miss the latest writes.
+func (router *RedisRouter) SearchClient() *redis.Client {
+	if len(router.replicas) == 0 {
+		return router.client
+	}
+	start := router.nextReplica.Add(1)
+	for i := range router.replicas {
+		if replica := router.replicas[(start+uint64(i))%uint64(len(router.replicas))]; replica.healthy.Load() {
+			return replica.client
+		}
+	}
+	return router.client
+}
+
+// CheckReplicas pings the replicas: a replica that does not answer stops serving the searches until it answers again
+func (router *RedisRouter) CheckReplicas(ctx context.Context) {
+	for _, replica := range router.replicas {
+		checkCtx, cancel := context.WithTimeout(ctx, 2*time.Second)
+		err := replica.client.Ping(checkCtx).Err()
+		cancel()
+		if healthy := err == nil; replica.healthy.Swap(healthy) != healthy {
+			if healthy {
+				log.Printf("🟢 Redis replica %s serves the searches", replica.client.Options().Addr)
+			} else {
+				log.Printf("🟠 Redis replica %s is unavailable, its searches go to the primary: %v", replica.client.Options().Addr, err)
+			}
+		}
+	}
+}
+
+// MonitorReplicas checks the replicas every interval (default: DefaultReplicaCheckInterval, see CheckReplicas), until
+// the context is done
+func (router *RedisRouter) MonitorReplicas(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultReplicaCheckInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			router.CheckReplicas(ctx)
+		}
+	}
+}
+
+// Close closes the connections to the database and to its replicas
+func (router *RedisRouter) Close() error {
+	firstErr := CloseRedisClient(router.client)
+	for _, replica := range router.replicas {
+		if err := CloseRedisClient(replica.client); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,97 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Index schemas (REDIS_SCHEMA)
+const (
+	SchemaVectorMind = "vectormind"
+	SchemaLangChain  = "langchain"
+)
+
+// Default hash fields of the documents of the LangChain Redis vector store
+const (
+	LangChainContentField = "content"
+	LangChainVectorField  = "content_vector"
+)
+
+// contentField and vectorField are the hash fields of the content and of the embedding of the documents (see
+// SetSchema). The index and the searches name them content and embedding whatever their hash fields.
+var (
+	contentField = "content"
+	vectorField  = "embedding"
+)
+
+// SchemaConfig is the layout of the documents in Redis
+type SchemaConfig struct {
+	Name string // SchemaVectorMind (default) or SchemaLangChain
+	// LangChainIndex is the name of the index of the LangChain Redis vector store, whose documents are the keys
+	// "doc:<index>:<id>" (SchemaLangChain only)
+	LangChainIndex string
+	// ContentField and VectorField are the hash fields of the content and of the embedding of the LangChain
+	// documents (default: LangChainContentField and LangChainVectorField, SchemaLangChain only)
+	ContentField string
+	VectorField  string
+}
+
+// SetSchema sets the layout of the documents (REDIS_SCHEMA). With SchemaLangChain, the main index covers the
+// documents of a LangChain Redis vector store: VectorMind searches them, and stores its documents like LangChain
+// (under its key prefix, with its content and vector fields), so that both read the same documents without migration.
+// The index of VectorMind is another index than the one of LangChain, over the same keys.
+func SetSchema(config SchemaConfig) error {
+	switch config.Name {
+	case "", SchemaVectorMind:
+		documentKeyPrefix, contentField, vectorField = "doc:", "content", "embedding"
+		return nil
+	case SchemaLangChain:
+	default:
+		return fmt.Errorf("unknown schema %q (use %s or %s)", config.Name, SchemaVectorMind, SchemaLangChain)
+	}
+
+	if config.LangChainIndex == "" || strings.ContainsAny(config.LangChainIndex, ": \t") {
+		return fmt.Errorf("the %s schema requires the name of the LangChain index (without ':' or spaces)", SchemaLangChain)
+	}
+	if config.ContentField == "" {
+		config.ContentField = LangChainContentField
+	}
+	if config.VectorField == "" {
+		config.VectorField = LangChainVectorField
+	}
+	if config.ContentField == config.VectorField {
+		return fmt.Errorf("the content and vector fields must be different")
+	}
+	documentKeyPrefix = "doc:" + config.LangChainIndex + ":"
+	contentField, vectorField = config.ContentField, config.VectorField
+	return nil
+}
+
+// schemaAlias returns the alias of a hash field in the index and in the search results: name when the field has
+// another name in the hash, "" otherwise
+func schemaAlias(field, name string) string {
+	if field == name {
+		return ""
+	}
+	return name
+}
+
+// returnField returns a hash field of the schema in the search results, under its name in VectorMind
+func returnField(field, name string) redis.FTSearchReturn {
+	return redis.FTSearchReturn{FieldName: field, As: schemaAlias(field, name)}
+}
+
+// schemaDocumentFields renames the content and vector fields of a document read from Redis to content and embedding
+func schemaDocumentFields(fields map[string]string) map[string]string {
+	if contentField != "content" {
+		fields["content"] = fields[contentField]
+		delete(fields, contentField)
+	}
+	if vectorField != "embedding" {
+		fields["embedding"] = fields[vectorField]
+		delete(fields, vectorField)
+	}
+	return fields
+}
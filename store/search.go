@@ -0,0 +1,173 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrSearchTimeout is returned when a search does not complete within its time budget
+var ErrSearchTimeout = errors.New("search timed out")
+
+// ErrKeywordSearchDisabled is returned by keyword searches when the content is encrypted at rest (not full-text indexed)
+var ErrKeywordSearchDisabled = errors.New("keyword search is disabled when the content is encrypted")
+
+// SearchFallbackKeyword is the fallback reported when a search returns keyword results instead of similarity results
+const SearchFallbackKeyword = "keyword"
+
+// embeddingBudgetShare is the share of the time budget given to the query embedding when the keyword fallback is enabled,
+// the rest of the budget is left to the keyword search
+const embeddingBudgetShare = 0.8
+
+// TextSearchBudget bounds the total time of a text search (query embedding and vector search)
+type TextSearchBudget struct {
+	Timeout time.Duration // 0 means no time budget
+	// KeywordFallback returns keyword search results when the query embedding does not complete in time
+	KeywordFallback bool
+}
+
+// SearchTimings holds the time spent by a text search in the query embedding and in the search of the store
+type SearchTimings struct {
+	Embed  time.Duration
+	Search time.Duration
+}
+
+// SearchByText embeds a text query and performs a similarity search, within the time budget (the QueryVariants of
+// the options are searched too, and the results are diversified with their Diversity; the keyword results are not).
+// When the embedding is too slow and the keyword fallback is enabled, it returns the results of a keyword search
+// and SearchFallbackKeyword. It returns ErrSearchTimeout when the budget is exceeded without fallback results.
+func SearchByText(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, indexName, text string, numberOfTopSimilarities int, options SearchOptions, budget TextSearchBudget) ([]redis.Document, string, error) {
+	docs, fallback, _, err := SearchByTextWithTimings(ctx, openaiClient, redisClient, embeddingModelId, indexName, text, numberOfTopSimilarities, options, budget)
+	return docs, fallback, err
+}
+
+// SearchByTextWithTimings is SearchByText, also returning the time spent in the query embedding and in the search
+// (the keyword search when the keyword fallback is used)
+func SearchByTextWithTimings(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, indexName, text string, numberOfTopSimilarities int, options SearchOptions, budget TextSearchBudget) ([]redis.Document, string, SearchTimings, error) {
+	var timings SearchTimings
+
+	searchCtx, embeddingCtx := ctx, ctx
+	if budget.Timeout > 0 {
+		var cancel context.CancelFunc
+		searchCtx, cancel = context.WithTimeout(ctx, budget.Timeout)
+		defer cancel()
+
+		embeddingCtx = searchCtx
+		if budget.KeywordFallback {
+			embeddingCtx, cancel = context.WithTimeout(searchCtx, time.Duration(float64(budget.Timeout)*embeddingBudgetShare))
+			defer cancel()
+		}
+	}
+
+	// Create embedding from query text
+	start := time.Now()
+	queryEmbedding, err := CreateQueryEmbeddingFromText(embeddingCtx, openaiClient, text, embeddingModelId)
+	timings.Embed = time.Since(start)
+	if err != nil {
+		if embeddingCtx.Err() == nil {
+			return nil, "", timings, fmt.Errorf("failed to create embedding: %w", err)
+		}
+		if !budget.KeywordFallback {
+			return nil, "", timings, fmt.Errorf("%w: the query embedding did not complete within %s", ErrSearchTimeout, budget.Timeout)
+		}
+
+		start = time.Now()
+		docs, keywordErr := KeywordSearch(searchCtx, redisClient, indexName, text, numberOfTopSimilarities, options)
+		timings.Search = time.Since(start)
+		if keywordErr != nil {
+			return nil, "", timings, fmt.Errorf("%w: the query embedding did not complete within %s and the keyword fallback failed: %v", ErrSearchTimeout, budget.Timeout, keywordErr)
+		}
+		return docs, SearchFallbackKeyword, timings, nil
+	}
+
+	// Perform similarity search (the diversification selects the results among more candidates)
+	start = time.Now()
+	candidates := numberOfTopSimilarities
+	if options.Diversity > 0 {
+		candidates = DiversityCandidates(numberOfTopSimilarities)
+	}
+	var docs []redis.Document
+	if len(options.QueryVariants) > 0 {
+		docs, err = multiQuerySearch(searchCtx, openaiClient, redisClient, embeddingModelId, indexName, queryEmbedding, candidates, options)
+	} else {
+		docs, err = SimilaritySearchWithOptions(searchCtx, redisClient, indexName, queryEmbedding, candidates, options)
+	}
+	if err == nil && options.Diversity > 0 {
+		docs = diversifyDocuments(queryEmbedding, docs, numberOfTopSimilarities, options.Diversity)
+	}
+	timings.Search = time.Since(start)
+	if err != nil {
+		if searchCtx.Err() != nil && ctx.Err() == nil {
+			return nil, "", timings, fmt.Errorf("%w: the similarity search did not complete within %s", ErrSearchTimeout, budget.Timeout)
+		}
+		return nil, "", timings, fmt.Errorf("failed to perform similarity search: %w", err)
+	}
+	return docs, "", timings, nil
+}
+
+// KeywordSearch performs a full-text search of the words of a text query in the content of the documents, or in
+// the SearchFields of the options (any word matches, the results are ordered by BM25 relevance and carry their score).
+// When the content is searched, the matches in the section titles and hierarchies are boosted (see FieldWeights).
+// The label and quality options are applied, the distance is not.
+// It returns ErrKeywordSearchDisabled when the content is encrypted at rest.
+func KeywordSearch(ctx context.Context, redisClient *redis.Client, indexName, text string, limit int, options SearchOptions) ([]redis.Document, error) {
+	if EncryptionEnabled() {
+		return nil, ErrKeywordSearchDisabled
+	}
+
+	query := buildKeywordQuery(text, options)
+	if query == "" {
+		return []redis.Document{}, nil
+	}
+
+	results, err := redisClient.FTSearchWithArgs(ctx,
+		indexName,
+		query,
+		&redis.FTSearchOptions{
+			Return:         searchReturnFields()[1:], // no vector distance
+			WithScores:     true,
+			Scorer:         "BM25",
+			LimitOffset:    0,
+			Limit:          limit,
+			DialectVersion: 2,
+		},
+	).Result()
+	if err != nil {
+		return nil, checkSearchError(ctx, redisClient, indexName, err)
+	}
+
+	return results.Docs, nil
+}
+
+// buildKeywordQuery builds the full-text query matching any word of the text ("" when the text has no word)
+func buildKeywordQuery(text string, options SearchOptions) string {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return ""
+	}
+
+	terms := "(" + strings.Join(words, "|") + ")"
+	query := "@" + searchFieldAttributes(options.SearchFields) + ":" + terms
+	// The matches in the section titles and hierarchies add to the score of the content matches
+	if searchesContent(options.SearchFields) {
+		weights := GetFieldWeights()
+		if options.FieldWeights != nil {
+			weights = *options.FieldWeights
+		}
+		if clauses := boostedSectionClauses(terms, weights); len(clauses) > 0 {
+			query = "((" + query + ") | " + strings.Join(clauses, " | ") + ")"
+		}
+	}
+	if filter := buildFilterQuery(options); filter != "*" {
+		query = filter + " " + query
+	}
+	return query
+}
@@ -0,0 +1,33 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"vectormind/splitter"
+
+	"github.com/openai/openai-go"
+)
+
+// SemanticChunks splits a text into sentences, embeds them (by batches of GetEmbeddingBatchSize sentences) and merges
+// the adjacent sentences into chunks of related content (see splitter.SemanticMerge). The chunks have at most maxSize
+// characters; they are embedded again when they are stored.
+func SemanticChunks(ctx context.Context, openaiClient openai.Client, text, embeddingModelId string, threshold float64, maxSize int) ([]string, error) {
+	sentences := splitter.SplitSentences(text, maxSize)
+	vectors := make([][]float32, 0, len(sentences))
+
+	batchSize := GetEmbeddingBatchSize()
+	for start := 0; start < len(sentences); start += batchSize {
+		end := min(start+batchSize, len(sentences))
+		texts := make([]string, 0, end-start)
+		for _, sentence := range sentences[start:end] {
+			texts = append(texts, strings.TrimSpace(sentence))
+		}
+		batch, err := CreateEmbeddingsFromTexts(ctx, openaiClient, texts, embeddingModelId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed the sentences: %w", err)
+		}
+		vectors = append(vectors, batch...)
+	}
+	return splitter.SemanticMerge(sentences, vectors, threshold, maxSize), nil
+}
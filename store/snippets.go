@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"vectormind/models"
+	"vectormind/splitter"
+
+	"github.com/openai/openai-go"
+)
+
+// MaxSnippetSize is the largest size of the snippets of the search results, in characters
+const MaxSnippetSize = 1000
+
+// ValidateSnippetSize checks the snippet size of a search (0: no snippets)
+func ValidateSnippetSize(size int) error {
+	if size < 0 || size > MaxSnippetSize {
+		return fmt.Errorf("snippet_size must be between 0 and %d", MaxSnippetSize)
+	}
+	return nil
+}
+
+// SearchSnippets returns the snippet of each content: its sentence most similar to the query (cosine similarity of
+// the embeddings), with the neighbouring sentences that fit in size characters (see splitter.Snippet). The query and
+// the sentences of all the contents are embedded together, by batches of GetEmbeddingBatchSize texts.
+func SearchSnippets(ctx context.Context, openaiClient openai.Client, query string, contents []string, embeddingModelId string, size int) ([]string, error) {
+	if len(contents) == 0 {
+		return []string{}, nil
+	}
+	sentencesByContent := make([][]string, len(contents))
+	texts := []string{query}
+	for i, content := range contents {
+		sentencesByContent[i] = splitter.SnippetSentences(content, size)
+		for _, sentence := range sentencesByContent[i] {
+			texts = append(texts, strings.TrimSpace(sentence))
+		}
+	}
+
+	vectors := make([][]float32, 0, len(texts))
+	batchSize := GetEmbeddingBatchSize()
+	for start := 0; start < len(texts); start += batchSize {
+		batch, err := CreateEmbeddingsFromTexts(ctx, openaiClient, texts[start:min(start+batchSize, len(texts))], embeddingModelId)
+		if err != nil {
+			return nil, fmt.Errorf("failed to embed the sentences of the snippets: %w", err)
+		}
+		vectors = append(vectors, batch...)
+	}
+
+	queryVector := make([]float64, len(vectors[0]))
+	for i, value := range vectors[0] {
+		queryVector[i] = float64(value)
+	}
+	snippets := make([]string, len(contents))
+	next := 1
+	for i, sentences := range sentencesByContent {
+		best, bestSimilarity := 0, -2.0
+		for j := range sentences {
+			if similarity := splitter.CosineSimilarity(queryVector, vectors[next+j]); similarity > bestSimilarity {
+				best, bestSimilarity = j, similarity
+			}
+		}
+		next += len(sentences)
+		snippets[i] = splitter.Snippet(sentences, best, size)
+	}
+	return snippets, nil
+}
+
+// AddSnippets sets the snippets of the search results (see SearchSnippets)
+func AddSnippets(ctx context.Context, openaiClient openai.Client, query string, results []models.SimilaritySearchResult, embeddingModelId string, size int) error {
+	contents := make([]string, len(results))
+	for i, result := range results {
+		contents[i] = result.Content
+	}
+	snippets, err := SearchSnippets(ctx, openaiClient, query, contents, embeddingModelId, size)
+	if err != nil {
+		return err
+	}
+	for i := range results {
+		results[i].Snippet = snippets[i]
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package store
+
+import (
+	"time"
+	"vectormind/splitter"
+)
+
+// SubtitleChunks returns the chunks of the captions of a subtitle file grouped by time window (see
+// splitter.GroupCaptions) with the metadata of each chunk: the metadata of the request completed with the time
+// interval of its window and the deep link to the video (see splitter.SubtitleMetadata)
+func SubtitleChunks(captions []splitter.Caption, window time.Duration, metadata, videoURL string, maxTokens int) ([]string, []string, error) {
+	chunks := []string{}
+	chunkMetadata := []string{}
+	for _, group := range splitter.GroupCaptions(captions, window, maxTokens) {
+		groupMetadata, err := MergeMetadata(metadata, splitter.SubtitleMetadata(group, videoURL))
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, chunk := range splitter.ChunkSubtitle(group, maxTokens) {
+			chunks = append(chunks, chunk)
+			chunkMetadata = append(chunkMetadata, groupMetadata)
+		}
+	}
+	return chunks, chunkMetadata, nil
+}
@@ -0,0 +1,85 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// tenantKeyPrefix is the prefix of the keys and of the index names of the tenants ("tenant:<name>:").
+// The documents of a tenant are stored under "tenant:<name>:doc:" (and "tenant:<name>:col:<collection>:" in its
+// collections) and indexed by "tenant:<name>:<index>": the main index never sees them.
+const tenantKeyPrefix = "tenant:"
+
+// ErrUnknownTenant is returned for a tenant that is not declared (see ParseTenants)
+var ErrUnknownTenant = errors.New("unknown tenant")
+
+// tenantContextKey is the context key of the tenant of a request (see WithTenant)
+type tenantContextKey struct{}
+
+// ParseTenants parses a comma separated list of tenant names like "acme,globex". The names follow the rules of the
+// collection names, as they are part of the keys and of the index names.
+func ParseTenants(spec string) ([]string, error) {
+	tenants := []string{}
+	seen := make(map[string]bool)
+	for _, tenant := range strings.Split(spec, ",") {
+		tenant = strings.TrimSpace(tenant)
+		if tenant == "" {
+			continue
+		}
+		if !collectionNamePattern.MatchString(tenant) {
+			return nil, fmt.Errorf("invalid tenant name %q (use up to 64 letters, digits, '_' or '-', starting with a letter or a digit)", tenant)
+		}
+		if seen[tenant] {
+			return nil, fmt.Errorf("duplicate tenant %s", tenant)
+		}
+		seen[tenant] = true
+		tenants = append(tenants, tenant)
+	}
+	return tenants, nil
+}
+
+// TenantIndexName returns the name of the main index of a tenant (indexName itself without tenant)
+func TenantIndexName(indexName, tenant string) string {
+	if tenant == "" {
+		return indexName
+	}
+	return tenantKeyPrefix + tenant + ":" + indexName
+}
+
+// tenantNamespace returns the "tenant:<name>:" prefix of a key or an index name of a tenant ("" outside the tenants)
+func tenantNamespace(name string) string {
+	rest, found := strings.CutPrefix(name, tenantKeyPrefix)
+	if !found {
+		return ""
+	}
+	tenant, _, found := strings.Cut(rest, ":")
+	if !found || !collectionNamePattern.MatchString(tenant) {
+		return ""
+	}
+	return tenantKeyPrefix + tenant + ":"
+}
+
+// ValidateTenantDocumentID checks that an ID designates a document of the tenant of an index (see ValidateDocumentID):
+// a request of a tenant cannot read or change the documents of another tenant, nor the documents outside the tenants.
+func ValidateTenantDocumentID(indexName, id string) error {
+	if err := ValidateDocumentID(id); err != nil {
+		return err
+	}
+	if tenantNamespace(id) != tenantNamespace(indexName) {
+		return fmt.Errorf("invalid document id %q (not a document of the tenant of the request)", id)
+	}
+	return nil
+}
+
+// WithTenant returns a context carrying the tenant of the request it serves
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+// ContextTenant returns the tenant of the request served with the context ("" without tenant)
+func ContextTenant(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantContextKey{}).(string)
+	return tenant
+}
@@ -0,0 +1,111 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Chunk is a chunk of a document given to the ingest transforms, before it is stored
+type Chunk struct {
+	Content  string
+	Metadata string // JSON metadata of the chunk ("" without metadata)
+	SourceID string // source document of the chunk (read only)
+	Index    int    // index of the chunk in its document (read only)
+}
+
+// IngestTransform transforms the chunks of the ingested documents before they are embedded and stored, e.g. to mask
+// personal data or to add metadata. The content of a transformed chunk must not be empty.
+type IngestTransform interface {
+	Transform(ctx context.Context, chunk Chunk) (Chunk, error)
+}
+
+var (
+	transformsMutex sync.RWMutex
+	// transforms are the registered ingest transforms, ingestTransforms the enabled ones, in their order of application
+	transforms       = make(map[string]IngestTransform)
+	ingestTransforms []IngestTransform
+)
+
+// RegisterIngestTransform makes an ingest transform available by name to SetIngestTransforms (INGEST_TRANSFORMS),
+// e.g. from the init function of a package imported by a fork. It panics if the name is empty, the transform is nil
+// or the name is already registered.
+func RegisterIngestTransform(name string, transform IngestTransform) {
+	transformsMutex.Lock()
+	defer transformsMutex.Unlock()
+
+	if name == "" {
+		panic("store: RegisterIngestTransform with an empty name")
+	}
+	if transform == nil {
+		panic("store: RegisterIngestTransform with a nil transform for " + name)
+	}
+	if _, exists := transforms[name]; exists {
+		panic("store: RegisterIngestTransform called twice for " + name)
+	}
+	transforms[name] = transform
+}
+
+// IngestTransforms returns the sorted names of the registered ingest transforms
+func IngestTransforms() []string {
+	transformsMutex.RLock()
+	defer transformsMutex.RUnlock()
+
+	return slices.Sorted(maps.Keys(transforms))
+}
+
+// SetIngestTransforms enables the registered ingest transforms of a comma separated list of names, e.g.
+// "mask_emails,add_language", applied to the chunks in the order of the list (none by default). It returns an error
+// for a name that is not registered.
+func SetIngestTransforms(spec string) error {
+	transformsMutex.Lock()
+	defer transformsMutex.Unlock()
+
+	enabled := []IngestTransform{}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		transform, ok := transforms[name]
+		if !ok {
+			return fmt.Errorf("unknown ingest transform %q (registered: %s)", name, strings.Join(slices.Sorted(maps.Keys(transforms)), ", "))
+		}
+		enabled = append(enabled, transform)
+	}
+	ingestTransforms = enabled
+	return nil
+}
+
+// transformChunks applies the enabled ingest transforms to the chunks of a document, and replaces the chunk metadata
+// of the options with the metadata of the transformed chunks
+func transformChunks(ctx context.Context, chunks []string, options *ChunkOptions) ([]string, error) {
+	transformsMutex.RLock()
+	enabled := ingestTransforms
+	transformsMutex.RUnlock()
+	if len(enabled) == 0 {
+		return chunks, nil
+	}
+
+	transformed := make([]string, len(chunks))
+	metadata := make([]string, len(chunks))
+	for i, content := range chunks {
+		chunk := Chunk{Content: content, Metadata: options.chunkMetadata(i), SourceID: options.SourceID, Index: i}
+		for _, transform := range enabled {
+			result, err := transform.Transform(ctx, chunk)
+			if err != nil {
+				return nil, fmt.Errorf("failed to transform chunk %d: %w", i, err)
+			}
+			if strings.TrimSpace(result.Content) == "" {
+				return nil, fmt.Errorf("failed to transform chunk %d: empty content", i)
+			}
+			chunk.Content, chunk.Metadata = result.Content, result.Metadata
+		}
+		transformed[i], metadata[i] = chunk.Content, chunk.Metadata
+	}
+	options.ChunkMetadata = metadata
+	return transformed, nil
+}
@@ -0,0 +1,259 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+	"vectormind/models"
+	"vectormind/splitter"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// UsageAttribution identifies who the embedding requests of a context are charged to
+type UsageAttribution struct {
+	Tenant string
+	APIKey string // fingerprint of the API key of the caller, never the key itself
+	Label  string
+}
+
+type usageAttributionKey struct{}
+
+// WithUsageAttribution returns a context charging its embedding requests to the tenant, API key and label of the attribution
+func WithUsageAttribution(ctx context.Context, attribution UsageAttribution) context.Context {
+	return context.WithValue(ctx, usageAttributionKey{}, attribution)
+}
+
+// WithUsageLabel returns a context charging its embedding requests to a label (the tenant and API key are kept)
+func WithUsageLabel(ctx context.Context, label string) context.Context {
+	attribution := usageAttributionFromContext(ctx)
+	attribution.Label = label
+	return WithUsageAttribution(ctx, attribution)
+}
+
+// usageAttributionFromContext returns the attribution of a context (empty when the requests are not attributed)
+func usageAttributionFromContext(ctx context.Context) UsageAttribution {
+	attribution, _ := ctx.Value(usageAttributionKey{}).(UsageAttribution)
+	return attribution
+}
+
+// APIKeyFingerprint identifies an API key in the usage totals without revealing it
+func APIKeyFingerprint(apiKey string) string {
+	if apiKey == "" {
+		return ""
+	}
+	return HashContent(apiKey)[:12]
+}
+
+// MaxUsageLabelLength is the longest label charged in the usage totals: the labels are sent by the callers, a longer
+// label (or a label with control characters) is charged to UsageOtherLabel
+const MaxUsageLabelLength = 128
+
+// MaxUsageEntries is the maximum number of usage totals (tenant, API key, label and model). Beyond it, the usage of
+// the new attributions is charged to UsageOtherLabel, so that the callers cannot grow the totals without limit.
+const MaxUsageEntries = 10000
+
+// UsageOtherLabel is the label charged with the usage of the invalid labels and of the attributions beyond MaxUsageEntries
+const UsageOtherLabel = "(other)"
+
+// usageKeyPrefix is the prefix of the Redis hashes of the usage totals, one by counter ("vectormind:usage:requests",
+// ...), whose fields are the attributions (see usageField)
+const usageKeyPrefix = "vectormind:usage:"
+
+// usageCounters are the counters of the usage totals, each one stored in its own hash
+var usageCounters = []string{"requests", "inputs", "embedding_tokens"}
+
+type usageKey struct {
+	attribution UsageAttribution
+	model       string
+}
+
+// usageTotals accumulates the usage of the embedding providers when the totals are not stored in Redis (see
+// SetUsageClient)
+var (
+	usageTotals = map[usageKey]*models.UsageTotal{}
+	usageMutex  sync.Mutex
+	usageClient *redis.Client
+)
+
+// SetUsageClient stores the usage totals in Redis, shared by the VectorMind instances and kept across restarts
+// (nil: the totals are kept in memory, since the start of the server)
+func SetUsageClient(redisClient *redis.Client) {
+	usageClient = redisClient
+}
+
+// usageField returns the field of an attribution in the hashes of the usage totals
+func usageField(key usageKey) string {
+	field, _ := json.Marshal([]string{key.attribution.Tenant, key.attribution.APIKey, key.attribution.Label, key.model})
+	return string(field)
+}
+
+// parseUsageField returns the attribution of a field of the hashes of the usage totals
+func parseUsageField(field string) (usageKey, bool) {
+	var values []string
+	if err := json.Unmarshal([]byte(field), &values); err != nil || len(values) != 4 {
+		return usageKey{}, false
+	}
+	return usageKey{attribution: UsageAttribution{Tenant: values[0], APIKey: values[1], Label: values[2]}, model: values[3]}, true
+}
+
+// usageLabel returns the label charged for a label sent by a caller
+func usageLabel(label string) string {
+	if len(label) > MaxUsageLabelLength || strings.IndexFunc(label, unicode.IsControl) >= 0 {
+		return UsageOtherLabel
+	}
+	return label
+}
+
+// overflowUsageKey returns the attribution charged instead of a new attribution beyond MaxUsageEntries
+func overflowUsageKey(key usageKey) usageKey {
+	return usageKey{attribution: UsageAttribution{Label: UsageOtherLabel}, model: key.model}
+}
+
+// recordEmbeddingUsage adds an embedding request to the usage totals of the attribution of the context.
+// When the provider does not report the tokens (local model runners), they are estimated from the texts.
+func recordEmbeddingUsage(ctx context.Context, embeddingModelId string, texts []string, tokens int64) {
+	if tokens <= 0 {
+		for _, text := range texts {
+			tokens += int64(splitter.CountTokens(text))
+		}
+	}
+
+	attribution := usageAttributionFromContext(ctx)
+	attribution.Tenant = usageLabel(attribution.Tenant)
+	attribution.Label = usageLabel(attribution.Label)
+	key := usageKey{attribution: attribution, model: embeddingModelId}
+	counts := []int64{1, int64(len(texts)), tokens}
+	if usageClient != nil {
+		// The provider has already been paid: the usage is recorded even when the request is canceled
+		if err := recordStoredUsage(context.WithoutCancel(ctx), usageClient, key, counts); err != nil {
+			log.Printf("🟠 Failed to record the usage of the embedding model %s: %v", embeddingModelId, err)
+		}
+		return
+	}
+
+	usageMutex.Lock()
+	defer usageMutex.Unlock()
+	if _, ok := usageTotals[key]; !ok && len(usageTotals) >= MaxUsageEntries {
+		key = overflowUsageKey(key)
+	}
+	total, ok := usageTotals[key]
+	if !ok {
+		total = &models.UsageTotal{
+			Tenant: key.attribution.Tenant,
+			APIKey: key.attribution.APIKey,
+			Label:  key.attribution.Label,
+			Model:  embeddingModelId,
+		}
+		usageTotals[key] = total
+	}
+	total.Requests += counts[0]
+	total.Inputs += counts[1]
+	total.EmbeddingTokens += counts[2]
+}
+
+// recordStoredUsage adds the counts of an attribution to the usage totals stored in Redis. The number of attributions
+// is checked first: concurrent requests can exceed MaxUsageEntries by a few entries.
+func recordStoredUsage(ctx context.Context, redisClient *redis.Client, key usageKey, counts []int64) error {
+	field := usageField(key)
+	pipe := redisClient.Pipeline()
+	exists := pipe.HExists(ctx, usageKeyPrefix+usageCounters[0], field)
+	entries := pipe.HLen(ctx, usageKeyPrefix+usageCounters[0])
+	if _, err := pipe.Exec(ctx); err != nil {
+		return err
+	}
+	if !exists.Val() && entries.Val() >= MaxUsageEntries {
+		field = usageField(overflowUsageKey(key))
+	}
+
+	pipe = redisClient.TxPipeline()
+	for i, counter := range usageCounters {
+		pipe.HIncrBy(ctx, usageKeyPrefix+counter, field, counts[i])
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// GetUsageTotals returns the usage totals of the embedding providers by tenant, API key, label and model
+func GetUsageTotals(ctx context.Context) ([]models.UsageTotal, error) {
+	var totals []models.UsageTotal
+	if usageClient != nil {
+		var err error
+		if totals, err = storedUsageTotals(ctx, usageClient); err != nil {
+			return nil, fmt.Errorf("failed to get the usage totals: %w", err)
+		}
+	} else {
+		usageMutex.Lock()
+		totals = make([]models.UsageTotal, 0, len(usageTotals))
+		for _, total := range usageTotals {
+			totals = append(totals, *total)
+		}
+		usageMutex.Unlock()
+	}
+
+	sort.Slice(totals, func(i, j int) bool {
+		a, b := totals[i], totals[j]
+		if a.Tenant != b.Tenant {
+			return a.Tenant < b.Tenant
+		}
+		if a.APIKey != b.APIKey {
+			return a.APIKey < b.APIKey
+		}
+		if a.Label != b.Label {
+			return a.Label < b.Label
+		}
+		return a.Model < b.Model
+	})
+	return totals, nil
+}
+
+// storedUsageTotals reads the usage totals stored in Redis (a single round trip)
+func storedUsageTotals(ctx context.Context, redisClient *redis.Client) ([]models.UsageTotal, error) {
+	pipe := redisClient.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, len(usageCounters))
+	for i, counter := range usageCounters {
+		cmds[i] = pipe.HGetAll(ctx, usageKeyPrefix+counter)
+	}
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	totals := make([]models.UsageTotal, 0, len(cmds[0].Val()))
+	for field, requests := range cmds[0].Val() {
+		key, ok := parseUsageField(field)
+		if !ok {
+			continue
+		}
+		total := models.UsageTotal{
+			Tenant: key.attribution.Tenant,
+			APIKey: key.attribution.APIKey,
+			Label:  key.attribution.Label,
+			Model:  key.model,
+		}
+		total.Requests, _ = strconv.ParseInt(requests, 10, 64)
+		total.Inputs, _ = strconv.ParseInt(cmds[1].Val()[field], 10, 64)
+		total.EmbeddingTokens, _ = strconv.ParseInt(cmds[2].Val()[field], 10, 64)
+		totals = append(totals, total)
+	}
+	return totals, nil
+}
+
+// ResetUsageTotals clears the usage totals (in Redis too with SetUsageClient)
+func ResetUsageTotals() {
+	usageMutex.Lock()
+	defer usageMutex.Unlock()
+	usageTotals = map[usageKey]*models.UsageTotal{}
+	if usageClient != nil {
+		keys := make([]string, len(usageCounters))
+		for i, counter := range usageCounters {
+			keys[i] = usageKeyPrefix + counter
+		}
+		usageClient.Del(context.Background(), keys...)
+	}
+}
@@ -0,0 +1,229 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"vectormind/models"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// versionField is the hash field of the version number of a document (no field: version 1)
+const versionField = "version"
+
+// versionStateField is the TAG field marking the previous versions and the deleted documents (versionInactive),
+// excluded from the searches with the document versioning
+const (
+	versionStateField = "version_state"
+	versionInactive   = "inactive"
+)
+
+// ErrVersioningDisabled is returned when the versions of a document are requested without the document versioning
+var ErrVersioningDisabled = errors.New("document versioning is disabled (set DOCUMENT_VERSIONING=true)")
+
+// ErrVersionNotFound is returned when a version of a document does not exist
+var ErrVersionNotFound = fmt.Errorf("version %w", ErrNotFound)
+
+// versioning keeps the previous versions of the updated documents, and marks the deleted documents inactive
+// instead of deleting them (see SetVersioning)
+var versioning bool
+
+// SetVersioning enables the document versioning (DOCUMENT_VERSIONING): an update keeps the previous version of the
+// document under "<id>:v<version>", and a deletion only marks the document deleted, so that the versions can be
+// listed and restored. The previous versions and the deleted documents stay in the index, excluded from the searches
+// by their version_state field.
+func SetVersioning(enabled bool) {
+	versioning = enabled
+}
+
+// VersioningEnabled reports whether the document versioning is enabled
+func VersioningEnabled() bool {
+	return versioning
+}
+
+// VersionID returns the ID of a previous version of a document
+func VersionID(id string, version int) string {
+	return id + ":v" + strconv.Itoa(version)
+}
+
+// inactiveVersionsFilter is the filter expression excluding the previous versions and the deleted documents
+var inactiveVersionsFilter = fmt.Sprintf("-@%s:{%s}", versionStateField, versionInactive)
+
+// activeVersionsQuery restricts a query of the index to the current versions of the documents (with the document
+// versioning only: the indexes created before have no version_state field)
+func activeVersionsQuery(query string) string {
+	if !versioning {
+		return query
+	}
+	return "(" + query + " " + inactiveVersionsFilter + ")"
+}
+
+// storedVersion returns the version number of a document read from Redis
+func storedVersion(value any) int {
+	text, _ := value.(string)
+	if version, err := strconv.Atoi(text); err == nil && version > 0 {
+		return version
+	}
+	return 1
+}
+
+// archivedVersion returns the hash fields of the previous version of a document, stored under VersionID
+func archivedVersion(fields map[string]string, version int) map[string]any {
+	archived := make(map[string]any, len(fields)+2)
+	for field, value := range fields {
+		archived[field] = value
+	}
+	archived[versionField] = version
+	archived[versionStateField] = versionInactive
+	return archived
+}
+
+// softDeleteDocument marks a document deleted (document versioning). It returns false when the document does not
+// exist or is already deleted.
+func softDeleteDocument(ctx context.Context, redisClient *redis.Client, id string) (bool, error) {
+	deleted := false
+	err := redisClient.Watch(ctx, func(tx *redis.Tx) error {
+		stored, err := tx.HMGet(ctx, id, contentField, versionStateField).Result()
+		if err != nil {
+			return err
+		}
+		if stored[0] == nil || stored[1] == versionInactive {
+			return nil
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, id, versionStateField, versionInactive)
+			queueChange(ctx, pipe, ChangeDeleted, id)
+			return nil
+		})
+		deleted = err == nil
+		return err
+	}, id)
+	return deleted, err
+}
+
+// DocumentVersions returns the versions of a document, oldest first: the previous versions, then the current
+// version (Deleted when the document was deleted). The previous versions are read with a single round trip.
+// It returns ErrDocumentNotFound when the document has never been stored.
+func DocumentVersions(ctx context.Context, redisClient *redis.Client, id string, includeEmbedding bool) ([]models.DocumentRecord, error) {
+	if !versioning {
+		return nil, ErrVersioningDisabled
+	}
+	if err := ValidateDocumentID(id); err != nil {
+		return nil, err
+	}
+
+	fields, err := redisClient.HGetAll(ctx, id).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get document %s: %w", id, err)
+	}
+	if len(fields) == 0 {
+		return nil, ErrDocumentNotFound
+	}
+	current := storedVersion(fields[versionField])
+
+	pipe := redisClient.Pipeline()
+	cmds := make([]*redis.MapStringStringCmd, current-1)
+	for version := 1; version < current; version++ {
+		cmds[version-1] = pipe.HGetAll(ctx, VersionID(id, version))
+	}
+	if len(cmds) > 0 {
+		if _, err := pipe.Exec(ctx); err != nil {
+			return nil, fmt.Errorf("failed to get the versions of document %s: %w", id, err)
+		}
+	}
+
+	versions := make([]models.DocumentRecord, 0, current)
+	for i, cmd := range cmds {
+		if len(cmd.Val()) == 0 {
+			continue // version deleted with its collection, or replaced
+		}
+		version := documentRecord(VersionID(id, i+1), cmd.Val(), includeEmbedding)
+		version.Version = i + 1
+		versions = append(versions, version)
+	}
+	record := documentRecord(id, fields, includeEmbedding)
+	record.Version = current
+	record.Deleted = fields[versionStateField] == versionInactive
+	return append(versions, record), nil
+}
+
+// RestoreDocumentVersion makes a version of a document its current version again, and returns the new version
+// number. The current version is kept as a previous version, and the restored version gets the next version number
+// (restoring the current version of a deleted document only undeletes it). The restoration is applied in a
+// transaction watching the document and the restored version. It returns ErrDocumentNotFound when the document
+// does not exist, and ErrVersionNotFound when the version does not exist.
+func RestoreDocumentVersion(ctx context.Context, redisClient *redis.Client, id string, version int) (int, error) {
+	if !versioning {
+		return 0, ErrVersioningDisabled
+	}
+	if err := ValidateDocumentID(id); err != nil {
+		return 0, err
+	}
+
+	restored := 0
+	err := redisClient.Watch(ctx, func(tx *redis.Tx) error {
+		fields, err := tx.HGetAll(ctx, id).Result()
+		if err != nil {
+			return err
+		}
+		if len(fields) == 0 {
+			return ErrDocumentNotFound
+		}
+		current := storedVersion(fields[versionField])
+		if version < 1 || version > current {
+			return ErrVersionNotFound
+		}
+
+		if version == current {
+			restored = current
+			if fields[versionStateField] != versionInactive {
+				return nil
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.HDel(ctx, id, versionStateField)
+				queueChange(ctx, pipe, ChangeStored, id)
+				return nil
+			})
+			return err
+		}
+
+		previous, err := tx.HGetAll(ctx, VersionID(id, version)).Result()
+		if err != nil {
+			return err
+		}
+		if len(previous) == 0 {
+			return ErrVersionNotFound
+		}
+		// The restored version gets the next version number: the restoration is itself a version
+		restoredFields := make(map[string]any, len(previous))
+		for field, value := range previous {
+			restoredFields[field] = value
+		}
+		delete(restoredFields, versionStateField)
+		restoredFields[versionField] = current + 1
+		restoredFields["updated_at"] = time.Now().Unix()
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.HSet(ctx, VersionID(id, current), archivedVersion(fields, current))
+			pipe.Del(ctx, id)
+			pipe.HSet(ctx, id, restoredFields)
+			queueChange(ctx, pipe, ChangeUpdated, id)
+			return nil
+		})
+		if err == nil {
+			restored = current + 1
+		}
+		return err
+	}, id, VersionID(id, version))
+
+	if errors.Is(err, ErrNotFound) {
+		return 0, err
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to restore version %d of document %s: %w", version, id, err)
+	}
+	return restored, nil
+}
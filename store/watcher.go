@@ -0,0 +1,309 @@
+package store
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+	"vectormind/splitter"
+
+	"github.com/openai/openai-go"
+	"github.com/redis/go-redis/v9"
+)
+
+// watchStateKeyPrefix is the prefix of the Redis hash holding the files ingested by the directory watcher, by path
+// ("vectormind:watch:<index name>")
+const watchStateKeyPrefix = "vectormind:watch:"
+
+// DefaultWatchInterval is the default interval between two scans of the watched directories
+const DefaultWatchInterval = 10 * time.Second
+
+// WatchMaxFileSize is the largest file ingested by the directory watcher, in bytes (larger files are not watched)
+const WatchMaxFileSize = 10 << 20
+
+// watchedExtensions are the extensions of the files ingested by the directory watcher
+var watchedExtensions = []string{".md", ".markdown", ".txt"}
+
+// WatchOptions configures the directory watcher
+type WatchOptions struct {
+	Dirs      []string      // absolute paths of the watched directories (see ParseWatchDirs)
+	Interval  time.Duration // interval between two scans (default: DefaultWatchInterval)
+	Label     string        // label of the chunks of the files (optional)
+	MaxTokens int           // maximum number of tokens of a chunk
+	IndexName string        // index of the chunks
+}
+
+// WatchScan reports the changes applied by a scan of the watched directories
+type WatchScan struct {
+	Stored  int // new files
+	Updated int // files whose content changed
+	Deleted int // files removed from the directories
+	Failed  int // files that could not be ingested (retried by the next scan)
+}
+
+// watchedFile is the state of a file ingested by the directory watcher
+type watchedFile struct {
+	Hash     string   `json:"hash"`      // SHA-256 of the content
+	ModTime  int64    `json:"mod_time"`  // modification time (Unix nanoseconds)
+	Size     int64    `json:"size"`      // size in bytes
+	ChunkIDs []string `json:"chunk_ids"` // IDs of the stored chunks
+}
+
+// ParseWatchDirs parses a comma separated list of directories, e.g. "/notes,/docs/wiki", to absolute paths
+func ParseWatchDirs(spec string) ([]string, error) {
+	dirs := []string{}
+	for _, dir := range strings.Split(spec, ",") {
+		dir = strings.TrimSpace(dir)
+		if dir == "" {
+			continue
+		}
+		absolute, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid directory %q: %w", dir, err)
+		}
+		info, err := os.Stat(absolute)
+		if err != nil {
+			return nil, fmt.Errorf("invalid directory %q: %w", dir, err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("%q is not a directory", dir)
+		}
+		if !slices.Contains(dirs, absolute) {
+			dirs = append(dirs, absolute)
+		}
+	}
+	return dirs, nil
+}
+
+// WatchSourceID returns the source ID of the chunks of a watched file, derived from its path: the chunks of a file
+// keep the same IDs as long as their content does not change (see IDStrategyContentHash)
+func WatchSourceID(path string) string {
+	return "file-" + HashContent(path)[:16]
+}
+
+// RunDirectoryWatcher keeps the chunks of the markdown and text files of the watched directories in sync with the
+// files: every interval, the new and changed files are split (with the strategy of their extension) and stored, and
+// the chunks of the deleted files are removed. It returns when the context is done.
+func RunDirectoryWatcher(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId string, options WatchOptions) {
+	if options.Interval <= 0 {
+		options.Interval = DefaultWatchInterval
+	}
+	ticker := time.NewTicker(options.Interval)
+	defer ticker.Stop()
+
+	for {
+		scan, err := ScanWatchedDirs(ctx, openaiClient, redisClient, embeddingModelId, options)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("🟠 Failed to scan the watched directories: %v", err)
+		}
+		if scan.Stored+scan.Updated+scan.Deleted > 0 {
+			log.Printf("📂 Watched directories: %d files stored, %d updated, %d deleted", scan.Stored, scan.Updated, scan.Deleted)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// ScanWatchedDirs applies the changes of the files of the watched directories since the previous scan (see
+// RunDirectoryWatcher). The files that cannot be ingested are logged and retried by the next scan.
+func ScanWatchedDirs(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId string, options WatchOptions) (WatchScan, error) {
+	scan := WatchScan{}
+	stateKey := watchStateKeyPrefix + options.IndexName
+	stored, err := redisClient.HGetAll(ctx, stateKey).Result()
+	if err != nil {
+		return scan, fmt.Errorf("failed to read the state of the watched files: %w", err)
+	}
+
+	seen := map[string]bool{}
+	for _, dir := range options.Dirs {
+		err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				// An unreadable directory is skipped, its files are not deleted
+				if entry != nil && entry.IsDir() && path != dir {
+					log.Printf("🟠 Failed to read the watched directory %s: %v", path, err)
+					return fs.SkipDir
+				}
+				return err
+			}
+			if entry.IsDir() || !slices.Contains(watchedExtensions, strings.ToLower(filepath.Ext(path))) {
+				return nil
+			}
+			// The symbolic links are not followed, they could designate any file of the server, and the files
+			// that are too large are not read: both are not watched
+			if entry.Type()&fs.ModeSymlink != 0 {
+				return nil
+			}
+			if info, err := entry.Info(); err != nil || info.Size() > WatchMaxFileSize {
+				return nil
+			}
+			seen[path] = true
+
+			var previous *watchedFile
+			if value, ok := stored[path]; ok {
+				previous = &watchedFile{}
+				if err := json.Unmarshal([]byte(value), previous); err != nil {
+					previous = nil
+				}
+			}
+			changed, err := ingestWatchedFile(ctx, openaiClient, redisClient, embeddingModelId, stateKey, path, previous, options)
+			switch {
+			case err != nil:
+				log.Printf("🟠 Failed to ingest the watched file %s: %v", path, err)
+				scan.Failed++
+			case changed && previous == nil:
+				scan.Stored++
+			case changed:
+				scan.Updated++
+			}
+			return ctx.Err()
+		})
+		if err != nil {
+			return scan, err
+		}
+	}
+
+	// The chunks of the files deleted from the watched directories are removed
+	for path, value := range stored {
+		if seen[path] || !watchedPath(path, options.Dirs) {
+			continue
+		}
+		var file watchedFile
+		if err := json.Unmarshal([]byte(value), &file); err == nil && len(file.ChunkIDs) > 0 {
+			if _, _, err := DeleteDocuments(ctx, redisClient, file.ChunkIDs); err != nil {
+				log.Printf("🟠 Failed to delete the chunks of the watched file %s: %v", path, err)
+				scan.Failed++
+				continue
+			}
+		}
+		if err := redisClient.HDel(ctx, stateKey, path).Err(); err != nil {
+			return scan, fmt.Errorf("failed to update the state of the watched files: %w", err)
+		}
+		scan.Deleted++
+	}
+	return scan, nil
+}
+
+// readWatchedFile reads a file of the watched directories, up to WatchMaxFileSize bytes. The opened file must be the
+// file that was checked (info): a file replaced meanwhile, e.g. by a symbolic link, is not read.
+func readWatchedFile(path string, info fs.FileInfo) ([]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	opened, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !os.SameFile(info, opened) {
+		return nil, fmt.Errorf("the file was replaced while it was read")
+	}
+	content, err := io.ReadAll(io.LimitReader(file, WatchMaxFileSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > WatchMaxFileSize {
+		return nil, fmt.Errorf("the file is larger than %d bytes", WatchMaxFileSize)
+	}
+	return content, nil
+}
+
+// ingestWatchedFile stores the chunks of a new or changed file and removes its chunks that are not part of its
+// content anymore. It returns false when the file did not change since the previous scan.
+func ingestWatchedFile(ctx context.Context, openaiClient openai.Client, redisClient *redis.Client, embeddingModelId, stateKey, path string, previous *watchedFile, options WatchOptions) (bool, error) {
+	info, err := os.Lstat(path)
+	if err != nil {
+		return false, err
+	}
+	if !info.Mode().IsRegular() || info.Size() > WatchMaxFileSize {
+		return false, fmt.Errorf("not a regular file of at most %d bytes", WatchMaxFileSize)
+	}
+	// Same modification time and size: the file is not read again
+	if previous != nil && previous.ModTime == info.ModTime().UnixNano() && previous.Size == info.Size() {
+		return false, nil
+	}
+
+	content, err := readWatchedFile(path, info)
+	if err != nil {
+		return false, err
+	}
+	file := watchedFile{Hash: HashContent(string(content)), ModTime: info.ModTime().UnixNano(), Size: info.Size(), ChunkIDs: []string{}}
+	changed := previous == nil || previous.Hash != file.Hash
+
+	if changed {
+		// The text files without configured strategy are split by tokens
+		config, ok := splitter.ConfigForFile(path)
+		if !ok {
+			config.Strategy = "tokens"
+		}
+		chunks, err := splitter.Split(config.Strategy, string(content), config.Options, options.MaxTokens)
+		if err != nil {
+			return false, err
+		}
+		if len(chunks) > 0 {
+			metadata, err := json.Marshal(map[string]string{"path": path, "file_hash": file.Hash})
+			if err != nil {
+				return false, err
+			}
+			statuses, err := StoreChunks(ctx, openaiClient, redisClient, embeddingModelId, chunks, ChunkOptions{
+				Label:      options.Label,
+				Metadata:   string(metadata),
+				IDStrategy: IDStrategyContentHash,
+				SourceID:   WatchSourceID(path),
+				Original:   string(content),
+				IndexName:  options.IndexName,
+			})
+			if err != nil {
+				return false, err
+			}
+			file.ChunkIDs, _ = StoredChunkIDs(statuses)
+		}
+
+		// The chunks of the previous content that were not stored again are removed
+		if previous != nil {
+			removed := []string{}
+			for _, id := range previous.ChunkIDs {
+				if !slices.Contains(file.ChunkIDs, id) {
+					removed = append(removed, id)
+				}
+			}
+			if len(removed) > 0 {
+				if _, _, err := DeleteDocuments(ctx, redisClient, removed); err != nil {
+					return false, err
+				}
+			}
+		}
+	} else {
+		file.ChunkIDs = previous.ChunkIDs
+	}
+
+	value, err := json.Marshal(file)
+	if err != nil {
+		return false, err
+	}
+	if err := redisClient.HSet(ctx, stateKey, path, value).Err(); err != nil {
+		return false, fmt.Errorf("failed to update the state of the watched files: %w", err)
+	}
+	return changed, nil
+}
+
+// watchedPath reports whether a path is in one of the watched directories
+func watchedPath(path string, dirs []string) bool {
+	for _, dir := range dirs {
+		if strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,220 @@
+// Package testsupport generates synthetic corpora for the tests and the evaluations of the searches: documents with a
+// known structure and labels, planted near-duplicate pairs and queries whose relevant documents are known, so that
+// the recall of the searches can be checked after a refactoring. The same seed always gives the same corpus.
+package testsupport
+
+import (
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+// DefaultLabels are the labels given in turn to the documents of a corpus without labels
+var DefaultLabels = []string{"guides", "reference", "faq"}
+
+// CorpusOptions holds the settings of a generated corpus (the zero values select the defaults)
+type CorpusOptions struct {
+	Seed      uint64
+	Documents int      // number of distinct documents (default: 50)
+	Sections  int      // number of sections of each document (default: 3)
+	Labels    []string // labels given in turn to the documents (default: DefaultLabels)
+	// NearDuplicates is the number of documents copied with a few words changed (planted near-duplicate pairs)
+	NearDuplicates int
+	Queries        int // number of queries (default: one per document, at most Documents)
+}
+
+// Document is a generated markdown document: a title and sections of filler sentences carrying the key terms
+// of the document, which no other document has (except its near duplicates)
+type Document struct {
+	ID       string
+	Label    string
+	Title    string
+	KeyTerms []string
+	Sections []string // the sections of the content, each starting with its "## " header
+	Content  string
+}
+
+// NearDuplicate is a planted pair of documents: the duplicate is the original with a few words changed
+type NearDuplicate struct {
+	OriginalID  string
+	DuplicateID string
+}
+
+// Query is a search of the key terms of a document, with the documents relevant to it: the document and its near
+// duplicates
+type Query struct {
+	Text        string
+	Label       string // label of the relevant documents
+	RelevantIDs []string
+}
+
+// Corpus is a generated corpus. The documents are ordered by ID, the near duplicates follow the originals.
+type Corpus struct {
+	Seed           uint64
+	Documents      []Document
+	NearDuplicates []NearDuplicate
+	Queries        []Query
+}
+
+// syllables build the key terms: made-up words that cannot be found in the filler text
+var syllables = []string{"ka", "lo", "mi", "ru", "ze", "vo", "ti", "na", "pe", "shu", "gri", "dax"}
+
+// fillerWords are the common words of the sentences of the documents
+var fillerWords = []string{
+	"the", "system", "stores", "each", "record", "with", "a", "short", "summary", "and", "keeps", "an", "index",
+	"of", "related", "entries", "so", "that", "readers", "can", "find", "details", "quickly", "when", "they",
+	"search", "for", "configuration", "notes", "about", "release", "steps", "network", "settings", "storage",
+	"limits", "team", "reviews", "changes", "before", "publishing", "them", "to", "production",
+}
+
+// Generate returns the corpus of the options. The same options always give the same corpus.
+func Generate(options CorpusOptions) Corpus {
+	if options.Documents <= 0 {
+		options.Documents = 50
+	}
+	if options.Sections <= 0 {
+		options.Sections = 3
+	}
+	if len(options.Labels) == 0 {
+		options.Labels = DefaultLabels
+	}
+	options.NearDuplicates = min(max(options.NearDuplicates, 0), options.Documents)
+	if options.Queries <= 0 || options.Queries > options.Documents {
+		options.Queries = options.Documents
+	}
+
+	random := rand.New(rand.NewPCG(options.Seed, 0x5eed))
+	corpus := Corpus{Seed: options.Seed}
+
+	originals := make([]Document, options.Documents)
+	for i := range originals {
+		keyTerms := []string{keyTerm(3 * i), keyTerm(3*i + 1), keyTerm(3*i + 2)}
+		title := fmt.Sprintf("%s %s", capitalize(keyTerms[0]), capitalize(keyTerms[1]))
+		sections := make([]string, options.Sections)
+		for s := range sections {
+			sections[s] = fmt.Sprintf("## %s part %d\n%s", capitalize(keyTerms[s%len(keyTerms)]), s+1, paragraph(random, keyTerms))
+		}
+		originals[i] = newDocument(documentID(options.Seed, i, false), options.Labels[i%len(options.Labels)], title, keyTerms, sections)
+	}
+
+	// Near duplicates of documents chosen at random
+	duplicates := map[int]Document{}
+	for _, i := range random.Perm(options.Documents)[:options.NearDuplicates] {
+		original := originals[i]
+		sections := slices.Clone(original.Sections)
+		for s := range sections {
+			sections[s] = changeWords(random, sections[s], 2)
+		}
+		duplicates[i] = newDocument(documentID(options.Seed, i, true), original.Label, original.Title, original.KeyTerms, sections)
+		corpus.NearDuplicates = append(corpus.NearDuplicates, NearDuplicate{OriginalID: original.ID, DuplicateID: duplicates[i].ID})
+	}
+	slices.SortFunc(corpus.NearDuplicates, func(a, b NearDuplicate) int { return strings.Compare(a.OriginalID, b.OriginalID) })
+
+	for i, original := range originals {
+		corpus.Documents = append(corpus.Documents, original)
+		if duplicate, ok := duplicates[i]; ok {
+			corpus.Documents = append(corpus.Documents, duplicate)
+		}
+	}
+
+	// Queries of the key terms of documents chosen at random
+	for _, i := range random.Perm(options.Documents)[:options.Queries] {
+		original := originals[i]
+		query := Query{
+			Text:        fmt.Sprintf("What about %s and %s?", original.KeyTerms[0], original.KeyTerms[2]),
+			Label:       original.Label,
+			RelevantIDs: []string{original.ID},
+		}
+		if duplicate, ok := duplicates[i]; ok {
+			query.RelevantIDs = append(query.RelevantIDs, duplicate.ID)
+		}
+		corpus.Queries = append(corpus.Queries, query)
+	}
+	return corpus
+}
+
+// Document returns the document of the corpus having an ID
+func (c Corpus) Document(id string) (Document, bool) {
+	index := slices.IndexFunc(c.Documents, func(d Document) bool { return d.ID == id })
+	if index < 0 {
+		return Document{}, false
+	}
+	return c.Documents[index], true
+}
+
+// IDs returns the IDs of the documents of the corpus
+func (c Corpus) IDs() []string {
+	ids := make([]string, len(c.Documents))
+	for i, document := range c.Documents {
+		ids[i] = document.ID
+	}
+	return ids
+}
+
+// newDocument returns a document with the content of its title and sections
+func newDocument(id, label, title string, keyTerms, sections []string) Document {
+	return Document{
+		ID:       id,
+		Label:    label,
+		Title:    title,
+		KeyTerms: keyTerms,
+		Sections: sections,
+		Content:  "# " + title + "\n\n" + strings.Join(sections, "\n\n"),
+	}
+}
+
+// documentID returns the ID of the nth document of a corpus, or of its near duplicate
+func documentID(seed uint64, n int, duplicate bool) string {
+	id := fmt.Sprintf("doc:corpus-%d-%04d", seed, n)
+	if duplicate {
+		id += "-dup"
+	}
+	return id
+}
+
+// keyTerm returns the nth key term: n written in base len(syllables), with a syllable per digit (at least 3),
+// so that each n gives another term
+func keyTerm(n int) string {
+	var term strings.Builder
+	for digits := 0; digits < 3 || n > 0; digits++ {
+		term.WriteString(syllables[n%len(syllables)])
+		n /= len(syllables)
+	}
+	return term.String()
+}
+
+// paragraph returns a few sentences of filler words, each one carrying one of the key terms
+func paragraph(random *rand.Rand, keyTerms []string) string {
+	sentences := make([]string, 3+random.IntN(3))
+	for i := range sentences {
+		words := make([]string, 8+random.IntN(8))
+		for w := range words {
+			words[w] = fillerWords[random.IntN(len(fillerWords))]
+		}
+		words[random.IntN(len(words))] = keyTerms[random.IntN(len(keyTerms))]
+		sentences[i] = capitalize(strings.Join(words, " ")) + "."
+	}
+	return strings.Join(sentences, " ")
+}
+
+// changeWords replaces n filler words of a text by other filler words (the header line and the key terms are kept)
+func changeWords(random *rand.Rand, text string, n int) string {
+	header, body, _ := strings.Cut(text, "\n")
+	words := strings.Fields(body)
+	for range n {
+		i := random.IntN(len(words))
+		if slices.Contains(fillerWords, words[i]) {
+			words[i] = fillerWords[random.IntN(len(fillerWords))]
+		}
+	}
+	return header + "\n" + strings.Join(words, " ")
+}
+
+// capitalize returns a text with an uppercase first letter
+func capitalize(text string) string {
+	if text == "" {
+		return text
+	}
+	return strings.ToUpper(text[:1]) + text[1:]
+}
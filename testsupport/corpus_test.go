@@ -0,0 +1,148 @@
+package testsupport
+
+import (
+	"cmp"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestGenerate_Deterministic(t *testing.T) {
+	options := CorpusOptions{Seed: 42, Documents: 20, NearDuplicates: 5, Queries: 10}
+	if first, second := Generate(options), Generate(options); !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the same corpus for the same seed")
+	}
+	options.Seed = 43
+	if Generate(CorpusOptions{Seed: 42, Documents: 20}).Documents[0].Content == Generate(options).Documents[0].Content {
+		t.Errorf("Expected another content for another seed")
+	}
+}
+
+func TestGenerate_Structure(t *testing.T) {
+	corpus := Generate(CorpusOptions{Seed: 7, Documents: 30, Sections: 4, Labels: []string{"a", "b"}, NearDuplicates: 6, Queries: 12})
+
+	if len(corpus.Documents) != 36 || len(corpus.NearDuplicates) != 6 || len(corpus.Queries) != 12 {
+		t.Fatalf("Expected 36 documents, 6 near duplicates and 12 queries, got %d, %d and %d", len(corpus.Documents), len(corpus.NearDuplicates), len(corpus.Queries))
+	}
+
+	ids := map[string]bool{}
+	keyTerms := map[string]string{}
+	for _, document := range corpus.Documents {
+		if ids[document.ID] {
+			t.Errorf("Duplicated ID %s", document.ID)
+		}
+		ids[document.ID] = true
+		if len(document.Sections) != 4 || !strings.HasPrefix(document.Content, "# "+document.Title+"\n") {
+			t.Errorf("Unexpected structure of %s: %q", document.ID, document.Content)
+		}
+		if document.Label != "a" && document.Label != "b" {
+			t.Errorf("Unexpected label of %s: %q", document.ID, document.Label)
+		}
+		// The key terms belong to a single document and its near duplicate
+		for _, term := range document.KeyTerms {
+			original := strings.TrimSuffix(document.ID, "-dup")
+			if other, ok := keyTerms[term]; ok && other != original {
+				t.Errorf("Key term %q of %s is also a key term of %s", term, document.ID, other)
+			}
+			keyTerms[term] = original
+		}
+	}
+
+	for _, pair := range corpus.NearDuplicates {
+		original, ok := corpus.Document(pair.OriginalID)
+		duplicate, dupOK := corpus.Document(pair.DuplicateID)
+		if !ok || !dupOK {
+			t.Fatalf("Unknown document in the pair %+v", pair)
+		}
+		if original.Content == duplicate.Content {
+			t.Errorf("Expected %s to differ from its original", duplicate.ID)
+		}
+		if similarity := cosine(Embedding(original.Content, 256), Embedding(duplicate.Content, 256)); similarity < 0.9 {
+			t.Errorf("Expected %s close to its original, got a similarity of %.2f", duplicate.ID, similarity)
+		}
+	}
+
+	for _, query := range corpus.Queries {
+		for _, id := range query.RelevantIDs {
+			document, ok := corpus.Document(id)
+			if !ok || document.Label != query.Label {
+				t.Fatalf("Unexpected relevant document %s of %q", id, query.Text)
+			}
+			for _, term := range document.KeyTerms[:1] {
+				if !strings.Contains(query.Text, term) || !strings.Contains(strings.ToLower(document.Content), term) {
+					t.Errorf("Expected the key term %q in the query %q and in %s", term, query.Text, id)
+				}
+			}
+		}
+	}
+}
+
+func TestGenerate_EmbeddingRecall(t *testing.T) {
+	corpus := Generate(CorpusOptions{Seed: 1, Documents: 40, NearDuplicates: 8, Queries: 20})
+
+	// Exact nearest neighbors of each query among the documents of its label
+	var recall float64
+	for _, query := range corpus.Queries {
+		vector := Embedding(query.Text, 128)
+		candidates := slices.DeleteFunc(slices.Clone(corpus.Documents), func(d Document) bool { return d.Label != query.Label })
+		slices.SortStableFunc(candidates, func(a, b Document) int {
+			return cmp.Compare(cosine(vector, Embedding(b.Content, 128)), cosine(vector, Embedding(a.Content, 128)))
+		})
+		retrieved := make([]string, len(candidates))
+		for i, candidate := range candidates {
+			retrieved[i] = candidate.ID
+		}
+		recall += RecallAtK(query.RelevantIDs, retrieved, 3)
+	}
+	if recall /= float64(len(corpus.Queries)); recall < 0.9 {
+		t.Errorf("Expected a recall@3 of at least 0.9, got %.2f", recall)
+	}
+}
+
+func TestEmbedding(t *testing.T) {
+	if vector := Embedding("", 8); len(vector) != 8 {
+		t.Errorf("Expected a vector of dimension 8, got %d", len(vector))
+	}
+	query := Embedding("kalomi and rutize", 64)
+	relevant := Embedding("The kalomi stores a rutize record", 64)
+	other := Embedding("Network settings of production", 64)
+	if cosine(query, relevant) <= cosine(query, other) {
+		t.Errorf("Expected the query closer to the text sharing its words")
+	}
+	if norm := cosine(relevant, relevant); norm < 0.999 || norm > 1.001 {
+		t.Errorf("Expected a normalized vector, got a norm of %f", norm)
+	}
+}
+
+func TestRecallAtK(t *testing.T) {
+	tests := []struct {
+		name      string
+		relevant  []string
+		retrieved []string
+		k         int
+		expected  float64
+	}{
+		{name: "All found", relevant: []string{"a", "b"}, retrieved: []string{"b", "a", "c"}, k: 2, expected: 1},
+		{name: "Half found in k", relevant: []string{"a", "b"}, retrieved: []string{"a", "c", "b"}, k: 2, expected: 0.5},
+		{name: "Fewer results than k", relevant: []string{"a"}, retrieved: []string{"a"}, k: 10, expected: 1},
+		{name: "None found", relevant: []string{"a"}, retrieved: []string{"b"}, k: 1, expected: 0},
+		{name: "No relevant document", retrieved: []string{"b"}, k: 1, expected: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if recall := RecallAtK(tt.relevant, tt.retrieved, tt.k); recall != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, recall)
+			}
+		})
+	}
+}
+
+// cosine returns the dot product of two vectors (their cosine similarity, the vectors being normalized)
+func cosine(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i] * b[i])
+	}
+	return dot
+}
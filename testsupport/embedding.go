@@ -0,0 +1,57 @@
+package testsupport
+
+import (
+	"hash/fnv"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// Embedding returns a deterministic embedding of a text, without an embedding model: the normalized counts of its
+// lowercased words hashed into dimension buckets. Texts sharing words have close vectors, so that the vector searches
+// of a generated corpus can be checked (the queries share the key terms of their relevant documents).
+func Embedding(text string, dimension int) []float32 {
+	vector := make([]float32, dimension)
+	if dimension <= 0 {
+		return vector
+	}
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		hash := fnv.New32a()
+		hash.Write([]byte(word))
+		vector[hash.Sum32()%uint32(dimension)]++
+	}
+
+	var norm float64
+	for _, value := range vector {
+		norm += float64(value * value)
+	}
+	if norm == 0 {
+		return vector
+	}
+	norm = math.Sqrt(norm)
+	for i := range vector {
+		vector[i] = float32(float64(vector[i]) / norm)
+	}
+	return vector
+}
+
+// RecallAtK returns the share of the relevant IDs found in the first k retrieved IDs (1 without relevant ID)
+func RecallAtK(relevant, retrieved []string, k int) float64 {
+	if len(relevant) == 0 {
+		return 1
+	}
+	retrieved = retrieved[:min(k, len(retrieved))]
+	found := 0
+	for _, id := range relevant {
+		for _, candidate := range retrieved {
+			if candidate == id {
+				found++
+				break
+			}
+		}
+	}
+	return float64(found) / float64(len(relevant))
+}